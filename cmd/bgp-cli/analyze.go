@@ -1,9 +1,8 @@
 package main
 
 import (
-	"bufio"
-	"compress/gzip"
 	"container/heap"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
@@ -11,30 +10,43 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
-	"github.com/osrg/gobgp/v3/pkg/packet/mrt"
 	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
 	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
 	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
 	"github.com/sudorandom/bgp-stream/pkg/utils"
 )
 
 type AnalyzeCmd struct {
-	Start   string `required:"" help:"Start time (YYYY-MM-DD HH:mm)"`
-	End     string `required:"" help:"End time (YYYY-MM-DD HH:mm)"`
-	RRCs    string `default:"" help:"Comma-separated list of RRCs (e.g. rrc00,rrc01). Defaults to all 27."`
-	CSV     string `default:"transitions.csv" help:"Output CSV file for state transitions"`
-	Summary string `default:"summary.txt" help:"Output text summary"`
-	Cache   string `default:"data/mrt-cache" help:"Directory for cached MRT files"`
-	Workers int    `default:"0" help:"Number of parallel classification workers (default: runtime.NumCPU())"`
+	Start      string `required:"" help:"Start time (YYYY-MM-DD HH:mm)"`
+	End        string `required:"" help:"End time (YYYY-MM-DD HH:mm)"`
+	Collectors string `name:"collectors" aliases:"rr-cs" default:"" help:"Comma-separated collectors as [name=]source: RIS (rrc00), RouteViews (route-views2) or a local directory or glob of MRT files. Defaults to all 27 RIS collectors."`
+	CSV        string `default:"transitions.csv" help:"Output CSV file for state transitions"`
+	Summary    string `default:"summary.txt" help:"Output text summary"`
+	HTML       string `name:"html" default:"" help:"Also write a self-contained HTML incident report to this file."`
+	Cache      string `default:"data/mrt-cache" help:"Directory for cached MRT files"`
+	Workers    int    `default:"0" help:"Number of parallel classification workers (default: runtime.NumCPU())"`
+
+	FullBogons bool `help:"Also flag prefixes and ASNs from unallocated space (requires bgp-cli fetch)."`
+
+	Bootstrap bool     `help:"Seed prefix state and seen prefixes from each collector's RIB dump taken at or before --start."`
+	RIB       []string `name:"rib" help:"TABLE_DUMP_V2 RIB dump to seed from, as [collector=]path (can be specified multiple times)."`
+	SeenDB    string   `default:"" help:"Seen prefixes database for historical origins. RIB dumps add the prefixes it lacks (empty for a temporary one)."`
+
+	Checkpoint      string        `default:"analyze-checkpoint" help:"Directory to save checkpoints in, removed once the run completes (empty to disable)."`
+	CheckpointEvery time.Duration `default:"10m" help:"How often to save a checkpoint."`
+	Resume          bool          `help:"Continue an interrupted run with the same times and collectors from its last checkpoint."`
 }
 
 func (c *AnalyzeCmd) Run() error {
@@ -47,12 +59,9 @@ func (c *AnalyzeCmd) Run() error {
 		return fmt.Errorf("invalid end time: %v", err)
 	}
 
-	rrcs := strings.Split(c.RRCs, ",")
-	if c.RRCs == "" {
-		rrcs = []string{}
-		for i := 0; i <= 26; i++ {
-			rrcs = append(rrcs, fmt.Sprintf("rrc%02d", i))
-		}
+	collectors, err := parseCollectors(c.Collectors)
+	if err != nil {
+		return err
 	}
 
 	numWorkers := c.Workers
@@ -60,11 +69,31 @@ func (c *AnalyzeCmd) Run() error {
 		numWorkers = runtime.NumCPU()
 	}
 
+	var cp *checkpointer
+	csvOffset := int64(-1)
+	switch {
+	case c.Checkpoint != "":
+		cp = newCheckpointer(c.Checkpoint, c.CheckpointEvery, startTime, endTime, collectors)
+		if c.Resume {
+			if err := cp.load(); err != nil {
+				return err
+			}
+			csvOffset = cp.resumed.CSVOffset
+		} else if cp.exists() {
+			return fmt.Errorf("%s holds the checkpoint of an earlier run: continue it with --resume or remove it", c.Checkpoint)
+		}
+	case c.Resume:
+		return fmt.Errorf("--resume needs a --checkpoint directory")
+	}
+
 	geo, asnMapping, rpki := setupDependencies()
 	defer func() { _ = geo.Close() }()
 
-	csvWriter, closeCSV := setupCSVWriter(c.CSV)
+	csvWriter, csvSize, closeCSV := setupCSVWriter(c.CSV, csvOffset)
 	defer closeCSV()
+	if cp != nil {
+		cp.csvOffset = csvSize
+	}
 
 	// Custom TimeProvider (shared, atomic update)
 	var currentTime int64
@@ -73,10 +102,71 @@ func (c *AnalyzeCmd) Run() error {
 	}
 
 	masterClassifier := bgp_pkg.NewClassifier(nil, nil, asnMapping, rpki, prefixToIP, nil, timeProvider)
+	masterClassifier.SetPeerTracker(bgp_pkg.NewPeerTracker())
+	if c.FullBogons {
+		bogons, err := utils.LoadFullBogons(utils.FullBogonsPath)
+		if err != nil {
+			return fmt.Errorf("failed to load full bogons: %v", err)
+		}
+		masterClassifier.SetFullBogons(bogons)
+	}
+
+	// A resumed run already holds the state seeded from RIB dumps.
+	var ribs []ribFile
+	if cp == nil || cp.resumed == nil {
+		for _, r := range c.RIB {
+			ribs = append(ribs, parseRIBFile(r))
+		}
+		if c.Bootstrap {
+			ribs = append(ribs, collectorRIBs(collectors, startTime, c.Cache)...)
+		}
+	}
 
-	runReplay(startTime, endTime, rrcs, c.Cache, numWorkers, timeProvider, &currentTime, masterClassifier, csvWriter)
+	seenPath := c.SeenDB
+	if seenPath == "" && (len(c.RIB) > 0 || c.Bootstrap) {
+		if cp != nil {
+			// Keep the seen prefixes added by RIB dumps for a resumed run.
+			if err := os.MkdirAll(c.Checkpoint, 0o755); err != nil {
+				return fmt.Errorf("failed to create checkpoint directory: %v", err)
+			}
+			seenPath = filepath.Join(c.Checkpoint, "seen-prefixes.db")
+		} else {
+			dir, err := os.MkdirTemp("", "bgp-analyze-seen")
+			if err != nil {
+				return fmt.Errorf("failed to create temporary seen prefixes database: %v", err)
+			}
+			defer func() { _ = os.RemoveAll(dir) }()
+			seenPath = filepath.Join(dir, "seen-prefixes.db")
+		}
+	}
+	var seenDB *utils.DiskTrie
+	if seenPath != "" {
+		seenDB, err = utils.OpenDiskTrie(seenPath)
+		if err != nil {
+			return fmt.Errorf("failed to open seen prefixes database: %v", err)
+		}
+		defer func() { _ = seenDB.Close() }()
+	}
+
+	var report *incidentReport
+	if c.HTML != "" {
+		report = newIncidentReport(startTime, endTime)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := runReplay(ctx, startTime, endTime, collectors, c.Cache, numWorkers, timeProvider, &currentTime, masterClassifier, csvWriter, seenDB, ribs, report, cp); err != nil {
+		return err
+	}
 
 	writeSummary(c.Summary, masterClassifier)
+	if report != nil {
+		if err := report.write(c.HTML, collectors, geo, asnMapping); err != nil {
+			return err
+		}
+	}
+	cp.remove()
 	return nil
 }
 
@@ -96,25 +186,60 @@ func setupDependencies() (*geoservice.GeoService, *utils.ASNMapping, *utils.RPKI
 	return geo, asnMapping, rpki
 }
 
-func setupCSVWriter(csvFile string) (writer *csv.Writer, closer func()) {
-	fCsv, err := os.Create(csvFile)
+// setupCSVWriter creates the transitions CSV or, with a resumeAt offset of 0
+// or more, continues the one of a resumed run from there. size flushes the
+// writer and returns how much has been written.
+func setupCSVWriter(csvFile string, resumeAt int64) (writer *csv.Writer, size func() int64, closer func()) {
+	var fCsv *os.File
+	var err error
+	if resumeAt < 0 {
+		fCsv, err = os.Create(csvFile)
+	} else {
+		fCsv, err = os.OpenFile(csvFile, os.O_WRONLY|os.O_CREATE, 0o644)
+		if err == nil {
+			err = fCsv.Truncate(resumeAt)
+		}
+		if err == nil {
+			_, err = fCsv.Seek(resumeAt, io.SeekStart)
+		}
+	}
 	if err != nil {
 		log.Fatalf("Failed to create CSV file: %v", err)
 	}
 	csvWriter := csv.NewWriter(fCsv)
-	_ = csvWriter.Write([]string{"timestamp", "prefix", "old_type", "new_type", "origin_asn"})
-
-	return csvWriter, func() {
-		csvWriter.Flush()
-		_ = fCsv.Close()
+	if resumeAt <= 0 {
+		_ = csvWriter.Write([]string{"timestamp", "prefix", "old_type", "new_type", "origin_asn"})
 	}
+
+	return csvWriter, func() int64 {
+			csvMu.Lock()
+			defer csvMu.Unlock()
+			csvWriter.Flush()
+			offset, _ := fCsv.Seek(0, io.SeekCurrent)
+			return offset
+		}, func() {
+			csvWriter.Flush()
+			_ = fCsv.Close()
+		}
 }
 
 var csvMu sync.Mutex
 
-func runReplay(startTime, endTime time.Time, rrcs []string, cacheDir string, numWorkers int, timeProvider bgp_pkg.TimeProvider, currentTime *int64, masterClassifier *bgp_pkg.Classifier, csvWriter *csv.Writer) {
+// progressInterval is how often the replay logs its progress.
+const progressInterval = 10 * time.Second
+
+// runReplay replays the updates of every collector in time order. With a
+// checkpointer it saves its progress periodically and when interrupted, and
+// continues from a resumed checkpoint.
+func runReplay(ctx context.Context, startTime, endTime time.Time, collectors []mrtCollector, cacheDir string, numWorkers int, timeProvider bgp_pkg.TimeProvider, currentTime *int64, masterClassifier *bgp_pkg.Classifier, csvWriter *csv.Writer, seenDB *utils.DiskTrie, ribs []ribFile, report *incidentReport, cp *checkpointer) error {
 	workers := make([]chan WorkerTask, numWorkers)
 	var wg sync.WaitGroup
+	defer func() {
+		for i := 0; i < numWorkers; i++ {
+			close(workers[i])
+		}
+		wg.Wait()
+	}()
 
 	asnMapping := masterClassifier.GetASNMapping()
 	rpki := masterClassifier.GetRPKIManager()
@@ -125,83 +250,201 @@ func runReplay(startTime, endTime time.Time, rrcs []string, cacheDir string, num
 		go func(ch chan WorkerTask) {
 			defer wg.Done()
 			localPrefixStates := utils.NewLRUCache[string, *bgpproto.PrefixState](1000000 / numWorkers)
-			localClassifier := bgp_pkg.NewClassifier(nil, nil, asnMapping, rpki, prefixToIP, localPrefixStates, timeProvider)
+			localClassifier := bgp_pkg.NewClassifier(seenDB, nil, asnMapping, rpki, prefixToIP, localPrefixStates, timeProvider)
+			localClassifier.SetPeerTracker(masterClassifier.GetPeerTracker())
+			localClassifier.SetFullBogons(masterClassifier.GetFullBogons())
 
 			for task := range ch {
-				processUpdate(localClassifier, masterClassifier, task.msg, task.update, csvWriter, &csvMu)
+				switch {
+				case task.checkpoint != nil:
+					task.checkpoint.writeStates(localClassifier)
+					continue
+				case task.restore != nil:
+					localClassifier.AddPrefixState(task.restore.prefix, task.restore.state)
+					continue
+				}
+				if task.rib != nil {
+					for _, p := range task.rib.Paths {
+						msg := &mrtfile.Message{Timestamp: task.rib.Timestamp, Collector: task.rib.Collector, Peer: p.Peer}
+						localClassifier.SeedRoute(task.rib.Prefix, mrtfile.Context(msg, p.Attrs))
+					}
+					continue
+				}
+				processUpdate(localClassifier, masterClassifier, task.msg, task.update, csvWriter, &csvMu, report)
 			}
 		}(workers[i])
 	}
 
+	count := 0
+	streams := make(map[string]*streamProgress)
+	if cp != nil && cp.resumed != nil {
+		if err := cp.restore(workers, numWorkers, masterClassifier, report); err != nil {
+			return err
+		}
+		atomic.StoreInt64(currentTime, cp.resumed.Time)
+		count = cp.resumed.Messages
+		if cp.resumed.Streams != nil {
+			streams = cp.resumed.Streams
+		}
+	} else if len(ribs) > 0 {
+		bootstrapFromRIB(ribs, startTime, seenDB, workers, numWorkers)
+	}
+
 	h := &StreamHeap{}
 	heap.Init(h)
 
-	for _, rrc := range rrcs {
-		files := getMRTFiles(rrc, startTime, endTime)
-		for _, remoteURL := range files {
-			localPath := filepath.Join(cacheDir, filepath.Base(remoteURL))
-			if err := downloadFile(remoteURL, localPath); err != nil {
-				log.Printf("Failed to download %s: %v", remoteURL, err)
+	for _, collector := range collectors {
+		files, err := collector.archive.updates(startTime, endTime)
+		if err != nil {
+			log.Printf("Failed to list updates of %s: %v", collector.name, err)
+			continue
+		}
+		for _, location := range files {
+			key := streamKey(collector.name, location)
+			progress := streams[key]
+			if progress == nil {
+				progress = &streamProgress{}
+				streams[key] = progress
+			}
+			if progress.Done {
+				continue
+			}
+			localPath, err := collector.fetch(location, cacheDir)
+			if err != nil {
+				log.Printf("Failed to download %s: %v", location, err)
 				continue
 			}
 
-			ch := make(chan *MRTMessage, 1000)
-			go func(c, p string, out chan *MRTMessage) {
-				readMRTFile(c, p, out)
+			ch := make(chan *mrtfile.Message, 1000)
+			go func(c, p string, skip int, out chan *mrtfile.Message) {
+				readMRTFile(c, p, startTime, endTime, skip, out)
 				close(out)
-			}(rrc, localPath, ch)
+			}(collector.name, localPath, progress.Messages, ch)
 
 			if msg, ok := <-ch; ok {
-				heap.Push(h, &MRTStream{collector: rrc, ch: ch, current: msg})
+				heap.Push(h, &MRTStream{collector: collector.name, ch: ch, current: msg, progress: progress})
+			} else {
+				progress.Done = true
 			}
 		}
 	}
 
 	log.Printf("Starting parallel replay with %d workers...", numWorkers)
 
-	count := 0
-	lastSecond := int64(0)
-	messagesThisSecond := 0
+	progress := newReplayProgress(startTime, endTime, atomic.LoadInt64(currentTime), count)
 	for h.Len() > 0 {
+		if count%1024 == 0 {
+			if ctx.Err() != nil {
+				if cp == nil {
+					return fmt.Errorf("interrupted")
+				}
+				if err := cp.save(workers, masterClassifier, report, streams, count, atomic.LoadInt64(currentTime)); err != nil {
+					return fmt.Errorf("interrupted, failed to save checkpoint: %v", err)
+				}
+				return fmt.Errorf("interrupted: continue from the checkpoint in %s with --resume", cp.dir)
+			}
+			if cp.due() {
+				if err := cp.save(workers, masterClassifier, report, streams, count, atomic.LoadInt64(currentTime)); err != nil {
+					log.Printf("Warning: failed to save checkpoint: %v", err)
+				}
+			}
+		}
+
 		stream := heap.Pop(h).(*MRTStream)
 		msg := stream.current
 		atomic.StoreInt64(currentTime, msg.Timestamp.Unix())
-		ts := atomic.LoadInt64(currentTime)
-
-		if lastSecond == 0 {
-			lastSecond = ts
-		}
-
-		if ts > lastSecond {
-			if messagesThisSecond > 0 {
-				log.Printf("[%s] Rate: %d msg/s", time.Unix(lastSecond, 0).Format("15:04:05"), messagesThisSecond)
-			}
-			lastSecond = ts
-			messagesThisSecond = 0
-		}
-		messagesThisSecond++
+		report.message(msg)
 
 		if msg.Message.Header.Type == bgp.BGP_MSG_UPDATE {
 			update := msg.Message.Body.(*bgp.BGPUpdate)
 			dispatchUpdate(update, msg, workers, numWorkers)
 		}
+		stream.progress.Messages++
+		stream.progress.Last = msg.Timestamp
 
 		if next, ok := <-stream.ch; ok {
 			stream.current = next
 			heap.Push(h, stream)
+		} else {
+			stream.progress.Done = true
 		}
 
 		count++
+		progress.log(msg.Timestamp, count)
 	}
 
-	for i := 0; i < numWorkers; i++ {
-		close(workers[i])
-	}
-	wg.Wait()
 	log.Printf("Done. Processed %d messages.", count)
+	return nil
+}
+
+// replayProgress logs how far a replay got through its time window, its rate
+// and when it will be done.
+type replayProgress struct {
+	start, end time.Time
+	began      time.Time
+	fromSim    int64
+
+	last      time.Time
+	lastCount int
+}
+
+func newReplayProgress(start, end time.Time, sim int64, count int) *replayProgress {
+	if sim < start.Unix() {
+		sim = start.Unix()
+	}
+	now := time.Now()
+	return &replayProgress{start: start, end: end, began: now, fromSim: sim, last: now, lastCount: count}
+}
+
+func (p *replayProgress) log(sim time.Time, count int) {
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval {
+		return
+	}
+	rate := float64(count-p.lastCount) / now.Sub(p.last).Seconds()
+	p.last, p.lastCount = now, count
+
+	total := p.end.Sub(p.start).Seconds()
+	done := sim.Sub(p.start).Seconds()
+	pct := 0.0
+	if total > 0 {
+		pct = 100 * done / total
+	}
+	eta := "unknown"
+	if advanced := float64(sim.Unix() - p.fromSim); advanced > 0 {
+		remaining := p.end.Sub(sim).Seconds() / advanced * now.Sub(p.began).Seconds()
+		eta = time.Duration(remaining * float64(time.Second)).Round(time.Second).String()
+	}
+	log.Printf("[PROGRESS] %s (%.1f%%), %d messages, %.0f msg/s, ETA %s",
+		sim.UTC().Format("2006-01-02 15:04:05"), pct, count, rate, eta)
 }
 
-func dispatchUpdate(update *bgp.BGPUpdate, msg *MRTMessage, workers []chan WorkerTask, numWorkers int) {
+// bootstrapFromRIB seeds the workers with the routes of each RIB dump, taken
+// as the routing table at start, and adds their origins to the seen prefixes
+// database before the replay begins.
+func bootstrapFromRIB(ribs []ribFile, start time.Time, seenDB *utils.DiskTrie, workers []chan WorkerTask, numWorkers int) {
+	origins := make(seenOrigins)
+	for _, rib := range ribs {
+		log.Printf("Seeding state from %s RIB dump %s...", rib.collector, rib.path)
+		n := readRIBFile(rib.collector, rib.path, func(e *ribEntry) {
+			origins.add(e)
+			e.Timestamp = start
+			workerID := utils.HashUint32(prefixToIP(e.Prefix)) % uint32(numWorkers)
+			workers[workerID] <- WorkerTask{rib: e}
+		})
+		log.Printf("Seeded %d prefixes from %s", n, rib.collector)
+	}
+	if seenDB != nil {
+		added, err := origins.write(seenDB, false)
+		if err != nil {
+			log.Printf("Warning: Failed to update seen prefixes database: %v", err)
+		} else {
+			log.Printf("Added %d prefixes to the seen prefixes database", added)
+		}
+	}
+}
+
+func dispatchUpdate(update *bgp.BGPUpdate, msg *mrtfile.Message, workers []chan WorkerTask, numWorkers int) {
 	for _, nlri := range update.NLRI {
 		prefix := nlri.String()
 		ip := prefixToIP(prefix)
@@ -235,18 +478,6 @@ func dispatchUpdate(update *bgp.BGPUpdate, msg *MRTMessage, workers []chan Worke
 	}
 }
 
-func getMRTFiles(rrc string, start, end time.Time) []string {
-	var files []string
-	current := start.Truncate(5 * time.Minute)
-	for current.Before(end) {
-		url := fmt.Sprintf("https://data.ris.ripe.net/%s/%s/updates.%s.gz",
-			rrc, current.Format("2006.01"), current.Format("20060102.1504"))
-		files = append(files, url)
-		current = current.Add(5 * time.Minute)
-	}
-	return files
-}
-
 func downloadFile(url, path string) error {
 	if _, err := os.Stat(path); err == nil {
 		return nil
@@ -282,56 +513,22 @@ func prefixToIP(p string) uint32 {
 	return utils.IPToUint32(parsedIP)
 }
 
-func processUpdate(localClassifier, masterClassifier *bgp_pkg.Classifier, msg *MRTMessage, update *bgp.BGPUpdate, writer *csv.Writer, csvMu *sync.Mutex) {
-	ctx := &bgp_pkg.MessageContext{
-		Peer: msg.Peer,
-		Host: msg.Collector,
-		Now:  msg.Timestamp,
-	}
-
-	for _, attr := range update.PathAttributes {
-		switch a := attr.(type) {
-		case *bgp.PathAttributeAsPath:
-			ctx.PathLen = 0
-			var asns []string
-			for _, param := range a.Value {
-				for _, asn := range param.GetAS() {
-					asns = append(asns, fmt.Sprintf("%d", asn))
-					ctx.PathLen++
-					ctx.OriginASN = asn
-				}
-			}
-			ctx.PathStr = "[" + strings.Join(asns, " ") + "]"
-		case *bgp.PathAttributeNextHop:
-			ctx.NextHop = a.Value.String()
-		case *bgp.PathAttributeAggregator:
-			ctx.Aggregator = fmt.Sprintf("AS%d:%s", a.Value.AS, a.Value.Address.String())
-		case *bgp.PathAttributeMultiExitDisc:
-			ctx.Med = int32(a.Value)
-		case *bgp.PathAttributeLocalPref:
-			ctx.LocalPref = int32(a.Value)
-		case *bgp.PathAttributeCommunities:
-			var comms []string
-			for _, c := range a.Value {
-				comms = append(comms, fmt.Sprintf("%d:%d", (c>>16)&0xffff, c&0xffff))
-			}
-			ctx.CommStr = "[" + strings.Join(comms, " ") + "]"
-		}
-	}
+func processUpdate(localClassifier, masterClassifier *bgp_pkg.Classifier, msg *mrtfile.Message, update *bgp.BGPUpdate, writer *csv.Writer, csvMu *sync.Mutex, report *incidentReport) {
+	ctx := mrtfile.Context(msg, update.PathAttributes)
 
 	for _, nlri := range update.NLRI {
 		prefix := nlri.String()
-		handlePrefix(localClassifier, masterClassifier, prefix, ctx, writer, csvMu)
+		handlePrefix(localClassifier, masterClassifier, prefix, ctx, writer, csvMu, report)
 	}
 
 	ctx.IsWithdrawal = true
 	for _, nlri := range update.WithdrawnRoutes {
 		prefix := nlri.String()
-		handlePrefix(localClassifier, masterClassifier, prefix, ctx, writer, csvMu)
+		handlePrefix(localClassifier, masterClassifier, prefix, ctx, writer, csvMu, report)
 	}
 }
 
-func handlePrefix(localClassifier, masterClassifier *bgp_pkg.Classifier, prefix string, ctx *bgp_pkg.MessageContext, writer *csv.Writer, csvMu *sync.Mutex) {
+func handlePrefix(localClassifier, masterClassifier *bgp_pkg.Classifier, prefix string, ctx *bgp_pkg.MessageContext, writer *csv.Writer, csvMu *sync.Mutex, report *incidentReport) {
 	oldType := bgp_pkg.ClassificationNone
 	state, ok := localClassifier.GetPrefixState(prefix)
 	if ok {
@@ -345,7 +542,8 @@ func handlePrefix(localClassifier, masterClassifier *bgp_pkg.Classifier, prefix
 		newType := bgp_pkg.ClassificationType(state.ClassifiedType)
 
 		if oldType != newType {
-			masterClassifier.RecordClassification(prefix, state, newType, ctx.Now.Unix(), ctx, ev.HistoricalASN, ev.LeakDetail)
+			recorded := masterClassifier.RecordClassification(prefix, state, newType, ctx.Now.Unix(), ctx, ev.HistoricalASN, ev.LeakDetail)
+			report.classified(recorded, state, ctx)
 
 			csvMu.Lock()
 			_ = writer.Write([]string{
@@ -386,77 +584,26 @@ func writeSummary(path string, c *bgp_pkg.Classifier) {
 	}
 }
 
-type MRTMessage struct {
-	Timestamp time.Time
-	Collector string
-	Peer      string
-	Message   *bgp.BGPMessage
-}
-
 type MRTStream struct {
 	collector string
-	ch        chan *MRTMessage
-	current   *MRTMessage
+	ch        chan *mrtfile.Message
+	current   *mrtfile.Message
+	progress  *streamProgress
 }
 
-func readMRTFile(collector, path string, ch chan *MRTMessage) {
-	f, err := os.Open(path)
-	if err != nil {
-		log.Printf("Error opening %s: %v", path, err)
-		return
-	}
-	defer func() { _ = f.Close() }()
-
-	gz, err := gzip.NewReader(bufio.NewReader(f))
-	if err != nil {
-		log.Printf("Error opening gzip %s: %v", path, err)
-		return
-	}
-	defer func() { _ = gz.Close() }()
-
-	for {
-		header := make([]byte, mrt.MRT_COMMON_HEADER_LEN)
-		_, err := io.ReadFull(gz, header)
-		if err == io.EOF {
-			break
+// readMRTFile sends the BGP messages of an update file received in
+// [start, end) to ch, leaving out the first skip of them.
+func readMRTFile(collector, path string, start, end time.Time, skip int, ch chan *mrtfile.Message) {
+	mrtfile.ScanMessages(collector, path, func(msg *mrtfile.Message) {
+		if msg.Timestamp.Before(start) || !msg.Timestamp.Before(end) {
+			return
 		}
-		if err != nil {
-			log.Printf("Error reading MRT header from %s: %v", collector, err)
-			break
-		}
-
-		h := &mrt.MRTHeader{}
-		if err := h.DecodeFromBytes(header); err != nil {
-			log.Printf("Error decoding MRT header from %s: %v", collector, err)
-			break
-		}
-
-		body := make([]byte, h.Len)
-		if _, err := io.ReadFull(gz, body); err != nil {
-			log.Printf("Error reading MRT body from %s: %v", collector, err)
-			break
-		}
-
-		msg, err := mrt.ParseMRTBody(h, body)
-		if err != nil {
-			continue
+		if skip > 0 {
+			skip--
+			return
 		}
-
-		if msg.Header.Type == mrt.BGP4MP || msg.Header.Type == mrt.BGP4MP_ET {
-			subtype := mrt.MRTSubTypeBGP4MP(msg.Header.SubType)
-			if subtype == mrt.MESSAGE || subtype == mrt.MESSAGE_AS4 ||
-				subtype == mrt.MESSAGE_LOCAL || subtype == mrt.MESSAGE_AS4_LOCAL {
-
-				bgp4mp := msg.Body.(*mrt.BGP4MPMessage)
-				ch <- &MRTMessage{
-					Timestamp: msg.Header.GetTime(),
-					Collector: collector,
-					Peer:      fmt.Sprintf("%d", bgp4mp.PeerAS),
-					Message:   bgp4mp.BGPMessage,
-				}
-			}
-		}
-	}
+		ch <- msg
+	})
 }
 
 type StreamHeap []*MRTStream
@@ -474,6 +621,12 @@ func (h *StreamHeap) Pop() interface{} {
 }
 
 type WorkerTask struct {
-	msg    *MRTMessage
+	msg    *mrtfile.Message
 	update *bgp.BGPUpdate
+	// rib, if set, seeds the prefix state with the routes of a RIB dump
+	// instead of classifying an update
+	rib *ribEntry
+	// checkpoint and restore save and load the worker's prefix states
+	checkpoint *workerCheckpoint
+	restore    *restoredState
 }
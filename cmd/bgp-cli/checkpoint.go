@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	checkpointManifest = "checkpoint.json"
+	checkpointStateDB  = "prefix-state.db"
+	// checkpointBatchSize bounds the prefix states written in one batch.
+	checkpointBatchSize = 10000
+)
+
+// analyzeCheckpoint is what a replay saves to continue where it stopped. The
+// prefix states of the workers are kept next to it in a prefix state database,
+// which bgp-cli report can also read.
+type analyzeCheckpoint struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Collectors []string  `json:"collectors"`
+	Saved      time.Time `json:"saved"`
+
+	// Time is the simulated time of the last replayed message.
+	Time     int64                      `json:"time"`
+	Messages int                        `json:"messages"`
+	Streams  map[string]*streamProgress `json:"streams"`
+
+	CSVOffset            int64                   `json:"csv_offset"`
+	Classifications      map[string]int          `json:"classifications"`
+	TotalClassifications int                     `json:"total_classifications"`
+	Peers                []bgp_pkg.PeerSession   `json:"peers"`
+	Report               *incidentReportSnapshot `json:"report,omitempty"`
+}
+
+// streamProgress is how far the replay got through one MRT file.
+type streamProgress struct {
+	Messages int       `json:"messages"`
+	Last     time.Time `json:"last"`
+	Done     bool      `json:"done"`
+}
+
+// streamKey identifies an MRT file of a collector across runs.
+func streamKey(collector, location string) string {
+	return collector + " " + location
+}
+
+// checkpointer periodically saves a replay to dir. The current checkpoint is
+// in dir/state; a new one is written next to it and swapped in, so an
+// interruption while saving leaves the previous one intact.
+type checkpointer struct {
+	dir   string
+	every time.Duration
+	last  time.Time
+
+	run     analyzeCheckpoint
+	resumed *analyzeCheckpoint
+
+	// csvOffset flushes the transitions CSV and returns its size.
+	csvOffset func() int64
+}
+
+func newCheckpointer(dir string, every time.Duration, start, end time.Time, collectors []mrtCollector) *checkpointer {
+	cp := &checkpointer{dir: dir, every: every, last: time.Now()}
+	cp.run.Start, cp.run.End = start.UTC(), end.UTC()
+	for _, c := range collectors {
+		cp.run.Collectors = append(cp.run.Collectors, c.name)
+	}
+	return cp
+}
+
+func (cp *checkpointer) stateDir() string { return filepath.Join(cp.dir, "state") }
+
+// load reads the current checkpoint, falling back to the previous one if a
+// swap was interrupted, and checks that it belongs to the same run.
+func (cp *checkpointer) load() error {
+	var data []byte
+	var err error
+	for _, dir := range []string{cp.stateDir(), cp.stateDir() + ".old"} {
+		if data, err = os.ReadFile(filepath.Join(dir, checkpointManifest)); err == nil {
+			if dir != cp.stateDir() {
+				if err := os.Rename(dir, cp.stateDir()); err != nil {
+					return fmt.Errorf("failed to restore previous checkpoint: %v", err)
+				}
+			}
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("no checkpoint to resume in %s", cp.dir)
+	}
+	saved := &analyzeCheckpoint{}
+	if err := json.Unmarshal(data, saved); err != nil {
+		return fmt.Errorf("failed to read checkpoint: %v", err)
+	}
+	if !saved.Start.Equal(cp.run.Start) || !saved.End.Equal(cp.run.End) || !slices.Equal(saved.Collectors, cp.run.Collectors) {
+		return fmt.Errorf("checkpoint in %s is for %s to %s on %d collectors, not this run",
+			cp.dir, saved.Start.Format("2006-01-02 15:04"), saved.End.Format("2006-01-02 15:04"), len(saved.Collectors))
+	}
+	cp.resumed = saved
+	return nil
+}
+
+// exists reports whether dir holds a checkpoint that a fresh run would
+// overwrite.
+func (cp *checkpointer) exists() bool {
+	for _, dir := range []string{cp.stateDir(), cp.stateDir() + ".old"} {
+		if _, err := os.Stat(filepath.Join(dir, checkpointManifest)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// due reports whether the next checkpoint should be saved.
+func (cp *checkpointer) due() bool {
+	return cp != nil && cp.every > 0 && time.Since(cp.last) >= cp.every
+}
+
+// workerCheckpoint asks a worker to write its prefix states to db.
+type workerCheckpoint struct {
+	db  *utils.DiskTrie
+	wg  *sync.WaitGroup
+	mu  sync.Mutex
+	err error
+	n   int
+}
+
+// save writes the states of every worker and the progress of the replay.
+// Workers finish the tasks queued before the checkpoint first, so it holds
+// exactly the messages counted in streams.
+func (cp *checkpointer) save(workers []chan WorkerTask, master *bgp_pkg.Classifier, report *incidentReport, streams map[string]*streamProgress, messages int, simTime int64) error {
+	start := time.Now()
+	newDir := cp.stateDir() + ".new"
+	if err := os.RemoveAll(newDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(newDir, 0o755); err != nil {
+		return err
+	}
+	db, err := utils.OpenDiskTrie(filepath.Join(newDir, checkpointStateDB))
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint database: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wc := &workerCheckpoint{db: db, wg: &wg}
+	for _, ch := range workers {
+		wg.Add(1)
+		ch <- WorkerTask{checkpoint: wc}
+	}
+	wg.Wait()
+	if err := db.Close(); err != nil && wc.err == nil {
+		wc.err = err
+	}
+	if wc.err != nil {
+		return fmt.Errorf("failed to write prefix states: %v", wc.err)
+	}
+
+	saved := cp.run
+	saved.Saved = time.Now().UTC()
+	saved.Time = simTime
+	saved.Messages = messages
+	saved.Streams = streams
+	saved.CSVOffset = cp.csvOffset()
+	stats, total := master.GetClassificationStats()
+	saved.Classifications = make(map[string]int, len(stats))
+	for t, n := range stats {
+		saved.Classifications[t.Key()] = n
+	}
+	saved.TotalClassifications = total
+	if peers := master.GetPeerTracker(); peers != nil {
+		saved.Peers = peers.Sessions()
+	}
+	saved.Report = report.snapshot()
+
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(newDir, checkpointManifest), data, 0o644); err != nil {
+		return err
+	}
+
+	oldDir := cp.stateDir() + ".old"
+	_ = os.RemoveAll(oldDir)
+	if err := os.Rename(cp.stateDir(), oldDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(newDir, cp.stateDir()); err != nil {
+		return err
+	}
+	_ = os.RemoveAll(oldDir)
+
+	cp.last = time.Now()
+	log.Printf("[CHECKPOINT] Saved %d prefix states at %s to %s in %v", wc.n,
+		time.Unix(simTime, 0).UTC().Format("2006-01-02 15:04:05"), cp.dir, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// writeStates writes the prefix states held by a worker's classifier.
+func (wc *workerCheckpoint) writeStates(c *bgp_pkg.Classifier) {
+	defer wc.wg.Done()
+	batch := make(map[string][]byte, checkpointBatchSize)
+	var err error
+	flush := func() {
+		if err == nil && len(batch) > 0 {
+			err = wc.db.BatchInsert(batch)
+		}
+		clear(batch)
+	}
+	n := 0
+	c.RangePrefixStates(func(prefix string, state *bgpproto.PrefixState) bool {
+		data, mErr := proto.Marshal(state)
+		if mErr != nil {
+			return true
+		}
+		batch[prefix] = data
+		n++
+		if len(batch) >= checkpointBatchSize {
+			flush()
+		}
+		return err == nil
+	})
+	flush()
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.n += n
+	if err != nil && wc.err == nil {
+		wc.err = err
+	}
+}
+
+// restore loads the resumed checkpoint into the classifiers and the report,
+// handing each prefix state to the worker that owns the prefix.
+func (cp *checkpointer) restore(workers []chan WorkerTask, numWorkers int, master *bgp_pkg.Classifier, report *incidentReport) error {
+	saved := cp.resumed
+	db, err := utils.OpenDiskTrieReadOnly(filepath.Join(cp.stateDir(), checkpointStateDB))
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint database: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	n := 0
+	err = db.ForEach(func(k, v []byte) error {
+		if len(k) != 5 {
+			return nil
+		}
+		state := &bgpproto.PrefixState{}
+		if err := proto.Unmarshal(v, state); err != nil {
+			return nil
+		}
+		prefix := fmt.Sprintf("%s/%d", net.IP(k[:4]).String(), k[4])
+		workerID := utils.HashUint32(prefixToIP(prefix)) % uint32(numWorkers)
+		workers[workerID] <- WorkerTask{restore: &restoredState{prefix: prefix, state: state}}
+		n++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint database: %v", err)
+	}
+
+	stats := make(map[bgp_pkg.ClassificationType]int, len(saved.Classifications))
+	for key, count := range saved.Classifications {
+		if t, ok := bgp_pkg.ParseClassificationKey(key); ok {
+			stats[t] = count
+		}
+	}
+	master.SetClassificationStats(stats, saved.TotalClassifications)
+	if peers := master.GetPeerTracker(); peers != nil {
+		peers.RestoreSessions(saved.Peers, time.Unix(saved.Time, 0))
+	}
+	if report != nil {
+		if saved.Report == nil {
+			log.Printf("Warning: checkpoint has no HTML report data; the report only covers the resumed part of the run")
+		} else {
+			report.restore(saved.Report)
+		}
+	}
+	log.Printf("[CHECKPOINT] Resuming from %s with %d prefix states and %d messages replayed",
+		time.Unix(saved.Time, 0).UTC().Format("2006-01-02 15:04:05"), n, saved.Messages)
+	return nil
+}
+
+// restoredState is a prefix state loaded from a checkpoint.
+type restoredState struct {
+	prefix string
+	state  *bgpproto.PrefixState
+}
+
+// remove deletes the checkpoint once the run it belongs to has completed.
+func (cp *checkpointer) remove() {
+	if cp == nil {
+		return
+	}
+	if err := os.RemoveAll(cp.dir); err != nil {
+		log.Printf("Warning: failed to remove checkpoint %s: %v", cp.dir, err)
+	}
+}
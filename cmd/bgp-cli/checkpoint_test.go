@@ -0,0 +1,288 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	checkpointStart      = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	checkpointEnd        = checkpointStart.Add(2 * time.Hour)
+	checkpointCollectors = []mrtCollector{{name: "rrc00"}, {name: "rrc01"}}
+)
+
+// checkpointWorkers runs workers that only handle checkpoint tasks, the way
+// the replay workers do.
+type checkpointWorkers struct {
+	chans       []chan WorkerTask
+	classifiers []*bgp_pkg.Classifier
+	wg          sync.WaitGroup
+}
+
+func newCheckpointWorkers(n int) *checkpointWorkers {
+	w := &checkpointWorkers{}
+	for i := 0; i < n; i++ {
+		ch := make(chan WorkerTask, 100)
+		c := bgp_pkg.NewClassifier(nil, nil, nil, nil, prefixToIP, utils.NewLRUCache[string, *bgpproto.PrefixState](1000), time.Now)
+		w.chans = append(w.chans, ch)
+		w.classifiers = append(w.classifiers, c)
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			for task := range ch {
+				switch {
+				case task.checkpoint != nil:
+					task.checkpoint.writeStates(c)
+				case task.restore != nil:
+					c.AddPrefixState(task.restore.prefix, task.restore.state)
+				}
+			}
+		}()
+	}
+	return w
+}
+
+func (w *checkpointWorkers) owner(prefix string) int {
+	return int(utils.HashUint32(prefixToIP(prefix)) % uint32(len(w.chans)))
+}
+
+// stop waits for the queued tasks and returns the states held by each worker.
+func (w *checkpointWorkers) stop() []map[string]*bgpproto.PrefixState {
+	for _, ch := range w.chans {
+		close(ch)
+	}
+	w.wg.Wait()
+	states := make([]map[string]*bgpproto.PrefixState, len(w.classifiers))
+	for i, c := range w.classifiers {
+		states[i] = make(map[string]*bgpproto.PrefixState)
+		c.RangePrefixStates(func(prefix string, state *bgpproto.PrefixState) bool {
+			states[i][prefix] = state
+			return true
+		})
+	}
+	return states
+}
+
+func newTestCheckpointer(dir string) *checkpointer {
+	cp := newCheckpointer(dir, time.Minute, checkpointStart, checkpointEnd, checkpointCollectors)
+	cp.csvOffset = func() int64 { return 42 }
+	return cp
+}
+
+// saveTestCheckpoint saves a checkpoint of a few prefix states, each held by
+// the worker that owns it, and returns them.
+func saveTestCheckpoint(t *testing.T, dir string, simTime int64) map[string]*bgpproto.PrefixState {
+	t.Helper()
+	workers := newCheckpointWorkers(3)
+	states := make(map[string]*bgpproto.PrefixState)
+	for i := 0; i < 8; i++ {
+		prefix := fmt.Sprintf("10.%d.0.0/16", i)
+		state := &bgpproto.PrefixState{LastOriginAsn: uint32(64500 + i), LastUpdateTs: simTime, ClassifiedType: int32(bgp_pkg.ClassificationFlap)}
+		states[prefix] = state
+		workers.classifiers[workers.owner(prefix)].AddPrefixState(prefix, state)
+	}
+
+	master := bgp_pkg.NewClassifier(nil, nil, nil, nil, prefixToIP, nil, time.Now)
+	master.SetClassificationStats(map[bgp_pkg.ClassificationType]int{bgp_pkg.ClassificationFlap: 8}, 8)
+	peers := bgp_pkg.NewPeerTracker()
+	peers.Observe("rrc00", "64510", "10.0.0.0/16", time.Unix(simTime, 0))
+	master.SetPeerTracker(peers)
+	report := newIncidentReport(checkpointStart, checkpointEnd)
+	report.message(&mrtfile.Message{Collector: "rrc00", Peer: "64510"})
+
+	streams := map[string]*streamProgress{streamKey("rrc00", "updates.20240301.1000.gz"): {Messages: 10, Last: time.Unix(simTime, 0).UTC()}}
+	cp := newTestCheckpointer(dir)
+	err := cp.save(workers.chans, master, report, streams, 10, simTime)
+	workers.stop()
+	if err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	return states
+}
+
+func TestCheckpointer_SaveRestore(t *testing.T) {
+	dir := t.TempDir()
+	simTime := checkpointStart.Add(30 * time.Minute).Unix()
+	states := saveTestCheckpoint(t, dir, simTime)
+
+	// Only the swapped in checkpoint is left
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "state" {
+		t.Errorf("expected only the state directory, got %v", entries)
+	}
+
+	cp := newTestCheckpointer(dir)
+	if !cp.exists() {
+		t.Fatal("expected the checkpoint to exist")
+	}
+	if err := cp.load(); err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if cp.resumed.Time != simTime || cp.resumed.Messages != 10 || cp.resumed.CSVOffset != 42 {
+		t.Errorf("resumed = (%d, %d messages, offset %d), want (%d, 10 messages, offset 42)",
+			cp.resumed.Time, cp.resumed.Messages, cp.resumed.CSVOffset, simTime)
+	}
+	if s := cp.resumed.Streams[streamKey("rrc00", "updates.20240301.1000.gz")]; s == nil || s.Messages != 10 {
+		t.Errorf("expected the stream progress to be saved, got %+v", cp.resumed.Streams)
+	}
+
+	workers := newCheckpointWorkers(3)
+	master := bgp_pkg.NewClassifier(nil, nil, nil, nil, prefixToIP, nil, time.Now)
+	peers := bgp_pkg.NewPeerTracker()
+	master.SetPeerTracker(peers)
+	report := newIncidentReport(checkpointStart, checkpointEnd)
+	err := cp.restore(workers.chans, len(workers.chans), master, report)
+	restored := workers.stop()
+	if err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+
+	n := 0
+	for i, held := range restored {
+		for prefix, state := range held {
+			n++
+			if owner := workers.owner(prefix); owner != i {
+				t.Errorf("%s was restored to worker %d, want %d", prefix, i, owner)
+			}
+			if !proto.Equal(state, states[prefix]) {
+				t.Errorf("%s = %v, want %v", prefix, state, states[prefix])
+			}
+		}
+	}
+	if n != len(states) {
+		t.Errorf("restored %d prefix states, want %d", n, len(states))
+	}
+	if stats, total := master.GetClassificationStats(); stats[bgp_pkg.ClassificationFlap] != 8 || total != 8 {
+		t.Errorf("classification stats = %v (%d), want 8 flaps", stats, total)
+	}
+	if sessions := peers.Sessions(); len(sessions) != 1 || sessions[0].Key != "rrc00:64510" {
+		t.Errorf("expected the peer session to be restored, got %v", sessions)
+	}
+	if report.messages["rrc00"] != 1 {
+		t.Errorf("expected the report to be restored, got %v", report.messages)
+	}
+}
+
+func TestCheckpointer_InterruptedSwap(t *testing.T) {
+	first := checkpointStart.Add(10 * time.Minute).Unix()
+	second := checkpointStart.Add(20 * time.Minute).Unix()
+
+	tests := []struct {
+		name string
+		// interrupt leaves dir as a save would if it stopped part way
+		interrupt func(t *testing.T, dir string)
+		want      int64
+	}{
+		{
+			"new checkpoint not written yet",
+			func(t *testing.T, dir string) {
+				if err := os.MkdirAll(filepath.Join(dir, "state.new", checkpointStateDB), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			first,
+		},
+		{
+			"current checkpoint moved aside",
+			func(t *testing.T, dir string) {
+				saveTestCheckpoint(t, filepath.Join(dir, "next"), second)
+				if err := os.Rename(filepath.Join(dir, "state"), filepath.Join(dir, "state.old")); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Rename(filepath.Join(dir, "next", "state"), filepath.Join(dir, "state.new")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			first,
+		},
+		{
+			"new checkpoint swapped in",
+			func(t *testing.T, dir string) {
+				if err := os.Rename(filepath.Join(dir, "state"), filepath.Join(dir, "state.old")); err != nil {
+					t.Fatal(err)
+				}
+				saveTestCheckpoint(t, filepath.Join(dir, "next"), second)
+				if err := os.Rename(filepath.Join(dir, "next", "state"), filepath.Join(dir, "state")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			saveTestCheckpoint(t, dir, first)
+			tt.interrupt(t, dir)
+
+			cp := newTestCheckpointer(dir)
+			if !cp.exists() {
+				t.Fatal("expected a checkpoint to exist")
+			}
+			if err := cp.load(); err != nil {
+				t.Fatalf("load() error = %v", err)
+			}
+			if cp.resumed.Time != tt.want {
+				t.Errorf("resumed the checkpoint at %d, want %d", cp.resumed.Time, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "state", checkpointStateDB)); err != nil {
+				t.Errorf("expected the resumed checkpoint in the state directory: %v", err)
+			}
+
+			// The next save replaces whatever the interrupted one left
+			saveTestCheckpoint(t, dir, second)
+			cp = newTestCheckpointer(dir)
+			if err := cp.load(); err != nil || cp.resumed.Time != second {
+				t.Errorf("expected the next save to be resumed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckpointer_LoadMismatchedRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := newTestCheckpointer(dir).load(); err == nil || !strings.Contains(err.Error(), "no checkpoint to resume") {
+		t.Errorf("load() error = %v, want no checkpoint", err)
+	}
+	saveTestCheckpoint(t, dir, checkpointStart.Unix())
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		collectors []mrtCollector
+	}{
+		{"start", checkpointStart.Add(time.Minute), checkpointEnd, checkpointCollectors},
+		{"end", checkpointStart, checkpointEnd.Add(time.Hour), checkpointCollectors},
+		{"collectors", checkpointStart, checkpointEnd, checkpointCollectors[:1]},
+		{"collector order", checkpointStart, checkpointEnd, []mrtCollector{checkpointCollectors[1], checkpointCollectors[0]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := newCheckpointer(dir, time.Minute, tt.start, tt.end, tt.collectors)
+			err := cp.load()
+			if err == nil || !strings.Contains(err.Error(), "not this run") {
+				t.Fatalf("load() error = %v, want a mismatched run", err)
+			}
+			if cp.resumed != nil {
+				t.Error("expected nothing to be resumed")
+			}
+		})
+	}
+
+	// Times are compared in UTC
+	cp := newCheckpointer(dir, time.Minute, checkpointStart.In(time.FixedZone("X", 3600)), checkpointEnd, checkpointCollectors)
+	if err := cp.load(); err != nil {
+		t.Errorf("load() error = %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+type DBCmd struct {
+	Compact DBCompactCmd `cmd:"" help:"Trim and expire prefix state, then reclaim disk space. The viewer must not be running."`
+	Seen    DBSeenCmd    `cmd:"" help:"Rebuild the seen prefixes database from MRT RIB dumps. The viewer must not be running."`
+}
+
+type DBSeenCmd struct {
+	Files      []string `arg:"" optional:"" help:"TABLE_DUMP_V2 RIB dumps, such as RIS bview or RouteViews rib files, as [collector=]path."`
+	Collectors string   `name:"collectors" aliases:"rrcs" default:"" help:"Comma-separated collectors as [name=]source (rrc00, route-views2 or a local directory or glob) whose RIB dump to use instead of reading files."`
+	At         string   `default:"" help:"Use the RIB dumps taken at or before this time (YYYY-MM-DD HH:mm). Defaults to the latest."`
+	Cache      string   `default:"data/mrt-cache" help:"Directory for downloaded RIB dumps."`
+	DB         string   `default:"./data/seen-prefixes.db" help:"Path to the seen prefixes database."`
+	Merge      bool     `help:"Keep the existing entries and only add prefixes the database lacks."`
+}
+
+func (c *DBSeenCmd) Run() error {
+	var ribs []ribFile
+	for _, f := range c.Files {
+		ribs = append(ribs, parseRIBFile(f))
+	}
+	if c.Collectors != "" {
+		collectors, err := parseCollectors(c.Collectors)
+		if err != nil {
+			return err
+		}
+		// Dumps take a while to be published, so the latest is the one before the last hour
+		at := time.Now().Add(-time.Hour)
+		if c.At != "" {
+			t, err := time.Parse("2006-01-02 15:04", c.At)
+			if err != nil {
+				return fmt.Errorf("invalid time: %v", err)
+			}
+			at = t
+		}
+		ribs = append(ribs, collectorRIBs(collectors, at, c.Cache)...)
+	}
+	if len(ribs) == 0 {
+		return fmt.Errorf("no RIB dumps given")
+	}
+
+	origins := make(seenOrigins)
+	for _, rib := range ribs {
+		log.Printf("Reading %s...", rib.path)
+		n := readRIBFile(rib.collector, rib.path, origins.add)
+		log.Printf("Read %d prefixes from %s", n, rib.collector)
+	}
+	if len(origins) == 0 {
+		return fmt.Errorf("no IPv4 prefixes found in the RIB dumps")
+	}
+
+	log.Printf("Opening database at %s...", c.DB)
+	db, err := utils.OpenDiskTrie(c.DB)
+	if err != nil {
+		return fmt.Errorf("failed to open seen prefixes database: %v", err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Warning: error closing database: %v", err)
+		}
+	}()
+	written, err := origins.write(db, !c.Merge)
+	if err != nil {
+		return fmt.Errorf("failed to write seen prefixes: %v", err)
+	}
+	log.Printf("Wrote %d of %d prefixes to %s", written, len(origins), c.DB)
+	return nil
+}
+
+type DBCompactCmd struct {
+	StateDB string        `default:"./data/prefix-state.db" help:"Path to the prefix state database."`
+	SeenDB  string        `default:"./data/seen-prefixes.db" help:"Path to the seen prefixes database."`
+	TTL     time.Duration `default:"720h" help:"Delete prefix state after this long without updates (0 to keep forever)."`
+	AsOf    string        `default:"" help:"Reference time for retention (YYYY-MM-DD HH:mm). Defaults to now; set it for databases built from historical data."`
+}
+
+func (c *DBCompactCmd) Run() error {
+	now := time.Now()
+	if c.AsOf != "" {
+		t, err := time.Parse("2006-01-02 15:04", c.AsOf)
+		if err != nil {
+			return fmt.Errorf("invalid as-of time: %v", err)
+		}
+		now = t
+	}
+
+	if c.StateDB != "" {
+		if err := compactDB(c.StateDB, func(db *utils.DiskTrie) error {
+			start := time.Now()
+			stats, err := bgp.CompactStateDB(db, now, c.TTL)
+			if err != nil {
+				return fmt.Errorf("failed to compact prefix states: %v", err)
+			}
+			log.Printf("Compacted prefix states in %v: %d trimmed, %d expired", time.Since(start).Round(time.Millisecond), stats.Trimmed, stats.Deleted)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if c.SeenDB != "" {
+		if err := compactDB(c.SeenDB, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactDB opens the database at path, applies prune if set, then flattens the
+// LSM tree and garbage collects the value log.
+func compactDB(path string, prune func(db *utils.DiskTrie) error) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		log.Printf("Skipping %s: database does not exist", path)
+		return nil
+	}
+	log.Printf("Opening database at %s...", path)
+	db, err := utils.OpenDiskTrie(path)
+	if err != nil {
+		return fmt.Errorf("failed to open database %s: %v", path, err)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			log.Printf("Warning: error closing database: %v", err)
+		}
+	}()
+
+	lsm, vlog := db.Size()
+	sizeBefore := lsm + vlog
+
+	if prune != nil {
+		if err := prune(db); err != nil {
+			return err
+		}
+	}
+	if err := db.Flatten(); err != nil {
+		return fmt.Errorf("failed to flatten %s: %v", path, err)
+	}
+	gc, err := bgp.GarbageCollect(db)
+	if err != nil {
+		return fmt.Errorf("failed to garbage collect %s: %v", path, err)
+	}
+
+	reclaimed := sizeBefore - gc.SizeAfter
+	if reclaimed < 0 {
+		reclaimed = 0
+	}
+	log.Printf("%s: %.1f MB -> %.1f MB, %.1f MB reclaimed (%d value log files rewritten)",
+		path, float64(sizeBefore)/(1<<20), float64(gc.SizeAfter)/(1<<20), float64(reclaimed)/(1<<20), gc.Rewritten)
+	return nil
+}
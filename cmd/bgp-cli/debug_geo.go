@@ -19,6 +19,45 @@ type DebugGeoCmd struct {
 }
 
 func (c *DebugGeoCmd) Run() error {
+	geo := openDebugGeo(c.MMDB)
+	defer func() { _ = geo.Close() }()
+
+	resolve := func(s string) {
+		parsedIP := net.ParseIP(s).To4()
+		if parsedIP == nil {
+			fmt.Printf("Invalid IPv4: %s\n", s)
+			return
+		}
+		ipUint := utils.IPToUint32(parsedIP)
+		lat, lng, cc, city, resType := geo.GetIPCoords(ipUint)
+
+		fmt.Printf("IP: %s\n", s)
+		fmt.Printf("  Coords:     %f, %f\n", lat, lng)
+		fmt.Printf("  Country:    %s\n", cc)
+		fmt.Printf("  City:       %s\n", city)
+		fmt.Printf("  Resolution: %s\n", resType)
+		fmt.Println("--------------------------------")
+	}
+
+	if c.IP != "" {
+		resolve(c.IP)
+		return nil
+	}
+
+	fmt.Println("Enter IPs to resolve (one per line, Ctrl+C to exit):")
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			resolve(line)
+		}
+	}
+	return nil
+}
+
+// openDebugGeo loads every geolocation source the viewer uses, plus the given
+// MMDB files, with the hint databases opened read-only.
+func openDebugGeo(mmdbs []string) *geoservice.GeoService {
 	// Initialize GeoService
 	geo := geoservice.NewGeoService(3840, 2160, 760.0)
 
@@ -26,7 +65,6 @@ func (c *DebugGeoCmd) Run() error {
 	if err := geo.OpenHintDBs("data", true); err != nil {
 		log.Printf("Warning: Failed to open hint databases: %v", err)
 	}
-	defer func() { _ = geo.Close() }()
 
 	// Load city data
 	dm := geoservice.NewDataManager(geo)
@@ -35,7 +73,7 @@ func (c *DebugGeoCmd) Run() error {
 		log.Printf("Warning: failed to load remote city data: %v", err)
 	}
 
-	for _, path := range c.MMDB {
+	for _, path := range mmdbs {
 		if err := geo.AddMMDBReader(path); err != nil {
 			log.Printf("Warning: failed to load MMDB database %s: %v", path, err)
 		}
@@ -56,36 +94,5 @@ func (c *DebugGeoCmd) Run() error {
 			geo.SetHubsData(hubsData)
 		}
 	}
-
-	resolve := func(s string) {
-		parsedIP := net.ParseIP(s).To4()
-		if parsedIP == nil {
-			fmt.Printf("Invalid IPv4: %s\n", s)
-			return
-		}
-		ipUint := utils.IPToUint32(parsedIP)
-		lat, lng, cc, city, resType := geo.GetIPCoords(ipUint)
-
-		fmt.Printf("IP: %s\n", s)
-		fmt.Printf("  Coords:     %f, %f\n", lat, lng)
-		fmt.Printf("  Country:    %s\n", cc)
-		fmt.Printf("  City:       %s\n", city)
-		fmt.Printf("  Resolution: %s\n", resType)
-		fmt.Println("--------------------------------")
-	}
-
-	if c.IP != "" {
-		resolve(c.IP)
-		return nil
-	}
-
-	fmt.Println("Enter IPs to resolve (one per line, Ctrl+C to exit):")
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			resolve(line)
-		}
-	}
-	return nil
+	return geo
 }
@@ -5,266 +5,162 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	gobgp "github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+	"github.com/sudorandom/bgp-stream/pkg/simulate"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
 )
 
 type DebugPrefixCmd struct {
-	Prefix  string        `default:"146.66.28.0/22" help:"BGP prefix to watch"`
-	Timeout time.Duration `default:"0" help:"How long to run before exiting (0 for infinite)"`
-	JSON    bool          `help:"Dump raw JSON instead of showing stats"`
+	Prefix  string        `default:"146.66.28.0/22" help:"BGP prefix to trace, along with its more- and less-specific prefixes."`
+	Tape    string        `default:"" help:"Replay a RIS-JSON recording, such as one written by bgp-cli simulate, instead of the live feed."`
+	MRT     []string      `help:"Replay MRT update files, as [collector=]path, instead of the live feed. The collector defaults to the file name up to its first dot."`
+	Timeout time.Duration `default:"0" help:"How long to watch the live feed before exiting (0 for infinite)"`
+	JSON    bool          `help:"Write the trace steps as JSON lines instead of text."`
+	SeenDB  string        `default:"./data/seen-prefixes.db" help:"Seen prefixes database for historical origins (empty to disable)."`
+	RPKIDB  string        `name:"rpki-db" default:"./data/rpki-vrps.db" help:"VRP database kept by the viewer or bgp-cli run (empty to disable)."`
 }
 
-type LastAttrs struct {
-	Path        string
-	Communities string
-	NextHop     string
-	Aggregator  string
+// prefixTrace runs a classifier on the messages for the watched prefix and
+// prints every step of its decisions.
+type prefixTrace struct {
+	watch      netip.Prefix
+	json       bool
+	classifier *bgp_pkg.Classifier
+
+	mu       sync.Mutex
+	now      time.Time
+	prefixes map[string]bool
+	enc      *json.Encoder
 }
 
-type ChurnStats struct {
-	PathChanges       int
-	CommunityChanges  int
-	NextHopChanges    int
-	AggregatorChanges int
-	PathLengthChanges int
-	LastPathLen       int
-}
-
-type Stats struct {
-	mu            sync.Mutex
-	Announcements int
-	Withdrawals   int
-	TotalMessages int
-	Peers         map[string]int
-	PeerChurn     map[string]*ChurnStats
-	PeerLastAttrs map[string]LastAttrs
-	StartTime     time.Time
-}
-
-func (s *Stats) Record(msg []byte, showJSON bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	var res struct {
-		Type string `json:"type"`
-		Data struct {
-			Announcements []struct {
-				NextHop  string   `json:"next_hop"`
-				Prefixes []string `json:"prefixes"`
-			} `json:"announcements"`
-			Withdrawals []string        `json:"withdrawals"`
-			Peer        string          `json:"peer"`
-			Path        []interface{}   `json:"path"`
-			Community   [][]interface{} `json:"community"`
-			Aggregator  string          `json:"aggregator"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(msg, &res); err != nil {
-		return
-	}
-
-	if res.Type != "ris_message" {
-		return
-	}
-
-	s.TotalMessages++
-	peer := res.Data.Peer
-	if peer != "" {
-		s.Peers[peer]++
+func (c *DebugPrefixCmd) Run() error {
+	watch, err := netip.ParsePrefix(c.Prefix)
+	if err != nil {
+		return fmt.Errorf("invalid prefix %q: %v", c.Prefix, err)
 	}
-
-	if len(res.Data.Withdrawals) > 0 {
-		s.Withdrawals += len(res.Data.Withdrawals)
-		return
+	if c.Tape != "" && len(c.MRT) > 0 {
+		return fmt.Errorf("--tape and --mrt are mutually exclusive")
 	}
 
-	if len(res.Data.Announcements) > 0 {
-		s.Announcements += len(res.Data.Announcements)
-
-		pathStr := fmt.Sprintf("%v", res.Data.Path)
-		commStr := fmt.Sprintf("%v", res.Data.Community)
-		nextHop := res.Data.Announcements[0].NextHop
-		agg := res.Data.Aggregator
-		pathLen := len(res.Data.Path)
-
-		if last, ok := s.PeerLastAttrs[peer]; ok {
-			churn, ok := s.PeerChurn[peer]
-			if !ok {
-				churn = &ChurnStats{}
-				s.PeerChurn[peer] = churn
-			}
-
-			if pathStr != last.Path {
-				churn.PathChanges++
-			}
-			if commStr != last.Communities {
-				churn.CommunityChanges++
-			}
-			if nextHop != last.NextHop {
-				churn.NextHopChanges++
-			}
-			if agg != last.Aggregator {
-				churn.AggregatorChanges++
-			}
-			if pathLen != churn.LastPathLen && churn.LastPathLen != 0 {
-				churn.PathLengthChanges++
-			}
-			churn.LastPathLen = pathLen
+	var seenDB *utils.DiskTrie
+	if c.SeenDB != "" {
+		if db, err := utils.OpenDiskTrieReadOnly(c.SeenDB); err != nil {
+			log.Printf("Warning: historical origins unavailable, failed to open seen prefixes database: %v", err)
+		} else {
+			seenDB = db
+			defer func() { _ = seenDB.Close() }()
 		}
-
-		s.PeerLastAttrs[peer] = LastAttrs{
-			Path:        pathStr,
-			Communities: commStr,
-			NextHop:     nextHop,
-			Aggregator:  agg,
+	}
+	var rpki *utils.RPKIManager
+	if c.RPKIDB != "" {
+		if m, err := utils.NewRPKIManagerReadOnly(c.RPKIDB); err != nil {
+			log.Printf("Warning: RPKI validation unavailable, failed to open VRP database: %v", err)
+		} else {
+			rpki = m
+			defer func() { _ = rpki.Close() }()
 		}
 	}
-
-	if showJSON {
-		var prettyJSON bytes.Buffer
-		_ = json.Indent(&prettyJSON, msg, "", "  ")
-		fmt.Printf("%s\n\n", prettyJSON.String())
+	asnMapping := utils.NewASNMapping()
+	if err := asnMapping.Load(); err != nil {
+		log.Printf("Warning: failed to load ASN names: %v", err)
 	}
-}
 
-func (s *Stats) Report() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	elapsed := time.Since(s.StartTime).Seconds()
-	if elapsed <= 0 {
-		elapsed = 1
+	t := &prefixTrace{
+		watch:    watch.Masked(),
+		json:     c.JSON,
+		prefixes: make(map[string]bool),
+		enc:      json.NewEncoder(os.Stdout),
 	}
-
-	fmt.Printf("\033[H\033[2J") // Clear screen
-	fmt.Printf("BGP Prefix Monitor Stats (Running for %.1fs)\n", elapsed)
-	fmt.Printf("--------------------------------------------------\n")
-	fmt.Printf("Announcements: %d (%.2f/s)\n", s.Announcements, float64(s.Announcements)/elapsed)
-	fmt.Printf("Withdrawals:   %d (%.2f/s)\n", s.Withdrawals, float64(s.Withdrawals)/elapsed)
-	fmt.Printf("Total Msgs:    %d (%.2f/s)\n", s.TotalMessages, float64(s.TotalMessages)/elapsed)
-	fmt.Printf("Unique Peers:  %d\n", len(s.Peers))
-	fmt.Printf("--------------------------------------------------\n")
-
-	// Aggregate Churn
-	totalPath, totalComm, totalHop, totalAgg, totalLen := 0, 0, 0, 0, 0
-	for _, c := range s.PeerChurn {
-		totalPath += c.PathChanges
-		totalComm += c.CommunityChanges
-		totalHop += c.NextHopChanges
-		totalAgg += c.AggregatorChanges
-		totalLen += c.PathLengthChanges
+	states := utils.NewLRUCache[string, *bgpproto.PrefixState](1000)
+	t.classifier = bgp_pkg.NewClassifier(seenDB, nil, asnMapping, rpki, prefixToIP, states, func() time.Time { return t.now })
+	t.classifier.SetTraceCallback(t.step)
+
+	switch {
+	case c.Tape != "":
+		err = t.replayTape(c.Tape)
+	case len(c.MRT) > 0:
+		err = t.replayMRT(c.MRT)
+	default:
+		err = t.watchLive(c.Timeout)
 	}
-
-	fmt.Printf("GLOBAL CHURN EVENTS:\n")
-	fmt.Printf("  AS-Path Changes:  %d\n", totalPath)
-	fmt.Printf("  Community Changes: %d\n", totalComm)
-	fmt.Printf("  Next-Hop Changes:  %d\n", totalHop)
-	fmt.Printf("  Aggregator Flaps:  %d\n", totalAgg)
-	fmt.Printf("  Path Length Flaps: %d\n", totalLen)
-	fmt.Printf("--------------------------------------------------\n")
-
-	fmt.Printf("LIKELY CONCLUSIONS:\n")
-	conclusions := s.analyze()
-	if len(conclusions) == 0 {
-		fmt.Printf("  - Routing appears stable (Normal Link)\n")
-	} else {
-		for _, c := range conclusions {
-			fmt.Printf("  - %s\n", c)
-		}
+	if err != nil {
+		return err
 	}
-	fmt.Printf("--------------------------------------------------\n")
+	t.summary()
+	return nil
+}
 
-	// Top Peers
-	type peerChurn struct {
-		IP    string
-		Churn int
-	}
-	var churnList []peerChurn
-	for ip, stats := range s.PeerChurn {
-		total := stats.PathChanges + stats.CommunityChanges + stats.NextHopChanges + stats.AggregatorChanges
-		churnList = append(churnList, peerChurn{ip, total})
+// replayTape traces the messages of a RIS-JSON recording at their timestamps.
+func (t *prefixTrace) replayTape(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
 	}
-	sort.Slice(churnList, func(i, j int) bool {
-		return churnList[i].Churn > churnList[j].Churn
+	defer func() { _ = f.Close() }()
+	return simulate.ReadRISJSON(f, func(m *bgp_pkg.RISMessageData) {
+		t.ris(m, m.Time())
 	})
-
-	maxPeers := 5
-	if len(churnList) < maxPeers {
-		maxPeers = len(churnList)
-	}
-	if maxPeers > 0 {
-		fmt.Printf("Top %d Churning Peers:\n", maxPeers)
-		for i := 0; i < maxPeers; i++ {
-			p := churnList[i]
-			fmt.Printf("  %s: %d attribute changes\n", p.IP, p.Churn)
-		}
-	}
 }
 
-func (s *Stats) analyze() []string {
-	var results []string
-	elapsed := time.Since(s.StartTime).Seconds()
-	msgRate := float64(s.TotalMessages) / elapsed
-
-	totalPath, totalComm, totalHop, totalAgg, totalLen := 0, 0, 0, 0, 0
-	for _, c := range s.PeerChurn {
-		totalPath += c.PathChanges
-		totalComm += c.CommunityChanges
-		totalHop += c.NextHopChanges
-		totalAgg += c.AggregatorChanges
-		totalLen += c.PathLengthChanges
-	}
-
-	// 1. Check for Anycast
-	// If many peers see different NextHops but the path length is stable and rate is low
-	uniqueHops := make(map[string]bool)
-	for _, attr := range s.PeerLastAttrs {
-		if attr.NextHop != "" {
-			uniqueHops[attr.NextHop] = true
+// replayMRT traces the updates of MRT files in time order.
+func (t *prefixTrace) replayMRT(specs []string) error {
+	var msgs []*mrtfile.Message
+	for _, spec := range specs {
+		collector, path, named := strings.Cut(spec, "=")
+		if !named {
+			path = collector
+			collector, _, _ = strings.Cut(filepath.Base(path), ".")
 		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		mrtfile.ScanMessages(collector, path, func(msg *mrtfile.Message) {
+			msgs = append(msgs, msg)
+		})
 	}
-	if len(uniqueHops) > 5 && msgRate < 1.0 {
-		results = append(results, "Signs of Anycast (Multiple entry points detected)")
-	}
-
-	// 2. Aggregator Flapping
-	if totalAgg > 10 && float64(totalAgg)/elapsed > 0.05 {
-		results = append(results, "Aggregator Flapping (Origin router is re-summarizing frequently)")
-	}
-
-	// 3. Path Length Oscillation
-	if totalLen > 10 && float64(totalLen)/elapsed > 0.05 {
-		results = append(results, "Path Length Oscillation (Route is toggling between different path lengths)")
-	}
-
-	// 4. Link Flap (High Withdrawal Ratio)
-	if s.Withdrawals > 5 && float64(s.Announcements)/float64(s.Withdrawals) < 2.5 {
-		results = append(results, "Link Flap (High ratio of withdrawals suggesting physical/session instability)")
-	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Timestamp.Before(msgs[j].Timestamp) })
 
-	// 5. Path Hunting
-	if s.Announcements > 50 && s.Withdrawals < (s.Announcements/10) && totalPath > s.Announcements/2 {
-		results = append(results, "Path Hunting (Router is exploring alternative paths after a failure)")
+	for _, msg := range msgs {
+		update, ok := msg.Message.Body.(*gobgp.BGPUpdate)
+		if !ok {
+			continue
+		}
+		ctx := mrtfile.Context(msg, update.PathAttributes)
+		for _, nlri := range update.NLRI {
+			t.classify(nlri.String(), ctx)
+		}
+		withdrawal := *ctx
+		withdrawal.IsWithdrawal = true
+		for _, nlri := range update.WithdrawnRoutes {
+			t.classify(nlri.String(), &withdrawal)
+		}
 	}
-
-	return results
+	return nil
 }
 
-func (c *DebugPrefixCmd) Run() error {
+// watchLive subscribes to the prefix on RIS Live and traces its messages as
+// they arrive, until interrupted or the timeout passes.
+func (t *prefixTrace) watchLive(timeout time.Duration) error {
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
 
-	if c.Timeout > 0 {
+	if timeout > 0 {
 		go func() {
-			time.Sleep(c.Timeout)
-			log.Printf("Timeout of %v reached, exiting...", c.Timeout)
+			time.Sleep(timeout)
+			log.Printf("Timeout of %v reached, exiting...", timeout)
 			interrupt <- os.Interrupt
 		}()
 	}
@@ -283,13 +179,6 @@ func (c *DebugPrefixCmd) Run() error {
 		_ = conn.Close()
 	}()
 
-	stats := &Stats{
-		Peers:         make(map[string]int),
-		PeerChurn:     make(map[string]*ChurnStats),
-		PeerLastAttrs: make(map[string]LastAttrs),
-		StartTime:     time.Now(),
-	}
-
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
@@ -298,7 +187,9 @@ func (c *DebugPrefixCmd) Run() error {
 			if err != nil {
 				return
 			}
-			stats.Record(message, c.JSON)
+			_ = simulate.ReadRISJSON(bytes.NewReader(message), func(m *bgp_pkg.RISMessageData) {
+				t.ris(m, time.Now())
+			})
 		}
 	}()
 
@@ -306,43 +197,115 @@ func (c *DebugPrefixCmd) Run() error {
 	subscribeMsg := map[string]interface{}{
 		"type": "ris_subscribe",
 		"data": map[string]interface{}{
-			"prefix":       c.Prefix,
+			"prefix":       t.watch.String(),
 			"moreSpecific": true,
 			"lessSpecific": true,
 		},
 	}
 	subBytes, _ := json.Marshal(subscribeMsg)
-	log.Printf("Subscribing to: %s", c.Prefix)
+	log.Printf("Subscribing to: %s", t.watch)
 	err = conn.WriteMessage(websocket.TextMessage, subBytes)
 	if err != nil {
 		return fmt.Errorf("subscribe error: %v", err)
 	}
 
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-
-	for {
+	select {
+	case <-done:
+		return nil
+	case <-interrupt:
+		log.Println("Exiting...")
+		err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		if err != nil {
+			return err
+		}
 		select {
 		case <-done:
-			return nil
-		case <-ticker.C:
-			if !c.JSON {
-				stats.Report()
-			}
-		case <-interrupt:
-			log.Println("Exiting...")
-			if !c.JSON {
-				stats.Report()
-			}
-			err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			if err != nil {
-				return err
-			}
-			select {
-			case <-done:
-			case <-time.After(time.Second):
-			}
-			return nil
+		case <-time.After(time.Second):
+		}
+		return nil
+	}
+}
+
+// ris traces the prefixes of a RIS message received at now the way the
+// processor hands them to its classifiers.
+func (t *prefixTrace) ris(m *bgp_pkg.RISMessageData, now time.Time) {
+	ctx := bgp_pkg.NewMessageContext(m, now)
+	withdrawal := *ctx
+	withdrawal.IsWithdrawal = true
+	for _, prefix := range m.Withdrawals {
+		t.classify(prefix, &withdrawal)
+	}
+	for _, ann := range m.Announcements {
+		ctx.NumPrefixes = len(ann.Prefixes)
+		ctx.NextHop = ann.NextHop
+		for _, prefix := range ann.Prefixes {
+			t.classify(prefix, ctx)
+		}
+	}
+}
+
+// classify passes a prefix of a message to the classifier if it overlaps the
+// watched prefix.
+func (t *prefixTrace) classify(prefix string, ctx *bgp_pkg.MessageContext) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil || !p.Overlaps(t.watch) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = ctx.Now
+	t.prefixes[prefix] = true
+	if !t.json {
+		action := "announce " + ctx.PathStr
+		if ctx.IsWithdrawal {
+			action = "withdraw"
+		}
+		fmt.Printf("\n%s  %s  %s from %s peer %s\n", ctx.Now.Format("2006-01-02 15:04:05.000"), prefix, action, ctx.Host, ctx.Peer)
+	}
+	t.classifier.ClassifyEvent(prefix, ctx)
+}
+
+// step prints a trace step. It is called from ClassifyEvent, with mu held.
+func (t *prefixTrace) step(s *bgp_pkg.TraceStep) {
+	if t.json {
+		_ = t.enc.Encode(s)
+		return
+	}
+	if s.Stage == bgp_pkg.TraceRule {
+		outcome := "no   "
+		if s.Fired {
+			outcome = "FIRED"
+		}
+		fmt.Printf("    %-7s %s %s: %s\n", s.Stage, outcome, s.Rule, s.Detail)
+		return
+	}
+	fmt.Printf("    %-7s %s\n", s.Stage, s.Detail)
+}
+
+// summary prints where each traced prefix ended up.
+func (t *prefixTrace) summary() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.json || len(t.prefixes) == 0 {
+		return
+	}
+	prefixes := make([]string, 0, len(t.prefixes))
+	for p := range t.prefixes {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	fmt.Println()
+	for _, p := range prefixes {
+		state, ok := t.classifier.GetPrefixState(p)
+		if !ok {
+			continue
+		}
+		classification := bgp_pkg.ClassificationType(state.ClassifiedType)
+		since := ""
+		if classification != bgp_pkg.ClassificationNone {
+			since = " since " + time.Unix(state.ClassifiedTimeTs, 0).UTC().Format("2006-01-02 15:04:05")
 		}
+		fmt.Printf("%s: %s%s, origin AS%d, RPKI %s, %d peer routes\n",
+			p, classification, since, state.LastOriginAsn, utils.RPKIStatus(state.LastRpkiStatus), len(state.PeerLastAttrs))
 	}
 }
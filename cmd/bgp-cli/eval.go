@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/eval"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+type EvalCmd struct {
+	Catalogue  string `arg:"" type:"existingfile" help:"JSON catalogue of labeled incidents and the MRT files to replay for them."`
+	Format     string `default:"table" enum:"table,json" help:"Output format (table, json)."`
+	FullBogons bool   `help:"Also flag prefixes and ASNs from unallocated space (requires bgp-cli fetch)."`
+}
+
+// evalIncident is an incident result as written by --format json.
+type evalIncident struct {
+	Name           string          `json:"name"`
+	Expected       string          `json:"expected"`
+	Predicted      string          `json:"predicted"`
+	Detected       bool            `json:"detected"`
+	LatencySeconds float64         `json:"latency_seconds,omitempty"`
+	Messages       int             `json:"messages"`
+	TruePositives  int             `json:"true_positives"`
+	FalsePositives int             `json:"false_positives"`
+	Detections     []evalDetection `json:"detections"`
+}
+
+type evalDetection struct {
+	Time           time.Time `json:"time"`
+	Prefix         string    `json:"prefix"`
+	Classification string    `json:"classification"`
+	Leaker         uint32    `json:"leaker,omitempty"`
+	Victim         uint32    `json:"victim,omitempty"`
+}
+
+type evalType struct {
+	Classification     string  `json:"classification"`
+	Incidents          int     `json:"incidents"`
+	Detected           int     `json:"detected"`
+	TruePositives      int     `json:"true_positives"`
+	FalsePositives     int     `json:"false_positives"`
+	Precision          float64 `json:"precision"`
+	Recall             float64 `json:"recall"`
+	MeanLatencySeconds float64 `json:"mean_latency_seconds"`
+	MaxLatencySeconds  float64 `json:"max_latency_seconds"`
+}
+
+func (c *EvalCmd) Run() error {
+	catalogue, err := eval.LoadCatalogue(c.Catalogue)
+	if err != nil {
+		return err
+	}
+
+	opts := eval.Options{ASNMapping: utils.NewASNMapping()}
+	if err := opts.ASNMapping.Load(); err != nil {
+		log.Printf("Warning: failed to load ASN names: %v", err)
+	}
+	if c.FullBogons {
+		opts.FullBogons, err = utils.LoadFullBogons(utils.FullBogonsPath)
+		if err != nil {
+			return fmt.Errorf("failed to load full bogons: %v", err)
+		}
+	}
+
+	log.Printf("Replaying %d incidents from %s...", len(catalogue.Incidents), c.Catalogue)
+	report, err := eval.Run(catalogue, opts)
+	if err != nil {
+		return err
+	}
+
+	if c.Format == "json" {
+		return writeEvalJSON(report)
+	}
+	return writeEvalTable(report)
+}
+
+func writeEvalJSON(r *eval.Report) error {
+	incidents := make([]evalIncident, 0, len(r.Incidents))
+	for _, res := range r.Incidents {
+		inc := evalIncident{
+			Name:           res.Incident.Name,
+			Expected:       res.Incident.Expected().Key(),
+			Predicted:      res.Predicted.Key(),
+			Detected:       res.Detected,
+			Messages:       res.Messages,
+			TruePositives:  res.TruePositives,
+			FalsePositives: res.FalsePositives,
+			Detections:     []evalDetection{},
+		}
+		if res.Detected && res.Incident.Expected() != bgp.ClassificationNone {
+			inc.LatencySeconds = res.Latency.Seconds()
+		}
+		for _, d := range res.Detections {
+			inc.Detections = append(inc.Detections, evalDetection{
+				Time:           d.Time,
+				Prefix:         d.Prefix,
+				Classification: d.Classification.Key(),
+				Leaker:         d.Leaker,
+				Victim:         d.Victim,
+			})
+		}
+		incidents = append(incidents, inc)
+	}
+
+	types := make([]evalType, 0, len(r.Types))
+	for _, s := range r.Types {
+		types = append(types, evalType{
+			Classification:     s.Classification.Key(),
+			Incidents:          s.Incidents,
+			Detected:           s.Detected,
+			TruePositives:      s.TruePositives,
+			FalsePositives:     s.FalsePositives,
+			Precision:          s.Precision(),
+			Recall:             s.Recall(),
+			MeanLatencySeconds: s.MeanLatency.Seconds(),
+			MaxLatencySeconds:  s.MaxLatency.Seconds(),
+		})
+	}
+
+	confusion := make(map[string]map[string]int, len(r.Confusion))
+	for expected, row := range r.Confusion {
+		out := make(map[string]int, len(row))
+		for predicted, n := range row {
+			out[predicted.Key()] = n
+		}
+		confusion[expected.Key()] = out
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]any{"incidents": incidents, "types": types, "confusion": confusion})
+}
+
+func writeEvalTable(r *eval.Report) error {
+	incidents := []string{"INCIDENT\tEXPECTED\tPREDICTED\tDETECTED\tLATENCY\tTP\tFP\tMESSAGES"}
+	for _, res := range r.Incidents {
+		detected, latency := "No", "-"
+		if res.Detected {
+			detected = "Yes"
+			if res.Incident.Expected() != bgp.ClassificationNone {
+				latency = res.Latency.String()
+			}
+		}
+		incidents = append(incidents, fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d",
+			res.Incident.Name, res.Incident.Expected(), res.Predicted, detected, latency,
+			res.TruePositives, res.FalsePositives, res.Messages))
+	}
+
+	types := []string{"CLASSIFICATION\tINCIDENTS\tDETECTED\tTP\tFP\tPRECISION\tRECALL\tMEAN LATENCY\tMAX LATENCY"}
+	for _, s := range r.Types {
+		types = append(types, fmt.Sprintf("%s\t%d\t%d\t%d\t%d\t%s\t%s\t%s\t%s",
+			s.Classification, s.Incidents, s.Detected, s.TruePositives, s.FalsePositives,
+			evalRatio(s.TruePositives+s.FalsePositives, s.Precision()),
+			evalRatio(s.Incidents, s.Recall()),
+			s.MeanLatency, s.MaxLatency))
+	}
+
+	// Confusion matrix with a row per expected classification and a column
+	// per predicted one
+	var expected, predicted []bgp.ClassificationType
+	for e, row := range r.Confusion {
+		expected = append(expected, e)
+		for p := range row {
+			if !slices.Contains(predicted, p) {
+				predicted = append(predicted, p)
+			}
+		}
+	}
+	slices.Sort(expected)
+	slices.Sort(predicted)
+	header := []string{"EXPECTED \\ PREDICTED"}
+	for _, p := range predicted {
+		header = append(header, strings.ToUpper(p.String()))
+	}
+	confusion := []string{strings.Join(header, "\t")}
+	for _, e := range expected {
+		cells := []string{e.String()}
+		for _, p := range predicted {
+			cells = append(cells, fmt.Sprintf("%d", r.Confusion[e][p]))
+		}
+		confusion = append(confusion, strings.Join(cells, "\t"))
+	}
+
+	for i, table := range [][]string{incidents, types, confusion} {
+		if i > 0 {
+			fmt.Println()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		for _, line := range table {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalRatio formats a precision or recall, or a dash if there was nothing to
+// compute it from.
+func evalRatio(n int, ratio float64) string {
+	if n == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", ratio)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
+)
+
+type EventsCmd struct {
+	Dir     string        `default:"./data/journal" help:"Path to the event journal directory."`
+	Since   string        `default:"" help:"Only show events at or after this time (YYYY-MM-DD HH:mm, UTC)."`
+	Until   string        `default:"" help:"Only show events before this time (YYYY-MM-DD HH:mm, UTC)."`
+	Last    time.Duration `default:"0" help:"Only show events from this long ago until now (e.g. 24h). Overrides --since."`
+	Prefix  string        `default:"" help:"Only show events for this prefix and the more-specifics it covers."`
+	ASN     uint32        `name:"asn" default:"0" help:"Only show events involving this ASN as origin, previous origin, leaker or victim."`
+	Country string        `default:"" help:"Only show events located in this country (ISO code)."`
+	Types   []string      `sep:"," help:"Only show transitions to these states (flap,path_hunting,traffic_eng,outage,route_leak,discovery,ddos_mitigation,bgp_hijack,bogon_martian)."`
+	Format  string        `default:"table" enum:"table,jsonl,csv" help:"Output format (table, jsonl, csv)."`
+	Limit   int           `default:"0" help:"Stop after this many events (0 for no limit)."`
+}
+
+var errEventLimit = errors.New("event limit reached")
+
+func (c *EventsCmd) Run() error {
+	filter, err := c.filter()
+	if err != nil {
+		return err
+	}
+
+	out, flush := newEventWriter(c.Format)
+	count := 0
+	err = journal.Query(c.Dir, filter, func(e *journal.Entry) error {
+		if c.Limit > 0 && count >= c.Limit {
+			return errEventLimit
+		}
+		count++
+		return out(e)
+	})
+	if err != nil && !errors.Is(err, errEventLimit) {
+		return err
+	}
+	return flush()
+}
+
+func (c *EventsCmd) filter() (journal.Filter, error) {
+	var f journal.Filter
+	var err error
+	if c.Since != "" {
+		if f.From, err = time.Parse("2006-01-02 15:04", c.Since); err != nil {
+			return f, fmt.Errorf("invalid since time: %v", err)
+		}
+	}
+	if c.Last > 0 {
+		f.From = time.Now().Add(-c.Last)
+	}
+	if c.Until != "" {
+		if f.To, err = time.Parse("2006-01-02 15:04", c.Until); err != nil {
+			return f, fmt.Errorf("invalid until time: %v", err)
+		}
+	}
+	if c.Prefix != "" {
+		if f.Prefix, err = netip.ParsePrefix(c.Prefix); err != nil {
+			return f, fmt.Errorf("invalid prefix: %v", err)
+		}
+	}
+	f.ASN = c.ASN
+	f.Country = c.Country
+	for _, key := range c.Types {
+		t, ok := bgp.ParseClassificationKey(key)
+		if !ok {
+			return f, fmt.Errorf("unknown state %q", key)
+		}
+		f.Types = append(f.Types, t)
+	}
+	return f, nil
+}
+
+func newEventWriter(format string) (write func(e *journal.Entry) error, flush func() error) {
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(os.Stdout)
+		return func(e *journal.Entry) error { return enc.Encode(e) }, func() error { return nil }
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write(eventCSVHeader)
+		write = func(e *journal.Entry) error {
+			return w.Write(eventCSVRow(e))
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+		return write, flush
+	default:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		if _, err := fmt.Fprintln(w, "TIME\tPREFIX\tTRANSITION\tORIGIN\tCOUNTRY\tDETAIL\tEVIDENCE"); err != nil {
+			return func(*journal.Entry) error { return err }, func() error { return err }
+		}
+		return func(e *journal.Entry) error {
+			origin := "-"
+			if e.OriginASN != 0 {
+				origin = fmt.Sprintf("AS%d", e.OriginASN)
+			}
+			country := e.Country
+			if country == "" {
+				country = "-"
+			}
+			_, err := fmt.Fprintf(w, "%s\t%s\t%s -> %s\t%s\t%s\t%s\t%s\n",
+				e.Time.UTC().Format("2006-01-02 15:04:05"), e.Prefix,
+				e.PreviousClassification(), e.Classification(),
+				origin, country, eventDetail(e), eventEvidence(e))
+			return err
+		}, w.Flush
+	}
+}
+
+func eventDetail(e *journal.Entry) string {
+	if e.Leak == nil {
+		return "-"
+	}
+	var parts []string
+	if e.Leak.Type != "" {
+		parts = append(parts, e.Leak.Type)
+	}
+	if e.Leak.LeakerASN != 0 {
+		parts = append(parts, fmt.Sprintf("leaker AS%d", e.Leak.LeakerASN))
+	}
+	if e.Leak.VictimASN != 0 {
+		parts = append(parts, fmt.Sprintf("victim AS%d", e.Leak.VictimASN))
+	}
+	if e.Leak.PeakVisibility > 0 {
+		parts = append(parts, fmt.Sprintf("visibility %.0f%% (peak %.0f%%)", e.Leak.Visibility*100, e.Leak.PeakVisibility*100))
+	}
+	if e.Leak.Reason != "" {
+		parts = append(parts, e.Leak.Reason)
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func eventEvidence(e *journal.Entry) string {
+	ev := e.Evidence
+	s := fmt.Sprintf("%d msgs, %d peers/%d hosts", ev.Messages, ev.Peers, ev.Hosts)
+	if ev.WithdrawnPeers > 0 {
+		s += fmt.Sprintf(", %d withdrawn", ev.WithdrawnPeers)
+	}
+	if ev.RPKI != "" {
+		s += ", RPKI " + ev.RPKI
+	}
+	return s
+}
+
+var eventCSVHeader = []string{
+	"time", "prefix", "previous_type", "type", "incident", "origin_asn", "historical_asn", "country", "city",
+	"leak_type", "leaker_asn", "victim_asn", "visibility", "peak_visibility", "reason",
+	"peer", "host", "path", "messages", "announcements", "withdrawals", "path_changes",
+	"peers", "hosts", "withdrawn_peers", "withdrawn_hosts", "origins", "anomaly_score", "rpki",
+}
+
+func eventCSVRow(e *journal.Entry) []string {
+	leak := e.Leak
+	if leak == nil {
+		leak = &journal.Leak{}
+	}
+	ev := e.Evidence
+	itoa := func(n int) string { return strconv.Itoa(n) }
+	utoa := func(n uint32) string { return strconv.FormatUint(uint64(n), 10) }
+	ftoa := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	return []string{
+		e.Time.UTC().Format(time.RFC3339), e.Prefix, e.PreviousType, e.Type, strconv.FormatBool(e.Incident),
+		utoa(e.OriginASN), utoa(e.HistoricalASN), e.Country, e.City,
+		leak.Type, utoa(leak.LeakerASN), utoa(leak.VictimASN), ftoa(leak.Visibility), ftoa(leak.PeakVisibility), leak.Reason,
+		ev.Peer, ev.Host, ev.Path, itoa(int(ev.Messages)), itoa(int(ev.Announcements)), itoa(int(ev.Withdrawals)), itoa(int(ev.PathChanges)),
+		itoa(ev.Peers), itoa(ev.Hosts), itoa(ev.WithdrawnPeers), itoa(ev.WithdrawnHosts), itoa(ev.Origins), ftoa(ev.AnomalyScore), ev.RPKI,
+	}
+}
@@ -0,0 +1,868 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+//go:embed incident_report.html
+var incidentReportHTML string
+
+const (
+	numClassifications = int(bgp_pkg.ClassificationBogon) + 1
+
+	// reportTopIncidents is how many incidents the report lists.
+	reportTopIncidents = 25
+	// reportLeakPaths is how many distinct AS paths are drawn per leak.
+	reportLeakPaths = 3
+	// maxIncidentPaths bounds the distinct AS paths kept per incident.
+	maxIncidentPaths = 50
+)
+
+var classificationColors = [numClassifications]string{
+	bgp_pkg.ClassificationNone:               "#777777",
+	bgp_pkg.ClassificationFlap:               "#ff7f00",
+	bgp_pkg.ClassificationPathHunting:        "#9400d3",
+	bgp_pkg.ClassificationTrafficEngineering: "#daa520",
+	bgp_pkg.ClassificationOutage:             "#ff3232",
+	bgp_pkg.ClassificationRouteLeak:          "#ff0000",
+	bgp_pkg.ClassificationDiscovery:          "#00bfff",
+	bgp_pkg.ClassificationDDoSMitigation:     "#da70d6",
+	bgp_pkg.ClassificationHijack:             "#ff1493",
+	bgp_pkg.ClassificationBogon:              "#a0a0a0",
+}
+
+// incidentReport collects what the HTML report of an analyze run shows:
+// classification counts over time, the incidents behind the critical
+// classifications and what each collector saw. Workers record concurrently.
+type incidentReport struct {
+	start, end time.Time
+
+	// messages and peers are only touched by the replay loop.
+	messages map[string]int
+	peers    map[string]map[string]struct{}
+
+	mu          sync.Mutex
+	timeline    map[int64]*[numClassifications]int
+	prefixes    [numClassifications]map[string]struct{}
+	transitions map[string]int
+	incidents   map[incidentKey]*incident
+}
+
+// incidentKey groups the transitions of one network: the leaker of a route
+// leak or DDoS mitigation, otherwise the origin.
+type incidentKey struct {
+	typ bgp_pkg.ClassificationType
+	asn uint32
+}
+
+type incident struct {
+	incidentKey
+	victim      uint32
+	leakType    bgp_pkg.LeakType
+	prefixes    map[string]struct{}
+	transitions int
+	first, last time.Time
+	collectors  map[string]struct{}
+	peers       map[string]struct{}
+	// paths holds the peers that carried each AS path of a route leak.
+	paths map[string]map[string]struct{}
+}
+
+func newIncidentReport(start, end time.Time) *incidentReport {
+	r := &incidentReport{
+		start:       start,
+		end:         end,
+		messages:    make(map[string]int),
+		peers:       make(map[string]map[string]struct{}),
+		timeline:    make(map[int64]*[numClassifications]int),
+		transitions: make(map[string]int),
+		incidents:   make(map[incidentKey]*incident),
+	}
+	for i := range r.prefixes {
+		r.prefixes[i] = make(map[string]struct{})
+	}
+	return r
+}
+
+// message counts a replayed message against its collector.
+func (r *incidentReport) message(msg *mrtfile.Message) {
+	if r == nil {
+		return
+	}
+	r.messages[msg.Collector]++
+	peers := r.peers[msg.Collector]
+	if peers == nil {
+		peers = make(map[string]struct{})
+		r.peers[msg.Collector] = peers
+	}
+	peers[msg.Peer] = struct{}{}
+}
+
+// classified records a prefix changing to ev's classification on the message
+// described by ctx. The peers in state are the ones that saw it.
+func (r *incidentReport) classified(ev bgp_pkg.PendingEvent, state *bgpproto.PrefixState, ctx *bgp_pkg.MessageContext) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	typ := ev.ClassificationType
+	minute := ctx.Now.Unix() / 60
+	counts := r.timeline[minute]
+	if counts == nil {
+		counts = new([numClassifications]int)
+		r.timeline[minute] = counts
+	}
+	counts[typ]++
+	r.prefixes[typ][ev.Prefix] = struct{}{}
+	r.transitions[ctx.Host]++
+
+	if !typ.IsCritical() {
+		return
+	}
+	key := incidentKey{typ: typ, asn: ev.ASN}
+	victim := ev.HistoricalASN
+	if ld := ev.LeakDetail; ld != nil {
+		if ld.LeakerASN != 0 {
+			key.asn = ld.LeakerASN
+		}
+		if ld.VictimASN != 0 {
+			victim = ld.VictimASN
+		}
+	}
+	inc := r.incidents[key]
+	if inc == nil {
+		inc = &incident{
+			incidentKey: key,
+			prefixes:    make(map[string]struct{}),
+			first:       ctx.Now,
+			collectors:  make(map[string]struct{}),
+			peers:       make(map[string]struct{}),
+			paths:       make(map[string]map[string]struct{}),
+		}
+		r.incidents[key] = inc
+	}
+	if victim != 0 && victim != key.asn {
+		inc.victim = victim
+	}
+	if ev.LeakDetail != nil && ev.LeakDetail.Type != bgp_pkg.LeakUnknown {
+		inc.leakType = ev.LeakDetail.Type
+	}
+	inc.prefixes[ev.Prefix] = struct{}{}
+	inc.transitions++
+	inc.last = ctx.Now
+	inc.collectors[ctx.Host] = struct{}{}
+	inc.peers[ctx.Host+":"+ctx.Peer] = struct{}{}
+	leaker := fmt.Sprint(key.asn)
+	for peer, attrs := range state.PeerLastAttrs {
+		if attrs.Host != "" {
+			inc.collectors[attrs.Host] = struct{}{}
+		}
+		inc.peers[peer] = struct{}{}
+		if typ != bgp_pkg.ClassificationRouteLeak || attrs.Withdrawn || !slices.Contains(strings.Fields(strings.Trim(attrs.Path, "[]")), leaker) {
+			continue
+		}
+		peers := inc.paths[attrs.Path]
+		if peers == nil {
+			if len(inc.paths) >= maxIncidentPaths {
+				continue
+			}
+			peers = make(map[string]struct{})
+			inc.paths[attrs.Path] = peers
+		}
+		peers[peer] = struct{}{}
+	}
+}
+
+// incidentReportSnapshot is what an incidentReport collected, saved with the
+// checkpoints of analyze.
+type incidentReportSnapshot struct {
+	Messages    map[string]int      `json:"messages"`
+	Peers       map[string][]string `json:"peers"`
+	Timeline    map[int64][]int     `json:"timeline"`
+	Prefixes    [][]string          `json:"prefixes"`
+	Transitions map[string]int      `json:"transitions"`
+	Incidents   []incidentSnapshot  `json:"incidents"`
+}
+
+type incidentSnapshot struct {
+	Type        bgp_pkg.ClassificationType `json:"type"`
+	ASN         uint32                     `json:"asn"`
+	Victim      uint32                     `json:"victim,omitempty"`
+	LeakType    bgp_pkg.LeakType           `json:"leak_type,omitempty"`
+	Prefixes    []string                   `json:"prefixes"`
+	Transitions int                        `json:"transitions"`
+	First       time.Time                  `json:"first"`
+	Last        time.Time                  `json:"last"`
+	Collectors  []string                   `json:"collectors"`
+	Peers       []string                   `json:"peers"`
+	Paths       map[string][]string        `json:"paths,omitempty"`
+}
+
+// snapshot copies what the report collected so far. It is called from the
+// replay loop.
+func (r *incidentReport) snapshot() *incidentReportSnapshot {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &incidentReportSnapshot{
+		Messages:    maps.Clone(r.messages),
+		Peers:       make(map[string][]string, len(r.peers)),
+		Timeline:    make(map[int64][]int, len(r.timeline)),
+		Transitions: maps.Clone(r.transitions),
+	}
+	for collector, peers := range r.peers {
+		s.Peers[collector] = setKeys(peers)
+	}
+	for minute, counts := range r.timeline {
+		s.Timeline[minute] = slices.Clone(counts[:])
+	}
+	for _, prefixes := range r.prefixes {
+		s.Prefixes = append(s.Prefixes, setKeys(prefixes))
+	}
+	for _, inc := range r.incidents {
+		is := incidentSnapshot{
+			Type:        inc.typ,
+			ASN:         inc.asn,
+			Victim:      inc.victim,
+			LeakType:    inc.leakType,
+			Prefixes:    setKeys(inc.prefixes),
+			Transitions: inc.transitions,
+			First:       inc.first,
+			Last:        inc.last,
+			Collectors:  setKeys(inc.collectors),
+			Peers:       setKeys(inc.peers),
+		}
+		if len(inc.paths) > 0 {
+			is.Paths = make(map[string][]string, len(inc.paths))
+			for path, peers := range inc.paths {
+				is.Paths[path] = setKeys(peers)
+			}
+		}
+		s.Incidents = append(s.Incidents, is)
+	}
+	return s
+}
+
+// restore replaces what the report collected with a snapshot.
+func (r *incidentReport) restore(s *incidentReportSnapshot) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = maps.Clone(s.Messages)
+	if r.messages == nil {
+		r.messages = make(map[string]int)
+	}
+	r.peers = make(map[string]map[string]struct{}, len(s.Peers))
+	for collector, peers := range s.Peers {
+		r.peers[collector] = keySet(peers)
+	}
+	r.timeline = make(map[int64]*[numClassifications]int, len(s.Timeline))
+	for minute, counts := range s.Timeline {
+		c := new([numClassifications]int)
+		copy(c[:], counts)
+		r.timeline[minute] = c
+	}
+	for i := range r.prefixes {
+		r.prefixes[i] = make(map[string]struct{})
+		if i < len(s.Prefixes) {
+			r.prefixes[i] = keySet(s.Prefixes[i])
+		}
+	}
+	r.transitions = maps.Clone(s.Transitions)
+	if r.transitions == nil {
+		r.transitions = make(map[string]int)
+	}
+	r.incidents = make(map[incidentKey]*incident, len(s.Incidents))
+	for _, is := range s.Incidents {
+		inc := &incident{
+			incidentKey: incidentKey{typ: is.Type, asn: is.ASN},
+			victim:      is.Victim,
+			leakType:    is.LeakType,
+			prefixes:    keySet(is.Prefixes),
+			transitions: is.Transitions,
+			first:       is.First,
+			last:        is.Last,
+			collectors:  keySet(is.Collectors),
+			peers:       keySet(is.Peers),
+			paths:       make(map[string]map[string]struct{}, len(is.Paths)),
+		}
+		for path, peers := range is.Paths {
+			inc.paths[path] = keySet(peers)
+		}
+		r.incidents[inc.incidentKey] = inc
+	}
+}
+
+func setKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func keySet(keys []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
+
+type htmlPage struct {
+	Start, End  string
+	Generated   string
+	Collectors  int
+	Messages    int
+	Transitions int
+	Incidents   int
+	Counts      []htmlCount
+	Timeline    htmlTimeline
+	Map         htmlMap
+	Top         []htmlIncident
+	Leaks       []htmlLeak
+	Visibility  []htmlCollector
+}
+
+type htmlCount struct {
+	Name, Color string
+	Critical    bool
+	Transitions int
+	Prefixes    int
+}
+
+// htmlTimeline is one bar chart per classification, each scaled to its own
+// peak so rare incidents stay visible next to routine churn.
+type htmlTimeline struct {
+	Bucket string
+	Width  int
+	Ticks  []htmlTick
+	Rows   []htmlTimelineRow
+}
+
+type htmlTick struct {
+	X     float64
+	Label string
+}
+
+type htmlTimelineRow struct {
+	Name, Color string
+	Peak        int
+	Bars        []htmlBar
+}
+
+type htmlBar struct {
+	X, Y, W, H float64
+	Title      string
+}
+
+type htmlMap struct {
+	ViewBox   string
+	Outline   string
+	Graticule string
+	Land      string
+	Spots     []htmlSpot
+}
+
+type htmlSpot struct {
+	X, Y, R float64
+	Color   string
+	Title   string
+}
+
+type htmlIncident struct {
+	Type, Color string
+	Network     string
+	Victim      string
+	LeakType    string
+	Prefixes    int
+	Sample      string
+	ImpactedIPs uint64
+	Countries   string
+	Collectors  string
+	Peers       int
+	First, Last string
+	Duration    string
+	Transitions int
+}
+
+type htmlLeak struct {
+	Title string
+	Paths []htmlPathDiagram
+}
+
+type htmlPathDiagram struct {
+	Peers int
+	Width int
+	Nodes []htmlNode
+}
+
+type htmlNode struct {
+	X, ArrowFrom int
+	Label, Name  string
+	Role         string
+}
+
+type htmlCollector struct {
+	Name        string
+	Messages    int
+	Peers       int
+	Transitions int
+	Incidents   int
+	Share       float64
+}
+
+// write renders the report to path as a single HTML file with inline SVG.
+func (r *incidentReport) write(path string, collectors []mrtCollector, geo *geoservice.GeoService, asnMapping *utils.ASNMapping) error {
+	page := &htmlPage{
+		Start:     r.start.UTC().Format("2006-01-02 15:04 UTC"),
+		End:       r.end.UTC().Format("2006-01-02 15:04 UTC"),
+		Generated: time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+		Incidents: len(r.incidents),
+	}
+	for _, n := range r.messages {
+		page.Messages += n
+	}
+	var totals [numClassifications]int
+	for _, counts := range r.timeline {
+		for t, n := range counts {
+			totals[t] += n
+			page.Transitions += n
+		}
+	}
+	for t := bgp_pkg.ClassificationFlap; int(t) < numClassifications; t++ {
+		if totals[t] == 0 {
+			continue
+		}
+		page.Counts = append(page.Counts, htmlCount{
+			Name:        t.String(),
+			Color:       classificationColors[t],
+			Critical:    t.IsCritical(),
+			Transitions: totals[t],
+			Prefixes:    len(r.prefixes[t]),
+		})
+	}
+	sort.SliceStable(page.Counts, func(i, j int) bool { return page.Counts[i].Transitions > page.Counts[j].Transitions })
+
+	page.Timeline = r.timelineChart(totals)
+	page.Map = r.worldMap(geo)
+
+	incidents := make([]*incident, 0, len(r.incidents))
+	for _, inc := range r.incidents {
+		incidents = append(incidents, inc)
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		a, b := incidents[i], incidents[j]
+		if len(a.prefixes) != len(b.prefixes) {
+			return len(a.prefixes) > len(b.prefixes)
+		}
+		if a.transitions != b.transitions {
+			return a.transitions > b.transitions
+		}
+		if a.typ != b.typ {
+			return a.typ.Priority() > b.typ.Priority()
+		}
+		return a.asn < b.asn
+	})
+	if len(incidents) > reportTopIncidents {
+		incidents = incidents[:reportTopIncidents]
+	}
+	for _, inc := range incidents {
+		row := newHTMLIncident(inc, len(collectors), geo, asnMapping)
+		page.Top = append(page.Top, row)
+		if len(inc.paths) > 0 {
+			page.Leaks = append(page.Leaks, leakDiagrams(inc, row, asnMapping))
+		}
+	}
+
+	names := make(map[string]struct{})
+	for _, c := range collectors {
+		names[c.name] = struct{}{}
+	}
+	for name := range r.messages {
+		names[name] = struct{}{}
+	}
+	page.Collectors = len(names)
+	for name := range names {
+		row := htmlCollector{
+			Name:        name,
+			Messages:    r.messages[name],
+			Peers:       len(r.peers[name]),
+			Transitions: r.transitions[name],
+		}
+		for _, inc := range r.incidents {
+			if _, ok := inc.collectors[name]; ok {
+				row.Incidents++
+			}
+		}
+		if len(r.incidents) > 0 {
+			row.Share = 100 * float64(row.Incidents) / float64(len(r.incidents))
+		}
+		page.Visibility = append(page.Visibility, row)
+	}
+	sort.Slice(page.Visibility, func(i, j int) bool {
+		a, b := page.Visibility[i], page.Visibility[j]
+		if a.Incidents != b.Incidents {
+			return a.Incidents > b.Incidents
+		}
+		return a.Name < b.Name
+	})
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"num": formatCount,
+		"pct": func(f float64) string { return fmt.Sprintf("%.0f%%", f) },
+	}).Parse(incidentReportHTML)
+	if err != nil {
+		return fmt.Errorf("failed to parse report template: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := tmpl.Execute(f, page); err != nil {
+		return fmt.Errorf("failed to write HTML report: %v", err)
+	}
+	log.Printf("Wrote HTML report with %d incidents to %s", len(r.incidents), path)
+	return nil
+}
+
+// timelineChart buckets the per-minute counts so the window spans about 120
+// bars.
+func (r *incidentReport) timelineChart(totals [numClassifications]int) htmlTimeline {
+	const width, height = 960.0, 48.0
+	span := r.end.Sub(r.start)
+	bucket := time.Minute
+	for _, b := range []time.Duration{time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute,
+		30 * time.Minute, time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour} {
+		bucket = b
+		if span/b <= 120 {
+			break
+		}
+	}
+	start := r.start.UTC().Truncate(bucket)
+	n := int((r.end.Sub(start) + bucket - 1) / bucket)
+	if n < 1 {
+		n = 1
+	}
+	barW := width / float64(n)
+
+	chart := htmlTimeline{Bucket: formatDuration(bucket), Width: int(width)}
+	step := int(math.Ceil(float64(n) / 6))
+	for i := 0; i < n; i += step {
+		t := start.Add(time.Duration(i) * bucket)
+		label := t.Format("15:04")
+		if i == 0 || span > 24*time.Hour {
+			label = t.Format("Jan 2 15:04")
+		}
+		chart.Ticks = append(chart.Ticks, htmlTick{X: float64(i) * barW, Label: label})
+	}
+
+	for t := bgp_pkg.ClassificationFlap; int(t) < numClassifications; t++ {
+		if totals[t] == 0 {
+			continue
+		}
+		buckets := make([]int, n)
+		for minute, counts := range r.timeline {
+			i := int(time.Unix(minute*60, 0).Sub(start) / bucket)
+			if i >= 0 && i < n {
+				buckets[i] += counts[t]
+			}
+		}
+		row := htmlTimelineRow{Name: t.String(), Color: classificationColors[t]}
+		for _, c := range buckets {
+			row.Peak = max(row.Peak, c)
+		}
+		for i, c := range buckets {
+			if c == 0 {
+				continue
+			}
+			h := math.Max(1, height*float64(c)/float64(row.Peak))
+			row.Bars = append(row.Bars, htmlBar{
+				X: float64(i) * barW, Y: height - h, W: math.Max(barW-1, 1), H: h,
+				Title: fmt.Sprintf("%s: %s", start.Add(time.Duration(i)*bucket).Format("Jan 2 15:04"), formatCount(c)),
+			})
+		}
+		chart.Rows = append(chart.Rows, row)
+	}
+	sort.SliceStable(chart.Rows, func(i, j int) bool {
+		a, _ := bgp_pkg.ParseClassificationName(chart.Rows[i].Name)
+		b, _ := bgp_pkg.ParseClassificationName(chart.Rows[j].Name)
+		return a.Priority() > b.Priority()
+	})
+	return chart
+}
+
+// worldMap draws the affected locations of every incident over a dot map of
+// the known cities, using the projection of the viewer.
+func (r *incidentReport) worldMap(geo *geoservice.GeoService) htmlMap {
+	// The outline runs up the left edge of the projection and down the right.
+	var outline strings.Builder
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for i := 0; i <= 72; i++ {
+		lat, lng := -90+float64(i)*5, -180.0
+		if i > 36 {
+			lat, lng = 90-float64(i-36)*5, 180
+		}
+		x, y := geo.Project(lat, lng)
+		minX, minY, maxX, maxY = math.Min(minX, x), math.Min(minY, y), math.Max(maxX, x), math.Max(maxY, y)
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+		}
+		fmt.Fprintf(&outline, "%s%.0f %.0f", cmd, x, y)
+	}
+	outline.WriteString("Z")
+
+	var graticule strings.Builder
+	for lng := -150.0; lng <= 150; lng += 30 {
+		for lat := -90.0; lat <= 90; lat += 5 {
+			x, y := geo.Project(lat, lng)
+			cmd := "L"
+			if lat == -90 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&graticule, "%s%.0f %.0f", cmd, x, y)
+		}
+	}
+	for lat := -60.0; lat <= 60; lat += 30 {
+		x1, y := geo.Project(lat, -180)
+		x2, _ := geo.Project(lat, 180)
+		fmt.Fprintf(&graticule, "M%.0f %.0fH%.0f", x1, y, x2)
+	}
+
+	const cell = 16.0
+	var land strings.Builder
+	seen := make(map[[2]int]struct{})
+	for _, c := range geo.GetCities() {
+		x, y := geo.Project(float64(c.Lat), float64(c.Lng))
+		k := [2]int{int(x / cell), int(y / cell)}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		fmt.Fprintf(&land, "M%.0f %.0fh%.0fv%.0fh-%.0fz", float64(k[0])*cell+2, float64(k[1])*cell+2, cell-4, cell-4, cell-4)
+	}
+
+	type location struct {
+		x, y     float64
+		place    string
+		prefixes map[string]struct{}
+		counts   [numClassifications]int
+	}
+	locations := make(map[[2]int]*location)
+	for _, inc := range r.incidents {
+		for prefix := range inc.prefixes {
+			lat, lng, cc, city, _ := geo.GetIPCoords(prefixToIP(prefix))
+			if lat == 0 && lng == 0 {
+				continue
+			}
+			x, y := geo.Project(lat, lng)
+			k := [2]int{int(x / (2 * cell)), int(y / (2 * cell))}
+			loc := locations[k]
+			if loc == nil {
+				place := cc
+				if city != "" {
+					place = city + ", " + cc
+				}
+				loc = &location{x: x, y: y, place: place, prefixes: make(map[string]struct{})}
+				locations[k] = loc
+			}
+			if _, ok := loc.prefixes[prefix]; !ok {
+				loc.prefixes[prefix] = struct{}{}
+				loc.counts[inc.typ]++
+			}
+		}
+	}
+	m := htmlMap{
+		ViewBox:   fmt.Sprintf("%.0f %.0f %.0f %.0f", minX-10, minY-10, maxX-minX+20, maxY-minY+20),
+		Outline:   outline.String(),
+		Graticule: graticule.String(),
+		Land:      land.String(),
+	}
+	for _, loc := range locations {
+		worst := bgp_pkg.ClassificationNone
+		var parts []string
+		for t := bgp_pkg.ClassificationFlap; int(t) < numClassifications; t++ {
+			if loc.counts[t] == 0 {
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s %d", t, loc.counts[t]))
+			if worst == bgp_pkg.ClassificationNone || t.Priority() > worst.Priority() ||
+				t.Priority() == worst.Priority() && loc.counts[t] > loc.counts[worst] {
+				worst = t
+			}
+		}
+		m.Spots = append(m.Spots, htmlSpot{
+			X: loc.x, Y: loc.y, R: 10 + 6*math.Sqrt(float64(len(loc.prefixes))),
+			Color: classificationColors[worst],
+			Title: fmt.Sprintf("%s: %d prefixes (%s)", orDash(loc.place), len(loc.prefixes), strings.Join(parts, ", ")),
+		})
+	}
+	// Draw large spots first so small ones stay on top
+	sort.Slice(m.Spots, func(i, j int) bool { return m.Spots[i].R > m.Spots[j].R })
+	return m
+}
+
+func newHTMLIncident(inc *incident, numCollectors int, geo *geoservice.GeoService, asnMapping *utils.ASNMapping) htmlIncident {
+	row := htmlIncident{
+		Type:        inc.typ.String(),
+		Color:       classificationColors[inc.typ],
+		Network:     reportNetwork(inc.asn, api.NetworkName(asnMapping, inc.asn)),
+		Victim:      "-",
+		LeakType:    "-",
+		Prefixes:    len(inc.prefixes),
+		Peers:       len(inc.peers),
+		First:       inc.first.UTC().Format("Jan 2 15:04:05"),
+		Last:        inc.last.UTC().Format("Jan 2 15:04:05"),
+		Duration:    formatDuration(inc.last.Sub(inc.first)),
+		Transitions: inc.transitions,
+	}
+	if inc.victim != 0 {
+		row.Victim = reportNetwork(inc.victim, api.NetworkName(asnMapping, inc.victim))
+	}
+	if inc.leakType != bgp_pkg.LeakUnknown {
+		row.LeakType = inc.leakType.String()
+	}
+
+	prefixes := make([]string, 0, len(inc.prefixes))
+	countries := make(map[string]int)
+	for prefix := range inc.prefixes {
+		prefixes = append(prefixes, prefix)
+		row.ImpactedIPs += utils.GetPrefixSize(prefix)
+		if _, _, cc, _, _ := geo.GetIPCoords(prefixToIP(prefix)); cc != "" {
+			countries[cc]++
+		}
+	}
+	sort.Strings(prefixes)
+	row.Sample = strings.Join(prefixes[:min(5, len(prefixes))], ", ")
+	if len(prefixes) > 5 {
+		row.Sample += fmt.Sprintf(" +%d more", len(prefixes)-5)
+	}
+	row.Countries = topKeys(countries, 5)
+
+	var names []string
+	for name := range inc.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	row.Collectors = fmt.Sprintf("%d of %d: %s", len(names), numCollectors, strings.Join(names, ", "))
+	return row
+}
+
+// leakDiagrams draws the most common AS paths of a route leak, from the peer
+// on the left to the origin on the right, marking the leaker and victim.
+func leakDiagrams(inc *incident, row htmlIncident, asnMapping *utils.ASNMapping) htmlLeak {
+	const nodeWidth, gap = 120, 36
+	leak := htmlLeak{Title: fmt.Sprintf("%s by %s", row.Type, row.Network)}
+	if row.Victim != "-" {
+		leak.Title += ", victim " + row.Victim
+	}
+	paths := make([]string, 0, len(inc.paths))
+	for p := range inc.paths {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if len(inc.paths[paths[i]]) != len(inc.paths[paths[j]]) {
+			return len(inc.paths[paths[i]]) > len(inc.paths[paths[j]])
+		}
+		return paths[i] < paths[j]
+	})
+	for _, p := range paths[:min(reportLeakPaths, len(paths))] {
+		var asns []string
+		for _, asn := range strings.Fields(strings.Trim(p, "[]")) {
+			if len(asns) == 0 || asns[len(asns)-1] != asn {
+				asns = append(asns, asn)
+			}
+		}
+		d := htmlPathDiagram{Peers: len(inc.paths[p]), Width: len(asns)*(nodeWidth+gap) - gap}
+		for i, asn := range asns {
+			x := i * (nodeWidth + gap)
+			node := htmlNode{X: x, ArrowFrom: x - gap + 2, Label: "AS" + asn}
+			var n uint32
+			if _, err := fmt.Sscanf(asn, "%d", &n); err == nil {
+				node.Name = truncate(api.NetworkName(asnMapping, n), 18)
+				switch n {
+				case inc.asn:
+					node.Role = "leaker"
+				case inc.victim:
+					node.Role = "victim"
+				}
+			}
+			d.Nodes = append(d.Nodes, node)
+		}
+		leak.Paths = append(leak.Paths, d)
+	}
+	return leak
+}
+
+// topKeys lists the n keys with the highest counts, with how many others
+// there were.
+func topKeys(counts map[string]int, n int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) == 0 {
+		return "-"
+	}
+	s := strings.Join(keys[:min(n, len(keys))], ", ")
+	if len(keys) > n {
+		s += fmt.Sprintf(" +%d", len(keys)-n)
+	}
+	return s
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// formatCount formats an integer with thousands separators.
+func formatCount(n any) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func formatDuration(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	return strings.TrimSuffix(s, "h0m")
+}
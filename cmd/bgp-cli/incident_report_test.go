@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+var reportStart = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// testIncidentReport records a route leak by AS64501 seen from two
+// collectors, an origin change and a few flaps.
+func testIncidentReport() *incidentReport {
+	r := newIncidentReport(reportStart, reportStart.Add(time.Hour))
+	for _, m := range []struct{ collector, peer string }{{"rrc00", "64510"}, {"rrc00", "64511"}, {"rrc01", "64512"}} {
+		r.message(&mrtfile.Message{Collector: m.collector, Peer: m.peer})
+	}
+
+	at := func(minute int, host, peer string) *bgp_pkg.MessageContext {
+		return &bgp_pkg.MessageContext{Now: reportStart.Add(time.Duration(minute) * time.Minute), Host: host, Peer: peer}
+	}
+	leak := &bgp_pkg.LeakDetail{Type: bgp_pkg.LeakPeerToProvider, LeakerASN: 64501, VictimASN: 64500}
+	leaked := &bgpproto.PrefixState{PeerLastAttrs: map[string]*bgpproto.LastAttrs{
+		"rrc00:64510": {Host: "rrc00", Path: "[64502 64501 64500]"},
+		"rrc00:64511": {Host: "rrc00", Path: "[64511 64501 64501 64500]"},
+		"rrc01:64512": {Host: "rrc01", Path: "[64502 64501 64500]"},
+		"rrc01:64513": {Host: "rrc01", Path: "[64513 64500]"},
+	}}
+	for i, prefix := range []string{"10.0.0.0/24", "10.0.1.0/24"} {
+		ev := bgp_pkg.PendingEvent{Prefix: prefix, ASN: 64500, ClassificationType: bgp_pkg.ClassificationRouteLeak, LeakDetail: leak}
+		r.classified(ev, leaked, at(5+i*20, "rrc00", "64510"))
+	}
+	hijack := bgp_pkg.PendingEvent{Prefix: "10.9.0.0/16", ASN: 64666, HistoricalASN: 64600, ClassificationType: bgp_pkg.ClassificationHijack}
+	r.classified(hijack, &bgpproto.PrefixState{}, at(30, "rrc01", "64512"))
+	for i := 0; i < 3; i++ {
+		flap := bgp_pkg.PendingEvent{Prefix: "10.5.0.0/24", ASN: 64505, ClassificationType: bgp_pkg.ClassificationFlap}
+		r.classified(flap, &bgpproto.PrefixState{}, at(40+i, "rrc01", "64512"))
+	}
+	return r
+}
+
+func TestIncidentReport_Write(t *testing.T) {
+	r := testIncidentReport()
+	asns := utils.NewASNMapping()
+	utils.SetASNName(asns, 64500, "VICTIM-NET")
+	utils.SetASNName(asns, 64501, "LEAKER-NET")
+	collectors := []mrtCollector{{name: "rrc00"}, {name: "rrc01"}}
+
+	path := filepath.Join(t.TempDir(), "report.html")
+	if err := r.write(path, collectors, geoservice.NewGeoService(3840, 2160, 760.0), asns); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+
+	leak := bgp_pkg.ClassificationRouteLeak.String()
+	for _, want := range []string{
+		"<title>BGP incident report 2024-03-01 10:00 UTC to 2024-03-01 11:00 UTC</title>",
+		"<b>3</b>messages", "<b>2</b>collectors", "<b>6</b>classification changes", "<b>2</b>incidents",
+		// Counts by classification
+		bgp_pkg.ClassificationFlap.String() + `</td><td class="n">3</td><td class="n">1</td>`,
+		// The leak is listed before the single-prefix hijack
+		leak + `<div class="small">Peer to Provider</div>`,
+		`AS64501 (LEAKER-NET)<div class="small">victim AS64500 (VICTIM-NET)</div>`,
+		`10.0.0.0/24, 10.0.1.0/24`,
+		`<td class="n">512</td>`,
+		`4 peers<div class="small">2 of 2: rrc00, rrc01</div>`,
+		`Mar 1 10:05:00<div class="small">to Mar 1 10:25:00 (20m), 2 changes</div>`,
+		`AS64666<div class="small">victim AS64600</div>`,
+		// Leaked paths, most common first, with repeated ASNs collapsed
+		"<h3>" + leak + " by AS64501 (LEAKER-NET), victim AS64500 (VICTIM-NET)</h3>",
+		"carried by 2 peers",
+		"carried by 1 peers",
+		`<g class="leaker">`,
+		`<g class="victim">`,
+		// Collectors by the incidents they saw
+		`<td>rrc01</td><td class="n">1</td><td class="n">1</td><td class="n">4</td>`,
+		`<td class="n">2 (100%)</td>`,
+		`<td>rrc00</td><td class="n">2</td><td class="n">2</td><td class="n">2</td>`,
+		`<td class="n">1 (50%)</td>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+	if strings.Index(html, "LEAKER-NET") > strings.Index(html, "AS64666") {
+		t.Error("expected the leak to be listed before the hijack")
+	}
+	if strings.Contains(html, "AS64513") {
+		t.Error("expected paths that do not contain the leaker to be left out")
+	}
+}
+
+func TestIncidentReport_SnapshotRestore(t *testing.T) {
+	r := testIncidentReport()
+	data, err := json.Marshal(r.snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved incidentReportSnapshot
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newIncidentReport(r.start, r.end)
+	restored.restore(&saved)
+
+	// Incidents are snapshotted in map order
+	encode := func(r *incidentReport) string {
+		s := r.snapshot()
+		slices.SortFunc(s.Incidents, func(a, b incidentSnapshot) int { return int(a.ASN) - int(b.ASN) })
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got, want := encode(restored), encode(r); got != want {
+		t.Errorf("restored report differs:\n got %s\nwant %s", got, want)
+	}
+
+	// The restored report keeps collecting
+	restored.message(&mrtfile.Message{Collector: "rrc02", Peer: "64520"})
+	restored.classified(bgp_pkg.PendingEvent{Prefix: "10.0.2.0/24", ClassificationType: bgp_pkg.ClassificationRouteLeak,
+		LeakDetail: &bgp_pkg.LeakDetail{LeakerASN: 64501}}, &bgpproto.PrefixState{}, &bgp_pkg.MessageContext{Now: reportStart.Add(50 * time.Minute), Host: "rrc02", Peer: "64520"})
+	inc := restored.incidents[incidentKey{typ: bgp_pkg.ClassificationRouteLeak, asn: 64501}]
+	if inc == nil || len(inc.prefixes) != 3 || inc.transitions != 3 || inc.victim != 64500 || len(inc.paths) != 2 {
+		t.Errorf("expected the restored leak to be extended, got %+v", inc)
+	}
+	if restored.messages["rrc02"] != 1 || restored.transitions["rrc02"] != 1 {
+		t.Errorf("expected the new collector to be counted, got %v and %v", restored.messages, restored.transitions)
+	}
+}
@@ -0,0 +1,638 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+type LookupCmd struct {
+	Query   string   `arg:"" help:"ASN (AS13335 or 13335), prefix (1.1.1.0/24) or IPv4 address to look up."`
+	Format  string   `default:"text" enum:"text,json" help:"Output format (text, json)."`
+	StateDB string   `default:"./data/prefix-state.db" help:"Prefix state database for current classifications (empty to disable)."`
+	SeenDB  string   `default:"./data/seen-prefixes.db" help:"Seen prefixes database for origins (empty to disable)."`
+	RPKIDB  string   `name:"rpki-db" default:"./data/rpki-vrps.db" help:"VRP database kept by the viewer or bgp-cli run (empty to disable)."`
+	MMDB    []string `name:"mmdb" help:"Path to an additional .mmdb file for geolocation (can be specified multiple times)."`
+	Limit   int      `default:"100" help:"Show at most this many prefixes per list (0 for no limit)."`
+}
+
+// lookupNetwork is an ASN with its name.
+type lookupNetwork struct {
+	ASN  uint32 `json:"asn"`
+	Name string `json:"name,omitempty"`
+}
+
+// lookupRoute is a prefix from the seen prefixes database.
+type lookupRoute struct {
+	Prefix string         `json:"prefix"`
+	Origin *lookupNetwork `json:"origin,omitempty"`
+}
+
+// lookupAnomaly is a classified prefix an ASN takes part in.
+type lookupAnomaly struct {
+	*api.PrefixInfo
+	Role string `json:"role"`
+}
+
+type asnLookup struct {
+	lookupNetwork
+	CC            string           `json:"cc,omitempty"`
+	OrgID         string           `json:"org_id,omitempty"`
+	Roles         bgp.NetworkRoles `json:"roles"`
+	Siblings      []lookupNetwork  `json:"siblings"`
+	Prefixes      []string         `json:"prefixes"`
+	TotalPrefixes int              `json:"total_prefixes"`
+	Anomalies     []lookupAnomaly  `json:"anomalies"`
+}
+
+type prefixLookup struct {
+	Prefix string `json:"prefix"`
+	// HistoricalOrigin is the origin in the seen prefixes database of the
+	// prefix or, failing that, of its longest covering prefix
+	HistoricalOrigin   *lookupNetwork              `json:"historical_origin,omitempty"`
+	HistoricalFrom     string                      `json:"historical_from,omitempty"`
+	Covering           []lookupRoute               `json:"covering"`
+	MoreSpecifics      []lookupRoute               `json:"more_specifics"`
+	TotalMoreSpecifics int                         `json:"total_more_specifics"`
+	VRPs               []utils.VRP                 `json:"vrps"`
+	RPKI               string                      `json:"rpki,omitempty"`
+	RPKIOrigin         uint32                      `json:"rpki_origin,omitempty"`
+	Geo                []geoservice.StageResult    `json:"geo"`
+	State              *api.PrefixInfo             `json:"state,omitempty"`
+	Evidence           *journal.Evidence           `json:"evidence,omitempty"`
+	PeerRoutes         map[string]*lookupPeerRoute `json:"peer_routes,omitempty"`
+}
+
+// lookupPeerRoute is the last route of a peer in a prefix state.
+type lookupPeerRoute struct {
+	Path        string    `json:"path,omitempty"`
+	NextHop     string    `json:"next_hop,omitempty"`
+	Communities string    `json:"communities,omitempty"`
+	Withdrawn   bool      `json:"withdrawn"`
+	LastUpdate  time.Time `json:"last_update"`
+}
+
+// lookupData holds the local databases a lookup reads. Any of them may be nil.
+type lookupData struct {
+	states     *utils.DiskTrie
+	seen       *utils.DiskTrie
+	rpki       *utils.RPKIManager
+	asnMapping *utils.ASNMapping
+	classifier *bgp.Classifier
+}
+
+func (c *LookupCmd) Run() error {
+	query := strings.TrimSpace(c.Query)
+	asn, isASN := parseLookupASN(query)
+	var prefix netip.Prefix
+	if !isASN {
+		var err error
+		if prefix, err = parseLookupPrefix(query); err != nil {
+			return fmt.Errorf("%q is neither an ASN, a prefix nor an IPv4 address", c.Query)
+		}
+	}
+
+	d := &lookupData{asnMapping: utils.NewASNMapping()}
+	if err := d.asnMapping.Load(); err != nil {
+		log.Printf("Warning: failed to load ASN names: %v", err)
+	}
+	d.classifier = bgp.NewClassifier(nil, nil, d.asnMapping, nil, prefixToIP, nil, time.Now)
+	if c.StateDB != "" {
+		if db, err := utils.OpenDiskTrieReadOnly(c.StateDB); err != nil {
+			log.Printf("Warning: current classifications unavailable, failed to open prefix state database: %v", err)
+		} else {
+			d.states = db
+			defer func() { _ = db.Close() }()
+		}
+	}
+	if c.SeenDB != "" {
+		if db, err := utils.OpenDiskTrieReadOnly(c.SeenDB); err != nil {
+			log.Printf("Warning: origins unavailable, failed to open seen prefixes database: %v", err)
+		} else {
+			d.seen = db
+			defer func() { _ = db.Close() }()
+		}
+	}
+
+	if isASN {
+		res, err := c.lookupASN(d, asn)
+		if err != nil {
+			return err
+		}
+		if c.Format == "json" {
+			return writeLookupJSON(res)
+		}
+		return c.writeASN(res)
+	}
+
+	if c.RPKIDB != "" {
+		if m, err := utils.NewRPKIManagerReadOnly(c.RPKIDB); err != nil {
+			log.Printf("Warning: RPKI data unavailable, failed to open VRP database: %v", err)
+		} else {
+			d.rpki = m
+			defer func() { _ = m.Close() }()
+		}
+	}
+	geo := openDebugGeo(c.MMDB)
+	defer func() { _ = geo.Close() }()
+	res, err := c.lookupPrefix(d, geo, prefix)
+	if err != nil {
+		return err
+	}
+	if c.Format == "json" {
+		return writeLookupJSON(res)
+	}
+	return c.writePrefix(res)
+}
+
+// parseLookupASN parses an ASN with or without the AS prefix.
+func parseLookupASN(s string) (uint32, bool) {
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		s = s[2:]
+	}
+	asn, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || asn == 0 {
+		return 0, false
+	}
+	return uint32(asn), true
+}
+
+// parseLookupPrefix parses an IPv4 prefix, or an address as a host route.
+func parseLookupPrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		s = addr.String() + "/32"
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if !p.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("only IPv4 is supported")
+	}
+	return p.Masked(), nil
+}
+
+// keyPrefix returns the prefix of a DiskTrie key, or false for other keys.
+func keyPrefix(k []byte) (string, bool) {
+	if len(k) != 5 {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%d", net.IP(k[:4]).String(), k[4]), true
+}
+
+func (d *lookupData) network(asn uint32) *lookupNetwork {
+	if asn == 0 {
+		return nil
+	}
+	return &lookupNetwork{ASN: asn, Name: api.NetworkName(d.asnMapping, asn)}
+}
+
+// origin returns the origin stored for exactly prefix in the seen prefixes
+// database.
+func (d *lookupData) origin(prefix string) (uint32, bool) {
+	if d.seen == nil {
+		return 0, false
+	}
+	val, err := d.seen.Get(prefix)
+	if err != nil || len(val) < 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(val), true
+}
+
+func (c *LookupCmd) lookupASN(d *lookupData, asn uint32) (*asnLookup, error) {
+	res := &asnLookup{
+		lookupNetwork: *d.network(asn),
+		CC:            d.asnMapping.GetCC(asn),
+		OrgID:         d.asnMapping.GetOrgID(asn),
+		Roles:         d.classifier.NetworkRoles(asn),
+		Siblings:      []lookupNetwork{},
+		Prefixes:      []string{},
+		Anomalies:     []lookupAnomaly{},
+	}
+	for _, sibling := range d.classifier.Siblings(asn) {
+		res.Siblings = append(res.Siblings, *d.network(sibling))
+	}
+
+	if d.seen != nil {
+		var prefixes []netip.Prefix
+		err := d.seen.ForEach(func(k, v []byte) error {
+			prefix, ok := keyPrefix(k)
+			if !ok || len(v) < 4 || binary.BigEndian.Uint32(v) != asn {
+				return nil
+			}
+			if p, err := netip.ParsePrefix(prefix); err == nil {
+				prefixes = append(prefixes, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error iterating through seen prefixes: %v", err)
+		}
+		sortPrefixes(prefixes)
+		res.TotalPrefixes = len(prefixes)
+		for _, p := range limitList(prefixes, c.Limit) {
+			res.Prefixes = append(res.Prefixes, p.String())
+		}
+	}
+
+	if d.states != nil {
+		err := d.states.ForEach(func(k, v []byte) error {
+			prefix, ok := keyPrefix(k)
+			if !ok {
+				return nil
+			}
+			state := &bgpproto.PrefixState{}
+			if err := proto.Unmarshal(v, state); err != nil || state.ClassifiedType == int32(bgp.ClassificationNone) {
+				return nil
+			}
+			var role string
+			switch asn {
+			case state.LastOriginAsn:
+				role = "origin"
+			case state.LeakerAsn:
+				role = "leaker"
+			case state.VictimAsn:
+				role = "victim"
+			default:
+				return nil
+			}
+			res.Anomalies = append(res.Anomalies, lookupAnomaly{PrefixInfo: api.NewPrefixInfo(prefix, state), Role: role})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error iterating through prefix states: %v", err)
+		}
+		sort.SliceStable(res.Anomalies, func(i, j int) bool {
+			return res.Anomalies[i].ClassifiedSince.After(res.Anomalies[j].ClassifiedSince)
+		})
+	}
+	return res, nil
+}
+
+func (c *LookupCmd) lookupPrefix(d *lookupData, geo *geoservice.GeoService, p netip.Prefix) (*prefixLookup, error) {
+	prefix := p.String()
+	res := &prefixLookup{
+		Prefix:        prefix,
+		Covering:      []lookupRoute{},
+		MoreSpecifics: []lookupRoute{},
+		VRPs:          []utils.VRP{},
+		Geo:           geo.ResolveStages(prefixToIP(prefix)),
+	}
+
+	// Covering prefixes, most specific first, and the historical origin the
+	// classifier would use
+	for bits := p.Bits(); bits >= 0; bits-- {
+		covering := netip.PrefixFrom(p.Addr(), bits).Masked().String()
+		asn, ok := d.origin(covering)
+		if !ok {
+			continue
+		}
+		if res.HistoricalOrigin == nil {
+			res.HistoricalOrigin, res.HistoricalFrom = d.network(asn), covering
+		}
+		if bits < p.Bits() {
+			res.Covering = append(res.Covering, lookupRoute{Prefix: covering, Origin: d.network(asn)})
+		}
+	}
+	if d.seen != nil {
+		var more []netip.Prefix
+		origins := make(map[netip.Prefix]uint32)
+		err := d.seen.ForEach(func(k, v []byte) error {
+			s, ok := keyPrefix(k)
+			if !ok {
+				return nil
+			}
+			q, err := netip.ParsePrefix(s)
+			if err != nil || q.Bits() <= p.Bits() || !p.Contains(q.Addr()) {
+				return nil
+			}
+			more = append(more, q)
+			if len(v) >= 4 {
+				origins[q] = binary.BigEndian.Uint32(v)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error iterating through seen prefixes: %v", err)
+		}
+		sortPrefixes(more)
+		res.TotalMoreSpecifics = len(more)
+		for _, q := range limitList(more, c.Limit) {
+			res.MoreSpecifics = append(res.MoreSpecifics, lookupRoute{Prefix: q.String(), Origin: d.network(origins[q])})
+		}
+	}
+
+	if d.states != nil {
+		if val, err := d.states.Get(prefix); err != nil {
+			log.Printf("Warning: failed to read prefix state: %v", err)
+		} else if val != nil {
+			state := &bgpproto.PrefixState{}
+			if err := proto.Unmarshal(val, state); err != nil {
+				log.Printf("Warning: failed to decode prefix state: %v", err)
+			} else {
+				res.State = api.NewPrefixInfo(prefix, state)
+				evidence := bgp.StateEvidence(state)
+				e := journal.NewEvidence(&evidence)
+				res.Evidence = &e
+				res.PeerRoutes = make(map[string]*lookupPeerRoute, len(state.PeerLastAttrs))
+				for peer, attrs := range state.PeerLastAttrs {
+					res.PeerRoutes[peer] = &lookupPeerRoute{
+						Path:        attrs.Path,
+						NextHop:     attrs.NextHop,
+						Communities: attrs.Communities,
+						Withdrawn:   attrs.Withdrawn,
+						LastUpdate:  time.Unix(attrs.LastUpdateTs, 0).UTC(),
+					}
+				}
+			}
+		}
+	}
+
+	if d.rpki != nil {
+		vrps, err := d.rpki.CoveringVRPs(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up VRPs: %v", err)
+		}
+		res.VRPs = append(res.VRPs, vrps...)
+		// Validate the origin announcing the prefix now, or else the one it had
+		switch {
+		case res.State != nil && res.State.OriginASN != 0:
+			res.RPKIOrigin = res.State.OriginASN
+		case res.HistoricalOrigin != nil:
+			res.RPKIOrigin = res.HistoricalOrigin.ASN
+		}
+		if res.RPKIOrigin != 0 {
+			status, err := d.rpki.Validate(prefix, res.RPKIOrigin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to validate origin: %v", err)
+			}
+			res.RPKI = status.String()
+		}
+	}
+	return res, nil
+}
+
+func sortPrefixes(prefixes []netip.Prefix) {
+	sort.Slice(prefixes, func(i, j int) bool {
+		if n := prefixes[i].Addr().Compare(prefixes[j].Addr()); n != 0 {
+			return n < 0
+		}
+		return prefixes[i].Bits() < prefixes[j].Bits()
+	})
+}
+
+func limitList[T any](list []T, limit int) []T {
+	if limit > 0 && len(list) > limit {
+		return list[:limit]
+	}
+	return list
+}
+
+func writeLookupJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (n *lookupNetwork) String() string {
+	if n == nil {
+		return "-"
+	}
+	if n.Name == "" {
+		return fmt.Sprintf("AS%d", n.ASN)
+	}
+	return fmt.Sprintf("AS%d (%s)", n.ASN, n.Name)
+}
+
+// more returns a note on how many entries of a list were left out.
+func more(shown, total int) string {
+	if total > shown {
+		return fmt.Sprintf(" (showing %d of %d)", shown, total)
+	}
+	return ""
+}
+
+func (c *LookupCmd) writeASN(res *asnLookup) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var roles []string
+	if res.Roles.Tier1 {
+		roles = append(roles, "Tier-1")
+	}
+	if res.Roles.LargeNetwork {
+		roles = append(roles, "large network")
+	}
+	if res.Roles.Cloud {
+		roles = append(roles, "cloud/CDN")
+	}
+	if res.Roles.DDoSProvider {
+		roles = append(roles, "DDoS scrubber or large provider")
+	}
+	siblings := make([]string, 0, len(res.Siblings))
+	for _, s := range res.Siblings {
+		siblings = append(siblings, s.String())
+	}
+	lines := [][2]string{
+		{"ASN", res.lookupNetwork.String()},
+		{"Country", orDash(res.CC)},
+		{"Organization", orDash(res.OrgID)},
+		{"Roles", orDash(strings.Join(roles, ", "))},
+		{"Siblings", orDash(strings.Join(siblings, ", "))},
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(w, "%s:\t%s\n", l[0], l[1]); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nOriginated prefixes: %d%s\n", res.TotalPrefixes, more(len(res.Prefixes), res.TotalPrefixes))
+	for _, p := range res.Prefixes {
+		fmt.Printf("  %s\n", p)
+	}
+
+	fmt.Printf("\nCurrent anomalies: %d\n", len(res.Anomalies))
+	if len(res.Anomalies) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintln(w, "  PREFIX\tCLASSIFICATION\tROLE\tSINCE\tLAST UPDATE"); err != nil {
+		return err
+	}
+	for _, a := range res.Anomalies {
+		if _, err := fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", a.Prefix, a.Name, a.Role, formatLookupTime(a.ClassifiedSince), formatLookupTime(a.LastUpdate)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func (c *LookupCmd) writePrefix(res *prefixLookup) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	historical := res.HistoricalOrigin.String()
+	if res.HistoricalOrigin != nil && res.HistoricalFrom != res.Prefix {
+		historical += " from " + res.HistoricalFrom
+	}
+	rpki := "-"
+	if res.RPKI != "" {
+		rpki = fmt.Sprintf("%s for AS%d", res.RPKI, res.RPKIOrigin)
+	}
+	if _, err := fmt.Fprintf(w, "Prefix:\t%s\nHistorical origin:\t%s\nRPKI:\t%s\n", res.Prefix, historical, rpki); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nVRPs: %d\n", len(res.VRPs))
+	for _, v := range res.VRPs {
+		fmt.Printf("  %s max /%d AS%d\n", v.Prefix, v.MaxLength, v.ASN)
+	}
+
+	fmt.Printf("\nCovering prefixes: %d\n", len(res.Covering))
+	if err := writeLookupRoutes(w, res.Covering); err != nil {
+		return err
+	}
+	fmt.Printf("\nMore-specific prefixes: %d%s\n", res.TotalMoreSpecifics, more(len(res.MoreSpecifics), res.TotalMoreSpecifics))
+	if err := writeLookupRoutes(w, res.MoreSpecifics); err != nil {
+		return err
+	}
+
+	fmt.Println("\nGeolocation:")
+	if _, err := fmt.Fprintln(w, "  STAGE\tRESULT\tCOUNTRY\tCITY\tCOORDS"); err != nil {
+		return err
+	}
+	resolved := false
+	for i, g := range res.Geo {
+		stage := string(g.ResType)
+		if i == len(res.Geo)-1 {
+			stage = "Fallback"
+		}
+		result := "-"
+		switch {
+		case g.OK && !resolved:
+			result, resolved = "used ("+string(g.ResType)+")", true
+		case g.OK:
+			result = "found"
+		case g.CC != "" || g.City != "":
+			result = "no coords"
+		}
+		coords := "-"
+		if g.Lat != 0 || g.Lng != 0 {
+			coords = fmt.Sprintf("%.4f, %.4f", g.Lat, g.Lng)
+		}
+		if _, err := fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", stage, result, orDash(g.CC), orDash(g.City), coords); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nState:")
+	if res.State == nil {
+		fmt.Println("  not in the prefix state database")
+		return nil
+	}
+	s := res.State
+	name := orDash(s.Name)
+	if !s.ClassifiedSince.IsZero() {
+		name += " since " + formatLookupTime(s.ClassifiedSince)
+	}
+	leak := "-"
+	if s.Leak != nil {
+		leak = fmt.Sprintf("%s, leaker AS%d, victim AS%d", orDash(s.Leak.Type), s.Leak.LeakerASN, s.Leak.VictimASN)
+	}
+	score := "baseline not established"
+	if s.AnomalyScore != nil {
+		score = fmt.Sprintf("%.1f", *s.AnomalyScore)
+	}
+	visibility := "-"
+	if s.Visibility != nil {
+		visibility = fmt.Sprintf("%.0f%% (peak %.0f%%)", *s.Visibility*100, *s.PeakVisibility*100)
+	}
+	e := res.Evidence
+	lines := [][2]string{
+		{"Classification", name},
+		{"Origin", fmt.Sprintf("AS%d", s.OriginASN)},
+		{"RPKI at last update", orDash(s.RPKI)},
+		{"Leak", leak},
+		{"Bogon reason", orDash(s.BogonReason)},
+		{"First seen", formatLookupTime(s.FirstSeen)},
+		{"Last update", formatLookupTime(s.LastUpdate)},
+		{"Anomaly score", score},
+		{"Visibility", visibility},
+		{"Window", fmt.Sprintf("%d msgs (%d ann, %d with), %d path changes", e.Messages, e.Announcements, e.Withdrawals, e.PathChanges)},
+		{"Peers", fmt.Sprintf("%d peers/%d hosts on the origin, %d withdrawn peers/%d hosts, %d origins", e.Peers, e.Hosts, e.WithdrawnPeers, e.WithdrawnHosts, e.Origins)},
+	}
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(w, "  %s:\t%s\n", l[0], l[1]); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(res.PeerRoutes) == 0 {
+		return nil
+	}
+	peers := make([]string, 0, len(res.PeerRoutes))
+	for peer := range res.PeerRoutes {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	fmt.Println("\nPeer routes:")
+	if _, err := fmt.Fprintln(w, "  PEER\tPATH\tNEXT HOP\tCOMMUNITIES\tLAST UPDATE"); err != nil {
+		return err
+	}
+	for _, peer := range peers {
+		r := res.PeerRoutes[peer]
+		path := r.Path
+		if r.Withdrawn {
+			path = "withdrawn"
+		}
+		if _, err := fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", peer, orDash(path), orDash(r.NextHop), orDash(r.Communities), formatLookupTime(r.LastUpdate)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func writeLookupRoutes(w *tabwriter.Writer, routes []lookupRoute) error {
+	for _, r := range routes {
+		if _, err := fmt.Fprintf(w, "  %s\t%s\n", r.Prefix, r.Origin.String()); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func formatLookupTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
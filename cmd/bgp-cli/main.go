@@ -8,8 +8,16 @@ type CLI struct {
 	Fetch       FetchCmd       `cmd:"" help:"Download and process required data for the engine."`
 	Report      ReportCmd      `cmd:"" help:"Generate a report of current BGP prefixes in specific states."`
 	Analyze     AnalyzeCmd     `cmd:"" help:"Analyze MRT files and generate a state transition report."`
+	Eval        EvalCmd        `cmd:"" help:"Score the classifier against a catalogue of labeled incidents."`
+	Simulate    SimulateCmd    `cmd:"" help:"Generate a synthetic BGP update stream from a scenario, as RIS-JSON, MRT or classified events."`
 	DebugGeo    DebugGeoCmd    `cmd:"" help:"Debug geolocation lookups for an IP address."`
 	DebugPrefix DebugPrefixCmd `cmd:"" help:"Watch a specific BGP prefix stream for debugging."`
+	Lookup      LookupCmd      `cmd:"" help:"Show what the local data knows about an ASN or a prefix."`
+	DB          DBCmd          `cmd:"" name:"db" help:"Maintain the local prefix databases."`
+	Events      EventsCmd      `cmd:"" help:"Query the journal of classification transitions."`
+	Metrics     MetricsCmd     `cmd:"" help:"Work with the recorded metric history."`
+	Run         RunCmd         `cmd:"" help:"Process the live stream headless, feeding the journal, alerts, SIEM sinks and optional APIs."`
+	Serve       ServeCmd       `cmd:"" help:"Process the live stream headless and serve the read-only JSON API."`
 }
 
 func main() {
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alecthomas/kong"
+)
+
+// Commands whose flags are all optional must parse without any.
+func TestCLI_ParseWithoutFlags(t *testing.T) {
+	for _, args := range [][]string{{"run"}, {"serve"}} {
+		var cli CLI
+		parser, err := kong.New(&cli, kong.Name("bgp-cli"))
+		if err != nil {
+			t.Fatalf("kong.New() error = %v", err)
+		}
+		ctx, err := parser.Parse(args)
+		if err != nil {
+			t.Errorf("Parse(%v) error = %v", args, err)
+			continue
+		}
+		if ctx.Command() != args[0] {
+			t.Errorf("Parse(%v) selected %q", args, ctx.Command())
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/tsdb"
+)
+
+type MetricsCmd struct {
+	Export MetricsExportCmd `cmd:"" help:"Export recorded metric history. The viewer must not be running."`
+}
+
+type MetricsExportCmd struct {
+	DB         string        `name:"db" default:"./data/metrics.db" help:"Path to the metric history database."`
+	Resolution string        `default:"1m" enum:"2s,1m,1h" help:"Bucket size to export (2s is kept for an hour, 1m for a week, 1h for a year)."`
+	Since      string        `default:"" help:"Only export buckets at or after this time (YYYY-MM-DD HH:mm, UTC)."`
+	Until      string        `default:"" help:"Only export buckets before this time (YYYY-MM-DD HH:mm, UTC)."`
+	Last       time.Duration `default:"0" help:"Only export buckets from this long ago until now (e.g. 24h). Overrides --since."`
+	Fields     []string      `sep:"," help:"Only export these fields (defaults to all)."`
+	Rate       bool          `help:"Export counters as per-second rates instead of totals per bucket."`
+	Format     string        `default:"csv" enum:"csv,jsonl" help:"Output format (csv, jsonl)."`
+}
+
+func (c *MetricsExportCmd) Run() error {
+	res, _ := tsdb.ParseResolution(c.Resolution)
+	from, to := time.Now().Add(-res.Retention), time.Now().Add(res.Step)
+	var err error
+	if c.Since != "" {
+		if from, err = time.Parse("2006-01-02 15:04", c.Since); err != nil {
+			return fmt.Errorf("invalid since time: %v", err)
+		}
+	}
+	if c.Last > 0 {
+		from = time.Now().Add(-c.Last)
+	}
+	if c.Until != "" {
+		if to, err = time.Parse("2006-01-02 15:04", c.Until); err != nil {
+			return fmt.Errorf("invalid until time: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(c.DB); err != nil {
+		return fmt.Errorf("failed to open metric history: %v", err)
+	}
+	store, err := tsdb.OpenReadOnly(c.DB)
+	if err != nil {
+		return fmt.Errorf("failed to open metric history: %v", err)
+	}
+	defer func() { _ = store.Close() }()
+
+	fields := store.Fields()
+	columns, err := metricColumns(fields, c.Fields)
+	if err != nil {
+		return err
+	}
+	samples, err := store.Range(res, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to read metric history: %v", err)
+	}
+
+	value := func(s *tsdb.Sample, i int) float64 {
+		if c.Rate && fields[i].Kind == tsdb.Counter {
+			return s.Rate(i)
+		}
+		return s.Values[i]
+	}
+
+	switch c.Format {
+	case "jsonl":
+		enc := json.NewEncoder(os.Stdout)
+		for i := range samples {
+			s := &samples[i]
+			row := map[string]any{"time": s.Time.UTC().Format(time.RFC3339), "seconds": s.Seconds}
+			for _, col := range columns {
+				row[fields[col].Name] = value(s, col)
+			}
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		w := csv.NewWriter(os.Stdout)
+		header := []string{"time", "seconds"}
+		for _, col := range columns {
+			header = append(header, fields[col].Name)
+		}
+		if err := w.Write(header); err != nil {
+			return err
+		}
+		for i := range samples {
+			s := &samples[i]
+			row := []string{s.Time.UTC().Format(time.RFC3339), strconv.FormatFloat(s.Seconds, 'f', -1, 64)}
+			for _, col := range columns {
+				row = append(row, strconv.FormatFloat(value(s, col), 'f', -1, 64))
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+}
+
+// metricColumns returns the indexes of the requested fields, or of every field
+// if none were requested.
+func metricColumns(fields []tsdb.Field, names []string) ([]int, error) {
+	if len(names) == 0 {
+		columns := make([]int, len(fields))
+		for i := range fields {
+			columns[i] = i
+		}
+		return columns, nil
+	}
+	columns := make([]int, 0, len(names))
+	for _, name := range names {
+		found := false
+		for i, f := range fields {
+			if f.Name == name {
+				columns = append(columns, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown metric field %q", name)
+		}
+	}
+	return columns, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mrtCollector is where the MRT files of one collector come from. Its name is
+// the host recorded with every message, so the same collector is named the
+// same way whichever archive it is read from.
+type mrtCollector struct {
+	name    string
+	archive mrtArchive
+}
+
+// mrtArchive lists the MRT files of a collector. Locations are URLs or local
+// paths.
+type mrtArchive interface {
+	// updates returns the update files covering [start, end), oldest first.
+	updates(start, end time.Time) ([]string, error)
+	// rib returns the last RIB dump taken at or before t.
+	rib(t time.Time) (string, error)
+}
+
+// risCollectors are the RIS route collectors analyzed by default.
+func risCollectors() []string {
+	var rrcs []string
+	for i := 0; i <= 26; i++ {
+		rrcs = append(rrcs, fmt.Sprintf("rrc%02d", i))
+	}
+	return rrcs
+}
+
+var risCollector = regexp.MustCompile(`^rrc[0-9]+$`)
+
+// parseCollector parses a [name=]source collector flag. The source is a RIS
+// collector (rrc00), a RouteViews collector (route-views2,
+// route-views.amsix) or a local directory or glob of MRT files. Local
+// collectors are named after their directory unless a name is given.
+func parseCollector(spec string) (mrtCollector, error) {
+	name, source, named := strings.Cut(spec, "=")
+	if !named {
+		source = name
+	}
+	switch {
+	case risCollector.MatchString(source):
+		return mrtCollector{name: source, archive: risArchive{collector: source}}, nil
+	case strings.HasPrefix(source, "route-views"):
+		return mrtCollector{name: source, archive: routeViewsArchive{collector: source}}, nil
+	}
+	if _, err := os.Stat(source); err != nil && !strings.ContainsAny(source, "*?[") {
+		return mrtCollector{}, fmt.Errorf("unknown collector %q: not a RIS or RouteViews collector, directory or glob", source)
+	}
+	if !named {
+		name = filepath.Base(strings.TrimRight(source, string(filepath.Separator)))
+		if strings.ContainsAny(name, "*?[") {
+			name = filepath.Base(filepath.Dir(source))
+		}
+	}
+	return mrtCollector{name: name, archive: localArchive{pattern: source}}, nil
+}
+
+func parseCollectors(list string) ([]mrtCollector, error) {
+	specs := risCollectors()
+	if list != "" {
+		specs = strings.Split(list, ",")
+	}
+	var collectors []mrtCollector
+	for _, spec := range specs {
+		c, err := parseCollector(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		collectors = append(collectors, c)
+	}
+	return collectors, nil
+}
+
+// fetch returns a local path for location, downloading URLs into the
+// collector's directory under cacheDir.
+func (c mrtCollector) fetch(location, cacheDir string) (string, error) {
+	if !strings.HasPrefix(location, "https://") && !strings.HasPrefix(location, "http://") {
+		return location, nil
+	}
+	localPath := filepath.Join(cacheDir, c.name, filepath.Base(location))
+	return localPath, downloadFile(location, localPath)
+}
+
+// archiveFiles returns the files named name.YYYYMMDD.HHMM.ext every interval
+// from start until end, with dir formatting the directory of a file's time.
+func archiveFiles(start, end time.Time, interval time.Duration, dir func(t time.Time) string, name, ext string) []string {
+	var files []string
+	for t := start.UTC().Truncate(interval); t.Before(end); t = t.Add(interval) {
+		files = append(files, dir(t)+name+"."+t.Format("20060102.1504")+ext)
+	}
+	return files
+}
+
+// risArchive is a RIPE RIS collector: updates every 5 minutes and a bview
+// every 8 hours.
+type risArchive struct {
+	collector string
+}
+
+func (a risArchive) dir(t time.Time) string {
+	return fmt.Sprintf("https://data.ris.ripe.net/%s/%s/", a.collector, t.Format("2006.01"))
+}
+
+func (a risArchive) updates(start, end time.Time) ([]string, error) {
+	return archiveFiles(start, end, 5*time.Minute, a.dir, "updates", ".gz"), nil
+}
+
+func (a risArchive) rib(t time.Time) (string, error) {
+	t = t.UTC().Truncate(8 * time.Hour)
+	return a.dir(t) + "bview." + t.Format("20060102.1504") + ".gz", nil
+}
+
+// routeViewsArchive is a RouteViews collector: bzip2 updates every 15
+// minutes and a RIB every 2 hours. route-views2 is archived at the top level,
+// the others under their own name.
+type routeViewsArchive struct {
+	collector string
+}
+
+func (a routeViewsArchive) dir(kind string) func(t time.Time) string {
+	base := "https://archive.routeviews.org/"
+	if a.collector != "route-views2" {
+		base += a.collector + "/"
+	}
+	return func(t time.Time) string {
+		return base + "bgpdata/" + t.Format("2006.01") + "/" + kind + "/"
+	}
+}
+
+func (a routeViewsArchive) updates(start, end time.Time) ([]string, error) {
+	return archiveFiles(start, end, 15*time.Minute, a.dir("UPDATES"), "updates", ".bz2"), nil
+}
+
+func (a routeViewsArchive) rib(t time.Time) (string, error) {
+	t = t.UTC().Truncate(2 * time.Hour)
+	return a.dir("RIBS")(t) + "rib." + t.Format("20060102.1504") + ".bz2", nil
+}
+
+// localArchive is a directory or glob of MRT files, named like the RIS and
+// RouteViews archives: bview.* and rib.* are RIB dumps, anything else holds
+// updates. Files are placed in time by the YYYYMMDD.HHMM in their name.
+type localArchive struct {
+	pattern string
+}
+
+var mrtFileTime = regexp.MustCompile(`([0-9]{8})\.([0-9]{4})`)
+
+type localFile struct {
+	path string
+	t    time.Time
+	rib  bool
+}
+
+func (a localArchive) files() ([]localFile, error) {
+	pattern := a.pattern
+	if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+		pattern = filepath.Join(pattern, "*")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %v", a.pattern, err)
+	}
+	var files []localFile
+	for _, path := range paths {
+		if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+			continue
+		}
+		base := filepath.Base(path)
+		f := localFile{path: path, rib: strings.HasPrefix(base, "bview") || strings.HasPrefix(base, "rib")}
+		if m := mrtFileTime.FindStringSubmatch(base); m != nil {
+			f.t, _ = time.Parse("20060102 1504", m[1]+" "+m[2])
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].t.Before(files[j].t) })
+	return files, nil
+}
+
+// updates returns the update files from the last one starting at or before
+// start until end. Files without a time in their name are always included.
+func (a localArchive) updates(start, end time.Time) ([]string, error) {
+	files, err := a.files()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	first := -1
+	for _, f := range files {
+		switch {
+		case f.rib:
+		case f.t.IsZero():
+			paths = append(paths, f.path)
+		case !f.t.After(start):
+			if first >= 0 {
+				paths = append(paths[:first], paths[first+1:]...)
+			}
+			first = len(paths)
+			paths = append(paths, f.path)
+		case f.t.Before(end):
+			paths = append(paths, f.path)
+		}
+	}
+	return paths, nil
+}
+
+func (a localArchive) rib(t time.Time) (string, error) {
+	files, err := a.files()
+	if err != nil {
+		return "", err
+	}
+	var path string
+	for _, f := range files {
+		if f.rib && !f.t.After(t) {
+			path = f.path
+		}
+	}
+	if path == "" {
+		return "", fmt.Errorf("no RIB dump at or before %s in %s", t.UTC().Format("2006-01-02 15:04"), a.pattern)
+	}
+	return path, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/mrt"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+)
+
+func TestParseCollector(t *testing.T) {
+	dir := t.TempDir()
+	collectorDir := filepath.Join(dir, "my-collector")
+	if err := os.Mkdir(collectorDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec    string
+		name    string
+		archive mrtArchive
+	}{
+		{"rrc00", "rrc00", risArchive{collector: "rrc00"}},
+		{"route-views2", "route-views2", routeViewsArchive{collector: "route-views2"}},
+		// Archived collectors keep their own name
+		{"rv=route-views.amsix", "route-views.amsix", routeViewsArchive{collector: "route-views.amsix"}},
+		{collectorDir, "my-collector", localArchive{pattern: collectorDir}},
+		{collectorDir + "/", "my-collector", localArchive{pattern: collectorDir + "/"}},
+		{filepath.Join(collectorDir, "updates.*"), "my-collector", localArchive{pattern: filepath.Join(collectorDir, "updates.*")}},
+		{"lab=" + filepath.Join(collectorDir, "*.gz"), "lab", localArchive{pattern: filepath.Join(collectorDir, "*.gz")}},
+		{"rrc00=" + collectorDir, "rrc00", localArchive{pattern: collectorDir}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := parseCollector(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCollector() error = %v", err)
+			}
+			if c.name != tt.name || c.archive != tt.archive {
+				t.Errorf("parseCollector() = (%q, %#v), want (%q, %#v)", c.name, c.archive, tt.name, tt.archive)
+			}
+		})
+	}
+
+	if _, err := parseCollector(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a path that does not exist")
+	}
+	collectors, err := parseCollectors("")
+	if err != nil || len(collectors) != 27 || collectors[26].name != "rrc26" {
+		t.Errorf("expected the RIS collectors by default, got %d (err %v)", len(collectors), err)
+	}
+}
+
+func TestRemoteArchives(t *testing.T) {
+	start := time.Date(2024, 3, 1, 23, 52, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 2, 0, 20, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 2, 1, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		archive mrtArchive
+		updates []string
+		rib     string
+	}{
+		{
+			"ris",
+			risArchive{collector: "rrc00"},
+			[]string{
+				"https://data.ris.ripe.net/rrc00/2024.03/updates.20240301.2350.gz",
+				"https://data.ris.ripe.net/rrc00/2024.03/updates.20240301.2355.gz",
+				"https://data.ris.ripe.net/rrc00/2024.03/updates.20240302.0000.gz",
+				"https://data.ris.ripe.net/rrc00/2024.03/updates.20240302.0005.gz",
+				"https://data.ris.ripe.net/rrc00/2024.03/updates.20240302.0010.gz",
+				"https://data.ris.ripe.net/rrc00/2024.03/updates.20240302.0015.gz",
+			},
+			"https://data.ris.ripe.net/rrc00/2024.03/bview.20240302.0000.gz",
+		},
+		{
+			"route-views2 at the top level",
+			routeViewsArchive{collector: "route-views2"},
+			[]string{
+				"https://archive.routeviews.org/bgpdata/2024.03/UPDATES/updates.20240301.2345.bz2",
+				"https://archive.routeviews.org/bgpdata/2024.03/UPDATES/updates.20240302.0000.bz2",
+				"https://archive.routeviews.org/bgpdata/2024.03/UPDATES/updates.20240302.0015.bz2",
+			},
+			"https://archive.routeviews.org/bgpdata/2024.03/RIBS/rib.20240302.0000.bz2",
+		},
+		{
+			"other collectors in their own directory",
+			routeViewsArchive{collector: "route-views.amsix"},
+			[]string{
+				"https://archive.routeviews.org/route-views.amsix/bgpdata/2024.03/UPDATES/updates.20240301.2345.bz2",
+				"https://archive.routeviews.org/route-views.amsix/bgpdata/2024.03/UPDATES/updates.20240302.0000.bz2",
+				"https://archive.routeviews.org/route-views.amsix/bgpdata/2024.03/UPDATES/updates.20240302.0015.bz2",
+			},
+			"https://archive.routeviews.org/route-views.amsix/bgpdata/2024.03/RIBS/rib.20240302.0000.bz2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, err := tt.archive.updates(start, end)
+			if err != nil || !slices.Equal(updates, tt.updates) {
+				t.Errorf("updates() = %v (err %v), want %v", updates, err, tt.updates)
+			}
+			rib, err := tt.archive.rib(at)
+			if err != nil || rib != tt.rib {
+				t.Errorf("rib() = %q (err %v), want %q", rib, err, tt.rib)
+			}
+		})
+	}
+}
+
+func TestLocalArchive(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"updates.20240301.0945.gz",
+		"updates.20240301.0955.gz",
+		"updates.20240301.1000.gz",
+		"updates.20240301.1005.gz",
+		"updates.20240301.1015.gz",
+		"bview.20240301.0800.gz",
+		"rib.20240301.1000.bz2",
+		"capture.mrt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "updates.20240301.1001.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	paths := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return paths
+	}
+	at := func(hhmm string) time.Time {
+		t, _ := time.Parse("20060102 1504", "20240301 "+hhmm)
+		return t
+	}
+
+	tests := []struct {
+		name       string
+		pattern    string
+		start, end time.Time
+		want       []string
+	}{
+		{
+			"starts with the last file at or before start",
+			dir, at("0958"), at("1010"),
+			paths("capture.mrt", "updates.20240301.0955.gz", "updates.20240301.1000.gz", "updates.20240301.1005.gz"),
+		},
+		{
+			"a file starting exactly at start",
+			dir, at("1000"), at("1005"),
+			paths("capture.mrt", "updates.20240301.1000.gz"),
+		},
+		{
+			"start before every file",
+			dir, at("0900"), at("0950"),
+			paths("capture.mrt", "updates.20240301.0945.gz"),
+		},
+		{
+			"glob",
+			filepath.Join(dir, "updates.*"), at("1003"), at("1100"),
+			paths("updates.20240301.1000.gz", "updates.20240301.1005.gz", "updates.20240301.1015.gz"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := localArchive{pattern: tt.pattern}.updates(tt.start, tt.end)
+			if err != nil || !slices.Equal(got, tt.want) {
+				t.Errorf("updates() = %v (err %v), want %v", got, err, tt.want)
+			}
+		})
+	}
+
+	ribs := []struct {
+		t    time.Time
+		want string
+	}{
+		{at("0959"), "bview.20240301.0800.gz"},
+		{at("1000"), "rib.20240301.1000.bz2"},
+		{at("2359"), "rib.20240301.1000.bz2"},
+	}
+	for _, tt := range ribs {
+		got, err := localArchive{pattern: dir}.rib(tt.t)
+		if err != nil || got != filepath.Join(dir, tt.want) {
+			t.Errorf("rib(%s) = %q (err %v), want %s", tt.t.Format("15:04"), got, err, tt.want)
+		}
+	}
+	if _, err := (localArchive{pattern: dir}).rib(at("0759")); err == nil {
+		t.Error("expected an error without a RIB dump at or before the time")
+	}
+}
+
+func TestReadMRTFile(t *testing.T) {
+	base := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var data []byte
+	for i := 0; i < 6; i++ {
+		update := bgp.NewBGPUpdateMessage([]*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "192.0.2.0")}, nil, nil)
+		body := mrt.NewBGP4MPMessage(64500, 64501, 0, "192.0.2.1", "192.0.2.2", true, update)
+		msg, err := mrt.NewMRTMessage(uint32(base.Add(time.Duration(i)*time.Minute).Unix()), mrt.BGP4MP, mrt.MESSAGE_AS4, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := msg.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+	path := filepath.Join(t.TempDir(), "updates.20240301.1000")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(start, end time.Time, skip int) []int {
+		ch := make(chan *mrtfile.Message, 10)
+		readMRTFile("rrc00", path, start, end, skip, ch)
+		close(ch)
+		var minutes []int
+		for msg := range ch {
+			if msg.Collector != "rrc00" || msg.Peer != "64500" {
+				t.Errorf("unexpected message from %s/%s", msg.Collector, msg.Peer)
+			}
+			minutes = append(minutes, int(msg.Timestamp.Sub(base)/time.Minute))
+		}
+		return minutes
+	}
+	if got := read(base.Add(time.Minute), base.Add(4*time.Minute), 0); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("expected messages in [start, end), got minutes %v", got)
+	}
+	if got := read(base.Add(time.Minute), base.Add(4*time.Minute), 2); !slices.Equal(got, []int{3}) {
+		t.Errorf("expected the first messages in the window to be skipped, got minutes %v", got)
+	}
+	if got := read(base.Add(time.Hour), base.Add(2*time.Hour), 0); len(got) != 0 {
+		t.Errorf("expected no messages outside the window, got minutes %v", got)
+	}
+}
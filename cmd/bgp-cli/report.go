@@ -1,48 +1,78 @@
 package main
 
 import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/netip"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/sudorandom/bgp-stream/pkg/api"
 	"github.com/sudorandom/bgp-stream/pkg/bgp"
 	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
 	"github.com/sudorandom/bgp-stream/pkg/utils"
 	"google.golang.org/protobuf/proto"
 )
 
 type ReportCmd struct {
-	States []string `default:"bgp_hijack,route_leak" sep:"," enum:"flap,path_hunting,traffic_eng,outage,route_leak,discovery,ddos_mitigation,bgp_hijack,bogon_martian" help:"List of states to filter by."`
-	DB     string   `default:"./data/prefix-state.db" help:"Path to the prefix state database."`
+	States        []string      `default:"bgp_hijack,route_leak" sep:"," enum:"flap,path_hunting,traffic_eng,outage,route_leak,discovery,ddos_mitigation,bgp_hijack,bogon_martian" help:"List of states to filter by."`
+	DB            string        `default:"./data/prefix-state.db" help:"Path to the prefix state database."`
+	Format        string        `default:"table" enum:"table,json,csv,ndjson" help:"Output format (table, json, csv, ndjson)."`
+	ASN           uint32        `name:"asn" default:"0" help:"Only show prefixes involving this ASN as origin, leaker or victim."`
+	Origin        uint32        `default:"0" help:"Only show prefixes originated by this ASN."`
+	Leaker        uint32        `default:"0" help:"Only show prefixes leaked by this ASN."`
+	Victim        uint32        `default:"0" help:"Only show prefixes whose victim is this ASN."`
+	Org           string        `default:"" help:"Only show prefixes whose origin, leaker or victim belongs to this organization ID."`
+	Country       string        `default:"" help:"Only show prefixes geolocated in this country (ISO code)."`
+	Prefix        string        `default:"" help:"Only show this prefix and the more-specifics it covers."`
+	MinMaskLen    int           `default:"0" help:"Only show prefixes with at least this mask length."`
+	UpdatedSince  string        `default:"" help:"Only show prefixes last updated at or after this time (YYYY-MM-DD HH:mm, UTC)."`
+	UpdatedUntil  string        `default:"" help:"Only show prefixes last updated before this time (YYYY-MM-DD HH:mm, UTC)."`
+	UpdatedWithin time.Duration `default:"0" help:"Only show prefixes updated within this long (e.g. 1h). Overrides --updated-since."`
+	Stale         string        `default:"any" enum:"any,yes,no" help:"Only show stale (yes) or fresh (no) prefixes."`
+	StaleAfter    time.Duration `default:"24h" help:"How long without updates before a prefix is stale."`
+	Sort          string        `default:"prefix" enum:"prefix,impacted-ips,duration,last-update,score" help:"Sort order (prefix, impacted-ips, duration, last-update, score). All but prefix sort the largest or newest first."`
+	Limit         int           `default:"0" help:"Stop after this many prefixes (0 for no limit)."`
 }
 
-func (c *ReportCmd) Run() error {
-	stateMap := map[string]string{
-		"flap":            bgp.NameFlap,
-		"path_hunting":    bgp.NamePathHunting,
-		"traffic_eng":     bgp.NameTrafficEng,
-		"outage":          bgp.NameHardOutage,
-		"route_leak":      bgp.NameRouteLeak,
-		"discovery":       bgp.NameDiscovery,
-		"ddos_mitigation": bgp.NameDDoSMitigation,
-		"bgp_hijack":      bgp.NameHijack,
-		"bogon_martian":   bgp.NameBogon,
-	}
+// reportRow is one prefix of the report: its classifier state, plus names,
+// location, size and the evidence stored with it.
+type reportRow struct {
+	*api.PrefixInfo
+	OriginName     string           `json:"origin_name,omitempty"`
+	OrgID          string           `json:"org_id,omitempty"`
+	LeakerName     string           `json:"leaker_name,omitempty"`
+	VictimName     string           `json:"victim_name,omitempty"`
+	Country        string           `json:"country,omitempty"`
+	City           string           `json:"city,omitempty"`
+	ImpactedIPs    uint64           `json:"impacted_ips"`
+	ActiveDuration int64            `json:"active_seconds"`
+	Stale          bool             `json:"stale"`
+	Evidence       journal.Evidence `json:"evidence"`
+}
 
-	targetStates := make(map[string]bool)
-	for _, s := range c.States {
-		if internalName, ok := stateMap[s]; ok {
-			targetStates[strings.ToLower(internalName)] = true
-		}
-	}
+// reportFilter holds the parsed filters of a ReportCmd.
+type reportFilter struct {
+	types    map[bgp.ClassificationType]bool
+	prefix   netip.Prefix
+	from, to time.Time
+}
 
-	if len(targetStates) == 0 {
-		return fmt.Errorf("no valid states provided")
+func (c *ReportCmd) Run() error {
+	f, err := c.filter()
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Opening database at %s...", c.DB)
@@ -56,14 +86,19 @@ func (c *ReportCmd) Run() error {
 		}
 	}()
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	if _, err := fmt.Fprintln(w, "PREFIX\tSTATE\tLAST ASN\tVICTIM ASN\tLEAKER ASN\tLAST UPDATE\tACTIVE DURATION\tSTALE"); err != nil {
-		return err
+	asnMapping := utils.NewASNMapping()
+	if err := asnMapping.Load(); err != nil {
+		log.Printf("Warning: failed to load ASN names: %v", err)
 	}
+	geo := geoservice.NewGeoService(3840, 2160, 760.0)
+	if err := geo.OpenHintDBs("data", true); err != nil {
+		log.Printf("Warning: failed to open hint databases: %v", err)
+	}
+	defer func() { _ = geo.Close() }()
+	geoservice.NewDataManager(geo).LoadWorldCities()
 
-	count := 0
-	now := time.Now().Unix()
-
+	now := time.Now()
+	var rows []*reportRow
 	err = db.ForEach(func(k []byte, v []byte) error {
 		if len(k) != 5 {
 			return nil
@@ -72,67 +107,327 @@ func (c *ReportCmd) Run() error {
 		if err := proto.Unmarshal(v, state); err != nil {
 			return nil
 		}
-
-		if state.ClassifiedType == 0 {
+		if !f.types[bgp.ClassificationType(state.ClassifiedType)] {
 			return nil
 		}
-
-		className := bgp.ClassificationType(state.ClassifiedType).String()
-		if !targetStates[strings.ToLower(className)] {
-			return nil
+		prefix := fmt.Sprintf("%s/%d", net.IP(k[:4]).String(), k[4])
+		if row := c.match(f, prefix, state, asnMapping, geo, now); row != nil {
+			rows = append(rows, row)
 		}
-
-		if err := c.printReportLine(w, k, state, className, now); err != nil {
-			return err
-		}
-		count++
 		return nil
 	})
-
 	if err != nil {
 		return fmt.Errorf("error iterating through database: %v", err)
 	}
 
-	if err := w.Flush(); err != nil {
-		return err
+	c.sort(rows)
+	if c.Limit > 0 && len(rows) > c.Limit {
+		rows = rows[:c.Limit]
 	}
-
-	fmt.Printf("\nTotal matched prefixes: %d\n", count)
-	return nil
+	return c.write(os.Stdout, rows)
 }
 
-func (c *ReportCmd) printReportLine(w io.Writer, k []byte, state *bgpproto.PrefixState, className string, now int64) error {
-	ip := net.IP(k[:4])
-	mask := int(k[4])
-	prefix := fmt.Sprintf("%s/%d", ip.String(), mask)
+func (c *ReportCmd) filter() (*reportFilter, error) {
+	f := &reportFilter{types: make(map[bgp.ClassificationType]bool)}
+	for _, key := range c.States {
+		t, ok := bgp.ParseClassificationKey(key)
+		if !ok {
+			return nil, fmt.Errorf("unknown state %q", key)
+		}
+		f.types[t] = true
+	}
+	if len(f.types) == 0 {
+		return nil, fmt.Errorf("no valid states provided")
+	}
+	var err error
+	if c.Prefix != "" {
+		if f.prefix, err = netip.ParsePrefix(c.Prefix); err != nil {
+			return nil, fmt.Errorf("invalid prefix: %v", err)
+		}
+		f.prefix = f.prefix.Masked()
+	}
+	if c.UpdatedSince != "" {
+		if f.from, err = time.Parse("2006-01-02 15:04", c.UpdatedSince); err != nil {
+			return nil, fmt.Errorf("invalid updated-since time: %v", err)
+		}
+	}
+	if c.UpdatedWithin > 0 {
+		f.from = time.Now().Add(-c.UpdatedWithin)
+	}
+	if c.UpdatedUntil != "" {
+		if f.to, err = time.Parse("2006-01-02 15:04", c.UpdatedUntil); err != nil {
+			return nil, fmt.Errorf("invalid updated-until time: %v", err)
+		}
+	}
+	return f, nil
+}
 
+// match builds the row for a classified prefix, or returns nil if the prefix
+// is filtered out. The cheap filters run before names and locations are
+// looked up.
+func (c *ReportCmd) match(f *reportFilter, prefix string, state *bgpproto.PrefixState, asnMapping *utils.ASNMapping, geo *geoservice.GeoService, now time.Time) *reportRow {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return nil
+	}
+	if p.Bits() < c.MinMaskLen {
+		return nil
+	}
+	if f.prefix.IsValid() && (p.Bits() < f.prefix.Bits() || !f.prefix.Contains(p.Addr())) {
+		return nil
+	}
 	lastUpdate := time.Unix(state.LastUpdateTs, 0)
-	duration := time.Duration(now-state.ClassifiedTimeTs) * time.Second
+	if !f.from.IsZero() && lastUpdate.Before(f.from) {
+		return nil
+	}
+	if !f.to.IsZero() && !lastUpdate.Before(f.to) {
+		return nil
+	}
+	stale := now.Sub(lastUpdate) > c.StaleAfter
+	if (c.Stale == "yes" && !stale) || (c.Stale == "no" && stale) {
+		return nil
+	}
+	origin, leaker, victim := state.LastOriginAsn, state.LeakerAsn, state.VictimAsn
+	if c.Origin != 0 && origin != c.Origin {
+		return nil
+	}
+	if c.Leaker != 0 && leaker != c.Leaker {
+		return nil
+	}
+	if c.Victim != 0 && victim != c.Victim {
+		return nil
+	}
+	if c.ASN != 0 && origin != c.ASN && leaker != c.ASN && victim != c.ASN {
+		return nil
+	}
+	if c.Org != "" && !slices.ContainsFunc([]uint32{origin, leaker, victim}, func(asn uint32) bool {
+		return asn != 0 && asnMapping.GetOrgID(asn) == c.Org
+	}) {
+		return nil
+	}
+	_, _, cc, city, _ := geo.GetIPCoords(prefixToIP(prefix))
+	if c.Country != "" && !strings.EqualFold(cc, c.Country) {
+		return nil
+	}
 
-	isStale := "No"
-	if now-state.LastUpdateTs > 86400 {
-		isStale = "Yes (>24h)"
+	evidence := bgp.StateEvidence(state)
+	row := &reportRow{
+		PrefixInfo:  api.NewPrefixInfo(prefix, state),
+		OriginName:  api.NetworkName(asnMapping, origin),
+		LeakerName:  api.NetworkName(asnMapping, leaker),
+		VictimName:  api.NetworkName(asnMapping, victim),
+		Country:     cc,
+		City:        city,
+		ImpactedIPs: utils.GetPrefixSize(prefix),
+		Stale:       stale,
+		Evidence:    journal.NewEvidence(&evidence),
+	}
+	if origin != 0 {
+		row.OrgID = asnMapping.GetOrgID(origin)
+	}
+	if state.ClassifiedTimeTs > 0 {
+		row.ActiveDuration = now.Unix() - state.ClassifiedTimeTs
+	}
+	return row
+}
+
+func (c *ReportCmd) sort(rows []*reportRow) {
+	byPrefix := func(a, b *reportRow) int {
+		pa, _ := netip.ParsePrefix(a.Prefix)
+		pb, _ := netip.ParsePrefix(b.Prefix)
+		if n := pa.Addr().Compare(pb.Addr()); n != 0 {
+			return n
+		}
+		return cmp.Compare(pa.Bits(), pb.Bits())
 	}
+	var key func(a, b *reportRow) int
+	switch c.Sort {
+	case "impacted-ips":
+		key = func(a, b *reportRow) int { return cmp.Compare(b.ImpactedIPs, a.ImpactedIPs) }
+	case "duration":
+		key = func(a, b *reportRow) int { return cmp.Compare(b.ActiveDuration, a.ActiveDuration) }
+	case "last-update":
+		key = func(a, b *reportRow) int { return b.LastUpdate.Compare(a.LastUpdate) }
+	case "score":
+		key = func(a, b *reportRow) int { return cmp.Compare(rowScore(b), rowScore(a)) }
+	}
+	slices.SortFunc(rows, func(a, b *reportRow) int {
+		if key != nil {
+			if n := key(a, b); n != 0 {
+				return n
+			}
+		}
+		return byPrefix(a, b)
+	})
+}
 
-	victimASN := "-"
-	if state.VictimAsn != 0 {
-		victimASN = fmt.Sprintf("%d", state.VictimAsn)
+// rowScore returns the anomaly score, or -1 for prefixes without a baseline
+// so they sort last.
+func rowScore(r *reportRow) float64 {
+	if r.AnomalyScore == nil {
+		return -1
 	}
+	return *r.AnomalyScore
+}
 
-	leakerASN := "-"
-	if state.LeakerAsn != 0 {
-		leakerASN = fmt.Sprintf("%d", state.LeakerAsn)
+func (c *ReportCmd) write(out io.Writer, rows []*reportRow) error {
+	switch c.Format {
+	case "json":
+		if rows == nil {
+			rows = []*reportRow{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"prefixes": rows, "total": len(rows)})
+	case "ndjson":
+		enc := json.NewEncoder(out)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		w := csv.NewWriter(out)
+		_ = w.Write(reportCSVHeader)
+		for _, r := range rows {
+			_ = w.Write(reportCSVRow(r))
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		return c.writeTable(out, rows)
 	}
+}
 
-	_, err := fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
-		prefix,
-		className,
-		state.LastOriginAsn,
-		victimASN,
-		leakerASN,
-		lastUpdate.Format(time.RFC3339),
-		duration.String(),
-		isStale,
-	)
+func (c *ReportCmd) writeTable(out io.Writer, rows []*reportRow) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	if _, err := fmt.Fprintln(w, "PREFIX\tSTATE\tORIGIN\tLEAK\tCOUNTRY\tIPS\tRPKI\tSCORE\tVISIBILITY\tEVIDENCE\tLAST UPDATE\tACTIVE DURATION\tSTALE"); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		className := r.Name
+		if r.BogonReason != "" && r.Classification == bgp.ClassificationBogon.Key() {
+			className = fmt.Sprintf("%s (%s)", className, r.BogonReason)
+		}
+		score := "-"
+		if r.AnomalyScore != nil {
+			score = fmt.Sprintf("%.1f", *r.AnomalyScore)
+		}
+		visibility := "-"
+		if r.Visibility != nil {
+			visibility = fmt.Sprintf("%.0f%% (peak %.0f%%)", *r.Visibility*100, *r.PeakVisibility*100)
+		}
+		stale := "No"
+		if r.Stale {
+			stale = fmt.Sprintf("Yes (>%s)", strings.TrimSuffix(strings.TrimSuffix(c.StaleAfter.String(), "0s"), "0m"))
+		}
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Prefix,
+			className,
+			reportNetwork(r.OriginASN, r.OriginName),
+			reportLeak(r),
+			orDash(r.Country),
+			r.ImpactedIPs,
+			orDash(r.RPKI),
+			score,
+			visibility,
+			reportEvidence(&r.Evidence),
+			r.LastUpdate.Format(time.RFC3339),
+			(time.Duration(r.ActiveDuration) * time.Second).String(),
+			stale,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\nTotal matched prefixes: %d\n", len(rows))
 	return err
 }
+
+func reportNetwork(asn uint32, name string) string {
+	if asn == 0 {
+		return "-"
+	}
+	if name == "" {
+		return fmt.Sprintf("AS%d", asn)
+	}
+	return fmt.Sprintf("AS%d (%s)", asn, name)
+}
+
+func reportLeak(r *reportRow) string {
+	if r.Leak == nil {
+		return "-"
+	}
+	var parts []string
+	if r.Leak.Type != "" {
+		parts = append(parts, r.Leak.Type)
+	}
+	if r.Leak.LeakerASN != 0 {
+		parts = append(parts, "leaker "+reportNetwork(r.Leak.LeakerASN, r.LeakerName))
+	}
+	if r.Leak.VictimASN != 0 {
+		parts = append(parts, "victim "+reportNetwork(r.Leak.VictimASN, r.VictimName))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func reportEvidence(ev *journal.Evidence) string {
+	s := fmt.Sprintf("%d msgs, %d peers/%d hosts", ev.Messages, ev.Peers, ev.Hosts)
+	if ev.WithdrawnPeers > 0 {
+		s += fmt.Sprintf(", %d withdrawn", ev.WithdrawnPeers)
+	}
+	if ev.Path != "" {
+		s += ", path " + ev.Path
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+var reportCSVHeader = []string{
+	"prefix", "type", "bogon_reason", "origin_asn", "origin_name", "org_id",
+	"leak_type", "leaker_asn", "leaker_name", "victim_asn", "victim_name",
+	"country", "city", "impacted_ips", "rpki", "anomaly_score", "visibility", "peak_visibility",
+	"first_seen", "classified_since", "last_update", "active_seconds", "stale",
+	"peer", "host", "path", "messages", "announcements", "withdrawals", "path_changes",
+	"peers", "hosts", "withdrawn_peers", "withdrawn_hosts", "origins",
+}
+
+func reportCSVRow(r *reportRow) []string {
+	leak := r.Leak
+	if leak == nil {
+		leak = &api.Leak{}
+	}
+	ev := r.Evidence
+	itoa := func(n int) string { return strconv.Itoa(n) }
+	utoa := func(n uint32) string { return strconv.FormatUint(uint64(n), 10) }
+	ftoa := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', -1, 64)
+	}
+	ttoa := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return []string{
+		r.Prefix, r.Classification, r.BogonReason, utoa(r.OriginASN), r.OriginName, r.OrgID,
+		leak.Type, utoa(leak.LeakerASN), r.LeakerName, utoa(leak.VictimASN), r.VictimName,
+		r.Country, r.City, strconv.FormatUint(r.ImpactedIPs, 10), r.RPKI, ftoa(r.AnomalyScore), ftoa(r.Visibility), ftoa(r.PeakVisibility),
+		ttoa(r.FirstSeen), ttoa(r.ClassifiedSince), ttoa(r.LastUpdate), strconv.FormatInt(r.ActiveDuration, 10), strconv.FormatBool(r.Stale),
+		ev.Peer, ev.Host, ev.Path, itoa(int(ev.Messages)), itoa(int(ev.Announcements)), itoa(int(ev.Withdrawals)), itoa(int(ev.PathChanges)),
+		itoa(ev.Peers), itoa(ev.Hosts), itoa(ev.WithdrawnPeers), itoa(ev.WithdrawnHosts), itoa(ev.Origins),
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+func TestReportCmd_Filter(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     ReportCmd
+		wantErr string
+	}{
+		{"defaults", ReportCmd{States: []string{"bgp_hijack", "route_leak"}}, ""},
+		{"unknown state", ReportCmd{States: []string{"bgp_hijack", "nope"}}, `unknown state "nope"`},
+		{"no states", ReportCmd{}, "no valid states provided"},
+		{"bad prefix", ReportCmd{States: []string{"outage"}, Prefix: "10.0.0.0/33"}, "invalid prefix"},
+		{"bad since", ReportCmd{States: []string{"outage"}, UpdatedSince: "yesterday"}, "invalid updated-since time"},
+		{"bad until", ReportCmd{States: []string{"outage"}, UpdatedUntil: "2024-01-01"}, "invalid updated-until time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cmd.filter()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("filter() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("filter() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	c := ReportCmd{States: []string{"route_leak"}, Prefix: "10.1.2.3/16", UpdatedSince: "2024-01-01 00:00", UpdatedWithin: time.Hour}
+	f, err := c.filter()
+	if err != nil {
+		t.Fatalf("filter() error = %v", err)
+	}
+	if !f.types[bgp.ClassificationRouteLeak] || len(f.types) != 1 {
+		t.Errorf("types = %v, want only route leaks", f.types)
+	}
+	if f.prefix.String() != "10.1.0.0/16" {
+		t.Errorf("prefix = %s, want the masked 10.1.0.0/16", f.prefix)
+	}
+	if time.Since(f.from) > 2*time.Hour {
+		t.Errorf("from = %v, expected --updated-within to override --updated-since", f.from)
+	}
+}
+
+func TestReportCmd_Match(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	asns := utils.NewASNMapping()
+	utils.SetASNName(asns, 64500, "ORIGIN-NET")
+	utils.SetASNOrgID(asns, 64500, "ORG-O")
+	utils.SetASNOrgID(asns, 64501, "ORG-L")
+	geo := geoservice.NewGeoService(3840, 2160, 760.0)
+
+	state := &bgpproto.PrefixState{
+		ClassifiedType:   int32(bgp.ClassificationRouteLeak),
+		ClassifiedTimeTs: now.Add(-time.Hour).Unix(),
+		LastUpdateTs:     now.Add(-10 * time.Minute).Unix(),
+		LastOriginAsn:    64500,
+		LeakerAsn:        64501,
+		VictimAsn:        64502,
+		LeakType:         int32(bgp.LeakPeerToProvider),
+	}
+	tests := []struct {
+		name   string
+		cmd    ReportCmd
+		prefix string
+		match  bool
+	}{
+		{"no filters", ReportCmd{}, "10.1.2.0/24", true},
+		{"min mask length", ReportCmd{MinMaskLen: 25}, "10.1.2.0/24", false},
+		{"covered by prefix", ReportCmd{Prefix: "10.1.0.0/16"}, "10.1.2.0/24", true},
+		{"outside prefix", ReportCmd{Prefix: "10.2.0.0/16"}, "10.1.2.0/24", false},
+		{"less specific than prefix", ReportCmd{Prefix: "10.1.2.0/24"}, "10.1.0.0/16", false},
+		{"updated since", ReportCmd{UpdatedSince: "2024-05-01 11:55"}, "10.1.2.0/24", false},
+		{"updated until", ReportCmd{UpdatedUntil: "2024-05-01 11:50"}, "10.1.2.0/24", false},
+		{"updated before until", ReportCmd{UpdatedUntil: "2024-05-01 11:51"}, "10.1.2.0/24", true},
+		{"stale only", ReportCmd{Stale: "yes", StaleAfter: time.Hour}, "10.1.2.0/24", false},
+		{"stale after short ttl", ReportCmd{Stale: "yes", StaleAfter: time.Minute}, "10.1.2.0/24", true},
+		{"fresh only", ReportCmd{Stale: "no", StaleAfter: time.Minute}, "10.1.2.0/24", false},
+		{"origin", ReportCmd{Origin: 64500}, "10.1.2.0/24", true},
+		{"other origin", ReportCmd{Origin: 64501}, "10.1.2.0/24", false},
+		{"leaker", ReportCmd{Leaker: 64501}, "10.1.2.0/24", true},
+		{"victim", ReportCmd{Victim: 64500}, "10.1.2.0/24", false},
+		{"any role", ReportCmd{ASN: 64502}, "10.1.2.0/24", true},
+		{"no role", ReportCmd{ASN: 64503}, "10.1.2.0/24", false},
+		{"leaker org", ReportCmd{Org: "ORG-L"}, "10.1.2.0/24", true},
+		{"unknown org", ReportCmd{Org: "ORG-X"}, "10.1.2.0/24", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cmd.States = []string{"route_leak"}
+			f, err := tt.cmd.filter()
+			if err != nil {
+				t.Fatalf("filter() error = %v", err)
+			}
+			row := tt.cmd.match(f, tt.prefix, state, asns, geo, now)
+			if (row != nil) != tt.match {
+				t.Errorf("match() = %v, want match %v", row, tt.match)
+			}
+		})
+	}
+
+	c := ReportCmd{States: []string{"route_leak"}, StaleAfter: 24 * time.Hour}
+	f, _ := c.filter()
+	row := c.match(f, "10.1.2.0/24", state, asns, geo, now)
+	if row == nil {
+		t.Fatal("expected a row")
+	}
+	if row.OriginName != "ORIGIN-NET" || row.OrgID != "ORG-O" {
+		t.Errorf("origin = (%q, %q), want (ORIGIN-NET, ORG-O)", row.OriginName, row.OrgID)
+	}
+	if row.ImpactedIPs != 256 || row.ActiveDuration != 3600 || row.Stale {
+		t.Errorf("row = (%d IPs, %ds, stale %v), want (256, 3600s, false)", row.ImpactedIPs, row.ActiveDuration, row.Stale)
+	}
+}
+
+func TestReportCmd_Sort(t *testing.T) {
+	score := func(f float64) *float64 { return &f }
+	newRow := func(prefix string, ips uint64, active int64, updated int, s *float64) *reportRow {
+		return &reportRow{
+			PrefixInfo: &api.PrefixInfo{
+				Prefix:       prefix,
+				LastUpdate:   time.Unix(int64(updated), 0),
+				AnomalyScore: s,
+			},
+			ImpactedIPs:    ips,
+			ActiveDuration: active,
+		}
+	}
+	rows := []*reportRow{
+		newRow("10.0.0.0/24", 256, 30, 3, score(1)),
+		newRow("9.0.0.0/16", 65536, 10, 1, nil),
+		newRow("10.0.0.0/16", 65536, 20, 2, score(5)),
+		newRow("10.0.0.0/8", 1<<24, 30, 3, score(-0.5)),
+	}
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"prefix", []string{"9.0.0.0/16", "10.0.0.0/8", "10.0.0.0/16", "10.0.0.0/24"}},
+		{"impacted-ips", []string{"10.0.0.0/8", "9.0.0.0/16", "10.0.0.0/16", "10.0.0.0/24"}},
+		{"duration", []string{"10.0.0.0/8", "10.0.0.0/24", "10.0.0.0/16", "9.0.0.0/16"}},
+		{"last-update", []string{"10.0.0.0/8", "10.0.0.0/24", "10.0.0.0/16", "9.0.0.0/16"}},
+		// Prefixes without a baseline sort after negative scores
+		{"score", []string{"10.0.0.0/16", "10.0.0.0/24", "10.0.0.0/8", "9.0.0.0/16"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			c := ReportCmd{Sort: tt.sort}
+			c.sort(rows)
+			var got []string
+			for _, r := range rows {
+				got = append(got, r.Prefix)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("sort(%s) = %v, want %v", tt.sort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportCmd_Write(t *testing.T) {
+	vis, peak := 0.25, 0.75
+	row := &reportRow{
+		PrefixInfo: &api.PrefixInfo{
+			Prefix:         "10.1.2.0/24",
+			Classification: bgp.ClassificationRouteLeak.Key(),
+			Name:           bgp.ClassificationRouteLeak.String(),
+			OriginASN:      64500,
+			LastUpdate:     time.Date(2024, 5, 1, 11, 50, 0, 0, time.UTC),
+			Leak:           &api.Leak{Type: bgp.LeakPeerToProvider.String(), LeakerASN: 64501},
+			Visibility:     &vis,
+			PeakVisibility: &peak,
+		},
+		OriginName:     "ORIGIN-NET",
+		ImpactedIPs:    256,
+		ActiveDuration: 3600,
+		Stale:          true,
+	}
+	rows := []*reportRow{row}
+
+	write := func(format string, rows []*reportRow) string {
+		t.Helper()
+		var buf bytes.Buffer
+		c := ReportCmd{Format: format, StaleAfter: 24 * time.Hour}
+		if err := c.write(&buf, rows); err != nil {
+			t.Fatalf("write(%s) error = %v", format, err)
+		}
+		return buf.String()
+	}
+
+	table := write("table", rows)
+	for _, want := range []string{
+		"PREFIX", "10.1.2.0/24", "AS64500 (ORIGIN-NET)", "Peer to Provider, leaker AS64501",
+		"25% (peak 75%)", "2024-05-01T11:50:00Z", "1h0m0s", "Yes (>24h)", "Total matched prefixes: 1",
+	} {
+		if !strings.Contains(table, want) {
+			t.Errorf("table output is missing %q:\n%s", want, table)
+		}
+	}
+
+	var doc struct {
+		Prefixes []map[string]any `json:"prefixes"`
+		Total    int              `json:"total"`
+	}
+	if err := json.Unmarshal([]byte(write("json", nil)), &doc); err != nil || doc.Prefixes == nil || doc.Total != 0 {
+		t.Errorf("expected an empty prefixes list for no rows, got %+v (err %v)", doc, err)
+	}
+	if err := json.Unmarshal([]byte(write("json", rows)), &doc); err != nil {
+		t.Fatalf("json output: %v", err)
+	}
+	if doc.Total != 1 || doc.Prefixes[0]["origin_name"] != "ORIGIN-NET" || doc.Prefixes[0]["impacted_ips"] != float64(256) {
+		t.Errorf("unexpected json output: %+v", doc)
+	}
+
+	lines := strings.Split(strings.TrimSpace(write("ndjson", []*reportRow{row, row})), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one ndjson line per row, got %d", len(lines))
+	}
+
+	records, err := csv.NewReader(strings.NewReader(write("csv", rows))).ReadAll()
+	if err != nil {
+		t.Fatalf("csv output: %v", err)
+	}
+	if len(records) != 2 || len(records[1]) != len(reportCSVHeader) {
+		t.Fatalf("expected a header and one row of %d columns, got %v", len(reportCSVHeader), records)
+	}
+	got := make(map[string]string)
+	for i, col := range reportCSVHeader {
+		got[col] = records[1][i]
+	}
+	want := map[string]string{
+		"prefix": "10.1.2.0/24", "type": "route_leak", "origin_asn": "64500", "leak_type": "Peer to Provider",
+		"leaker_asn": "64501", "victim_asn": "0", "visibility": "0.25", "anomaly_score": "", "first_seen": "",
+		"last_update": "2024-05-01T11:50:00Z", "active_seconds": "3600", "stale": "true",
+	}
+	for col, v := range want {
+		if got[col] != v {
+			t.Errorf("csv %s = %q, want %q", col, got[col], v)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/mrt"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// ribFile is a TABLE_DUMP_V2 RIB dump and the collector that took it.
+type ribFile struct {
+	collector string
+	path      string
+}
+
+// parseRIBFile parses a [collector=]path flag. Without a collector the file
+// name is used.
+func parseRIBFile(s string) ribFile {
+	if collector, path, ok := strings.Cut(s, "="); ok {
+		return ribFile{collector: collector, path: path}
+	}
+	return ribFile{collector: filepath.Base(s), path: s}
+}
+
+// ribPath is the route one peer had for a prefix when the dump was taken.
+type ribPath struct {
+	Peer  string
+	Attrs []bgp.PathAttributeInterface
+}
+
+// ribEntry is one prefix of a RIB dump with the paths of every peer.
+type ribEntry struct {
+	Timestamp time.Time
+	Collector string
+	Prefix    string
+	Paths     []ribPath
+}
+
+// readRIBFile passes every IPv4 unicast prefix of a TABLE_DUMP_V2 dump (a RIS
+// bview or a RouteViews rib) to fn and returns how many there were. Peers are
+// identified by their ASN, as in the updates replayed by analyze.
+func readRIBFile(collector, path string, fn func(e *ribEntry)) int {
+	var peers []*mrt.Peer
+	count := 0
+	mrtfile.Scan(collector, path, func(msg *mrt.MRTMessage) {
+		if msg.Header.Type != mrt.TABLE_DUMPv2 {
+			return
+		}
+		switch body := msg.Body.(type) {
+		case *mrt.PeerIndexTable:
+			peers = body.Peers
+		case *mrt.Rib:
+			subtype := mrt.MRTSubTypeTableDumpv2(msg.Header.SubType)
+			nlri, ok := body.Prefix.(*bgp.IPAddrPrefix)
+			if !ok || (subtype != mrt.RIB_IPV4_UNICAST && subtype != mrt.RIB_IPV4_UNICAST_ADDPATH) {
+				return
+			}
+			e := &ribEntry{
+				Timestamp: msg.Header.GetTime(),
+				Collector: collector,
+				Prefix:    nlri.String(),
+			}
+			for _, re := range body.Entries {
+				if int(re.PeerIndex) >= len(peers) {
+					continue
+				}
+				e.Paths = append(e.Paths, ribPath{
+					Peer:  strconv.FormatUint(uint64(peers[re.PeerIndex].AS), 10),
+					Attrs: re.PathAttributes,
+				})
+			}
+			count++
+			fn(e)
+		}
+	})
+	return count
+}
+
+// collectorRIBs fetches the RIB dump of each collector taken at or before t,
+// downloading archived ones into cacheDir.
+func collectorRIBs(collectors []mrtCollector, t time.Time, cacheDir string) []ribFile {
+	var files []ribFile
+	for _, c := range collectors {
+		location, err := c.archive.rib(t)
+		if err != nil {
+			log.Printf("No RIB dump for %s: %v", c.name, err)
+			continue
+		}
+		path, err := c.fetch(location, cacheDir)
+		if err != nil {
+			log.Printf("Failed to download %s: %v", location, err)
+			continue
+		}
+		files = append(files, ribFile{collector: c.name, path: path})
+	}
+	return files
+}
+
+// pathOrigin returns the last ASN of the AS path, or 0 without one.
+func pathOrigin(attrs []bgp.PathAttributeInterface) uint32 {
+	for _, attr := range attrs {
+		if a, ok := attr.(*bgp.PathAttributeAsPath); ok && len(a.Value) > 0 {
+			if asns := a.Value[len(a.Value)-1].GetAS(); len(asns) > 0 {
+				return asns[len(asns)-1]
+			}
+		}
+	}
+	return 0
+}
+
+type seenVote struct {
+	asn   uint32
+	peers int
+}
+
+// seenOrigins collects the origin of each prefix in RIB dumps, as stored in
+// the seen prefixes database. A prefix gets the origin carried by the most
+// peers; when collectors disagree, the one with the most peers wins.
+type seenOrigins map[string]seenVote
+
+func (s seenOrigins) add(e *ribEntry) {
+	counts := make(map[uint32]int)
+	var best seenVote
+	for _, p := range e.Paths {
+		asn := pathOrigin(p.Attrs)
+		if asn == 0 {
+			continue
+		}
+		counts[asn]++
+		if n := counts[asn]; n > best.peers || (n == best.peers && asn < best.asn) {
+			best = seenVote{asn: asn, peers: n}
+		}
+	}
+	if best.asn == 0 {
+		return
+	}
+	prev, ok := s[e.Prefix]
+	switch {
+	case !ok:
+		s[e.Prefix] = best
+	case prev.asn == best.asn:
+		prev.peers += best.peers
+		s[e.Prefix] = prev
+	case best.peers > prev.peers:
+		s[e.Prefix] = best
+	}
+}
+
+// write stores the origins in db. With replace the database holds exactly
+// these prefixes afterwards; otherwise only prefixes it lacks are added, so
+// origins observed earlier are kept.
+func (s seenOrigins) write(db *utils.DiskTrie, replace bool) (int, error) {
+	entries := make(map[string][]byte, len(s))
+	for prefix, v := range s {
+		if !replace {
+			if val, _ := db.Get(prefix); val != nil {
+				continue
+			}
+		}
+		asnBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(asnBytes, v.asn)
+		entries[prefix] = asnBytes
+	}
+	if replace {
+		return len(entries), db.ReplaceAll(entries)
+	}
+	return len(entries), db.BatchInsertRaw(entries)
+}
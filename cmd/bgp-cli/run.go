@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/alerting"
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/eventstream"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
+	"github.com/sudorandom/bgp-stream/pkg/rislive"
+	"github.com/sudorandom/bgp-stream/pkg/siem"
+	"github.com/sudorandom/bgp-stream/pkg/simulate"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"github.com/sudorandom/bgp-stream/pkg/watchlist"
+)
+
+// PipelineFlags configure the headless pipeline shared by run and serve.
+type PipelineFlags struct {
+	StreamAddr  string        `default:"" help:"Address to serve the gRPC/Connect event stream on (empty to disable)."`
+	WSAddr      string        `default:"" help:"Address to re-broadcast enriched RIS Live messages on over a websocket (empty to disable)."`
+	Watchlist   string        `default:"" help:"Watchlist file of prefixes and ASNs to alert on (empty to disable)."`
+	AlertRules  string        `default:"" help:"Alert rules file routing critical events to webhooks, syslog and files; reloaded when it changes (empty to disable)."`
+	SIEM        string        `default:"" help:"SIEM sink configuration for exporting classified events over syslog or to files (empty to disable)."`
+	JournalDir  string        `default:"./data/journal" help:"Directory for the event journal (empty to disable)."`
+	StateDB     string        `default:"./data/prefix-state.db" help:"Path to the prefix state database (empty to keep state in memory only)."`
+	SeenDB      string        `default:"./data/seen-prefixes.db" help:"Path to the seen prefixes database (empty to disable)."`
+	StateTTL    time.Duration `default:"720h" help:"Delete a prefix's state after this long without updates (0 to keep it forever)."`
+	FullBogons  bool          `help:"Also flag prefixes and ASNs from unallocated space (requires bgp-cli fetch)."`
+	LogInterval time.Duration `default:"1m" help:"How often to log processing statistics (0 to disable)."`
+	Simulate    string        `default:"" help:"Play a simulation scenario in real time, starting now, instead of the live stream (empty to disable)."`
+}
+
+type RunCmd struct {
+	Addr          string `default:"" help:"Address to serve the JSON API on (empty to disable)."`
+	PipelineFlags `embed:""`
+}
+
+func (c *RunCmd) Run() error {
+	return runPipeline(c.Addr, &c.PipelineFlags)
+}
+
+// runPipeline processes the live RIS stream without a window until SIGINT or
+// SIGTERM, feeding the configured outputs. On the way out the servers stop
+// first, then the processor flushes its state, then the databases close.
+func runPipeline(addr string, f *PipelineFlags) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var scenario *simulate.Scenario
+	if f.Simulate != "" {
+		sc, err := simulate.Load(f.Simulate)
+		if err != nil {
+			return err
+		}
+		sc.Start = time.Now().UTC()
+		scenario = sc
+	}
+
+	geo, asnMapping, rpki := setupDependencies()
+	defer func() { _ = geo.Close() }()
+
+	var seenDB, stateDB *utils.DiskTrie
+	if f.SeenDB != "" {
+		db, err := utils.OpenDiskTrie(f.SeenDB)
+		if err != nil {
+			return fmt.Errorf("failed to open seen prefixes database: %v", err)
+		}
+		seenDB = db
+		defer func() { _ = seenDB.Close() }()
+	}
+	if f.StateDB != "" {
+		db, err := utils.OpenDiskTrie(f.StateDB)
+		if err != nil {
+			return fmt.Errorf("failed to open prefix state database: %v", err)
+		}
+		stateDB = db
+		defer func() { _ = stateDB.Close() }()
+	}
+
+	var wg sync.WaitGroup
+	// Background work stops before the deferred closes run
+	defer wg.Wait()
+	defer stop()
+
+	if rpki != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(30 * time.Minute)
+			defer ticker.Stop()
+			for {
+				if err := rpki.Sync(); err != nil {
+					log.Printf("Error during RPKI sync: %v", err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	var monitor *watchlist.Monitor
+	if f.Watchlist != "" {
+		list, err := watchlist.Load(f.Watchlist)
+		if err != nil {
+			return fmt.Errorf("failed to load watchlist: %v", err)
+		}
+		monitor = watchlist.NewMonitor(list, asnMapping, time.Now)
+	}
+	var router *alerting.Router
+	if f.AlertRules != "" {
+		r, err := alerting.NewRouter(f.AlertRules, asnMapping, time.Now)
+		if err != nil {
+			return fmt.Errorf("failed to load alert rules: %v", err)
+		}
+		router = r
+	}
+	var exporter *siem.Exporter
+	if f.SIEM != "" {
+		sc, err := siem.Load(f.SIEM)
+		if err != nil {
+			return fmt.Errorf("failed to load SIEM sinks: %v", err)
+		}
+		if exporter, err = siem.New(sc, asnMapping); err != nil {
+			return fmt.Errorf("failed to load SIEM sinks: %v", err)
+		}
+	}
+	var journalWriter *journal.Writer
+	if f.JournalDir != "" {
+		w, err := journal.OpenWriter(f.JournalDir)
+		if err != nil {
+			return fmt.Errorf("failed to open event journal: %v", err)
+		}
+		journalWriter = w
+		defer func() { _ = journalWriter.Close() }()
+	}
+
+	tracker := api.NewTracker(asnMapping, time.Now)
+	onEvent := tracker.OnEvent
+	onIncident := []api.IncidentCallback{logIncident}
+	var hub *eventstream.Hub
+	if f.StreamAddr != "" {
+		hub = eventstream.NewHub(time.Now)
+		onIncident = append(onIncident, hub.OnIncident)
+		onEvent = func(lat, lng float64, cc, city string, eventType bgp.EventType, classificationType bgp.ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*bgp.LeakDetail) {
+			tracker.OnEvent(lat, lng, cc, city, eventType, classificationType, prefix, asn, historicalASN, leakDetail...)
+			hub.OnEvent(lat, lng, cc, city, eventType, classificationType, prefix, asn, historicalASN, leakDetail...)
+		}
+	}
+	if router != nil {
+		onIncident = append(onIncident, router.OnIncident)
+		tracker.SetResolvedCallback(router.OnResolved)
+	}
+	tracker.SetIncidentCallback(func(ev api.CriticalEvent, opened bool) {
+		for _, fn := range onIncident {
+			fn(ev, opened)
+		}
+	})
+
+	processor := bgp.NewBGPProcessor(geo.GetIPCoords, seenDB, stateDB, asnMapping, rpki, prefixToIP, time.Now, onEvent)
+	var bogons *utils.FullBogonsWatcher
+	if f.FullBogons {
+		bogons = utils.NewFullBogonsWatcher(utils.FullBogonsPath, processor.SetFullBogons)
+		if err := bogons.Reload(); err != nil {
+			log.Printf("Warning: Failed to load full bogons (run bgp-cli fetch first): %v", err)
+		}
+	}
+	var onTransition []bgp.TransitionCallback
+	var onMessage []bgp.MessageCallback
+	if journalWriter != nil {
+		onTransition = append(onTransition, func(t *bgp.Transition) { journalWriter.Append(journal.NewEntry(t)) })
+	}
+	if hub != nil {
+		onTransition = append(onTransition, hub.OnTransition)
+	}
+	var ws *rislive.Server
+	if f.WSAddr != "" {
+		ws = rislive.NewServer(asnMapping)
+		onMessage = append(onMessage, ws.Publish)
+	}
+	if monitor != nil {
+		onTransition = append(onTransition, monitor.OnTransition)
+		onMessage = append(onMessage, monitor.OnMessage)
+	}
+	if router != nil {
+		onTransition = append(onTransition, router.OnTransition)
+	}
+	if exporter != nil {
+		onTransition = append(onTransition, exporter.OnTransition)
+	}
+	if len(onTransition) > 0 {
+		processor.SetTransitionCallback(func(t *bgp.Transition) {
+			for _, fn := range onTransition {
+				fn(t)
+			}
+		})
+	}
+	if len(onMessage) > 0 {
+		processor.SetMessageCallback(func(m *bgp.EnrichedMessage) {
+			for _, fn := range onMessage {
+				fn(m)
+			}
+		})
+	}
+	// Closed after the background work stops but before the databases, so
+	// pending state is flushed
+	defer func() {
+		log.Println("Flushing prefix state...")
+		processor.Close()
+	}()
+	if scenario == nil {
+		processor.Listen()
+	}
+
+	src := &api.LiveSource{Tracker: tracker, Processor: processor, Geo: geo}
+	src.Dropped = func() map[string]uint64 {
+		dropped := make(map[string]uint64)
+		if hub != nil {
+			dropped["event_stream"] = hub.Dropped()
+		}
+		if ws != nil {
+			dropped["websocket"] = ws.Dropped()
+		}
+		if exporter != nil {
+			dropped["siem"] = exporter.Dropped()
+		}
+		if journalWriter != nil {
+			dropped["journal"] = journalWriter.Dropped()
+		}
+		return dropped
+	}
+
+	errs := make(chan error, 3)
+	serve := func(name string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				log.Printf("Warning: %s stopped: %v", name, err)
+				errs <- err
+				stop()
+			}
+		}()
+	}
+	background := func(fn func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(ctx)
+		}()
+	}
+	if addr != "" {
+		serve("API server", func() error { return api.ListenAndServe(ctx, addr, src) })
+	}
+	if hub != nil {
+		serve("event stream server", func() error { return eventstream.ListenAndServe(ctx, f.StreamAddr, hub) })
+	}
+	if ws != nil {
+		serve("websocket server", func() error { return rislive.ListenAndServe(ctx, f.WSAddr, ws) })
+	}
+	if monitor != nil {
+		background(monitor.Run)
+	}
+	if router != nil {
+		background(router.Run)
+	}
+	if exporter != nil {
+		background(exporter.Run)
+	}
+	if bogons != nil {
+		background(bogons.Run)
+	}
+	if stateDB != nil || seenDB != nil {
+		background(bgp.NewStateMaintainer(stateDB, seenDB, f.StateTTL, time.Now).Run)
+	}
+	if f.LogInterval > 0 {
+		background(func(ctx context.Context) { logStats(ctx, src, f.LogInterval) })
+	}
+
+	if scenario != nil {
+		msgs := simulate.Generate(scenario)
+		background(func(ctx context.Context) {
+			err := simulate.Play(ctx, msgs, 1, func(_ time.Time, m *bgp.RISMessageData) { processor.Inject(m) })
+			if err == nil {
+				log.Printf("[SIMULATE] Scenario finished after %d messages", len(msgs))
+			}
+		})
+		log.Printf("Playing scenario %s until %s. Press Ctrl+C to stop.", f.Simulate, scenario.End().Local().Format(time.Kitchen))
+	} else {
+		log.Println("Processing the live stream. Press Ctrl+C to stop.")
+	}
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// logIncident writes incidents to the log as they open.
+func logIncident(ev api.CriticalEvent, opened bool) {
+	if !opened {
+		return
+	}
+	network := fmt.Sprintf("AS%d", ev.ASN)
+	if ev.Network != "" {
+		network += " (" + ev.Network + ")"
+	}
+	log.Printf("[INCIDENT] #%d %s: %s, %d IPs in %s", ev.ID, ev.Name, network, ev.ImpactedIPs, ev.Locations)
+}
+
+// logStats writes a summary of the processor's throughput and the classifier
+// state every interval.
+func logStats(ctx context.Context, src api.Source, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastMessages uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m := src.Metrics()
+		var messages uint64
+		var maxQueue int
+		if p := m.Processor; p != nil {
+			messages = p.Messages
+			if len(p.QueueDepths) > 0 {
+				maxQueue = slices.Max(p.QueueDepths)
+			}
+		}
+		var classified int
+		for key, n := range m.Classifications {
+			if key != bgp.ClassificationNone.Key() {
+				classified += n
+			}
+		}
+		var dropped uint64
+		for _, n := range m.Dropped {
+			dropped += n
+		}
+		log.Printf("[STATS] %.1f msgs/s, deepest queue %d, %d classified prefixes, %d open incidents, %d dropped",
+			float64(messages-lastMessages)/interval.Seconds(), maxQueue, classified, len(src.CriticalEvents()), dropped)
+		lastMessages = messages
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+type ServeCmd struct {
+	Addr          string `default:"localhost:8080" help:"Address to serve the JSON API on."`
+	PipelineFlags `embed:""`
+}
+
+func (c *ServeCmd) Run() error {
+	return runPipeline(c.Addr, &c.PipelineFlags)
+}
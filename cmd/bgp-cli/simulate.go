@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
+	"github.com/sudorandom/bgp-stream/pkg/simulate"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+type SimulateCmd struct {
+	Scenario string  `arg:"" type:"existingfile" help:"JSON scenario of networks, churn and incidents to generate updates for."`
+	Format   string  `default:"ris-json" enum:"ris-json,mrt,events" help:"What to write: RIS Live messages as JSON lines (ris-json), a gzipped MRT update file per collector (mrt), or the classification transitions of the processor (events)."`
+	Output   string  `default:"" help:"File for ris-json (default stdout) or directory for mrt (default ./simulation)."`
+	Speed    float64 `default:"60" help:"How many times faster than real time events plays the scenario. Must be positive."`
+	Start    string  `default:"" help:"Start the scenario at this time instead of its own (YYYY-MM-DD HH:mm, UTC, or now)."`
+}
+
+func (c *SimulateCmd) Run() error {
+	sc, err := simulate.Load(c.Scenario)
+	if err != nil {
+		return err
+	}
+	switch c.Start {
+	case "":
+	case "now":
+		sc.Start = time.Now().UTC().Truncate(time.Second)
+	default:
+		if sc.Start, err = time.Parse("2006-01-02 15:04", c.Start); err != nil {
+			return fmt.Errorf("invalid start time: %v", err)
+		}
+	}
+	msgs := simulate.Generate(sc)
+	log.Printf("[SIMULATE] Generated %d messages from %s to %s", len(msgs), sc.Start.Format(time.RFC3339), sc.End().Format(time.RFC3339))
+
+	switch c.Format {
+	case "mrt":
+		dir := c.Output
+		if dir == "" {
+			dir = "simulation"
+		}
+		paths, err := simulate.WriteMRT(dir, msgs)
+		if err != nil {
+			return fmt.Errorf("failed to write MRT files: %v", err)
+		}
+		for _, path := range paths {
+			log.Printf("[SIMULATE] Wrote %s", path)
+		}
+		return nil
+	case "events":
+		if c.Speed <= 0 {
+			return fmt.Errorf("speed must be positive for events: the processor classifies on a real-time ticker")
+		}
+		return c.runEvents(sc, msgs)
+	default:
+		if c.Output == "" || c.Output == "-" {
+			return simulate.WriteRISJSON(os.Stdout, msgs)
+		}
+		f, err := os.Create(c.Output)
+		if err != nil {
+			return err
+		}
+		if err := simulate.WriteRISJSON(f, msgs); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
+
+// runEvents plays the messages into a processor whose clock follows the
+// scenario and prints the transitions it reports. The seen prefixes and VRPs
+// come from the scenario's networks, so hijacks are RPKI invalid.
+func (c *SimulateCmd) runEvents(sc *simulate.Scenario, msgs []*bgp.RISMessageData) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dir, err := os.MkdirTemp("", "bgp-simulate")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	seenDB, err := utils.OpenDiskTrie(filepath.Join(dir, "seen-prefixes.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open seen prefixes database: %v", err)
+	}
+	defer func() { _ = seenDB.Close() }()
+	origins := make(map[string][]byte)
+	for prefix, asn := range sc.Origins() {
+		origins[prefix] = binary.BigEndian.AppendUint32(nil, asn)
+	}
+	if err := seenDB.BatchInsert(origins); err != nil {
+		return fmt.Errorf("failed to store origins: %v", err)
+	}
+	rpki, err := utils.NewRPKIManager(filepath.Join(dir, "rpki-vrps.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open VRP database: %v", err)
+	}
+	defer func() { _ = rpki.Close() }()
+	if err := rpki.Load(sc.ROAs()); err != nil {
+		return fmt.Errorf("failed to store ROAs: %v", err)
+	}
+
+	asnMapping := utils.NewASNMapping()
+	if err := asnMapping.Load(); err != nil {
+		log.Printf("Warning: failed to load ASN names: %v", err)
+	}
+	geo := geoservice.NewGeoService(3840, 2160, 760.0)
+	if err := geo.OpenHintDBs("data", true); err != nil {
+		log.Printf("Warning: failed to open hint databases: %v", err)
+	}
+	defer func() { _ = geo.Close() }()
+	geoservice.NewDataManager(geo).LoadWorldCities()
+
+	var clock atomic.Int64
+	clock.Store(sc.Start.UnixNano())
+	now := func() time.Time { return time.Unix(0, clock.Load()).UTC() }
+
+	var mu sync.Mutex
+	var entries []*journal.Entry
+	processor := bgp.NewBGPProcessor(geo.GetIPCoords, seenDB, nil, asnMapping, rpki, prefixToIP, now, func(float64, float64, string, string, bgp.EventType, bgp.ClassificationType, string, uint32, uint32, ...*bgp.LeakDetail) {
+	})
+	processor.SetTransitionCallback(func(t *bgp.Transition) {
+		mu.Lock()
+		entries = append(entries, journal.NewEntry(t))
+		mu.Unlock()
+	})
+
+	log.Printf("[SIMULATE] Playing %s at %gx...", filepath.Base(c.Scenario), c.Speed)
+	err = simulate.Play(ctx, msgs, c.Speed, func(t time.Time, m *bgp.RISMessageData) {
+		clock.Store(t.UnixNano())
+		processor.Inject(m)
+	})
+	processor.Drain()
+	processor.Close()
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	write, flush := newEventWriter("table")
+	for _, e := range entries {
+		if err := write(e); err != nil {
+			return err
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	counts := make(map[bgp.ClassificationType]int)
+	for _, e := range entries {
+		counts[e.Classification()]++
+	}
+	types := make([]bgp.ClassificationType, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	fmt.Println()
+	for _, t := range types {
+		fmt.Printf("%s: %d\n", t, counts[t])
+	}
+	return nil
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
@@ -11,7 +12,11 @@ import (
 
 	"github.com/hajimehoshi/ebiten/v2"
 	_ "github.com/silbinarywolf/preferdiscretegpu"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
 	"github.com/sudorandom/bgp-stream/pkg/bgpengine"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
+	"github.com/sudorandom/bgp-stream/pkg/simulate"
+	"github.com/sudorandom/bgp-stream/pkg/tsdb"
 )
 
 type multiFlag []string
@@ -42,6 +47,17 @@ var (
 	videoPath          *string = flag.String("video", "", "Path to save recorded video (implies -hide-ui and -tps 30)")
 	videoDelay                 = flag.Duration("video-delay", 8*time.Second, "Delay before starting video recording")
 	audioDir           *string = flag.String("audio-dir", "", "Directory containing MP3 files for background music")
+	fullBogons                 = flag.Bool("full-bogons", false, "Also flag prefixes and ASNs from unallocated space (requires bgp-cli fetch)")
+	stateTTL                   = flag.Duration("state-ttl", bgp.DefaultStateTTL, "Delete prefix state after this long without updates (0 to keep forever)")
+	journalDir         *string = flag.String("journal-dir", journal.DefaultDir, "Directory for the event journal (empty to disable)")
+	metricsDB          *string = flag.String("metrics-db", tsdb.DefaultPath, "Path to the metric history database used by the trendline ranges (empty to disable)")
+	apiAddr            *string = flag.String("api-addr", "", "Address to serve the read-only JSON API on, e.g. localhost:8080 (empty to disable)")
+	streamAddr         *string = flag.String("stream-addr", "", "Address to serve the gRPC/Connect event stream on, e.g. localhost:8081 (empty to disable)")
+	watchlistPath      *string = flag.String("watchlist", "", "Watchlist file of prefixes and ASNs to alert on (empty to disable)")
+	alertRulesPath     *string = flag.String("alert-rules", "", "Alert rules file routing critical events to webhooks, syslog and files; reloaded when it changes (empty to disable)")
+	siemPath           *string = flag.String("siem", "", "SIEM sink configuration for exporting classified events over syslog or to files (empty to disable)")
+	wsAddr             *string = flag.String("ws-addr", "", "Address to re-broadcast enriched RIS Live messages on over a websocket, e.g. localhost:8082 (empty to disable)")
+	simulatePath       *string = flag.String("simulate", "", "Play a simulation scenario in real time, starting now, instead of the live stream (empty to disable)")
 	mmdbFiles          multiFlag
 )
 
@@ -56,9 +72,18 @@ func main() {
 		*tpsFlag = 30 // Ensure consistent FPS for recording
 	}
 
+	var scenario *simulate.Scenario
+	if *simulatePath != "" {
+		sc, err := simulate.Load(*simulatePath)
+		if err != nil {
+			log.Fatalf("Fatal: %v", err)
+		}
+		scenario = sc
+	}
+
 	engine := initEngine()
 
-	startBackgroundTasks(engine)
+	startBackgroundTasks(engine, scenario)
 	setupSignalHandler(engine)
 	runWindowLoop(engine)
 }
@@ -73,6 +98,16 @@ func initEngine() *bgpengine.Engine {
 	engine.VideoStartDelay = *videoDelay
 	engine.MMDBFiles = mmdbFiles
 	engine.AudioDir = *audioDir
+	engine.FullBogons = *fullBogons
+	engine.StateTTL = *stateTTL
+	engine.JournalDir = *journalDir
+	engine.MetricsDB = *metricsDB
+	engine.APIAddr = *apiAddr
+	engine.StreamAddr = *streamAddr
+	engine.WSAddr = *wsAddr
+	engine.WatchlistPath = *watchlistPath
+	engine.AlertRulesPath = *alertRulesPath
+	engine.SIEMPath = *siemPath
 
 	// Initialize video writer if requested
 	if engine.VideoPath != "" {
@@ -97,7 +132,7 @@ func initEngine() *bgpengine.Engine {
 	return engine
 }
 
-func startBackgroundTasks(engine *bgpengine.Engine) {
+func startBackgroundTasks(engine *bgpengine.Engine, scenario *simulate.Scenario) {
 	// Start all data loading in the background
 	go func() {
 		// 1. Generate initial map background
@@ -111,8 +146,12 @@ func startBackgroundTasks(engine *bgpengine.Engine) {
 			os.Exit(1)
 		}
 
-		if engine.GetProcessor() != nil {
-			go engine.GetProcessor().Listen()
+		if processor := engine.GetProcessor(); processor != nil {
+			if scenario != nil {
+				go playScenario(processor, scenario)
+			} else {
+				go processor.Listen()
+			}
 		}
 		if engine.GetAudioPlayer() != nil {
 			go engine.GetAudioPlayer().Start()
@@ -124,6 +163,20 @@ func startBackgroundTasks(engine *bgpengine.Engine) {
 	go engine.StartMemoryWatcher()
 }
 
+// playScenario feeds the updates of a scenario to the processor at their
+// times, starting now rather than when the scenario was loaded.
+func playScenario(processor *bgp.BGPProcessor, scenario *simulate.Scenario) {
+	scenario.Start = time.Now().UTC()
+	msgs := simulate.Generate(scenario)
+	log.Printf("[SIMULATE] Playing %d messages until %s", len(msgs), scenario.End().Local().Format(time.Kitchen))
+	err := simulate.Play(context.Background(), msgs, 1, func(_ time.Time, m *bgp.RISMessageData) { processor.Inject(m) })
+	if err != nil {
+		log.Printf("Warning: simulation stopped: %v", err)
+		return
+	}
+	log.Println("[SIMULATE] Scenario finished")
+}
+
 func setupSignalHandler(engine *bgpengine.Engine) {
 	// Handle graceful shutdown for audio fade-out
 	sigChan := make(chan os.Signal, 1)
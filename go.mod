@@ -3,6 +3,7 @@ module github.com/sudorandom/bgp-stream
 go 1.25.0
 
 require (
+	connectrpc.com/connect v1.19.1
 	github.com/alecthomas/kong v1.14.0
 	github.com/biter777/countries v1.7.5
 	github.com/cloudflare/ahocorasick v0.0.0-20240916140611-054963ec9396
@@ -14,6 +15,7 @@ require (
 	github.com/oschwald/maxminddb-golang v1.13.1
 	github.com/osrg/gobgp/v3 v3.37.0
 	github.com/paulmach/go.geojson v1.5.0
+	github.com/prometheus/client_golang v1.16.0
 	github.com/silbinarywolf/preferdiscretegpu v1.0.0
 	google.golang.org/protobuf v1.36.11
 )
@@ -146,7 +148,6 @@ require (
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/polyfloyd/go-errorlint v1.7.1 // indirect
-	github.com/prometheus/client_golang v1.16.0 // indirect
 	github.com/prometheus/client_model v0.3.0 // indirect
 	github.com/prometheus/common v0.42.0 // indirect
 	github.com/prometheus/procfs v0.10.1 // indirect
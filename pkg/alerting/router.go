@@ -0,0 +1,440 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+const (
+	// queueSize bounds the notifications waiting for the outputs
+	queueSize = 256
+	// flushInterval is how often pending notifications are checked
+	flushInterval = time.Second
+	// reloadInterval is how often the rules file is checked for changes
+	reloadInterval = 5 * time.Second
+	// staleAfter forgets events that never resolved, so that they can
+	// notify again
+	staleAfter = 24 * time.Hour
+)
+
+// Event is an incident or classified prefix as the rules see it.
+type Event struct {
+	// Key identifies the event across updates
+	Key            string    `json:"key"`
+	IncidentID     uint64    `json:"incident_id,omitempty"`
+	Time           time.Time `json:"time"`
+	Classification string    `json:"classification"`
+	Name           string    `json:"name"`
+	Severity       Severity  `json:"severity"`
+	ASN            uint32    `json:"asn,omitempty"`
+	Network        string    `json:"network,omitempty"`
+	OrgID          string    `json:"org_id,omitempty"`
+	Leak           *api.Leak `json:"leak,omitempty"`
+	Locations      string    `json:"locations,omitempty"`
+	Countries      []string  `json:"countries,omitempty"`
+	ImpactedIPs    uint64    `json:"impacted_ips"`
+	Prefixes       []string  `json:"prefixes"`
+}
+
+// Status tells whether a notification is about new or finished events.
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Notification is sent to the outputs as the JSON payload of a notify.Message.
+type Notification struct {
+	Time    time.Time `json:"time"`
+	Rule    string    `json:"rule"`
+	Status  Status    `json:"status"`
+	Events  []Event   `json:"events"`
+	Summary string    `json:"summary"`
+}
+
+// ruleState is what a rule has sent and is about to send.
+type ruleState struct {
+	rule *Rule
+	// notified holds the keys of the events the rule has taken, by the time
+	// they were taken
+	notified     map[string]time.Time
+	firing       []Event
+	resolved     []Event
+	pendingSince time.Time
+	lastSent     time.Time
+}
+
+// Router matches events against the rules of a rules file and sends grouped,
+// throttled notifications to the rules' outputs. The file is reloaded while
+// Run is active whenever it changes. OnIncident, OnResolved and OnTransition
+// may be called from any goroutine.
+type Router struct {
+	path       string
+	asnMapping *utils.ASNMapping
+	now        bgp.TimeProvider
+	dispatcher *notify.Dispatcher
+
+	mu      sync.Mutex
+	rules   []*ruleState
+	modTime time.Time
+	size    int64
+
+	// onNotify replaces delivery in tests
+	onNotify func(n *Notification)
+}
+
+// NewRouter loads the rules file at path. asnMapping may be nil, in which case
+// events from transitions don't name networks.
+func NewRouter(path string, asnMapping *utils.ASNMapping, now bgp.TimeProvider) (*Router, error) {
+	r := &Router{
+		path:       path,
+		asnMapping: asnMapping,
+		now:        now,
+		dispatcher: notify.NewDispatcher(nil, queueSize),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run sends notifications and watches the rules file until ctx is canceled.
+func (r *Router) Run(ctx context.Context) {
+	go r.dispatcher.Run(ctx)
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	reload := time.NewTicker(reloadInterval)
+	defer reload.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			r.flush(r.now())
+		case <-reload.C:
+			if err := r.reload(); err != nil {
+				log.Printf("Warning: Failed to reload alert rules, keeping the previous ones: %v", err)
+			}
+		}
+	}
+}
+
+// reload applies the rules file if it changed since it was last read. Rules
+// that keep their name keep their state.
+func (r *Router) reload() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	unchanged := fi.ModTime().Equal(r.modTime) && fi.Size() == r.size
+	r.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	rules, err := Load(r.path)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Remember the version even if it is broken, so it is reported once
+	r.modTime, r.size = fi.ModTime(), fi.Size()
+	if err != nil {
+		return err
+	}
+	states := make([]*ruleState, len(rules.rules))
+	for i, rule := range rules.rules {
+		states[i] = &ruleState{rule: rule, notified: make(map[string]time.Time)}
+		for _, old := range r.rules {
+			if old.rule.Name == rule.Name {
+				old.rule = rule
+				states[i] = old
+				break
+			}
+		}
+	}
+	r.rules = states
+	log.Printf("[ALERT] Loaded %d rules from %s", len(states), r.path)
+	return nil
+}
+
+// OnIncident matches an incident that opened or grew. It has the signature of
+// an api.IncidentCallback.
+func (r *Router) OnIncident(ev api.CriticalEvent, _ bool) {
+	r.fire(incidentEvent(&ev))
+}
+
+// OnResolved resolves an incident that expired. It has the signature of an
+// api.ResolvedCallback.
+func (r *Router) OnResolved(ev api.CriticalEvent) {
+	r.resolve(incidentEvent(&ev))
+}
+
+// OnTransition matches the classifications that don't form incidents, such as
+// flaps and DDoS mitigations, one prefix at a time. Incidents are expected to
+// come from an api.Tracker through OnIncident.
+func (r *Router) OnTransition(t *bgp.Transition) {
+	if t.From == t.To {
+		return
+	}
+	if transitionClass(t.From) {
+		ev := r.transitionEvent(t, t.From)
+		r.resolve(ev)
+	}
+	if transitionClass(t.To) {
+		r.fire(r.transitionEvent(t, t.To))
+	}
+}
+
+// transitionClass reports whether events of class are taken from transitions
+// rather than incidents.
+func transitionClass(class bgp.ClassificationType) bool {
+	p := class.Priority()
+	return p < 3 && (p > 0 || class.IsCritical())
+}
+
+func incidentEvent(ev *api.CriticalEvent) Event {
+	class, _ := bgp.ParseClassificationKey(ev.Classification)
+	return Event{
+		Key:            "incident:" + strconv.FormatUint(ev.ID, 10),
+		IncidentID:     ev.ID,
+		Time:           ev.Time,
+		Classification: ev.Classification,
+		Name:           ev.Name,
+		Severity:       SeverityOf(class),
+		ASN:            ev.ASN,
+		Network:        ev.Network,
+		OrgID:          ev.OrgID,
+		Leak:           ev.Leak,
+		Locations:      ev.Locations,
+		Countries:      countries(ev.Locations),
+		ImpactedIPs:    ev.ImpactedIPs,
+		Prefixes:       ev.Prefixes,
+	}
+}
+
+func (r *Router) transitionEvent(t *bgp.Transition, class bgp.ClassificationType) Event {
+	asn := t.OriginASN
+	if asn == 0 {
+		asn = t.HistoricalASN
+	}
+	ev := Event{
+		Key:            "prefix:" + t.Prefix + "|" + class.Key(),
+		Time:           t.Time,
+		Classification: class.Key(),
+		Name:           class.String(),
+		Severity:       SeverityOf(class),
+		ASN:            asn,
+		Network:        api.NetworkName(r.asnMapping, asn),
+		ImpactedIPs:    utils.GetPrefixSize(t.Prefix),
+		Prefixes:       []string{t.Prefix},
+	}
+	if r.asnMapping != nil {
+		ev.OrgID = r.asnMapping.GetOrgID(asn)
+	}
+	if t.LeakDetail != nil && class == t.To {
+		ev.Leak = api.NewLeak(t.LeakDetail)
+	}
+	if t.Country != "" {
+		ev.Countries = []string{t.Country}
+		ev.Locations = t.Country
+		if t.City != "" {
+			ev.Locations = t.City + ", " + t.Country
+		}
+	}
+	return ev
+}
+
+// countries extracts the country codes from incident locations such as
+// "Dallas, US | DE".
+func countries(locations string) []string {
+	var ccs []string
+	for loc := range strings.SplitSeq(locations, " | ") {
+		if i := strings.LastIndex(loc, ", "); i >= 0 {
+			loc = loc[i+2:]
+		}
+		if loc != "" && !slices.Contains(ccs, loc) {
+			ccs = append(ccs, loc)
+		}
+	}
+	return ccs
+}
+
+// fire queues ev for every rule it matches that hasn't taken it yet. Rules
+// that already took it only refresh a notification that is still pending.
+func (r *Router) fire(ev Event) {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rs := range r.rules {
+		if _, ok := rs.notified[ev.Key]; ok {
+			if i := indexOf(rs.firing, ev.Key); i >= 0 {
+				rs.firing[i] = ev
+			}
+			continue
+		}
+		if !rs.rule.Matches(&ev) {
+			continue
+		}
+		rs.notified[ev.Key] = now
+		rs.firing = append(rs.firing, ev)
+		if rs.pendingSince.IsZero() {
+			rs.pendingSince = now
+		}
+	}
+}
+
+// resolve tells the rules that took ev that it is over. An event that ends
+// before its notification went out is dropped instead.
+func (r *Router) resolve(ev Event) {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rs := range r.rules {
+		if _, ok := rs.notified[ev.Key]; !ok {
+			continue
+		}
+		delete(rs.notified, ev.Key)
+		if i := indexOf(rs.firing, ev.Key); i >= 0 {
+			rs.firing = slices.Delete(rs.firing, i, i+1)
+			if len(rs.firing) == 0 && len(rs.resolved) == 0 {
+				rs.pendingSince = time.Time{}
+			}
+			continue
+		}
+		if !rs.rule.NotifyResolve {
+			continue
+		}
+		rs.resolved = append(rs.resolved, ev)
+		if rs.pendingSince.IsZero() {
+			rs.pendingSince = now
+		}
+	}
+}
+
+func indexOf(events []Event, key string) int {
+	return slices.IndexFunc(events, func(ev Event) bool { return ev.Key == key })
+}
+
+// flush sends the notifications whose group window and throttle have passed.
+func (r *Router) flush(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rs := range r.rules {
+		for key, t := range rs.notified {
+			if now.Sub(t) >= staleAfter {
+				delete(rs.notified, key)
+			}
+		}
+		if rs.pendingSince.IsZero() {
+			continue
+		}
+		due := rs.pendingSince.Add(rs.rule.GroupWindow)
+		if next := rs.lastSent.Add(rs.rule.Throttle); next.After(due) {
+			due = next
+		}
+		if now.Before(due) {
+			continue
+		}
+		if len(rs.firing) > 0 {
+			r.send(rs.rule, &Notification{Time: now.UTC(), Rule: rs.rule.Name, Status: StatusFiring, Events: rs.firing})
+		}
+		if len(rs.resolved) > 0 {
+			r.send(rs.rule, &Notification{Time: now.UTC(), Rule: rs.rule.Name, Status: StatusResolved, Events: rs.resolved})
+		}
+		rs.firing, rs.resolved = nil, nil
+		rs.pendingSince = time.Time{}
+		rs.lastSent = now
+	}
+}
+
+func (r *Router) send(rule *Rule, n *Notification) {
+	n.Summary = r.subject(n)
+	log.Printf("[ALERT] %s", n.Summary)
+	if r.onNotify != nil {
+		r.onNotify(n)
+		return
+	}
+	r.dispatcher.SendTo(rule.outputs, &notify.Message{Subject: n.Summary, Text: r.text(n), Payload: n})
+}
+
+// subject summarizes a notification on one line.
+func (r *Router) subject(n *Notification) string {
+	prefix := "[" + n.Rule + "] "
+	if n.Status == StatusResolved {
+		prefix += "Resolved: "
+	}
+	if len(n.Events) == 1 {
+		return prefix + r.summary(&n.Events[0])
+	}
+	counts := make(map[string]int)
+	var names []string
+	for _, ev := range n.Events {
+		if counts[ev.Name] == 0 {
+			names = append(names, ev.Name)
+		}
+		counts[ev.Name]++
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s (%d)", name, counts[name])
+	}
+	return prefix + fmt.Sprintf("%d events: ", len(n.Events)) + strings.Join(parts, ", ")
+}
+
+// text lists the events of a notification, one per line.
+func (r *Router) text(n *Notification) string {
+	lines := make([]string, len(n.Events))
+	for i := range n.Events {
+		ev := &n.Events[i]
+		lines[i] = ev.Time.UTC().Format(time.RFC3339) + " " + r.summary(ev)
+		if ev.Locations != "" {
+			lines[i] += " in " + ev.Locations
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// summary describes an event, for example "Route Leak by AS200 (Example) at
+// the expense of AS300 (Victim), 2 prefixes, 512 IPs".
+func (r *Router) summary(ev *Event) string {
+	s := ev.Name
+	switch {
+	case ev.Leak != nil && ev.Leak.LeakerASN != 0:
+		s += " by " + r.network(ev.Leak.LeakerASN)
+		if ev.Leak.VictimASN != 0 {
+			s += " at the expense of " + r.network(ev.Leak.VictimASN)
+		}
+	case ev.ASN != 0:
+		s += " affecting AS" + strconv.FormatUint(uint64(ev.ASN), 10)
+		if ev.Network != "" {
+			s += " (" + ev.Network + ")"
+		}
+	}
+	if len(ev.Prefixes) == 1 {
+		s += ", " + ev.Prefixes[0]
+	} else {
+		s += fmt.Sprintf(", %d prefixes", len(ev.Prefixes))
+	}
+	return s + fmt.Sprintf(", %d IPs", ev.ImpactedIPs)
+}
+
+func (r *Router) network(asn uint32) string {
+	s := "AS" + strconv.FormatUint(uint64(asn), 10)
+	if name := api.NetworkName(r.asnMapping, asn); name != "" {
+		s += " (" + name + ")"
+	}
+	return s
+}
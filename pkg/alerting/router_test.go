@@ -0,0 +1,176 @@
+package alerting
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time { return c.t }
+
+func writeRules(t *testing.T, path string, rules ...RuleConfig) {
+	t.Helper()
+	b, err := json.Marshal(Config{Outputs: testOutputs, Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestRouter(t *testing.T, rules ...RuleConfig) (*Router, *testClock, *[]*Notification) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, rules...)
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	r, err := NewRouter(path, nil, clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []*Notification
+	r.onNotify = func(n *Notification) { sent = append(sent, n) }
+	return r, clock, &sent
+}
+
+func TestRouter_GroupAndThrottle(t *testing.T) {
+	r, clock, sent := newTestRouter(t, RuleConfig{
+		Name:            "leaks",
+		Classifications: []string{"route_leak"},
+		GroupWindow:     "1m",
+		Throttle:        "10m",
+		NotifyResolve:   true,
+		Outputs:         []string{"archive"},
+	})
+	leak := api.CriticalEvent{ID: 1, Time: clock.t, Classification: "route_leak", Name: "Route Leak", Leak: &api.Leak{LeakerASN: 200, VictimASN: 300}, Locations: "Dallas, US", ImpactedIPs: 256, Prefixes: []string{"1.2.3.0/24"}}
+	r.OnIncident(leak, true)
+	r.OnIncident(api.CriticalEvent{ID: 2, Time: clock.t, Classification: "outage", Name: "Outage", ASN: 100}, true)
+
+	clock.t = clock.t.Add(30 * time.Second)
+	// An update replaces the pending copy instead of notifying twice
+	leak.Prefixes = append(leak.Prefixes, "1.2.4.0/24")
+	leak.ImpactedIPs = 512
+	r.OnIncident(leak, false)
+	r.OnIncident(api.CriticalEvent{ID: 3, Time: clock.t, Classification: "route_leak", Name: "Route Leak", ASN: 400, Prefixes: []string{"5.6.7.0/24"}}, true)
+	r.flush(clock.t)
+	if len(*sent) != 0 {
+		t.Fatalf("expected the group window to hold notifications, got %+v", *sent)
+	}
+
+	clock.t = clock.t.Add(30 * time.Second)
+	r.flush(clock.t)
+	if len(*sent) != 1 {
+		t.Fatalf("expected one grouped notification, got %+v", *sent)
+	}
+	n := (*sent)[0]
+	if n.Status != StatusFiring || len(n.Events) != 2 || len(n.Events[0].Prefixes) != 2 || n.Events[0].Countries[0] != "US" || n.Summary != "[leaks] 2 events: Route Leak (2)" {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	// Within the throttle, resolutions and new incidents wait
+	clock.t = clock.t.Add(time.Minute)
+	r.OnResolved(leak)
+	r.OnIncident(api.CriticalEvent{ID: 4, Time: clock.t, Classification: "route_leak", Name: "Route Leak", ASN: 500, Prefixes: []string{"9.9.9.0/24"}}, true)
+	r.flush(clock.t.Add(5 * time.Minute))
+	if len(*sent) != 1 {
+		t.Fatalf("expected the throttle to hold notifications, got %+v", *sent)
+	}
+	r.flush(clock.t.Add(9 * time.Minute))
+	if len(*sent) != 3 {
+		t.Fatalf("expected firing and resolved notifications, got %+v", *sent)
+	}
+	if n := (*sent)[1]; n.Status != StatusFiring || n.Events[0].IncidentID != 4 {
+		t.Errorf("unexpected firing notification: %+v", n)
+	}
+	if n := (*sent)[2]; n.Status != StatusResolved || n.Events[0].IncidentID != 1 || !strings.HasPrefix(n.Summary, "[leaks] Resolved: Route Leak by AS200 at the expense of AS300") {
+		t.Errorf("unexpected resolved notification: %+v", n)
+	}
+}
+
+func TestRouter_ResolvedBeforeSent(t *testing.T) {
+	r, clock, sent := newTestRouter(t, RuleConfig{Name: "all", GroupWindow: "1m", NotifyResolve: true, Outputs: []string{"archive"}})
+	ev := api.CriticalEvent{ID: 1, Time: clock.t, Classification: "outage", Name: "Outage", ASN: 100}
+	r.OnIncident(ev, true)
+	r.OnResolved(ev)
+	r.flush(clock.t.Add(time.Hour))
+	if len(*sent) != 0 {
+		t.Errorf("expected nothing to be sent, got %+v", *sent)
+	}
+}
+
+func TestRouter_Transitions(t *testing.T) {
+	r, clock, sent := newTestRouter(t, RuleConfig{Name: "ddos", Classifications: []string{"ddos_mitigation"}, NotifyResolve: true, Outputs: []string{"archive"}})
+	tr := &bgp.Transition{
+		Time:       clock.t,
+		Prefix:     "1.2.3.4/32",
+		From:       bgp.ClassificationNone,
+		To:         bgp.ClassificationDDoSMitigation,
+		OriginASN:  100,
+		Country:    "NL",
+		LeakDetail: &bgp.LeakDetail{LeakerASN: 200, VictimASN: 100},
+	}
+	r.OnTransition(tr)
+	// Incident classifications are left to the tracker
+	r.OnTransition(&bgp.Transition{Time: clock.t, Prefix: "5.6.0.0/16", To: bgp.ClassificationOutage})
+	r.flush(clock.t)
+	if len(*sent) != 1 {
+		t.Fatalf("expected one notification, got %+v", *sent)
+	}
+	if ev := (*sent)[0].Events[0]; ev.Key != "prefix:1.2.3.4/32|ddos_mitigation" || ev.Leak == nil || ev.Countries[0] != "NL" || ev.ImpactedIPs != 1 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	r.OnTransition(&bgp.Transition{Time: clock.t, Prefix: "1.2.3.4/32", From: bgp.ClassificationDDoSMitigation, To: bgp.ClassificationNone, OriginASN: 100})
+	r.flush(clock.t)
+	if len(*sent) != 2 || (*sent)[1].Status != StatusResolved {
+		t.Errorf("expected a resolved notification, got %+v", *sent)
+	}
+}
+
+func TestRouter_Reload(t *testing.T) {
+	r, clock, sent := newTestRouter(t, RuleConfig{Name: "outages", Classifications: []string{"outage"}, Outputs: []string{"archive"}})
+	r.OnIncident(api.CriticalEvent{ID: 1, Time: clock.t, Classification: "outage", Name: "Outage", ASN: 100}, true)
+	r.flush(clock.t)
+
+	// The rule keeps its state across the reload, so the incident isn't sent
+	// again, and the new rule picks up leaks
+	writeRules(t, r.path,
+		RuleConfig{Name: "outages", Classifications: []string{"outage"}, Throttle: "1h", Outputs: []string{"archive"}},
+		RuleConfig{Name: "leaks", Classifications: []string{"route_leak"}, Outputs: []string{"archive"}},
+	)
+	if err := os.Chtimes(r.path, clock.t, clock.t.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err != nil {
+		t.Fatal(err)
+	}
+	r.OnIncident(api.CriticalEvent{ID: 1, Time: clock.t, Classification: "outage", Name: "Outage", ASN: 100, Prefixes: []string{"10.0.0.0/16"}}, false)
+	r.OnIncident(api.CriticalEvent{ID: 2, Time: clock.t, Classification: "route_leak", Name: "Route Leak", ASN: 200}, true)
+	r.flush(clock.t)
+	if len(*sent) != 2 || (*sent)[1].Rule != "leaks" {
+		t.Fatalf("unexpected notifications after reload: %+v", *sent)
+	}
+
+	// A broken file keeps the previous rules
+	if err := os.WriteFile(r.path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(r.path, clock.t, clock.t.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.reload(); err == nil {
+		t.Error("expected the broken file to be reported")
+	}
+	if len(r.rules) != 2 {
+		t.Errorf("expected the previous rules to stay, got %d", len(r.rules))
+	}
+}
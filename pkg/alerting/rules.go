@@ -0,0 +1,274 @@
+// Package alerting routes critical events to outputs such as webhooks, syslog
+// and files according to a set of rules. Each rule decides which events it
+// cares about and how often it may notify, and the rules file is reloaded
+// when it changes.
+package alerting
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+)
+
+// Config is the rules file.
+type Config struct {
+	// Outputs are the named destinations rules can send to. Every output needs
+	// a unique name.
+	Outputs []notify.Config `json:"outputs"`
+	Rules   []RuleConfig    `json:"rules"`
+}
+
+// RuleConfig is a rule as written in the rules file. Every match field that is
+// set must match; empty fields match anything.
+type RuleConfig struct {
+	Name string `json:"name"`
+
+	// Classifications are classification keys such as route_leak or
+	// bgp_hijack
+	Classifications []string `json:"classifications,omitempty"`
+	// MinSeverity is the lowest severity tier the rule matches
+	MinSeverity Severity `json:"min_severity,omitempty"`
+	// ASNs match the origin, leaker or victim of an event
+	ASNs []uint32 `json:"asns,omitempty"`
+	// Orgs are organization IDs from the ASN data
+	Orgs      []string `json:"orgs,omitempty"`
+	Countries []string `json:"countries,omitempty"`
+	// MinPrefixLen and MaxPrefixLen bound the length of at least one of the
+	// event's prefixes
+	MinPrefixLen   int    `json:"min_prefix_len,omitempty"`
+	MaxPrefixLen   int    `json:"max_prefix_len,omitempty"`
+	MinImpactedIPs uint64 `json:"min_impacted_ips,omitempty"`
+
+	// Throttle is the minimum time between two notifications of the rule, as a
+	// Go duration. Events that match in between are sent together once it
+	// has passed.
+	Throttle string `json:"throttle,omitempty"`
+	// GroupWindow is how long the rule collects events after the first one
+	// before notifying, as a Go duration.
+	GroupWindow string `json:"group_window,omitempty"`
+	// NotifyResolve sends a notification when an event the rule notified
+	// about is over.
+	NotifyResolve bool `json:"notify_resolve,omitempty"`
+
+	Outputs []string `json:"outputs"`
+}
+
+// Severity is a tier of classifications, following the colors of the viewer's
+// legend.
+type Severity string
+
+const (
+	SeverityNormal   Severity = "normal"
+	SeverityPolicy   Severity = "policy"
+	SeverityBad      Severity = "bad"
+	SeverityCritical Severity = "critical"
+)
+
+// severities are indexed by bgp.ClassificationType.Priority.
+var severities = []Severity{SeverityNormal, SeverityPolicy, SeverityBad, SeverityCritical}
+
+// SeverityOf returns the severity tier of a classification.
+func SeverityOf(t bgp.ClassificationType) Severity {
+	return severities[t.Priority()]
+}
+
+func (s Severity) rank() int {
+	return slices.Index(severities, s)
+}
+
+// Rule is a parsed RuleConfig.
+type Rule struct {
+	Name          string
+	Throttle      time.Duration
+	GroupWindow   time.Duration
+	NotifyResolve bool
+
+	classifications map[string]struct{}
+	minSeverity     int
+	asns            map[uint32]struct{}
+	orgs            map[string]struct{}
+	countries       map[string]struct{}
+	minPrefixLen    int
+	maxPrefixLen    int
+	minImpactedIPs  uint64
+	outputs         []notify.Notifier
+}
+
+// Rules is a parsed Config.
+type Rules struct {
+	rules []*Rule
+}
+
+// Load reads a rules file.
+func Load(path string) (*Rules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return New(&c)
+}
+
+// New validates c and creates its outputs.
+func New(c *Config) (*Rules, error) {
+	outputs := make(map[string]notify.Notifier)
+	for _, oc := range c.Outputs {
+		n, err := notify.New(oc)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := outputs[n.Name()]; ok {
+			return nil, fmt.Errorf("duplicate output %q", n.Name())
+		}
+		outputs[n.Name()] = n
+	}
+
+	rs := &Rules{}
+	names := make(map[string]struct{})
+	for i, rc := range c.Rules {
+		if rc.Name == "" {
+			return nil, fmt.Errorf("rules[%d]: name is required", i)
+		}
+		if _, ok := names[rc.Name]; ok {
+			return nil, fmt.Errorf("duplicate rule %q", rc.Name)
+		}
+		names[rc.Name] = struct{}{}
+		r, err := newRule(&rc, outputs)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", rc.Name, err)
+		}
+		rs.rules = append(rs.rules, r)
+	}
+	return rs, nil
+}
+
+func newRule(rc *RuleConfig, outputs map[string]notify.Notifier) (*Rule, error) {
+	r := &Rule{
+		Name:           rc.Name,
+		NotifyResolve:  rc.NotifyResolve,
+		minPrefixLen:   rc.MinPrefixLen,
+		maxPrefixLen:   rc.MaxPrefixLen,
+		minImpactedIPs: rc.MinImpactedIPs,
+	}
+	if len(rc.Classifications) > 0 {
+		r.classifications = make(map[string]struct{})
+		for _, key := range rc.Classifications {
+			if _, ok := bgp.ParseClassificationKey(key); !ok {
+				return nil, fmt.Errorf("unknown classification %q", key)
+			}
+			r.classifications[key] = struct{}{}
+		}
+	}
+	if rc.MinSeverity != "" {
+		if r.minSeverity = rc.MinSeverity.rank(); r.minSeverity < 0 {
+			return nil, fmt.Errorf("unknown severity %q", rc.MinSeverity)
+		}
+	}
+	if len(rc.ASNs) > 0 {
+		r.asns = make(map[uint32]struct{})
+		for _, asn := range rc.ASNs {
+			r.asns[asn] = struct{}{}
+		}
+	}
+	if len(rc.Orgs) > 0 {
+		r.orgs = make(map[string]struct{})
+		for _, org := range rc.Orgs {
+			r.orgs[org] = struct{}{}
+		}
+	}
+	if len(rc.Countries) > 0 {
+		r.countries = make(map[string]struct{})
+		for _, cc := range rc.Countries {
+			r.countries[strings.ToUpper(cc)] = struct{}{}
+		}
+	}
+	if r.maxPrefixLen > 0 && r.maxPrefixLen < r.minPrefixLen {
+		return nil, fmt.Errorf("max_prefix_len is below min_prefix_len")
+	}
+	var err error
+	if r.Throttle, err = parseDuration(rc.Throttle); err != nil {
+		return nil, fmt.Errorf("invalid throttle: %v", err)
+	}
+	if r.GroupWindow, err = parseDuration(rc.GroupWindow); err != nil {
+		return nil, fmt.Errorf("invalid group_window: %v", err)
+	}
+	if len(rc.Outputs) == 0 {
+		return nil, fmt.Errorf("at least one output is required")
+	}
+	for _, name := range rc.Outputs {
+		n, ok := outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown output %q", name)
+		}
+		r.outputs = append(r.outputs, n)
+	}
+	return r, nil
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Matches reports whether ev matches every condition of the rule.
+func (r *Rule) Matches(ev *Event) bool {
+	if r.classifications != nil {
+		if _, ok := r.classifications[ev.Classification]; !ok {
+			return false
+		}
+	}
+	if ev.Severity.rank() < r.minSeverity {
+		return false
+	}
+	if r.asns != nil && !r.matchASN(ev) {
+		return false
+	}
+	if r.orgs != nil {
+		if _, ok := r.orgs[ev.OrgID]; !ok {
+			return false
+		}
+	}
+	if r.countries != nil && !slices.ContainsFunc(ev.Countries, func(cc string) bool {
+		_, ok := r.countries[cc]
+		return ok
+	}) {
+		return false
+	}
+	if (r.minPrefixLen > 0 || r.maxPrefixLen > 0) && !slices.ContainsFunc(ev.Prefixes, r.matchPrefixLen) {
+		return false
+	}
+	return ev.ImpactedIPs >= r.minImpactedIPs
+}
+
+func (r *Rule) matchASN(ev *Event) bool {
+	asns := []uint32{ev.ASN}
+	if ev.Leak != nil {
+		asns = append(asns, ev.Leak.LeakerASN, ev.Leak.VictimASN)
+	}
+	for _, asn := range asns {
+		if _, ok := r.asns[asn]; ok && asn != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchPrefixLen(prefix string) bool {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	return p.Bits() >= r.minPrefixLen && (r.maxPrefixLen == 0 || p.Bits() <= r.maxPrefixLen)
+}
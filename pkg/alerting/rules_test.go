@@ -0,0 +1,94 @@
+package alerting
+
+import (
+	"testing"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+)
+
+var testOutputs = []notify.Config{{Type: "file", Name: "archive", Path: "/dev/null"}}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, c := range map[string]Config{
+		"unnamed rule":           {Outputs: testOutputs, Rules: []RuleConfig{{Outputs: []string{"archive"}}}},
+		"duplicate rule":         {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a", Outputs: []string{"archive"}}, {Name: "a", Outputs: []string{"archive"}}}},
+		"duplicate output":       {Outputs: append(testOutputs, testOutputs...)},
+		"no outputs":             {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a"}}},
+		"unknown output":         {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a", Outputs: []string{"pager"}}}},
+		"unknown classification": {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a", Classifications: []string{"leak"}, Outputs: []string{"archive"}}}},
+		"unknown severity":       {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a", MinSeverity: "urgent", Outputs: []string{"archive"}}}},
+		"invalid throttle":       {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a", Throttle: "soon", Outputs: []string{"archive"}}}},
+		"inverted prefix range":  {Outputs: testOutputs, Rules: []RuleConfig{{Name: "a", MinPrefixLen: 24, MaxPrefixLen: 16, Outputs: []string{"archive"}}}},
+	} {
+		if _, err := New(&c); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	leak := Event{
+		Classification: "route_leak",
+		Severity:       SeverityCritical,
+		ASN:            100,
+		OrgID:          "ORG-A",
+		Leak:           &api.Leak{LeakerASN: 200, VictimASN: 300},
+		Countries:      []string{"US", "DE"},
+		ImpactedIPs:    65536 + 256,
+		Prefixes:       []string{"10.0.0.0/16", "1.2.3.0/24"},
+	}
+	flap := Event{
+		Classification: "flap",
+		Severity:       SeverityBad,
+		ASN:            400,
+		Countries:      []string{"FR"},
+		ImpactedIPs:    256,
+		Prefixes:       []string{"5.6.7.0/24"},
+	}
+	for _, tc := range []struct {
+		name       string
+		rule       RuleConfig
+		leak, flap bool
+	}{
+		{"empty", RuleConfig{}, true, true},
+		{"classification", RuleConfig{Classifications: []string{"route_leak"}}, true, false},
+		{"severity", RuleConfig{MinSeverity: SeverityCritical}, true, false},
+		{"severity below", RuleConfig{MinSeverity: SeverityPolicy}, true, true},
+		{"victim asn", RuleConfig{ASNs: []uint32{300}}, true, false},
+		{"org", RuleConfig{Orgs: []string{"ORG-A"}}, true, false},
+		{"country", RuleConfig{Countries: []string{"fr"}}, false, true},
+		{"prefix length", RuleConfig{MaxPrefixLen: 16}, true, false},
+		{"prefix range", RuleConfig{MinPrefixLen: 20, MaxPrefixLen: 24}, true, true},
+		{"impact", RuleConfig{MinImpactedIPs: 1000}, true, false},
+		{"all", RuleConfig{Classifications: []string{"route_leak", "flap"}, Countries: []string{"DE"}, MinImpactedIPs: 1000}, true, false},
+	} {
+		tc.rule.Name = tc.name
+		tc.rule.Outputs = []string{"archive"}
+		rs, err := New(&Config{Outputs: testOutputs, Rules: []RuleConfig{tc.rule}})
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		r := rs.rules[0]
+		if got := r.Matches(&leak); got != tc.leak {
+			t.Errorf("%s: leak matched %v, want %v", tc.name, got, tc.leak)
+		}
+		if got := r.Matches(&flap); got != tc.flap {
+			t.Errorf("%s: flap matched %v, want %v", tc.name, got, tc.flap)
+		}
+	}
+}
+
+func TestSeverityOf(t *testing.T) {
+	for class, want := range map[bgp.ClassificationType]Severity{
+		bgp.ClassificationHijack:         SeverityCritical,
+		bgp.ClassificationFlap:           SeverityBad,
+		bgp.ClassificationDDoSMitigation: SeverityPolicy,
+		bgp.ClassificationDiscovery:      SeverityNormal,
+	} {
+		if got := SeverityOf(class); got != want {
+			t.Errorf("%v: got %s, want %s", class, got, want)
+		}
+	}
+}
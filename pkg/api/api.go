@@ -0,0 +1,254 @@
+// Package api serves the live state of the engine and the classifier as
+// read-only JSON over HTTP.
+package api
+
+import (
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// Window is the span the activity rates in a Snapshot are averaged over.
+const Window = 60 * time.Second
+
+// Source provides the state the API serves. The viewer engine and the headless
+// LiveSource both implement it.
+type Source interface {
+	// Snapshot returns the classified prefixes and the activity over the last
+	// Window.
+	Snapshot() *Snapshot
+	// CriticalEvents returns the current incidents, newest first.
+	CriticalEvents() []CriticalEvent
+	// PrefixState returns the classifier state of a prefix.
+	PrefixState(prefix string) (*bgpproto.PrefixState, bool)
+	// Metrics returns the processor and geolocation counters.
+	Metrics() Metrics
+}
+
+// Leak holds the classification-specific detail of a route leak, hijack or
+// DDoS mitigation.
+type Leak struct {
+	Type      string `json:"type,omitempty"`
+	LeakerASN uint32 `json:"leaker_asn,omitempty"`
+	VictimASN uint32 `json:"victim_asn,omitempty"`
+}
+
+// Anomaly is a prefix that is currently classified. Classification holds a key
+// as returned by bgp.ClassificationType.Key.
+type Anomaly struct {
+	Prefix         string   `json:"prefix"`
+	Classification string   `json:"classification"`
+	Name           string   `json:"name"`
+	OriginASN      uint32   `json:"origin_asn,omitempty"`
+	Network        string   `json:"network,omitempty"`
+	Rate           float64  `json:"rate"`
+	Countries      []string `json:"countries,omitempty"`
+	Leak           *Leak    `json:"leak,omitempty"`
+}
+
+// ClassificationSummary totals the anomalies of one classification.
+type ClassificationSummary struct {
+	Classification string  `json:"classification"`
+	Name           string  `json:"name"`
+	Prefixes       int     `json:"prefixes"`
+	ASNs           int     `json:"asns"`
+	IPs            uint64  `json:"ips"`
+	Rate           float64 `json:"rate"`
+}
+
+// ASNImpact groups the anomalies of one classification by origin ASN.
+type ASNImpact struct {
+	ASN            uint32   `json:"asn"`
+	Network        string   `json:"network,omitempty"`
+	Classification string   `json:"classification"`
+	Name           string   `json:"name"`
+	Prefixes       []string `json:"prefixes"`
+	IPs            uint64   `json:"ips"`
+	Rate           float64  `json:"rate"`
+	Countries      []string `json:"countries,omitempty"`
+	Leak           *Leak    `json:"leak,omitempty"`
+}
+
+// CountryActivity is the rate of located events in one country.
+type CountryActivity struct {
+	Country string  `json:"country"`
+	Rate    float64 `json:"rate"`
+}
+
+// Snapshot is the classified state at one point in time. Rates are events per
+// second averaged over Window, and every list is sorted busiest first.
+type Snapshot struct {
+	Time            time.Time               `json:"time"`
+	Classifications []ClassificationSummary `json:"classifications"`
+	Anomalies       []Anomaly               `json:"anomalies"`
+	ASNs            []ASNImpact             `json:"asns"`
+	Countries       []CountryActivity       `json:"countries"`
+}
+
+// CriticalEvent is an ongoing incident such as an outage, route leak or hijack,
+// possibly spanning several prefixes. ID is set by a Tracker to identify the
+// incident across updates.
+type CriticalEvent struct {
+	ID             uint64    `json:"id,omitempty"`
+	Time           time.Time `json:"time"`
+	Classification string    `json:"classification"`
+	Name           string    `json:"name"`
+	ASN            uint32    `json:"asn,omitempty"`
+	Network        string    `json:"network,omitempty"`
+	OrgID          string    `json:"org_id,omitempty"`
+	Leak           *Leak     `json:"leak,omitempty"`
+	Locations      string    `json:"locations,omitempty"`
+	Visibility     float64   `json:"visibility,omitempty"`
+	PeakVisibility float64   `json:"peak_visibility,omitempty"`
+	ImpactedIPs    uint64    `json:"impacted_ips"`
+	Prefixes       []string  `json:"prefixes"`
+}
+
+// PrefixInfo is the classifier state of a prefix, along with its anomaly entry
+// if it is currently classified.
+type PrefixInfo struct {
+	Prefix          string    `json:"prefix"`
+	Classification  string    `json:"classification"`
+	Name            string    `json:"name,omitempty"`
+	ClassifiedSince time.Time `json:"classified_since,omitzero"`
+	FirstSeen       time.Time `json:"first_seen,omitzero"`
+	LastUpdate      time.Time `json:"last_update,omitzero"`
+	OriginASN       uint32    `json:"origin_asn,omitempty"`
+	Leak            *Leak     `json:"leak,omitempty"`
+	RPKI            string    `json:"rpki,omitempty"`
+	BogonReason     string    `json:"bogon_reason,omitempty"`
+	Peers           int       `json:"peers"`
+	AnomalyScore    *float64  `json:"anomaly_score,omitempty"`
+	Visibility      *float64  `json:"visibility,omitempty"`
+	PeakVisibility  *float64  `json:"peak_visibility,omitempty"`
+	Anomaly         *Anomaly  `json:"anomaly,omitempty"`
+}
+
+// Metrics holds the processor and geolocation counters. Counters are totals
+// since startup.
+type Metrics struct {
+	Time                 time.Time                      `json:"time"`
+	Processor            *bgp.ProcessorMetrics          `json:"processor,omitempty"`
+	Geo                  *geoservice.GeoMetricsSnapshot `json:"geo,omitempty"`
+	Classifications      map[string]int                 `json:"classifications,omitempty"`
+	ClassificationEvents int                            `json:"classification_events"`
+	Dropped              map[string]uint64              `json:"dropped,omitempty"`
+}
+
+// LiveSource serves a Tracker that is fed by a processor, for running without
+// the viewer.
+type LiveSource struct {
+	*Tracker
+	Processor *bgp.BGPProcessor
+	Geo       *geoservice.GeoService
+	// Dropped, if set, returns the drop counters of the consumers the
+	// processor feeds
+	Dropped func() map[string]uint64
+}
+
+func (l *LiveSource) PrefixState(prefix string) (*bgpproto.PrefixState, bool) {
+	if l.Processor == nil {
+		return nil, false
+	}
+	return l.Processor.PrefixState(prefix)
+}
+
+func (l *LiveSource) Metrics() Metrics {
+	m := NewMetrics(l.Processor, l.Geo, l.now())
+	if l.Dropped != nil {
+		m.Dropped = l.Dropped()
+	}
+	return m
+}
+
+// NewMetrics collects the counters of a processor and a geolocation service,
+// either of which may be nil.
+func NewMetrics(p *bgp.BGPProcessor, geo *geoservice.GeoService, now time.Time) Metrics {
+	m := Metrics{Time: now.UTC()}
+	if p != nil {
+		pm := p.Metrics()
+		m.Processor = &pm
+		stats, total := p.GetClassificationStats()
+		m.Classifications = make(map[string]int, len(stats))
+		for t, n := range stats {
+			m.Classifications[t.Key()] = n
+		}
+		m.ClassificationEvents = total
+	}
+	if geo != nil {
+		gm := geo.Metrics()
+		m.Geo = &gm
+	}
+	return m
+}
+
+// NewPrefixInfo converts the classifier state of prefix.
+func NewPrefixInfo(prefix string, state *bgpproto.PrefixState) *PrefixInfo {
+	t := bgp.ClassificationType(state.ClassifiedType)
+	info := &PrefixInfo{
+		Prefix:         prefix,
+		Classification: t.Key(),
+		OriginASN:      state.LastOriginAsn,
+		BogonReason:    state.BogonReason,
+		Peers:          len(state.PeerLastAttrs),
+		FirstSeen:      unixTime(state.StartTimeTs),
+		LastUpdate:     unixTime(state.LastUpdateTs),
+	}
+	if t != bgp.ClassificationNone {
+		info.Name = t.String()
+		info.ClassifiedSince = unixTime(state.ClassifiedTimeTs)
+	}
+	if state.LastRpkiStatus != 0 {
+		info.RPKI = utils.RPKIStatus(state.LastRpkiStatus).String()
+	}
+	info.Leak = newLeak(bgp.LeakType(state.LeakType), state.LeakerAsn, state.VictimAsn)
+	if bgp.BaselineEstablished(state) {
+		score := state.AnomalyScore
+		info.AnomalyScore = &score
+	}
+	if bgp.HasVisibility(state) {
+		vis, peak := state.Visibility, state.VisibilityPeak
+		info.Visibility, info.PeakVisibility = &vis, &peak
+	}
+	return info
+}
+
+func newLeak(t bgp.LeakType, leaker, victim uint32) *Leak {
+	if t == bgp.LeakUnknown && leaker == 0 && victim == 0 {
+		return nil
+	}
+	l := &Leak{LeakerASN: leaker, VictimASN: victim}
+	if t != bgp.LeakUnknown {
+		l.Type = t.String()
+	}
+	return l
+}
+
+// NewLeak converts the leak detail of an event, which may be nil.
+func NewLeak(ld *bgp.LeakDetail) *Leak {
+	if ld == nil {
+		return nil
+	}
+	return newLeak(ld.Type, ld.LeakerASN, ld.VictimASN)
+}
+
+func unixTime(ts int64) time.Time {
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0).UTC()
+}
+
+// NetworkName returns the name of asn, or "" if the mapping does not know it.
+func NetworkName(m *utils.ASNMapping, asn uint32) string {
+	if m == nil || asn == 0 {
+		return ""
+	}
+	if name := m.GetName(asn); name != bgp.StrUnknown {
+		return name
+	}
+	return ""
+}
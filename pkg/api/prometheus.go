@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+const namespace = "bgpstream"
+
+func newDesc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+}
+
+var (
+	descMessages          = newDesc("messages_total", "RIS Live messages received.")
+	descCollectorMessages = newDesc("collector_messages_total", "RIS Live messages received per collector.", "collector")
+	descQueueDepth        = newDesc("worker_queue_depth", "Messages waiting for each processor worker.", "worker")
+	descStatesFlushed     = newDesc("states_flushed_total", "Prefix states written to the state database.")
+	descFullFeedPeers     = newDesc("full_feed_peers", "Peers sending a full table.")
+	descEvents            = newDesc("events_total", "Events reported per classification.", "classification")
+	descClassified        = newDesc("classified_prefixes", "Prefixes currently in each classification.", "classification")
+	descCacheHits         = newDesc("cache_hits_total", "Lookups that found their key in an in-memory cache.", "cache")
+	descCacheMisses       = newDesc("cache_misses_total", "Lookups that missed an in-memory cache.", "cache")
+	descDiskTrieLatency   = newDesc("disktrie_lookup_duration_seconds", "Latency of prefix database lookups.", "db")
+	descDataAge           = newDesc("data_age_seconds", "Time since the data in use was downloaded.", "dataset")
+	descGeoLookups        = newDesc("geo_lookups_total", "Geolocation lookups per resolving source.", "source")
+	descGeoCacheResets    = newDesc("geo_cache_resets_total", "Times the geolocation cache was reset.")
+	descDropped           = newDesc("dropped_total", "Items dropped because a consumer fell behind.", "stage")
+)
+
+// collector exports the Metrics of a Source. Values are read on every scrape,
+// so nothing has to be kept in sync with the processor.
+type collector struct {
+	src Source
+}
+
+// NewCollector returns a Prometheus collector for the metrics of src.
+func NewCollector(src Source) prometheus.Collector {
+	return &collector{src: src}
+}
+
+// NewMetricsHandler serves the metrics of src, along with the Go runtime and
+// process metrics, in the Prometheus exposition format.
+func NewMetricsHandler(src Source) http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		NewCollector(src),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		descMessages, descCollectorMessages, descQueueDepth, descStatesFlushed, descFullFeedPeers,
+		descEvents, descClassified, descCacheHits, descCacheMisses, descDiskTrieLatency, descDataAge,
+		descGeoLookups, descGeoCacheResets, descDropped,
+	} {
+		ch <- d
+	}
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	m := c.src.Metrics()
+	counter := func(d *prometheus.Desc, v uint64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), labels...)
+	}
+	gauge := func(d *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, labels...)
+	}
+	diskTries := func(stats map[string]utils.DiskTrieStats) {
+		for name, st := range stats {
+			buckets := make(map[float64]uint64, len(st.Buckets))
+			for i, n := range st.Buckets {
+				buckets[utils.LookupLatencyBuckets[i]] = n
+			}
+			ch <- prometheus.MustNewConstHistogram(descDiskTrieLatency, st.Lookups, st.Seconds, buckets, name)
+		}
+	}
+
+	if p := m.Processor; p != nil {
+		counter(descMessages, p.Messages)
+		for name, n := range p.CollectorMessages {
+			counter(descCollectorMessages, n, name)
+		}
+		for i, depth := range p.QueueDepths {
+			gauge(descQueueDepth, float64(depth), strconv.Itoa(i))
+		}
+		counter(descStatesFlushed, p.StatesFlushed)
+		gauge(descFullFeedPeers, float64(p.FullFeedPeers))
+		for key, n := range p.Events {
+			counter(descEvents, n, key)
+		}
+		for name, st := range p.Caches {
+			counter(descCacheHits, st.Hits, name)
+			counter(descCacheMisses, st.Misses, name)
+		}
+		diskTries(p.DiskTries)
+		if !p.RPKIDataTime.IsZero() {
+			gauge(descDataAge, m.Time.Sub(p.RPKIDataTime).Seconds(), "rpki")
+		}
+		if !p.ASNDataTime.IsZero() {
+			gauge(descDataAge, m.Time.Sub(p.ASNDataTime).Seconds(), "asn")
+		}
+	}
+	for key, n := range m.Classifications {
+		gauge(descClassified, float64(n), key)
+	}
+
+	if g := m.Geo; g != nil {
+		for source, n := range map[string]uint64{
+			"cache":   g.Cache,
+			"custom":  g.Custom,
+			"cloud":   g.Cloud,
+			"mmdb":    g.MMDB,
+			"rir":     g.RIR,
+			"whois":   g.WHOIS,
+			"peering": g.Peering,
+			"hubs":    g.Hubs,
+			"unknown": g.Unknown,
+		} {
+			counter(descGeoLookups, n, source)
+		}
+		counter(descGeoCacheResets, g.CacheResets)
+		diskTries(g.DiskTries)
+	}
+
+	for stage, n := range m.Dropped {
+		counter(descDropped, n, stage)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	src := newFakeSource()
+	src.metrics = Metrics{
+		Time: now,
+		Processor: &bgp.ProcessorMetrics{
+			Messages:          1500,
+			CollectorMessages: map[string]uint64{"rrc00": 1000, "rrc01": 500},
+			QueueDepths:       []int{3, 0},
+			Events:            map[string]uint64{"bgp_hijack": 2, "none": 900},
+			Caches:            map[string]utils.CacheStats{"prefix_states": {Hits: 90, Misses: 10}},
+			DiskTries: map[string]utils.DiskTrieStats{
+				"state": {Lookups: 4, Seconds: 0.002, Buckets: []uint64{0, 1, 2, 3, 4, 4}},
+			},
+			RPKIDataTime: now.Add(-2 * time.Hour),
+		},
+		Geo:             &geoservice.GeoMetricsSnapshot{Cache: 40, MMDB: 7},
+		Classifications: map[string]int{"bgp_hijack": 1},
+		Dropped:         map[string]uint64{"queue": 5},
+	}
+
+	rec := httptest.NewRecorder()
+	NewServer(src).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		"bgpstream_messages_total 1500",
+		`bgpstream_collector_messages_total{collector="rrc01"} 500`,
+		`bgpstream_worker_queue_depth{worker="0"} 3`,
+		`bgpstream_events_total{classification="bgp_hijack"} 2`,
+		`bgpstream_classified_prefixes{classification="bgp_hijack"} 1`,
+		`bgpstream_cache_misses_total{cache="prefix_states"} 10`,
+		`bgpstream_disktrie_lookup_duration_seconds_bucket{db="state",le="0.0001"} 2`,
+		`bgpstream_disktrie_lookup_duration_seconds_count{db="state"} 4`,
+		`bgpstream_data_age_seconds{dataset="rpki"} 7200`,
+		`bgpstream_geo_lookups_total{source="mmdb"} 7`,
+		`bgpstream_dropped_total{stage="queue"} 5`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(body, `dataset="asn"`) {
+		t.Error("expected no age for ASN data that was never loaded")
+	}
+}
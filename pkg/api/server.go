@@ -0,0 +1,351 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+// Server serves a Source over HTTP. Every endpoint is read-only:
+//
+//	GET /api/v1/anomalies          classified prefixes and per-classification totals
+//	GET /api/v1/critical           the critical event stream
+//	GET /api/v1/prefixes/{prefix}  classifier state of one prefix
+//	GET /api/v1/asns               anomalies grouped by origin ASN
+//	GET /api/v1/asns/{asn}         impact and anomalies of one ASN
+//	GET /api/v1/countries          event rate per country
+//	GET /api/v1/countries/{cc}     event rate and anomalies of one country
+//	GET /api/v1/metrics            processor and geolocation counters
+//	GET /metrics                   the same counters for Prometheus
+//
+// The list endpoints accept type (comma-separated classification keys), asn,
+// country and limit query parameters where they apply.
+type Server struct {
+	src Source
+	mux *http.ServeMux
+}
+
+func NewServer(src Source) *Server {
+	s := &Server{src: src, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /api/v1/anomalies", s.handleAnomalies)
+	s.mux.HandleFunc("GET /api/v1/critical", s.handleCritical)
+	s.mux.HandleFunc("GET /api/v1/prefixes/{prefix...}", s.handlePrefix)
+	s.mux.HandleFunc("GET /api/v1/asns", s.handleASNs)
+	s.mux.HandleFunc("GET /api/v1/asns/{asn}", s.handleASN)
+	s.mux.HandleFunc("GET /api/v1/countries", s.handleCountries)
+	s.mux.HandleFunc("GET /api/v1/countries/{cc}", s.handleCountry)
+	s.mux.HandleFunc("GET /api/v1/metrics", s.handleMetrics)
+	s.mux.Handle("GET /metrics", NewMetricsHandler(src))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves src on addr until ctx is canceled. It returns once
+// in-flight requests have finished, so src can be torn down afterwards.
+func ListenAndServe(ctx context.Context, addr string, src Source) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: NewServer(src), ReadHeaderTimeout: 10 * time.Second}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	log.Printf("[API] Serving on http://%s/api/v1/", ln.Addr())
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[API] Error writing response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// filter holds the query parameters shared by the list endpoints.
+type filter struct {
+	types   map[string]bool
+	asn     uint32
+	country string
+	limit   int
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	var f filter
+	q := r.URL.Query()
+	if v := q.Get("type"); v != "" {
+		f.types = make(map[string]bool)
+		for _, key := range strings.Split(v, ",") {
+			if _, ok := bgp.ParseClassificationKey(key); !ok {
+				return f, errors.New("unknown classification " + strconv.Quote(key))
+			}
+			f.types[key] = true
+		}
+	}
+	if v := q.Get("asn"); v != "" {
+		asn, err := parseASN(v)
+		if err != nil {
+			return f, err
+		}
+		f.asn = asn
+	}
+	f.country = strings.ToUpper(q.Get("country"))
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, errors.New("invalid limit")
+		}
+		f.limit = n
+	}
+	return f, nil
+}
+
+func parseASN(v string) (uint32, error) {
+	v = strings.TrimPrefix(strings.ToUpper(v), "AS")
+	asn, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, errors.New("invalid ASN")
+	}
+	return uint32(asn), nil
+}
+
+func (f *filter) matchType(key string) bool {
+	return f.types == nil || f.types[key]
+}
+
+func (f *filter) matchCountry(countries []string) bool {
+	if f.country == "" {
+		return true
+	}
+	for _, cc := range countries {
+		if cc == f.country {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *filter) matchAnomaly(a *Anomaly) bool {
+	if !f.matchType(a.Classification) || !f.matchCountry(a.Countries) {
+		return false
+	}
+	return f.asn == 0 || a.OriginASN == f.asn || involves(a.Leak, f.asn)
+}
+
+func involves(l *Leak, asn uint32) bool {
+	return l != nil && (l.LeakerASN == asn || l.VictimASN == asn)
+}
+
+// locatedIn reports whether a critical event's locations, formatted as
+// "City, CC | CC", include country cc.
+func locatedIn(locations, cc string) bool {
+	for _, loc := range strings.Split(locations, " | ") {
+		if loc == cc || strings.HasSuffix(loc, ", "+cc) {
+			return true
+		}
+	}
+	return false
+}
+
+func limit[T any](items []T, n int) []T {
+	if n > 0 && len(items) > n {
+		return items[:n]
+	}
+	return items
+}
+
+func (s *Server) handleAnomalies(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	snap := s.src.Snapshot()
+	anomalies := make([]Anomaly, 0, len(snap.Anomalies))
+	for i := range snap.Anomalies {
+		if f.matchAnomaly(&snap.Anomalies[i]) {
+			anomalies = append(anomalies, snap.Anomalies[i])
+		}
+	}
+	classes := make([]ClassificationSummary, 0, len(snap.Classifications))
+	for _, c := range snap.Classifications {
+		if f.matchType(c.Classification) {
+			classes = append(classes, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"time":            snap.Time,
+		"window_seconds":  Window.Seconds(),
+		"classifications": classes,
+		"anomalies":       limit(anomalies, f.limit),
+	})
+}
+
+func (s *Server) handleCritical(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	all := s.src.CriticalEvents()
+	events := make([]CriticalEvent, 0, len(all))
+	for _, ev := range all {
+		if !f.matchType(ev.Classification) {
+			continue
+		}
+		if f.asn != 0 && ev.ASN != f.asn && !involves(ev.Leak, f.asn) {
+			continue
+		}
+		if f.country != "" && !locatedIn(ev.Locations, f.country) {
+			continue
+		}
+		events = append(events, ev)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": limit(events, f.limit)})
+}
+
+func (s *Server) handlePrefix(w http.ResponseWriter, r *http.Request) {
+	p, err := netip.ParsePrefix(r.PathValue("prefix"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid prefix")
+		return
+	}
+	prefix := p.Masked().String()
+	state, ok := s.src.PrefixState(prefix)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no state for "+prefix)
+		return
+	}
+	info := NewPrefixInfo(prefix, state)
+	for _, a := range s.src.Snapshot().Anomalies {
+		if a.Prefix == prefix {
+			info.Anomaly = &a
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleASNs(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	snap := s.src.Snapshot()
+	asns := make([]ASNImpact, 0, len(snap.ASNs))
+	for _, g := range snap.ASNs {
+		if f.matchType(g.Classification) && f.matchCountry(g.Countries) && (f.asn == 0 || g.ASN == f.asn) {
+			asns = append(asns, g)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"time":           snap.Time,
+		"window_seconds": Window.Seconds(),
+		"asns":           limit(asns, f.limit),
+	})
+}
+
+func (s *Server) handleASN(w http.ResponseWriter, r *http.Request) {
+	asn, err := parseASN(r.PathValue("asn"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	snap := s.src.Snapshot()
+	impacts := []ASNImpact{}
+	for _, g := range snap.ASNs {
+		if g.ASN == asn {
+			impacts = append(impacts, g)
+		}
+	}
+	f := filter{asn: asn}
+	anomalies := []Anomaly{}
+	for i := range snap.Anomalies {
+		if f.matchAnomaly(&snap.Anomalies[i]) {
+			anomalies = append(anomalies, snap.Anomalies[i])
+		}
+	}
+	events := []CriticalEvent{}
+	for _, ev := range s.src.CriticalEvents() {
+		if ev.ASN == asn || involves(ev.Leak, asn) {
+			events = append(events, ev)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"time":      snap.Time,
+		"asn":       asn,
+		"impacts":   impacts,
+		"anomalies": anomalies,
+		"critical":  events,
+	})
+}
+
+func (s *Server) handleCountries(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	snap := s.src.Snapshot()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"time":           snap.Time,
+		"window_seconds": Window.Seconds(),
+		"countries":      limit(snap.Countries, f.limit),
+	})
+}
+
+func (s *Server) handleCountry(w http.ResponseWriter, r *http.Request) {
+	cc := strings.ToUpper(r.PathValue("cc"))
+	snap := s.src.Snapshot()
+	activity := CountryActivity{Country: cc}
+	for _, c := range snap.Countries {
+		if c.Country == cc {
+			activity = c
+			break
+		}
+	}
+	f := filter{country: cc}
+	anomalies := []Anomaly{}
+	for i := range snap.Anomalies {
+		if f.matchAnomaly(&snap.Anomalies[i]) {
+			anomalies = append(anomalies, snap.Anomalies[i])
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"time":      snap.Time,
+		"country":   activity.Country,
+		"rate":      activity.Rate,
+		"anomalies": anomalies,
+	})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.src.Metrics())
+}
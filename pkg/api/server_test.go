@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+type fakeSource struct {
+	snap    *Snapshot
+	events  []CriticalEvent
+	states  map[string]*bgpproto.PrefixState
+	metrics Metrics
+}
+
+func (f *fakeSource) Snapshot() *Snapshot             { return f.snap }
+func (f *fakeSource) CriticalEvents() []CriticalEvent { return f.events }
+func (f *fakeSource) Metrics() Metrics                { return f.metrics }
+
+func (f *fakeSource) PrefixState(prefix string) (*bgpproto.PrefixState, bool) {
+	s, ok := f.states[prefix]
+	return s, ok
+}
+
+func newFakeSource() *fakeSource {
+	anomalies := []Anomaly{
+		{Prefix: "1.2.3.0/24", Classification: "bgp_hijack", Name: bgp.NameHijack, OriginASN: 100, Rate: 2, Countries: []string{"US"}},
+		{Prefix: "20.0.0.0/16", Classification: "route_leak", Name: bgp.NameRouteLeak, OriginASN: 300, Rate: 1, Countries: []string{"DE"}, Leak: &Leak{LeakerASN: 200, VictimASN: 300}},
+		{Prefix: "5.6.0.0/16", Classification: "flap", Name: bgp.NameFlap, OriginASN: 100, Rate: 0.5, Countries: []string{"DE"}},
+	}
+	classes, asns := Summarize(anomalies)
+	return &fakeSource{
+		snap: &Snapshot{
+			Time:            time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Anomalies:       anomalies,
+			Classifications: classes,
+			ASNs:            asns,
+			Countries:       []CountryActivity{{Country: "US", Rate: 3}, {Country: "DE", Rate: 1}},
+		},
+		events: []CriticalEvent{
+			{Classification: "route_leak", Name: bgp.NameRouteLeak, ASN: 300, Leak: &Leak{LeakerASN: 200, VictimASN: 300}, Locations: "Berlin, DE"},
+			{Classification: "outage", Name: bgp.NameHardOutage, ASN: 100, Locations: "Dallas, US | CA"},
+		},
+		states: map[string]*bgpproto.PrefixState{
+			"1.2.3.0/24": {ClassifiedType: int32(bgp.ClassificationHijack), ClassifiedTimeTs: 1704110400, LastOriginAsn: 100, LastRpkiStatus: 2},
+		},
+		metrics: Metrics{ClassificationEvents: 7},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string, wantStatus int, out any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != wantStatus {
+		t.Fatalf("GET %s: status %d, want %d: %s", path, rec.Code, wantStatus, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("GET %s: content type %q", path, ct)
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+}
+
+func TestServer_Anomalies(t *testing.T) {
+	srv := NewServer(newFakeSource())
+
+	var resp struct {
+		Anomalies       []Anomaly               `json:"anomalies"`
+		Classifications []ClassificationSummary `json:"classifications"`
+	}
+	get(t, srv, "/api/v1/anomalies", http.StatusOK, &resp)
+	if len(resp.Anomalies) != 3 || len(resp.Classifications) != 3 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	get(t, srv, "/api/v1/anomalies?type=bgp_hijack,flap&limit=1", http.StatusOK, &resp)
+	if len(resp.Anomalies) != 1 || resp.Anomalies[0].Prefix != "1.2.3.0/24" || len(resp.Classifications) != 2 {
+		t.Errorf("unexpected filtered response: %+v", resp)
+	}
+
+	// Leakers and victims match the ASN filter too
+	get(t, srv, "/api/v1/anomalies?asn=AS200", http.StatusOK, &resp)
+	if len(resp.Anomalies) != 1 || resp.Anomalies[0].Classification != "route_leak" {
+		t.Errorf("unexpected ASN filtered response: %+v", resp.Anomalies)
+	}
+
+	get(t, srv, "/api/v1/anomalies?country=de", http.StatusOK, &resp)
+	if len(resp.Anomalies) != 2 {
+		t.Errorf("unexpected country filtered response: %+v", resp.Anomalies)
+	}
+
+	get(t, srv, "/api/v1/anomalies?type=bogus", http.StatusBadRequest, nil)
+	get(t, srv, "/api/v1/anomalies?asn=x", http.StatusBadRequest, nil)
+}
+
+func TestServer_Critical(t *testing.T) {
+	srv := NewServer(newFakeSource())
+	var resp struct {
+		Events []CriticalEvent `json:"events"`
+	}
+	get(t, srv, "/api/v1/critical?country=US", http.StatusOK, &resp)
+	if len(resp.Events) != 1 || resp.Events[0].Classification != "outage" {
+		t.Errorf("unexpected events: %+v", resp.Events)
+	}
+	get(t, srv, "/api/v1/critical?country=CA", http.StatusOK, &resp)
+	if len(resp.Events) != 1 {
+		t.Errorf("unexpected events: %+v", resp.Events)
+	}
+	get(t, srv, "/api/v1/critical?asn=200", http.StatusOK, &resp)
+	if len(resp.Events) != 1 || resp.Events[0].Classification != "route_leak" {
+		t.Errorf("unexpected events: %+v", resp.Events)
+	}
+}
+
+func TestServer_Prefix(t *testing.T) {
+	srv := NewServer(newFakeSource())
+
+	var info PrefixInfo
+	// Host bits are masked off
+	get(t, srv, "/api/v1/prefixes/1.2.3.4/24", http.StatusOK, &info)
+	if info.Prefix != "1.2.3.0/24" || info.Classification != "bgp_hijack" || info.RPKI != "InvalidASN" || info.OriginASN != 100 {
+		t.Errorf("unexpected prefix info: %+v", info)
+	}
+	if info.Anomaly == nil || info.Anomaly.Rate != 2 {
+		t.Errorf("expected the current anomaly, got %+v", info.Anomaly)
+	}
+	if !info.ClassifiedSince.Equal(time.Unix(1704110400, 0)) {
+		t.Errorf("unexpected classification time %v", info.ClassifiedSince)
+	}
+
+	get(t, srv, "/api/v1/prefixes/9.9.9.0/24", http.StatusNotFound, nil)
+	get(t, srv, "/api/v1/prefixes/not-a-prefix", http.StatusBadRequest, nil)
+}
+
+func TestServer_ASNsAndCountries(t *testing.T) {
+	srv := NewServer(newFakeSource())
+
+	var asns struct {
+		ASNs []ASNImpact `json:"asns"`
+	}
+	get(t, srv, "/api/v1/asns?type=flap", http.StatusOK, &asns)
+	if len(asns.ASNs) != 1 || asns.ASNs[0].ASN != 100 {
+		t.Errorf("unexpected ASN impacts: %+v", asns.ASNs)
+	}
+
+	var asn struct {
+		ASN       uint32          `json:"asn"`
+		Impacts   []ASNImpact     `json:"impacts"`
+		Anomalies []Anomaly       `json:"anomalies"`
+		Critical  []CriticalEvent `json:"critical"`
+	}
+	get(t, srv, "/api/v1/asns/AS100", http.StatusOK, &asn)
+	if asn.ASN != 100 || len(asn.Impacts) != 2 || len(asn.Anomalies) != 2 || len(asn.Critical) != 1 {
+		t.Errorf("unexpected ASN response: %+v", asn)
+	}
+
+	var country struct {
+		Country   string    `json:"country"`
+		Rate      float64   `json:"rate"`
+		Anomalies []Anomaly `json:"anomalies"`
+	}
+	get(t, srv, "/api/v1/countries/de", http.StatusOK, &country)
+	if country.Country != "DE" || country.Rate != 1 || len(country.Anomalies) != 2 {
+		t.Errorf("unexpected country response: %+v", country)
+	}
+
+	var metrics Metrics
+	get(t, srv, "/api/v1/metrics", http.StatusOK, &metrics)
+	if metrics.ClassificationEvents != 7 {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+}
@@ -0,0 +1,524 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+const (
+	// classifiedTTL is how long a prefix stays listed after its last
+	// classified event, so that silent outages remain visible
+	classifiedTTL = 10 * time.Minute
+	// maxCriticalEvents caps the incident list
+	maxCriticalEvents = 100
+)
+
+type trackedPrefix struct {
+	class     bgp.ClassificationType
+	seen      time.Time
+	asn       uint32
+	leak      *bgp.LeakDetail
+	countries map[string]struct{}
+}
+
+type trackerBucket struct {
+	sec       int64
+	countries map[string]int
+	anomalies map[bgp.ClassificationType]map[string]int
+}
+
+type trackedIncident struct {
+	event CriticalEvent
+	class bgp.ClassificationType
+	open  bgp.OpenIncident
+}
+
+// Tracker aggregates processor events into the state the API serves, following
+// the same incident rules as the viewer's summaries. It is safe for concurrent use.
+type Tracker struct {
+	asnMapping *utils.ASNMapping
+	now        bgp.TimeProvider
+
+	mu        sync.Mutex
+	prefixes  map[string]*trackedPrefix
+	buckets   [60]trackerBucket
+	lastPrune int64
+	incidents []*trackedIncident
+	cooldown  bgp.IncidentCooldowns
+
+	lastIncidentID uint64
+	onIncident     IncidentCallback
+	onResolved     ResolvedCallback
+	resolved       []CriticalEvent
+}
+
+// IncidentCallback is called with a copy of an incident when it is opened or
+// gains a prefix or location.
+type IncidentCallback func(ev CriticalEvent, opened bool)
+
+// ResolvedCallback is called with the last copy of an incident once it has
+// gone bgp.IncidentTTL without an event.
+type ResolvedCallback func(ev CriticalEvent)
+
+// NewTracker returns an empty tracker. asnMapping may be nil, in which case
+// network names are left out.
+func NewTracker(asnMapping *utils.ASNMapping, now bgp.TimeProvider) *Tracker {
+	t := &Tracker{
+		asnMapping: asnMapping,
+		now:        now,
+		prefixes:   make(map[string]*trackedPrefix),
+		cooldown:   make(bgp.IncidentCooldowns),
+	}
+	for i := range t.buckets {
+		t.buckets[i].countries = make(map[string]int)
+		t.buckets[i].anomalies = make(map[bgp.ClassificationType]map[string]int)
+	}
+	return t
+}
+
+// SetIncidentCallback registers fn to be called from OnEvent for incident
+// changes. It must be set before the tracker receives events.
+func (t *Tracker) SetIncidentCallback(fn IncidentCallback) {
+	t.onIncident = fn
+}
+
+// SetResolvedCallback registers fn to be called from OnEvent for incidents
+// that expired. It must be set before the tracker receives events.
+func (t *Tracker) SetResolvedCallback(fn ResolvedCallback) {
+	t.onResolved = fn
+}
+
+// OnEvent records an event. It has the signature of a bgp.BGPEventCallback so
+// the tracker can be handed to a processor directly.
+func (t *Tracker) OnEvent(lat, lng float64, cc, city string, eventType bgp.EventType, classificationType bgp.ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*bgp.LeakDetail) {
+	var ld *bgp.LeakDetail
+	if len(leakDetail) > 0 {
+		ld = leakDetail[0]
+	}
+	ev := &bgp.IncidentEvent{Class: classificationType, Prefix: prefix, ASN: asn, HistoricalASN: historicalASN, Leak: ld}
+	if ev.IgnoredDDoS() {
+		return
+	}
+	inc, opened := t.record(t.now(), cc, city, ev)
+	if inc != nil && t.onIncident != nil {
+		t.onIncident(*inc, opened)
+	}
+	if t.onResolved != nil {
+		for _, ev := range t.takeResolved() {
+			t.onResolved(ev)
+		}
+	}
+}
+
+// takeResolved returns the incidents that expired since the last call.
+func (t *Tracker) takeResolved() []CriticalEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	resolved := t.resolved
+	t.resolved = nil
+	return resolved
+}
+
+// record updates the tracked state with an event and returns the incident it
+// opened or changed, if any.
+func (t *Tracker) record(now time.Time, cc, city string, ev *bgp.IncidentEvent) (*CriticalEvent, bool) {
+	classificationType, prefix, asn, ld := ev.Class, ev.Prefix, ev.ASN, ev.Leak
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.bucket(now)
+	if cc != "" {
+		b.countries[cc]++
+	}
+	if prefix == "" {
+		return nil, false
+	}
+
+	tp, ok := t.prefixes[prefix]
+	if classificationType != bgp.ClassificationNone {
+		if b.anomalies[classificationType] == nil {
+			b.anomalies[classificationType] = make(map[string]int)
+		}
+		b.anomalies[classificationType][prefix]++
+		if !ok {
+			tp = &trackedPrefix{countries: make(map[string]struct{})}
+			t.prefixes[prefix] = tp
+		}
+		if ld != nil {
+			tp.leak = ld
+		}
+	} else if !ok {
+		return nil, false
+	}
+	tp.class = classificationType
+	tp.seen = now
+	if asn != 0 {
+		tp.asn = asn
+	}
+	if cc != "" {
+		tp.countries[cc] = struct{}{}
+	}
+
+	if bgp.IsIncident(classificationType) {
+		return t.recordIncident(now, cc, city, ev)
+	}
+	return nil, false
+}
+
+// bucket returns the window bucket for now, clearing it if it last held an
+// older second. Must be called with mu held.
+func (t *Tracker) bucket(now time.Time) *trackerBucket {
+	sec := now.Unix()
+	b := &t.buckets[sec%int64(len(t.buckets))]
+	if b.sec != sec {
+		b.sec = sec
+		clear(b.countries)
+		clear(b.anomalies)
+	}
+	if sec != t.lastPrune {
+		t.lastPrune = sec
+		t.prune(now)
+	}
+	return b
+}
+
+// prune drops prefixes that no longer show up in the snapshot. Must be called
+// with mu held.
+func (t *Tracker) prune(now time.Time) {
+	for p, tp := range t.prefixes {
+		age := now.Sub(tp.seen)
+		if age > classifiedTTL || (tp.class == bgp.ClassificationNone && age > Window) {
+			delete(t.prefixes, p)
+		}
+	}
+	t.cooldown.Prune(now)
+	n := 0
+	for _, inc := range t.incidents {
+		if now.Sub(inc.event.Time) < bgp.IncidentTTL {
+			t.incidents[n] = inc
+			n++
+		} else if t.onResolved != nil {
+			t.resolved = append(t.resolved, inc.snapshot())
+		}
+	}
+	clear(t.incidents[n:])
+	t.incidents = t.incidents[:n]
+}
+
+// recordIncident adds a critical event to a matching incident or opens a new
+// one. It returns a copy of the incident if it was opened or grew. Must be
+// called with mu held.
+func (t *Tracker) recordIncident(now time.Time, cc, city string, ev *bgp.IncidentEvent) (*CriticalEvent, bool) {
+	if ev.Excluded() {
+		return nil, false
+	}
+
+	// A prefix that changed classification leaves its previous incident
+	for _, inc := range t.incidents {
+		if _, ok := inc.open.Prefixes[ev.Prefix]; ok && inc.class != ev.Class {
+			inc.removePrefix(ev.Prefix)
+		}
+	}
+
+	loc := cc
+	if city != "" && cc != "" {
+		loc = fmt.Sprintf("%s, %s", city, cc)
+	}
+	for _, inc := range t.incidents {
+		if inc.class == ev.Class && inc.open.Matches(ev) {
+			inc.event.Time = now
+			if inc.update(loc, ev.Prefix, ev.Leak) {
+				snap := inc.snapshot()
+				return &snap, false
+			}
+			return nil, false
+		}
+	}
+
+	if !ev.OpensIncident() || !t.cooldown.Open(ev.Prefix, now) {
+		return nil, false
+	}
+	t.lastIncidentID++
+
+	asn := ev.OriginASN()
+	inc := &trackedIncident{
+		class: ev.Class,
+		open:  bgp.OpenIncident{ASN: asn, Prefixes: make(map[string]struct{})},
+		event: CriticalEvent{
+			ID:             t.lastIncidentID,
+			Time:           now,
+			Classification: ev.Class.Key(),
+			Name:           ev.Class.String(),
+			ASN:            asn,
+			Network:        NetworkName(t.asnMapping, asn),
+		},
+	}
+	if t.asnMapping != nil {
+		inc.event.OrgID = t.asnMapping.GetOrgID(asn)
+	}
+	inc.update(loc, ev.Prefix, ev.Leak)
+	t.incidents = append(t.incidents, inc)
+	if len(t.incidents) > maxCriticalEvents {
+		t.incidents = t.incidents[len(t.incidents)-maxCriticalEvents:]
+	}
+	snap := inc.snapshot()
+	return &snap, true
+}
+
+// update merges an event into the incident and reports whether it gained a
+// location or prefix.
+func (inc *trackedIncident) update(loc, prefix string, ld *bgp.LeakDetail) bool {
+	grew := false
+	if loc != "" && !strings.Contains(inc.event.Locations, loc) {
+		if inc.event.Locations == "" {
+			inc.event.Locations = loc
+		} else {
+			inc.event.Locations += " | " + loc
+		}
+		grew = true
+	}
+	if _, ok := inc.open.Prefixes[prefix]; !ok {
+		inc.open.Prefixes[prefix] = struct{}{}
+		inc.event.ImpactedIPs += utils.GetPrefixSize(prefix)
+		grew = true
+	}
+	if ld != nil {
+		if inc.open.Leak == nil || inc.open.Leak.Type == bgp.LeakUnknown {
+			inc.open.Leak = ld
+			inc.event.Leak = NewLeak(ld)
+		}
+		if ld.PeakVisibility > 0 {
+			inc.event.Visibility = ld.Visibility
+			inc.event.PeakVisibility = ld.PeakVisibility
+		}
+	}
+	return grew
+}
+
+// snapshot returns a copy of the incident that is safe to use without mu.
+func (inc *trackedIncident) snapshot() CriticalEvent {
+	ev := inc.event
+	ev.Prefixes = sortedKeys(inc.open.Prefixes)
+	if inc.event.Leak != nil {
+		l := *inc.event.Leak
+		ev.Leak = &l
+	}
+	return ev
+}
+
+func (inc *trackedIncident) removePrefix(prefix string) {
+	delete(inc.open.Prefixes, prefix)
+	if size := utils.GetPrefixSize(prefix); inc.event.ImpactedIPs >= size {
+		inc.event.ImpactedIPs -= size
+	} else {
+		inc.event.ImpactedIPs = 0
+	}
+}
+
+// CriticalEvents returns the current incidents, newest first.
+func (t *Tracker) CriticalEvents() []CriticalEvent {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	events := make([]CriticalEvent, 0, len(t.incidents))
+	for _, inc := range t.incidents {
+		if now.Sub(inc.event.Time) >= bgp.IncidentTTL {
+			continue
+		}
+		events = append(events, inc.snapshot())
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.After(events[j].Time) })
+	return events
+}
+
+// Snapshot aggregates the current window.
+func (t *Tracker) Snapshot() *Snapshot {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	windowStart := now.Unix() - int64(len(t.buckets))
+	countryCounts := make(map[string]int)
+	prefixCounts := make(map[string]int)
+	prefixClass := make(map[string]bgp.ClassificationType)
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		if b.sec <= windowStart || b.sec > now.Unix() {
+			continue
+		}
+		for cc, n := range b.countries {
+			countryCounts[cc] += n
+		}
+		for class, prefixes := range b.anomalies {
+			for p, n := range prefixes {
+				prefixCounts[p] += n
+				if class.Priority() >= prefixClass[p].Priority() {
+					prefixClass[p] = class
+				}
+			}
+		}
+	}
+	// Prefixes that are still classified stay listed while they are silent
+	for p, tp := range t.prefixes {
+		if tp.class == bgp.ClassificationNone || now.Sub(tp.seen) > classifiedTTL {
+			continue
+		}
+		if tp.class.Priority() >= prefixClass[p].Priority() {
+			prefixClass[p] = tp.class
+		}
+	}
+
+	snap := &Snapshot{Time: now.UTC(), Anomalies: []Anomaly{}, Countries: []CountryActivity{}}
+	window := Window.Seconds()
+	for cc, n := range countryCounts {
+		snap.Countries = append(snap.Countries, CountryActivity{Country: cc, Rate: float64(n) / window})
+	}
+	sort.Slice(snap.Countries, func(i, j int) bool {
+		if snap.Countries[i].Rate != snap.Countries[j].Rate {
+			return snap.Countries[i].Rate > snap.Countries[j].Rate
+		}
+		return snap.Countries[i].Country < snap.Countries[j].Country
+	})
+
+	for p, class := range prefixClass {
+		a := Anomaly{
+			Prefix:         p,
+			Classification: class.Key(),
+			Name:           class.String(),
+			Rate:           float64(prefixCounts[p]) / window,
+		}
+		if tp, ok := t.prefixes[p]; ok {
+			a.OriginASN = tp.asn
+			a.Countries = sortedKeys(tp.countries)
+			a.Leak = NewLeak(tp.leak)
+		}
+		if a.OriginASN == 0 && a.Leak != nil {
+			a.OriginASN = a.Leak.LeakerASN
+		}
+		a.Network = NetworkName(t.asnMapping, a.OriginASN)
+		snap.Anomalies = append(snap.Anomalies, a)
+	}
+	SortAnomalies(snap.Anomalies)
+	snap.Classifications, snap.ASNs = Summarize(snap.Anomalies)
+	return snap
+}
+
+// SortAnomalies orders anomalies by classification priority, then rate.
+func SortAnomalies(anomalies []Anomaly) {
+	sort.Slice(anomalies, func(i, j int) bool {
+		pi, pj := classPriority(anomalies[i].Classification), classPriority(anomalies[j].Classification)
+		if pi != pj {
+			return pi > pj
+		}
+		if anomalies[i].Rate != anomalies[j].Rate {
+			return anomalies[i].Rate > anomalies[j].Rate
+		}
+		return anomalies[i].Prefix < anomalies[j].Prefix
+	})
+}
+
+func classPriority(key string) int {
+	t, _ := bgp.ParseClassificationKey(key)
+	return t.Priority()
+}
+
+type asnKey struct {
+	asn   uint32
+	class string
+}
+
+// Summarize totals anomalies per classification and groups them by origin ASN.
+// Discovery is left out of the ASN groups, as it is in the viewer.
+func Summarize(anomalies []Anomaly) ([]ClassificationSummary, []ASNImpact) {
+	summaries := make(map[string]*ClassificationSummary)
+	asnsPerClass := make(map[string]map[uint32]struct{})
+	groups := make(map[asnKey]*ASNImpact)
+	groupCountries := make(map[asnKey]map[string]struct{})
+
+	for _, a := range anomalies {
+		s, ok := summaries[a.Classification]
+		if !ok {
+			s = &ClassificationSummary{Classification: a.Classification, Name: a.Name}
+			summaries[a.Classification] = s
+			asnsPerClass[a.Classification] = make(map[uint32]struct{})
+		}
+		size := utils.GetPrefixSize(a.Prefix)
+		s.Prefixes++
+		s.IPs += size
+		s.Rate += a.Rate
+		asnsPerClass[a.Classification][a.OriginASN] = struct{}{}
+
+		if a.OriginASN == 0 || classPriority(a.Classification) < 1 {
+			continue
+		}
+		key := asnKey{a.OriginASN, a.Classification}
+		g, ok := groups[key]
+		if !ok {
+			g = &ASNImpact{ASN: a.OriginASN, Network: a.Network, Classification: a.Classification, Name: a.Name}
+			groups[key] = g
+			groupCountries[key] = make(map[string]struct{})
+		}
+		g.Prefixes = append(g.Prefixes, a.Prefix)
+		g.IPs += size
+		g.Rate += a.Rate
+		if a.Leak != nil && a.Leak.Type != "" {
+			g.Leak = a.Leak
+		}
+		for _, cc := range a.Countries {
+			groupCountries[key][cc] = struct{}{}
+		}
+	}
+
+	classes := make([]ClassificationSummary, 0, len(summaries))
+	for key, s := range summaries {
+		s.ASNs = len(asnsPerClass[key])
+		classes = append(classes, *s)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		pi, pj := classPriority(classes[i].Classification), classPriority(classes[j].Classification)
+		if pi != pj {
+			return pi > pj
+		}
+		if classes[i].Prefixes != classes[j].Prefixes {
+			return classes[i].Prefixes > classes[j].Prefixes
+		}
+		return classes[i].Classification < classes[j].Classification
+	})
+
+	asns := make([]ASNImpact, 0, len(groups))
+	for key, g := range groups {
+		sort.Strings(g.Prefixes)
+		g.Countries = sortedKeys(groupCountries[key])
+		asns = append(asns, *g)
+	}
+	sort.Slice(asns, func(i, j int) bool {
+		pi, pj := classPriority(asns[i].Classification), classPriority(asns[j].Classification)
+		if pi != pj {
+			return pi > pj
+		}
+		if asns[i].Rate != asns[j].Rate {
+			return asns[i].Rate > asns[j].Rate
+		}
+		return asns[i].ASN < asns[j].ASN
+	})
+	return classes, asns
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
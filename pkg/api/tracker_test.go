@@ -0,0 +1,130 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time { return c.t }
+
+func TestTracker_Snapshot(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	tr := NewTracker(nil, clock.now)
+
+	for i := 0; i < 30; i++ {
+		tr.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationHijack, "1.2.3.0/24", 100, 0)
+	}
+	tr.OnEvent(0, 0, "DE", "", bgp.EventUpdate, bgp.ClassificationHijack, "1.2.4.0/24", 100, 0)
+	for i := 0; i < 6; i++ {
+		tr.OnEvent(0, 0, "DE", "", bgp.EventUpdate, bgp.ClassificationFlap, "5.6.0.0/16", 200, 0)
+	}
+	tr.OnEvent(0, 0, "FR", "", bgp.EventUpdate, bgp.ClassificationNone, "9.9.9.0/24", 300, 0)
+	// DDoS mitigation without a victim is left out, as in the viewer
+	tr.OnEvent(0, 0, "FR", "", bgp.EventUpdate, bgp.ClassificationDDoSMitigation, "7.7.7.0/24", 400, 0)
+
+	snap := tr.Snapshot()
+	if len(snap.Anomalies) != 3 {
+		t.Fatalf("expected 3 anomalies, got %+v", snap.Anomalies)
+	}
+	if a := snap.Anomalies[0]; a.Prefix != "1.2.3.0/24" || a.Classification != "bgp_hijack" || a.Rate != 0.5 || a.OriginASN != 100 {
+		t.Errorf("unexpected first anomaly: %+v", a)
+	}
+	if a := snap.Anomalies[2]; a.Classification != "flap" || a.Rate != 0.1 {
+		t.Errorf("unexpected flap anomaly: %+v", a)
+	}
+	if len(snap.Classifications) != 2 || snap.Classifications[0].Prefixes != 2 || snap.Classifications[0].IPs != 512 {
+		t.Errorf("unexpected classification totals: %+v", snap.Classifications)
+	}
+	if len(snap.ASNs) != 2 || snap.ASNs[0].ASN != 100 || len(snap.ASNs[0].Prefixes) != 2 || len(snap.ASNs[0].Countries) != 2 {
+		t.Errorf("unexpected ASN impacts: %+v", snap.ASNs)
+	}
+	if len(snap.Countries) != 3 || snap.Countries[0].Country != "US" || snap.Countries[0].Rate != 0.5 {
+		t.Errorf("unexpected country activity: %+v", snap.Countries)
+	}
+
+	// Once the window has passed, classified prefixes stay listed without a rate
+	clock.t = clock.t.Add(2 * time.Minute)
+	snap = tr.Snapshot()
+	if len(snap.Anomalies) != 3 || snap.Anomalies[0].Rate != 0 || len(snap.Countries) != 0 {
+		t.Errorf("unexpected snapshot after the window: %+v", snap)
+	}
+
+	// A prefix that returns to normal is dropped
+	tr.OnEvent(0, 0, "DE", "", bgp.EventUpdate, bgp.ClassificationNone, "5.6.0.0/16", 200, 0)
+	if snap = tr.Snapshot(); len(snap.Anomalies) != 2 {
+		t.Errorf("expected the flap to be dropped, got %+v", snap.Anomalies)
+	}
+
+	clock.t = clock.t.Add(classifiedTTL)
+	tr.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationNone, "", 0, 0)
+	if snap = tr.Snapshot(); len(snap.Anomalies) != 0 || len(tr.prefixes) != 0 {
+		t.Errorf("expected silent prefixes to expire, got %+v", snap.Anomalies)
+	}
+}
+
+func TestTracker_CriticalEvents(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	tr := NewTracker(nil, clock.now)
+	var opened, updated, resolved []CriticalEvent
+	tr.SetResolvedCallback(func(ev CriticalEvent) { resolved = append(resolved, ev) })
+	tr.SetIncidentCallback(func(ev CriticalEvent, isNew bool) {
+		if isNew {
+			opened = append(opened, ev)
+		} else {
+			updated = append(updated, ev)
+		}
+	})
+
+	// Small outages don't open an incident
+	tr.OnEvent(0, 0, "US", "", bgp.EventWithdrawal, bgp.ClassificationOutage, "1.2.3.0/24", 100, 0)
+	if events := tr.CriticalEvents(); len(events) != 0 {
+		t.Fatalf("expected no incidents, got %+v", events)
+	}
+
+	tr.OnEvent(0, 0, "US", "Dallas", bgp.EventWithdrawal, bgp.ClassificationOutage, "10.0.0.0/16", 100, 0)
+	clock.t = clock.t.Add(time.Second)
+	// The small outage joins the incident of the same origin
+	tr.OnEvent(0, 0, "US", "Dallas", bgp.EventWithdrawal, bgp.ClassificationOutage, "1.2.3.0/24", 100, 0)
+	clock.t = clock.t.Add(time.Second)
+	leak := &bgp.LeakDetail{Type: bgp.LeakLateral, LeakerASN: 200, VictimASN: 300}
+	tr.OnEvent(0, 0, "DE", "", bgp.EventUpdate, bgp.ClassificationRouteLeak, "20.0.0.0/16", 300, 0, leak)
+
+	events := tr.CriticalEvents()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 incidents, got %+v", events)
+	}
+	if ev := events[0]; ev.Classification != "route_leak" || ev.Leak == nil || ev.Leak.LeakerASN != 200 || ev.Locations != "DE" {
+		t.Errorf("unexpected leak incident: %+v", ev)
+	}
+	if ev := events[1]; ev.ASN != 100 || len(ev.Prefixes) != 2 || ev.ImpactedIPs != 65536+256 || ev.Locations != "Dallas, US" {
+		t.Errorf("unexpected outage incident: %+v", ev)
+	}
+	if len(opened) != 2 || opened[0].ID != 1 || opened[1].ID != 2 || len(updated) != 1 || len(updated[0].Prefixes) != 2 {
+		t.Errorf("unexpected incident callbacks: opened %+v, updated %+v", opened, updated)
+	}
+
+	// A prefix that turns into a hijack leaves the outage
+	tr.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationHijack, "1.2.3.0/24", 400, 0)
+	events = tr.CriticalEvents()
+	for _, ev := range events {
+		if ev.Classification == "outage" && (len(ev.Prefixes) != 1 || ev.ImpactedIPs != 65536) {
+			t.Errorf("expected the prefix to leave the outage, got %+v", ev)
+		}
+	}
+
+	clock.t = clock.t.Add(bgp.IncidentTTL)
+	if events := tr.CriticalEvents(); len(events) != 0 {
+		t.Errorf("expected incidents to expire, got %+v", events)
+	}
+	if len(resolved) != 0 {
+		t.Errorf("expected resolutions to wait for the next event, got %+v", resolved)
+	}
+	tr.OnEvent(0, 0, "FR", "", bgp.EventUpdate, bgp.ClassificationNone, "9.9.9.0/24", 500, 0)
+	if len(resolved) != 3 || resolved[0].ID != 1 || len(resolved[0].Prefixes) != 1 {
+		t.Errorf("unexpected resolved incidents: %+v", resolved)
+	}
+}
@@ -0,0 +1,144 @@
+package bgp
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+const (
+	// baselineHalfLife is how long it takes for old behavior to lose half of its weight.
+	baselineHalfLife = 24 * time.Hour
+	// baselineWarmupMinutes is the number of observed minutes before a baseline is trusted.
+	baselineWarmupMinutes = 60
+	// scoreWindowMinutes matches the aggregation window used by the classification rules.
+	scoreWindowMinutes = 10
+
+	// Standard deviation floors keep near-constant baselines from producing huge scores.
+	minCountStdDev  = 1.0
+	minOriginStdDev = 0.25
+
+	// anomalyScoreElevated is the score above which activity is no longer typical for a prefix.
+	anomalyScoreElevated = 2.0
+	// anomalyScoreUnusual is the score at which activity is flagged even at low volume.
+	anomalyScoreUnusual = 6.0
+)
+
+var baselineAlpha = 1 - math.Pow(0.5, 1/baselineHalfLife.Minutes())
+
+// BaselineEstablished reports whether the prefix has been observed long enough
+// for its anomaly score to be meaningful.
+func BaselineEstablished(state *bgpproto.PrefixState) bool {
+	return state.GetBaseline().GetSamples() >= baselineWarmupMinutes
+}
+
+// updateBaseline folds every completed minute before minuteTS into the prefix
+// baseline. Minutes with a bucket are samples; the idle minutes between them
+// only decay the baseline towards no activity and do not count towards warm-up.
+func updateBaseline(state *bgpproto.PrefixState, minuteTS int64) {
+	if state.Baseline == nil {
+		state.Baseline = &bgpproto.PrefixBaseline{
+			Announcements: &bgpproto.RateBaseline{},
+			Withdrawals:   &bgpproto.RateBaseline{},
+			PathChanges:   &bgpproto.RateBaseline{},
+			Origins:       &bgpproto.RateBaseline{},
+			LastMinuteTs:  minuteTS - 60,
+		}
+		return
+	}
+
+	b := state.Baseline
+	if minuteTS-b.LastMinuteTs <= 60 {
+		return
+	}
+
+	// Origins are a gauge rather than a counter, so the current view is used
+	// for every minute being folded.
+	origins := float64(countOrigins(state))
+
+	var active []int64
+	for ts := range state.Buckets {
+		if ts > b.LastMinuteTs && ts < minuteTS {
+			active = append(active, ts)
+		}
+	}
+	slices.Sort(active)
+
+	last := b.LastMinuteTs
+	for _, ts := range active {
+		decayIdle(b, (ts-last)/60-1, origins)
+
+		// Use a plain running mean until the decay rate takes over, so early
+		// samples are not biased towards zero.
+		alpha := math.Max(baselineAlpha, 1/float64(b.Samples+1))
+		bucket := state.Buckets[ts]
+		observe(b.Announcements, float64(bucket.Announcements), alpha)
+		observe(b.Withdrawals, float64(bucket.Withdrawals), alpha)
+		observe(b.PathChanges, float64(bucket.PathChanges), alpha)
+		observe(b.Origins, origins, alpha)
+		b.Samples++
+		last = ts
+	}
+	decayIdle(b, (minuteTS-last)/60-1, origins)
+	b.LastMinuteTs = minuteTS - 60
+}
+
+// decayIdle folds n minutes without activity into the baseline at the decay
+// rate, in closed form.
+func decayIdle(b *bgpproto.PrefixBaseline, n int64, origins float64) {
+	if n <= 0 {
+		return
+	}
+	observeN(b.Announcements, 0, baselineAlpha, n)
+	observeN(b.Withdrawals, 0, baselineAlpha, n)
+	observeN(b.PathChanges, 0, baselineAlpha, n)
+	observeN(b.Origins, origins, baselineAlpha, n)
+}
+
+// scoreWindow compares the current window against the prefix baseline and
+// returns the largest positive z-score across the tracked signals.
+func (c *Classifier) scoreWindow(state *bgpproto.PrefixState, s *prefixStats) (score float64, ready bool) {
+	if !BaselineEstablished(state) {
+		return 0, false
+	}
+	b := state.Baseline
+	score = math.Max(score, zScore(float64(s.totalAnn), b.Announcements, scoreWindowMinutes, minCountStdDev))
+	score = math.Max(score, zScore(float64(s.totalWith), b.Withdrawals, scoreWindowMinutes, minCountStdDev))
+	score = math.Max(score, zScore(float64(s.totalPath), b.PathChanges, scoreWindowMinutes, minCountStdDev))
+	score = math.Max(score, zScore(float64(len(s.uniqueASNs)), b.Origins, 1, minOriginStdDev))
+	return score, true
+}
+
+func observe(r *bgpproto.RateBaseline, x, alpha float64) {
+	diff := x - r.Mean
+	incr := alpha * diff
+	r.Mean += incr
+	r.Variance = (1 - alpha) * (r.Variance + diff*incr)
+}
+
+// observeN is observe applied n times with the same x and alpha. The distance
+// to x shrinks by (1-alpha) each time and the variance follows it.
+func observeN(r *bgpproto.RateBaseline, x, alpha float64, n int64) {
+	decay := math.Pow(1-alpha, float64(n))
+	diff := x - r.Mean
+	r.Mean = x - decay*diff
+	r.Variance = decay * (r.Variance + diff*diff*(1-decay))
+}
+
+// zScore scores x, a total over n minutes, against a per-minute baseline.
+func zScore(x float64, r *bgpproto.RateBaseline, n, minStdDev float64) float64 {
+	stdDev := math.Max(math.Sqrt(n*r.GetVariance()), minStdDev)
+	return (x - n*r.GetMean()) / stdDev
+}
+
+func countOrigins(state *bgpproto.PrefixState) int {
+	origins := make(map[uint32]struct{})
+	for _, attr := range state.PeerLastAttrs {
+		if attr.OriginAsn != 0 {
+			origins[attr.OriginAsn] = struct{}{}
+		}
+	}
+	return len(origins)
+}
@@ -0,0 +1,143 @@
+package bgp
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+func newBaselineTestClassifier() *Classifier {
+	return NewClassifier(nil, nil, nil, nil, func(string) uint32 { return 0 }, utils.NewLRUCache[string, *bgpproto.PrefixState](100), time.Now)
+}
+
+func TestClassifier_BaselineChattyPrefix(t *testing.T) {
+	c := newBaselineTestClassifier()
+	prefix := "8.8.8.0/24"
+	start := time.Now().Truncate(time.Hour)
+
+	// Six announcements per minute for three hours: plenty for the absolute Discovery threshold.
+	var last ClassificationType
+	for m := 0; m < 180; m++ {
+		for i := 0; i < 6; i++ {
+			ctx := &MessageContext{
+				Peer: fmt.Sprintf("peer%d", i), Host: "rrc00", PathStr: "[100 200]", OriginASN: 200,
+				Now: start.Add(time.Duration(m)*time.Minute + time.Duration(i*10)*time.Second),
+			}
+			ev, ok := c.ClassifyEvent(prefix, ctx)
+			last = ClassificationNone
+			if ok {
+				last = ev.ClassificationType
+			}
+		}
+	}
+
+	state, _ := c.GetPrefixState(prefix)
+	if !BaselineEstablished(state) {
+		t.Fatalf("expected baseline to be established, samples = %d", state.GetBaseline().GetSamples())
+	}
+	if mean := state.Baseline.Announcements.Mean; mean < 5.5 || mean > 6.5 {
+		t.Errorf("expected announcement mean near 6, got %f", mean)
+	}
+	if state.AnomalyScore >= anomalyScoreElevated {
+		t.Errorf("expected low anomaly score for steady traffic, got %f", state.AnomalyScore)
+	}
+	if last == ClassificationDiscovery {
+		t.Errorf("steady traffic should not stay classified as %s once a baseline exists", last)
+	}
+}
+
+func TestClassifier_BaselineQuietPrefix(t *testing.T) {
+	c := newBaselineTestClassifier()
+	prefix := "9.9.9.0/24"
+	start := time.Now().Truncate(time.Hour)
+
+	// One announcement every three minutes: enough active minutes for a
+	// baseline, far below the absolute Discovery threshold.
+	for m := 0; m < 3*baselineWarmupMinutes; m += 3 {
+		c.ClassifyEvent(prefix, &MessageContext{Peer: "peer0", Host: "rrc00", PathStr: "[100 200]", OriginASN: 200, Now: start.Add(time.Duration(m) * time.Minute)})
+	}
+
+	burst := start.Add(3*baselineWarmupMinutes*time.Minute + time.Minute)
+	var last ClassificationType
+	for i := 0; i < 15; i++ {
+		ctx := &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: "rrc00", PathStr: "[100 200]", OriginASN: 200,
+			Now: burst.Add(time.Duration(i) * time.Second),
+		}
+		if ev, ok := c.ClassifyEvent(prefix, ctx); ok {
+			last = ev.ClassificationType
+		}
+	}
+
+	state, _ := c.GetPrefixState(prefix)
+	if !BaselineEstablished(state) {
+		t.Fatalf("expected baseline to be established, samples = %d", state.GetBaseline().GetSamples())
+	}
+	if state.AnomalyScore < anomalyScoreUnusual {
+		t.Errorf("expected a high anomaly score for a burst on a quiet prefix, got %f", state.AnomalyScore)
+	}
+	if last != ClassificationDiscovery {
+		t.Errorf("expected %s, got %s", ClassificationDiscovery, last)
+	}
+}
+
+func TestClassifier_BaselineIdleGapIsNotWarmup(t *testing.T) {
+	c := newBaselineTestClassifier()
+	prefix := "9.9.8.0/24"
+	start := time.Now().Truncate(time.Hour)
+
+	// A single announcement, followed by two idle hours.
+	c.ClassifyEvent(prefix, &MessageContext{Peer: "peer0", Host: "rrc00", PathStr: "[100 200]", OriginASN: 200, Now: start})
+
+	burst := start.Add(2 * time.Hour)
+	for i := 0; i < 8; i++ {
+		ctx := &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: "rrc00", PathStr: "[100 200]", OriginASN: 200,
+			Now: burst.Add(time.Duration(i) * time.Second),
+		}
+		if ev, ok := c.ClassifyEvent(prefix, ctx); ok {
+			t.Errorf("expected no classification without a baseline, got %s", ev.ClassificationType)
+		}
+	}
+
+	state, _ := c.GetPrefixState(prefix)
+	if b := state.GetBaseline(); b.GetSamples() != 1 || BaselineEstablished(state) {
+		t.Errorf("expected the idle minutes not to count towards warm-up, samples = %d", b.GetSamples())
+	}
+	// The idle minutes still decay the one observed minute
+	if mean := state.Baseline.Announcements.Mean; mean <= 0 || mean >= 1 {
+		t.Errorf("expected the announcement mean to decay below 1, got %f", mean)
+	}
+}
+
+func TestObserveN(t *testing.T) {
+	stepped := &bgpproto.RateBaseline{Mean: 4, Variance: 2}
+	closed := &bgpproto.RateBaseline{Mean: 4, Variance: 2}
+	for i := 0; i < 500; i++ {
+		observe(stepped, 1, baselineAlpha)
+	}
+	observeN(closed, 1, baselineAlpha, 500)
+	if math.Abs(stepped.Mean-closed.Mean) > 1e-9 || math.Abs(stepped.Variance-closed.Variance) > 1e-9 {
+		t.Errorf("observeN = %+v, want %+v as with observe", closed, stepped)
+	}
+}
+
+func TestZScore(t *testing.T) {
+	r := &bgpproto.RateBaseline{}
+	for i := 0; i < 1000; i++ {
+		observe(r, float64(i%2), 0.01)
+	}
+	if r.Mean < 0.4 || r.Mean > 0.6 {
+		t.Errorf("expected mean near 0.5, got %f", r.Mean)
+	}
+	if z := zScore(5, r, 10, minCountStdDev); z > 1 {
+		t.Errorf("expected typical window to score low, got %f", z)
+	}
+	if z := zScore(50, r, 10, minCountStdDev); z < 10 {
+		t.Errorf("expected burst window to score high, got %f", z)
+	}
+}
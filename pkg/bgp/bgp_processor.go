@@ -15,6 +15,7 @@ import (
 	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
 	"github.com/sudorandom/bgp-stream/pkg/geoservice"
 	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
 )
 
 type BGPEventCallback func(lat, lng float64, cc, city string, eventType EventType, classificationType ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*LeakDetail)
@@ -28,17 +29,73 @@ type RISAnnouncement struct {
 }
 
 type RISMessageData struct {
+	Timestamp     float64           `json:"timestamp"`
+	ID            string            `json:"id"`
+	Type          string            `json:"type"`
 	Announcements []RISAnnouncement `json:"announcements"`
 	Withdrawals   []string          `json:"withdrawals"`
 	Path          []json.RawMessage `json:"path"`
 	Community     [][]interface{}   `json:"community"`
+	Origin        string            `json:"origin"`
 	Aggregator    string            `json:"aggregator"`
 	Peer          string            `json:"peer"`
+	PeerASN       string            `json:"peer_asn"`
 	Host          string            `json:"host"`
 	Med           int32             `json:"med"`
 	LocalPref     int32             `json:"local_pref"`
 }
 
+// Hops parses the AS path. A hop is a single ASN or the members of an AS set.
+func (d *RISMessageData) Hops() [][]uint32 {
+	hops := make([][]uint32, 0, len(d.Path))
+	for _, raw := range d.Path {
+		var asn uint32
+		if err := json.Unmarshal(raw, &asn); err == nil {
+			hops = append(hops, []uint32{asn})
+			continue
+		}
+		var set []uint32
+		if err := json.Unmarshal(raw, &set); err == nil {
+			hops = append(hops, set)
+		}
+	}
+	return hops
+}
+
+// Time returns the timestamp of the message, to the millisecond.
+func (d *RISMessageData) Time() time.Time {
+	return time.UnixMilli(int64(d.Timestamp*1000 + 0.5)).UTC()
+}
+
+// withoutPrefixes returns a copy of the message attributes with no
+// announcements or withdrawals.
+func (d *RISMessageData) withoutPrefixes() *RISMessageData {
+	c := *d
+	c.Announcements = nil
+	c.Withdrawals = nil
+	return &c
+}
+
+// EnrichedPrefix is what the processor made of one prefix of a RIS message.
+type EnrichedPrefix struct {
+	PendingEvent
+	Lat, Lng   float64
+	Country    string
+	City       string
+	Resolution geoservice.ResolutionType
+	RPKIStatus utils.RPKIStatus
+}
+
+// EnrichedMessage is a RIS message along with the classification and location
+// of its prefixes. A message whose prefixes are spread over several workers is
+// reported once per worker, each time with that worker's share of prefixes.
+type EnrichedMessage struct {
+	Data     *RISMessageData
+	Prefixes []EnrichedPrefix
+}
+
+type MessageCallback func(m *EnrichedMessage)
+
 type processorWorker struct {
 	classifier   *Classifier
 	recentlySeen *utils.LRUCache[uint32, struct {
@@ -49,7 +106,15 @@ type processorWorker struct {
 		Time   time.Time
 		Prefix string
 	}
-	taskCh chan *RISMessageData
+	taskCh    chan *RISMessageData
+	queryCh   chan prefixQuery
+	lastFlush time.Time
+}
+
+// prefixQuery asks the worker that owns a prefix for a copy of its state.
+type prefixQuery struct {
+	prefix string
+	reply  chan *bgpproto.PrefixState
 }
 
 type BGPProcessor struct {
@@ -59,20 +124,35 @@ type BGPProcessor struct {
 	asnMapping   *utils.ASNMapping
 	rpki         *utils.RPKIManager
 	onEvent      BGPEventCallback
+	onMessage    MessageCallback
 	prefixToIP   PrefixToIPConverter
 	timeProvider TimeProvider
+	peers        *PeerTracker
 
 	workers []*processorWorker
 
 	msgCount        atomic.Uint64
 	collectorCounts sync.Map // map[string]*atomic.Uint64
 	lastRateReport  time.Time
+	lastMsgCount    uint64
+	lastCollectors  map[string]uint64
 	conns           sync.Map // map[string]*websocket.Conn
 	stopCh          chan struct{}
 	mu              sync.Mutex
 	stopping        atomic.Bool
+	workersWG       sync.WaitGroup
+	statesFlushed   atomic.Uint64
+	// eventCounts counts the events reported per classification type
+	eventCounts [ClassificationBogon + 1]atomic.Uint64
 }
 
+const (
+	// stateFlushInterval is the longest a changed prefix state waits before it is written.
+	stateFlushInterval = 30 * time.Second
+	// stateFlushBatchSize triggers an early flush when a worker has this many changed states.
+	stateFlushBatchSize = 5000
+)
+
 func NewBGPProcessor(geo IPCoordsProvider, seenDB, stateDB *utils.DiskTrie, asnMapping *utils.ASNMapping, rpki *utils.RPKIManager, prefixToIP PrefixToIPConverter, timeProvider TimeProvider, onEvent BGPEventCallback) *BGPProcessor {
 	numWorkers := runtime.NumCPU()
 	if numWorkers < 4 {
@@ -88,15 +168,20 @@ func NewBGPProcessor(geo IPCoordsProvider, seenDB, stateDB *utils.DiskTrie, asnM
 		onEvent:        onEvent,
 		prefixToIP:     prefixToIP,
 		timeProvider:   timeProvider,
+		peers:          NewPeerTracker(),
 		lastRateReport: time.Now(),
+		lastCollectors: make(map[string]uint64),
 		workers:        make([]*processorWorker, numWorkers),
 		stopCh:         make(chan struct{}),
 	}
 
 	for i := 0; i < numWorkers; i++ {
 		prefixStates := utils.NewLRUCache[string, *bgpproto.PrefixState](1000000 / numWorkers)
+		classifier := NewClassifier(seenDB, stateDB, asnMapping, rpki, prefixToIP, prefixStates, timeProvider)
+		classifier.SetPeerTracker(p.peers)
+		classifier.EnableWriteBehind()
 		p.workers[i] = &processorWorker{
-			classifier: NewClassifier(seenDB, stateDB, asnMapping, rpki, prefixToIP, prefixStates, timeProvider),
+			classifier: classifier,
 			recentlySeen: utils.NewLRUCache[uint32, struct {
 				Time time.Time
 				Type EventType
@@ -105,8 +190,11 @@ func NewBGPProcessor(geo IPCoordsProvider, seenDB, stateDB *utils.DiskTrie, asnM
 				Time   time.Time
 				Prefix string
 			}),
-			taskCh: make(chan *RISMessageData, 10000),
+			taskCh:    make(chan *RISMessageData, 10000),
+			queryCh:   make(chan prefixQuery),
+			lastFlush: time.Now(),
 		}
+		p.workersWG.Add(1)
 		go p.runWorker(p.workers[i])
 	}
 
@@ -114,6 +202,10 @@ func NewBGPProcessor(geo IPCoordsProvider, seenDB, stateDB *utils.DiskTrie, asnM
 }
 
 func (p *BGPProcessor) runWorker(w *processorWorker) {
+	defer p.workersWG.Done()
+	// Whatever is still pending is written on the way out
+	defer p.flushWorkerStates(w)
+
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -127,17 +219,62 @@ func (p *BGPProcessor) runWorker(w *processorWorker) {
 				return
 			}
 			events := p.handleRISMessage(w, data)
+			var enriched *EnrichedMessage
+			if p.onMessage != nil {
+				enriched = &EnrichedMessage{Data: data, Prefixes: make([]EnrichedPrefix, 0, len(events))}
+			}
 			for _, e := range events {
-				if lat, lng, cc, city, _ := p.geo(e.IP); cc != "" {
+				p.eventCounts[e.ClassificationType].Add(1)
+				lat, lng, cc, city, res := p.geo(e.IP)
+				if cc != "" {
 					p.onEvent(lat, lng, cc, city, e.EventType, e.ClassificationType, e.Prefix, e.ASN, e.HistoricalASN, e.LeakDetail)
 				}
+				if enriched != nil {
+					enriched.Prefixes = append(enriched.Prefixes, EnrichedPrefix{
+						PendingEvent: e,
+						Lat:          lat,
+						Lng:          lng,
+						Country:      cc,
+						City:         city,
+						Resolution:   res,
+						RPKIStatus:   w.classifier.rpkiStatus(e.Prefix),
+					})
+				}
+			}
+			if enriched != nil {
+				p.onMessage(enriched)
+			}
+			if w.classifier.DirtyStates() >= stateFlushBatchSize {
+				p.flushWorkerStates(w)
 			}
 		case <-ticker.C:
 			p.processWorkerWithdrawals(w)
+			if time.Since(w.lastFlush) >= stateFlushInterval {
+				p.flushWorkerStates(w)
+			}
+		case q := <-w.queryCh:
+			q.reply <- w.classifier.clonePrefixState(q.prefix)
+		case <-p.stopCh:
+			return
 		}
 	}
 }
 
+// flushWorkerStates writes the prefix states changed by a worker to the stateDB
+// in a single batch.
+func (p *BGPProcessor) flushWorkerStates(w *processorWorker) {
+	w.lastFlush = time.Now()
+	batch := w.classifier.TakeDirtyStates()
+	if len(batch) == 0 {
+		return
+	}
+	if err := p.stateDB.BatchInsertRaw(batch); err != nil {
+		log.Printf("[BGP-STATE] Error writing %d prefix states: %v", len(batch), err)
+		return
+	}
+	p.statesFlushed.Add(uint64(len(batch)))
+}
+
 func (p *BGPProcessor) processWorkerWithdrawals(w *processorWorker) {
 	now := p.timeProvider()
 	for ip, entry := range w.pendingWithdrawals {
@@ -165,6 +302,12 @@ func (p *BGPProcessor) Close() {
 		}
 		return true
 	})
+	// Workers flush their pending prefix states before exiting, which must
+	// happen before the caller closes the stateDB.
+	p.workersWG.Wait()
+	if n := p.statesFlushed.Load(); n > 0 {
+		log.Printf("[BGP-STATE] Wrote %d prefix state updates", n)
+	}
 }
 
 func (p *BGPProcessor) isStopping() bool {
@@ -322,16 +465,42 @@ func (p *BGPProcessor) runMessageLoop(c *websocket.Conn, rrc string) {
 		}
 
 		if msg.Type == "ris_message" {
-			p.msgCount.Add(1)
+			p.Inject(&msg.Data)
+		}
+	}
+}
 
-			host := msg.Data.Host
-			if host == "" {
-				host = "unknown"
-			}
-			actual, _ := p.collectorCounts.LoadOrStore(host, &atomic.Uint64{})
-			actual.(*atomic.Uint64).Add(1)
+// Inject processes a RIS message as if it came from the live stream, for
+// sources other than RIS Live such as recordings and simulations. Messages
+// are classified at the time of the processor's time provider.
+func (p *BGPProcessor) Inject(data *RISMessageData) {
+	p.msgCount.Add(1)
 
-			p.dispatchMessage(&msg.Data)
+	host := data.Host
+	if host == "" {
+		host = "unknown"
+	}
+	actual, _ := p.collectorCounts.LoadOrStore(host, &atomic.Uint64{})
+	actual.(*atomic.Uint64).Add(1)
+
+	p.dispatchMessage(data)
+}
+
+// Drain waits until the workers have processed every message injected so
+// far.
+func (p *BGPProcessor) Drain() {
+	for _, w := range p.workers {
+		for len(w.taskCh) > 0 && !p.isStopping() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		// A worker answers queries between messages, so once it replies the
+		// message it was working on is done
+		q := prefixQuery{reply: make(chan *bgpproto.PrefixState, 1)}
+		select {
+		case w.queryCh <- q:
+			<-q.reply
+		case <-p.stopCh:
+			return
 		}
 	}
 }
@@ -342,21 +511,13 @@ func (p *BGPProcessor) dispatchMessage(data *RISMessageData) {
 	}
 	// Group prefixes by worker
 	workerTasks := make(map[int]*RISMessageData)
-
-	getWorker := func(prefix string) int {
-		ip := p.prefixToIP(prefix)
-		return int(utils.HashUint32(ip) % uint32(len(p.workers)))
-	}
+	getWorker := p.workerFor
 
 	for _, ann := range data.Announcements {
 		for _, prefix := range ann.Prefixes {
 			wIdx := getWorker(prefix)
 			if _, ok := workerTasks[wIdx]; !ok {
-				workerTasks[wIdx] = &RISMessageData{
-					Peer: data.Peer, Host: data.Host, Path: data.Path,
-					Community: data.Community, Aggregator: data.Aggregator,
-					Med: data.Med, LocalPref: data.LocalPref,
-				}
+				workerTasks[wIdx] = data.withoutPrefixes()
 			}
 			// Find or create announcement group for this worker task
 			found := false
@@ -379,11 +540,7 @@ func (p *BGPProcessor) dispatchMessage(data *RISMessageData) {
 	for _, prefix := range data.Withdrawals {
 		wIdx := getWorker(prefix)
 		if _, ok := workerTasks[wIdx]; !ok {
-			workerTasks[wIdx] = &RISMessageData{
-				Peer: data.Peer, Host: data.Host, Path: data.Path,
-				Community: data.Community, Aggregator: data.Aggregator,
-				Med: data.Med, LocalPref: data.LocalPref,
-			}
+			workerTasks[wIdx] = data.withoutPrefixes()
 		}
 		workerTasks[wIdx].Withdrawals = append(workerTasks[wIdx].Withdrawals, prefix)
 	}
@@ -393,6 +550,12 @@ func (p *BGPProcessor) dispatchMessage(data *RISMessageData) {
 	}
 }
 
+// workerFor returns the index of the worker that owns prefix.
+func (p *BGPProcessor) workerFor(prefix string) int {
+	ip := p.prefixToIP(prefix)
+	return int(utils.HashUint32(ip) % uint32(len(p.workers)))
+}
+
 func (p *BGPProcessor) ReportProcessorMetrics() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -403,7 +566,9 @@ func (p *BGPProcessor) ReportProcessorMetrics() {
 		elapsed = 1.0
 	}
 
-	globalCount := p.msgCount.Swap(0)
+	total := p.msgCount.Load()
+	globalCount := total - p.lastMsgCount
+	p.lastMsgCount = total
 	globalRate := float64(globalCount) / elapsed
 	log.Printf("[RIS] Global Message Rate: %.2f msg/s", globalRate)
 
@@ -421,8 +586,9 @@ func (p *BGPProcessor) ReportProcessorMetrics() {
 	}
 	var rates []colRate
 	p.collectorCounts.Range(func(key, value interface{}) bool {
-		count := value.(*atomic.Uint64).Swap(0)
-		rates = append(rates, colRate{key.(string), float64(count) / elapsed})
+		name, total := key.(string), value.(*atomic.Uint64).Load()
+		rates = append(rates, colRate{name, float64(total-p.lastCollectors[name]) / elapsed})
+		p.lastCollectors[name] = total
 		return true
 	})
 	sort.Slice(rates, func(i, j int) bool { return rates[i].rate > rates[j].rate })
@@ -440,6 +606,15 @@ func (p *BGPProcessor) ReportProcessorMetrics() {
 		log.Println(sb.String())
 	}
 
+	fullFeed := p.peers.FullFeedPeers()
+	if len(fullFeed) > 0 {
+		log.Printf("[BGP-VIS] Full-feed peers: %d across %d collectors", p.peers.TotalFullFeedPeers(), len(fullFeed))
+	}
+
+	if p.stateDB != nil {
+		log.Printf("[BGP-STATE] Prefix states written: %d", p.statesFlushed.Load())
+	}
+
 	p.lastRateReport = now
 }
 
@@ -526,7 +701,10 @@ func (p *BGPProcessor) handleAnnouncements(w *processorWorker, announcements []R
 	return events
 }
 
-func (p *BGPProcessor) handleRISMessage(w *processorWorker, data *RISMessageData) []PendingEvent {
+// NewMessageContext describes a RIS message received at now. The per-prefix
+// fields, such as the next hop and whether it is a withdrawal, are left for
+// the caller to set.
+func NewMessageContext(data *RISMessageData, now time.Time) *MessageContext {
 	var originASN uint32
 	if len(data.Path) > 0 {
 		last := data.Path[len(data.Path)-1]
@@ -536,7 +714,6 @@ func (p *BGPProcessor) handleRISMessage(w *processorWorker, data *RISMessageData
 		}
 	}
 
-	now := p.timeProvider()
 	ctx := &MessageContext{
 		Peer:       data.Peer,
 		Aggregator: data.Aggregator,
@@ -557,6 +734,13 @@ func (p *BGPProcessor) handleRISMessage(w *processorWorker, data *RISMessageData
 	if len(data.Community) > 0 {
 		ctx.CommStr = fmt.Sprintf("%v", data.Community)
 	}
+	return ctx
+}
+
+func (p *BGPProcessor) handleRISMessage(w *processorWorker, data *RISMessageData) []PendingEvent {
+	now := p.timeProvider()
+	ctx := NewMessageContext(data, now)
+	originASN := ctx.OriginASN
 
 	var events []PendingEvent
 
@@ -597,6 +781,126 @@ func (p *BGPProcessor) GetClassificationStats() (stats map[ClassificationType]in
 	return combinedStats, total
 }
 
+// SetFullBogons enables unallocated space checks on every worker.
+func (p *BGPProcessor) SetFullBogons(bogons *utils.FullBogons) {
+	for _, w := range p.workers {
+		w.classifier.SetFullBogons(bogons)
+	}
+}
+
+// SetMessageCallback reports every RIS message with what the workers made of
+// its prefixes. It must be set before Listen.
+func (p *BGPProcessor) SetMessageCallback(fn MessageCallback) {
+	p.onMessage = fn
+}
+
+// SetTransitionCallback reports classification transitions from every worker,
+// with the location of the prefix filled in.
+func (p *BGPProcessor) SetTransitionCallback(fn TransitionCallback) {
+	for _, w := range p.workers {
+		w.classifier.SetTransitionCallback(func(t *Transition) {
+			t.Lat, t.Lng, t.Country, t.City, _ = p.geo(t.IP)
+			fn(t)
+		})
+	}
+}
+
+// ProcessorMetrics is a point-in-time view of the processor. Message counts are
+// totals since startup.
+type ProcessorMetrics struct {
+	Messages          uint64            `json:"messages"`
+	CollectorMessages map[string]uint64 `json:"collector_messages"`
+	QueueDepths       []int             `json:"queue_depths"`
+	StatesFlushed     uint64            `json:"states_flushed"`
+	FullFeedPeers     int               `json:"full_feed_peers"`
+	// Events counts the events reported per classification key
+	Events map[string]uint64 `json:"events"`
+	// Caches holds the lookups of the in-memory caches, summed over workers
+	Caches map[string]utils.CacheStats `json:"caches"`
+	// DiskTries holds the lookups of the seen prefix, prefix state and VRP
+	// databases that are open
+	DiskTries    map[string]utils.DiskTrieStats `json:"disk_tries,omitempty"`
+	RPKIDataTime time.Time                      `json:"rpki_data_time,omitzero"`
+	ASNDataTime  time.Time                      `json:"asn_data_time,omitzero"`
+}
+
+func (p *BGPProcessor) Metrics() ProcessorMetrics {
+	m := ProcessorMetrics{
+		Messages:          p.msgCount.Load(),
+		CollectorMessages: make(map[string]uint64),
+		QueueDepths:       make([]int, len(p.workers)),
+		StatesFlushed:     p.statesFlushed.Load(),
+		FullFeedPeers:     p.peers.TotalFullFeedPeers(),
+		Events:            make(map[string]uint64),
+		Caches:            make(map[string]utils.CacheStats, 2),
+		DiskTries:         make(map[string]utils.DiskTrieStats, 3),
+	}
+	p.collectorCounts.Range(func(key, value interface{}) bool {
+		m.CollectorMessages[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	for i := range p.eventCounts {
+		if n := p.eventCounts[i].Load(); n > 0 {
+			m.Events[ClassificationType(i).Key()] = n
+		}
+	}
+	var states, seen utils.CacheStats
+	for i, w := range p.workers {
+		m.QueueDepths[i] = len(w.taskCh)
+		states = states.Add(w.classifier.prefixStates.Stats())
+		seen = seen.Add(w.recentlySeen.Stats())
+	}
+	m.Caches["prefix_states"] = states
+	m.Caches["recently_seen"] = seen
+
+	for name, db := range map[string]*utils.DiskTrie{"seen": p.seenDB, "state": p.stateDB} {
+		if db != nil {
+			m.DiskTries[name] = db.Stats()
+		}
+	}
+	if p.rpki != nil {
+		m.DiskTries["rpki"] = p.rpki.TrieStats()
+		m.RPKIDataTime = p.rpki.DataTime()
+	}
+	if p.asnMapping != nil {
+		m.ASNDataTime = p.asnMapping.DataTime()
+	}
+	return m
+}
+
+// PrefixState returns a copy of the current state of prefix. A state held in
+// memory is read through the worker that owns it, so it reflects every message
+// that worker has processed. Anything else is read from the stateDB.
+func (p *BGPProcessor) PrefixState(prefix string) (*bgpproto.PrefixState, bool) {
+	w := p.workers[p.workerFor(prefix)]
+	q := prefixQuery{prefix: prefix, reply: make(chan *bgpproto.PrefixState, 1)}
+	select {
+	case w.queryCh <- q:
+	case <-p.stopCh:
+		return nil, false
+	}
+	if state := <-q.reply; state != nil {
+		return state, true
+	}
+
+	if p.stateDB == nil {
+		return nil, false
+	}
+	data, err := p.stateDB.Get(prefix)
+	if err != nil || data == nil {
+		return nil, false
+	}
+	state := &bgpproto.PrefixState{}
+	if err := proto.Unmarshal(data, state); err != nil {
+		return nil, false
+	}
+	return state, true
+}
+
+func (p *BGPProcessor) GetPeerTracker() *PeerTracker {
+	return p.peers
+}
+
 func (p *BGPProcessor) SyncRPKI() error {
 	if p.rpki == nil {
 		return fmt.Errorf("rpki manager not initialized")
@@ -1,10 +1,15 @@
 package bgp
 
 import (
+	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
 	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestBGPProcessorDeduplication(t *testing.T) {
@@ -50,3 +55,94 @@ func TestBGPProcessorDeduplication(t *testing.T) {
 		t.Errorf("Expected 2 events, got %d", events)
 	}
 }
+
+func TestBGPProcessor_PrefixState(t *testing.T) {
+	stateDB, err := utils.OpenDiskTrie(filepath.Join(t.TempDir(), "test-state.db"))
+	if err != nil {
+		t.Fatalf("failed to open stateDB: %v", err)
+	}
+	defer func() { _ = stateDB.Close() }()
+
+	stored, _ := proto.Marshal(&bgpproto.PrefixState{LastOriginAsn: 300})
+	if err := stateDB.BatchInsertRaw(map[string][]byte{"9.9.9.0/24": stored}); err != nil {
+		t.Fatalf("failed to seed stateDB: %v", err)
+	}
+
+	onEvent := func(lat, lng float64, cc, city string, eventType EventType, classificationType ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*LeakDetail) {
+	}
+	geo := func(ip uint32) (float64, float64, string, string, geoservice.ResolutionType) {
+		return 37.0, -122.0, "US", "San Francisco", geoservice.ResGeoIP
+	}
+	p := NewBGPProcessor(geo, nil, stateDB, nil, nil, testPrefixToIP, time.Now, onEvent)
+	defer p.Close()
+
+	p.dispatchMessage(&RISMessageData{
+		Announcements: []RISAnnouncement{{NextHop: "192.0.2.1", Prefixes: []string{"8.8.8.0/24"}}},
+		Path:          []json.RawMessage{json.RawMessage("100"), json.RawMessage("200")},
+		Peer:          "peer0",
+		Host:          "rrc00",
+	})
+
+	// The state is read through the worker before it is ever written
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state, ok := p.PrefixState("8.8.8.0/24")
+		if ok {
+			if attr, ok := state.PeerLastAttrs["rrc00:peer0"]; !ok || attr.OriginAsn != 200 {
+				t.Errorf("unexpected live state: %+v", state)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the live state")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if state := loadTestState(t, stateDB, "8.8.8.0/24"); state != nil {
+		t.Errorf("expected the state to still be pending")
+	}
+
+	if state, ok := p.PrefixState("9.9.9.0/24"); !ok || state.LastOriginAsn != 300 {
+		t.Errorf("expected the stored state, got %+v", state)
+	}
+	if _, ok := p.PrefixState("1.1.1.0/24"); ok {
+		t.Errorf("expected no state for an unseen prefix")
+	}
+
+	m := p.Metrics()
+	if len(m.QueueDepths) != len(p.workers) {
+		t.Errorf("expected a queue depth per worker, got %v", m.QueueDepths)
+	}
+}
+
+func TestBGPProcessor_InjectDrain(t *testing.T) {
+	onEvent := func(lat, lng float64, cc, city string, eventType EventType, classificationType ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*LeakDetail) {
+	}
+	geo := func(ip uint32) (float64, float64, string, string, geoservice.ResolutionType) {
+		return 37.0, -122.0, "US", "San Francisco", geoservice.ResGeoIP
+	}
+	p := NewBGPProcessor(geo, nil, nil, nil, nil, testPrefixToIP, time.Now, onEvent)
+	defer p.Close()
+
+	prefixes := []string{"8.8.8.0/24", "9.9.9.0/24", "1.1.1.0/24", "1.0.0.0/24", "4.4.4.0/24"}
+	for _, prefix := range prefixes {
+		p.Inject(&RISMessageData{
+			Announcements: []RISAnnouncement{{NextHop: "192.0.2.1", Prefixes: []string{prefix}}},
+			Path:          []json.RawMessage{json.RawMessage("100"), json.RawMessage("200")},
+			Peer:          "peer0",
+			Host:          "rrc00",
+		})
+	}
+	p.Drain()
+
+	// Every message is processed once Drain returns
+	for _, prefix := range prefixes {
+		if _, ok := p.PrefixState(prefix); !ok {
+			t.Errorf("expected state for %s after Drain", prefix)
+		}
+	}
+	m := p.Metrics()
+	if m.Messages != uint64(len(prefixes)) || m.CollectorMessages["rrc00"] != uint64(len(prefixes)) {
+		t.Errorf("expected %d messages from rrc00, got %d (%v)", len(prefixes), m.Messages, m.CollectorMessages)
+	}
+}
@@ -1,6 +1,7 @@
 package bgp
 
 import (
+	"cmp"
 	"encoding/binary"
 	"fmt"
 	"log"
@@ -8,6 +9,7 @@ import (
 	"net/netip"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
@@ -27,6 +29,10 @@ type prefixStats struct {
 	uniqueHosts                              map[string]bool
 	withdrawnPeers                           map[string]bool
 	withdrawnHosts                           map[string]bool
+	anomalyScore                             float64
+	baselineReady                            bool
+	visibility, visibilityPeak               float64
+	hasVisibility                            bool
 }
 
 type Classifier struct {
@@ -35,6 +41,12 @@ type Classifier struct {
 	asnMapping *utils.ASNMapping
 	rpki       *utils.RPKIManager
 	prefixToIP PrefixToIPConverter
+	peers      *PeerTracker
+	bogons     atomic.Pointer[utils.FullBogons]
+
+	writeBehind  *writeBehind
+	onTransition TransitionCallback
+	onTrace      TraceCallback
 
 	classificationStats          map[ClassificationType]int
 	classificationUniquePrefixes map[ClassificationType]map[string]struct{}
@@ -59,38 +71,52 @@ func NewClassifier(seenDB, stateDB *utils.DiskTrie, asnMapping *utils.ASNMapping
 	}
 }
 
+// SetPeerTracker enables visibility tracking against a peer population that may
+// be shared with other classifiers.
+func (c *Classifier) SetPeerTracker(peers *PeerTracker) {
+	c.peers = peers
+}
+
+func (c *Classifier) GetPeerTracker() *PeerTracker {
+	return c.peers
+}
+
+// SetFullBogons enables matching against unallocated address and ASN space in
+// addition to the IANA special-purpose registries. It may be called while
+// events are classified, to swap in a refreshed list.
+func (c *Classifier) SetFullBogons(bogons *utils.FullBogons) {
+	c.bogons.Store(bogons)
+}
+
+func (c *Classifier) GetFullBogons() *utils.FullBogons {
+	return c.bogons.Load()
+}
+
 func (c *Classifier) GetPrefixState(prefix string) (*bgpproto.PrefixState, bool) {
 	return c.prefixStates.Get(prefix)
 }
 
+// RangePrefixStates calls fn for every prefix state held in memory, from the
+// least to the most recently used, until fn returns false.
+func (c *Classifier) RangePrefixStates(fn func(prefix string, state *bgpproto.PrefixState) bool) {
+	c.prefixStates.Range(fn)
+}
+
+// AddPrefixState puts a saved prefix state in memory, replacing any state the
+// prefix had.
+func (c *Classifier) AddPrefixState(prefix string, state *bgpproto.PrefixState) {
+	c.prefixStates.Add(prefix, state)
+}
+
 func (c *Classifier) ClassifyEvent(prefix string, ctx *MessageContext) (PendingEvent, bool) {
 	if strings.Contains(prefix, ":") {
-		return PendingEvent{}, false
+		return c.classifyIPv6(prefix, ctx)
 	}
-	state, ok := c.prefixStates.Get(prefix)
-	if !ok {
-		// Try to load from stateDB
-		if c.stateDB != nil {
-			data, err := c.stateDB.Get(prefix)
-			if err == nil && data != nil {
-				state = &bgpproto.PrefixState{}
-				if err := proto.Unmarshal(data, state); err != nil {
-					log.Printf("Error unmarshaling prefix state: %v", err)
-					state = nil
-				}
-			}
-		}
-
-		if state == nil {
-			state = &bgpproto.PrefixState{
-				PeerLastAttrs: make(map[string]*bgpproto.LastAttrs),
-				Buckets:       make(map[int64]*bgpproto.StatsBucket),
-				StartTimeTs:   ctx.Now.Unix(),
-			}
-		}
-		c.prefixStates.Add(prefix, state)
+	if c.peers != nil {
+		c.peers.Observe(ctx.Host, ctx.Peer, prefix, ctx.Now)
 	}
-
+	tr := c.tracer(prefix, ctx)
+	state := c.loadState(prefix, ctx.Now)
 	state.LastUpdateTs = ctx.Now.Unix()
 	bucket := c.getOrCreateBucket(state, ctx.Now)
 	bucket.TotalMessages++
@@ -101,29 +127,50 @@ func (c *Classifier) ClassifyEvent(prefix string, ctx *MessageContext) (PendingE
 	}
 
 	if ctx.IsWithdrawal {
+		tr.withdrawal(state.PeerLastAttrs[ctx.Host+":"+ctx.Peer])
 		c.handleWithdrawal(state, bucket, ctx)
 	} else {
 		// We always update peer attributes for consensus tracking
+		tr.announcement(state.PeerLastAttrs[ctx.Host+":"+ctx.Peer])
 		c.updateAnnouncementStats(state, bucket, ctx)
 		c.updateRPKIStatus(prefix, state, ctx)
+		if tr != nil {
+			tr.step(TraceRPKI, "origin AS%d is %s", ctx.OriginASN, utils.RPKIStatus(state.LastRpkiStatus))
+		}
+	}
+	if tr != nil {
+		tr.step(TraceBucket, "minute %s: %d msgs (%d ann, %d with)", ctx.Now.Truncate(time.Minute).Format("15:04"), bucket.TotalMessages, bucket.Announcements, bucket.Withdrawals)
 	}
 
 	ctx.LastRpkiStatus = state.LastRpkiStatus
 	ctx.LastOriginAsn = state.LastOriginAsn
+	visibility, hasVisibility := c.updateVisibility(state, ctx.Now)
+	prevType := ClassificationType(state.ClassifiedType)
 
 	// If already classified, emit the classification pulse immediately for this peer
 	if state.ClassifiedType != 0 {
-		// Recovery check: If it was an outage but we are seeing announcements now, reset classification
+		// Recovery check: If it was an outage but we are seeing announcements now, reset classification.
+		// When visibility is tracked, the prefix must also be carried by most full-feed peers again.
 		switch {
-		case ClassificationType(state.ClassifiedType) == ClassificationOutage && !ctx.IsWithdrawal:
+		case ClassificationType(state.ClassifiedType) == ClassificationOutage && !ctx.IsWithdrawal &&
+			(!hasVisibility || visibility >= partialOutageVisibility):
+			if tr != nil {
+				tr.step(TraceState, "%s cleared: the prefix is announced again", prevType)
+			}
 			state.ClassifiedType = 0
 			state.ClassifiedTimeTs = 0
 			state.UncategorizedCounted = false
 		case ctx.Now.Unix()-state.ClassifiedTimeTs > 600:
+			if tr != nil {
+				tr.step(TraceState, "%s expired after %ds", prevType, ctx.Now.Unix()-state.ClassifiedTimeTs)
+			}
 			state.ClassifiedType = 0
 			state.ClassifiedTimeTs = 0
 			state.UncategorizedCounted = false
 		default:
+			if tr != nil {
+				tr.step(TraceState, "held as %s for another %ds, rules not evaluated", prevType, 600-(ctx.Now.Unix()-state.ClassifiedTimeTs))
+			}
 			// Always emit updates for ongoing classifications to keep them active in the stream
 			var ld *LeakDetail
 			if state.LeakType != 0 || ClassificationType(state.ClassifiedType) == ClassificationDDoSMitigation {
@@ -140,12 +187,93 @@ func (c *Classifier) ClassifyEvent(prefix string, ctx *MessageContext) (PendingE
 				HistoricalASN:      historicalOriginAsn,
 				EventType:          ctx.EventType(),
 				ClassificationType: ClassificationType(state.ClassifiedType),
-				LeakDetail:         ld,
+				LeakDetail:         withStateDetail(state, ld),
 			}, true
 		}
 	}
 
-	return c.evaluatePrefixState(prefix, state, historicalOriginAsn, ctx)
+	ev, classified := c.evaluatePrefixState(prefix, state, historicalOriginAsn, ctx)
+	if ClassificationType(state.ClassifiedType) != prevType {
+		if tr != nil {
+			tr.step(TraceState, "%s -> %s", prevType, ClassificationType(state.ClassifiedType))
+		}
+		if c.onTransition != nil {
+			c.emitTransition(prefix, state, prevType, ev, classified, historicalOriginAsn, ctx)
+		}
+	}
+	return ev, classified
+}
+
+// classifyIPv6 only checks an IPv6 prefix and its path against the bogon
+// registries. No state is kept for IPv6, so nothing else is classified and
+// no transitions are reported.
+func (c *Classifier) classifyIPv6(prefix string, ctx *MessageContext) (PendingEvent, bool) {
+	reason, ok := c.isBogon(prefix, ctx)
+	if !ok {
+		return PendingEvent{}, false
+	}
+	return PendingEvent{
+		Prefix:             prefix,
+		ASN:                ctx.OriginASN,
+		EventType:          ctx.EventType(),
+		ClassificationType: ClassificationBogon,
+		LeakDetail:         &LeakDetail{Reason: reason},
+	}, true
+}
+
+// loadState returns the state of prefix from memory, the state database or a
+// fresh one starting at now, and marks it dirty.
+func (c *Classifier) loadState(prefix string, now time.Time) *bgpproto.PrefixState {
+	state, ok := c.prefixStates.Get(prefix)
+	if !ok {
+		state, _ = c.takeEvicted(prefix)
+		// Try to load from stateDB
+		if state == nil && c.stateDB != nil {
+			data, err := c.stateDB.Get(prefix)
+			if err == nil && data != nil {
+				state = &bgpproto.PrefixState{}
+				if err := proto.Unmarshal(data, state); err != nil {
+					log.Printf("Error unmarshaling prefix state: %v", err)
+					state = nil
+				}
+			}
+		}
+
+		if state == nil {
+			state = &bgpproto.PrefixState{
+				PeerLastAttrs: make(map[string]*bgpproto.LastAttrs),
+				Buckets:       make(map[int64]*bgpproto.StatsBucket),
+				StartTimeTs:   now.Unix(),
+			}
+		}
+		c.prefixStates.Add(prefix, state)
+	}
+	c.markDirty(prefix)
+	return state
+}
+
+// SeedRoute records a route from a RIB dump as the peer's current path for
+// prefix. Unlike ClassifyEvent it counts no activity and classifies nothing,
+// so a replay can start from the routing table instead of empty state.
+func (c *Classifier) SeedRoute(prefix string, ctx *MessageContext) {
+	if strings.Contains(prefix, ":") {
+		return
+	}
+	if c.peers != nil {
+		c.peers.Observe(ctx.Host, ctx.Peer, prefix, ctx.Now)
+	}
+	state := c.loadState(prefix, ctx.Now)
+	if ctx.Now.Unix() > state.LastUpdateTs {
+		state.LastUpdateTs = ctx.Now.Unix()
+	}
+	if state.PeerLastAttrs == nil {
+		state.PeerLastAttrs = make(map[string]*bgpproto.LastAttrs)
+	}
+	state.PeerLastAttrs[ctx.Host+":"+ctx.Peer] = newLastAttrs(ctx)
+	c.updateRPKIStatus(prefix, state, ctx)
+	if state.LastOriginAsn == 0 {
+		state.LastOriginAsn = ctx.OriginASN
+	}
 }
 
 func (c *Classifier) handleWithdrawal(state *bgpproto.PrefixState, bucket *bgpproto.StatsBucket, ctx *MessageContext) {
@@ -179,6 +307,14 @@ func (c *Classifier) updateRPKIStatus(prefix string, state *bgpproto.PrefixState
 	}
 }
 
+// rpkiStatus returns the last RPKI status of a prefix held in memory.
+func (c *Classifier) rpkiStatus(prefix string) utils.RPKIStatus {
+	if state, ok := c.prefixStates.Peek(prefix); ok {
+		return utils.RPKIStatus(state.LastRpkiStatus)
+	}
+	return utils.RPKIUnknown
+}
+
 func (c *Classifier) getOrCreateBucket(state *bgpproto.PrefixState, now time.Time) *bgpproto.StatsBucket {
 	minuteTS := now.Truncate(time.Minute).Unix()
 	if state.Buckets == nil {
@@ -186,12 +322,15 @@ func (c *Classifier) getOrCreateBucket(state *bgpproto.PrefixState, now time.Tim
 	}
 	bucket, ok := state.Buckets[minuteTS]
 	if !ok {
+		// A new minute means the previous ones are complete, fold them into the baseline
+		// before the cleanup below discards them.
+		updateBaseline(state, minuteTS)
 		bucket = &bgpproto.StatsBucket{}
 		state.Buckets[minuteTS] = bucket
 	}
 
 	// Also cleanup old buckets here to keep memory/size low
-	cutoff := now.Add(-10 * time.Minute).Unix()
+	cutoff := now.Add(-analysisWindow).Unix()
 	for ts := range state.Buckets {
 		if ts < cutoff {
 			delete(state.Buckets, ts)
@@ -213,7 +352,11 @@ func (c *Classifier) updateAnnouncementStats(state *bgpproto.PrefixState, bucket
 		c.compareAndUpdateBucketStats(bucket, last, ctx)
 	}
 
-	state.PeerLastAttrs[sessionKey] = &bgpproto.LastAttrs{
+	state.PeerLastAttrs[sessionKey] = newLastAttrs(ctx)
+}
+
+func newLastAttrs(ctx *MessageContext) *bgpproto.LastAttrs {
+	return &bgpproto.LastAttrs{
 		Path:         ctx.PathStr,
 		Communities:  ctx.CommStr,
 		NextHop:      ctx.NextHop,
@@ -258,6 +401,11 @@ func (c *Classifier) compareAndUpdateBucketStats(bucket *bgpproto.StatsBucket, l
 
 func (c *Classifier) evaluatePrefixState(prefix string, state *bgpproto.PrefixState, historicalOriginAsn uint32, ctx *MessageContext) (PendingEvent, bool) {
 	stats := c.aggregateRecentBuckets(state, ctx.Now, ctx.OriginASN)
+	stats.anomalyScore, stats.baselineReady = c.scoreWindow(state, &stats)
+	state.AnomalyScore = stats.anomalyScore
+	if HasVisibility(state) {
+		stats.visibility, stats.visibilityPeak, stats.hasVisibility = state.Visibility, state.VisibilityPeak, true
+	}
 
 	elapsed := float64(ctx.Now.Unix() - stats.earliestTS)
 	if elapsed < 60 {
@@ -267,8 +415,14 @@ func (c *Classifier) evaluatePrefixState(prefix string, state *bgpproto.PrefixSt
 		elapsed = 1
 	}
 
+	tr := c.tracer(prefix, ctx)
+	tr.stats(&stats, elapsed)
+
 	// Ensure we have seen enough messages over a small time window to classify
 	if elapsed < 60 && stats.totalMsgs < 5 {
+		if tr != nil {
+			tr.step(TraceState, "not evaluated: %d msgs in %.0fs, needs 5 or a minute of activity", stats.totalMsgs, elapsed)
+		}
 		return PendingEvent{}, false
 	}
 
@@ -277,6 +431,9 @@ func (c *Classifier) evaluatePrefixState(prefix string, state *bgpproto.PrefixSt
 	if classified {
 		if state.ClassifiedType != 0 {
 			if c.getPriority(anomType) <= c.getPriority(ClassificationType(state.ClassifiedType)) {
+				if tr != nil {
+					tr.step(TraceState, "%s kept over %s, which has no higher priority", ClassificationType(state.ClassifiedType), anomType)
+				}
 				// We already have a classification of equal or higher priority
 				// Just return the event for the current classification
 				var ld *LeakDetail
@@ -300,7 +457,7 @@ func (c *Classifier) evaluatePrefixState(prefix string, state *bgpproto.PrefixSt
 					HistoricalASN:      historicalOriginAsn,
 					EventType:          ctx.EventType(),
 					ClassificationType: ClassificationType(state.ClassifiedType),
-					LeakDetail:         ld,
+					LeakDetail:         withStateDetail(state, ld),
 				}, true
 			}
 		}
@@ -324,12 +481,18 @@ func (c *Classifier) getPriority(t ClassificationType) int {
 
 func (c *Classifier) aggregateRecentBuckets(state *bgpproto.PrefixState, now time.Time, currentOriginASN uint32) prefixStats {
 	for peer, attr := range state.PeerLastAttrs {
-		if now.Unix()-attr.LastUpdateTs > 3600 {
+		if now.Unix()-attr.LastUpdateTs > int64(peerAttrTTL.Seconds()) {
 			delete(state.PeerLastAttrs, peer)
 		}
 	}
+	return summarizeState(state, now, currentOriginASN)
+}
 
-	cutoff := now.Add(-10 * time.Minute).Unix()
+// summarizeState totals the buckets in the analysis window ending at now and
+// collects the peers and hosts that withdrew the prefix or announce it from
+// currentOriginASN. Unlike aggregateRecentBuckets it leaves state unchanged.
+func summarizeState(state *bgpproto.PrefixState, now time.Time, currentOriginASN uint32) prefixStats {
+	cutoff := now.Add(-analysisWindow).Unix()
 	s := prefixStats{
 		earliestTS:     now.Unix(),
 		uniqueHops:     make(map[string]bool),
@@ -385,18 +548,20 @@ func (c *Classifier) aggregateRecentBuckets(state *bgpproto.PrefixState, now tim
 }
 
 func (c *Classifier) findClassification(prefix string, s *prefixStats, elapsed float64, ctx *MessageContext) (ClassificationType, *LeakDetail, bool) {
+	tr := c.tracer(prefix, ctx)
+
 	// 1. Critical
 	if et, ld, ok := c.findCriticalAnomaly(prefix, s, elapsed, ctx); ok {
 		return et, ld, true
 	}
 
 	// 2. Bad
-	if et, ok := c.findBadAnomaly(s); ok {
+	if et, ok := c.findBadAnomaly(s, tr); ok {
 		return et, nil, true
 	}
 
 	// 3. Normal / Policy
-	if et, ok := c.findNormalAnomaly(s, elapsed); ok {
+	if et, ok := c.findNormalAnomaly(s, elapsed, tr); ok {
 		return et, nil, true
 	}
 
@@ -435,28 +600,65 @@ func (c *Classifier) findCriticalAnomaly(prefix string, s *prefixStats, elapsed
 	withdrawnPeerCount := len(s.withdrawnPeers)
 	withdrawnHostCount := len(s.withdrawnHosts)
 	totalKnownPeers := peerCount + withdrawnPeerCount
+	tr := c.tracer(prefix, ctx)
 
 	// 0. Bogon Detection
-	if c.isBogon(prefix, ctx) {
-		return ClassificationBogon, nil, true
+	reason, isBogon := c.isBogon(prefix, ctx)
+	if tr != nil {
+		tr.rule("bogon", isBogon, "%s", cmp.Or(reason, "no special-purpose or unallocated prefix or ASN"))
+	}
+	if isBogon {
+		return ClassificationBogon, &LeakDetail{Reason: reason}, true
 	}
 
 	// Outage heuristic based on host diversity and total peers tracking the prefix
 	// Industry standard: A prefix is considered in outage if it loses all its paths (peerCount == 0)
+	isOutage := false
 	if elapsed > 60 && totalKnownPeers > 0 && peerCount == 0 && withdrawnPeerCount > 0 {
 		if withdrawnPeerCount >= 3 && withdrawnHostCount >= 2 {
 			// Sufficient diversity across collectors and peers to confirm an outage
-			return ClassificationOutage, nil, true
+			isOutage = true
 		} else if withdrawnPeerCount >= totalKnownPeers && withdrawnHostCount >= 2 {
 			// For smaller prefixes (<= 2 peers), require all known peers and multiple hosts to have withdrawn
-			return ClassificationOutage, nil, true
+			isOutage = true
 		}
 	}
+	if tr != nil {
+		tr.rule("outage", isOutage, "%d of %d known peers withdrawn across %d hosts, %d announcing, %.0fs of activity (needs all withdrawn, 3 peers or every known one, 2 hosts, over a minute)",
+			withdrawnPeerCount, totalKnownPeers, withdrawnHostCount, peerCount, elapsed)
+	}
+	if isOutage {
+		return ClassificationOutage, nil, true
+	}
+
+	// Partial outage: visibility across the full-feed peer population dropped sharply,
+	// even though some peers still carry the prefix.
+	isPartialOutage := s.hasVisibility && elapsed > 60 && withdrawnHostCount >= 2 &&
+		s.visibility < partialOutageVisibility && s.visibilityPeak-s.visibility >= partialOutageDrop
+	switch {
+	case tr == nil:
+	case !s.hasVisibility:
+		tr.rule("partial outage", false, "visibility is not tracked")
+	default:
+		tr.rule("partial outage", isPartialOutage, "visibility %.0f%% from a peak of %.0f%%, %d withdrawn hosts (needs under %.0f%%, a drop of %.0f points, 2 hosts)",
+			s.visibility*100, s.visibilityPeak*100, withdrawnHostCount, partialOutageVisibility*100, partialOutageDrop*100)
+	}
+	if isPartialOutage {
+		return ClassificationOutage, nil, true
+	}
 
 	historicalOriginAsn := c.getHistoricalASN(prefix)
 
 	// 0.5 DDoS Mitigation Detection
-	if ld, ok := c.detectDDoSMitigation(prefix, ctx, historicalOriginAsn); ok {
+	ld, isMitigation := c.detectDDoSMitigation(prefix, ctx, historicalOriginAsn)
+	if tr != nil {
+		detail := "no flowspec or blackhole community, not a host route, no scrubber as origin or prepended upstream"
+		if isMitigation {
+			detail = fmt.Sprintf("%s, leaker AS%d, victim AS%d", ld.Type, ld.LeakerASN, ld.VictimASN)
+		}
+		tr.rule("DDoS mitigation", isMitigation, "%s", detail)
+	}
+	if isMitigation {
 		return ClassificationDDoSMitigation, ld, true
 	}
 
@@ -474,7 +676,11 @@ func (c *Classifier) findCriticalAnomaly(prefix string, s *prefixStats, elapsed
 }
 
 func (c *Classifier) detectHijack(prefix string, peerCount, hostCount int, ctx *MessageContext) (ClassificationType, *LeakDetail, bool) {
+	tr := c.tracer(prefix, ctx)
 	if ctx.OriginASN == 0 || (utils.RPKIStatus(ctx.LastRpkiStatus) != utils.RPKIInvalidASN && utils.RPKIStatus(ctx.LastRpkiStatus) != utils.RPKIInvalidMaxLength) {
+		if tr != nil {
+			tr.rule("hijack", false, "origin AS%d is RPKI %s, not invalid", ctx.OriginASN, utils.RPKIStatus(ctx.LastRpkiStatus))
+		}
 		return ClassificationNone, nil, false
 	}
 
@@ -488,14 +694,27 @@ func (c *Classifier) detectHijack(prefix string, peerCount, hostCount int, ctx *
 	// Transition Hijack (Highest Signal)
 	isTransition := historicalASN != 0 && historicalASN != ctx.OriginASN
 	if isTransition {
-		return c.detectTransitionHijack(prefix, peerCount, hostCount, ctx.OriginASN, historicalASN)
+		anom, ld, ok := c.detectTransitionHijack(prefix, peerCount, hostCount, ctx.OriginASN, historicalASN)
+		if tr != nil {
+			tr.rule("hijack", ok, "RPKI invalid origin AS%d replaces historical AS%d (siblings: %v) with %d peers/%d hosts (needs 3/2)",
+				ctx.OriginASN, historicalASN, c.isSibling(ctx.OriginASN, historicalASN), peerCount, hostCount)
+		}
+		return anom, ld, ok
 	}
 
 	// New Prefix Hijack (RPKI Invalid but never seen before)
 	if historicalASN == 0 {
-		return c.detectNewPrefixHijack(prefix, peerCount, hostCount, ctx.OriginASN, expectedASN)
+		anom, ld, ok := c.detectNewPrefixHijack(prefix, peerCount, hostCount, ctx.OriginASN, expectedASN)
+		if tr != nil {
+			tr.rule("hijack", ok, "RPKI invalid origin AS%d on a prefix never seen before, expected AS%d, with %d peers/%d hosts (needs 15/5)",
+				ctx.OriginASN, expectedASN, peerCount, hostCount)
+		}
+		return anom, ld, ok
 	}
 
+	if tr != nil {
+		tr.rule("hijack", false, "RPKI invalid, but AS%d is the historical origin", ctx.OriginASN)
+	}
 	return ClassificationNone, nil, false
 }
 
@@ -549,13 +768,22 @@ func (c *Classifier) detectNewPrefixHijack(prefix string, peerCount, hostCount i
 }
 
 func (c *Classifier) detectRouteLeak(prefix string, peerCount, hostCount int, ctx *MessageContext) (ClassificationType, *LeakDetail, bool) {
+	tr := c.tracer(prefix, ctx)
 	ld, ok := c.hasRouteLeak(ctx)
 	if !ok {
+		if tr != nil {
+			tr.rule("route leak", false, "path %s is valley-free", cmp.Or(ctx.PathStr, "[]"))
+		}
 		return ClassificationNone, nil, false
 	}
 
 	// Consensus requirement for path violations to filter out terminal edge/collector leaks.
-	if peerCount >= 3 && hostCount >= 2 {
+	hasConsensus := peerCount >= 3 && hostCount >= 2
+	if tr != nil {
+		tr.rule("route leak", hasConsensus, "%s by AS%d towards AS%d in %s, seen by %d peers/%d hosts (needs 3/2)",
+			ld.Type, ld.LeakerASN, ld.VictimASN, ctx.PathStr, peerCount, hostCount)
+	}
+	if hasConsensus {
 		c.logRouteLeak(prefix, ld)
 		return ClassificationRouteLeak, ld, true
 	}
@@ -580,8 +808,9 @@ func (c *Classifier) isSibling(asn1, asn2 uint32) bool {
 	name1 := c.asnMapping.GetName(asn1)
 	name2 := c.asnMapping.GetName(asn2)
 
-	// Only use heuristic if both names are reasonable length (e.g. not just "A") and both are valid
-	if name1 != StrUnknown && name2 != StrUnknown {
+	// Only use heuristic if both names are reasonable length (e.g. not just "A") and both are valid.
+	// Networks with only a hardcoded organization have no name.
+	if name1 != StrUnknown && name2 != StrUnknown && name1 != "" && name2 != "" {
 		n1 := strings.Fields(strings.ToLower(name1))
 		n2 := strings.Fields(strings.ToLower(name2))
 
@@ -726,9 +955,53 @@ func (c *Classifier) isCloud(asn uint32) bool {
 	}
 }
 
-func (c *Classifier) findBadAnomaly(s *prefixStats) (ClassificationType, bool) {
+// NetworkRoles are the lists of networks the classifier treats specially.
+type NetworkRoles struct {
+	// Tier1 networks are the transit-free top of the route leak valley
+	Tier1 bool `json:"tier1"`
+	// LargeNetwork covers the Tier-1s and major regional backbones
+	LargeNetwork bool `json:"large_network"`
+	// Cloud networks are major clouds and CDNs
+	Cloud bool `json:"cloud"`
+	// DDoSProvider networks may originate or carry other networks' prefixes
+	// for scrubbing without it being a hijack
+	DDoSProvider bool `json:"ddos_provider"`
+}
+
+// NetworkRoles returns which of the classifier's lists asn is on.
+func (c *Classifier) NetworkRoles(asn uint32) NetworkRoles {
+	return NetworkRoles{
+		Tier1:        c.isTier1(asn),
+		LargeNetwork: c.isLargeNetwork(asn),
+		Cloud:        c.isCloud(asn),
+		DDoSProvider: c.isDDoSProvider(asn),
+	}
+}
+
+// Siblings returns the other ASNs the classifier considers part of the same
+// organization as asn, by organization ID or name.
+func (c *Classifier) Siblings(asn uint32) []uint32 {
+	if c.asnMapping == nil {
+		return nil
+	}
+	var siblings []uint32
+	for _, other := range c.asnMapping.ASNs() {
+		if other != asn && c.isSibling(asn, other) {
+			siblings = append(siblings, other)
+		}
+	}
+	return siblings
+}
+
+func (c *Classifier) findBadAnomaly(s *prefixStats, tr *tracer) (ClassificationType, bool) {
 	isNextHopOsc := len(s.uniqueHops) > 1 && s.totalHop >= 10 && s.totalPath <= 2
 	isLinkFlap := s.totalWith >= 5 && float64(s.totalAnn)/float64(s.totalWith) < 2.0
+	if tr != nil {
+		tr.rule("next-hop oscillation", isNextHopOsc,
+			"%d next hops, %d next-hop changes, %d path changes (needs 2, 10 and at most 2)", len(s.uniqueHops), s.totalHop, s.totalPath)
+		tr.rule("link flap", isLinkFlap,
+			"%d withdrawals, %d announcements (needs 5 withdrawals and under 2 announcements for each)", s.totalWith, s.totalAnn)
+	}
 
 	if isNextHopOsc || isLinkFlap {
 		return ClassificationFlap, true
@@ -737,10 +1010,19 @@ func (c *Classifier) findBadAnomaly(s *prefixStats) (ClassificationType, bool) {
 	return ClassificationNone, false
 }
 
-func (c *Classifier) findNormalAnomaly(s *prefixStats, elapsed float64) (ClassificationType, bool) {
+func (c *Classifier) findNormalAnomaly(s *prefixStats, elapsed float64, tr *tracer) (ClassificationType, bool) {
 	isPathHunting := s.totalAnn >= 5 && s.totalIncreases >= 2 && s.totalWith >= 1
 	isPolicyChurn := s.totalComm >= 10 || (s.totalPath >= 10 && s.totalIncreases+s.totalDecreases <= 2) || (s.totalMed+s.totalLP >= 5 && s.totalPath <= 5)
 	isPathLengthOsc := (s.totalIncreases+s.totalDecreases) >= 5 && float64(s.totalIncreases+s.totalDecreases)/elapsed > 0.01
+	if tr != nil {
+		tr.rule("path hunting", isPathHunting,
+			"%d announcements, %d path length increases, %d withdrawals (needs 5, 2 and 1)", s.totalAnn, s.totalIncreases, s.totalWith)
+		tr.rule("policy churn", isPolicyChurn,
+			"%d community changes, %d path changes with %d length changes, %d MED/local pref changes (needs 10 community changes, 10 path changes with at most 2 length changes, or 5 MED/local pref changes with at most 5 path changes)",
+			s.totalComm, s.totalPath, s.totalIncreases+s.totalDecreases, s.totalMed+s.totalLP)
+		tr.rule("path length oscillation", isPathLengthOsc,
+			"%d path length changes in %.0fs (needs 5 at over 0.01/s)", s.totalIncreases+s.totalDecreases, elapsed)
+	}
 
 	if isPathHunting {
 		return ClassificationPathHunting, true
@@ -751,7 +1033,24 @@ func (c *Classifier) findNormalAnomaly(s *prefixStats, elapsed float64) (Classif
 
 	// Discovery as the catch-all for high volume activity (>= 25 messages)
 	// that didn't match any "Bad" anomaly or specific "Normal" pattern.
-	if s.totalMsgs >= 25 {
+	// Once a prefix has a baseline, the volume must also be unusual for that prefix,
+	// so naturally chatty prefixes (CDNs, anycast) don't stay permanently flagged.
+	isVolume := s.totalMsgs >= 25 && (!s.baselineReady || s.anomalyScore >= anomalyScoreElevated)
+	if tr != nil {
+		tr.rule("discovery volume", isVolume,
+			"%d msgs, anomaly score %.1f (needs 25, and a score of %.1f once the baseline is ready)", s.totalMsgs, s.anomalyScore, anomalyScoreElevated)
+	}
+	if isVolume {
+		return ClassificationDiscovery, true
+	}
+
+	// Quiet prefixes never reach the absolute threshold, so rely on the baseline alone.
+	isUnusual := s.baselineReady && s.totalMsgs >= 5 && s.anomalyScore >= anomalyScoreUnusual
+	if tr != nil {
+		tr.rule("discovery baseline", isUnusual,
+			"%d msgs, anomaly score %.1f (needs a ready baseline, 5 msgs and a score of %.1f)", s.totalMsgs, s.anomalyScore, anomalyScoreUnusual)
+	}
+	if isUnusual {
 		return ClassificationDiscovery, true
 	}
 	return ClassificationNone, false
@@ -800,6 +1099,7 @@ func (c *Classifier) RecordClassification(prefix string, state *bgpproto.PrefixS
 		state.LeakType = int32(ld.Type)
 		state.LeakerAsn = ld.LeakerASN
 		state.VictimAsn = ld.VictimASN
+		state.BogonReason = ld.Reason
 	}
 
 	if anomType == ClassificationDDoSMitigation {
@@ -824,8 +1124,8 @@ func (c *Classifier) RecordClassification(prefix string, state *bgpproto.PrefixS
 		}
 	}
 
-	// Persist state if we have a stateDB
-	if c.stateDB != nil {
+	// Persist state if we have a stateDB, unless the write is deferred
+	if c.stateDB != nil && c.writeBehind == nil {
 		if data, err := proto.Marshal(state); err == nil {
 			_ = c.stateDB.Put(prefix, data)
 		}
@@ -838,8 +1138,33 @@ func (c *Classifier) RecordClassification(prefix string, state *bgpproto.PrefixS
 		HistoricalASN:      historicalOriginAsn,
 		EventType:          ctx.EventType(),
 		ClassificationType: anomType,
-		LeakDetail:         ld,
+		LeakDetail:         withStateDetail(state, ld),
+	}
+}
+
+// withStateDetail attaches details kept in the prefix state, such as outage
+// visibility and bogon reasons, to the event detail of a classified prefix.
+func withStateDetail(state *bgpproto.PrefixState, ld *LeakDetail) *LeakDetail {
+	switch ClassificationType(state.ClassifiedType) {
+	case ClassificationOutage:
+		if !HasVisibility(state) {
+			return ld
+		}
+		if ld == nil {
+			ld = &LeakDetail{}
+		}
+		ld.Visibility = state.Visibility
+		ld.PeakVisibility = state.VisibilityPeak
+	case ClassificationBogon:
+		if state.BogonReason == "" {
+			return ld
+		}
+		if ld == nil {
+			ld = &LeakDetail{}
+		}
+		ld.Reason = state.BogonReason
 	}
+	return ld
 }
 
 func (c *Classifier) GetClassificationStats() (stats map[ClassificationType]int, totalEvents int) {
@@ -854,53 +1179,48 @@ func (c *Classifier) GetClassificationStats() (stats map[ClassificationType]int,
 	return statsCopy, c.totalClassificationEvents
 }
 
-func (c *Classifier) isBogon(prefix string, ctx *MessageContext) bool {
-	// Check AS Path for Private ASNs
+// SetClassificationStats replaces the counts returned by
+// GetClassificationStats, such as with ones saved by an earlier run.
+func (c *Classifier) SetClassificationStats(stats map[ClassificationType]int, totalEvents int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.classificationStats = make(map[ClassificationType]int, len(stats))
+	for k, v := range stats {
+		c.classificationStats[k] = v
+	}
+	c.totalClassificationEvents = totalEvents
+}
+
+func (c *Classifier) isBogon(prefix string, ctx *MessageContext) (string, bool) {
+	bogons := c.bogons.Load()
+	// Check AS Path for special-purpose and unallocated ASNs
 	if ctx.PathStr != "" {
 		fields := strings.Fields(strings.Trim(ctx.PathStr, "[]"))
 		for _, f := range fields {
 			var asn uint32
 			if _, err := fmt.Sscanf(f, "%d", &asn); err == nil {
-				// Private ASNs: 64512-65534, 4200000000-4294967294
-				if (asn >= 64512 && asn <= 65534) || (asn >= 4200000000 && asn <= 4294967294) {
-					return true
+				if reason, ok := utils.SpecialPurposeASN(asn); ok {
+					return fmt.Sprintf("AS%d in path: %s", asn, reason), true
+				}
+				if bogons != nil && bogons.IsUnallocatedASN(asn) {
+					return fmt.Sprintf("AS%d in path: unallocated ASN (RIR delegated stats)", asn), true
 				}
 			}
 		}
 	}
 
-	// Check for bogon prefixes
+	// Check for special-purpose and unallocated prefixes
 	pfx, err := netip.ParsePrefix(prefix)
 	if err != nil {
-		return false
+		return "", false
 	}
-
-	ip := pfx.Addr()
-	if ip.IsLoopback() || ip.IsMulticast() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsPrivate() {
-		return true
+	if reason, ok := utils.SpecialPurposePrefix(pfx); ok {
+		return reason, true
 	}
-
-	// TEST-NET-1, TEST-NET-2, TEST-NET-3
-	// 192.0.2.0/24, 198.51.100.0/24, 203.0.113.0/24
-	if ip.Is4() {
-		b := ip.As4()
-		if b[0] == 192 && b[1] == 0 && b[2] == 2 {
-			return true
-		}
-		if b[0] == 198 && b[1] == 51 && b[2] == 100 {
-			return true
-		}
-		if b[0] == 203 && b[1] == 0 && b[2] == 113 {
-			return true
-		}
-
-		// Carrier-grade NAT 100.64.0.0/10
-		if b[0] == 100 && (b[1]&0b11000000) == 64 {
-			return true
-		}
+	if bogons != nil && bogons.IsUnallocatedPrefix(pfx) {
+		return "Unallocated address space (RIR delegated stats)", true
 	}
-
-	return false
+	return "", false
 }
 
 func (c *Classifier) isDDoSProvider(asn uint32) bool {
@@ -98,6 +98,19 @@ func TestClassifier_SiblingRouteLeak(t *testing.T) {
 	if _, ok := c.hasRouteLeak(ctx3); ok {
 		t.Errorf("Expected NO route leak for sibling endpoints (via Name Fallback) [2914 100 2915], but one was detected")
 	}
+
+	// Test 4: Networks known only by their OrgID have no name, which must not
+	// make them siblings of each other
+	utils.SetASNOrgID(m, 3356, "ORG-LUMEN")
+	utils.SetASNOrgID(m, 6453, "ORG-TATA")
+
+	ctx4 := &MessageContext{PathStr: "[3356 100 6453]"}
+	if _, ok := c.hasRouteLeak(ctx4); !ok {
+		t.Errorf("Expected route leak for nameless endpoints of different orgs [3356 100 6453] but got none")
+	}
+	if c.isSibling(3356, 6453) {
+		t.Errorf("Expected nameless ASNs of different orgs not to be siblings")
+	}
 }
 
 func TestClassifier_FindCriticalAnomaly_Outage(t *testing.T) {
@@ -436,7 +449,7 @@ func TestClassifier_FindCriticalAnomaly_DDoS_Detailed(t *testing.T) {
 		},
 		{
 			name:         "RTBH via /128 IPv6",
-			prefix:       "2001:db8::1/128",
+			prefix:       "2606:4700::1/128",
 			commStr:      "",
 			wantType:     ClassificationDDoSMitigation,
 			wantLeakType: DDoSRTBH,
@@ -522,3 +535,145 @@ func TestClassifier_FindCriticalAnomaly_DDoS_Detailed(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifier_BogonTakesPrecedenceOverRTBH(t *testing.T) {
+	c := NewClassifier(nil, nil, nil, nil, nil, nil, time.Now)
+	s := &prefixStats{
+		uniquePeers: map[string]bool{"p1": true},
+		uniqueHosts: map[string]bool{"h1": true},
+	}
+	// A blackholed host route in documentation space is reported as a bogon
+	ctx := &MessageContext{OriginASN: 13335, PathStr: "[174 13335]", CommStr: "65535:666", Now: time.Now()}
+	for _, prefix := range []string{"192.0.2.1/32", "2001:db8::1/128"} {
+		gotType, _, ok := c.findCriticalAnomaly(prefix, s, 65.0, ctx)
+		if !ok || gotType != ClassificationBogon {
+			t.Errorf("%s: Expected %v, got %v (ok=%v)", prefix, ClassificationBogon, gotType, ok)
+		}
+	}
+
+	gotType, gotLD, ok := c.findCriticalAnomaly("1.1.1.1/32", s, 65.0, ctx)
+	if !ok || gotType != ClassificationDDoSMitigation || gotLD == nil || gotLD.Type != DDoSRTBH {
+		t.Errorf("Expected RTBH outside special-purpose space, got %v (ok=%v)", gotType, ok)
+	}
+}
+
+func TestClassifier_IsBogon(t *testing.T) {
+	c := NewClassifier(nil, nil, nil, nil, nil, nil, time.Now)
+	c.SetFullBogons(utils.NewFullBogons(
+		[]utils.Range{{Start: 0x01000000, End: 0x01FFFFFF}}, // 1.0.0.0/8
+		[]utils.Range{{Start: 1, End: 65535}},
+	))
+
+	tests := []struct {
+		name    string
+		prefix  string
+		pathStr string
+		reason  string
+	}{
+		{"Clean", "1.1.1.0/24", "[174 13335]", ""},
+		{"AS_TRANS", "1.1.1.0/24", "[174 23456 13335]", "AS23456 in path: AS_TRANS (RFC 6793)"},
+		{"AS0", "1.1.1.0/24", "[174 0]", "AS0 in path: AS0, reserved (RFC 7607)"},
+		{"Private ASN", "1.1.1.0/24", "[174 65001]", "AS65001 in path: Private-Use ASN (RFC 6996)"},
+		{"Unallocated ASN", "1.1.1.0/24", "[174 200000]", "AS200000 in path: unallocated ASN (RIR delegated stats)"},
+		{"Private Prefix", "192.168.1.0/24", "[174 13335]", "Private-Use (RFC 1918) in 192.168.0.0/16"},
+		{"Unallocated Prefix", "5.5.5.0/24", "[174 13335]", "Unallocated address space (RIR delegated stats)"},
+		{"IPv6 Documentation", "2001:db8::/48", "[174 13335]", "Documentation (RFC 3849) in 2001:db8::/32"},
+		{"IPv6 ULA", "fd00:1::/48", "[174 13335]", "Unique-Local (RFC 4193) in fc00::/7"},
+		{"IPv6 Private ASN", "2606:4700::/32", "[174 65001]", "AS65001 in path: Private-Use ASN (RFC 6996)"},
+		{"IPv6 Clean", "2606:4700::/32", "[174 13335]", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := c.isBogon(tt.prefix, &MessageContext{PathStr: tt.pathStr})
+			if ok != (tt.reason != "") || reason != tt.reason {
+				t.Errorf("isBogon() = %q, %v; want %q", reason, ok, tt.reason)
+			}
+		})
+	}
+}
+
+func TestClassifier_IPv6Bogon(t *testing.T) {
+	c := newBaselineTestClassifier()
+	ctx := &MessageContext{Now: time.Now(), Peer: "peer1", Host: "rrc00", PathStr: "[174 13335]", OriginASN: 13335}
+
+	ev, ok := c.ClassifyEvent("fe80::/64", ctx)
+	if !ok || ev.ClassificationType != ClassificationBogon || ev.LeakDetail == nil || ev.LeakDetail.Reason != "Link-Local Unicast (RFC 4291) in fe80::/10" {
+		t.Errorf("expected a link-local bogon, got %+v (classified %v)", ev, ok)
+	}
+	if _, ok := c.ClassifyEvent("2606:4700::/32", ctx); ok {
+		t.Error("expected a clean IPv6 prefix not to be classified")
+	}
+	if _, ok := c.GetPrefixState("fe80::/64"); ok {
+		t.Error("expected no state to be kept for IPv6")
+	}
+}
+
+func TestClassifier_SeedRoute(t *testing.T) {
+	c := newBaselineTestClassifier()
+	c.SetPeerTracker(NewPeerTracker())
+	prefix := "10.2.0.0/16"
+	dump := time.Now().Truncate(time.Hour)
+	for i := range 3 {
+		c.SeedRoute(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: "rrc00", PathStr: "[100 200]", PathLen: 2, OriginASN: 200, Now: dump,
+		})
+	}
+
+	state, ok := c.GetPrefixState(prefix)
+	if !ok {
+		t.Fatal("expected the seeded prefix to have state")
+	}
+	if len(state.PeerLastAttrs) != 3 || state.LastOriginAsn != 200 || state.LastUpdateTs != dump.Unix() {
+		t.Errorf("unexpected seeded state %+v", state)
+	}
+	if len(state.Buckets) != 0 || state.ClassifiedType != 0 {
+		t.Errorf("expected seeding to count no activity, got %d buckets, type %d", len(state.Buckets), state.ClassifiedType)
+	}
+
+	// Updates are compared against the seeded paths
+	now := dump.Add(time.Minute)
+	c.ClassifyEvent(prefix, &MessageContext{Peer: "peer0", Host: "rrc00", PathStr: "[100 200]", PathLen: 2, OriginASN: 200, Now: now})
+	c.ClassifyEvent(prefix, &MessageContext{Peer: "peer1", Host: "rrc00", PathStr: "[300 200]", PathLen: 2, OriginASN: 200, Now: now})
+	if b := state.Buckets[now.Truncate(time.Minute).Unix()]; b == nil || b.Announcements != 2 || b.PathChanges != 1 {
+		t.Errorf("expected two announcements with one path change, got %+v", b)
+	}
+}
+
+func TestClassifier_NetworkRolesAndSiblings(t *testing.T) {
+	m := utils.NewASNMapping()
+	utils.SetASNName(m, 1239, "SPRINTLINK")
+	utils.SetASNName(m, 1240, "SPRINT-B")
+	utils.SetASNName(m, 64510, "EXAMPLENET-US")
+	utils.SetASNName(m, 64511, "EXAMPLENET-EU")
+	utils.SetASNName(m, 64500, "OTHER-NET")
+	utils.SetASNOrgID(m, 1239, "ORG-SPRINT")
+	utils.SetASNOrgID(m, 1240, "ORG-SPRINT")
+	c := NewClassifier(nil, nil, m, nil, nil, nil, time.Now)
+
+	if got := c.Siblings(1239); len(got) != 1 || got[0] != 1240 {
+		t.Errorf("Siblings(1239) = %v, want [1240] by organization ID", got)
+	}
+	if got := c.Siblings(64511); len(got) != 1 || got[0] != 64510 {
+		t.Errorf("Siblings(64511) = %v, want [64510] by name", got)
+	}
+	// Networks with an organization ID but no name are not siblings by name
+	utils.SetASNOrgID(m, 64520, "ORG-A")
+	utils.SetASNOrgID(m, 64521, "ORG-B")
+	if got := c.Siblings(64520); len(got) != 0 {
+		t.Errorf("Siblings(64520) = %v, want none", got)
+	}
+	if got := c.Siblings(64500); len(got) != 0 {
+		t.Errorf("Siblings(64500) = %v, want none", got)
+	}
+
+	if r := c.NetworkRoles(1299); !r.Tier1 || !r.LargeNetwork || r.Cloud || !r.DDoSProvider {
+		t.Errorf("NetworkRoles(1299) = %+v, want a Tier-1 large network on the DDoS provider list", r)
+	}
+	if r := c.NetworkRoles(13335); r.Tier1 || !r.Cloud || !r.DDoSProvider {
+		t.Errorf("NetworkRoles(13335) = %+v, want a cloud DDoS provider", r)
+	}
+	if r := c.NetworkRoles(64500); r != (NetworkRoles{}) {
+		t.Errorf("NetworkRoles(64500) = %+v, want none", r)
+	}
+}
@@ -0,0 +1,131 @@
+package bgp
+
+import (
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// The rules below decide how classified events are summarized into incidents.
+// The viewer's critical event stream and the API tracker both follow them.
+const (
+	// IncidentTTL is how long an incident stays listed after its last event
+	IncidentTTL = 10 * time.Minute
+	// IncidentCooldown keeps a prefix from opening a new incident too often
+	IncidentCooldown = 10 * time.Minute
+	// MinOutageIPs is the smallest outage that opens a new incident
+	MinOutageIPs = 1000
+)
+
+// IsIncident reports whether events of the classification are grouped into
+// incidents.
+func IsIncident(t ClassificationType) bool {
+	switch t {
+	case ClassificationOutage, ClassificationRouteLeak, ClassificationHijack:
+		return true
+	default:
+		return false
+	}
+}
+
+// IncidentEvent is a classified event as the summary rules see it.
+type IncidentEvent struct {
+	Class         ClassificationType
+	Prefix        string
+	ASN           uint32
+	HistoricalASN uint32
+	Leak          *LeakDetail
+}
+
+// OriginASN returns the origin of the event, falling back to the historical
+// origin when the event has none.
+func (ev *IncidentEvent) OriginASN() uint32 {
+	if ev.ASN != 0 {
+		return ev.ASN
+	}
+	return ev.HistoricalASN
+}
+
+// DDoSParties returns the provider announcing a DDoS mitigation and the
+// network it protects.
+func (ev *IncidentEvent) DDoSParties() (provider, victim uint32) {
+	provider, victim = ev.ASN, ev.HistoricalASN
+	if ev.Leak != nil {
+		if provider == 0 {
+			provider = ev.Leak.LeakerASN
+		}
+		if ev.Leak.VictimASN != 0 {
+			victim = ev.Leak.VictimASN
+		}
+	}
+	return provider, victim
+}
+
+// IgnoredDDoS reports whether a DDoS mitigation event lacks the provider or
+// victim needed for a meaningful summary. Mitigation by the victim itself is
+// kept.
+func (ev *IncidentEvent) IgnoredDDoS() bool {
+	if ev.Class != ClassificationDDoSMitigation {
+		return false
+	}
+	provider, victim := ev.DDoSParties()
+	return provider == 0 || victim == 0
+}
+
+// Excluded reports whether an incident event is left out of the summaries:
+// outages without an origin, origins in excluded ASN categories and DDoS
+// mitigations without both parties.
+func (ev *IncidentEvent) Excluded() bool {
+	return (ev.Class == ClassificationOutage && ev.ASN == 0) || utils.IsExcludedASN(ev.ASN) || ev.IgnoredDDoS()
+}
+
+// OpensIncident reports whether an event that matches no open incident is
+// large enough to open one. Outages must cover MinOutageIPs addresses.
+func (ev *IncidentEvent) OpensIncident() bool {
+	return ev.Class != ClassificationOutage || utils.GetPrefixSize(ev.Prefix) >= MinOutageIPs
+}
+
+// OpenIncident describes an open incident to match events against.
+type OpenIncident struct {
+	ASN      uint32
+	Leak     *LeakDetail
+	Prefixes map[string]struct{}
+}
+
+// Matches reports whether an event of the incident's classification belongs
+// to it: it is on one of its prefixes, is the same route leak, or else has
+// the same origin.
+func (inc *OpenIncident) Matches(ev *IncidentEvent) bool {
+	if _, ok := inc.Prefixes[ev.Prefix]; ok {
+		return true
+	}
+	if ev.Class == ClassificationRouteLeak && ev.Leak != nil {
+		l := inc.Leak
+		return l != nil && (l.Type == LeakUnknown || l.Type == ev.Leak.Type) &&
+			l.LeakerASN == ev.Leak.LeakerASN && l.VictimASN == ev.Leak.VictimASN
+	}
+	asn := ev.OriginASN()
+	return asn != 0 && asn == inc.ASN
+}
+
+// IncidentCooldowns remembers when each prefix last opened an incident.
+type IncidentCooldowns map[string]time.Time
+
+// Open records that prefix opens an incident at now, unless it opened one
+// within IncidentCooldown.
+func (c IncidentCooldowns) Open(prefix string, now time.Time) bool {
+	if last, ok := c[prefix]; ok && now.Sub(last) < IncidentCooldown {
+		return false
+	}
+	c[prefix] = now
+	return true
+}
+
+// Prune forgets prefixes whose cooldown is over.
+func (c IncidentCooldowns) Prune(now time.Time) {
+	for p, ts := range c {
+		if now.Sub(ts) >= IncidentCooldown {
+			delete(c, p)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package bgp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIncidentEvent_Rules(t *testing.T) {
+	tests := []struct {
+		name     string
+		ev       IncidentEvent
+		excluded bool
+		opens    bool
+	}{
+		{"hijack", IncidentEvent{Class: ClassificationHijack, Prefix: "1.1.1.0/24", ASN: 666}, false, true},
+		{"outage without an origin", IncidentEvent{Class: ClassificationOutage, Prefix: "8.0.0.0/16", HistoricalASN: 3356}, true, true},
+		{"small outage", IncidentEvent{Class: ClassificationOutage, Prefix: "8.8.8.0/24", ASN: 15169}, false, false},
+		{"large outage", IncidentEvent{Class: ClassificationOutage, Prefix: "8.8.0.0/22", ASN: 15169}, false, true},
+		{"DDoS mitigation without a victim", IncidentEvent{Class: ClassificationDDoSMitigation, Prefix: "1.1.1.1/32", ASN: 13335}, true, true},
+		{"DDoS mitigation with a victim", IncidentEvent{Class: ClassificationDDoSMitigation, Prefix: "1.1.1.1/32", Leak: &LeakDetail{LeakerASN: 13335, VictimASN: 64500}}, false, true},
+		{"self mitigation", IncidentEvent{Class: ClassificationDDoSMitigation, Prefix: "1.1.1.1/32", ASN: 13335, HistoricalASN: 13335}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ev.Excluded(); got != tt.excluded {
+				t.Errorf("Excluded() = %v, want %v", got, tt.excluded)
+			}
+			if got := tt.ev.OpensIncident(); got != tt.opens {
+				t.Errorf("OpensIncident() = %v, want %v", got, tt.opens)
+			}
+		})
+	}
+}
+
+func TestOpenIncident_Matches(t *testing.T) {
+	leak := &OpenIncident{
+		ASN:      64500,
+		Leak:     &LeakDetail{Type: LeakHairpin, LeakerASN: 64501, VictimASN: 64500},
+		Prefixes: map[string]struct{}{"10.0.0.0/24": {}},
+	}
+	outage := &OpenIncident{ASN: 15169, Prefixes: map[string]struct{}{"8.8.0.0/22": {}}}
+
+	tests := []struct {
+		name string
+		inc  *OpenIncident
+		ev   IncidentEvent
+		want bool
+	}{
+		{"same prefix", leak, IncidentEvent{Class: ClassificationRouteLeak, Prefix: "10.0.0.0/24"}, true},
+		{"same leak", leak, IncidentEvent{Class: ClassificationRouteLeak, Prefix: "10.0.1.0/24", Leak: &LeakDetail{Type: LeakHairpin, LeakerASN: 64501, VictimASN: 64500}}, true},
+		{"other leaker", leak, IncidentEvent{Class: ClassificationRouteLeak, Prefix: "10.0.1.0/24", ASN: 64500, Leak: &LeakDetail{Type: LeakHairpin, LeakerASN: 64502, VictimASN: 64500}}, false},
+		{"same origin", outage, IncidentEvent{Class: ClassificationOutage, Prefix: "8.8.4.0/22", ASN: 15169}, true},
+		{"same historical origin", outage, IncidentEvent{Class: ClassificationOutage, Prefix: "8.8.4.0/22", HistoricalASN: 15169}, true},
+		{"other origin", outage, IncidentEvent{Class: ClassificationOutage, Prefix: "9.9.9.0/24", ASN: 19281}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.inc.Matches(&tt.ev); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncidentCooldowns(t *testing.T) {
+	c := make(IncidentCooldowns)
+	now := time.Now()
+	if !c.Open("1.1.1.0/24", now) {
+		t.Fatal("expected the first incident to open")
+	}
+	if c.Open("1.1.1.0/24", now.Add(IncidentCooldown-time.Second)) {
+		t.Error("expected the prefix to be on cooldown")
+	}
+	c.Prune(now.Add(IncidentCooldown))
+	if len(c) != 0 {
+		t.Errorf("expected the cooldown to be pruned, got %v", c)
+	}
+	if !c.Open("1.1.1.0/24", now.Add(IncidentCooldown)) {
+		t.Error("expected an incident to open after the cooldown")
+	}
+}
@@ -0,0 +1,1303 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: v1/stream.proto
+
+package bgpproto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Classification values match bgp.ClassificationType.
+type Classification int32
+
+const (
+	// No classification (normal activity)
+	Classification_CLASSIFICATION_UNSPECIFIED         Classification = 0
+	Classification_CLASSIFICATION_FLAP                Classification = 1
+	Classification_CLASSIFICATION_PATH_HUNTING        Classification = 2
+	Classification_CLASSIFICATION_TRAFFIC_ENGINEERING Classification = 3
+	Classification_CLASSIFICATION_OUTAGE              Classification = 4
+	Classification_CLASSIFICATION_ROUTE_LEAK          Classification = 5
+	Classification_CLASSIFICATION_DISCOVERY           Classification = 6
+	Classification_CLASSIFICATION_DDOS_MITIGATION     Classification = 7
+	Classification_CLASSIFICATION_HIJACK              Classification = 8
+	Classification_CLASSIFICATION_BOGON               Classification = 9
+)
+
+// Enum value maps for Classification.
+var (
+	Classification_name = map[int32]string{
+		0: "CLASSIFICATION_UNSPECIFIED",
+		1: "CLASSIFICATION_FLAP",
+		2: "CLASSIFICATION_PATH_HUNTING",
+		3: "CLASSIFICATION_TRAFFIC_ENGINEERING",
+		4: "CLASSIFICATION_OUTAGE",
+		5: "CLASSIFICATION_ROUTE_LEAK",
+		6: "CLASSIFICATION_DISCOVERY",
+		7: "CLASSIFICATION_DDOS_MITIGATION",
+		8: "CLASSIFICATION_HIJACK",
+		9: "CLASSIFICATION_BOGON",
+	}
+	Classification_value = map[string]int32{
+		"CLASSIFICATION_UNSPECIFIED":         0,
+		"CLASSIFICATION_FLAP":                1,
+		"CLASSIFICATION_PATH_HUNTING":        2,
+		"CLASSIFICATION_TRAFFIC_ENGINEERING": 3,
+		"CLASSIFICATION_OUTAGE":              4,
+		"CLASSIFICATION_ROUTE_LEAK":          5,
+		"CLASSIFICATION_DISCOVERY":           6,
+		"CLASSIFICATION_DDOS_MITIGATION":     7,
+		"CLASSIFICATION_HIJACK":              8,
+		"CLASSIFICATION_BOGON":               9,
+	}
+)
+
+func (x Classification) Enum() *Classification {
+	p := new(Classification)
+	*p = x
+	return p
+}
+
+func (x Classification) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Classification) Descriptor() protoreflect.EnumDescriptor {
+	return file_v1_stream_proto_enumTypes[0].Descriptor()
+}
+
+func (Classification) Type() protoreflect.EnumType {
+	return &file_v1_stream_proto_enumTypes[0]
+}
+
+func (x Classification) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Classification.Descriptor instead.
+func (Classification) EnumDescriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{0}
+}
+
+// EventType values match bgp.EventType.
+type EventType int32
+
+const (
+	EventType_EVENT_TYPE_UNSPECIFIED EventType = 0
+	EventType_EVENT_TYPE_NEW         EventType = 1
+	EventType_EVENT_TYPE_UPDATE      EventType = 2
+	EventType_EVENT_TYPE_WITHDRAWAL  EventType = 3
+	EventType_EVENT_TYPE_GOSSIP      EventType = 4
+)
+
+// Enum value maps for EventType.
+var (
+	EventType_name = map[int32]string{
+		0: "EVENT_TYPE_UNSPECIFIED",
+		1: "EVENT_TYPE_NEW",
+		2: "EVENT_TYPE_UPDATE",
+		3: "EVENT_TYPE_WITHDRAWAL",
+		4: "EVENT_TYPE_GOSSIP",
+	}
+	EventType_value = map[string]int32{
+		"EVENT_TYPE_UNSPECIFIED": 0,
+		"EVENT_TYPE_NEW":         1,
+		"EVENT_TYPE_UPDATE":      2,
+		"EVENT_TYPE_WITHDRAWAL":  3,
+		"EVENT_TYPE_GOSSIP":      4,
+	}
+)
+
+func (x EventType) Enum() *EventType {
+	p := new(EventType)
+	*p = x
+	return p
+}
+
+func (x EventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_v1_stream_proto_enumTypes[1].Descriptor()
+}
+
+func (EventType) Type() protoreflect.EnumType {
+	return &file_v1_stream_proto_enumTypes[1]
+}
+
+func (x EventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventType.Descriptor instead.
+func (EventType) EnumDescriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{1}
+}
+
+// LeakType values match bgp.LeakType.
+type LeakType int32
+
+const (
+	LeakType_LEAK_TYPE_UNSPECIFIED              LeakType = 0
+	LeakType_LEAK_TYPE_HAIRPIN                  LeakType = 1
+	LeakType_LEAK_TYPE_LATERAL                  LeakType = 2
+	LeakType_LEAK_TYPE_PROVIDER_TO_PEER         LeakType = 3
+	LeakType_LEAK_TYPE_PEER_TO_PROVIDER         LeakType = 4
+	LeakType_LEAK_TYPE_RE_ORIGINATION           LeakType = 5
+	LeakType_LEAK_TYPE_DDOS_RTBH                LeakType = 6
+	LeakType_LEAK_TYPE_DDOS_FLOWSPEC            LeakType = 7
+	LeakType_LEAK_TYPE_DDOS_TRAFFIC_REDIRECTION LeakType = 8
+)
+
+// Enum value maps for LeakType.
+var (
+	LeakType_name = map[int32]string{
+		0: "LEAK_TYPE_UNSPECIFIED",
+		1: "LEAK_TYPE_HAIRPIN",
+		2: "LEAK_TYPE_LATERAL",
+		3: "LEAK_TYPE_PROVIDER_TO_PEER",
+		4: "LEAK_TYPE_PEER_TO_PROVIDER",
+		5: "LEAK_TYPE_RE_ORIGINATION",
+		6: "LEAK_TYPE_DDOS_RTBH",
+		7: "LEAK_TYPE_DDOS_FLOWSPEC",
+		8: "LEAK_TYPE_DDOS_TRAFFIC_REDIRECTION",
+	}
+	LeakType_value = map[string]int32{
+		"LEAK_TYPE_UNSPECIFIED":              0,
+		"LEAK_TYPE_HAIRPIN":                  1,
+		"LEAK_TYPE_LATERAL":                  2,
+		"LEAK_TYPE_PROVIDER_TO_PEER":         3,
+		"LEAK_TYPE_PEER_TO_PROVIDER":         4,
+		"LEAK_TYPE_RE_ORIGINATION":           5,
+		"LEAK_TYPE_DDOS_RTBH":                6,
+		"LEAK_TYPE_DDOS_FLOWSPEC":            7,
+		"LEAK_TYPE_DDOS_TRAFFIC_REDIRECTION": 8,
+	}
+)
+
+func (x LeakType) Enum() *LeakType {
+	p := new(LeakType)
+	*p = x
+	return p
+}
+
+func (x LeakType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LeakType) Descriptor() protoreflect.EnumDescriptor {
+	return file_v1_stream_proto_enumTypes[2].Descriptor()
+}
+
+func (LeakType) Type() protoreflect.EnumType {
+	return &file_v1_stream_proto_enumTypes[2]
+}
+
+func (x LeakType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LeakType.Descriptor instead.
+func (LeakType) EnumDescriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{2}
+}
+
+// EventKind selects which messages a subscription receives.
+type EventKind int32
+
+const (
+	EventKind_EVENT_KIND_UNSPECIFIED           EventKind = 0
+	EventKind_EVENT_KIND_BGP_EVENT             EventKind = 1
+	EventKind_EVENT_KIND_INCIDENT              EventKind = 2
+	EventKind_EVENT_KIND_CLASSIFICATION_CHANGE EventKind = 3
+)
+
+// Enum value maps for EventKind.
+var (
+	EventKind_name = map[int32]string{
+		0: "EVENT_KIND_UNSPECIFIED",
+		1: "EVENT_KIND_BGP_EVENT",
+		2: "EVENT_KIND_INCIDENT",
+		3: "EVENT_KIND_CLASSIFICATION_CHANGE",
+	}
+	EventKind_value = map[string]int32{
+		"EVENT_KIND_UNSPECIFIED":           0,
+		"EVENT_KIND_BGP_EVENT":             1,
+		"EVENT_KIND_INCIDENT":              2,
+		"EVENT_KIND_CLASSIFICATION_CHANGE": 3,
+	}
+)
+
+func (x EventKind) Enum() *EventKind {
+	p := new(EventKind)
+	*p = x
+	return p
+}
+
+func (x EventKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_v1_stream_proto_enumTypes[3].Descriptor()
+}
+
+func (EventKind) Type() protoreflect.EnumType {
+	return &file_v1_stream_proto_enumTypes[3]
+}
+
+func (x EventKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventKind.Descriptor instead.
+func (EventKind) EnumDescriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{3}
+}
+
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Kinds of messages to receive. Empty selects every kind.
+	Kinds []EventKind `protobuf:"varint,1,rep,packed,name=kinds,proto3,enum=bgp.v1.EventKind" json:"kinds,omitempty"`
+	// Classifications to receive. Empty selects every classification except
+	// CLASSIFICATION_UNSPECIFIED, which must be listed to receive unclassified
+	// events.
+	Types []Classification `protobuf:"varint,2,rep,packed,name=types,proto3,enum=bgp.v1.Classification" json:"types,omitempty"`
+	// ASNs that must appear as origin, previous origin, leaker or victim
+	Asns []uint32 `protobuf:"varint,3,rep,packed,name=asns,proto3" json:"asns,omitempty"`
+	// Prefixes in CIDR notation. More-specifics of a listed prefix match too.
+	Prefixes []string `protobuf:"bytes,4,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	// ISO 3166-1 alpha-2 country codes
+	Countries     []string `protobuf:"bytes,5,rep,name=countries,proto3" json:"countries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_v1_stream_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SubscribeRequest) GetKinds() []EventKind {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+func (x *SubscribeRequest) GetTypes() []Classification {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *SubscribeRequest) GetAsns() []uint32 {
+	if x != nil {
+		return x.Asns
+	}
+	return nil
+}
+
+func (x *SubscribeRequest) GetPrefixes() []string {
+	if x != nil {
+		return x.Prefixes
+	}
+	return nil
+}
+
+func (x *SubscribeRequest) GetCountries() []string {
+	if x != nil {
+		return x.Countries
+	}
+	return nil
+}
+
+type SubscribeResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*SubscribeResponse_Event
+	//	*SubscribeResponse_Incident
+	//	*SubscribeResponse_Change
+	Payload       isSubscribeResponse_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeResponse) Reset() {
+	*x = SubscribeResponse{}
+	mi := &file_v1_stream_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeResponse) ProtoMessage() {}
+
+func (x *SubscribeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeResponse.ProtoReflect.Descriptor instead.
+func (*SubscribeResponse) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SubscribeResponse) GetPayload() isSubscribeResponse_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *SubscribeResponse) GetEvent() *BGPEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*SubscribeResponse_Event); ok {
+			return x.Event
+		}
+	}
+	return nil
+}
+
+func (x *SubscribeResponse) GetIncident() *Incident {
+	if x != nil {
+		if x, ok := x.Payload.(*SubscribeResponse_Incident); ok {
+			return x.Incident
+		}
+	}
+	return nil
+}
+
+func (x *SubscribeResponse) GetChange() *ClassificationChange {
+	if x != nil {
+		if x, ok := x.Payload.(*SubscribeResponse_Change); ok {
+			return x.Change
+		}
+	}
+	return nil
+}
+
+type isSubscribeResponse_Payload interface {
+	isSubscribeResponse_Payload()
+}
+
+type SubscribeResponse_Event struct {
+	Event *BGPEvent `protobuf:"bytes,1,opt,name=event,proto3,oneof"`
+}
+
+type SubscribeResponse_Incident struct {
+	Incident *Incident `protobuf:"bytes,2,opt,name=incident,proto3,oneof"`
+}
+
+type SubscribeResponse_Change struct {
+	Change *ClassificationChange `protobuf:"bytes,3,opt,name=change,proto3,oneof"`
+}
+
+func (*SubscribeResponse_Event) isSubscribeResponse_Payload() {}
+
+func (*SubscribeResponse_Incident) isSubscribeResponse_Payload() {}
+
+func (*SubscribeResponse_Change) isSubscribeResponse_Payload() {}
+
+type Leak struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Kind of leak or DDoS mitigation
+	Type LeakType `protobuf:"varint,1,opt,name=type,proto3,enum=bgp.v1.LeakType" json:"type,omitempty"`
+	// ASN identified as the source of the leak, or the mitigation provider
+	LeakerAsn uint32 `protobuf:"varint,2,opt,name=leaker_asn,json=leakerAsn,proto3" json:"leaker_asn,omitempty"`
+	// ASN identified as the victim of the leak, hijack or mitigation
+	VictimAsn uint32 `protobuf:"varint,3,opt,name=victim_asn,json=victimAsn,proto3" json:"victim_asn,omitempty"`
+	// Fraction of full-feed peers still carrying an outage prefix (0.0 - 1.0)
+	Visibility float64 `protobuf:"fixed64,4,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	// Visibility before the outage
+	PeakVisibility float64 `protobuf:"fixed64,5,opt,name=peak_visibility,json=peakVisibility,proto3" json:"peak_visibility,omitempty"`
+	// Registry entry or allocation check that matched for bogons
+	Reason        string `protobuf:"bytes,6,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Leak) Reset() {
+	*x = Leak{}
+	mi := &file_v1_stream_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Leak) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Leak) ProtoMessage() {}
+
+func (x *Leak) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Leak.ProtoReflect.Descriptor instead.
+func (*Leak) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Leak) GetType() LeakType {
+	if x != nil {
+		return x.Type
+	}
+	return LeakType_LEAK_TYPE_UNSPECIFIED
+}
+
+func (x *Leak) GetLeakerAsn() uint32 {
+	if x != nil {
+		return x.LeakerAsn
+	}
+	return 0
+}
+
+func (x *Leak) GetVictimAsn() uint32 {
+	if x != nil {
+		return x.VictimAsn
+	}
+	return 0
+}
+
+func (x *Leak) GetVisibility() float64 {
+	if x != nil {
+		return x.Visibility
+	}
+	return 0
+}
+
+func (x *Leak) GetPeakVisibility() float64 {
+	if x != nil {
+		return x.PeakVisibility
+	}
+	return 0
+}
+
+func (x *Leak) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// BGPEvent is a single processed update, as plotted by the viewer.
+type BGPEvent struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Time           *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Prefix         string                 `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Type           EventType              `protobuf:"varint,3,opt,name=type,proto3,enum=bgp.v1.EventType" json:"type,omitempty"`
+	Classification Classification         `protobuf:"varint,4,opt,name=classification,proto3,enum=bgp.v1.Classification" json:"classification,omitempty"`
+	// Originating ASN of the update
+	OriginAsn uint32 `protobuf:"varint,5,opt,name=origin_asn,json=originAsn,proto3" json:"origin_asn,omitempty"`
+	// Previously known originating ASN, set for withdrawals and origin changes
+	HistoricalOriginAsn uint32  `protobuf:"varint,6,opt,name=historical_origin_asn,json=historicalOriginAsn,proto3" json:"historical_origin_asn,omitempty"`
+	Country             string  `protobuf:"bytes,7,opt,name=country,proto3" json:"country,omitempty"`
+	City                string  `protobuf:"bytes,8,opt,name=city,proto3" json:"city,omitempty"`
+	Latitude            float64 `protobuf:"fixed64,9,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude           float64 `protobuf:"fixed64,10,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Leak                *Leak   `protobuf:"bytes,11,opt,name=leak,proto3" json:"leak,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *BGPEvent) Reset() {
+	*x = BGPEvent{}
+	mi := &file_v1_stream_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BGPEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BGPEvent) ProtoMessage() {}
+
+func (x *BGPEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BGPEvent.ProtoReflect.Descriptor instead.
+func (*BGPEvent) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BGPEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *BGPEvent) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *BGPEvent) GetType() EventType {
+	if x != nil {
+		return x.Type
+	}
+	return EventType_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *BGPEvent) GetClassification() Classification {
+	if x != nil {
+		return x.Classification
+	}
+	return Classification_CLASSIFICATION_UNSPECIFIED
+}
+
+func (x *BGPEvent) GetOriginAsn() uint32 {
+	if x != nil {
+		return x.OriginAsn
+	}
+	return 0
+}
+
+func (x *BGPEvent) GetHistoricalOriginAsn() uint32 {
+	if x != nil {
+		return x.HistoricalOriginAsn
+	}
+	return 0
+}
+
+func (x *BGPEvent) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *BGPEvent) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *BGPEvent) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *BGPEvent) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+func (x *BGPEvent) GetLeak() *Leak {
+	if x != nil {
+		return x.Leak
+	}
+	return nil
+}
+
+// Incident is an outage, route leak or hijack, possibly spanning several
+// prefixes. It is sent when opened and again whenever it grows.
+type Incident struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifies the incident across updates
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Time of the most recent event belonging to the incident
+	Time           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Classification Classification         `protobuf:"varint,3,opt,name=classification,proto3,enum=bgp.v1.Classification" json:"classification,omitempty"`
+	Asn            uint32                 `protobuf:"varint,4,opt,name=asn,proto3" json:"asn,omitempty"`
+	// Name of the ASN, if known
+	Network string `protobuf:"bytes,5,opt,name=network,proto3" json:"network,omitempty"`
+	OrgId   string `protobuf:"bytes,6,opt,name=org_id,json=orgId,proto3" json:"org_id,omitempty"`
+	Leak    *Leak  `protobuf:"bytes,7,opt,name=leak,proto3" json:"leak,omitempty"`
+	// Locations formatted as "City, CC" and joined by " | "
+	Locations      string   `protobuf:"bytes,8,opt,name=locations,proto3" json:"locations,omitempty"`
+	Visibility     float64  `protobuf:"fixed64,9,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	PeakVisibility float64  `protobuf:"fixed64,10,opt,name=peak_visibility,json=peakVisibility,proto3" json:"peak_visibility,omitempty"`
+	ImpactedIps    uint64   `protobuf:"varint,11,opt,name=impacted_ips,json=impactedIps,proto3" json:"impacted_ips,omitempty"`
+	Prefixes       []string `protobuf:"bytes,12,rep,name=prefixes,proto3" json:"prefixes,omitempty"`
+	// Set on the first message for this incident
+	Opened        bool `protobuf:"varint,13,opt,name=opened,proto3" json:"opened,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Incident) Reset() {
+	*x = Incident{}
+	mi := &file_v1_stream_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Incident) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Incident) ProtoMessage() {}
+
+func (x *Incident) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Incident.ProtoReflect.Descriptor instead.
+func (*Incident) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Incident) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Incident) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Incident) GetClassification() Classification {
+	if x != nil {
+		return x.Classification
+	}
+	return Classification_CLASSIFICATION_UNSPECIFIED
+}
+
+func (x *Incident) GetAsn() uint32 {
+	if x != nil {
+		return x.Asn
+	}
+	return 0
+}
+
+func (x *Incident) GetNetwork() string {
+	if x != nil {
+		return x.Network
+	}
+	return ""
+}
+
+func (x *Incident) GetOrgId() string {
+	if x != nil {
+		return x.OrgId
+	}
+	return ""
+}
+
+func (x *Incident) GetLeak() *Leak {
+	if x != nil {
+		return x.Leak
+	}
+	return nil
+}
+
+func (x *Incident) GetLocations() string {
+	if x != nil {
+		return x.Locations
+	}
+	return ""
+}
+
+func (x *Incident) GetVisibility() float64 {
+	if x != nil {
+		return x.Visibility
+	}
+	return 0
+}
+
+func (x *Incident) GetPeakVisibility() float64 {
+	if x != nil {
+		return x.PeakVisibility
+	}
+	return 0
+}
+
+func (x *Incident) GetImpactedIps() uint64 {
+	if x != nil {
+		return x.ImpactedIps
+	}
+	return 0
+}
+
+func (x *Incident) GetPrefixes() []string {
+	if x != nil {
+		return x.Prefixes
+	}
+	return nil
+}
+
+func (x *Incident) GetOpened() bool {
+	if x != nil {
+		return x.Opened
+	}
+	return false
+}
+
+// Evidence is what the classifier observed when a prefix changed
+// classification. Counts are totals over the analysis window.
+type Evidence struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Peer           string                 `protobuf:"bytes,1,opt,name=peer,proto3" json:"peer,omitempty"`
+	Host           string                 `protobuf:"bytes,2,opt,name=host,proto3" json:"host,omitempty"`
+	Path           string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Communities    string                 `protobuf:"bytes,4,opt,name=communities,proto3" json:"communities,omitempty"`
+	Messages       int32                  `protobuf:"varint,5,opt,name=messages,proto3" json:"messages,omitempty"`
+	Announcements  int32                  `protobuf:"varint,6,opt,name=announcements,proto3" json:"announcements,omitempty"`
+	Withdrawals    int32                  `protobuf:"varint,7,opt,name=withdrawals,proto3" json:"withdrawals,omitempty"`
+	PathChanges    int32                  `protobuf:"varint,8,opt,name=path_changes,json=pathChanges,proto3" json:"path_changes,omitempty"`
+	Peers          int32                  `protobuf:"varint,9,opt,name=peers,proto3" json:"peers,omitempty"`
+	Hosts          int32                  `protobuf:"varint,10,opt,name=hosts,proto3" json:"hosts,omitempty"`
+	WithdrawnPeers int32                  `protobuf:"varint,11,opt,name=withdrawn_peers,json=withdrawnPeers,proto3" json:"withdrawn_peers,omitempty"`
+	WithdrawnHosts int32                  `protobuf:"varint,12,opt,name=withdrawn_hosts,json=withdrawnHosts,proto3" json:"withdrawn_hosts,omitempty"`
+	Origins        int32                  `protobuf:"varint,13,opt,name=origins,proto3" json:"origins,omitempty"`
+	AnomalyScore   float64                `protobuf:"fixed64,14,opt,name=anomaly_score,json=anomalyScore,proto3" json:"anomaly_score,omitempty"`
+	// RPKI validation status (Valid, InvalidASN, InvalidMaxLength), if known
+	Rpki          string `protobuf:"bytes,15,opt,name=rpki,proto3" json:"rpki,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Evidence) Reset() {
+	*x = Evidence{}
+	mi := &file_v1_stream_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Evidence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Evidence) ProtoMessage() {}
+
+func (x *Evidence) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Evidence.ProtoReflect.Descriptor instead.
+func (*Evidence) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Evidence) GetPeer() string {
+	if x != nil {
+		return x.Peer
+	}
+	return ""
+}
+
+func (x *Evidence) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Evidence) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *Evidence) GetCommunities() string {
+	if x != nil {
+		return x.Communities
+	}
+	return ""
+}
+
+func (x *Evidence) GetMessages() int32 {
+	if x != nil {
+		return x.Messages
+	}
+	return 0
+}
+
+func (x *Evidence) GetAnnouncements() int32 {
+	if x != nil {
+		return x.Announcements
+	}
+	return 0
+}
+
+func (x *Evidence) GetWithdrawals() int32 {
+	if x != nil {
+		return x.Withdrawals
+	}
+	return 0
+}
+
+func (x *Evidence) GetPathChanges() int32 {
+	if x != nil {
+		return x.PathChanges
+	}
+	return 0
+}
+
+func (x *Evidence) GetPeers() int32 {
+	if x != nil {
+		return x.Peers
+	}
+	return 0
+}
+
+func (x *Evidence) GetHosts() int32 {
+	if x != nil {
+		return x.Hosts
+	}
+	return 0
+}
+
+func (x *Evidence) GetWithdrawnPeers() int32 {
+	if x != nil {
+		return x.WithdrawnPeers
+	}
+	return 0
+}
+
+func (x *Evidence) GetWithdrawnHosts() int32 {
+	if x != nil {
+		return x.WithdrawnHosts
+	}
+	return 0
+}
+
+func (x *Evidence) GetOrigins() int32 {
+	if x != nil {
+		return x.Origins
+	}
+	return 0
+}
+
+func (x *Evidence) GetAnomalyScore() float64 {
+	if x != nil {
+		return x.AnomalyScore
+	}
+	return 0
+}
+
+func (x *Evidence) GetRpki() string {
+	if x != nil {
+		return x.Rpki
+	}
+	return ""
+}
+
+// ClassificationChange is a prefix entering, escalating or leaving a
+// classification.
+type ClassificationChange struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Time                *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Prefix              string                 `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	From                Classification         `protobuf:"varint,3,opt,name=from,proto3,enum=bgp.v1.Classification" json:"from,omitempty"`
+	To                  Classification         `protobuf:"varint,4,opt,name=to,proto3,enum=bgp.v1.Classification" json:"to,omitempty"`
+	OriginAsn           uint32                 `protobuf:"varint,5,opt,name=origin_asn,json=originAsn,proto3" json:"origin_asn,omitempty"`
+	HistoricalOriginAsn uint32                 `protobuf:"varint,6,opt,name=historical_origin_asn,json=historicalOriginAsn,proto3" json:"historical_origin_asn,omitempty"`
+	Country             string                 `protobuf:"bytes,7,opt,name=country,proto3" json:"country,omitempty"`
+	City                string                 `protobuf:"bytes,8,opt,name=city,proto3" json:"city,omitempty"`
+	Leak                *Leak                  `protobuf:"bytes,9,opt,name=leak,proto3" json:"leak,omitempty"`
+	Evidence            *Evidence              `protobuf:"bytes,10,opt,name=evidence,proto3" json:"evidence,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *ClassificationChange) Reset() {
+	*x = ClassificationChange{}
+	mi := &file_v1_stream_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassificationChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassificationChange) ProtoMessage() {}
+
+func (x *ClassificationChange) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_stream_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassificationChange.ProtoReflect.Descriptor instead.
+func (*ClassificationChange) Descriptor() ([]byte, []int) {
+	return file_v1_stream_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ClassificationChange) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ClassificationChange) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *ClassificationChange) GetFrom() Classification {
+	if x != nil {
+		return x.From
+	}
+	return Classification_CLASSIFICATION_UNSPECIFIED
+}
+
+func (x *ClassificationChange) GetTo() Classification {
+	if x != nil {
+		return x.To
+	}
+	return Classification_CLASSIFICATION_UNSPECIFIED
+}
+
+func (x *ClassificationChange) GetOriginAsn() uint32 {
+	if x != nil {
+		return x.OriginAsn
+	}
+	return 0
+}
+
+func (x *ClassificationChange) GetHistoricalOriginAsn() uint32 {
+	if x != nil {
+		return x.HistoricalOriginAsn
+	}
+	return 0
+}
+
+func (x *ClassificationChange) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *ClassificationChange) GetCity() string {
+	if x != nil {
+		return x.City
+	}
+	return ""
+}
+
+func (x *ClassificationChange) GetLeak() *Leak {
+	if x != nil {
+		return x.Leak
+	}
+	return nil
+}
+
+func (x *ClassificationChange) GetEvidence() *Evidence {
+	if x != nil {
+		return x.Evidence
+	}
+	return nil
+}
+
+var File_v1_stream_proto protoreflect.FileDescriptor
+
+const file_v1_stream_proto_rawDesc = "" +
+	"\n" +
+	"\x0fv1/stream.proto\x12\x06bgp.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb7\x01\n" +
+	"\x10SubscribeRequest\x12'\n" +
+	"\x05kinds\x18\x01 \x03(\x0e2\x11.bgp.v1.EventKindR\x05kinds\x12,\n" +
+	"\x05types\x18\x02 \x03(\x0e2\x16.bgp.v1.ClassificationR\x05types\x12\x12\n" +
+	"\x04asns\x18\x03 \x03(\rR\x04asns\x12\x1a\n" +
+	"\bprefixes\x18\x04 \x03(\tR\bprefixes\x12\x1c\n" +
+	"\tcountries\x18\x05 \x03(\tR\tcountries\"\xb0\x01\n" +
+	"\x11SubscribeResponse\x12(\n" +
+	"\x05event\x18\x01 \x01(\v2\x10.bgp.v1.BGPEventH\x00R\x05event\x12.\n" +
+	"\bincident\x18\x02 \x01(\v2\x10.bgp.v1.IncidentH\x00R\bincident\x126\n" +
+	"\x06change\x18\x03 \x01(\v2\x1c.bgp.v1.ClassificationChangeH\x00R\x06changeB\t\n" +
+	"\apayload\"\xcb\x01\n" +
+	"\x04Leak\x12$\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x10.bgp.v1.LeakTypeR\x04type\x12\x1d\n" +
+	"\n" +
+	"leaker_asn\x18\x02 \x01(\rR\tleakerAsn\x12\x1d\n" +
+	"\n" +
+	"victim_asn\x18\x03 \x01(\rR\tvictimAsn\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x04 \x01(\x01R\n" +
+	"visibility\x12'\n" +
+	"\x0fpeak_visibility\x18\x05 \x01(\x01R\x0epeakVisibility\x12\x16\n" +
+	"\x06reason\x18\x06 \x01(\tR\x06reason\"\x96\x03\n" +
+	"\bBGPEvent\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06prefix\x18\x02 \x01(\tR\x06prefix\x12%\n" +
+	"\x04type\x18\x03 \x01(\x0e2\x11.bgp.v1.EventTypeR\x04type\x12>\n" +
+	"\x0eclassification\x18\x04 \x01(\x0e2\x16.bgp.v1.ClassificationR\x0eclassification\x12\x1d\n" +
+	"\n" +
+	"origin_asn\x18\x05 \x01(\rR\toriginAsn\x122\n" +
+	"\x15historical_origin_asn\x18\x06 \x01(\rR\x13historicalOriginAsn\x12\x18\n" +
+	"\acountry\x18\a \x01(\tR\acountry\x12\x12\n" +
+	"\x04city\x18\b \x01(\tR\x04city\x12\x1a\n" +
+	"\blatitude\x18\t \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\n" +
+	" \x01(\x01R\tlongitude\x12 \n" +
+	"\x04leak\x18\v \x01(\v2\f.bgp.v1.LeakR\x04leak\"\xad\x03\n" +
+	"\bIncident\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12>\n" +
+	"\x0eclassification\x18\x03 \x01(\x0e2\x16.bgp.v1.ClassificationR\x0eclassification\x12\x10\n" +
+	"\x03asn\x18\x04 \x01(\rR\x03asn\x12\x18\n" +
+	"\anetwork\x18\x05 \x01(\tR\anetwork\x12\x15\n" +
+	"\x06org_id\x18\x06 \x01(\tR\x05orgId\x12 \n" +
+	"\x04leak\x18\a \x01(\v2\f.bgp.v1.LeakR\x04leak\x12\x1c\n" +
+	"\tlocations\x18\b \x01(\tR\tlocations\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\t \x01(\x01R\n" +
+	"visibility\x12'\n" +
+	"\x0fpeak_visibility\x18\n" +
+	" \x01(\x01R\x0epeakVisibility\x12!\n" +
+	"\fimpacted_ips\x18\v \x01(\x04R\vimpactedIps\x12\x1a\n" +
+	"\bprefixes\x18\f \x03(\tR\bprefixes\x12\x16\n" +
+	"\x06opened\x18\r \x01(\bR\x06opened\"\xc0\x03\n" +
+	"\bEvidence\x12\x12\n" +
+	"\x04peer\x18\x01 \x01(\tR\x04peer\x12\x12\n" +
+	"\x04host\x18\x02 \x01(\tR\x04host\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\x12 \n" +
+	"\vcommunities\x18\x04 \x01(\tR\vcommunities\x12\x1a\n" +
+	"\bmessages\x18\x05 \x01(\x05R\bmessages\x12$\n" +
+	"\rannouncements\x18\x06 \x01(\x05R\rannouncements\x12 \n" +
+	"\vwithdrawals\x18\a \x01(\x05R\vwithdrawals\x12!\n" +
+	"\fpath_changes\x18\b \x01(\x05R\vpathChanges\x12\x14\n" +
+	"\x05peers\x18\t \x01(\x05R\x05peers\x12\x14\n" +
+	"\x05hosts\x18\n" +
+	" \x01(\x05R\x05hosts\x12'\n" +
+	"\x0fwithdrawn_peers\x18\v \x01(\x05R\x0ewithdrawnPeers\x12'\n" +
+	"\x0fwithdrawn_hosts\x18\f \x01(\x05R\x0ewithdrawnHosts\x12\x18\n" +
+	"\aorigins\x18\r \x01(\x05R\aorigins\x12#\n" +
+	"\ranomaly_score\x18\x0e \x01(\x01R\fanomalyScore\x12\x12\n" +
+	"\x04rpki\x18\x0f \x01(\tR\x04rpki\"\x83\x03\n" +
+	"\x14ClassificationChange\x12.\n" +
+	"\x04time\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x16\n" +
+	"\x06prefix\x18\x02 \x01(\tR\x06prefix\x12*\n" +
+	"\x04from\x18\x03 \x01(\x0e2\x16.bgp.v1.ClassificationR\x04from\x12&\n" +
+	"\x02to\x18\x04 \x01(\x0e2\x16.bgp.v1.ClassificationR\x02to\x12\x1d\n" +
+	"\n" +
+	"origin_asn\x18\x05 \x01(\rR\toriginAsn\x122\n" +
+	"\x15historical_origin_asn\x18\x06 \x01(\rR\x13historicalOriginAsn\x12\x18\n" +
+	"\acountry\x18\a \x01(\tR\acountry\x12\x12\n" +
+	"\x04city\x18\b \x01(\tR\x04city\x12 \n" +
+	"\x04leak\x18\t \x01(\v2\f.bgp.v1.LeakR\x04leak\x12,\n" +
+	"\bevidence\x18\n" +
+	" \x01(\v2\x10.bgp.v1.EvidenceR\bevidence*\xc3\x02\n" +
+	"\x0eClassification\x12\x1e\n" +
+	"\x1aCLASSIFICATION_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13CLASSIFICATION_FLAP\x10\x01\x12\x1f\n" +
+	"\x1bCLASSIFICATION_PATH_HUNTING\x10\x02\x12&\n" +
+	"\"CLASSIFICATION_TRAFFIC_ENGINEERING\x10\x03\x12\x19\n" +
+	"\x15CLASSIFICATION_OUTAGE\x10\x04\x12\x1d\n" +
+	"\x19CLASSIFICATION_ROUTE_LEAK\x10\x05\x12\x1c\n" +
+	"\x18CLASSIFICATION_DISCOVERY\x10\x06\x12\"\n" +
+	"\x1eCLASSIFICATION_DDOS_MITIGATION\x10\a\x12\x19\n" +
+	"\x15CLASSIFICATION_HIJACK\x10\b\x12\x18\n" +
+	"\x14CLASSIFICATION_BOGON\x10\t*\x84\x01\n" +
+	"\tEventType\x12\x1a\n" +
+	"\x16EVENT_TYPE_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eEVENT_TYPE_NEW\x10\x01\x12\x15\n" +
+	"\x11EVENT_TYPE_UPDATE\x10\x02\x12\x19\n" +
+	"\x15EVENT_TYPE_WITHDRAWAL\x10\x03\x12\x15\n" +
+	"\x11EVENT_TYPE_GOSSIP\x10\x04*\x8f\x02\n" +
+	"\bLeakType\x12\x19\n" +
+	"\x15LEAK_TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11LEAK_TYPE_HAIRPIN\x10\x01\x12\x15\n" +
+	"\x11LEAK_TYPE_LATERAL\x10\x02\x12\x1e\n" +
+	"\x1aLEAK_TYPE_PROVIDER_TO_PEER\x10\x03\x12\x1e\n" +
+	"\x1aLEAK_TYPE_PEER_TO_PROVIDER\x10\x04\x12\x1c\n" +
+	"\x18LEAK_TYPE_RE_ORIGINATION\x10\x05\x12\x17\n" +
+	"\x13LEAK_TYPE_DDOS_RTBH\x10\x06\x12\x1b\n" +
+	"\x17LEAK_TYPE_DDOS_FLOWSPEC\x10\a\x12&\n" +
+	"\"LEAK_TYPE_DDOS_TRAFFIC_REDIRECTION\x10\b*\x80\x01\n" +
+	"\tEventKind\x12\x1a\n" +
+	"\x16EVENT_KIND_UNSPECIFIED\x10\x00\x12\x18\n" +
+	"\x14EVENT_KIND_BGP_EVENT\x10\x01\x12\x17\n" +
+	"\x13EVENT_KIND_INCIDENT\x10\x02\x12$\n" +
+	" EVENT_KIND_CLASSIFICATION_CHANGE\x10\x032R\n" +
+	"\fEventService\x12B\n" +
+	"\tSubscribe\x12\x18.bgp.v1.SubscribeRequest\x1a\x19.bgp.v1.SubscribeResponse0\x01B<Z:github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1;bgpprotob\x06proto3"
+
+var (
+	file_v1_stream_proto_rawDescOnce sync.Once
+	file_v1_stream_proto_rawDescData []byte
+)
+
+func file_v1_stream_proto_rawDescGZIP() []byte {
+	file_v1_stream_proto_rawDescOnce.Do(func() {
+		file_v1_stream_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_v1_stream_proto_rawDesc), len(file_v1_stream_proto_rawDesc)))
+	})
+	return file_v1_stream_proto_rawDescData
+}
+
+var file_v1_stream_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_v1_stream_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_v1_stream_proto_goTypes = []any{
+	(Classification)(0),           // 0: bgp.v1.Classification
+	(EventType)(0),                // 1: bgp.v1.EventType
+	(LeakType)(0),                 // 2: bgp.v1.LeakType
+	(EventKind)(0),                // 3: bgp.v1.EventKind
+	(*SubscribeRequest)(nil),      // 4: bgp.v1.SubscribeRequest
+	(*SubscribeResponse)(nil),     // 5: bgp.v1.SubscribeResponse
+	(*Leak)(nil),                  // 6: bgp.v1.Leak
+	(*BGPEvent)(nil),              // 7: bgp.v1.BGPEvent
+	(*Incident)(nil),              // 8: bgp.v1.Incident
+	(*Evidence)(nil),              // 9: bgp.v1.Evidence
+	(*ClassificationChange)(nil),  // 10: bgp.v1.ClassificationChange
+	(*timestamppb.Timestamp)(nil), // 11: google.protobuf.Timestamp
+}
+var file_v1_stream_proto_depIdxs = []int32{
+	3,  // 0: bgp.v1.SubscribeRequest.kinds:type_name -> bgp.v1.EventKind
+	0,  // 1: bgp.v1.SubscribeRequest.types:type_name -> bgp.v1.Classification
+	7,  // 2: bgp.v1.SubscribeResponse.event:type_name -> bgp.v1.BGPEvent
+	8,  // 3: bgp.v1.SubscribeResponse.incident:type_name -> bgp.v1.Incident
+	10, // 4: bgp.v1.SubscribeResponse.change:type_name -> bgp.v1.ClassificationChange
+	2,  // 5: bgp.v1.Leak.type:type_name -> bgp.v1.LeakType
+	11, // 6: bgp.v1.BGPEvent.time:type_name -> google.protobuf.Timestamp
+	1,  // 7: bgp.v1.BGPEvent.type:type_name -> bgp.v1.EventType
+	0,  // 8: bgp.v1.BGPEvent.classification:type_name -> bgp.v1.Classification
+	6,  // 9: bgp.v1.BGPEvent.leak:type_name -> bgp.v1.Leak
+	11, // 10: bgp.v1.Incident.time:type_name -> google.protobuf.Timestamp
+	0,  // 11: bgp.v1.Incident.classification:type_name -> bgp.v1.Classification
+	6,  // 12: bgp.v1.Incident.leak:type_name -> bgp.v1.Leak
+	11, // 13: bgp.v1.ClassificationChange.time:type_name -> google.protobuf.Timestamp
+	0,  // 14: bgp.v1.ClassificationChange.from:type_name -> bgp.v1.Classification
+	0,  // 15: bgp.v1.ClassificationChange.to:type_name -> bgp.v1.Classification
+	6,  // 16: bgp.v1.ClassificationChange.leak:type_name -> bgp.v1.Leak
+	9,  // 17: bgp.v1.ClassificationChange.evidence:type_name -> bgp.v1.Evidence
+	4,  // 18: bgp.v1.EventService.Subscribe:input_type -> bgp.v1.SubscribeRequest
+	5,  // 19: bgp.v1.EventService.Subscribe:output_type -> bgp.v1.SubscribeResponse
+	19, // [19:20] is the sub-list for method output_type
+	18, // [18:19] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_v1_stream_proto_init() }
+func file_v1_stream_proto_init() {
+	if File_v1_stream_proto != nil {
+		return
+	}
+	file_v1_stream_proto_msgTypes[1].OneofWrappers = []any{
+		(*SubscribeResponse_Event)(nil),
+		(*SubscribeResponse_Incident)(nil),
+		(*SubscribeResponse_Change)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_v1_stream_proto_rawDesc), len(file_v1_stream_proto_rawDesc)),
+			NumEnums:      4,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_v1_stream_proto_goTypes,
+		DependencyIndexes: file_v1_stream_proto_depIdxs,
+		EnumInfos:         file_v1_stream_proto_enumTypes,
+		MessageInfos:      file_v1_stream_proto_msgTypes,
+	}.Build()
+	File_v1_stream_proto = out.File
+	file_v1_stream_proto_goTypes = nil
+	file_v1_stream_proto_depIdxs = nil
+}
@@ -316,7 +316,19 @@ type PrefixState struct {
 	// ASN identified as the source of a route leak
 	LeakerAsn uint32 `protobuf:"varint,11,opt,name=leaker_asn,json=leakerAsn,proto3" json:"leaker_asn,omitempty"`
 	// ASN identified as the victim of a route leak or hijack
-	VictimAsn     uint32 `protobuf:"varint,12,opt,name=victim_asn,json=victimAsn,proto3" json:"victim_asn,omitempty"`
+	VictimAsn uint32 `protobuf:"varint,12,opt,name=victim_asn,json=victimAsn,proto3" json:"victim_asn,omitempty"`
+	// Long-term decayed activity profile for this prefix
+	Baseline *PrefixBaseline `protobuf:"bytes,13,opt,name=baseline,proto3" json:"baseline,omitempty"`
+	// Anomaly score of the most recent 10-minute window against the baseline
+	AnomalyScore float64 `protobuf:"fixed64,14,opt,name=anomaly_score,json=anomalyScore,proto3" json:"anomaly_score,omitempty"`
+	// Fraction of full-feed peers currently carrying this prefix (0.0 - 1.0)
+	Visibility float64 `protobuf:"fixed64,15,opt,name=visibility,proto3" json:"visibility,omitempty"`
+	// Highest visibility observed within the recent peak window
+	VisibilityPeak float64 `protobuf:"fixed64,16,opt,name=visibility_peak,json=visibilityPeak,proto3" json:"visibility_peak,omitempty"`
+	// Unix timestamp (seconds) when the visibility peak was recorded
+	VisibilityPeakTs int64 `protobuf:"varint,17,opt,name=visibility_peak_ts,json=visibilityPeakTs,proto3" json:"visibility_peak_ts,omitempty"`
+	// Registry entry or allocation check that matched when classified as a bogon
+	BogonReason   string `protobuf:"bytes,18,opt,name=bogon_reason,json=bogonReason,proto3" json:"bogon_reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -435,6 +447,192 @@ func (x *PrefixState) GetVictimAsn() uint32 {
 	return 0
 }
 
+func (x *PrefixState) GetBaseline() *PrefixBaseline {
+	if x != nil {
+		return x.Baseline
+	}
+	return nil
+}
+
+func (x *PrefixState) GetAnomalyScore() float64 {
+	if x != nil {
+		return x.AnomalyScore
+	}
+	return 0
+}
+
+func (x *PrefixState) GetVisibility() float64 {
+	if x != nil {
+		return x.Visibility
+	}
+	return 0
+}
+
+func (x *PrefixState) GetVisibilityPeak() float64 {
+	if x != nil {
+		return x.VisibilityPeak
+	}
+	return 0
+}
+
+func (x *PrefixState) GetVisibilityPeakTs() int64 {
+	if x != nil {
+		return x.VisibilityPeakTs
+	}
+	return 0
+}
+
+func (x *PrefixState) GetBogonReason() string {
+	if x != nil {
+		return x.BogonReason
+	}
+	return ""
+}
+
+type RateBaseline struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Exponentially weighted mean of the per-minute value
+	Mean float64 `protobuf:"fixed64,1,opt,name=mean,proto3" json:"mean,omitempty"`
+	// Exponentially weighted variance of the per-minute value
+	Variance      float64 `protobuf:"fixed64,2,opt,name=variance,proto3" json:"variance,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RateBaseline) Reset() {
+	*x = RateBaseline{}
+	mi := &file_v1_v1_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RateBaseline) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RateBaseline) ProtoMessage() {}
+
+func (x *RateBaseline) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_v1_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RateBaseline.ProtoReflect.Descriptor instead.
+func (*RateBaseline) Descriptor() ([]byte, []int) {
+	return file_v1_v1_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RateBaseline) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *RateBaseline) GetVariance() float64 {
+	if x != nil {
+		return x.Variance
+	}
+	return 0
+}
+
+type PrefixBaseline struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Decayed rate of announcements per minute
+	Announcements *RateBaseline `protobuf:"bytes,1,opt,name=announcements,proto3" json:"announcements,omitempty"`
+	// Decayed rate of withdrawals per minute
+	Withdrawals *RateBaseline `protobuf:"bytes,2,opt,name=withdrawals,proto3" json:"withdrawals,omitempty"`
+	// Decayed rate of AS path changes per minute
+	PathChanges *RateBaseline `protobuf:"bytes,3,opt,name=path_changes,json=pathChanges,proto3" json:"path_changes,omitempty"`
+	// Decayed number of distinct origin ASNs observed at once
+	Origins *RateBaseline `protobuf:"bytes,4,opt,name=origins,proto3" json:"origins,omitempty"`
+	// Minute-aligned Unix timestamp of the last minute folded into the baseline
+	LastMinuteTs int64 `protobuf:"varint,5,opt,name=last_minute_ts,json=lastMinuteTs,proto3" json:"last_minute_ts,omitempty"`
+	// Number of minutes with activity folded into the baseline so far
+	Samples       int64 `protobuf:"varint,6,opt,name=samples,proto3" json:"samples,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrefixBaseline) Reset() {
+	*x = PrefixBaseline{}
+	mi := &file_v1_v1_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrefixBaseline) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrefixBaseline) ProtoMessage() {}
+
+func (x *PrefixBaseline) ProtoReflect() protoreflect.Message {
+	mi := &file_v1_v1_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrefixBaseline.ProtoReflect.Descriptor instead.
+func (*PrefixBaseline) Descriptor() ([]byte, []int) {
+	return file_v1_v1_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PrefixBaseline) GetAnnouncements() *RateBaseline {
+	if x != nil {
+		return x.Announcements
+	}
+	return nil
+}
+
+func (x *PrefixBaseline) GetWithdrawals() *RateBaseline {
+	if x != nil {
+		return x.Withdrawals
+	}
+	return nil
+}
+
+func (x *PrefixBaseline) GetPathChanges() *RateBaseline {
+	if x != nil {
+		return x.PathChanges
+	}
+	return nil
+}
+
+func (x *PrefixBaseline) GetOrigins() *RateBaseline {
+	if x != nil {
+		return x.Origins
+	}
+	return nil
+}
+
+func (x *PrefixBaseline) GetLastMinuteTs() int64 {
+	if x != nil {
+		return x.LastMinuteTs
+	}
+	return 0
+}
+
+func (x *PrefixBaseline) GetSamples() int64 {
+	if x != nil {
+		return x.Samples
+	}
+	return 0
+}
+
 var File_v1_v1_proto protoreflect.FileDescriptor
 
 const file_v1_v1_proto_rawDesc = "" +
@@ -470,7 +668,7 @@ const file_v1_v1_proto_rawDesc = "" +
 	"\x0elast_update_ts\x18\t \x01(\x03R\flastUpdateTs\x12\x12\n" +
 	"\x04host\x18\n" +
 	" \x01(\tR\x04host\x12\x1c\n" +
-	"\twithdrawn\x18\v \x01(\bR\twithdrawn\"\xc2\x05\n" +
+	"\twithdrawn\x18\v \x01(\bR\twithdrawn\"\xb5\a\n" +
 	"\vPrefixState\x12:\n" +
 	"\abuckets\x18\x01 \x03(\v2 .bgp.v1.PrefixState.BucketsEntryR\abuckets\x12N\n" +
 	"\x0fpeer_last_attrs\x18\x02 \x03(\v2&.bgp.v1.PrefixState.PeerLastAttrsEntryR\rpeerLastAttrs\x12$\n" +
@@ -486,13 +684,31 @@ const file_v1_v1_proto_rawDesc = "" +
 	"\n" +
 	"leaker_asn\x18\v \x01(\rR\tleakerAsn\x12\x1d\n" +
 	"\n" +
-	"victim_asn\x18\f \x01(\rR\tvictimAsn\x1aO\n" +
+	"victim_asn\x18\f \x01(\rR\tvictimAsn\x122\n" +
+	"\bbaseline\x18\r \x01(\v2\x16.bgp.v1.PrefixBaselineR\bbaseline\x12#\n" +
+	"\ranomaly_score\x18\x0e \x01(\x01R\fanomalyScore\x12\x1e\n" +
+	"\n" +
+	"visibility\x18\x0f \x01(\x01R\n" +
+	"visibility\x12'\n" +
+	"\x0fvisibility_peak\x18\x10 \x01(\x01R\x0evisibilityPeak\x12,\n" +
+	"\x12visibility_peak_ts\x18\x11 \x01(\x03R\x10visibilityPeakTs\x12!\n" +
+	"\fbogon_reason\x18\x12 \x01(\tR\vbogonReason\x1aO\n" +
 	"\fBucketsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\x03R\x03key\x12)\n" +
 	"\x05value\x18\x02 \x01(\v2\x13.bgp.v1.StatsBucketR\x05value:\x028\x01\x1aS\n" +
 	"\x12PeerLastAttrsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12'\n" +
-	"\x05value\x18\x02 \x01(\v2\x11.bgp.v1.LastAttrsR\x05value:\x028\x01B<Z:github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1;bgpprotob\x06proto3"
+	"\x05value\x18\x02 \x01(\v2\x11.bgp.v1.LastAttrsR\x05value:\x028\x01\">\n" +
+	"\fRateBaseline\x12\x12\n" +
+	"\x04mean\x18\x01 \x01(\x01R\x04mean\x12\x1a\n" +
+	"\bvariance\x18\x02 \x01(\x01R\bvariance\"\xad\x02\n" +
+	"\x0ePrefixBaseline\x12:\n" +
+	"\rannouncements\x18\x01 \x01(\v2\x14.bgp.v1.RateBaselineR\rannouncements\x126\n" +
+	"\vwithdrawals\x18\x02 \x01(\v2\x14.bgp.v1.RateBaselineR\vwithdrawals\x127\n" +
+	"\fpath_changes\x18\x03 \x01(\v2\x14.bgp.v1.RateBaselineR\vpathChanges\x12.\n" +
+	"\aorigins\x18\x04 \x01(\v2\x14.bgp.v1.RateBaselineR\aorigins\x12$\n" +
+	"\x0elast_minute_ts\x18\x05 \x01(\x03R\flastMinuteTs\x12\x18\n" +
+	"\asamples\x18\x06 \x01(\x03R\asamplesB<Z:github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1;bgpprotob\x06proto3"
 
 var (
 	file_v1_v1_proto_rawDescOnce sync.Once
@@ -506,24 +722,31 @@ func file_v1_v1_proto_rawDescGZIP() []byte {
 	return file_v1_v1_proto_rawDescData
 }
 
-var file_v1_v1_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_v1_v1_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_v1_v1_proto_goTypes = []any{
-	(*StatsBucket)(nil), // 0: bgp.v1.StatsBucket
-	(*LastAttrs)(nil),   // 1: bgp.v1.LastAttrs
-	(*PrefixState)(nil), // 2: bgp.v1.PrefixState
-	nil,                 // 3: bgp.v1.PrefixState.BucketsEntry
-	nil,                 // 4: bgp.v1.PrefixState.PeerLastAttrsEntry
+	(*StatsBucket)(nil),    // 0: bgp.v1.StatsBucket
+	(*LastAttrs)(nil),      // 1: bgp.v1.LastAttrs
+	(*PrefixState)(nil),    // 2: bgp.v1.PrefixState
+	(*RateBaseline)(nil),   // 3: bgp.v1.RateBaseline
+	(*PrefixBaseline)(nil), // 4: bgp.v1.PrefixBaseline
+	nil,                    // 5: bgp.v1.PrefixState.BucketsEntry
+	nil,                    // 6: bgp.v1.PrefixState.PeerLastAttrsEntry
 }
 var file_v1_v1_proto_depIdxs = []int32{
-	3, // 0: bgp.v1.PrefixState.buckets:type_name -> bgp.v1.PrefixState.BucketsEntry
-	4, // 1: bgp.v1.PrefixState.peer_last_attrs:type_name -> bgp.v1.PrefixState.PeerLastAttrsEntry
-	0, // 2: bgp.v1.PrefixState.BucketsEntry.value:type_name -> bgp.v1.StatsBucket
-	1, // 3: bgp.v1.PrefixState.PeerLastAttrsEntry.value:type_name -> bgp.v1.LastAttrs
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	5, // 0: bgp.v1.PrefixState.buckets:type_name -> bgp.v1.PrefixState.BucketsEntry
+	6, // 1: bgp.v1.PrefixState.peer_last_attrs:type_name -> bgp.v1.PrefixState.PeerLastAttrsEntry
+	4, // 2: bgp.v1.PrefixState.baseline:type_name -> bgp.v1.PrefixBaseline
+	3, // 3: bgp.v1.PrefixBaseline.announcements:type_name -> bgp.v1.RateBaseline
+	3, // 4: bgp.v1.PrefixBaseline.withdrawals:type_name -> bgp.v1.RateBaseline
+	3, // 5: bgp.v1.PrefixBaseline.path_changes:type_name -> bgp.v1.RateBaseline
+	3, // 6: bgp.v1.PrefixBaseline.origins:type_name -> bgp.v1.RateBaseline
+	0, // 7: bgp.v1.PrefixState.BucketsEntry.value:type_name -> bgp.v1.StatsBucket
+	1, // 8: bgp.v1.PrefixState.PeerLastAttrsEntry.value:type_name -> bgp.v1.LastAttrs
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_v1_v1_proto_init() }
@@ -537,7 +760,7 @@ func file_v1_v1_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_v1_v1_proto_rawDesc), len(file_v1_v1_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,181 @@
+package bgp
+
+import (
+	"context"
+	"log"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// analysisWindow is the span of minute buckets read by the classification rules.
+	analysisWindow = 10 * time.Minute
+	// peerAttrTTL is how long a session's last attributes count towards consensus.
+	peerAttrTTL = time.Hour
+
+	// DefaultStateTTL is how long a prefix may go without updates before its state is deleted.
+	DefaultStateTTL = 30 * 24 * time.Hour
+
+	stateCompactionInterval = time.Hour
+	valueLogGCInterval      = 10 * time.Minute
+	// valueLogGCDiscardRatio is the fraction of stale data a value log file needs before it is rewritten.
+	valueLogGCDiscardRatio = 0.5
+)
+
+// CompactPrefixState drops data the classifier no longer reads: minute buckets
+// outside the analysis window, which are folded into the baseline first, and
+// session attributes that have not been refreshed within peerAttrTTL. It
+// reports whether the state was changed.
+func CompactPrefixState(state *bgpproto.PrefixState, now time.Time) bool {
+	changed := false
+	if state.Baseline != nil {
+		before := state.Baseline.LastMinuteTs
+		updateBaseline(state, now.Truncate(time.Minute).Unix())
+		changed = state.Baseline.LastMinuteTs != before
+	}
+
+	cutoff := now.Add(-analysisWindow).Unix()
+	for ts := range state.Buckets {
+		if ts < cutoff {
+			delete(state.Buckets, ts)
+			changed = true
+		}
+	}
+	for peer, attr := range state.PeerLastAttrs {
+		if now.Unix()-attr.LastUpdateTs > int64(peerAttrTTL.Seconds()) {
+			delete(state.PeerLastAttrs, peer)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// StateCompactionStats summarizes a compaction pass over a prefix state database.
+type StateCompactionStats struct {
+	Trimmed int
+	Deleted int
+}
+
+// CompactStateDB compacts every prefix state in db and deletes prefixes that
+// have not been updated within ttl. A ttl of zero keeps idle prefixes.
+// Write-behind flushes may run meanwhile: DiskTrie.Rewrite keeps them out of
+// the chunk being rewritten, so a newer flushed state is never overwritten.
+func CompactStateDB(db *utils.DiskTrie, now time.Time, ttl time.Duration) (StateCompactionStats, error) {
+	trimmed, deleted, err := db.Rewrite(func(k, v []byte) ([]byte, bool) {
+		if len(k) != 5 {
+			return nil, false
+		}
+		state := &bgpproto.PrefixState{}
+		if err := proto.Unmarshal(v, state); err != nil {
+			return nil, false
+		}
+		if ttl > 0 && now.Sub(time.Unix(state.LastUpdateTs, 0)) > ttl {
+			return nil, true
+		}
+		if !CompactPrefixState(state, now) {
+			return nil, false
+		}
+		data, err := proto.Marshal(state)
+		if err != nil {
+			return nil, false
+		}
+		return data, false
+	})
+	return StateCompactionStats{Trimmed: trimmed, Deleted: deleted}, err
+}
+
+// ValueLogGCStats reports the effect of a value log garbage collection run.
+type ValueLogGCStats struct {
+	SizeBefore int64
+	SizeAfter  int64
+	Rewritten  int
+}
+
+// Reclaimed returns the number of bytes freed on disk.
+func (s ValueLogGCStats) Reclaimed() int64 {
+	if s.SizeAfter > s.SizeBefore {
+		return 0
+	}
+	return s.SizeBefore - s.SizeAfter
+}
+
+// GarbageCollect rewrites value log files with mostly stale data.
+func GarbageCollect(db *utils.DiskTrie) (ValueLogGCStats, error) {
+	lsm, vlog := db.Size()
+	stats := ValueLogGCStats{SizeBefore: lsm + vlog}
+	rewritten, err := db.RunValueLogGC(valueLogGCDiscardRatio)
+	stats.Rewritten = rewritten
+	lsm, vlog = db.Size()
+	stats.SizeAfter = lsm + vlog
+	return stats, err
+}
+
+// StateMaintainer periodically compacts the prefix state database and runs
+// value log garbage collection on the state and seen-prefix databases while
+// the processor is running.
+type StateMaintainer struct {
+	stateDB      *utils.DiskTrie
+	seenDB       *utils.DiskTrie
+	ttl          time.Duration
+	timeProvider TimeProvider
+}
+
+func NewStateMaintainer(stateDB, seenDB *utils.DiskTrie, ttl time.Duration, timeProvider TimeProvider) *StateMaintainer {
+	return &StateMaintainer{
+		stateDB:      stateDB,
+		seenDB:       seenDB,
+		ttl:          ttl,
+		timeProvider: timeProvider,
+	}
+}
+
+// Run performs maintenance on a schedule until ctx is canceled.
+func (m *StateMaintainer) Run(ctx context.Context) {
+	compactTicker := time.NewTicker(stateCompactionInterval)
+	defer compactTicker.Stop()
+	gcTicker := time.NewTicker(valueLogGCInterval)
+	defer gcTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-compactTicker.C:
+			m.Compact()
+		case <-gcTicker.C:
+			m.collectGarbage("prefix-state", m.stateDB)
+			m.collectGarbage("seen-prefixes", m.seenDB)
+		}
+	}
+}
+
+// Compact trims and expires prefix states, then reclaims the space they used.
+func (m *StateMaintainer) Compact() {
+	if m.stateDB == nil {
+		return
+	}
+	start := time.Now()
+	stats, err := CompactStateDB(m.stateDB, m.timeProvider(), m.ttl)
+	if err != nil {
+		log.Printf("[STATE-DB] Error compacting prefix states: %v", err)
+		return
+	}
+	log.Printf("[STATE-DB] Compacted prefix states in %v: %d trimmed, %d expired", time.Since(start).Round(time.Millisecond), stats.Trimmed, stats.Deleted)
+	m.collectGarbage("prefix-state", m.stateDB)
+}
+
+func (m *StateMaintainer) collectGarbage(name string, db *utils.DiskTrie) {
+	if db == nil {
+		return
+	}
+	stats, err := GarbageCollect(db)
+	if err != nil {
+		log.Printf("[STATE-DB] Error during %s value log GC: %v", name, err)
+		return
+	}
+	log.Printf("[STATE-DB] %s: %.1f MB on disk, %.1f MB reclaimed (%d value log files rewritten)",
+		name, float64(stats.SizeAfter)/(1<<20), float64(stats.Reclaimed())/(1<<20), stats.Rewritten)
+}
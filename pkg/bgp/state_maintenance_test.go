@@ -0,0 +1,109 @@
+package bgp
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCompactPrefixState(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	state := &bgpproto.PrefixState{
+		PeerLastAttrs: map[string]*bgpproto.LastAttrs{
+			"rrc00:fresh": {LastUpdateTs: now.Add(-time.Minute).Unix(), OriginAsn: 200},
+			"rrc00:stale": {LastUpdateTs: now.Add(-2 * time.Hour).Unix(), OriginAsn: 200},
+		},
+		Buckets: map[int64]*bgpproto.StatsBucket{
+			now.Add(-time.Minute).Unix():      {Announcements: 1},
+			now.Add(-30 * time.Minute).Unix(): {Announcements: 4},
+		},
+		Baseline: &bgpproto.PrefixBaseline{
+			Announcements: &bgpproto.RateBaseline{},
+			Withdrawals:   &bgpproto.RateBaseline{},
+			PathChanges:   &bgpproto.RateBaseline{},
+			Origins:       &bgpproto.RateBaseline{},
+			LastMinuteTs:  now.Add(-time.Hour).Unix(),
+		},
+	}
+
+	if !CompactPrefixState(state, now) {
+		t.Fatalf("expected state to change")
+	}
+	if len(state.Buckets) != 1 {
+		t.Errorf("expected only the recent bucket to remain, got %d", len(state.Buckets))
+	}
+	if _, ok := state.PeerLastAttrs["rrc00:stale"]; ok {
+		t.Errorf("expected stale peer attributes to be removed")
+	}
+	if _, ok := state.PeerLastAttrs["rrc00:fresh"]; !ok {
+		t.Errorf("expected fresh peer attributes to be kept")
+	}
+	// The trimmed bucket must have been folded into the baseline first, as one
+	// of the two minutes with activity
+	if state.Baseline.Samples != 2 || state.Baseline.Announcements.Mean <= 0 {
+		t.Errorf("expected trimmed buckets to be folded into the baseline, got %+v", state.Baseline)
+	}
+
+	if CompactPrefixState(state, now) {
+		t.Errorf("expected a second pass to be a no-op")
+	}
+}
+
+func TestCompactStateDB(t *testing.T) {
+	db, err := utils.OpenDiskTrie(filepath.Join(t.TempDir(), "test-state.db"))
+	if err != nil {
+		t.Fatalf("failed to open stateDB: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	now := time.Now().Truncate(time.Minute)
+	put := func(prefix string, state *bgpproto.PrefixState) {
+		data, err := proto.Marshal(state)
+		if err != nil {
+			t.Fatalf("failed to marshal state: %v", err)
+		}
+		if err := db.Put(prefix, data); err != nil {
+			t.Fatalf("failed to write state: %v", err)
+		}
+	}
+	put("8.8.8.0/24", &bgpproto.PrefixState{
+		LastUpdateTs: now.Add(-30 * time.Minute).Unix(),
+		Buckets: map[int64]*bgpproto.StatsBucket{
+			now.Add(-30 * time.Minute).Unix(): {Announcements: 1},
+		},
+	})
+	put("9.9.9.0/24", &bgpproto.PrefixState{
+		LastUpdateTs: now.Add(-48 * time.Hour).Unix(),
+	})
+	put("1.1.1.0/24", &bgpproto.PrefixState{
+		LastUpdateTs: now.Unix(),
+	})
+
+	stats, err := CompactStateDB(db, now, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("compaction failed: %v", err)
+	}
+	if stats.Trimmed != 1 || stats.Deleted != 1 {
+		t.Errorf("expected 1 trimmed and 1 deleted, got %+v", stats)
+	}
+
+	if data, _ := db.Get("9.9.9.0/24"); data != nil {
+		t.Errorf("expected idle prefix to be deleted")
+	}
+	data, _ := db.Get("8.8.8.0/24")
+	state := &bgpproto.PrefixState{}
+	if err := proto.Unmarshal(data, state); err != nil || len(state.Buckets) != 0 {
+		t.Errorf("expected old buckets to be trimmed, got %+v (err %v)", state.Buckets, err)
+	}
+	if data, _ := db.Get("1.1.1.0/24"); data == nil {
+		t.Errorf("expected active prefix to be kept")
+	}
+
+	if _, err := GarbageCollect(db); err != nil {
+		t.Errorf("value log GC failed: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package bgp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+// TraceStage names the part of the classifier a trace step comes from.
+type TraceStage string
+
+const (
+	// TraceBucket steps report the counts of the minute bucket a message went into.
+	TraceBucket TraceStage = "bucket"
+	// TracePeer steps report what changed in a peer's route.
+	TracePeer TraceStage = "peer"
+	// TraceRPKI steps report the RPKI status of the announced origin.
+	TraceRPKI TraceStage = "rpki"
+	// TraceStats steps report the totals over the analysis window.
+	TraceStats TraceStage = "stats"
+	// TraceRule steps report a rule that was evaluated and whether it fired.
+	TraceRule TraceStage = "rule"
+	// TraceState steps report changes to the prefix's classification.
+	TraceState TraceStage = "state"
+)
+
+// TraceStep is one step of the classifier's decision on a message.
+type TraceStep struct {
+	Time   time.Time  `json:"time"`
+	Prefix string     `json:"prefix"`
+	Peer   string     `json:"peer,omitempty"`
+	Stage  TraceStage `json:"stage"`
+	// Rule and Fired are set for rule steps
+	Rule   string `json:"rule,omitempty"`
+	Fired  bool   `json:"fired,omitempty"`
+	Detail string `json:"detail"`
+}
+
+type TraceCallback func(step *TraceStep)
+
+// SetTraceCallback registers fn to be called from ClassifyEvent with every
+// step of the decision on a message: the bucket and peer updates, the RPKI
+// status, the window totals and each rule evaluated. Tracing is meant for
+// debugging a few prefixes; it slows the classifier down.
+func (c *Classifier) SetTraceCallback(fn TraceCallback) {
+	c.onTrace = fn
+}
+
+// tracer reports the steps for one prefix and message. It is nil when no
+// callback is set; the classifier checks for that before building the
+// arguments of a step or rule, so untraced messages don't pay for formatting.
+type tracer struct {
+	fn     TraceCallback
+	prefix string
+	ctx    *MessageContext
+}
+
+func (c *Classifier) tracer(prefix string, ctx *MessageContext) *tracer {
+	if c.onTrace == nil {
+		return nil
+	}
+	return &tracer{fn: c.onTrace, prefix: prefix, ctx: ctx}
+}
+
+func (t *tracer) step(stage TraceStage, format string, args ...any) {
+	if t == nil {
+		return
+	}
+	t.fn(&TraceStep{
+		Time:   t.ctx.Now,
+		Prefix: t.prefix,
+		Peer:   t.ctx.Host + ":" + t.ctx.Peer,
+		Stage:  stage,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// rule reports a rule that was evaluated and whether it fired.
+func (t *tracer) rule(name string, fired bool, format string, args ...any) {
+	if t == nil {
+		return
+	}
+	t.fn(&TraceStep{
+		Time:   t.ctx.Now,
+		Prefix: t.prefix,
+		Peer:   t.ctx.Host + ":" + t.ctx.Peer,
+		Stage:  TraceRule,
+		Rule:   name,
+		Fired:  fired,
+		Detail: fmt.Sprintf(format, args...),
+	})
+}
+
+// stats reports the totals a classification is based on.
+func (t *tracer) stats(s *prefixStats, elapsed float64) {
+	if t == nil {
+		return
+	}
+	t.step(TraceStats, "%d msgs (%d ann, %d with) over %.0fs; changes: path %d, comm %d, next hop %d, aggregator %d, MED %d, local pref %d, path length +%d/-%d",
+		s.totalMsgs, s.totalAnn, s.totalWith, elapsed, s.totalPath, s.totalComm, s.totalHop, s.totalAgg, s.totalMed, s.totalLP, s.totalIncreases, s.totalDecreases)
+	baseline := "baseline not ready"
+	if s.baselineReady {
+		baseline = "baseline ready"
+	}
+	visibility := ""
+	if s.hasVisibility {
+		visibility = fmt.Sprintf(", visibility %.0f%% (peak %.0f%%)", s.visibility*100, s.visibilityPeak*100)
+	}
+	t.step(TraceStats, "%d peers/%d hosts on the origin, %d withdrawn peers/%d hosts, %d origins, %d next hops; anomaly score %.1f (%s)%s",
+		len(s.uniquePeers), len(s.uniqueHosts), len(s.withdrawnPeers), len(s.withdrawnHosts), len(s.uniqueASNs), len(s.uniqueHops), s.anomalyScore, baseline, visibility)
+}
+
+// withdrawal reports a peer withdrawing the prefix. last is the peer's route
+// before the message, if any.
+func (t *tracer) withdrawal(last *bgpproto.LastAttrs) {
+	if t == nil {
+		return
+	}
+	switch {
+	case last == nil:
+		t.step(TracePeer, "withdrawn without a route seen before")
+	case last.Withdrawn:
+		t.step(TracePeer, "withdrawn again")
+	default:
+		t.step(TracePeer, "withdrawn, was %s via %s", last.Path, last.NextHop)
+	}
+}
+
+// announcement reports what changed in a peer's route, counted the way
+// compareAndUpdateBucketStats counts it. last is the route before the message.
+func (t *tracer) announcement(last *bgpproto.LastAttrs) {
+	if t == nil {
+		return
+	}
+	ctx := t.ctx
+	if last == nil {
+		t.step(TracePeer, "new route %s via %s", ctx.PathStr, ctx.NextHop)
+		return
+	}
+	var changes []string
+	if last.Withdrawn {
+		changes = append(changes, "announced again")
+	}
+	if ctx.PathStr != last.Path {
+		changes = append(changes, fmt.Sprintf("path %s -> %s", last.Path, ctx.PathStr))
+	}
+	if int32(ctx.PathLen) != last.LastPathLen && last.LastPathLen != 0 {
+		changes = append(changes, fmt.Sprintf("path length %d -> %d", last.LastPathLen, ctx.PathLen))
+	}
+	if ctx.CommStr != last.Communities {
+		changes = append(changes, fmt.Sprintf("communities %q -> %q", last.Communities, ctx.CommStr))
+	}
+	if ctx.NextHop != last.NextHop {
+		changes = append(changes, fmt.Sprintf("next hop %s -> %s", last.NextHop, ctx.NextHop))
+	}
+	if ctx.Aggregator != last.Aggregator {
+		changes = append(changes, fmt.Sprintf("aggregator %q -> %q", last.Aggregator, ctx.Aggregator))
+	}
+	if ctx.Med != last.Med {
+		changes = append(changes, fmt.Sprintf("MED %d -> %d", last.Med, ctx.Med))
+	}
+	if ctx.LocalPref != last.LocalPref {
+		changes = append(changes, fmt.Sprintf("local pref %d -> %d", last.LocalPref, ctx.LocalPref))
+	}
+	t.step(TracePeer, "%s", joinOr(changes, "no attribute changes"))
+}
+
+func joinOr(parts []string, none string) string {
+	if len(parts) == 0 {
+		return none
+	}
+	return strings.Join(parts, ", ")
+}
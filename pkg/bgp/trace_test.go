@@ -0,0 +1,104 @@
+package bgp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifier_TraceCallback(t *testing.T) {
+	c := newBaselineTestClassifier()
+	var steps []*TraceStep
+	c.SetTraceCallback(func(s *TraceStep) {
+		steps = append(steps, s)
+	})
+
+	prefix := "5.5.5.0/24"
+	start := time.Now().Truncate(time.Hour)
+	classify := func(peer int, path string, at time.Duration) {
+		c.ClassifyEvent(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", peer), Host: fmt.Sprintf("rrc%d", peer%2), PathStr: path, PathLen: 3, OriginASN: 702,
+			Now: start.Add(at),
+		})
+	}
+	find := func(stage TraceStage, rule string) *TraceStep {
+		for _, s := range steps {
+			if s.Stage == stage && s.Rule == rule {
+				return s
+			}
+		}
+		return nil
+	}
+
+	classify(0, "[100 200 702]", 0)
+	if s := steps[0]; s.Stage != TracePeer || s.Prefix != prefix || s.Peer != "rrc0:peer0" || !strings.HasPrefix(s.Detail, "new route") {
+		t.Errorf("expected a new route step first, got %+v", s)
+	}
+	if s := find(TraceRPKI, ""); s == nil {
+		t.Errorf("expected the RPKI status to be traced")
+	}
+	steps = nil
+	classify(0, "[12956 500 702]", 30*time.Second)
+	if s := find(TracePeer, ""); s == nil || !strings.Contains(s.Detail, "path [100 200 702] -> [12956 500 702]") {
+		t.Errorf("expected the path change to be traced, got %+v", s)
+	}
+	if s := steps[len(steps)-1]; s.Stage != TraceState || !strings.HasPrefix(s.Detail, "not evaluated") {
+		t.Errorf("expected two messages in 30s not to be evaluated, got %+v", s)
+	}
+
+	// Two peers are not enough to call a leak
+	steps = nil
+	classify(1, "[12956 500 702]", 90*time.Second)
+	if s := find(TraceStats, ""); s == nil || !strings.Contains(s.Detail, "3 msgs") {
+		t.Errorf("expected the window totals to be traced, got %+v", s)
+	}
+	if s := find(TraceRule, "route leak"); s == nil || s.Fired || !strings.Contains(s.Detail, "Hairpin Turn by AS500") {
+		t.Errorf("expected the leak rule to be held back by consensus, got %+v", s)
+	}
+
+	steps = nil
+	classify(2, "[12956 500 702]", 120*time.Second)
+	if s := find(TraceRule, "route leak"); s == nil || !s.Fired {
+		t.Errorf("expected the leak rule to fire, got %+v", s)
+	}
+	if s := find(TraceRule, "link flap"); s != nil {
+		t.Errorf("expected no rules after a critical one fired, got %+v", s)
+	}
+	if s := steps[len(steps)-1]; s.Stage != TraceState || s.Detail != "None -> Route Leak" {
+		t.Errorf("expected the transition last, got %+v", s)
+	}
+
+	// A classified prefix is held without evaluating the rules
+	steps = nil
+	classify(0, "[12956 500 702]", 150*time.Second)
+	if s := steps[len(steps)-1]; s.Stage != TraceState || !strings.HasPrefix(s.Detail, "held as Route Leak") {
+		t.Errorf("expected the classification to be held, got %+v", s)
+	}
+}
+
+func BenchmarkClassifyEvent(b *testing.B) {
+	bench := func(b *testing.B, onTrace TraceCallback) {
+		c := newBaselineTestClassifier()
+		if onTrace != nil {
+			c.SetTraceCallback(onTrace)
+		}
+		prefixes := make([]string, 256)
+		for i := range prefixes {
+			prefixes[i] = fmt.Sprintf("5.5.%d.0/24", i)
+		}
+		peers := []string{"peer0", "peer1", "peer2", "peer3"}
+		paths := []string{"[100 200 702]", "[300 200 702]", "[100 400 200 702]"}
+		start := time.Now().Truncate(time.Hour)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.ClassifyEvent(prefixes[i%len(prefixes)], &MessageContext{
+				Peer: peers[i%len(peers)], Host: "rrc0", PathStr: paths[i%len(paths)], PathLen: 3, OriginASN: 702,
+				Now: start.Add(time.Duration(i) * time.Second),
+			})
+		}
+	}
+	b.Run("untraced", func(b *testing.B) { bench(b, nil) })
+	b.Run("traced", func(b *testing.B) { bench(b, func(*TraceStep) {}) })
+}
@@ -0,0 +1,121 @@
+package bgp
+
+import (
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+// Evidence captures what the classifier observed when a prefix changed
+// classification.
+type Evidence struct {
+	Peer        string
+	Host        string
+	Path        string
+	Communities string
+
+	// Totals over the analysis window
+	Messages      int32
+	Announcements int32
+	Withdrawals   int32
+	PathChanges   int32
+
+	Peers          int
+	Hosts          int
+	WithdrawnPeers int
+	WithdrawnHosts int
+	Origins        int
+
+	AnomalyScore float64
+	RPKIStatus   int32
+}
+
+// Transition describes a prefix entering, escalating or leaving a classification.
+type Transition struct {
+	Time          time.Time
+	IP            uint32
+	Prefix        string
+	From          ClassificationType
+	To            ClassificationType
+	OriginASN     uint32
+	HistoricalASN uint32
+	Lat, Lng      float64
+	Country       string
+	City          string
+	LeakDetail    *LeakDetail
+	Evidence      Evidence
+}
+
+type TransitionCallback func(t *Transition)
+
+// SetTransitionCallback registers fn to be called from ClassifyEvent whenever a
+// prefix changes classification. Ongoing classifications that are renewed
+// without changing type are not reported.
+func (c *Classifier) SetTransitionCallback(fn TransitionCallback) {
+	c.onTransition = fn
+}
+
+func (c *Classifier) emitTransition(prefix string, state *bgpproto.PrefixState, from ClassificationType, ev PendingEvent, classified bool, historicalOriginAsn uint32, ctx *MessageContext) {
+	to := ClassificationType(state.ClassifiedType)
+	t := &Transition{
+		Time:          ctx.Now,
+		IP:            c.prefixToIP(prefix),
+		Prefix:        prefix,
+		From:          from,
+		To:            to,
+		OriginASN:     ctx.OriginASN,
+		HistoricalASN: historicalOriginAsn,
+	}
+	if classified && ev.ClassificationType == to {
+		t.OriginASN = ev.ASN
+		t.LeakDetail = ev.LeakDetail
+	}
+
+	s := c.aggregateRecentBuckets(state, ctx.Now, ctx.OriginASN)
+	t.Evidence = newEvidence(&s, state)
+	t.Evidence.Peer = ctx.Peer
+	t.Evidence.Host = ctx.Host
+	t.Evidence.Path = ctx.PathStr
+	t.Evidence.Communities = ctx.CommStr
+	c.onTransition(t)
+}
+
+func newEvidence(s *prefixStats, state *bgpproto.PrefixState) Evidence {
+	return Evidence{
+		Messages:       s.totalMsgs,
+		Announcements:  s.totalAnn,
+		Withdrawals:    s.totalWith,
+		PathChanges:    s.totalPath,
+		Peers:          len(s.uniquePeers),
+		Hosts:          len(s.uniqueHosts),
+		WithdrawnPeers: len(s.withdrawnPeers),
+		WithdrawnHosts: len(s.withdrawnHosts),
+		Origins:        len(s.uniqueASNs),
+		AnomalyScore:   state.AnomalyScore,
+		RPKIStatus:     state.LastRpkiStatus,
+	}
+}
+
+// StateEvidence returns the evidence stored in a prefix's state as of its last
+// update. Peer, host, path and communities come from the most recent
+// announcement of the last seen origin.
+func StateEvidence(state *bgpproto.PrefixState) Evidence {
+	s := summarizeState(state, time.Unix(state.LastUpdateTs, 0), state.LastOriginAsn)
+	ev := newEvidence(&s, state)
+	var latest *bgpproto.LastAttrs
+	for peer, attr := range state.PeerLastAttrs {
+		if attr.Withdrawn || attr.OriginAsn != state.LastOriginAsn {
+			continue
+		}
+		if latest == nil || attr.LastUpdateTs > latest.LastUpdateTs || (attr.LastUpdateTs == latest.LastUpdateTs && peer < ev.Peer) {
+			latest = attr
+			ev.Peer = peer
+		}
+	}
+	if latest != nil {
+		ev.Host = latest.Host
+		ev.Path = latest.Path
+		ev.Communities = latest.Communities
+	}
+	return ev
+}
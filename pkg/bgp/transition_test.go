@@ -0,0 +1,86 @@
+package bgp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+func TestClassifier_TransitionCallback(t *testing.T) {
+	c := newBaselineTestClassifier()
+	var transitions []*Transition
+	c.SetTransitionCallback(func(tr *Transition) {
+		transitions = append(transitions, tr)
+	})
+
+	prefix := "10.1.0.0/16"
+	start := time.Now().Truncate(time.Hour)
+	for i := 0; i < 8; i++ {
+		c.ClassifyEvent(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: "rrc00", PathStr: "[100 200]", OriginASN: 200,
+			Now: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	// Renewals of the same classification are not transitions
+	if len(transitions) != 1 {
+		t.Fatalf("expected exactly one transition, got %d", len(transitions))
+	}
+	tr := transitions[0]
+	if tr.From != ClassificationNone || tr.To != ClassificationBogon {
+		t.Errorf("expected None -> Bogon, got %s -> %s", tr.From, tr.To)
+	}
+	if tr.LeakDetail == nil || tr.LeakDetail.Reason == "" {
+		t.Errorf("expected the bogon reason to be attached, got %+v", tr.LeakDetail)
+	}
+	if tr.Evidence.Messages < 5 || tr.Evidence.Peers == 0 || tr.Evidence.Path != "[100 200]" {
+		t.Errorf("expected evidence from the analysis window, got %+v", tr.Evidence)
+	}
+
+	// An outage cleared by a new announcement is reported as a transition back
+	state, _ := c.GetPrefixState(prefix)
+	state.ClassifiedType = int32(ClassificationOutage)
+	state.ClassifiedTimeTs = start.Unix()
+	transitions = nil
+	c.ClassifyEvent(prefix, &MessageContext{Peer: "peer0", Host: "rrc00", PathStr: "[100 200]", OriginASN: 200, Now: start.Add(10 * time.Second)})
+	if len(transitions) != 1 || transitions[0].From != ClassificationOutage || transitions[0].To == ClassificationOutage {
+		t.Errorf("expected a transition out of Outage, got %+v", transitions)
+	}
+}
+
+func TestStateEvidence(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	state := &bgpproto.PrefixState{
+		LastUpdateTs:   now.Unix(),
+		LastOriginAsn:  200,
+		LastRpkiStatus: 2,
+		Buckets: map[int64]*bgpproto.StatsBucket{
+			now.Add(-time.Minute).Unix(): {Announcements: 3, Withdrawals: 1, TotalMessages: 4, PathChanges: 2},
+			now.Unix():                   {Announcements: 1, TotalMessages: 1},
+			// Outside the analysis window
+			now.Add(-time.Hour).Unix(): {Announcements: 50, TotalMessages: 50},
+		},
+		PeerLastAttrs: map[string]*bgpproto.LastAttrs{
+			"peer1": {Path: "100 200", OriginAsn: 200, Host: "rrc00", LastUpdateTs: now.Add(-time.Minute).Unix()},
+			"peer2": {Path: "300 200", OriginAsn: 200, Host: "rrc01", LastUpdateTs: now.Unix()},
+			"peer3": {Path: "400 666", OriginAsn: 666, Host: "rrc01", LastUpdateTs: now.Unix() + 1},
+			"peer4": {Withdrawn: true, Host: "rrc03", LastUpdateTs: now.Unix()},
+		},
+	}
+	ev := StateEvidence(state)
+	if ev.Messages != 5 || ev.Announcements != 4 || ev.Withdrawals != 1 || ev.PathChanges != 2 {
+		t.Errorf("unexpected window totals %+v", ev)
+	}
+	if ev.Peers != 2 || ev.Hosts != 2 || ev.WithdrawnPeers != 1 || ev.Origins != 2 {
+		t.Errorf("unexpected peer counts %+v", ev)
+	}
+	// The latest announcement of the last origin, not of another origin
+	if ev.Peer != "peer2" || ev.Host != "rrc01" || ev.Path != "300 200" || ev.RPKIStatus != 2 {
+		t.Errorf("unexpected latest announcement %+v", ev)
+	}
+	if len(state.PeerLastAttrs) != 4 {
+		t.Error("expected the state to be left unchanged")
+	}
+}
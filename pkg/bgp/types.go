@@ -71,10 +71,28 @@ func (t LeakType) String() string {
 	}
 }
 
+// ParseLeakType returns the leak type with the name returned by String.
+func ParseLeakType(name string) (LeakType, bool) {
+	for t := LeakHairpin; t <= DDoSTrafficRedirection; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return LeakUnknown, false
+}
+
 type LeakDetail struct {
 	Type      LeakType
 	LeakerASN uint32
 	VictimASN uint32
+
+	// Visibility and PeakVisibility are set for outages when the full-feed peer
+	// population is known. Both are fractions between 0 and 1.
+	Visibility     float64
+	PeakVisibility float64
+
+	// Reason is set for bogons and names the exact check that matched.
+	Reason string
 }
 
 type ClassificationType int
@@ -129,6 +147,80 @@ func (t ClassificationType) String() string {
 	}
 }
 
+// Key returns a short identifier for the classification, as used by
+// command-line filters and machine-readable output.
+func (t ClassificationType) Key() string {
+	switch t {
+	case ClassificationFlap:
+		return "flap"
+	case ClassificationPathHunting:
+		return "path_hunting"
+	case ClassificationTrafficEngineering:
+		return "traffic_eng"
+	case ClassificationOutage:
+		return "outage"
+	case ClassificationRouteLeak:
+		return "route_leak"
+	case ClassificationDiscovery:
+		return "discovery"
+	case ClassificationDDoSMitigation:
+		return "ddos_mitigation"
+	case ClassificationHijack:
+		return "bgp_hijack"
+	case ClassificationBogon:
+		return "bogon_martian"
+	default:
+		return "none"
+	}
+}
+
+// ParseClassificationKey returns the classification identified by key.
+func ParseClassificationKey(key string) (ClassificationType, bool) {
+	for t := ClassificationFlap; t <= ClassificationBogon; t++ {
+		if t.Key() == key {
+			return t, true
+		}
+	}
+	return ClassificationNone, false
+}
+
+// ParseClassificationName returns the classification with the display name
+// returned by String.
+func ParseClassificationName(name string) (ClassificationType, bool) {
+	for t := ClassificationFlap; t <= ClassificationBogon; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return ClassificationNone, false
+}
+
+// Priority ranks classifications for the summaries, from 3 for critical
+// incidents down to 0 for discovery and anything unranked.
+func (t ClassificationType) Priority() int {
+	switch t {
+	case ClassificationRouteLeak, ClassificationOutage, ClassificationHijack:
+		return 3
+	case ClassificationFlap:
+		return 2
+	case ClassificationTrafficEngineering, ClassificationPathHunting, ClassificationDDoSMitigation:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsCritical reports whether the classification is an incident rather than
+// routine routing activity.
+func (t ClassificationType) IsCritical() bool {
+	switch t {
+	case ClassificationRouteLeak, ClassificationOutage, ClassificationDDoSMitigation, ClassificationHijack, ClassificationBogon:
+		return true
+	default:
+		return false
+	}
+}
+
 type MessageContext struct {
 	IsWithdrawal   bool
 	NumPrefixes    int
@@ -0,0 +1,292 @@
+package bgp
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+const (
+	// fullFeedFraction is how close a session must come to the largest session's
+	// distinct prefix count to be considered a full-table peer.
+	fullFeedFraction = 0.5
+	// minFullFeedPrefixes avoids treating every session as full-feed right after startup.
+	minFullFeedPrefixes = 10000
+	// minFullFeedPeers is the smallest population for which a visibility ratio is meaningful.
+	minFullFeedPeers = 10
+	// sessionIdleTimeout drops sessions that stopped sending updates.
+	sessionIdleTimeout = time.Hour
+	// fullFeedRefreshInterval controls how often the full-feed population is recomputed.
+	fullFeedRefreshInterval = 30 * time.Second
+
+	// visibilityPeakWindow is how long a peak visibility is remembered.
+	visibilityPeakWindow = time.Hour
+	// partialOutageVisibility is the visibility below which a sharp drop becomes an outage.
+	partialOutageVisibility = 0.5
+	// partialOutageDrop is the minimum drop from the recent peak for a partial outage.
+	partialOutageDrop = 0.4
+)
+
+const (
+	hllPrecision = 10
+	hllRegisters = 1 << hllPrecision
+)
+
+// hyperLogLog estimates the number of distinct prefixes a session has carried
+// in constant memory.
+type hyperLogLog [hllRegisters]uint8
+
+func (h *hyperLogLog) add(x uint64) {
+	idx := x >> (64 - hllPrecision)
+	w := x<<hllPrecision | 1<<(hllPrecision-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+	if rank > h[idx] {
+		h[idx] = rank
+	}
+}
+
+func (h *hyperLogLog) estimate() float64 {
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+	for _, r := range h {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities
+		est = m * math.Log(m/float64(zeros))
+	}
+	return est
+}
+
+func hashPrefix(prefix string) uint64 {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(prefix))
+	// FNV leaves the high bits poorly mixed, which HyperLogLog relies on
+	x := f.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+type peerSession struct {
+	host     string
+	mu       sync.Mutex
+	lastSeen int64
+	prefixes hyperLogLog
+}
+
+type fullFeedSnapshot struct {
+	sessions     map[string]struct{}
+	perCollector map[string]int
+}
+
+// PeerTracker keeps track of the peer population across all collectors so that
+// per-prefix visibility can be expressed as a fraction of the full-feed peers.
+// It is safe for concurrent use by multiple classifiers.
+type PeerTracker struct {
+	mu       sync.RWMutex
+	sessions map[string]*peerSession
+
+	fullFeed    atomic.Pointer[fullFeedSnapshot]
+	lastRefresh atomic.Int64
+}
+
+func NewPeerTracker() *PeerTracker {
+	t := &PeerTracker{
+		sessions: make(map[string]*peerSession),
+	}
+	t.fullFeed.Store(&fullFeedSnapshot{
+		sessions:     make(map[string]struct{}),
+		perCollector: make(map[string]int),
+	})
+	return t
+}
+
+// Observe records that the session identified by host and peer carried prefix.
+func (t *PeerTracker) Observe(host, peer, prefix string, now time.Time) {
+	key := host + ":" + peer
+	t.mu.RLock()
+	s, ok := t.sessions[key]
+	t.mu.RUnlock()
+	if !ok {
+		t.mu.Lock()
+		if s, ok = t.sessions[key]; !ok {
+			s = &peerSession{host: host}
+			t.sessions[key] = s
+		}
+		t.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.lastSeen = now.Unix()
+	s.prefixes.add(hashPrefix(prefix))
+	s.mu.Unlock()
+
+	t.maybeRefresh(now)
+}
+
+func (t *PeerTracker) maybeRefresh(now time.Time) {
+	last := t.lastRefresh.Load()
+	if now.Unix()-last < int64(fullFeedRefreshInterval.Seconds()) {
+		return
+	}
+	if !t.lastRefresh.CompareAndSwap(last, now.Unix()) {
+		return
+	}
+	t.refresh(now)
+}
+
+func (t *PeerTracker) refresh(now time.Time) {
+	type candidate struct {
+		key, host string
+		count     float64
+	}
+
+	t.mu.Lock()
+	candidates := make([]candidate, 0, len(t.sessions))
+	maxCount := 0.0
+	for key, s := range t.sessions {
+		s.mu.Lock()
+		idle := now.Unix()-s.lastSeen > int64(sessionIdleTimeout.Seconds())
+		count := s.prefixes.estimate()
+		s.mu.Unlock()
+		if idle {
+			delete(t.sessions, key)
+			continue
+		}
+		candidates = append(candidates, candidate{key: key, host: s.host, count: count})
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	t.mu.Unlock()
+
+	snap := &fullFeedSnapshot{
+		sessions:     make(map[string]struct{}),
+		perCollector: make(map[string]int),
+	}
+	if maxCount >= minFullFeedPrefixes {
+		for _, c := range candidates {
+			if c.count >= maxCount*fullFeedFraction {
+				snap.sessions[c.key] = struct{}{}
+				snap.perCollector[c.host]++
+			}
+		}
+	}
+	t.fullFeed.Store(snap)
+}
+
+// PeerSession is the saved state of one session of a PeerTracker.
+type PeerSession struct {
+	Key      string `json:"key"`
+	Host     string `json:"host"`
+	LastSeen int64  `json:"last_seen"`
+	// Registers holds the HyperLogLog estimating the prefixes it carried.
+	Registers []byte `json:"registers"`
+}
+
+// Sessions returns the state of every session, to be restored with
+// RestoreSessions.
+func (t *PeerTracker) Sessions() []PeerSession {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	res := make([]PeerSession, 0, len(t.sessions))
+	for key, s := range t.sessions {
+		s.mu.Lock()
+		res = append(res, PeerSession{Key: key, Host: s.host, LastSeen: s.lastSeen, Registers: append([]byte(nil), s.prefixes[:]...)})
+		s.mu.Unlock()
+	}
+	return res
+}
+
+// RestoreSessions adds saved sessions to the tracker and recomputes the
+// full-feed population as of now.
+func (t *PeerTracker) RestoreSessions(sessions []PeerSession, now time.Time) {
+	t.mu.Lock()
+	for _, saved := range sessions {
+		s := &peerSession{host: saved.Host, lastSeen: saved.LastSeen}
+		copy(s.prefixes[:], saved.Registers)
+		t.sessions[saved.Key] = s
+	}
+	t.mu.Unlock()
+	t.lastRefresh.Store(now.Unix())
+	t.refresh(now)
+}
+
+// FullFeedPeers returns the number of full-table peers seen by each collector.
+func (t *PeerTracker) FullFeedPeers() map[string]int {
+	snap := t.fullFeed.Load()
+	res := make(map[string]int, len(snap.perCollector))
+	for host, n := range snap.perCollector {
+		res[host] = n
+	}
+	return res
+}
+
+// TotalFullFeedPeers returns the number of full-table peers across all collectors.
+func (t *PeerTracker) TotalFullFeedPeers() int {
+	return len(t.fullFeed.Load().sessions)
+}
+
+// Visibility returns the fraction of the full-feed peers that announced the
+// prefix which still carry it. Full-feed peers that never announced it are
+// left out, so prefixes only some peers see are not overstated.
+func (t *PeerTracker) Visibility(state *bgpproto.PrefixState) (float64, bool) {
+	snap := t.fullFeed.Load()
+	if len(snap.sessions) < minFullFeedPeers {
+		return 0, false
+	}
+	carriers, withdrawn := 0, 0
+	for key, attr := range state.PeerLastAttrs {
+		// A withdrawal from a session that never announced the prefix leaves no path
+		if attr.Path == "" {
+			continue
+		}
+		if _, ok := snap.sessions[key]; !ok {
+			continue
+		}
+		carriers++
+		if attr.Withdrawn {
+			withdrawn++
+		}
+	}
+	if carriers == 0 {
+		return 0, false
+	}
+	return 1 - float64(withdrawn)/float64(carriers), true
+}
+
+// updateVisibility refreshes the visibility of the prefix and its recent peak.
+func (c *Classifier) updateVisibility(state *bgpproto.PrefixState, now time.Time) (visibility float64, ok bool) {
+	if c.peers == nil {
+		return 0, false
+	}
+	visibility, ok = c.peers.Visibility(state)
+	if !ok {
+		return 0, false
+	}
+	state.Visibility = visibility
+	if visibility >= state.VisibilityPeak || now.Unix()-state.VisibilityPeakTs > int64(visibilityPeakWindow.Seconds()) {
+		state.VisibilityPeak = visibility
+		state.VisibilityPeakTs = now.Unix()
+	}
+	return visibility, true
+}
+
+// HasVisibility reports whether a visibility ratio has been computed for the prefix.
+func HasVisibility(state *bgpproto.PrefixState) bool {
+	return state.GetVisibilityPeakTs() != 0
+}
@@ -0,0 +1,183 @@
+package bgp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	for _, n := range []int{100, 5000, 200000} {
+		var h hyperLogLog
+		for i := 0; i < n; i++ {
+			h.add(hashPrefix(fmt.Sprintf("%d.%d.%d.0/24", i>>16&0xff, i>>8&0xff, i&0xff)))
+		}
+		est := h.estimate()
+		if diff := (est - float64(n)) / float64(n); diff > 0.1 || diff < -0.1 {
+			t.Errorf("estimate for %d distinct prefixes was %.0f", n, est)
+		}
+	}
+}
+
+// seedPeerTracker makes `full` sessions look like full-table peers and `partial`
+// sessions carry only a handful of prefixes.
+func seedPeerTracker(tr *PeerTracker, full, partial int, now time.Time) {
+	for i := 0; i < full; i++ {
+		for j := 0; j < 20000; j++ {
+			tr.Observe(fmt.Sprintf("rrc%02d", i%4), fmt.Sprintf("peer%d", i), fmt.Sprintf("10.%d.%d.0/24", j>>8, j&0xff), now)
+		}
+	}
+	for i := 0; i < partial; i++ {
+		for j := 0; j < 100; j++ {
+			tr.Observe("rrc00", fmt.Sprintf("partial%d", i), fmt.Sprintf("10.%d.%d.0/24", j>>8, j&0xff), now)
+		}
+	}
+	tr.refresh(now)
+}
+
+func TestPeerTracker_FullFeedPeers(t *testing.T) {
+	tr := NewPeerTracker()
+	now := time.Now()
+	seedPeerTracker(tr, 12, 5, now)
+
+	if got := tr.TotalFullFeedPeers(); got != 12 {
+		t.Errorf("expected 12 full-feed peers, got %d", got)
+	}
+	if got := tr.FullFeedPeers()["rrc00"]; got != 3 {
+		t.Errorf("expected 3 full-feed peers on rrc00, got %d", got)
+	}
+
+	// Sessions that go quiet drop out of the population
+	tr.Observe("rrc00", "peer0", "10.0.0.0/24", now.Add(2*time.Hour))
+	tr.refresh(now.Add(2 * time.Hour))
+	if got := tr.TotalFullFeedPeers(); got != 1 {
+		t.Errorf("expected only the active session to remain, got %d full-feed peers", got)
+	}
+}
+
+func TestPeerTracker_RestoreSessions(t *testing.T) {
+	tr := NewPeerTracker()
+	now := time.Now()
+	seedPeerTracker(tr, 12, 5, now)
+
+	restored := NewPeerTracker()
+	restored.RestoreSessions(tr.Sessions(), now)
+	if got := restored.TotalFullFeedPeers(); got != 12 {
+		t.Errorf("expected 12 full-feed peers after restoring, got %d", got)
+	}
+	if got := restored.FullFeedPeers()["rrc00"]; got != 3 {
+		t.Errorf("expected 3 full-feed peers on rrc00 after restoring, got %d", got)
+	}
+	if got, want := len(restored.Sessions()), len(tr.Sessions()); got != want {
+		t.Errorf("expected %d sessions after restoring, got %d", want, got)
+	}
+}
+
+func TestClassifier_PartialOutage(t *testing.T) {
+	c := newBaselineTestClassifier()
+	tr := NewPeerTracker()
+	start := time.Now().Truncate(time.Hour)
+	seedPeerTracker(tr, 20, 0, start)
+	c.SetPeerTracker(tr)
+
+	prefix := "44.44.44.0/24"
+	for i := 0; i < 20; i++ {
+		c.ClassifyEvent(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: fmt.Sprintf("rrc%02d", i%4), PathStr: "[100 200]", OriginASN: 200,
+			Now: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	// 15 of the 20 full-feed peers withdraw; the remaining 5 keep the prefix.
+	var last ClassificationType
+	var ld *LeakDetail
+	for i := 0; i < 15; i++ {
+		ev, ok := c.ClassifyEvent(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: fmt.Sprintf("rrc%02d", i%4), IsWithdrawal: true,
+			Now: start.Add(2*time.Minute + time.Duration(i)*time.Second),
+		})
+		if ok {
+			last, ld = ev.ClassificationType, ev.LeakDetail
+		}
+	}
+
+	if last != ClassificationOutage {
+		t.Fatalf("expected %s, got %s", ClassificationOutage, last)
+	}
+	if ld == nil || ld.PeakVisibility != 1 || ld.Visibility > 0.5 {
+		t.Errorf("expected outage detail to carry the visibility drop, got %+v", ld)
+	}
+
+	// An announcement from a peer that never withdrew does not end the outage
+	ev, ok := c.ClassifyEvent(prefix, &MessageContext{
+		Peer: "peer19", Host: "rrc03", PathStr: "[100 200]", OriginASN: 200, Now: start.Add(3 * time.Minute),
+	})
+	if !ok || ev.ClassificationType != ClassificationOutage {
+		t.Errorf("expected outage to persist while visibility is low, got %s", ev.ClassificationType)
+	}
+}
+
+func TestPeerTracker_VisibilityOnlyCountsCarriers(t *testing.T) {
+	tr := NewPeerTracker()
+	now := time.Now()
+	seedPeerTracker(tr, 20, 0, now)
+
+	// Only half of the full-feed peers ever carried the prefix, and 4 of them withdrew it
+	state := &bgpproto.PrefixState{PeerLastAttrs: make(map[string]*bgpproto.LastAttrs)}
+	for i := 0; i < 10; i++ {
+		state.PeerLastAttrs[fmt.Sprintf("rrc%02d:peer%d", i%4, i)] = &bgpproto.LastAttrs{Path: "[100 200]", Withdrawn: i < 4}
+	}
+	// A withdrawal from a peer that never announced it does not count either way
+	state.PeerLastAttrs["rrc00:peer12"] = &bgpproto.LastAttrs{Withdrawn: true}
+
+	visibility, ok := tr.Visibility(state)
+	if !ok {
+		t.Fatalf("expected visibility to be tracked")
+	}
+	if visibility != 0.6 {
+		t.Errorf("expected 6 of the 10 carrying peers, got visibility %f", visibility)
+	}
+
+	if _, ok := tr.Visibility(&bgpproto.PrefixState{}); ok {
+		t.Errorf("expected no visibility for a prefix no full-feed peer carried")
+	}
+}
+
+func TestClassifier_PartialOutageOfPartiallySeenPrefix(t *testing.T) {
+	c := newBaselineTestClassifier()
+	tr := NewPeerTracker()
+	start := time.Now().Truncate(time.Hour)
+	seedPeerTracker(tr, 20, 0, start)
+	c.SetPeerTracker(tr)
+
+	// Only half of the 20 full-feed peers carry the prefix
+	prefix := "45.45.45.0/24"
+	for i := 0; i < 10; i++ {
+		c.ClassifyEvent(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: fmt.Sprintf("rrc%02d", i%4), PathStr: "[100 200]", OriginASN: 200,
+			Now: start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	// 8 of the 10 withdraw: counted against all 20 full-feed peers this would
+	// still be 60% visibility, hiding the outage
+	var last ClassificationType
+	for i := 0; i < 8; i++ {
+		if ev, ok := c.ClassifyEvent(prefix, &MessageContext{
+			Peer: fmt.Sprintf("peer%d", i), Host: fmt.Sprintf("rrc%02d", i%4), IsWithdrawal: true,
+			Now: start.Add(2*time.Minute + time.Duration(i)*time.Second),
+		}); ok {
+			last = ev.ClassificationType
+		}
+	}
+
+	state, _ := c.GetPrefixState(prefix)
+	if state.Visibility > 0.21 {
+		t.Errorf("expected visibility of 2 in 10 carrying peers, got %f", state.Visibility)
+	}
+	if last != ClassificationOutage {
+		t.Errorf("expected %s, got %s", ClassificationOutage, last)
+	}
+}
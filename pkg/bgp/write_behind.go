@@ -0,0 +1,106 @@
+package bgp
+
+import (
+	"log"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// writeBehind tracks prefix states that changed since they were last written to
+// the stateDB. States evicted from the LRU before a flush are kept until the
+// next flush so that no update is lost.
+type writeBehind struct {
+	dirty   map[string]struct{}
+	evicted map[string]*bgpproto.PrefixState
+}
+
+// EnableWriteBehind defers stateDB writes until TakeDirtyStates is called,
+// instead of writing on every classification. The classifier must be the only
+// writer of its prefixes to the stateDB.
+func (c *Classifier) EnableWriteBehind() {
+	if c.stateDB == nil || c.writeBehind != nil {
+		return
+	}
+	wb := &writeBehind{
+		dirty:   make(map[string]struct{}),
+		evicted: make(map[string]*bgpproto.PrefixState),
+	}
+	c.writeBehind = wb
+	c.prefixStates.SetOnEvict(func(prefix string, state *bgpproto.PrefixState) {
+		if _, ok := wb.dirty[prefix]; ok {
+			delete(wb.dirty, prefix)
+			wb.evicted[prefix] = state
+		}
+	})
+}
+
+// DirtyStates returns the number of prefix states waiting to be written.
+func (c *Classifier) DirtyStates() int {
+	if c.writeBehind == nil {
+		return 0
+	}
+	return len(c.writeBehind.dirty) + len(c.writeBehind.evicted)
+}
+
+// TakeDirtyStates marshals every prefix state changed since the previous call,
+// keyed by prefix, and resets the dirty set.
+func (c *Classifier) TakeDirtyStates() map[string][]byte {
+	wb := c.writeBehind
+	if wb == nil || len(wb.dirty)+len(wb.evicted) == 0 {
+		return nil
+	}
+
+	batch := make(map[string][]byte, len(wb.dirty)+len(wb.evicted))
+	add := func(prefix string, state *bgpproto.PrefixState) {
+		data, err := proto.Marshal(state)
+		if err != nil {
+			log.Printf("Error marshaling prefix state for %s: %v", prefix, err)
+			return
+		}
+		batch[prefix] = data
+	}
+	for prefix, state := range wb.evicted {
+		add(prefix, state)
+	}
+	for prefix := range wb.dirty {
+		if state, ok := c.prefixStates.Peek(prefix); ok {
+			add(prefix, state)
+		}
+	}
+	clear(wb.evicted)
+	clear(wb.dirty)
+	return batch
+}
+
+func (c *Classifier) markDirty(prefix string) {
+	if c.writeBehind != nil {
+		c.writeBehind.dirty[prefix] = struct{}{}
+	}
+}
+
+// clonePrefixState returns a copy of the in-memory state of prefix, including a
+// state that was evicted but not written yet, or nil if there is none.
+func (c *Classifier) clonePrefixState(prefix string) *bgpproto.PrefixState {
+	state, ok := c.prefixStates.Peek(prefix)
+	if !ok && c.writeBehind != nil {
+		state, ok = c.writeBehind.evicted[prefix]
+	}
+	if !ok {
+		return nil
+	}
+	return proto.Clone(state).(*bgpproto.PrefixState)
+}
+
+// takeEvicted returns a state that was evicted but not yet flushed, so that it
+// is not reloaded from a stale copy in the stateDB.
+func (c *Classifier) takeEvicted(prefix string) (*bgpproto.PrefixState, bool) {
+	if c.writeBehind == nil {
+		return nil, false
+	}
+	state, ok := c.writeBehind.evicted[prefix]
+	if ok {
+		delete(c.writeBehind.evicted, prefix)
+	}
+	return state, ok
+}
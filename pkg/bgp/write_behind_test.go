@@ -0,0 +1,133 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/proto"
+)
+
+func testPrefixToIP(p string) uint32 {
+	pfx, err := netip.ParsePrefix(p)
+	if err != nil || !pfx.Addr().Is4() {
+		return 0
+	}
+	a := pfx.Addr().As4()
+	return binary.BigEndian.Uint32(a[:])
+}
+
+func loadTestState(t *testing.T, db *utils.DiskTrie, prefix string) *bgpproto.PrefixState {
+	t.Helper()
+	data, err := db.Get(prefix)
+	if err != nil || data == nil {
+		return nil
+	}
+	state := &bgpproto.PrefixState{}
+	if err := proto.Unmarshal(data, state); err != nil {
+		t.Fatalf("failed to unmarshal state for %s: %v", prefix, err)
+	}
+	return state
+}
+
+func TestClassifier_WriteBehindEviction(t *testing.T) {
+	stateDB, err := utils.OpenDiskTrie(filepath.Join(t.TempDir(), "test-state.db"))
+	if err != nil {
+		t.Fatalf("failed to open stateDB: %v", err)
+	}
+	defer func() { _ = stateDB.Close() }()
+
+	c := NewClassifier(nil, stateDB, nil, nil, testPrefixToIP, utils.NewLRUCache[string, *bgpproto.PrefixState](2), time.Now)
+	c.EnableWriteBehind()
+
+	start := time.Now().Truncate(time.Hour)
+	prefixes := []string{"8.8.8.0/24", "9.9.9.0/24", "1.1.1.0/24"}
+	for i, prefix := range prefixes {
+		c.ClassifyEvent(prefix, &MessageContext{Peer: "peer0", Host: "rrc00", PathStr: "[100 200]", OriginASN: 200, Now: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	if n := c.DirtyStates(); n != 3 {
+		t.Errorf("expected 3 dirty states including the evicted one, got %d", n)
+	}
+	if state := loadTestState(t, stateDB, prefixes[0]); state != nil {
+		t.Errorf("expected no write before the flush")
+	}
+
+	// Coming back to an evicted prefix must reuse the pending state
+	c.ClassifyEvent(prefixes[0], &MessageContext{Peer: "peer1", Host: "rrc00", PathStr: "[100 200]", OriginASN: 200, Now: start.Add(time.Minute)})
+	state, ok := c.GetPrefixState(prefixes[0])
+	if !ok || state.StartTimeTs != start.Unix() || len(state.PeerLastAttrs) != 2 {
+		t.Errorf("expected evicted state to be reused, got %+v", state)
+	}
+
+	batch := c.TakeDirtyStates()
+	if len(batch) != 3 {
+		t.Fatalf("expected 3 states in batch, got %d", len(batch))
+	}
+	if c.DirtyStates() != 0 {
+		t.Errorf("expected dirty set to be empty after taking it")
+	}
+	if err := stateDB.BatchInsertRaw(batch); err != nil {
+		t.Fatalf("failed to write batch: %v", err)
+	}
+	for _, prefix := range prefixes {
+		if loadTestState(t, stateDB, prefix) == nil {
+			t.Errorf("expected state for %s to be persisted", prefix)
+		}
+	}
+}
+
+func TestBGPProcessor_FlushOnClose(t *testing.T) {
+	stateDB, err := utils.OpenDiskTrie(filepath.Join(t.TempDir(), "test-state.db"))
+	if err != nil {
+		t.Fatalf("failed to open stateDB: %v", err)
+	}
+	defer func() { _ = stateDB.Close() }()
+
+	onEvent := func(lat, lng float64, cc, city string, eventType EventType, classificationType ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*LeakDetail) {
+	}
+	geo := func(ip uint32) (float64, float64, string, string, geoservice.ResolutionType) {
+		return 37.0, -122.0, "US", "San Francisco", geoservice.ResGeoIP
+	}
+	p := NewBGPProcessor(geo, nil, stateDB, nil, nil, testPrefixToIP, time.Now, onEvent)
+
+	prefixes := []string{"8.8.8.0/24", "9.9.9.0/24", "1.1.1.0/24", "4.4.4.0/24"}
+	p.dispatchMessage(&RISMessageData{
+		Announcements: []RISAnnouncement{{NextHop: "192.0.2.1", Prefixes: prefixes}},
+		Path:          []json.RawMessage{json.RawMessage("100"), json.RawMessage("200")},
+		Peer:          "peer0",
+		Host:          "rrc00",
+	})
+
+	// Wait for the workers to pick the message up; a worker finishes the
+	// message it is handling before it notices the processor is stopping.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pending := 0
+		for _, w := range p.workers {
+			pending += len(w.taskCh)
+		}
+		if pending == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.Close()
+
+	for _, prefix := range prefixes {
+		state := loadTestState(t, stateDB, prefix)
+		if state == nil {
+			t.Errorf("expected state for %s to be flushed on close", prefix)
+			continue
+		}
+		if attr, ok := state.PeerLastAttrs["rrc00:peer0"]; !ok || attr.OriginAsn != 200 {
+			t.Errorf("expected peer attributes for %s to be persisted, got %+v", prefix, state.PeerLastAttrs)
+		}
+	}
+}
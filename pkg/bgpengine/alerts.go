@@ -0,0 +1,64 @@
+package bgpengine
+
+import (
+	"log"
+
+	"github.com/sudorandom/bgp-stream/pkg/alerting"
+	"github.com/sudorandom/bgp-stream/pkg/siem"
+	"github.com/sudorandom/bgp-stream/pkg/watchlist"
+)
+
+// startWatchlist loads WatchlistPath and delivers its alerts until the engine
+// stops. It returns nil if the watchlist can't be loaded.
+func (e *Engine) startWatchlist() *watchlist.Monitor {
+	list, err := watchlist.Load(e.WatchlistPath)
+	if err != nil {
+		log.Printf("Warning: Failed to load watchlist: %v", err)
+		return nil
+	}
+	m := watchlist.NewMonitor(list, e.asnMapping, e.Now)
+	e.bgWg.Add(1)
+	go func() {
+		defer e.bgWg.Done()
+		m.Run(e.ctx)
+	}()
+	return m
+}
+
+// startAlerting loads the rules file at AlertRulesPath and routes alerts until
+// the engine stops, picking up changes to the file as it goes. It returns nil
+// if the rules can't be loaded.
+func (e *Engine) startAlerting() *alerting.Router {
+	r, err := alerting.NewRouter(e.AlertRulesPath, e.asnMapping, e.Now)
+	if err != nil {
+		log.Printf("Warning: Failed to load alert rules: %v", err)
+		return nil
+	}
+	e.bgWg.Add(1)
+	go func() {
+		defer e.bgWg.Done()
+		r.Run(e.ctx)
+	}()
+	return r
+}
+
+// startSIEM creates the sinks configured in SIEMPath and feeds them until the
+// engine stops. It returns nil if the configuration can't be loaded.
+func (e *Engine) startSIEM() *siem.Exporter {
+	c, err := siem.Load(e.SIEMPath)
+	if err != nil {
+		log.Printf("Warning: Failed to load SIEM sinks: %v", err)
+		return nil
+	}
+	x, err := siem.New(c, e.asnMapping)
+	if err != nil {
+		log.Printf("Warning: Failed to load SIEM sinks: %v", err)
+		return nil
+	}
+	e.bgWg.Add(1)
+	go func() {
+		defer e.bgWg.Done()
+		x.Run(e.ctx)
+	}()
+	return x
+}
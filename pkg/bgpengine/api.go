@@ -0,0 +1,129 @@
+package bgpengine
+
+import (
+	"log"
+	"sort"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/eventstream"
+	"github.com/sudorandom/bgp-stream/pkg/rislive"
+)
+
+// apiSource exposes the engine to the API server. It is kept separate from
+// Engine so the Source methods don't clash with the renderer's.
+type apiSource struct {
+	e *Engine
+}
+
+// startAPI serves the tracker fed by the stats worker on APIAddr until the
+// engine stops.
+func (e *Engine) startAPI() {
+	e.bgWg.Add(1)
+	go func() {
+		defer e.bgWg.Done()
+		if err := api.ListenAndServe(e.ctx, e.APIAddr, &apiSource{e: e}); err != nil {
+			log.Printf("Warning: API server stopped: %v", err)
+		}
+	}()
+}
+
+// startEventStream serves the EventService on StreamAddr until the engine
+// stops. The caller feeds it incidents from the API tracker.
+func (e *Engine) startEventStream() {
+	e.eventHub = eventstream.NewHub(e.Now)
+	e.bgWg.Add(1)
+	go func() {
+		defer e.bgWg.Done()
+		if err := eventstream.ListenAndServe(e.ctx, e.StreamAddr, e.eventHub); err != nil {
+			log.Printf("Warning: event stream server stopped: %v", err)
+		}
+	}()
+}
+
+// startWebSocket serves the websocket that re-broadcasts the processor's
+// enriched messages on WSAddr until the engine stops.
+func (e *Engine) startWebSocket() {
+	e.wsServer = rislive.NewServer(e.asnMapping)
+	e.bgWg.Add(1)
+	go func() {
+		defer e.bgWg.Done()
+		if err := rislive.ListenAndServe(e.ctx, e.WSAddr, e.wsServer); err != nil {
+			log.Printf("Warning: websocket server stopped: %v", err)
+		}
+	}()
+}
+
+func (s *apiSource) Snapshot() *api.Snapshot {
+	return s.e.apiTracker.Snapshot()
+}
+
+// CriticalEvents returns the events shown in the critical stream along with the
+// ones still queued for display.
+func (s *apiSource) CriticalEvents() []api.CriticalEvent {
+	e := s.e
+	e.streamMu.Lock()
+	events := make([]api.CriticalEvent, 0, len(e.CriticalStream)+len(e.criticalQueue))
+	for _, list := range [][]*CriticalEvent{e.CriticalStream, e.criticalQueue} {
+		for _, ce := range list {
+			t, _ := bgp.ParseClassificationName(ce.Anom)
+			prefixes := make([]string, 0, len(ce.ImpactedPrefixes))
+			for p := range ce.ImpactedPrefixes {
+				prefixes = append(prefixes, p)
+			}
+			sort.Strings(prefixes)
+			events = append(events, api.CriticalEvent{
+				Time:           ce.Timestamp.UTC(),
+				Classification: t.Key(),
+				Name:           ce.Anom,
+				ASN:            ce.ASN,
+				Network:        api.NetworkName(e.asnMapping, ce.ASN),
+				OrgID:          ce.OrgID,
+				Leak:           api.NewLeak(&bgp.LeakDetail{Type: ce.LeakType, LeakerASN: ce.LeakerASN, VictimASN: ce.VictimASN}),
+				Locations:      ce.Locations,
+				Visibility:     ce.Visibility,
+				PeakVisibility: ce.PeakVisibility,
+				ImpactedIPs:    ce.ImpactedIPs,
+				Prefixes:       prefixes,
+			})
+		}
+	}
+	e.streamMu.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.After(events[j].Time)
+	})
+	return events
+}
+
+func (s *apiSource) PrefixState(prefix string) (*bgpproto.PrefixState, bool) {
+	if s.e.processor == nil {
+		return nil, false
+	}
+	return s.e.processor.PrefixState(prefix)
+}
+
+func (s *apiSource) Metrics() api.Metrics {
+	e := s.e
+	m := api.NewMetrics(e.processor, e.geo, e.Now())
+	m.Dropped = map[string]uint64{
+		"pulses": e.droppedPulses.Load(),
+		"queue":  e.droppedQueue.Load(),
+		"stale":  e.droppedStale.Load(),
+		"frames": e.droppedFrames.Load(),
+	}
+	if e.eventHub != nil {
+		m.Dropped["event_stream"] = e.eventHub.Dropped()
+	}
+	if e.wsServer != nil {
+		m.Dropped["websocket"] = e.wsServer.Dropped()
+	}
+	if e.siemExporter != nil {
+		m.Dropped["siem"] = e.siemExporter.Dropped()
+	}
+	if e.journal != nil {
+		m.Dropped["journal"] = e.journal.Dropped()
+	}
+	return m
+}
@@ -28,9 +28,15 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 	geojson "github.com/paulmach/go.geojson"
+	"github.com/sudorandom/bgp-stream/pkg/api"
 	"github.com/sudorandom/bgp-stream/pkg/bgp"
 	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/eventstream"
 	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/journal"
+	"github.com/sudorandom/bgp-stream/pkg/rislive"
+	"github.com/sudorandom/bgp-stream/pkg/siem"
+	"github.com/sudorandom/bgp-stream/pkg/tsdb"
 	"github.com/sudorandom/bgp-stream/pkg/utils"
 	"google.golang.org/protobuf/proto"
 )
@@ -132,6 +138,11 @@ type CriticalEvent struct {
 	Color     color.RGBA
 	UIColor   color.RGBA
 
+	// Visibility is the fraction of full-feed peers still carrying an outage prefix,
+	// PeakVisibility what it was before the drop. Both are zero when unknown.
+	Visibility     float64
+	PeakVisibility float64
+
 	ImpactedIPs      uint64
 	ImpactedPrefixes map[string]struct{}
 
@@ -150,6 +161,8 @@ type CriticalEvent struct {
 	CachedNetVal      string
 	CachedLocLabel    string
 	CachedLocVal      string
+	CachedVisLabel    string
+	CachedVisVal      string
 
 	CachedImpactStr string
 }
@@ -254,19 +267,43 @@ type Engine struct {
 	streamDirty            bool
 	streamMu               sync.Mutex
 	impactDirty            bool
-	criticalCooldown       map[string]time.Time
+	criticalCooldown       bgp.IncidentCooldowns
 
 	SeenDB  *utils.DiskTrie
 	StateDB *utils.DiskTrie
 	RPKI    *utils.RPKIManager
 
-	audioPlayer *AudioPlayer
-	processor   *bgp.BGPProcessor
-	asnMapping  *utils.ASNMapping
-	geoResolver geoservice.GeoResolver
-	dataMgr     *geoservice.DataManager
-	MMDBFiles   []string
-	AudioDir    string
+	audioPlayer    *AudioPlayer
+	processor      *bgp.BGPProcessor
+	asnMapping     *utils.ASNMapping
+	geoResolver    geoservice.GeoResolver
+	dataMgr        *geoservice.DataManager
+	MMDBFiles      []string
+	AudioDir       string
+	FullBogons     bool
+	StateTTL       time.Duration
+	JournalDir     string
+	journal        *journal.Writer
+	MetricsDB      string
+	APIAddr        string
+	apiTracker     *api.Tracker
+	StreamAddr     string
+	eventHub       *eventstream.Hub
+	WSAddr         string
+	wsServer       *rislive.Server
+	WatchlistPath  string
+	AlertRulesPath string
+	SIEMPath       string
+	siemExporter   *siem.Exporter
+
+	metricsStore      *tsdb.Store
+	metricsStoreMu    sync.RWMutex
+	trendRangeIdx     atomic.Int32
+	loadedTrendRange  int
+	rangeLoadedAt     time.Time
+	rangeHistory      []MetricSnapshot
+	rangeHistoryLabel string
+	trendRangeKeyDown bool
 
 	HideUI                 bool
 	VideoPath              string
@@ -284,6 +321,7 @@ type Engine struct {
 	tourRegionStayDuration time.Duration
 
 	lastPerfLog time.Time
+	lastDropped [4]uint64
 
 	// Viewport and Tour state
 	currentZoom         float64
@@ -333,6 +371,17 @@ type bgpEvent struct {
 	leakDetail         *bgp.LeakDetail
 }
 
+// incidentEvent returns the event as the shared incident rules see it.
+func (ev *bgpEvent) incidentEvent() *bgp.IncidentEvent {
+	return &bgp.IncidentEvent{
+		Class:         ev.classificationType,
+		Prefix:        ev.prefix,
+		ASN:           ev.asn,
+		HistoricalASN: ev.historicalASN,
+		Leak:          ev.leakDetail,
+	}
+}
+
 type VisualHub struct {
 	CC          string
 	CountryStr  string
@@ -496,7 +545,7 @@ func NewEngine(width, height int, scale float64) *Engine {
 		tourRegionStayDuration: 10 * time.Second,
 		eventCh:                make(chan *bgpEvent, 250000),
 		statsCh:                make(chan *statsEvent, 250000),
-		criticalCooldown:       make(map[string]time.Time),
+		criticalCooldown:       make(bgp.IncidentCooldowns),
 		streamDirty:            true,
 	}
 	e.dataMgr = geoservice.NewDataManager(e.geo)
@@ -639,11 +688,106 @@ func (e *Engine) LoadRemainingData() error {
 	}
 
 	e.processor = bgp.NewBGPProcessor(e.GetIPCoords, e.SeenDB, e.StateDB, e.asnMapping, e.RPKI, e.prefixToIP, e.Now, e.recordEvent)
+	if e.FullBogons {
+		bogons := utils.NewFullBogonsWatcher(utils.FullBogonsPath, e.processor.SetFullBogons)
+		if err := bogons.Reload(); err != nil {
+			log.Printf("Warning: Failed to load full bogons (run bgp-cli fetch first): %v", err)
+		}
+		// A later bgp-cli fetch refreshes the list without a restart
+		e.bgWg.Add(1)
+		go func() {
+			defer e.bgWg.Done()
+			bogons.Run(e.ctx)
+		}()
+	}
+	var onTransition []bgp.TransitionCallback
+	if e.JournalDir != "" {
+		if w, err := journal.OpenWriter(e.JournalDir); err != nil {
+			log.Printf("Warning: Failed to open event journal: %v", err)
+		} else {
+			e.journal = w
+			onTransition = append(onTransition, func(t *bgp.Transition) { w.Append(journal.NewEntry(t)) })
+		}
+	}
+	if e.APIAddr != "" || e.StreamAddr != "" || e.AlertRulesPath != "" {
+		e.apiTracker = api.NewTracker(e.asnMapping, e.Now)
+	}
+	var onIncident []api.IncidentCallback
+	if e.StreamAddr != "" {
+		e.startEventStream()
+		onTransition = append(onTransition, e.eventHub.OnTransition)
+		onIncident = append(onIncident, e.eventHub.OnIncident)
+	}
+	if e.AlertRulesPath != "" {
+		if r := e.startAlerting(); r != nil {
+			onTransition = append(onTransition, r.OnTransition)
+			onIncident = append(onIncident, r.OnIncident)
+			e.apiTracker.SetResolvedCallback(r.OnResolved)
+		}
+	}
+	if e.SIEMPath != "" {
+		if x := e.startSIEM(); x != nil {
+			e.siemExporter = x
+			onTransition = append(onTransition, x.OnTransition)
+		}
+	}
+	if len(onIncident) > 0 {
+		e.apiTracker.SetIncidentCallback(func(ev api.CriticalEvent, opened bool) {
+			for _, fn := range onIncident {
+				fn(ev, opened)
+			}
+		})
+	}
+	var onMessage []bgp.MessageCallback
+	if e.WSAddr != "" {
+		e.startWebSocket()
+		onMessage = append(onMessage, e.wsServer.Publish)
+	}
+	if e.WatchlistPath != "" {
+		if m := e.startWatchlist(); m != nil {
+			onMessage = append(onMessage, m.OnMessage)
+			onTransition = append(onTransition, m.OnTransition)
+		}
+	}
+	if len(onTransition) > 0 {
+		e.processor.SetTransitionCallback(func(t *bgp.Transition) {
+			for _, fn := range onTransition {
+				fn(t)
+			}
+		})
+	}
+	if len(onMessage) > 0 {
+		e.processor.SetMessageCallback(func(m *bgp.EnrichedMessage) {
+			for _, fn := range onMessage {
+				fn(m)
+			}
+		})
+	}
+	if e.MetricsDB != "" {
+		if store, err := tsdb.Open(e.MetricsDB, MetricFields); err != nil {
+			log.Printf("Warning: Failed to open metric history: %v", err)
+		} else {
+			e.metricsStore = store
+		}
+	}
+
+	if e.APIAddr != "" {
+		e.startAPI()
+	}
 
 	// Preload anomalies from state DB to initialize the BGP EVENT SUMMARY
 	e.bgWg.Add(1)
 	go e.preloadActiveAnomalies()
 
+	if e.StateDB != nil || e.SeenDB != nil {
+		maintainer := bgp.NewStateMaintainer(e.StateDB, e.SeenDB, e.StateTTL, e.Now)
+		e.bgWg.Add(1)
+		go func() {
+			defer e.bgWg.Done()
+			maintainer.Run(e.ctx)
+		}()
+	}
+
 	log.Println("Engine startup complete. Listening for events...")
 
 	return nil
@@ -703,6 +847,9 @@ func (e *Engine) preloadActiveAnomalies() {
 					VictimASN: state.VictimAsn,
 				}
 			}
+			if bgp.ClassificationType(state.ClassifiedType) == bgp.ClassificationOutage && bgp.HasVisibility(state) {
+				ev.leakDetail = &bgp.LeakDetail{Visibility: state.Visibility, PeakVisibility: state.VisibilityPeak}
+			}
 
 			if e.isIgnoredDDoS(ev) {
 				return nil
@@ -1138,10 +1285,14 @@ func (e *Engine) UpdatePerformanceMetrics() {
 
 	tps := ebiten.ActualTPS()
 	fps := ebiten.ActualFPS()
-	droppedPulses := e.droppedPulses.Swap(0)
-	droppedQueue := e.droppedQueue.Swap(0)
-	droppedStale := e.droppedStale.Swap(0)
-	droppedFrames := e.droppedFrames.Swap(0)
+	// The counters are cumulative for the API, so log what changed since the
+	// previous report
+	dropped := [4]uint64{e.droppedPulses.Load(), e.droppedQueue.Load(), e.droppedStale.Load(), e.droppedFrames.Load()}
+	droppedPulses := dropped[0] - e.lastDropped[0]
+	droppedQueue := dropped[1] - e.lastDropped[1]
+	droppedStale := dropped[2] - e.lastDropped[2]
+	droppedFrames := dropped[3] - e.lastDropped[3]
+	e.lastDropped = dropped
 
 	if tps < 28 || fps < 28 || droppedPulses > 0 || droppedQueue > 0 || droppedStale > 0 || droppedFrames > 0 {
 		var sb strings.Builder
@@ -1239,6 +1390,15 @@ func (e *Engine) updateInput() {
 	} else {
 		e.tourSkipKeyPressed = false
 	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyR) {
+		if !e.trendRangeKeyDown {
+			e.cycleTrendRange()
+			e.trendRangeKeyDown = true
+		}
+	} else {
+		e.trendRangeKeyDown = false
+	}
 }
 
 func (e *Engine) handleTourSkip() {
@@ -1276,13 +1436,14 @@ func (e *Engine) updateMetrics() {
 
 	e.updateBeaconPercent()
 
-	// Cleanup Critical Event Stream (remove entries older than 10 mins)
+	// Cleanup Critical Event Stream (remove entries quiet for IncidentTTL)
 	now := e.Now()
 	e.streamMu.Lock()
+	e.criticalCooldown.Prune(now)
 	activeStream := e.CriticalStream[:0]
 	removedAny := false
 	for _, ce := range e.CriticalStream {
-		if now.Sub(ce.Timestamp) < 10*time.Minute {
+		if now.Sub(ce.Timestamp) < bgp.IncidentTTL {
 			activeStream = append(activeStream, ce)
 		} else {
 			removedAny = true
@@ -1465,6 +1626,10 @@ func (e *Engine) processEventBatch(batch []*bgpEvent) {
 }
 
 func (e *Engine) recordEvent(lat, lng float64, cc, city string, eventType bgp.EventType, classificationType bgp.ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*bgp.LeakDetail) {
+	if e.eventHub != nil {
+		// Subscribers get every event, even when the renderer is too busy
+		e.eventHub.OnEvent(lat, lng, cc, city, eventType, classificationType, prefix, asn, historicalASN, leakDetail...)
+	}
 	var ld *bgp.LeakDetail
 	if len(leakDetail) > 0 {
 		ld = leakDetail[0]
@@ -1491,7 +1656,7 @@ func (e *Engine) processEventLocked(ev *bgpEvent) {
 	e.incrementCityBuffer(ev.lat, ev.lng, c, shape)
 
 	// 4. Record to CriticalStream if it's a critical anomaly
-	if ev.classificationType == bgp.ClassificationOutage || ev.classificationType == bgp.ClassificationRouteLeak || ev.classificationType == bgp.ClassificationHijack {
+	if bgp.IsIncident(ev.classificationType) {
 		e.recordToCriticalStream(ev, c, name)
 	}
 
@@ -1533,50 +1698,14 @@ func (e *Engine) getBestLocation(prefix, cc, city string) string {
 }
 
 func (e *Engine) isIgnoredDDoS(ev *bgpEvent) bool {
-	if ev.classificationType != bgp.ClassificationDDoSMitigation {
-		return false
-	}
-
-	providerASN := ev.asn
-	if providerASN == 0 && ev.leakDetail != nil {
-		providerASN = ev.leakDetail.LeakerASN
-	}
-
-	if providerASN == 0 {
-		return true
-	}
-
-	victimASN := uint32(0)
-	if ev.leakDetail != nil {
-		victimASN = ev.leakDetail.VictimASN
-	}
-	if victimASN == 0 {
-		victimASN = ev.historicalASN
-	}
-
-	// We used to filter out self-mitigation (victim == provider), but
-	// for the Major Event Stream, even self-mitigation via RTBH is a
-	// significant event for the prefix.
-	if victimASN == 0 {
-		return true
-	}
-
-	return false
+	return ev.incidentEvent().IgnoredDDoS()
 }
 
 func (e *Engine) recordToCriticalStream(ev *bgpEvent, c color.RGBA, name string) {
-	// Filter out ASN 0 for outages as requested
-	if ev.classificationType == bgp.ClassificationOutage && ev.asn == 0 {
-		return
-	}
-
-	// Filter out excluded ASN categories
-	if utils.IsExcludedASN(ev.asn) {
-		return
-	}
-
-	// Filter out invalid DDoS Mitigation events
-	if e.isIgnoredDDoS(ev) {
+	// Filter out outages without an origin, excluded ASN categories and
+	// invalid DDoS Mitigation events
+	inc := ev.incidentEvent()
+	if inc.Excluded() {
 		return
 	}
 
@@ -1608,13 +1737,13 @@ func (e *Engine) recordToCriticalStream(ev *bgpEvent, c color.RGBA, name string)
 	}
 
 	// If the event is not critical itself, we are done
-	if ev.classificationType != bgp.ClassificationOutage && ev.classificationType != bgp.ClassificationRouteLeak && ev.classificationType != bgp.ClassificationHijack {
+	if !bgp.IsIncident(ev.classificationType) {
 		return
 	}
 
 	// Check for duplicates across the entire visible stream
 	for _, ce := range e.CriticalStream {
-		if e.isSameEvent(ce, ev, name) {
+		if isSameEvent(ce, inc, name) {
 			ce.Timestamp = now // Reset expiration timer
 			// If we found an existing event, update it in place
 			if e.updateExistingCriticalEvent(ce, ev) {
@@ -1627,7 +1756,7 @@ func (e *Engine) recordToCriticalStream(ev *bgpEvent, c color.RGBA, name string)
 
 	// Also check for duplicates in the pending queue
 	for _, ce := range e.criticalQueue {
-		if e.isSameEvent(ce, ev, name) {
+		if isSameEvent(ce, inc, name) {
 			ce.Timestamp = now // Reset expiration timer
 			// If we found an existing event, update it in place
 			if e.updateExistingCriticalEvent(ce, ev) {
@@ -1637,19 +1766,16 @@ func (e *Engine) recordToCriticalStream(ev *bgpEvent, c color.RGBA, name string)
 		}
 	}
 
-	// Filter out outages with low impact (< 1000 IPs)
-	// We only add NEW outages to the stream if they meet the threshold.
-	if ev.classificationType == bgp.ClassificationOutage && utils.GetPrefixSize(ev.prefix) < 1000 {
+	// Filter out outages with low impact. We only add NEW outages to the
+	// stream if they meet the threshold.
+	if !inc.OpensIncident() {
 		return
 	}
 
 	// Only add a new event if it's not on a per-prefix cooldown
-	// Substantial cooldown: 10 minutes
-	if lastTime, ok := e.criticalCooldown[ev.prefix]; ok && now.Sub(lastTime) < 10*time.Minute {
+	if !e.criticalCooldown.Open(ev.prefix, now) {
 		return
 	}
-
-	e.criticalCooldown[ev.prefix] = now
 	ce := e.createCriticalEvent(ev, c, name, asnStr, orgID, newLoc, now)
 	e.criticalQueue = append(e.criticalQueue, ce)
 }
@@ -1691,50 +1817,16 @@ func (e *Engine) removePrefixFromEvent(ce *CriticalEvent, prefix string) {
 	e.updateCriticalEventCacheStrs(ce)
 }
 
-func (e *Engine) isSameEvent(ce *CriticalEvent, ev *bgpEvent, name string) bool {
+func isSameEvent(ce *CriticalEvent, ev *bgp.IncidentEvent, name string) bool {
 	if ce.Anom != name {
 		return false
 	}
-
-	// 1. Exact Prefix Match: If this prefix is already part of this event, it's definitely the same event.
-	if ev.prefix != "" && ce.ImpactedPrefixes != nil {
-		if _, ok := ce.ImpactedPrefixes[ev.prefix]; ok {
-			return true
-		}
-	}
-
-	// For Route Leaks match based on leak details
-	if name == bgp.NameRouteLeak && ev.leakDetail != nil {
-		return (ce.LeakType == bgp.LeakUnknown || ce.LeakType == ev.leakDetail.Type) &&
-			ce.LeakerASN == ev.leakDetail.LeakerASN && ce.VictimASN == ev.leakDetail.VictimASN
-	}
-
-	// For DDoS Mitigation, always match by provider/victim pair
-	if name == bgp.NameDDoSMitigation {
-		leaker := ev.asn
-		victim := ev.historicalASN
-		if ev.leakDetail != nil {
-			leaker = ev.leakDetail.LeakerASN
-			victim = ev.leakDetail.VictimASN
-		}
-		// If both are known/0, we can't safely deduplicate across victims
-		if leaker == 0 && victim == 0 {
-			return false
-		}
-		return ce.LeakerASN == leaker && ce.VictimASN == victim
-	}
-
-	// 4. Origin ASN Match: For other anomalies (like Outages), match by Origin ASN
-	asn := ev.asn
-	if asn == 0 {
-		asn = ev.historicalASN
-	}
-
-	if asn != 0 && ce.ASN != 0 && asn == ce.ASN {
-		return true
+	open := bgp.OpenIncident{
+		ASN:      ce.ASN,
+		Leak:     &bgp.LeakDetail{Type: ce.LeakType, LeakerASN: ce.LeakerASN, VictimASN: ce.VictimASN},
+		Prefixes: ce.ImpactedPrefixes,
 	}
-
-	return false
+	return open.Matches(ev)
 }
 
 func (e *Engine) updateExistingCriticalEvent(ce *CriticalEvent, ev *bgpEvent) bool {
@@ -1751,7 +1843,7 @@ func (e *Engine) updateExistingCriticalEvent(ce *CriticalEvent, ev *bgpEvent) bo
 	}
 
 	// Update IP Impact
-	if ev.classificationType == bgp.ClassificationOutage || ev.classificationType == bgp.ClassificationRouteLeak || ev.classificationType == bgp.ClassificationHijack {
+	if bgp.IsIncident(ev.classificationType) {
 		if ce.ImpactedPrefixes == nil {
 			ce.ImpactedPrefixes = make(map[string]struct{})
 		}
@@ -1788,6 +1880,14 @@ func (e *Engine) updateExistingCriticalEvent(ce *CriticalEvent, ev *bgpEvent) bo
 		ce.VictimASN = ev.leakDetail.VictimASN
 		needsUpdate = true
 	}
+
+	// Keep the visibility of outages current as more peers withdraw or recover
+	if ev.leakDetail != nil && ev.leakDetail.PeakVisibility > 0 &&
+		(ce.Visibility != ev.leakDetail.Visibility || ce.PeakVisibility != ev.leakDetail.PeakVisibility) {
+		ce.Visibility = ev.leakDetail.Visibility
+		ce.PeakVisibility = ev.leakDetail.PeakVisibility
+		needsUpdate = true
+	}
 	return needsUpdate
 }
 
@@ -1807,7 +1907,7 @@ func (e *Engine) createCriticalEvent(ev *bgpEvent, c color.RGBA, name, asnStr, o
 		Locations:        newLoc,
 		ImpactedPrefixes: make(map[string]struct{}),
 	}
-	if ev.classificationType == bgp.ClassificationOutage || ev.classificationType == bgp.ClassificationRouteLeak || ev.classificationType == bgp.ClassificationHijack {
+	if bgp.IsIncident(ev.classificationType) {
 		ce.ImpactedPrefixes[ev.prefix] = struct{}{}
 		ce.ImpactedIPs = utils.GetPrefixSize(ev.prefix)
 	}
@@ -1815,6 +1915,8 @@ func (e *Engine) createCriticalEvent(ev *bgpEvent, c color.RGBA, name, asnStr, o
 		ce.LeakType = ev.leakDetail.Type
 		ce.LeakerASN = ev.leakDetail.LeakerASN
 		ce.VictimASN = ev.leakDetail.VictimASN
+		ce.Visibility = ev.leakDetail.Visibility
+		ce.PeakVisibility = ev.leakDetail.PeakVisibility
 	}
 	if ce.Anom == bgp.NameDDoSMitigation {
 		if ce.LeakerASN == 0 {
@@ -1989,6 +2091,13 @@ func (e *Engine) cacheOutageStrings(ce *CriticalEvent) {
 		displayLocs := locs[:2]
 		ce.CachedLocVal = fmt.Sprintf("%s | %s (%d more)", displayLocs[0], displayLocs[1], len(locs)-2)
 	}
+
+	// Visibility line
+	ce.CachedVisLabel = "  Visibility: "
+	ce.CachedVisVal = ""
+	if ce.PeakVisibility > 0 {
+		ce.CachedVisVal = fmt.Sprintf("%.0f%% of peers (down from %.0f%%)", ce.Visibility*100, ce.PeakVisibility*100)
+	}
 }
 
 func (e *Engine) cacheImpactStrings(ce *CriticalEvent) {
@@ -2129,16 +2238,8 @@ func (e *Engine) getClassificationVisuals(classificationType bgp.ClassificationT
 }
 
 func (e *Engine) GetPriority(name string) int {
-	switch name {
-	case bgp.NameRouteLeak, bgp.NameHardOutage, bgp.NameHijack:
-		return 3 // Critical (Red)
-	case bgp.NameFlap:
-		return 2 // Bad (Orange)
-	case bgp.NameTrafficEng, bgp.NamePathHunting, bgp.NameDDoSMitigation:
-		return 1 // Normalish (Purple)
-	default:
-		return 0 // Discovery (Blue)
-	}
+	t, _ := bgp.ParseClassificationName(name)
+	return t.Priority()
 }
 
 func (e *Engine) getClassificationUIColor(name string) color.RGBA {
@@ -2540,6 +2641,10 @@ func (e *Engine) Stop() {
 	if e.processor != nil {
 		e.processor.Close()
 	}
+	if e.journal != nil {
+		_ = e.journal.Close()
+	}
+	e.closeMetricsStore()
 	if e.SeenDB != nil {
 		_ = e.SeenDB.Close()
 	}
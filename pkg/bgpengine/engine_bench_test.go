@@ -1,11 +1,16 @@
 package bgpengine
 
 import (
+	"encoding/binary"
+	"fmt"
 	"math/rand"
+	"net"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
 )
 
 // BenchmarkDrawBGPStatus measures the allocations and performance of the BGP status rendering.
@@ -163,3 +168,49 @@ func BenchmarkDrawTrendGrid(b *testing.B) {
 		e.drawTrendGrid(screen, gx, gy, chartW, chartH, titlePadding, globalMinLog, globalMaxLog, fontSize)
 	}
 }
+
+// BenchmarkHintLookup compares geo hint lookups served by probing Badger once
+// per mask length against the in-memory LPM index. Addresses are spread widely
+// so that the probing path gets few lookup cache hits, as with live traffic.
+func BenchmarkHintLookup(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	entries := make(map[string][]byte)
+	for len(entries) < 50000 {
+		bits := 12 + r.Intn(13)
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, r.Uint32()&(^uint32(0)<<(32-bits)))
+		entries[fmt.Sprintf("%s/%d", ip, bits)] = []byte(fmt.Sprintf(`{"cc":"C%d","city":"City %d"}`, len(entries)%200, len(entries)%5000))
+	}
+	ips := make([]uint32, 1<<20)
+	for i := range ips {
+		ips[i] = r.Uint32()
+	}
+
+	for _, indexed := range []bool{false, true} {
+		name := "badger"
+		if indexed {
+			name = "index"
+		}
+		b.Run(name, func(b *testing.B) {
+			trie, err := utils.OpenDiskTrie(filepath.Join(b.TempDir(), "hints.db"))
+			if err != nil {
+				b.Fatalf("Failed to open trie: %v", err)
+			}
+			defer func() { _ = trie.Close() }()
+			if err := trie.BatchInsert(entries); err != nil {
+				b.Fatalf("Failed to insert hints: %v", err)
+			}
+			if indexed {
+				if err := trie.EnableIndex(""); err != nil {
+					b.Fatalf("Failed to build index: %v", err)
+				}
+			}
+
+			b.ResetTimer()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _, _ = trie.LookupUint32(ips[i&(len(ips)-1)])
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package bgpengine
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/tsdb"
+)
+
+// MetricFields is the layout of a MetricSnapshot in the metric history store.
+var MetricFields = []tsdb.Field{
+	{Name: "new", Kind: tsdb.Counter},
+	{Name: "upd", Kind: tsdb.Counter},
+	{Name: "with", Kind: tsdb.Counter},
+	{Name: "gossip", Kind: tsdb.Counter},
+	{Name: "note", Kind: tsdb.Counter},
+	{Name: "peer", Kind: tsdb.Counter},
+	{Name: "open", Kind: tsdb.Counter},
+	{Name: "beacon", Kind: tsdb.Counter},
+	{Name: "honeypot", Kind: tsdb.Counter},
+	{Name: "research", Kind: tsdb.Counter},
+	{Name: "security", Kind: tsdb.Counter},
+	{Name: "flap", Kind: tsdb.Counter},
+	{Name: "te", Kind: tsdb.Counter},
+	{Name: "oscill", Kind: tsdb.Counter},
+	{Name: "hunting", Kind: tsdb.Counter},
+	{Name: "next_hop", Kind: tsdb.Counter},
+	{Name: "outage", Kind: tsdb.Counter},
+	{Name: "leak", Kind: tsdb.Counter},
+	{Name: "hijack", Kind: tsdb.Counter},
+	{Name: "bogon", Kind: tsdb.Counter},
+	{Name: "attr", Kind: tsdb.Counter},
+	{Name: "global", Kind: tsdb.Counter},
+	{Name: "ddos", Kind: tsdb.Counter},
+	{Name: "dedupe", Kind: tsdb.Counter},
+	{Name: "uncat", Kind: tsdb.Counter},
+	{Name: "good_ips", Kind: tsdb.Gauge},
+	{Name: "poly_ips", Kind: tsdb.Gauge},
+	{Name: "bad_ips", Kind: tsdb.Gauge},
+	{Name: "crit_ips", Kind: tsdb.Gauge},
+}
+
+func (s *MetricSnapshot) values() []float64 {
+	return []float64{
+		float64(s.New), float64(s.Upd), float64(s.With), float64(s.Gossip), float64(s.Note), float64(s.Peer), float64(s.Open),
+		float64(s.Beacon), float64(s.Honeypot), float64(s.Research), float64(s.Security),
+		float64(s.Flap), float64(s.TE), float64(s.Oscill), float64(s.Hunting), float64(s.NextHop),
+		float64(s.Outage), float64(s.Leak), float64(s.Hijack), float64(s.Bogon), float64(s.Attr),
+		float64(s.Global), float64(s.DDoS), float64(s.Dedupe), float64(s.Uncat),
+		float64(s.GoodIPs), float64(s.PolyIPs), float64(s.BadIPs), float64(s.CritIPs),
+	}
+}
+
+// metricSnapshotFromValues is the inverse of values. Counters are expected as
+// per-second rates so that they line up with the live one-second snapshots.
+func metricSnapshotFromValues(v []float64) MetricSnapshot {
+	i := func(idx int) int { return int(math.Round(v[idx])) }
+	u := func(idx int) uint64 { return uint64(math.Round(v[idx])) }
+	return MetricSnapshot{
+		New: i(0), Upd: i(1), With: i(2), Gossip: i(3), Note: i(4), Peer: i(5), Open: i(6),
+		Beacon: i(7), Honeypot: i(8), Research: i(9), Security: i(10),
+		Flap: i(11), TE: i(12), Oscill: i(13), Hunting: i(14), NextHop: i(15),
+		Outage: i(16), Leak: i(17), Hijack: i(18), Bogon: i(19), Attr: i(20),
+		Global: i(21), DDoS: i(22), Dedupe: i(23), Uncat: i(24),
+		GoodIPs: u(25), PolyIPs: u(26), BadIPs: u(27), CritIPs: u(28),
+	}
+}
+
+type trendRange struct {
+	Label      string
+	Span       time.Duration
+	Resolution string
+}
+
+// trendRanges are the time ranges the trendline panels cycle through. The
+// first one is the live in-memory history.
+var trendRanges = []trendRange{
+	{Label: "LIVE"},
+	{Label: "PAST HOUR", Span: time.Hour, Resolution: "2s"},
+	{Label: "PAST WEEK", Span: 7 * 24 * time.Hour, Resolution: "1m"},
+	{Label: "PAST YEAR", Span: 365 * 24 * time.Hour, Resolution: "1h"},
+}
+
+const (
+	// trendRangePoints is how many points a stored range is downsampled to
+	trendRangePoints = 240
+	// trendRangeRefresh is how often a stored range is reloaded
+	trendRangeRefresh = 10 * time.Second
+)
+
+func (e *Engine) cycleTrendRange() {
+	e.trendRangeIdx.Store(int32((int(e.trendRangeIdx.Load()) + 1) % len(trendRanges)))
+}
+
+// trendHistory returns the snapshots the trendline panels should draw. Must be
+// called with metricsMu held.
+func (e *Engine) trendHistory() []MetricSnapshot {
+	if e.rangeHistory != nil {
+		return e.rangeHistory
+	}
+	return e.history
+}
+
+// trendRangeLabel returns the suffix for the trendline panel titles. Must be
+// called with metricsMu held.
+func (e *Engine) trendRangeLabel() string {
+	if e.rangeHistory == nil {
+		return ""
+	}
+	return " - " + e.rangeHistoryLabel
+}
+
+// recordMetricHistory persists the latest snapshot. Must be called with
+// metricsMu held.
+func (e *Engine) recordMetricHistory(now time.Time, interval float64) {
+	e.metricsStoreMu.RLock()
+	defer e.metricsStoreMu.RUnlock()
+	if e.metricsStore == nil || len(e.history) == 0 {
+		return
+	}
+	snap := &e.history[len(e.history)-1]
+	if err := e.metricsStore.Add(now, interval, snap.values()); err != nil {
+		log.Printf("Warning: Failed to record metric history: %v", err)
+	}
+}
+
+// refreshTrendRange reloads the selected stored range when it changes or has
+// gone stale. It reads the store without holding metricsMu.
+func (e *Engine) refreshTrendRange(now time.Time) {
+	e.metricsStoreMu.RLock()
+	defer e.metricsStoreMu.RUnlock()
+
+	idx := int(e.trendRangeIdx.Load())
+	if idx == 0 || e.metricsStore == nil {
+		if idx != e.loadedTrendRange {
+			e.metricsMu.Lock()
+			e.rangeHistory = nil
+			e.lastTrendUpdate = time.Time{}
+			e.metricsMu.Unlock()
+			e.loadedTrendRange = 0
+		}
+		return
+	}
+	if idx == e.loadedTrendRange && now.Sub(e.rangeLoadedAt) < trendRangeRefresh {
+		return
+	}
+
+	r := trendRanges[idx]
+	history, err := loadTrendRange(e.metricsStore, r, now)
+	if err != nil {
+		log.Printf("Warning: Failed to load metric history: %v", err)
+		return
+	}
+	e.loadedTrendRange = idx
+	e.rangeLoadedAt = now
+
+	e.metricsMu.Lock()
+	e.rangeHistory = history
+	e.rangeHistoryLabel = r.Label
+	e.lastTrendUpdate = time.Time{}
+	e.metricsMu.Unlock()
+}
+
+func (e *Engine) closeMetricsStore() {
+	e.metricsStoreMu.Lock()
+	defer e.metricsStoreMu.Unlock()
+	if e.metricsStore == nil {
+		return
+	}
+	if err := e.metricsStore.Close(); err != nil {
+		log.Printf("Warning: Failed to close metric history: %v", err)
+	}
+	e.metricsStore = nil
+}
+
+// loadTrendRange reads r from the store and downsamples it to evenly spaced
+// points ending at now. Counters become per-second rates, gauges are averaged,
+// and points without any recorded data are zero.
+func loadTrendRange(store *tsdb.Store, r trendRange, now time.Time) ([]MetricSnapshot, error) {
+	res, _ := tsdb.ParseResolution(r.Resolution)
+	from := now.Add(-r.Span)
+	samples, err := store.Range(res, from, now.Add(res.Step))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := store.Fields()
+	sums := make([][]float64, trendRangePoints)
+	seconds := make([]float64, trendRangePoints)
+	slot := r.Span / trendRangePoints
+	for i := range samples {
+		s := &samples[i]
+		idx := int(s.Time.Sub(from) / slot)
+		if idx == trendRangePoints {
+			idx--
+		}
+		if idx < 0 || idx >= trendRangePoints || s.Seconds <= 0 || len(s.Values) != len(fields) {
+			continue
+		}
+		if sums[idx] == nil {
+			sums[idx] = make([]float64, len(fields))
+		}
+		for j, f := range fields {
+			if f.Kind == tsdb.Gauge {
+				sums[idx][j] += s.Values[j] * s.Seconds
+			} else {
+				sums[idx][j] += s.Values[j]
+			}
+		}
+		seconds[idx] += s.Seconds
+	}
+
+	history := make([]MetricSnapshot, trendRangePoints)
+	for i, sum := range sums {
+		if sum == nil {
+			continue
+		}
+		for j := range sum {
+			sum[j] /= seconds[i]
+		}
+		history[i] = metricSnapshotFromValues(sum)
+	}
+	return history, nil
+}
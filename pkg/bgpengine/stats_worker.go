@@ -469,6 +469,9 @@ func (e *Engine) buildActiveASNImpactsWorker(state *statsWorkerState) []*ASNImpa
 
 func (e *Engine) processStatsEvent(msg *statsEvent, state *statsWorkerState) {
 	ev := msg.ev
+	if e.apiTracker != nil {
+		e.apiTracker.OnEvent(ev.lat, ev.lng, ev.cc, ev.city, ev.eventType, ev.classificationType, ev.prefix, ev.asn, ev.historicalASN, ev.leakDetail)
+	}
 	if ev.prefix != "" {
 		if ev.asn != 0 {
 			state.prefixToASN[ev.prefix] = ev.asn
@@ -388,6 +388,11 @@ func (e *Engine) drawCriticalEvent(ce *CriticalEvent, x, y, boxW, fontSize float
 		if ce.CachedLocVal != "" {
 			nextY = e.drawLabeledLine(e.streamClipBuffer, ce.CachedLocLabel, ce.CachedLocVal, e.subMonoFace, x+indent, nextY, boxW-indent-5, fontSize, labelCol, valueCol)
 		}
+
+		// Visibility line
+		if ce.CachedVisVal != "" {
+			nextY = e.drawLabeledLine(e.streamClipBuffer, ce.CachedVisLabel, ce.CachedVisVal, e.subMonoFace, x+indent, nextY, boxW-indent-5, fontSize, labelCol, valueCol)
+		}
 	case bgp.NameDDoSMitigation, bgp.NameHijack:
 		// Provider/Hijacker - Skip if DDoS and Provider == Victim
 		if ce.Anom != bgp.NameDDoSMitigation || ce.LeakerASN != ce.VictimASN {
@@ -529,7 +534,7 @@ func (e *Engine) drawIPTrendlines(screen *ebiten.Image, gx, gy, graphW, trendBox
 	vector.FillRect(screen, float32(gx-10), float32(gy-fontSize-15), float32(trendBoxW+20), float32(boxH), color.RGBA{0, 0, 0, 100}, false)
 	vector.StrokeRect(screen, float32(gx-10), float32(gy-fontSize-15), float32(trendBoxW+20), float32(boxH), 1, color.RGBA{36, 42, 53, 255}, false)
 
-	trendTitle := "IP ADDRESSES IN EACH STATE"
+	trendTitle := "IP ADDRESSES IN EACH STATE" + e.trendRangeLabel()
 	titleFace := &text.GoTextFace{Source: e.fontSource, Size: fontSize * 0.8}
 
 	// Draw subtle hacker-green accent
@@ -540,7 +545,8 @@ func (e *Engine) drawIPTrendlines(screen *ebiten.Image, gx, gy, graphW, trendBox
 	trendOp.ColorScale.Scale(1, 1, 1, 0.5)
 	text.Draw(screen, trendTitle, titleFace, trendOp)
 
-	if len(e.history) < 2 {
+	history := e.trendHistory()
+	if len(history) < 2 {
 		return
 	}
 
@@ -558,7 +564,7 @@ func (e *Engine) drawIPTrendlines(screen *ebiten.Image, gx, gy, graphW, trendBox
 	e.drawTrendGrid(screen, gx, gy, chartW, chartH, titlePadding, globalMinLog, globalMaxLog, fontSize)
 
 	// Use persistent buffer for trendlines to avoid per-frame allocations
-	hLen := len(e.history)
+	hLen := len(history)
 	numSteps := float64(hLen - 2)
 	if numSteps <= 0 {
 		numSteps = 1
@@ -580,7 +586,11 @@ func (e *Engine) drawIPTrendlines(screen *ebiten.Image, gx, gy, graphW, trendBox
 	}
 	e.ipTrendClipBuffer.Clear()
 
-	smoothOffset := math.Mod(time.Since(e.lastMetricsUpdate).Seconds(), 1.0)
+	// Stored ranges are redrawn in place rather than scrolled
+	smoothOffset := 0.0
+	if e.rangeHistory == nil {
+		smoothOffset = math.Mod(time.Since(e.lastMetricsUpdate).Seconds(), 1.0)
+	}
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(-smoothOffset*step, 0)
 	op.ColorScale.Scale(1, 1, 1, 1.0)
@@ -598,7 +608,7 @@ func (e *Engine) drawEventTrendlines(screen *ebiten.Image, gx, gy, graphW, trend
 	vector.FillRect(screen, float32(gx-10), float32(gy-fontSize-15), float32(trendBoxW+20), float32(boxH), color.RGBA{0, 0, 0, 100}, false)
 	vector.StrokeRect(screen, float32(gx-10), float32(gy-fontSize-15), float32(trendBoxW+20), float32(boxH), 1, color.RGBA{36, 42, 53, 255}, false)
 
-	trendTitle := "EVENTS OVER TIME"
+	trendTitle := "EVENTS OVER TIME" + e.trendRangeLabel()
 	titleFace := &text.GoTextFace{Source: e.fontSource, Size: fontSize * 0.8}
 
 	// Draw subtle hacker-green accent
@@ -609,7 +619,8 @@ func (e *Engine) drawEventTrendlines(screen *ebiten.Image, gx, gy, graphW, trend
 	trendOp.ColorScale.Scale(1, 1, 1, 0.5)
 	text.Draw(screen, trendTitle, titleFace, trendOp)
 
-	if len(e.history) < 2 {
+	history := e.trendHistory()
+	if len(history) < 2 {
 		return
 	}
 
@@ -628,7 +639,7 @@ func (e *Engine) drawEventTrendlines(screen *ebiten.Image, gx, gy, graphW, trend
 
 	// Use persistent buffer for trendlines to avoid per-frame allocations
 	// We make it slightly wider to accommodate the smooth sliding
-	hLen := len(e.history)
+	hLen := len(history)
 	numSteps := float64(hLen - 2)
 	if numSteps <= 0 {
 		numSteps = 1
@@ -653,7 +664,11 @@ func (e *Engine) drawEventTrendlines(screen *ebiten.Image, gx, gy, graphW, trend
 	}
 	e.trendClipBuffer.Clear()
 
-	smoothOffset := math.Mod(time.Since(e.lastMetricsUpdate).Seconds(), 1.0)
+	// Stored ranges are redrawn in place rather than scrolled
+	smoothOffset := 0.0
+	if e.rangeHistory == nil {
+		smoothOffset = math.Mod(time.Since(e.lastMetricsUpdate).Seconds(), 1.0)
+	}
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(-smoothOffset*step, 0)
 	op.ColorScale.Scale(1, 1, 1, 1.0)
@@ -687,14 +702,15 @@ func (e *Engine) logVal(v float64) float64 {
 }
 
 func (e *Engine) calculateGlobalLogBounds() (minLog, maxLog float64) {
+	history := e.trendHistory()
 	globalMaxLog := 1.0
 	globalMinLog := 100.0 // higher than any possible log10 for these metrics
-	if len(e.history) < 2 {
+	if len(history) < 2 {
 		return 0, 1.0
 	}
 	hasData := false
-	for i := 1; i < len(e.history); i++ {
-		good, poly, bad, crit := e.aggregateMetrics(&e.history[i])
+	for i := 1; i < len(history); i++ {
+		good, poly, bad, crit := e.aggregateMetrics(&history[i])
 		for _, v := range []int{good, poly, bad, crit} {
 			if v > 0 {
 				l := e.logVal(float64(v))
@@ -726,14 +742,15 @@ func (e *Engine) calculateGlobalLogBounds() (minLog, maxLog float64) {
 }
 
 func (e *Engine) calculateGlobalIPBounds() (minLog, maxLog float64) {
+	history := e.trendHistory()
 	globalMaxLog := 1.0
 	globalMinLog := 100.0
-	if len(e.history) < 3 {
+	if len(history) < 3 {
 		return 0, 1.0
 	}
 	hasData := false
-	for i := 2; i < len(e.history); i++ {
-		s := &e.history[i]
+	for i := 2; i < len(history); i++ {
+		s := &history[i]
 		for _, v := range []uint64{s.GoodIPs, s.PolyIPs, s.BadIPs, s.CritIPs} {
 			if v > 0 {
 				l := e.logVal(float64(v))
@@ -765,7 +782,8 @@ func (e *Engine) calculateGlobalIPBounds() (minLog, maxLog float64) {
 }
 
 func (e *Engine) drawIPTrendLayers(chartW, chartH, globalMinLog, globalMaxLog float64) {
-	hLen := len(e.history)
+	history := e.trendHistory()
+	hLen := len(history)
 	numSteps := float64(hLen - 3) // Adjusted for skipping 2 values
 	if numSteps <= 0 {
 		numSteps = 1
@@ -800,11 +818,11 @@ func (e *Engine) drawIPTrendLayers(chartW, chartH, globalMinLog, globalMaxLog fl
 		// Smoothing: average current snapshot with the previous one
 		avgMetrics := func(idx int) (uint64, uint64, uint64, uint64) {
 			if idx <= 2 {
-				s := e.history[idx]
+				s := history[idx]
 				return s.GoodIPs, s.PolyIPs, s.BadIPs, s.CritIPs
 			}
-			s1 := e.history[idx-1]
-			s2 := e.history[idx]
+			s1 := history[idx-1]
+			s2 := history[idx]
 			return (s1.GoodIPs + s2.GoodIPs) / 2, (s1.PolyIPs + s2.PolyIPs) / 2, (s1.BadIPs + s2.BadIPs) / 2, (s1.CritIPs + s2.CritIPs) / 2
 		}
 
@@ -889,7 +907,8 @@ func (e *Engine) drawTrendGrid(screen *ebiten.Image, gx, gy, chartW, chartH, tit
 }
 
 func (e *Engine) drawTrendLayers(chartW, chartH, globalMinLog, globalMaxLog float64) {
-	hLen := len(e.history)
+	history := e.trendHistory()
+	hLen := len(history)
 	numSteps := float64(hLen - 2)
 	if numSteps <= 0 {
 		numSteps = 1
@@ -924,10 +943,10 @@ func (e *Engine) drawTrendLayers(chartW, chartH, globalMinLog, globalMaxLog floa
 		// Smoothing: average current snapshot with the previous one
 		avgMetrics := func(idx int) (int, int, int, int) {
 			if idx <= 1 {
-				return e.aggregateMetrics(&e.history[idx])
+				return e.aggregateMetrics(&history[idx])
 			}
-			g1, p1, b1, c1 := e.aggregateMetrics(&e.history[idx-1])
-			g2, p2, b2, c2 := e.aggregateMetrics(&e.history[idx])
+			g1, p1, b1, c1 := e.aggregateMetrics(&history[idx-1])
+			g2, p2, b2, c2 := e.aggregateMetrics(&history[idx])
 			return (g1 + g2) / 2, (p1 + p2) / 2, (b1 + b2) / 2, (c1 + c2) / 2
 		}
 
@@ -982,6 +1001,7 @@ func (e *Engine) StartMetricsLoop() {
 		e.lastMetricsUpdate = now
 
 		e.updateMetricSnapshots(interval)
+		e.recordMetricHistory(now, interval)
 
 		logTicks++
 		if logTicks >= 60 {
@@ -1017,6 +1037,7 @@ func (e *Engine) StartMetricsLoop() {
 
 	for range ticker.C {
 		run()
+		e.refreshTrendRange(e.Now())
 	}
 }
 
@@ -1368,6 +1389,9 @@ func (e *Engine) calculateEventHeight(ce *CriticalEvent, boxW, fontSize float64)
 		if ce.CachedLocVal != "" {
 			h += e.labeledLineHeight(ce.CachedLocLabel, ce.CachedLocVal, e.subMonoFace, detailsW, fontSize)
 		}
+		if ce.CachedVisVal != "" {
+			h += e.labeledLineHeight(ce.CachedVisLabel, ce.CachedVisVal, e.subMonoFace, detailsW, fontSize)
+		}
 	case bgp.NameDDoSMitigation, bgp.NameHijack:
 		h += e.labeledLineHeight(ce.CachedLeakerLabel, ce.CachedLeakerVal, e.subMonoFace, detailsW, fontSize)
 		h += e.labeledLineHeight(ce.CachedVictimLabel, ce.CachedVictimVal, e.subMonoFace, detailsW, fontSize)
@@ -0,0 +1,194 @@
+// Package eval measures how well the classifier detects known incidents. A
+// catalogue labels incidents with their time window, prefixes and expected
+// classification; the MRT updates recorded around each incident are replayed
+// through a fresh classifier and the classifications it makes are scored
+// against the labels.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// DefaultGrace is how long after an incident ends a detection still counts
+// unless the catalogue sets its own.
+const DefaultGrace = 5 * time.Minute
+
+// Catalogue is a set of labeled incidents, read from a JSON file.
+type Catalogue struct {
+	// Grace is how long after an incident ends a detection still counts, as a
+	// Go duration
+	Grace     string     `json:"grace,omitempty"`
+	Incidents []Incident `json:"incidents"`
+
+	grace time.Duration
+	// dir is what the MRT paths of the incidents are relative to
+	dir string
+}
+
+// Incident is a labeled incident and the data to replay for it.
+type Incident struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Classification is the classification key the incident should get, or
+	// none for routes that should not raise any critical classification
+	Classification string `json:"classification"`
+	// Prefixes are the affected prefixes. Their more-specifics are affected
+	// too.
+	Prefixes []string `json:"prefixes"`
+	// Leaker and Victim, if set, must match the ones the classifier reports
+	Leaker uint32 `json:"leaker,omitempty"`
+	Victim uint32 `json:"victim,omitempty"`
+
+	// MRT are the update files replayed for the incident, as
+	// [collector=]path relative to the catalogue. Paths may be globs. The
+	// collector defaults to the file name up to its first dot, as in
+	// rrc00.updates.gz.
+	MRT []string `json:"mrt"`
+	// Origins are the historical origins of prefixes, as kept in the seen
+	// prefixes database
+	Origins map[string]uint32 `json:"origins,omitempty"`
+	// ROAs are the VRPs routes are validated against
+	ROAs []utils.VRP `json:"roas,omitempty"`
+
+	expected bgp.ClassificationType
+	prefixes []netip.Prefix
+}
+
+// LoadCatalogue reads and checks a catalogue file.
+func LoadCatalogue(path string) (*Catalogue, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Catalogue
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	c.dir = filepath.Dir(path)
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid catalogue %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+func (c *Catalogue) validate() error {
+	c.grace = DefaultGrace
+	if c.Grace != "" {
+		d, err := time.ParseDuration(c.Grace)
+		if err != nil {
+			return fmt.Errorf("invalid grace %q: %v", c.Grace, err)
+		}
+		c.grace = d
+	}
+	if len(c.Incidents) == 0 {
+		return fmt.Errorf("no incidents")
+	}
+	names := make(map[string]bool)
+	for i := range c.Incidents {
+		inc := &c.Incidents[i]
+		if inc.Name == "" {
+			return fmt.Errorf("incident %d has no name", i+1)
+		}
+		if names[inc.Name] {
+			return fmt.Errorf("duplicate incident %q", inc.Name)
+		}
+		names[inc.Name] = true
+		if err := inc.validate(); err != nil {
+			return fmt.Errorf("incident %q: %v", inc.Name, err)
+		}
+	}
+	return nil
+}
+
+func (inc *Incident) validate() error {
+	if inc.Start.IsZero() || !inc.End.After(inc.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+	if inc.Classification != bgp.ClassificationNone.Key() {
+		t, ok := bgp.ParseClassificationKey(inc.Classification)
+		if !ok {
+			return fmt.Errorf("unknown classification %q", inc.Classification)
+		}
+		inc.expected = t
+	}
+	if len(inc.Prefixes) == 0 {
+		return fmt.Errorf("no prefixes")
+	}
+	inc.prefixes = inc.prefixes[:0]
+	for _, p := range inc.Prefixes {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return fmt.Errorf("invalid prefix %q: %v", p, err)
+		}
+		inc.prefixes = append(inc.prefixes, prefix.Masked())
+	}
+	if len(inc.MRT) == 0 {
+		return fmt.Errorf("no MRT files")
+	}
+	return nil
+}
+
+// Expected returns the classification the incident should get.
+func (inc *Incident) Expected() bgp.ClassificationType {
+	return inc.expected
+}
+
+// covers reports whether prefix is one of the incident's prefixes or a
+// more-specific of one.
+func (inc *Incident) covers(prefix string) bool {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	for _, label := range inc.prefixes {
+		if p.Bits() >= label.Bits() && label.Contains(p.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+// mrtFile is an update file and the collector that recorded it.
+type mrtFile struct {
+	collector string
+	path      string
+}
+
+// files expands the incident's MRT files relative to dir.
+func (inc *Incident) files(dir string) ([]mrtFile, error) {
+	var files []mrtFile
+	for _, spec := range inc.MRT {
+		collector, pattern, named := strings.Cut(spec, "=")
+		if !named {
+			pattern = collector
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MRT pattern %q: %v", spec, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no MRT files match %q", spec)
+		}
+		for _, path := range paths {
+			name := collector
+			if !named {
+				name, _, _ = strings.Cut(filepath.Base(path), ".")
+			}
+			files = append(files, mrtFile{collector: name, path: path})
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,396 @@
+package eval
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	gobgp "github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// Detection is a prefix changing classification during a replay.
+type Detection struct {
+	Time           time.Time
+	Prefix         string
+	Classification bgp.ClassificationType
+	Leaker         uint32
+	Victim         uint32
+}
+
+// IncidentResult is how the classifier did on one incident.
+type IncidentResult struct {
+	Incident *Incident
+	Messages int
+	// Predicted is the classification the incident's prefixes got: the
+	// expected one if it was detected, otherwise the most severe one they got
+	// during the incident. Only critical ones count against routes labeled
+	// none.
+	Predicted bgp.ClassificationType
+	// Detected is set if the incident got the expected classification, or
+	// for routes labeled none, if they got no critical one
+	Detected bool
+	// Latency is from the start of the incident to its first detection
+	Latency time.Duration
+	// TruePositives and FalsePositives count the detections of the
+	// incident's prefixes during it that match it and those with a critical
+	// classification that do not
+	TruePositives  int
+	FalsePositives int
+	// Detections are those of the incident's prefixes during it
+	Detections []Detection
+}
+
+// TypeScore sums up the results of one classification.
+type TypeScore struct {
+	Classification bgp.ClassificationType
+	// Incidents are labeled with the classification, Detected of them got it
+	Incidents int
+	Detected  int
+	// TruePositives and FalsePositives count detections with the
+	// classification that match an incident and those that do not
+	TruePositives  int
+	FalsePositives int
+	MeanLatency    time.Duration
+	MaxLatency     time.Duration
+}
+
+// Precision is the share of detections with the classification that match
+// an incident, or 0 without any.
+func (s TypeScore) Precision() float64 {
+	if n := s.TruePositives + s.FalsePositives; n > 0 {
+		return float64(s.TruePositives) / float64(n)
+	}
+	return 0
+}
+
+// Recall is the share of incidents with the classification that were
+// detected, or 0 without any.
+func (s TypeScore) Recall() float64 {
+	if s.Incidents > 0 {
+		return float64(s.Detected) / float64(s.Incidents)
+	}
+	return 0
+}
+
+// Report is the result of evaluating a catalogue.
+type Report struct {
+	Incidents []IncidentResult
+	// Types has a score for every classification expected by an incident or
+	// detected with a critical classification, most severe first
+	Types []TypeScore
+	// Confusion counts incidents by expected and predicted classification
+	Confusion map[bgp.ClassificationType]map[bgp.ClassificationType]int
+}
+
+// Options configure the classifier an evaluation runs.
+type Options struct {
+	// ASNMapping names networks for the sibling checks of the classifier.
+	// Without one only identical ASNs are siblings.
+	ASNMapping *utils.ASNMapping
+	// FullBogons, if set, also flags unallocated space.
+	FullBogons *utils.FullBogons
+}
+
+// replayGroup is a set of incidents replayed together because they share
+// their MRT files, origins and ROAs.
+type replayGroup struct {
+	incidents  []*Incident
+	files      []mrtFile
+	until      time.Time
+	detections []Detection
+	messages   int
+}
+
+// Run replays the data of the catalogue's incidents through a fresh
+// classifier for each set of MRT files and scores the detections.
+func Run(c *Catalogue, opts Options) (*Report, error) {
+	groups, err := c.groups()
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Confusion: make(map[bgp.ClassificationType]map[bgp.ClassificationType]int)}
+	results := make(map[*Incident]IncidentResult)
+	for _, g := range groups {
+		if err := g.replay(opts); err != nil {
+			return nil, fmt.Errorf("incident %q: %v", g.incidents[0].Name, err)
+		}
+		for _, inc := range g.incidents {
+			results[inc] = score(inc, g, c.grace)
+		}
+	}
+	for i := range c.Incidents {
+		inc := &c.Incidents[i]
+		res := results[inc]
+		r.Incidents = append(r.Incidents, res)
+		row := r.Confusion[inc.expected]
+		if row == nil {
+			row = make(map[bgp.ClassificationType]int)
+			r.Confusion[inc.expected] = row
+		}
+		row[res.Predicted]++
+	}
+	r.Types = typeScores(groups, r.Incidents, c.grace)
+	return r, nil
+}
+
+// groups puts the incidents with the same data to replay together, in
+// catalogue order.
+func (c *Catalogue) groups() ([]*replayGroup, error) {
+	var groups []*replayGroup
+	byKey := make(map[string]*replayGroup)
+	for i := range c.Incidents {
+		inc := &c.Incidents[i]
+		files, err := inc.files(c.dir)
+		if err != nil {
+			return nil, fmt.Errorf("incident %q: %v", inc.Name, err)
+		}
+		var paths []string
+		for _, f := range files {
+			paths = append(paths, f.collector+"="+f.path)
+		}
+		key, err := json.Marshal([]any{paths, inc.Origins, inc.ROAs})
+		if err != nil {
+			return nil, err
+		}
+		g := byKey[string(key)]
+		if g == nil {
+			g = &replayGroup{files: files}
+			byKey[string(key)] = g
+			groups = append(groups, g)
+		}
+		g.incidents = append(g.incidents, inc)
+		if until := inc.End.Add(c.grace); until.After(g.until) {
+			g.until = until
+		}
+	}
+	return groups, nil
+}
+
+// replay runs the group's updates received until the end of its last grace
+// period through a fresh classifier and collects the detections.
+func (g *replayGroup) replay(opts Options) error {
+	var msgs []*mrtfile.Message
+	for _, f := range g.files {
+		mrtfile.ScanMessages(f.collector, f.path, func(msg *mrtfile.Message) {
+			if !msg.Timestamp.After(g.until) {
+				msgs = append(msgs, msg)
+			}
+		})
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Timestamp.Before(msgs[j].Timestamp) })
+	g.messages = len(msgs)
+
+	dir, err := os.MkdirTemp("", "bgp-eval")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	inc := g.incidents[0]
+	var seenDB *utils.DiskTrie
+	if len(inc.Origins) > 0 {
+		seenDB, err = utils.OpenDiskTrie(filepath.Join(dir, "seen-prefixes.db"))
+		if err != nil {
+			return fmt.Errorf("failed to open seen prefixes database: %v", err)
+		}
+		defer func() { _ = seenDB.Close() }()
+		entries := make(map[string][]byte, len(inc.Origins))
+		for prefix, asn := range inc.Origins {
+			entries[prefix] = binary.BigEndian.AppendUint32(nil, asn)
+		}
+		if err := seenDB.BatchInsert(entries); err != nil {
+			return fmt.Errorf("failed to store origins: %v", err)
+		}
+	}
+	var rpki *utils.RPKIManager
+	if len(inc.ROAs) > 0 {
+		rpki, err = utils.NewRPKIManager(filepath.Join(dir, "rpki-vrps.db"))
+		if err != nil {
+			return fmt.Errorf("failed to open VRP database: %v", err)
+		}
+		defer func() { _ = rpki.Close() }()
+		if err := rpki.Load(inc.ROAs); err != nil {
+			return fmt.Errorf("failed to store ROAs: %v", err)
+		}
+	}
+
+	var now time.Time
+	states := utils.NewLRUCache[string, *bgpproto.PrefixState](100000)
+	classifier := bgp.NewClassifier(seenDB, nil, opts.ASNMapping, rpki, prefixToIP, states, func() time.Time { return now })
+	classifier.SetPeerTracker(bgp.NewPeerTracker())
+	classifier.SetFullBogons(opts.FullBogons)
+
+	for _, msg := range msgs {
+		now = msg.Timestamp
+		update, ok := msg.Message.Body.(*gobgp.BGPUpdate)
+		if !ok {
+			continue
+		}
+		ctx := mrtfile.Context(msg, update.PathAttributes)
+		for _, nlri := range update.NLRI {
+			g.classify(classifier, nlri.String(), ctx)
+		}
+		withdrawal := *ctx
+		withdrawal.IsWithdrawal = true
+		for _, nlri := range update.WithdrawnRoutes {
+			g.classify(classifier, nlri.String(), &withdrawal)
+		}
+	}
+	return nil
+}
+
+// classify passes one prefix of a message to the classifier and records a
+// change of its classification.
+func (g *replayGroup) classify(c *bgp.Classifier, prefix string, ctx *bgp.MessageContext) {
+	oldType := bgp.ClassificationNone
+	if state, ok := c.GetPrefixState(prefix); ok {
+		oldType = bgp.ClassificationType(state.ClassifiedType)
+	}
+	ev, classified := c.ClassifyEvent(prefix, ctx)
+	if !classified || ev.ClassificationType == oldType {
+		return
+	}
+	d := Detection{Time: ctx.Now, Prefix: prefix, Classification: ev.ClassificationType}
+	if ev.LeakDetail != nil {
+		d.Leaker, d.Victim = ev.LeakDetail.LeakerASN, ev.LeakDetail.VictimASN
+	}
+	g.detections = append(g.detections, d)
+}
+
+// score matches the detections of a replay against one of its incidents. A
+// detection matches if it is on one of the incident's prefixes during the
+// incident or its grace period, with the expected classification, leaker and
+// victim.
+func score(inc *Incident, g *replayGroup, grace time.Duration) IncidentResult {
+	res := IncidentResult{Incident: inc, Messages: g.messages, Predicted: bgp.ClassificationNone}
+	until := inc.End.Add(grace)
+	for _, d := range g.detections {
+		if !inc.during(d, until) {
+			continue
+		}
+		res.Detections = append(res.Detections, d)
+		switch {
+		case inc.matches(d):
+			res.TruePositives++
+			if !res.Detected {
+				res.Latency = d.Time.Sub(inc.Start)
+				res.Detected = true
+			}
+			res.Predicted = inc.expected
+			continue
+		case d.Classification.IsCritical():
+			res.FalsePositives++
+		}
+		if res.Predicted != inc.expected && severity(d.Classification) > severity(res.Predicted) {
+			res.Predicted = d.Classification
+		}
+	}
+	if inc.expected == bgp.ClassificationNone {
+		// Routes labeled none only go wrong with a critical classification
+		if !res.Predicted.IsCritical() {
+			res.Predicted = bgp.ClassificationNone
+		}
+		res.Detected = res.Predicted == bgp.ClassificationNone
+	}
+	return res
+}
+
+// during reports whether d is on one of the incident's prefixes between its
+// start and until.
+func (inc *Incident) during(d Detection, until time.Time) bool {
+	return !d.Time.Before(inc.Start) && !d.Time.After(until) && inc.covers(d.Prefix)
+}
+
+func (inc *Incident) matches(d Detection) bool {
+	return d.Classification == inc.expected && inc.expected != bgp.ClassificationNone &&
+		(inc.Leaker == 0 || d.Leaker == inc.Leaker) &&
+		(inc.Victim == 0 || d.Victim == inc.Victim)
+}
+
+// severity orders classifications for picking the one an incident was
+// mistaken for: critical ones first, then by priority.
+func severity(t bgp.ClassificationType) int {
+	if t == bgp.ClassificationNone {
+		return -1
+	}
+	if t.IsCritical() {
+		return 10 + t.Priority()
+	}
+	return t.Priority()
+}
+
+// typeScores sums up the results by classification. A detection is a true
+// positive if it matches any incident replayed with it and a false positive
+// if it has a critical classification and matches none.
+func typeScores(groups []*replayGroup, results []IncidentResult, grace time.Duration) []TypeScore {
+	byType := make(map[bgp.ClassificationType]*TypeScore)
+	get := func(t bgp.ClassificationType) *TypeScore {
+		s := byType[t]
+		if s == nil {
+			s = &TypeScore{Classification: t}
+			byType[t] = s
+		}
+		return s
+	}
+	latencies := make(map[bgp.ClassificationType]time.Duration)
+	for _, res := range results {
+		t := res.Incident.expected
+		if t == bgp.ClassificationNone {
+			continue
+		}
+		s := get(t)
+		s.Incidents++
+		if res.Detected {
+			s.Detected++
+			latencies[t] += res.Latency
+			s.MaxLatency = max(s.MaxLatency, res.Latency)
+		}
+	}
+	for _, g := range groups {
+		for _, d := range g.detections {
+			matched := slices.ContainsFunc(g.incidents, func(inc *Incident) bool {
+				return inc.during(d, inc.End.Add(grace)) && inc.matches(d)
+			})
+			switch {
+			case matched:
+				get(d.Classification).TruePositives++
+			case d.Classification.IsCritical():
+				get(d.Classification).FalsePositives++
+			}
+		}
+	}
+
+	scores := make([]TypeScore, 0, len(byType))
+	for t, s := range byType {
+		if s.Detected > 0 {
+			s.MeanLatency = latencies[t] / time.Duration(s.Detected)
+		}
+		scores = append(scores, *s)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if a, b := severity(scores[i].Classification), severity(scores[j].Classification); a != b {
+			return a > b
+		}
+		return scores[i].Classification < scores[j].Classification
+	})
+	return scores
+}
+
+func prefixToIP(p string) uint32 {
+	ip := net.ParseIP(strings.Split(p, "/")[0])
+	if ip == nil {
+		return 0
+	}
+	return utils.IPToUint32(ip)
+}
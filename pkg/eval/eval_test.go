@@ -0,0 +1,161 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+func TestRun_Catalogue(t *testing.T) {
+	c, err := LoadCatalogue(filepath.Join("testdata", "catalogue.json"))
+	if err != nil {
+		t.Fatalf("LoadCatalogue failed: %v", err)
+	}
+	r, err := Run(c, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(r.Incidents) != len(c.Incidents) {
+		t.Fatalf("Expected %d incident results, got %d", len(c.Incidents), len(r.Incidents))
+	}
+	for _, res := range r.Incidents {
+		if res.Messages == 0 {
+			t.Errorf("%s: no messages replayed", res.Incident.Name)
+		}
+		if !res.Detected {
+			t.Errorf("%s: not detected, predicted %v", res.Incident.Name, res.Predicted)
+		}
+		if res.Predicted != res.Incident.Expected() {
+			t.Errorf("%s: predicted %v, want %v", res.Incident.Name, res.Predicted, res.Incident.Expected())
+		}
+		if res.FalsePositives != 0 {
+			t.Errorf("%s: %d false positives: %+v", res.Incident.Name, res.FalsePositives, res.Detections)
+		}
+		if res.Latency > time.Minute {
+			t.Errorf("%s: latency %v", res.Incident.Name, res.Latency)
+		}
+	}
+
+	for expected, row := range r.Confusion {
+		for predicted, n := range row {
+			if predicted != expected {
+				t.Errorf("%d incidents of %v confused with %v", n, expected, predicted)
+			}
+		}
+	}
+
+	want := map[bgp.ClassificationType]bool{
+		bgp.ClassificationRouteLeak: true,
+		bgp.ClassificationOutage:    true,
+		bgp.ClassificationHijack:    true,
+	}
+	for _, s := range r.Types {
+		if !want[s.Classification] {
+			t.Errorf("Unexpected score for %v: %+v", s.Classification, s)
+			continue
+		}
+		delete(want, s.Classification)
+		if s.Precision() != 1 || s.Recall() != 1 {
+			t.Errorf("%v: precision %.2f, recall %.2f, want 1", s.Classification, s.Precision(), s.Recall())
+		}
+	}
+	for missing := range want {
+		t.Errorf("Missing score for %v", missing)
+	}
+}
+
+func TestScore(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	leak := Incident{
+		Name: "leak", Start: start, End: start.Add(10 * time.Minute),
+		Classification: "route_leak", Prefixes: []string{"192.0.2.0/23"},
+		Leaker: 64500, Victim: 64501, MRT: []string{"x"},
+	}
+	quiet := Incident{
+		Name: "quiet", Start: start, End: start.Add(10 * time.Minute),
+		Classification: "none", Prefixes: []string{"198.51.100.0/24"}, MRT: []string{"x"},
+	}
+	for _, inc := range []*Incident{&leak, &quiet} {
+		if err := inc.validate(); err != nil {
+			t.Fatalf("validate %s: %v", inc.Name, err)
+		}
+	}
+
+	g := &replayGroup{
+		incidents: []*Incident{&leak, &quiet},
+		detections: []Detection{
+			// Before the incident
+			{Time: start.Add(-time.Minute), Prefix: "192.0.2.0/24", Classification: bgp.ClassificationOutage},
+			// Wrong leaker
+			{Time: start.Add(time.Minute), Prefix: "192.0.2.0/24", Classification: bgp.ClassificationRouteLeak, Leaker: 64502, Victim: 64501},
+			// More-specific of the labeled prefix
+			{Time: start.Add(2 * time.Minute), Prefix: "192.0.3.0/24", Classification: bgp.ClassificationRouteLeak, Leaker: 64500, Victim: 64501},
+			// Within the grace period
+			{Time: start.Add(12 * time.Minute), Prefix: "192.0.2.0/24", Classification: bgp.ClassificationRouteLeak, Leaker: 64500, Victim: 64501},
+			// After the grace period
+			{Time: start.Add(20 * time.Minute), Prefix: "192.0.2.0/24", Classification: bgp.ClassificationRouteLeak, Leaker: 64500, Victim: 64501},
+			// Not critical
+			{Time: start.Add(time.Minute), Prefix: "198.51.100.0/24", Classification: bgp.ClassificationFlap},
+		},
+	}
+	grace := 5 * time.Minute
+
+	res := score(&leak, g, grace)
+	if !res.Detected || res.Predicted != bgp.ClassificationRouteLeak {
+		t.Errorf("leak: detected %v, predicted %v", res.Detected, res.Predicted)
+	}
+	if res.Latency != 2*time.Minute {
+		t.Errorf("leak: latency %v, want 2m", res.Latency)
+	}
+	if res.TruePositives != 2 || res.FalsePositives != 1 {
+		t.Errorf("leak: %d true and %d false positives, want 2 and 1", res.TruePositives, res.FalsePositives)
+	}
+
+	res = score(&quiet, g, grace)
+	if !res.Detected || res.Predicted != bgp.ClassificationNone {
+		t.Errorf("quiet: detected %v, predicted %v", res.Detected, res.Predicted)
+	}
+
+	types := typeScores([]*replayGroup{g}, []IncidentResult{score(&leak, g, grace), res}, grace)
+	if len(types) != 2 {
+		t.Fatalf("Expected scores for outages and route leaks, got %+v", types)
+	}
+	if s := types[0]; s.Classification != bgp.ClassificationOutage || s.Incidents != 0 || s.FalsePositives != 1 {
+		t.Errorf("Unexpected outage score: %+v", s)
+	}
+	if s := types[1]; s.Classification != bgp.ClassificationRouteLeak || s.TruePositives != 2 || s.FalsePositives != 2 {
+		t.Errorf("Unexpected route leak score: %+v", s)
+	}
+}
+
+func TestLoadCatalogue_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"empty", `{"incidents": []}`},
+		{"grace", `{"grace": "soon", "incidents": [{"name": "a", "start": "2024-03-01T10:00:00Z", "end": "2024-03-01T11:00:00Z", "classification": "outage", "prefixes": ["192.0.2.0/24"], "mrt": ["x"]}]}`},
+		{"window", `{"incidents": [{"name": "a", "start": "2024-03-01T11:00:00Z", "end": "2024-03-01T10:00:00Z", "classification": "outage", "prefixes": ["192.0.2.0/24"], "mrt": ["x"]}]}`},
+		{"classification", `{"incidents": [{"name": "a", "start": "2024-03-01T10:00:00Z", "end": "2024-03-01T11:00:00Z", "classification": "meltdown", "prefixes": ["192.0.2.0/24"], "mrt": ["x"]}]}`},
+		{"prefix", `{"incidents": [{"name": "a", "start": "2024-03-01T10:00:00Z", "end": "2024-03-01T11:00:00Z", "classification": "outage", "prefixes": ["192.0.2.0"], "mrt": ["x"]}]}`},
+		{"mrt", `{"incidents": [{"name": "a", "start": "2024-03-01T10:00:00Z", "end": "2024-03-01T11:00:00Z", "classification": "outage", "prefixes": ["192.0.2.0/24"]}]}`},
+		{"duplicate", `{"incidents": [
+			{"name": "a", "start": "2024-03-01T10:00:00Z", "end": "2024-03-01T11:00:00Z", "classification": "outage", "prefixes": ["192.0.2.0/24"], "mrt": ["x"]},
+			{"name": "a", "start": "2024-03-01T10:00:00Z", "end": "2024-03-01T11:00:00Z", "classification": "outage", "prefixes": ["192.0.2.0/24"], "mrt": ["x"]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "catalogue.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadCatalogue(path); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Package eventstream serves classified BGP activity to EventService
+// subscribers over Connect, gRPC and gRPC-Web.
+package eventstream
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind before
+// messages are dropped for it.
+const subscriberBuffer = 1024
+
+type subscriber struct {
+	filter  *filter
+	ch      chan *bgpproto.SubscribeResponse
+	dropped atomic.Uint64
+}
+
+// Hub fans processor output out to subscribers. Its On* methods are safe to
+// call from the processor workers and never block on a slow subscriber.
+type Hub struct {
+	now bgp.TimeProvider
+
+	mu      sync.RWMutex
+	subs    map[*subscriber]struct{}
+	active  atomic.Int32
+	dropped atomic.Uint64
+}
+
+func NewHub(now bgp.TimeProvider) *Hub {
+	return &Hub{now: now, subs: make(map[*subscriber]struct{})}
+}
+
+// Subscribers returns the number of connected subscribers.
+func (h *Hub) Subscribers() int {
+	return int(h.active.Load())
+}
+
+// Dropped returns how many messages were dropped for slow subscribers.
+func (h *Hub) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+func (h *Hub) subscribe(f *filter) *subscriber {
+	s := &subscriber{filter: f, ch: make(chan *bgpproto.SubscribeResponse, subscriberBuffer)}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.active.Store(int32(len(h.subs)))
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.active.Store(int32(len(h.subs)))
+	h.mu.Unlock()
+}
+
+// subject describes a message for filtering.
+type subject struct {
+	kind      bgpproto.EventKind
+	classes   []bgp.ClassificationType
+	asns      []uint32
+	prefixes  []string
+	countries []string
+}
+
+func (h *Hub) publish(msg *bgpproto.SubscribeResponse, subj *subject) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subs {
+		if !s.filter.matches(subj) {
+			continue
+		}
+		select {
+		case s.ch <- msg:
+		default:
+			s.dropped.Add(1)
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// OnEvent publishes a processed update. It has the signature of a
+// bgp.BGPEventCallback.
+func (h *Hub) OnEvent(lat, lng float64, cc, city string, eventType bgp.EventType, classificationType bgp.ClassificationType, prefix string, asn, historicalASN uint32, leakDetail ...*bgp.LeakDetail) {
+	if h.active.Load() == 0 {
+		return
+	}
+	var ld *bgp.LeakDetail
+	if len(leakDetail) > 0 {
+		ld = leakDetail[0]
+	}
+	ev := &bgpproto.BGPEvent{
+		Time:                timestamppb.New(h.now()),
+		Prefix:              prefix,
+		Type:                bgpproto.EventType(eventType),
+		Classification:      bgpproto.Classification(classificationType),
+		OriginAsn:           asn,
+		HistoricalOriginAsn: historicalASN,
+		Country:             cc,
+		City:                city,
+		Latitude:            lat,
+		Longitude:           lng,
+		Leak:                newLeak(ld),
+	}
+	h.publish(&bgpproto.SubscribeResponse{Payload: &bgpproto.SubscribeResponse_Event{Event: ev}}, &subject{
+		kind:      bgpproto.EventKind_EVENT_KIND_BGP_EVENT,
+		classes:   []bgp.ClassificationType{classificationType},
+		asns:      leakASNs(ld, asn, historicalASN),
+		prefixes:  []string{prefix},
+		countries: []string{cc},
+	})
+}
+
+// OnTransition publishes a classification change. It has the signature of a
+// bgp.TransitionCallback.
+func (h *Hub) OnTransition(t *bgp.Transition) {
+	if h.active.Load() == 0 {
+		return
+	}
+	ev := t.Evidence
+	change := &bgpproto.ClassificationChange{
+		Time:                timestamppb.New(t.Time),
+		Prefix:              t.Prefix,
+		From:                bgpproto.Classification(t.From),
+		To:                  bgpproto.Classification(t.To),
+		OriginAsn:           t.OriginASN,
+		HistoricalOriginAsn: t.HistoricalASN,
+		Country:             t.Country,
+		City:                t.City,
+		Leak:                newLeak(t.LeakDetail),
+		Evidence: &bgpproto.Evidence{
+			Peer:           ev.Peer,
+			Host:           ev.Host,
+			Path:           ev.Path,
+			Communities:    ev.Communities,
+			Messages:       ev.Messages,
+			Announcements:  ev.Announcements,
+			Withdrawals:    ev.Withdrawals,
+			PathChanges:    ev.PathChanges,
+			Peers:          int32(ev.Peers),
+			Hosts:          int32(ev.Hosts),
+			WithdrawnPeers: int32(ev.WithdrawnPeers),
+			WithdrawnHosts: int32(ev.WithdrawnHosts),
+			Origins:        int32(ev.Origins),
+			AnomalyScore:   ev.AnomalyScore,
+		},
+	}
+	if ev.RPKIStatus != 0 {
+		change.Evidence.Rpki = utils.RPKIStatus(ev.RPKIStatus).String()
+	}
+	h.publish(&bgpproto.SubscribeResponse{Payload: &bgpproto.SubscribeResponse_Change{Change: change}}, &subject{
+		kind:      bgpproto.EventKind_EVENT_KIND_CLASSIFICATION_CHANGE,
+		classes:   []bgp.ClassificationType{t.From, t.To},
+		asns:      leakASNs(t.LeakDetail, t.OriginASN, t.HistoricalASN),
+		prefixes:  []string{t.Prefix},
+		countries: []string{t.Country},
+	})
+}
+
+// OnIncident publishes an incident update. It has the signature of an
+// api.IncidentCallback.
+func (h *Hub) OnIncident(ev api.CriticalEvent, opened bool) {
+	if h.active.Load() == 0 {
+		return
+	}
+	class, _ := bgp.ParseClassificationKey(ev.Classification)
+	inc := &bgpproto.Incident{
+		Id:             ev.ID,
+		Time:           timestamppb.New(ev.Time),
+		Classification: bgpproto.Classification(class),
+		Asn:            ev.ASN,
+		Network:        ev.Network,
+		OrgId:          ev.OrgID,
+		Locations:      ev.Locations,
+		Visibility:     ev.Visibility,
+		PeakVisibility: ev.PeakVisibility,
+		ImpactedIps:    ev.ImpactedIPs,
+		Prefixes:       ev.Prefixes,
+		Opened:         opened,
+	}
+	asns := []uint32{ev.ASN}
+	if ev.Leak != nil {
+		inc.Leak = &bgpproto.Leak{LeakerAsn: ev.Leak.LeakerASN, VictimAsn: ev.Leak.VictimASN}
+		if t, ok := bgp.ParseLeakType(ev.Leak.Type); ok {
+			inc.Leak.Type = bgpproto.LeakType(t)
+		}
+		asns = append(asns, ev.Leak.LeakerASN, ev.Leak.VictimASN)
+	}
+	h.publish(&bgpproto.SubscribeResponse{Payload: &bgpproto.SubscribeResponse_Incident{Incident: inc}}, &subject{
+		kind:      bgpproto.EventKind_EVENT_KIND_INCIDENT,
+		classes:   []bgp.ClassificationType{class},
+		asns:      asns,
+		prefixes:  ev.Prefixes,
+		countries: locationCountries(ev.Locations),
+	})
+}
+
+func newLeak(ld *bgp.LeakDetail) *bgpproto.Leak {
+	if ld == nil {
+		return nil
+	}
+	return &bgpproto.Leak{
+		Type:           bgpproto.LeakType(ld.Type),
+		LeakerAsn:      ld.LeakerASN,
+		VictimAsn:      ld.VictimASN,
+		Visibility:     ld.Visibility,
+		PeakVisibility: ld.PeakVisibility,
+		Reason:         ld.Reason,
+	}
+}
+
+func leakASNs(ld *bgp.LeakDetail, asns ...uint32) []uint32 {
+	if ld != nil {
+		asns = append(asns, ld.LeakerASN, ld.VictimASN)
+	}
+	return asns
+}
+
+// locationCountries extracts the country codes from incident locations
+// formatted as "City, CC | CC".
+func locationCountries(locations string) []string {
+	if locations == "" {
+		return nil
+	}
+	var ccs []string
+	for _, loc := range strings.Split(locations, " | ") {
+		if i := strings.LastIndex(loc, ", "); i >= 0 {
+			loc = loc[i+2:]
+		}
+		ccs = append(ccs, loc)
+	}
+	return ccs
+}
+
+// filter is the compiled form of a SubscribeRequest.
+type filter struct {
+	kinds     map[bgpproto.EventKind]bool
+	types     map[bgp.ClassificationType]bool
+	asns      map[uint32]bool
+	prefixes  []netip.Prefix
+	countries map[string]bool
+}
+
+func newFilter(req *bgpproto.SubscribeRequest) (*filter, error) {
+	f := &filter{}
+	if len(req.Kinds) > 0 {
+		f.kinds = make(map[bgpproto.EventKind]bool)
+		for _, k := range req.Kinds {
+			f.kinds[k] = true
+		}
+	}
+	if len(req.Types) > 0 {
+		f.types = make(map[bgp.ClassificationType]bool)
+		for _, t := range req.Types {
+			f.types[bgp.ClassificationType(t)] = true
+		}
+	}
+	if len(req.Asns) > 0 {
+		f.asns = make(map[uint32]bool)
+		for _, asn := range req.Asns {
+			f.asns[asn] = true
+		}
+	}
+	for _, s := range req.Prefixes {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, errors.New("invalid prefix " + s)
+		}
+		f.prefixes = append(f.prefixes, p.Masked())
+	}
+	if len(req.Countries) > 0 {
+		f.countries = make(map[string]bool)
+		for _, cc := range req.Countries {
+			f.countries[strings.ToUpper(cc)] = true
+		}
+	}
+	return f, nil
+}
+
+func (f *filter) matches(s *subject) bool {
+	if f.kinds != nil && !f.kinds[s.kind] {
+		return false
+	}
+	if !f.matchClasses(s.classes) {
+		return false
+	}
+	if f.asns != nil && !anyOf(s.asns, func(asn uint32) bool { return f.asns[asn] }) {
+		return false
+	}
+	if f.prefixes != nil && !anyOf(s.prefixes, f.matchPrefix) {
+		return false
+	}
+	if f.countries != nil && !anyOf(s.countries, func(cc string) bool { return f.countries[cc] }) {
+		return false
+	}
+	return true
+}
+
+func (f *filter) matchClasses(classes []bgp.ClassificationType) bool {
+	if f.types == nil {
+		return anyOf(classes, func(t bgp.ClassificationType) bool { return t != bgp.ClassificationNone })
+	}
+	return anyOf(classes, func(t bgp.ClassificationType) bool { return f.types[t] })
+}
+
+// matchPrefix reports whether prefix is one of the filter prefixes or a
+// more-specific of one.
+func (f *filter) matchPrefix(prefix string) bool {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	for _, fp := range f.prefixes {
+		if fp.Bits() <= p.Bits() && fp.Contains(p.Addr()) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyOf[T any](items []T, fn func(T) bool) bool {
+	for _, item := range items {
+		if fn(item) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package eventstream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+)
+
+func fixedNow() time.Time {
+	return time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+}
+
+func TestFilter(t *testing.T) {
+	f, err := newFilter(&bgpproto.SubscribeRequest{
+		Types:     []bgpproto.Classification{bgpproto.Classification_CLASSIFICATION_HIJACK, bgpproto.Classification_CLASSIFICATION_ROUTE_LEAK},
+		Asns:      []uint32{200},
+		Prefixes:  []string{"10.0.0.0/8", "2001:db8::/32"},
+		Countries: []string{"de"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hijack := []bgp.ClassificationType{bgp.ClassificationHijack}
+	tests := []struct {
+		name string
+		subj subject
+		want bool
+	}{
+		{"match", subject{classes: hijack, asns: []uint32{200}, prefixes: []string{"10.1.0.0/16"}, countries: []string{"DE"}}, true},
+		{"exact prefix", subject{classes: hijack, asns: []uint32{200}, prefixes: []string{"10.0.0.0/8"}, countries: []string{"DE"}}, true},
+		{"ipv6 more-specific", subject{classes: hijack, asns: []uint32{200}, prefixes: []string{"2001:db8:1::/48"}, countries: []string{"DE"}}, true},
+		{"less-specific", subject{classes: hijack, asns: []uint32{200}, prefixes: []string{"10.0.0.0/7"}, countries: []string{"DE"}}, false},
+		{"other type", subject{classes: []bgp.ClassificationType{bgp.ClassificationFlap}, asns: []uint32{200}, prefixes: []string{"10.1.0.0/16"}, countries: []string{"DE"}}, false},
+		{"leaker ASN", subject{classes: hijack, asns: []uint32{100, 200}, prefixes: []string{"10.1.0.0/16"}, countries: []string{"DE"}}, true},
+		{"other ASN", subject{classes: hijack, asns: []uint32{100}, prefixes: []string{"10.1.0.0/16"}, countries: []string{"DE"}}, false},
+		{"other country", subject{classes: hijack, asns: []uint32{200}, prefixes: []string{"10.1.0.0/16"}, countries: []string{"US"}}, false},
+	}
+	for _, tt := range tests {
+		if got := f.matches(&tt.subj); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Without types, unclassified activity is left out unless a change involves
+	// a classification
+	all, _ := newFilter(&bgpproto.SubscribeRequest{})
+	if all.matches(&subject{classes: []bgp.ClassificationType{bgp.ClassificationNone}}) {
+		t.Error("expected unclassified events to be filtered out")
+	}
+	if !all.matches(&subject{classes: []bgp.ClassificationType{bgp.ClassificationOutage, bgp.ClassificationNone}}) {
+		t.Error("expected a change out of an outage to match")
+	}
+
+	if _, err := newFilter(&bgpproto.SubscribeRequest{Prefixes: []string{"nope"}}); err == nil {
+		t.Error("expected an invalid prefix to be rejected")
+	}
+}
+
+func TestHub_DropsForSlowSubscribers(t *testing.T) {
+	h := NewHub(fixedNow)
+	// No subscribers, nothing to do
+	h.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationFlap, "1.2.3.0/24", 100, 0)
+
+	f, _ := newFilter(&bgpproto.SubscribeRequest{})
+	sub := h.subscribe(f)
+	for i := 0; i < subscriberBuffer+5; i++ {
+		h.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationFlap, "1.2.3.0/24", 100, 0)
+	}
+	if h.Dropped() != 5 || sub.dropped.Load() != 5 || len(sub.ch) != subscriberBuffer {
+		t.Errorf("expected 5 dropped messages, got %d (queued %d)", h.Dropped(), len(sub.ch))
+	}
+
+	h.unsubscribe(sub)
+	if h.Subscribers() != 0 {
+		t.Errorf("expected no subscribers, got %d", h.Subscribers())
+	}
+}
+
+func TestHub_Incident(t *testing.T) {
+	h := NewHub(fixedNow)
+	f, _ := newFilter(&bgpproto.SubscribeRequest{Countries: []string{"CA"}, Asns: []uint32{300}})
+	sub := h.subscribe(f)
+
+	h.OnIncident(api.CriticalEvent{
+		ID:             3,
+		Time:           fixedNow(),
+		Classification: "route_leak",
+		ASN:            100,
+		Leak:           &api.Leak{Type: bgp.LeakLateral.String(), LeakerASN: 200, VictimASN: 300},
+		Locations:      "Dallas, US | CA",
+		Prefixes:       []string{"1.2.3.0/24"},
+	}, true)
+
+	select {
+	case msg := <-sub.ch:
+		inc := msg.GetIncident()
+		if inc.GetId() != 3 || !inc.GetOpened() || inc.GetClassification() != bgpproto.Classification_CLASSIFICATION_ROUTE_LEAK || inc.GetLeak().GetType() != bgpproto.LeakType_LEAK_TYPE_LATERAL {
+			t.Errorf("unexpected incident: %v", inc)
+		}
+	default:
+		t.Fatal("expected the incident to be published")
+	}
+}
@@ -0,0 +1,90 @@
+package eventstream
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1/bgpprotoconnect"
+)
+
+type service struct {
+	hub *Hub
+}
+
+// NewHandler returns the route and handler of the EventService backed by hub.
+// The handler speaks Connect, gRPC and gRPC-Web.
+func NewHandler(hub *Hub) (string, http.Handler) {
+	return bgpprotoconnect.NewEventServiceHandler(&service{hub: hub})
+}
+
+func (s *service) Subscribe(ctx context.Context, req *connect.Request[bgpproto.SubscribeRequest], stream *connect.ServerStream[bgpproto.SubscribeResponse]) error {
+	f, err := newFilter(req.Msg)
+	if err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	sub := s.hub.subscribe(f)
+	defer s.hub.unsubscribe(sub)
+	// Send the headers right away so the client knows it is subscribed
+	if err := stream.Send(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if n := sub.dropped.Load(); n > 0 {
+				log.Printf("[STREAM] Subscriber from %s missed %d messages", req.Peer().Addr, n)
+			}
+			return nil
+		case msg := <-sub.ch:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ListenAndServe serves the EventService of hub on addr until ctx is canceled.
+// Plain-text HTTP/2 is accepted so gRPC clients can connect without TLS.
+func ListenAndServe(ctx context.Context, addr string, hub *Hub) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(NewHandler(hub))
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Handler:           mux,
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		// Subscriptions only end when their context does
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	log.Printf("[STREAM] Serving %s on %s", bgpprotoconnect.EventServiceName, ln.Addr())
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package eventstream
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	bgpproto "github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1"
+	"github.com/sudorandom/bgp-stream/pkg/bgp/proto/v1/bgpprotoconnect"
+)
+
+func newTestServer(t *testing.T, hub *Hub, http2 bool) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(NewHandler(hub))
+	srv := httptest.NewUnstartedServer(mux)
+	if http2 {
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func subscribe(t *testing.T, hub *Hub, client bgpprotoconnect.EventServiceClient, req *bgpproto.SubscribeRequest) *connect.ServerStreamForClient[bgpproto.SubscribeResponse] {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	stream, err := client.Subscribe(ctx, connect.NewRequest(req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = stream.Close() })
+	for deadline := time.Now().Add(5 * time.Second); hub.Subscribers() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription did not register")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return stream
+}
+
+func receive(t *testing.T, stream *connect.ServerStreamForClient[bgpproto.SubscribeResponse]) *bgpproto.SubscribeResponse {
+	t.Helper()
+	if !stream.Receive() {
+		t.Fatalf("stream ended: %v", stream.Err())
+	}
+	return stream.Msg()
+}
+
+func TestSubscribe_Connect(t *testing.T) {
+	hub := NewHub(fixedNow)
+	srv := newTestServer(t, hub, false)
+	client := bgpprotoconnect.NewEventServiceClient(srv.Client(), srv.URL)
+
+	stream := subscribe(t, hub, client, &bgpproto.SubscribeRequest{
+		Types:    []bgpproto.Classification{bgpproto.Classification_CLASSIFICATION_HIJACK},
+		Prefixes: []string{"10.0.0.0/8"},
+	})
+
+	hub.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationHijack, "11.0.0.0/16", 100, 200)
+	hub.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationFlap, "10.2.0.0/16", 100, 0)
+	hub.OnEvent(0, 0, "US", "", bgp.EventUpdate, bgp.ClassificationNone, "10.3.0.0/16", 100, 0)
+	hub.OnEvent(32.8, -96.8, "US", "Dallas", bgp.EventUpdate, bgp.ClassificationHijack, "10.1.0.0/16", 100, 200, &bgp.LeakDetail{VictimASN: 200})
+	hub.OnTransition(&bgp.Transition{
+		Time:      fixedNow(),
+		Prefix:    "10.1.0.0/16",
+		From:      bgp.ClassificationNone,
+		To:        bgp.ClassificationHijack,
+		OriginASN: 100,
+		Evidence:  bgp.Evidence{Peers: 12, RPKIStatus: 2},
+	})
+
+	ev := receive(t, stream).GetEvent()
+	if ev.GetPrefix() != "10.1.0.0/16" || ev.GetType() != bgpproto.EventType_EVENT_TYPE_UPDATE || ev.GetCity() != "Dallas" || ev.GetLeak().GetVictimAsn() != 200 || !ev.GetTime().AsTime().Equal(fixedNow()) {
+		t.Errorf("unexpected event: %v", ev)
+	}
+	change := receive(t, stream).GetChange()
+	if change.GetTo() != bgpproto.Classification_CLASSIFICATION_HIJACK || change.GetEvidence().GetPeers() != 12 || change.GetEvidence().GetRpki() != "InvalidASN" {
+		t.Errorf("unexpected change: %v", change)
+	}
+}
+
+func TestSubscribe_GRPC(t *testing.T) {
+	hub := NewHub(fixedNow)
+	srv := newTestServer(t, hub, true)
+	client := bgpprotoconnect.NewEventServiceClient(srv.Client(), srv.URL, connect.WithGRPC())
+
+	stream := subscribe(t, hub, client, &bgpproto.SubscribeRequest{
+		Kinds: []bgpproto.EventKind{bgpproto.EventKind_EVENT_KIND_CLASSIFICATION_CHANGE},
+		Asns:  []uint32{300},
+	})
+
+	hub.OnEvent(0, 0, "DE", "", bgp.EventUpdate, bgp.ClassificationOutage, "20.0.0.0/16", 300, 0)
+	hub.OnTransition(&bgp.Transition{Time: fixedNow(), Prefix: "30.0.0.0/16", From: bgp.ClassificationNone, To: bgp.ClassificationRouteLeak, OriginASN: 100})
+	hub.OnTransition(&bgp.Transition{
+		Time:       fixedNow(),
+		Prefix:     "20.0.0.0/16",
+		From:       bgp.ClassificationNone,
+		To:         bgp.ClassificationRouteLeak,
+		OriginASN:  100,
+		LeakDetail: &bgp.LeakDetail{Type: bgp.LeakPeerToProvider, LeakerASN: 200, VictimASN: 300},
+	})
+
+	change := receive(t, stream).GetChange()
+	if change.GetPrefix() != "20.0.0.0/16" || change.GetLeak().GetType() != bgpproto.LeakType_LEAK_TYPE_PEER_TO_PROVIDER {
+		t.Errorf("unexpected change: %v", change)
+	}
+}
+
+func TestSubscribe_InvalidArgument(t *testing.T) {
+	hub := NewHub(fixedNow)
+	srv := newTestServer(t, hub, false)
+	client := bgpprotoconnect.NewEventServiceClient(srv.Client(), srv.URL)
+
+	stream, err := client.Subscribe(context.Background(), connect.NewRequest(&bgpproto.SubscribeRequest{Prefixes: []string{"not-a-prefix"}}))
+	if err == nil {
+		defer func() { _ = stream.Close() }()
+		if stream.Receive() {
+			t.Fatal("expected the stream to fail")
+		}
+		err = stream.Err()
+	}
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeInvalidArgument {
+		t.Errorf("expected an invalid argument error, got %v", err)
+	}
+}
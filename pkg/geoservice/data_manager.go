@@ -232,9 +232,24 @@ func (dm *DataManager) ProcessCustomHints(hints []string) {
 
 func (dm *DataManager) ProcessRIRData() error {
 	log.Println("[GEO] Fetching RIR data...")
-	allRanges, countryOnlyRanges := dm.fetchRIRData()
+	allRanges, countryOnlyRanges, alloc := dm.fetchRIRData()
 	log.Printf("[GEO] RIR fetch complete. Total ranges: %d city-level, %d country-only", len(allRanges), len(countryOnlyRanges))
 
+	// Unallocated space can only be derived when every registry contributed, otherwise
+	// a missing registry would turn its entire allocation into bogons.
+	// Running viewers and bgp-cli run/serve processes swap in the saved list
+	// through their FullBogonsWatcher.
+	if alloc.sources == len(sources.GetRIRSources()) {
+		bogons := utils.NewFullBogons(alloc.ipv4, alloc.asns)
+		if err := bogons.Save(utils.FullBogonsPath); err != nil {
+			log.Printf("Warning: Failed to save full bogons: %v", err)
+		} else {
+			log.Printf("[BOGONS] Saved %d unallocated IPv4 ranges and %d unallocated ASN ranges", len(bogons.IPv4), len(bogons.ASNs))
+		}
+	} else {
+		log.Printf("[BOGONS] Skipping full bogons: only %d of %d RIR files were loaded", alloc.sources, len(sources.GetRIRSources()))
+	}
+
 	segments := dm.flattenPrefixData(allRanges)
 	dm.indexPrefixData(segments, &dm.geo.prefixData)
 
@@ -259,21 +274,30 @@ func (dm *DataManager) ProcessRIRData() error {
 	return nil
 }
 
-func (dm *DataManager) fetchRIRData() (allRanges, countryOnlyRanges []ipRange) {
+// allocatedSpace collects the allocated and assigned resources listed in the RIR
+// delegated stats files.
+type allocatedSpace struct {
+	ipv4    []utils.Range
+	asns    []utils.Range
+	sources int
+}
+
+func (dm *DataManager) fetchRIRData() (allRanges, countryOnlyRanges []ipRange, alloc *allocatedSpace) {
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	alloc = &allocatedSpace{}
 	for _, src := range sources.GetRIRSources() {
 		wg.Add(1)
 		go func(s sources.RIRSource) {
 			defer wg.Done()
-			dm.processRIRSource(s, &mu, &allRanges, &countryOnlyRanges)
+			dm.processRIRSource(s, &mu, &allRanges, &countryOnlyRanges, alloc)
 		}(src)
 	}
 	wg.Wait()
-	return allRanges, countryOnlyRanges
+	return allRanges, countryOnlyRanges, alloc
 }
 
-func (dm *DataManager) processRIRSource(src sources.RIRSource, mu *sync.Mutex, allRanges, countryOnlyRanges *[]ipRange) {
+func (dm *DataManager) processRIRSource(src sources.RIRSource, mu *sync.Mutex, allRanges, countryOnlyRanges *[]ipRange, alloc *allocatedSpace) {
 	rc, err := utils.GetCachedReader(src.URL, true, "[RIR-"+src.Name+"]")
 	if err != nil {
 		log.Printf("[RIR-%s] Error fetching data: %v", src.Name, err)
@@ -299,9 +323,20 @@ func (dm *DataManager) processRIRSource(src sources.RIRSource, mu *sync.Mutex, a
 
 	scanner := bufio.NewScanner(r)
 	count := 0
+	var allocIPv4, allocASNs []utils.Range
 	for scanner.Scan() {
 		parts := strings.Split(scanner.Text(), "|")
-		if len(parts) < 7 || parts[2] != "ipv4" {
+		if len(parts) < 7 {
+			continue
+		}
+		if rng, ok := parseAllocatedRange(parts); ok {
+			if parts[2] == "asn" {
+				allocASNs = append(allocASNs, rng)
+			} else {
+				allocIPv4 = append(allocIPv4, rng)
+			}
+		}
+		if parts[2] != "ipv4" {
 			continue
 		}
 		c, _ := strconv.ParseUint(parts[4], 10, 32)
@@ -316,7 +351,51 @@ func (dm *DataManager) processRIRSource(src sources.RIRSource, mu *sync.Mutex, a
 			count++
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("[RIR-%s] Error reading data: %v", src.Name, err)
+		return
+	}
 	log.Printf("[RIR-%s] Loaded %d ranges", src.Name, count)
+
+	mu.Lock()
+	alloc.ipv4 = append(alloc.ipv4, allocIPv4...)
+	alloc.asns = append(alloc.asns, allocASNs...)
+	alloc.sources++
+	mu.Unlock()
+}
+
+// parseAllocatedRange returns the IPv4 or ASN range of an allocated or assigned
+// record from a delegated stats file.
+func parseAllocatedRange(parts []string) (utils.Range, bool) {
+	if parts[6] != "allocated" && parts[6] != "assigned" {
+		return utils.Range{}, false
+	}
+	count, err := strconv.ParseUint(parts[4], 10, 32)
+	if err != nil || count == 0 {
+		return utils.Range{}, false
+	}
+	var start uint32
+	switch parts[2] {
+	case "ipv4":
+		ip := net.ParseIP(parts[3]).To4()
+		if ip == nil {
+			return utils.Range{}, false
+		}
+		start = binary.BigEndian.Uint32(ip)
+	case "asn":
+		asn, err := strconv.ParseUint(parts[3], 10, 32)
+		if err != nil {
+			return utils.Range{}, false
+		}
+		start = uint32(asn)
+	default:
+		return utils.Range{}, false
+	}
+	end := uint64(start) + count - 1
+	if end > 0xFFFFFFFF {
+		end = 0xFFFFFFFF
+	}
+	return utils.Range{Start: start, End: uint32(end)}, true
 }
 
 func (dm *DataManager) handleRIRRange(start, end uint32, cc string, priority int, mu *sync.Mutex, allRanges, countryOnlyRanges *[]ipRange) {
@@ -50,6 +50,23 @@ type GeoMetrics struct {
 	CacheResets  atomic.Uint64
 }
 
+// GeoMetricsSnapshot holds the lookup counters at a point in time. Each count is
+// a total since startup.
+type GeoMetricsSnapshot struct {
+	Cache       uint64 `json:"cache"`
+	Custom      uint64 `json:"custom"`
+	Cloud       uint64 `json:"cloud"`
+	MMDB        uint64 `json:"mmdb"`
+	RIR         uint64 `json:"rir"`
+	WHOIS       uint64 `json:"whois"`
+	Peering     uint64 `json:"peering"`
+	Hubs        uint64 `json:"hubs"`
+	Unknown     uint64 `json:"unknown"`
+	CacheResets uint64 `json:"cache_resets"`
+	// DiskTries holds the lookups of the hint databases that are open
+	DiskTries map[string]utils.DiskTrieStats `json:"disk_tries,omitempty"`
+}
+
 type ripeHint struct {
 	Lat, Lng float32
 	CC       string
@@ -79,6 +96,8 @@ type GeoService struct {
 	mmdbHints         *utils.DiskTrie
 	geoReaders        []*maxminddb.Reader
 	metrics           GeoMetrics
+	reportMu          sync.Mutex
+	lastReport        GeoMetricsSnapshot
 }
 
 type cityKey struct {
@@ -143,6 +162,17 @@ func (g *GeoService) OpenHintDBs(dir string, readOnly bool) error {
 		g.cloudHints, _ = utils.OpenDiskTrieReadOnly(cloudPath)
 		g.customHints, _ = utils.OpenDiskTrieReadOnly(customPath)
 		g.mmdbHints, _ = utils.OpenDiskTrieReadOnly(mmdbPath)
+
+		// The hint databases only change when bgp-cli fetch runs, so lookups are
+		// served from in-memory indexes with snapshots next to each database
+		for _, trie := range []*utils.DiskTrie{g.ripeHints, g.peeringHints, g.cloudHints, g.customHints, g.mmdbHints} {
+			if trie == nil {
+				continue
+			}
+			if err := trie.EnableIndex(strings.TrimSuffix(trie.Path(), ".db") + ".lpm"); err != nil {
+				log.Printf("Warning: Failed to index hints database: %v", err)
+			}
+		}
 	} else {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
 			return err
@@ -278,17 +308,57 @@ func (g *GeoService) SetCustomHintsCIDR(hints map[string]ripeHint) {
 	}
 }
 
+func (g *GeoService) Metrics() GeoMetricsSnapshot {
+	return GeoMetricsSnapshot{
+		Cache:       g.metrics.CacheHits.Load(),
+		Custom:      g.metrics.CustomHits.Load(),
+		Cloud:       g.metrics.CloudHits.Load(),
+		MMDB:        g.metrics.MMDBHits.Load(),
+		RIR:         g.metrics.RIRHits.Load(),
+		WHOIS:       g.metrics.WHOISHits.Load(),
+		Peering:     g.metrics.PeeringHits.Load(),
+		Hubs:        g.metrics.HubHits.Load(),
+		Unknown:     g.metrics.UnknownHits.Load(),
+		CacheResets: g.metrics.CacheResets.Load(),
+		DiskTries:   g.hintDBStats(),
+	}
+}
+
+// hintDBStats returns the lookup stats of the open hint databases by name.
+func (g *GeoService) hintDBStats() map[string]utils.DiskTrieStats {
+	stats := make(map[string]utils.DiskTrieStats)
+	for name, trie := range map[string]*utils.DiskTrie{
+		"ripe_hints":    g.ripeHints,
+		"peering_hints": g.peeringHints,
+		"cloud_hints":   g.cloudHints,
+		"custom_hints":  g.customHints,
+		"mmdb_hints":    g.mmdbHints,
+	} {
+		if trie != nil {
+			stats[name] = trie.Stats()
+		}
+	}
+	return stats
+}
+
+// ReportGeoMetrics logs the lookups since the previous report.
 func (g *GeoService) ReportGeoMetrics() {
-	cache := g.metrics.CacheHits.Swap(0)
-	custom := g.metrics.CustomHits.Swap(0)
-	cloud := g.metrics.CloudHits.Swap(0)
-	mmdb := g.metrics.MMDBHits.Swap(0)
-	rir := g.metrics.RIRHits.Swap(0)
-	whois := g.metrics.WHOISHits.Swap(0)
-	peering := g.metrics.PeeringHits.Swap(0)
-	hubs := g.metrics.HubHits.Swap(0)
-	unknown := g.metrics.UnknownHits.Swap(0)
-	resets := g.metrics.CacheResets.Swap(0)
+	g.reportMu.Lock()
+	cur := g.Metrics()
+	last := g.lastReport
+	g.lastReport = cur
+	g.reportMu.Unlock()
+
+	cache := cur.Cache - last.Cache
+	custom := cur.Custom - last.Custom
+	cloud := cur.Cloud - last.Cloud
+	mmdb := cur.MMDB - last.MMDB
+	rir := cur.RIR - last.RIR
+	whois := cur.WHOIS - last.WHOIS
+	peering := cur.Peering - last.Peering
+	hubs := cur.Hubs - last.Hubs
+	unknown := cur.Unknown - last.Unknown
+	resets := cur.CacheResets - last.CacheResets
 	total := custom + cloud + mmdb + rir + whois + peering + hubs + unknown
 
 	if total == 0 || unknown == 0 {
@@ -383,11 +453,48 @@ func (g *GeoService) incrementSourceMetric(resType ResolutionType) {
 	}
 }
 
+// resolutionStages are the sources resolveIP tries, in order. The first one
+// with coordinates wins; the country and city of the others are kept for the
+// final fallback.
+var resolutionStages = []struct {
+	resType ResolutionType
+	resolve func(g *GeoService, ip uint32) (lat, lng float64, cc, city string, ok bool)
+}{
+	// Stage 0: Custom Hints (Explicit overrides)
+	{ResCustom, func(g *GeoService, ip uint32) (float64, float64, string, string, bool) {
+		return g.resolveFromHints(g.customHints, ip)
+	}},
+	// Stage 1: MMDB Hints (Pre-processed MMDB files)
+	{ResMMDB, func(g *GeoService, ip uint32) (float64, float64, string, string, bool) {
+		return g.resolveFromHints(g.mmdbHints, ip)
+	}},
+	// Stage 2: Direct MMDB (Runtime loaded files)
+	{ResGeoIP, (*GeoService).resolveFromMMDBs},
+	// Stage 3: Cloud Trie (Highest priority, very specific)
+	{ResCloud, (*GeoService).resolveFromCloudTrie},
+	// Stage 4: PeeringDB Hints (Infrastructure)
+	{ResPeering, func(g *GeoService, ip uint32) (float64, float64, string, string, bool) {
+		return g.resolveFromHints(g.peeringHints, ip)
+	}},
+	// Stage 5: RIPE WHOIS hints (Background loaded)
+	{ResWHOIS, func(g *GeoService, ip uint32) (float64, float64, string, string, bool) {
+		return g.resolveFromHints(g.ripeHints, ip)
+	}},
+	// Stage 6: RIR-indexed data
+	{ResRIR, (*GeoService).resolveFromRIRInternal},
+	// Stage 7: RIR-indexed hub data (Country only)
+	{ResHubs, (*GeoService).resolveFromHubs},
+}
+
 func (g *GeoService) resolveIP(ip uint32) (lat, lng float64, countryCode, city string, resType ResolutionType) {
 	// Metadata holders for fallbacks
 	var bestCC, bestCity string
 
-	updateMetadata := func(cc, cty string) {
+	for _, stage := range resolutionStages {
+		lat, lng, cc, cty, ok := stage.resolve(g, ip)
+		if ok {
+			return lat, lng, cc, cty, stage.resType
+		}
 		if cc != "" && bestCC == "" {
 			bestCC = cc
 		}
@@ -396,67 +503,39 @@ func (g *GeoService) resolveIP(ip uint32) (lat, lng float64, countryCode, city s
 		}
 	}
 
-	// Stage 0: Custom Hints (Explicit overrides)
-	if lat, lng, cc, cty, ok := g.resolveFromHints(g.customHints, ip); ok {
-		return lat, lng, cc, cty, ResCustom
-	} else {
-		updateMetadata(cc, cty)
-	}
-
-	// Stage 1: MMDB Hints (Pre-processed MMDB files)
-	if lat, lng, cc, cty, ok := g.resolveFromHints(g.mmdbHints, ip); ok {
-		return lat, lng, cc, cty, ResMMDB
-	} else {
-		updateMetadata(cc, cty)
-	}
-
-	// Stage 2: Direct MMDB (Runtime loaded files)
-	if lat, lng, cc, cty, ok := g.resolveFromMMDBs(ip); ok {
-		return lat, lng, cc, cty, ResGeoIP
-	} else {
-		updateMetadata(cc, cty)
-	}
-
-	// Stage 3: Cloud Trie (Highest priority, very specific)
-	if lat, lng, cc, cty, ok := g.resolveFromCloudTrie(ip); ok {
-		return lat, lng, cc, cty, ResCloud
-	} else {
-		updateMetadata(cc, cty)
-	}
-
-	// Stage 4: PeeringDB Hints (Infrastructure)
-	if lat, lng, cc, cty, ok := g.resolveFromHints(g.peeringHints, ip); ok {
-		return lat, lng, cc, cty, ResPeering
-	} else {
-		updateMetadata(cc, cty)
-	}
-
-	// Stage 5: RIPE WHOIS hints (Background loaded)
-	if lat, lng, cc, cty, ok := g.resolveFromHints(g.ripeHints, ip); ok {
-		return lat, lng, cc, cty, ResWHOIS
-	} else {
-		updateMetadata(cc, cty)
-	}
+	lat2, lng2, cc2, city2, resT2 := g.resolveFinalFallback(ip, bestCC, bestCity)
+	return lat2, lng2, cc2, city2, resT2
+}
 
-	// Stage 6: RIR-indexed data
-	if lat, lng, cc, cty, ok := g.resolveFromRIRInternal(ip); ok {
-		return lat, lng, cc, cty, ResRIR
-	} else {
-		updateMetadata(cc, cty)
-	}
+// StageResult is what one resolution stage found for an IP.
+type StageResult struct {
+	ResType ResolutionType `json:"type"`
+	Lat     float64        `json:"lat"`
+	Lng     float64        `json:"lng"`
+	CC      string         `json:"country,omitempty"`
+	City    string         `json:"city,omitempty"`
+	// OK is set if the stage had coordinates, so resolution would stop there
+	OK bool `json:"ok"`
+}
 
-	// Stage 7: RIR-indexed hub data (Country only)
-	if cc, cty, ok := g.resolveFromHubsInternal(ip); ok {
-		if cty != "" && cc != "" {
-			if l, ln, _ := g.ResolveCityToCoords(cty, cc); l != 0 || ln != 0 {
-				return l, ln, cc, cty, ResHubs
-			}
+// ResolveStages runs every resolution stage for ip, bypassing the cache, and
+// returns what each found. The last result is the fallback used when no stage
+// has coordinates, from the first country and city the stages found.
+func (g *GeoService) ResolveStages(ip uint32) []StageResult {
+	results := make([]StageResult, 0, len(resolutionStages)+1)
+	var bestCC, bestCity string
+	for _, stage := range resolutionStages {
+		lat, lng, cc, cty, ok := stage.resolve(g, ip)
+		results = append(results, StageResult{ResType: stage.resType, Lat: lat, Lng: lng, CC: cc, City: cty, OK: ok})
+		if !ok && cc != "" && bestCC == "" {
+			bestCC = cc
+		}
+		if !ok && cty != "" && bestCity == "" {
+			bestCity = cty
 		}
-		updateMetadata(cc, cty)
 	}
-
-	lat2, lng2, cc2, city2, resT2 := g.resolveFinalFallback(ip, bestCC, bestCity)
-	return lat2, lng2, cc2, city2, resT2
+	lat, lng, cc, cty, resType := g.resolveFinalFallback(ip, bestCC, bestCity)
+	return append(results, StageResult{ResType: resType, Lat: lat, Lng: lng, CC: cc, City: cty, OK: lat != 0 || lng != 0})
 }
 
 func (g *GeoService) resolveFinalFallback(ip uint32, bestCC, bestCity string) (lat, lng float64, countryCode, city string, resType ResolutionType) {
@@ -658,6 +737,19 @@ func (g *GeoService) resolveFromRIRInternal(ip uint32) (lat, lng float64, cc, ci
 	return 0, 0, cc, city, false
 }
 
+// resolveFromHubs places an IP at the city of its hub data, if the city is
+// known.
+func (g *GeoService) resolveFromHubs(ip uint32) (lat, lng float64, cc, city string, ok bool) {
+	cc, city, ok = g.resolveFromHubsInternal(ip)
+	if !ok || cc == "" || city == "" {
+		return 0, 0, cc, city, false
+	}
+	if lat, lng, _ = g.ResolveCityToCoords(city, cc); lat != 0 || lng != 0 {
+		return lat, lng, cc, city, true
+	}
+	return 0, 0, cc, city, false
+}
+
 func (g *GeoService) resolveFromHubsInternal(ip uint32) (cc, city string, ok bool) {
 	loc := g.lookupHubIP(ip)
 	if loc == nil {
@@ -49,3 +49,39 @@ func TestGetIPCoords(t *testing.T) {
 		t.Errorf("Expected SF coordinates (37.7749, -122.4194), got (%f, %f)", lat, lng)
 	}
 }
+
+func TestResolveStages(t *testing.T) {
+	g := NewGeoService(1920, 1080, 380.0)
+	g.prefixData = PrefixData{
+		L: []Location{
+			{37.7749, -122.4194, "US", "San Francisco"},
+		},
+		R: []uint32{
+			0x08080800, 0, // 8.8.8.0/24 -> SF
+		},
+	}
+
+	stages := g.ResolveStages(0x08080808)
+	if len(stages) != len(resolutionStages)+1 {
+		t.Fatalf("expected a result per stage and the fallback, got %d", len(stages))
+	}
+	var first *StageResult
+	for i := range stages {
+		if stages[i].OK {
+			first = &stages[i]
+			break
+		}
+	}
+	if first == nil || first.ResType != ResRIR || first.CC != "US" || first.City != "San Francisco" {
+		t.Errorf("expected the RIR stage to resolve 8.8.8.8, got %+v", first)
+	}
+
+	lat, lng, cc, city, resType := g.GetIPCoords(0x08080808)
+	if lat != first.Lat || lng != first.Lng || cc != first.CC || city != first.City || resType != first.ResType {
+		t.Errorf("GetIPCoords = (%f, %f, %s, %s, %s); want the first resolving stage %+v", lat, lng, cc, city, resType, *first)
+	}
+
+	if last := stages[len(stages)-1]; last.ResType != ResUnknown || last.OK {
+		t.Errorf("expected an unknown fallback without any other country, got %+v", last)
+	}
+}
@@ -0,0 +1,265 @@
+// Package journal implements a durable, append-only record of classification
+// transitions, stored as one JSON lines file per UTC day.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// DefaultDir is where the viewer writes the journal unless configured otherwise.
+const DefaultDir = "./data/journal"
+
+const dayFormat = "2006-01-02"
+
+// Leak holds the classification-specific detail of an entry.
+type Leak struct {
+	Type           string  `json:"type,omitempty"`
+	LeakerASN      uint32  `json:"leaker_asn,omitempty"`
+	VictimASN      uint32  `json:"victim_asn,omitempty"`
+	Visibility     float64 `json:"visibility,omitempty"`
+	PeakVisibility float64 `json:"peak_visibility,omitempty"`
+	Reason         string  `json:"reason,omitempty"`
+}
+
+// Evidence holds what the classifier observed when the transition happened.
+type Evidence struct {
+	Peer           string  `json:"peer,omitempty"`
+	Host           string  `json:"host,omitempty"`
+	Path           string  `json:"path,omitempty"`
+	Communities    string  `json:"communities,omitempty"`
+	Messages       int32   `json:"messages"`
+	Announcements  int32   `json:"announcements"`
+	Withdrawals    int32   `json:"withdrawals"`
+	PathChanges    int32   `json:"path_changes"`
+	Peers          int     `json:"peers"`
+	Hosts          int     `json:"hosts"`
+	WithdrawnPeers int     `json:"withdrawn_peers"`
+	WithdrawnHosts int     `json:"withdrawn_hosts"`
+	Origins        int     `json:"origins"`
+	AnomalyScore   float64 `json:"anomaly_score,omitempty"`
+	RPKI           string  `json:"rpki,omitempty"`
+}
+
+// Entry is a single journal record. Type and PreviousType hold classification
+// keys as returned by bgp.ClassificationType.Key.
+type Entry struct {
+	Time          time.Time `json:"time"`
+	Prefix        string    `json:"prefix"`
+	Type          string    `json:"type"`
+	PreviousType  string    `json:"previous_type"`
+	Incident      bool      `json:"incident"`
+	OriginASN     uint32    `json:"origin_asn,omitempty"`
+	HistoricalASN uint32    `json:"historical_asn,omitempty"`
+	Country       string    `json:"country,omitempty"`
+	City          string    `json:"city,omitempty"`
+	Leak          *Leak     `json:"leak,omitempty"`
+	Evidence      Evidence  `json:"evidence"`
+}
+
+// NewEvidence converts the evidence of a transition or a stored prefix state.
+func NewEvidence(ev *bgp.Evidence) Evidence {
+	e := Evidence{
+		Peer:           ev.Peer,
+		Host:           ev.Host,
+		Path:           ev.Path,
+		Communities:    ev.Communities,
+		Messages:       ev.Messages,
+		Announcements:  ev.Announcements,
+		Withdrawals:    ev.Withdrawals,
+		PathChanges:    ev.PathChanges,
+		Peers:          ev.Peers,
+		Hosts:          ev.Hosts,
+		WithdrawnPeers: ev.WithdrawnPeers,
+		WithdrawnHosts: ev.WithdrawnHosts,
+		Origins:        ev.Origins,
+		AnomalyScore:   ev.AnomalyScore,
+	}
+	if ev.RPKIStatus != 0 {
+		e.RPKI = utils.RPKIStatus(ev.RPKIStatus).String()
+	}
+	return e
+}
+
+// NewEntry converts a classifier transition into a journal entry.
+func NewEntry(t *bgp.Transition) *Entry {
+	e := &Entry{
+		Time:          t.Time.UTC(),
+		Prefix:        t.Prefix,
+		Type:          t.To.Key(),
+		PreviousType:  t.From.Key(),
+		Incident:      t.To.IsCritical(),
+		OriginASN:     t.OriginASN,
+		HistoricalASN: t.HistoricalASN,
+		Country:       t.Country,
+		City:          t.City,
+		Evidence:      NewEvidence(&t.Evidence),
+	}
+	if ld := t.LeakDetail; ld != nil {
+		e.Leak = &Leak{
+			LeakerASN:      ld.LeakerASN,
+			VictimASN:      ld.VictimASN,
+			Visibility:     ld.Visibility,
+			PeakVisibility: ld.PeakVisibility,
+			Reason:         ld.Reason,
+		}
+		if ld.Type != bgp.LeakUnknown {
+			e.Leak.Type = ld.Type.String()
+		}
+	}
+	return e
+}
+
+// Classification returns the classification the prefix transitioned to.
+func (e *Entry) Classification() bgp.ClassificationType {
+	t, _ := bgp.ParseClassificationKey(e.Type)
+	return t
+}
+
+// PreviousClassification returns the classification the prefix transitioned from.
+func (e *Entry) PreviousClassification() bgp.ClassificationType {
+	t, _ := bgp.ParseClassificationKey(e.PreviousType)
+	return t
+}
+
+func segmentPath(dir, day string) string {
+	return filepath.Join(dir, "events-"+day+".jsonl")
+}
+
+// writerBuffer is how many entries a Writer holds while its file is busy.
+const writerBuffer = 10000
+
+// Writer appends entries to the segment for the UTC day of each entry. Entries
+// are queued and written by a single goroutine, so Append never blocks; when
+// the queue is full the entry is dropped and counted. It is safe for
+// concurrent use.
+type Writer struct {
+	dir     string
+	queue   chan *Entry
+	dropped atomic.Uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+
+	// Owned by the writing goroutine
+	day     string
+	f       *os.File
+	failing bool
+}
+
+func OpenWriter(dir string) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	w := &Writer{
+		dir:   dir,
+		queue: make(chan *Entry, writerBuffer),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Append queues e to be written as a single line to its day segment.
+func (w *Writer) Append(e *Entry) {
+	select {
+	case <-w.stop:
+		w.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case w.queue <- e:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many entries were lost because the queue was full or
+// the segment could not be written.
+func (w *Writer) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close writes the entries still queued and closes the segment. Entries
+// appended after Close are dropped.
+func (w *Writer) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+		if w.f != nil {
+			w.closeErr = w.f.Close()
+			w.f = nil
+		}
+	})
+	return w.closeErr
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	for {
+		select {
+		case e := <-w.queue:
+			w.write(e)
+		case <-w.stop:
+			for {
+				select {
+				case e := <-w.queue:
+					w.write(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *Writer) write(e *Entry) {
+	err := w.writeEntry(e)
+	switch {
+	case err != nil:
+		w.dropped.Add(1)
+		if !w.failing {
+			log.Printf("Warning: Failed to write event journal, dropping entries until it recovers: %v", err)
+			w.failing = true
+		}
+	case w.failing:
+		log.Println("[JOURNAL] Writing entries again")
+		w.failing = false
+	}
+}
+
+func (w *Writer) writeEntry(e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	day := e.Time.UTC().Format(dayFormat)
+	if w.f == nil || day != w.day {
+		if w.f != nil {
+			_ = w.f.Close()
+			w.f = nil
+		}
+		f, err := os.OpenFile(segmentPath(w.dir, day), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to open journal segment: %v", err)
+		}
+		w.f, w.day = f, day
+	}
+	_, err = w.f.Write(data)
+	return err
+}
@@ -0,0 +1,141 @@
+package journal
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+func TestJournal_AppendAndQuery(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWriter(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+
+	day1 := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Minute)
+	transitions := []*bgp.Transition{
+		{Time: day1, Prefix: "8.8.8.0/24", From: bgp.ClassificationNone, To: bgp.ClassificationHijack, OriginASN: 666, HistoricalASN: 15169, Country: "US"},
+		{Time: day2, Prefix: "8.8.8.128/25", From: bgp.ClassificationNone, To: bgp.ClassificationRouteLeak, OriginASN: 15169, Country: "US",
+			LeakDetail: &bgp.LeakDetail{Type: bgp.LeakHairpin, LeakerASN: 64500, VictimASN: 15169}},
+		{Time: day2.Add(time.Second), Prefix: "9.9.9.0/24", From: bgp.ClassificationOutage, To: bgp.ClassificationNone, OriginASN: 19281, Country: "CH"},
+		// Appended out of order, as concurrent workers may do
+		{Time: day1.Add(-time.Minute), Prefix: "8.8.0.0/16", From: bgp.ClassificationNone, To: bgp.ClassificationFlap, OriginASN: 15169, Country: "US"},
+	}
+	for _, tr := range transitions {
+		w.Append(NewEntry(tr))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 day segments, got %v", segments)
+	}
+
+	query := func(f Filter) []*Entry {
+		var res []*Entry
+		if err := Query(dir, f, func(e *Entry) error {
+			res = append(res, e)
+			return nil
+		}); err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+		return res
+	}
+
+	all := query(Filter{})
+	if len(all) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(all))
+	}
+	if all[0].Prefix != "8.8.0.0/16" || !all[1].Time.Equal(day1) {
+		t.Errorf("expected entries in time order, got %s then %s", all[0].Prefix, all[1].Prefix)
+	}
+	if !all[1].Incident || all[1].Classification() != bgp.ClassificationHijack {
+		t.Errorf("expected a hijack incident, got %+v", all[1])
+	}
+
+	covered := query(Filter{Prefix: netip.MustParsePrefix("8.8.8.0/24")})
+	if len(covered) != 2 {
+		t.Errorf("expected the prefix and its more-specific, got %d", len(covered))
+	}
+
+	leaker := query(Filter{ASN: 64500})
+	if len(leaker) != 1 || leaker[0].Leak == nil || leaker[0].Leak.Type != bgp.LeakHairpin.String() {
+		t.Errorf("expected the leak to match by leaker ASN, got %+v", leaker)
+	}
+
+	if res := query(Filter{Country: "ch"}); len(res) != 1 || res[0].PreviousClassification() != bgp.ClassificationOutage {
+		t.Errorf("expected one cleared outage in CH, got %+v", res)
+	}
+
+	if res := query(Filter{From: day2}); len(res) != 2 {
+		t.Errorf("expected 2 entries on the second day, got %d", len(res))
+	}
+
+	if res := query(Filter{Types: []bgp.ClassificationType{bgp.ClassificationHijack, bgp.ClassificationFlap}}); len(res) != 2 {
+		t.Errorf("expected 2 entries matching the type filter, got %d", len(res))
+	}
+}
+
+func TestJournal_SkipsTruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWriter(dir)
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	w.Append(NewEntry(&bgp.Transition{Time: now, Prefix: "1.1.1.0/24", To: bgp.ClassificationDiscovery}))
+	_ = w.Close()
+
+	f, err := os.OpenFile(segmentPath(dir, "2026-03-01"), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open segment: %v", err)
+	}
+	_, _ = f.WriteString(`{"time":"2026-03-01T12:00:01Z","pre`)
+	_ = f.Close()
+
+	count := 0
+	if err := Query(dir, Filter{}, func(*Entry) error { count++; return nil }); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected the truncated line to be skipped, got %d entries", count)
+	}
+}
+
+func TestJournal_DropsWhenFull(t *testing.T) {
+	dir := t.TempDir()
+	// The writing goroutine is started late, so the queue fills up
+	w := &Writer{dir: dir, queue: make(chan *Entry, 2), stop: make(chan struct{}), done: make(chan struct{})}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		w.Append(NewEntry(&bgp.Transition{Time: now.Add(time.Duration(i) * time.Second), Prefix: "1.1.1.0/24", To: bgp.ClassificationFlap}))
+	}
+	if w.Dropped() != 1 {
+		t.Errorf("expected 1 dropped entry, got %d", w.Dropped())
+	}
+
+	go w.run()
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	w.Append(NewEntry(&bgp.Transition{Time: now, Prefix: "1.1.1.0/24", To: bgp.ClassificationFlap}))
+	if w.Dropped() != 2 {
+		t.Errorf("expected entries appended after Close to be dropped, got %d dropped", w.Dropped())
+	}
+
+	count := 0
+	if err := Query(dir, Filter{}, func(*Entry) error { count++; return nil }); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected Close to write the queued entries, got %d", count)
+	}
+}
@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+// Filter selects journal entries. Zero values match everything.
+type Filter struct {
+	From time.Time
+	To   time.Time
+	// Prefix matches entries for the prefix itself and any more-specific it covers.
+	Prefix netip.Prefix
+	// ASN matches the origin, historical origin, leaker or victim.
+	ASN     uint32
+	Country string
+	Types   []bgp.ClassificationType
+}
+
+// Match reports whether e satisfies every criterion of the filter.
+func (f *Filter) Match(e *Entry) bool {
+	if !f.From.IsZero() && e.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.Time.Before(f.To) {
+		return false
+	}
+	if f.Prefix.IsValid() {
+		p, err := netip.ParsePrefix(e.Prefix)
+		if err != nil || p.Addr().Is4() != f.Prefix.Addr().Is4() {
+			return false
+		}
+		if p.Bits() < f.Prefix.Bits() || !f.Prefix.Masked().Contains(p.Addr()) {
+			return false
+		}
+	}
+	if f.ASN != 0 && !e.involvesASN(f.ASN) {
+		return false
+	}
+	if f.Country != "" && !strings.EqualFold(f.Country, e.Country) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		t := e.Classification()
+		found := false
+		for _, want := range f.Types {
+			if t == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Entry) involvesASN(asn uint32) bool {
+	if e.OriginASN == asn || e.HistoricalASN == asn {
+		return true
+	}
+	return e.Leak != nil && (e.Leak.LeakerASN == asn || e.Leak.VictimASN == asn)
+}
+
+// Segments returns the day segments in dir that may hold entries between from
+// and to, oldest first.
+func Segments(dir string, from, to time.Time) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var res []string
+	for _, path := range paths {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "events-"), ".jsonl")
+		day, err := time.Parse(dayFormat, name)
+		if err != nil {
+			continue
+		}
+		if !from.IsZero() && day.Add(24*time.Hour).Before(from.UTC()) {
+			continue
+		}
+		if !to.IsZero() && !day.Before(to.UTC()) {
+			continue
+		}
+		res = append(res, path)
+	}
+	return res, nil
+}
+
+// Query calls fn for every entry in dir matching f, in time order. Lines that
+// cannot be parsed, such as one cut short by a crash, are skipped.
+func Query(dir string, f Filter, fn func(e *Entry) error) error {
+	segments, err := Segments(dir, f.From, f.To)
+	if err != nil {
+		return err
+	}
+	for _, path := range segments {
+		entries, err := readSegment(path, &f)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readSegment(path string, f *Filter) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal segment: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		e := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			log.Printf("Warning: skipping malformed journal entry %s:%d: %v", path, line, err)
+			continue
+		}
+		if f.Match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal segment %s: %v", path, err)
+	}
+
+	// Workers append concurrently, so lines are only approximately ordered
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
@@ -0,0 +1,163 @@
+// Package mrtfile reads archived MRT files, such as the update files and RIB
+// dumps of RIS and RouteViews collectors, and turns their BGP messages into
+// the context the classifier works on.
+package mrtfile
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/mrt"
+	bgp_pkg "github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+// Open opens an MRT file that is gzip or bzip2 compressed, or not at all.
+func Open(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("error opening gzip: %v", err)
+		}
+		return readCloser{gz, func() error { _ = gz.Close(); return f.Close() }}, nil
+	case bytes.Equal(magic, []byte("BZh")):
+		return readCloser{bzip2.NewReader(br), f.Close}, nil
+	default:
+		return readCloser{br, f.Close}, nil
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// Scan passes every record of an MRT file to fn. Records that fail to parse
+// are skipped; a truncated file ends the scan. name identifies the file in
+// logs.
+func Scan(name, path string, fn func(msg *mrt.MRTMessage)) {
+	r, err := Open(path)
+	if err != nil {
+		log.Printf("Error opening %s: %v", path, err)
+		return
+	}
+	defer func() { _ = r.Close() }()
+
+	for {
+		header := make([]byte, mrt.MRT_COMMON_HEADER_LEN)
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error reading MRT header from %s: %v", name, err)
+			break
+		}
+
+		h := &mrt.MRTHeader{}
+		if err := h.DecodeFromBytes(header); err != nil {
+			log.Printf("Error decoding MRT header from %s: %v", name, err)
+			break
+		}
+
+		body := make([]byte, h.Len)
+		if _, err := io.ReadFull(r, body); err != nil {
+			log.Printf("Error reading MRT body from %s: %v", name, err)
+			break
+		}
+
+		msg, err := mrt.ParseMRTBody(h, body)
+		if err != nil {
+			continue
+		}
+		fn(msg)
+	}
+}
+
+// Message is a BGP message received by a collector from one of its peers.
+// Peers are identified by their ASN.
+type Message struct {
+	Timestamp time.Time
+	Collector string
+	Peer      string
+	Message   *bgp.BGPMessage
+}
+
+// ScanMessages passes the BGP messages recorded in a BGP4MP update file to fn.
+func ScanMessages(collector, path string, fn func(msg *Message)) {
+	Scan(collector, path, func(msg *mrt.MRTMessage) {
+		if msg.Header.Type != mrt.BGP4MP && msg.Header.Type != mrt.BGP4MP_ET {
+			return
+		}
+		subtype := mrt.MRTSubTypeBGP4MP(msg.Header.SubType)
+		if subtype != mrt.MESSAGE && subtype != mrt.MESSAGE_AS4 &&
+			subtype != mrt.MESSAGE_LOCAL && subtype != mrt.MESSAGE_AS4_LOCAL {
+			return
+		}
+		bgp4mp := msg.Body.(*mrt.BGP4MPMessage)
+		fn(&Message{
+			Timestamp: msg.Header.GetTime(),
+			Collector: collector,
+			Peer:      fmt.Sprintf("%d", bgp4mp.PeerAS),
+			Message:   bgp4mp.BGPMessage,
+		})
+	})
+}
+
+// Context describes a message from msg's peer with the given path attributes.
+func Context(msg *Message, attrs []bgp.PathAttributeInterface) *bgp_pkg.MessageContext {
+	ctx := &bgp_pkg.MessageContext{
+		Peer: msg.Peer,
+		Host: msg.Collector,
+		Now:  msg.Timestamp,
+	}
+
+	for _, attr := range attrs {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeAsPath:
+			ctx.PathLen = 0
+			var asns []string
+			for _, param := range a.Value {
+				for _, asn := range param.GetAS() {
+					asns = append(asns, fmt.Sprintf("%d", asn))
+					ctx.PathLen++
+					ctx.OriginASN = asn
+				}
+			}
+			ctx.PathStr = "[" + strings.Join(asns, " ") + "]"
+		case *bgp.PathAttributeNextHop:
+			ctx.NextHop = a.Value.String()
+		case *bgp.PathAttributeAggregator:
+			ctx.Aggregator = fmt.Sprintf("AS%d:%s", a.Value.AS, a.Value.Address.String())
+		case *bgp.PathAttributeMultiExitDisc:
+			ctx.Med = int32(a.Value)
+		case *bgp.PathAttributeLocalPref:
+			ctx.LocalPref = int32(a.Value)
+		case *bgp.PathAttributeCommunities:
+			var comms []string
+			for _, c := range a.Value {
+				comms = append(comms, fmt.Sprintf("%d:%d", (c>>16)&0xffff, c&0xffff))
+			}
+			ctx.CommStr = "[" + strings.Join(comms, " ") + "]"
+		}
+	}
+	return ctx
+}
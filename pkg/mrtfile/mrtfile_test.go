@@ -0,0 +1,139 @@
+package mrtfile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/mrt"
+)
+
+var testTime = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func updateRecord(t *testing.T, peerAS uint32, ts time.Time) []byte {
+	t.Helper()
+	update := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{peerAS, 64501})}),
+		bgp.NewPathAttributeNextHop("192.0.2.1"),
+	}, []*bgp.IPAddrPrefix{bgp.NewIPAddrPrefix(24, "198.51.100.0")})
+	body := mrt.NewBGP4MPMessage(peerAS, 64999, 0, "192.0.2.1", "192.0.2.2", true, update)
+	msg, err := mrt.NewMRTMessage(uint32(ts.Unix()), mrt.BGP4MP, mrt.MESSAGE_AS4, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func peerIndexRecord(t *testing.T) []byte {
+	t.Helper()
+	body := mrt.NewPeerIndexTable("192.0.2.254", "rrc00", []*mrt.Peer{mrt.NewPeer("192.0.2.1", "192.0.2.1", 64500, true)})
+	msg, err := mrt.NewMRTMessage(uint32(testTime.Unix()), mrt.TABLE_DUMPv2, mrt.PEER_INDEX_TABLE, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeFile(t *testing.T, name string, data []byte, compress bool) string {
+	t.Helper()
+	if compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data = buf.Bytes()
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScanMessages(t *testing.T) {
+	var data []byte
+	data = append(data, peerIndexRecord(t)...)
+	data = append(data, updateRecord(t, 64500, testTime)...)
+	data = append(data, updateRecord(t, 64510, testTime.Add(time.Minute))...)
+
+	for _, compress := range []bool{false, true} {
+		path := writeFile(t, "updates.20240301.1000", data, compress)
+		var msgs []*Message
+		ScanMessages("rrc00", path, func(msg *Message) { msgs = append(msgs, msg) })
+
+		// The peer index table is not a BGP4MP message
+		if len(msgs) != 2 {
+			t.Fatalf("compressed %v: expected 2 messages, got %d", compress, len(msgs))
+		}
+		if m := msgs[1]; m.Collector != "rrc00" || m.Peer != "64510" || !m.Timestamp.Equal(testTime.Add(time.Minute)) {
+			t.Errorf("compressed %v: message = (%s, %s, %v)", compress, m.Collector, m.Peer, m.Timestamp)
+		}
+		if _, ok := msgs[0].Message.Body.(*bgp.BGPUpdate); !ok {
+			t.Errorf("compressed %v: expected an update, got %T", compress, msgs[0].Message.Body)
+		}
+	}
+}
+
+func TestScan_Truncated(t *testing.T) {
+	record := updateRecord(t, 64500, testTime)
+	data := append(append([]byte{}, record...), record[:len(record)-5]...)
+	path := writeFile(t, "updates.20240301.1000", data, false)
+
+	n := 0
+	Scan("rrc00", path, func(msg *mrt.MRTMessage) { n++ })
+	if n != 1 {
+		t.Errorf("expected the records before the truncation, got %d", n)
+	}
+
+	n = 0
+	Scan("rrc00", filepath.Join(t.TempDir(), "missing"), func(msg *mrt.MRTMessage) { n++ })
+	if n != 0 {
+		t.Errorf("expected no records from a missing file, got %d", n)
+	}
+}
+
+func TestContext(t *testing.T) {
+	msg := &Message{Timestamp: testTime, Collector: "rrc00", Peer: "64500"}
+	attrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+			bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{64500, 64501}),
+			bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SET, []uint32{64502}),
+		}),
+		bgp.NewPathAttributeNextHop("192.0.2.1"),
+		bgp.NewPathAttributeAggregator(uint32(64502), "192.0.2.9"),
+		bgp.NewPathAttributeMultiExitDisc(10),
+		bgp.NewPathAttributeLocalPref(200),
+		bgp.NewPathAttributeCommunities([]uint32{65535<<16 | 666, 64500<<16 | 1}),
+	}
+	ctx := Context(msg, attrs)
+
+	if ctx.Peer != "64500" || ctx.Host != "rrc00" || !ctx.Now.Equal(testTime) {
+		t.Errorf("session = (%s, %s, %v)", ctx.Peer, ctx.Host, ctx.Now)
+	}
+	if ctx.PathStr != "[64500 64501 64502]" || ctx.PathLen != 3 || ctx.OriginASN != 64502 {
+		t.Errorf("path = (%s, %d, AS%d), want ([64500 64501 64502], 3, AS64502)", ctx.PathStr, ctx.PathLen, ctx.OriginASN)
+	}
+	if ctx.NextHop != "192.0.2.1" || ctx.Aggregator != "AS64502:192.0.2.9" || ctx.Med != 10 || ctx.LocalPref != 200 {
+		t.Errorf("attributes = (%s, %s, %d, %d)", ctx.NextHop, ctx.Aggregator, ctx.Med, ctx.LocalPref)
+	}
+	if ctx.CommStr != "[65535:666 64500:1]" {
+		t.Errorf("communities = %s, want [65535:666 64500:1]", ctx.CommStr)
+	}
+}
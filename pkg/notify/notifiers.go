@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Webhook posts the message payload as JSON.
+type Webhook struct {
+	name    string
+	URL     string
+	Headers map[string]string
+}
+
+func (w *Webhook) Name() string { return w.name }
+
+func (w *Webhook) Notify(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return postJSON(ctx, w.URL, w.Headers, body)
+}
+
+// Slack posts the message text to a Slack-compatible incoming webhook, which
+// Mattermost, Rocket.Chat and Discord (with /slack appended) also accept.
+type Slack struct {
+	name string
+	URL  string
+}
+
+func (s *Slack) Name() string { return s.name }
+
+func (s *Slack) Notify(ctx context.Context, msg *Message) error {
+	text := "*" + msg.Subject + "*"
+	if msg.Text != "" {
+		text += "\n" + msg.Text
+	}
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.URL, nil, body)
+}
+
+func postJSON(ctx context.Context, url string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bgp-stream/1.0")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// SMTP sends the message as a plain-text email. STARTTLS is used when the
+// server offers it, and authentication only when Username is set.
+type SMTP struct {
+	name     string
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (s *SMTP) Name() string { return s.name }
+
+func (s *SMTP) Notify(ctx context.Context, msg *Message) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return fmt.Errorf("authentication failed: %v", err)
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.format(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *SMTP) format(msg *Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.ReplaceAll(msg.Subject, "\n", " "))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	text := msg.Text
+	if text == "" {
+		text = msg.Subject
+	}
+	b.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// Syslog sends the message subject and JSON payload to a syslog server in the
+// RFC 5424 format, over UDP or over TCP or TLS with octet-counted framing.
+type Syslog struct {
+	name    string
+	Network string
+	Addr    string
+}
+
+// syslogPriority is facility user with severity warning.
+const syslogPriority = 1*8 + 4
+
+func (s *Syslog) Name() string { return s.name }
+
+func (s *Syslog) Notify(ctx context.Context, msg *Message) error {
+	line, err := s.format(msg)
+	if err != nil {
+		return err
+	}
+	var conn net.Conn
+	switch s.Network {
+	case "tls":
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		d := tls.Dialer{Config: &tls.Config{ServerName: host}}
+		conn, err = d.DialContext(ctx, "tcp", s.Addr)
+		if err != nil {
+			return err
+		}
+	default:
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, s.Network, s.Addr)
+		if err != nil {
+			return err
+		}
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if s.Network != "udp" {
+		line = append([]byte(fmt.Sprintf("%d ", len(line))), line...)
+	}
+	_, err = conn.Write(line)
+	return err
+}
+
+func (s *Syslog) format(msg *Message) ([]byte, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	text := strings.ReplaceAll(msg.Subject, "\n", " ")
+	if msg.Payload != nil {
+		body, err := json.Marshal(msg.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode payload: %v", err)
+		}
+		text += " " + string(body)
+	}
+	return fmt.Appendf(nil, "<%d>1 %s %s bgp-stream %d alert - %s",
+		syslogPriority, time.Now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"), hostname, os.Getpid(), text), nil
+}
+
+// File appends the message payload to a file as a line of JSON.
+type File struct {
+	name string
+	Path string
+}
+
+func (f *File) Name() string { return f.name }
+
+func (f *File) Notify(_ context.Context, msg *Message) error {
+	payload := msg.Payload
+	if payload == nil {
+		payload = map[string]string{"subject": msg.Subject, "text": msg.Text}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	out, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(append(body, '\n')); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Script runs a local program for every message. The payload is written to its
+// standard input as JSON, and the subject and text are passed in the
+// NOTIFY_SUBJECT and NOTIFY_TEXT environment variables.
+type Script struct {
+	name string
+	Path string
+	Args []string
+}
+
+func (s *Script) Name() string { return s.name }
+
+func (s *Script) Notify(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(msg.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	cmd := exec.CommandContext(ctx, s.Path, s.Args...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "NOTIFY_SUBJECT="+msg.Subject, "NOTIFY_TEXT="+msg.Text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", s.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package notify delivers alerts to people and other systems: JSON webhooks,
+// Slack-compatible webhooks, email, syslog, files and local scripts.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Message is one alert. Subject is a one-line summary and Text a longer
+// human-readable description; Payload is sent as JSON to webhooks and scripts.
+type Message struct {
+	Subject string
+	Text    string
+	Payload any
+}
+
+// Notifier delivers messages to one destination.
+type Notifier interface {
+	// Name identifies the notifier in logs
+	Name() string
+	Notify(ctx context.Context, msg *Message) error
+}
+
+// Config describes a notifier. Type is one of webhook, slack, smtp, syslog,
+// file and script; the other fields apply to the types noted next to them.
+type Config struct {
+	Type string `json:"type"`
+	// Name identifies the notifier in logs and defaults to Type
+	Name string `json:"name,omitempty"`
+
+	// webhook, slack
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// smtp, syslog. The smtp password is read from the environment variable
+	// named by PasswordEnv so it stays out of the file.
+	Addr        string   `json:"addr,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+
+	// syslog: udp (the default), tcp or tls
+	Network string `json:"network,omitempty"`
+
+	// file, script
+	Path string   `json:"path,omitempty"`
+	Args []string `json:"args,omitempty"`
+}
+
+// New creates the notifier described by c.
+func New(c Config) (Notifier, error) {
+	name := c.Name
+	if name == "" {
+		name = c.Type
+	}
+	switch c.Type {
+	case "webhook":
+		if c.URL == "" {
+			return nil, fmt.Errorf("%s: url is required", name)
+		}
+		return &Webhook{name: name, URL: c.URL, Headers: c.Headers}, nil
+	case "slack":
+		if c.URL == "" {
+			return nil, fmt.Errorf("%s: url is required", name)
+		}
+		return &Slack{name: name, URL: c.URL}, nil
+	case "smtp":
+		if c.Addr == "" || c.From == "" || len(c.To) == 0 {
+			return nil, fmt.Errorf("%s: addr, from and to are required", name)
+		}
+		s := &SMTP{name: name, Addr: c.Addr, From: c.From, To: c.To, Username: c.Username}
+		if c.PasswordEnv != "" {
+			s.Password = os.Getenv(c.PasswordEnv)
+		}
+		return s, nil
+	case "syslog":
+		if c.Addr == "" {
+			return nil, fmt.Errorf("%s: addr is required", name)
+		}
+		network := c.Network
+		if network == "" {
+			network = "udp"
+		}
+		if network != "udp" && network != "tcp" && network != "tls" {
+			return nil, fmt.Errorf("%s: unknown network %q", name, c.Network)
+		}
+		return &Syslog{name: name, Network: network, Addr: c.Addr}, nil
+	case "file":
+		if c.Path == "" {
+			return nil, fmt.Errorf("%s: path is required", name)
+		}
+		return &File{name: name, Path: c.Path}, nil
+	case "script":
+		if c.Path == "" {
+			return nil, fmt.Errorf("%s: path is required", name)
+		}
+		return &Script{name: name, Path: c.Path, Args: c.Args}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", c.Type)
+	}
+}
+
+// dispatcherTimeout bounds a single delivery attempt.
+const dispatcherTimeout = 30 * time.Second
+
+// Dispatcher delivers messages to a set of notifiers in the background, so the
+// caller never waits on the network. Messages are dropped when the queue is
+// full.
+type Dispatcher struct {
+	notifiers []Notifier
+	queue     chan dispatch
+}
+
+type dispatch struct {
+	msg       *Message
+	notifiers []Notifier
+}
+
+// NewDispatcher creates a dispatcher that queues up to buffer messages.
+func NewDispatcher(notifiers []Notifier, buffer int) *Dispatcher {
+	return &Dispatcher{notifiers: notifiers, queue: make(chan dispatch, buffer)}
+}
+
+// Send queues msg for the dispatcher's notifiers and reports whether there was
+// room for it.
+func (d *Dispatcher) Send(msg *Message) bool {
+	return d.SendTo(d.notifiers, msg)
+}
+
+// SendTo queues msg for notifiers instead of the dispatcher's own and reports
+// whether there was room for it.
+func (d *Dispatcher) SendTo(notifiers []Notifier, msg *Message) bool {
+	select {
+	case d.queue <- dispatch{msg: msg, notifiers: notifiers}:
+		return true
+	default:
+		log.Printf("Warning: notification queue full, dropping %q", msg.Subject)
+		return false
+	}
+}
+
+// Run delivers queued messages until ctx is canceled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-d.queue:
+			for _, n := range q.notifiers {
+				sendCtx, cancel := context.WithTimeout(ctx, dispatcherTimeout)
+				if err := n.Notify(sendCtx, q.msg); err != nil {
+					log.Printf("Warning: %s notification failed: %v", n.Name(), err)
+				}
+				cancel()
+			}
+		}
+	}
+}
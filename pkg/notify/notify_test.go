@@ -0,0 +1,313 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMessage = &Message{
+	Subject: "Origin change for 192.0.2.0/24",
+	Text:    "192.0.2.0/24 is announced by AS64666\nexpected AS64500",
+	Payload: map[string]any{"kind": "origin_change", "prefix": "192.0.2.0/24"},
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n, err := New(Config{Type: "webhook", URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if got["kind"] != "origin_change" || auth != "Bearer x" {
+		t.Errorf("unexpected request: %v (auth %q)", got, auth)
+	}
+}
+
+func TestWebhook_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	n, _ := New(Config{Type: "webhook", URL: srv.URL})
+	if err := n.Notify(context.Background(), testMessage); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("expected the response to be reported, got %v", err)
+	}
+}
+
+func TestSlack(t *testing.T) {
+	var got struct {
+		Text string `json:"text"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n, _ := New(Config{Type: "slack", URL: srv.URL})
+	if err := n.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "*Origin change for 192.0.2.0/24*\n"+testMessage.Text {
+		t.Errorf("unexpected text %q", got.Text)
+	}
+}
+
+// smtpStub accepts a single message and sends its envelope and data on the
+// returned channel.
+func smtpStub(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	ch := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		var got strings.Builder
+		reply := func(s string) { _, _ = fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 stub")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 stub")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				got.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					got.WriteString(l)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				ch <- got.String()
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestSMTP(t *testing.T) {
+	addr, ch := smtpStub(t)
+	n, err := New(Config{Type: "smtp", Addr: addr, From: "bgp@example.com", To: []string{"noc@example.com", "oncall@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := n.Notify(ctx, testMessage); err != nil {
+		t.Fatal(err)
+	}
+	got := <-ch
+	for _, want := range []string{
+		"MAIL FROM:<bgp@example.com>",
+		"RCPT TO:<oncall@example.com>",
+		"Subject: Origin change for 192.0.2.0/24\r\n",
+		"is announced by AS64666\r\nexpected AS64500",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert")
+	n, err := New(Config{Type: "script", Path: "/bin/sh", Args: []string{"-c", `cat > "$0"; echo "$NOTIFY_SUBJECT" >> "$0"`, out}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"kind":"origin_change","prefix":"192.0.2.0/24"}` + testMessage.Subject + "\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+
+	failing, _ := New(Config{Type: "script", Path: "/bin/sh", Args: []string{"-c", "echo broken >&2; exit 3"}})
+	if err := failing.Notify(context.Background(), testMessage); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the script output in the error, got %v", err)
+	}
+}
+
+func TestSyslog(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pc.Close() }()
+	n, err := New(Config{Type: "syslog", Addr: pc.LocalAddr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(buf[:size])
+	if !strings.HasPrefix(got, "<12>1 ") || !strings.Contains(got, " bgp-stream ") || !strings.HasSuffix(got, testMessage.Subject+` {"kind":"origin_change","prefix":"192.0.2.0/24"}`) {
+		t.Errorf("unexpected syslog message %q", got)
+	}
+
+	// Stream transports prefix each message with its length
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		b, _ := io.ReadAll(conn)
+		received <- string(b)
+	}()
+	n, _ = New(Config{Type: "syslog", Network: "tcp", Addr: ln.Addr().String()})
+	if err := n.Notify(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-received:
+		length, rest, _ := strings.Cut(got, " ")
+		if length != fmt.Sprint(len(rest)) || !strings.HasPrefix(rest, "<12>1 ") {
+			t.Errorf("unexpected framing %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.jsonl")
+	n, err := New(Config{Type: "file", Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := n.Notify(context.Background(), testMessage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `{"kind":"origin_change","prefix":"192.0.2.0/24"}` + "\n"
+	if string(b) != line+line {
+		t.Errorf("unexpected file contents %q", b)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, c := range []Config{
+		{Type: "webhook"},
+		{Type: "smtp", Addr: "localhost:25"},
+		{Type: "syslog"},
+		{Type: "syslog", Addr: "localhost:514", Network: "unix"},
+		{Type: "file"},
+		{Type: "script"},
+		{Type: "pager"},
+	} {
+		if _, err := New(c); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+}
+
+type recorder struct {
+	got chan *Message
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Notify(ctx context.Context, msg *Message) error {
+	r.got <- msg
+	return io.EOF
+}
+
+func TestDispatcher(t *testing.T) {
+	rec := &recorder{got: make(chan *Message, 1)}
+	d := NewDispatcher([]Notifier{rec}, 1)
+	if !d.Send(testMessage) || d.Send(testMessage) {
+		t.Fatal("expected the second message to be dropped")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+	select {
+	case msg := <-rec.got:
+		if msg != testMessage {
+			t.Errorf("unexpected message %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	other := &recorder{got: make(chan *Message, 1)}
+	d.SendTo([]Notifier{other}, testMessage)
+	select {
+	case <-other.got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not delivered to the given notifier")
+	}
+	select {
+	case <-rec.got:
+		t.Error("expected SendTo to skip the dispatcher's notifiers")
+	default:
+	}
+}
@@ -0,0 +1,159 @@
+// Package rislive re-broadcasts processed RIS Live messages over a websocket
+// that speaks the RIS Live dialect, with each message enriched by what the
+// processor made of its prefixes.
+package rislive
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// Message is the envelope of every websocket message, in both directions.
+type Message struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Data is a RIS Live message with an Enrichment added.
+type Data struct {
+	Timestamp     float64               `json:"timestamp"`
+	Peer          string                `json:"peer"`
+	PeerASN       string                `json:"peer_asn"`
+	ID            string                `json:"id"`
+	Host          string                `json:"host"`
+	Type          string                `json:"type"`
+	Path          []json.RawMessage     `json:"path,omitempty"`
+	Community     [][]interface{}       `json:"community,omitempty"`
+	Origin        string                `json:"origin,omitempty"`
+	Med           int32                 `json:"med,omitempty"`
+	Aggregator    string                `json:"aggregator,omitempty"`
+	Announcements []bgp.RISAnnouncement `json:"announcements,omitempty"`
+	Withdrawals   []string              `json:"withdrawals,omitempty"`
+	Enrichment    Enrichment            `json:"enrichment"`
+}
+
+// Enrichment holds the processor's view of a message. Prefixes is keyed by
+// prefix and ASNames by ASN, for every known ASN in the path, the origins and
+// the leak detail.
+type Enrichment struct {
+	Prefixes map[string]*PrefixEnrichment `json:"prefixes"`
+	ASNames  map[string]string            `json:"as_names,omitempty"`
+}
+
+// PrefixEnrichment describes one prefix of a message. EventType is one of new,
+// upd, with and gossip, and Classification a key as returned by
+// bgp.ClassificationType.Key.
+type PrefixEnrichment struct {
+	EventType           string `json:"event_type"`
+	Classification      string `json:"classification"`
+	ClassificationName  string `json:"classification_name,omitempty"`
+	OriginASN           uint32 `json:"origin_asn,omitempty"`
+	HistoricalOriginASN uint32 `json:"historical_origin_asn,omitempty"`
+	RPKI                string `json:"rpki,omitempty"`
+	Geo                 *Geo   `json:"geo,omitempty"`
+	Leak                *Leak  `json:"leak,omitempty"`
+}
+
+// Geo is where a prefix was located and which source resolved it.
+type Geo struct {
+	Lat        float64 `json:"lat"`
+	Lng        float64 `json:"lng"`
+	Country    string  `json:"country"`
+	City       string  `json:"city,omitempty"`
+	Resolution string  `json:"resolution"`
+}
+
+// Leak holds the classification-specific detail of a prefix.
+type Leak struct {
+	Type           string  `json:"type,omitempty"`
+	LeakerASN      uint32  `json:"leaker_asn,omitempty"`
+	VictimASN      uint32  `json:"victim_asn,omitempty"`
+	Visibility     float64 `json:"visibility,omitempty"`
+	PeakVisibility float64 `json:"peak_visibility,omitempty"`
+	Reason         string  `json:"reason,omitempty"`
+}
+
+// NewData converts an enriched processor message. asnMapping may be nil, in
+// which case no names are added.
+func NewData(m *bgp.EnrichedMessage, asnMapping *utils.ASNMapping) *Data {
+	d := m.Data
+	out := &Data{
+		Timestamp:     d.Timestamp,
+		Peer:          d.Peer,
+		PeerASN:       d.PeerASN,
+		ID:            d.ID,
+		Host:          d.Host,
+		Type:          d.Type,
+		Path:          d.Path,
+		Community:     d.Community,
+		Origin:        d.Origin,
+		Med:           d.Med,
+		Aggregator:    d.Aggregator,
+		Announcements: d.Announcements,
+		Withdrawals:   d.Withdrawals,
+		Enrichment:    Enrichment{Prefixes: make(map[string]*PrefixEnrichment, len(m.Prefixes))},
+	}
+
+	asns := pathASNs(d)
+	if asn, err := strconv.ParseUint(d.PeerASN, 10, 32); err == nil {
+		asns = append(asns, uint32(asn))
+	}
+	for i := range m.Prefixes {
+		p := &m.Prefixes[i]
+		pe := &PrefixEnrichment{
+			EventType:           p.EventType.String(),
+			Classification:      p.ClassificationType.Key(),
+			OriginASN:           p.ASN,
+			HistoricalOriginASN: p.HistoricalASN,
+		}
+		if p.ClassificationType != bgp.ClassificationNone {
+			pe.ClassificationName = p.ClassificationType.String()
+		}
+		if p.RPKIStatus != utils.RPKIUnknown {
+			pe.RPKI = p.RPKIStatus.String()
+		}
+		if p.Country != "" {
+			pe.Geo = &Geo{Lat: p.Lat, Lng: p.Lng, Country: p.Country, City: p.City, Resolution: string(p.Resolution)}
+		}
+		if ld := p.LeakDetail; ld != nil {
+			pe.Leak = &Leak{
+				LeakerASN:      ld.LeakerASN,
+				VictimASN:      ld.VictimASN,
+				Visibility:     ld.Visibility,
+				PeakVisibility: ld.PeakVisibility,
+				Reason:         ld.Reason,
+			}
+			if ld.Type != bgp.LeakUnknown {
+				pe.Leak.Type = ld.Type.String()
+			}
+			asns = append(asns, ld.LeakerASN, ld.VictimASN)
+		}
+		asns = append(asns, p.ASN, p.HistoricalASN)
+		out.Enrichment.Prefixes[p.Prefix] = pe
+	}
+
+	for _, asn := range asns {
+		name := api.NetworkName(asnMapping, asn)
+		if name == "" {
+			continue
+		}
+		if out.Enrichment.ASNames == nil {
+			out.Enrichment.ASNames = make(map[string]string)
+		}
+		out.Enrichment.ASNames[strconv.FormatUint(uint64(asn), 10)] = name
+	}
+	return out
+}
+
+// pathASNs flattens an AS path, including the members of AS sets.
+func pathASNs(d *bgp.RISMessageData) []uint32 {
+	var asns []uint32
+	for _, hop := range d.Hops() {
+		asns = append(asns, hop...)
+	}
+	return asns
+}
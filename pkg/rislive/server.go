@@ -0,0 +1,287 @@
+package rislive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// Path is where the websocket is served, matching RIS Live.
+const Path = "/v1/ws/"
+
+const (
+	// clientBuffer is how many messages may queue for a client before new ones
+	// are dropped
+	clientBuffer = 1024
+	writeWait    = 10 * time.Second
+	pingInterval = 30 * time.Second
+	pongWait     = 90 * time.Second
+	maxReadSize  = 64 * 1024
+)
+
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+
+	mu      sync.Mutex
+	filters []*filter
+
+	dropped atomic.Uint64
+}
+
+// Server re-broadcasts enriched messages to websocket clients. Clients
+// subscribe the same way they would to RIS Live and receive every message
+// matching at least one of their subscriptions.
+type Server struct {
+	asnMapping *utils.ASNMapping
+	upgrader   websocket.Upgrader
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	active  atomic.Int32
+	dropped atomic.Uint64
+}
+
+// NewServer creates a server naming ASNs with asnMapping, which may be nil.
+func NewServer(asnMapping *utils.ASNMapping) *Server {
+	return &Server{
+		asnMapping: asnMapping,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// Clients returns the number of connected clients.
+func (s *Server) Clients() int {
+	return int(s.active.Load())
+}
+
+// Dropped returns how many messages were dropped because a client fell behind.
+func (s *Server) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Publish sends m to every client subscribed to it. It never blocks; clients
+// that fall behind miss messages. It is safe to call from several goroutines.
+func (s *Server) Publish(m *bgp.EnrichedMessage) {
+	if s.active.Load() == 0 {
+		return
+	}
+	var payload []byte
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for c := range s.clients {
+		if !c.matches(m.Data) {
+			continue
+		}
+		if payload == nil {
+			b, err := json.Marshal(Message{Type: "ris_message", Data: NewData(m, s.asnMapping)})
+			if err != nil {
+				log.Printf("Warning: failed to encode websocket message: %v", err)
+				return
+			}
+			payload = b
+		}
+		select {
+		case c.send <- payload:
+		default:
+			c.dropped.Add(1)
+			s.dropped.Add(1)
+		}
+	}
+}
+
+func (c *client) matches(d *bgp.RISMessageData) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, f := range c.filters {
+		if f.matches(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP upgrades the request and serves the client until it disconnects.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &client{conn: conn, send: make(chan []byte, clientBuffer), done: make(chan struct{})}
+	s.mu.Lock()
+	s.clients[c] = struct{}{}
+	s.active.Store(int32(len(s.clients)))
+	s.mu.Unlock()
+
+	go c.writeLoop()
+	c.readLoop()
+
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.active.Store(int32(len(s.clients)))
+	s.mu.Unlock()
+	close(c.done)
+	_ = conn.Close()
+	if n := c.dropped.Load(); n > 0 {
+		log.Printf("[WS] Client %s missed %d messages", r.RemoteAddr, n)
+	}
+}
+
+func (c *client) writeLoop() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case b := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				_ = c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *client) readLoop() {
+	c.conn.SetReadLimit(maxReadSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, b, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var req struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(b, &req); err != nil {
+			c.reply("ris_error", map[string]string{"message": "invalid JSON: " + err.Error()})
+			continue
+		}
+		switch req.Type {
+		case "ping":
+			c.reply("pong", nil)
+		case "ris_subscribe", "ris_unsubscribe":
+			if err := c.handleSubscription(req.Type, req.Data); err != nil {
+				c.reply("ris_error", map[string]string{"message": err.Error()})
+			}
+		default:
+			c.reply("ris_error", map[string]string{"message": "unsupported message type " + req.Type})
+		}
+	}
+}
+
+func (c *client) handleSubscription(typ string, data json.RawMessage) error {
+	var sub Subscription
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &sub); err != nil {
+			return errors.New("invalid subscription: " + err.Error())
+		}
+	}
+	f, err := newFilter(&sub)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	kept := c.filters[:0]
+	for _, existing := range c.filters {
+		if existing.key != f.key {
+			kept = append(kept, existing)
+		}
+	}
+	c.filters = kept
+	if typ == "ris_subscribe" {
+		c.filters = append(c.filters, f)
+	}
+	c.mu.Unlock()
+
+	if typ == "ris_subscribe" && sub.SocketOptions != nil && sub.SocketOptions.Acknowledge {
+		opts := sub.SocketOptions
+		sub.SocketOptions = nil
+		c.reply("ris_subscribe_ok", map[string]any{"subscription": sub, "socketOptions": opts})
+	}
+	return nil
+}
+
+// reply queues a message for the client, waiting if its queue is full.
+func (c *client) reply(typ string, data any) {
+	b, err := json.Marshal(Message{Type: typ, Data: data})
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- b:
+	case <-c.done:
+	}
+}
+
+// closeAll disconnects every client.
+func (s *Server) closeAll() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for c := range s.clients {
+		_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(writeWait))
+		_ = c.conn.Close()
+	}
+}
+
+// ListenAndServe serves s at Path on addr until ctx is canceled.
+func ListenAndServe(ctx context.Context, addr string, s *Server) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+	log.Printf("[WS] Serving enriched RIS Live messages on ws://%s%s", ln.Addr(), Path)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		// Hijacked websocket connections are not tracked by Shutdown
+		s.closeAll()
+		err := srv.Shutdown(shutdownCtx)
+		if err2 := <-errCh; !errors.Is(err2, http.ErrServerClosed) {
+			return err2
+		}
+		return err
+	}
+}
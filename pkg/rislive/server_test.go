@@ -0,0 +1,155 @@
+package rislive
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/geoservice"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+func testMessage(prefix string, c bgp.ClassificationType) *bgp.EnrichedMessage {
+	return &bgp.EnrichedMessage{
+		Data: &bgp.RISMessageData{
+			Timestamp: 1704110400.5,
+			Peer:      "192.0.2.1",
+			PeerASN:   "64500",
+			Host:      "rrc00",
+			Type:      "UPDATE",
+			Path:      path("64500", "100", "200"),
+			Announcements: []bgp.RISAnnouncement{
+				{NextHop: "192.0.2.1", Prefixes: []string{prefix}},
+			},
+		},
+		Prefixes: []bgp.EnrichedPrefix{{
+			PendingEvent: bgp.PendingEvent{
+				Prefix:             prefix,
+				ASN:                200,
+				HistoricalASN:      300,
+				EventType:          bgp.EventUpdate,
+				ClassificationType: c,
+				LeakDetail:         &bgp.LeakDetail{Type: bgp.LeakPeerToProvider, LeakerASN: 100, VictimASN: 300},
+			},
+			Lat:        52.5,
+			Lng:        13.4,
+			Country:    "DE",
+			City:       "Berlin",
+			Resolution: geoservice.ResMMDB,
+			RPKIStatus: utils.RPKIInvalidASN,
+		}},
+	}
+}
+
+func TestNewData(t *testing.T) {
+	d := NewData(testMessage("10.1.0.0/16", bgp.ClassificationHijack), nil)
+	if d.Host != "rrc00" || d.PeerASN != "64500" || len(d.Announcements) != 1 || d.Enrichment.ASNames != nil {
+		t.Errorf("unexpected data: %+v", d)
+	}
+	pe := d.Enrichment.Prefixes["10.1.0.0/16"]
+	if pe == nil {
+		t.Fatal("expected the prefix to be enriched")
+	}
+	if pe.EventType != "upd" || pe.Classification != "bgp_hijack" || pe.OriginASN != 200 || pe.HistoricalOriginASN != 300 || pe.RPKI != utils.RPKIInvalidASN.String() {
+		t.Errorf("unexpected enrichment: %+v", pe)
+	}
+	if pe.Geo == nil || pe.Geo.City != "Berlin" || pe.Geo.Resolution != "MMDB" {
+		t.Errorf("unexpected geo: %+v", pe.Geo)
+	}
+	if pe.Leak == nil || pe.Leak.Type != bgp.LeakPeerToProvider.String() || pe.Leak.LeakerASN != 100 {
+		t.Errorf("unexpected leak: %+v", pe.Leak)
+	}
+
+	unclassified := NewData(testMessage("10.1.0.0/16", bgp.ClassificationNone), nil)
+	if pe := unclassified.Enrichment.Prefixes["10.1.0.0/16"]; pe.ClassificationName != "" {
+		t.Errorf("expected no classification name, got %q", pe.ClassificationName)
+	}
+}
+
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+Path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, msg string) {
+	t.Helper()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, conn *websocket.Conn) (string, json.RawMessage) {
+	t.Helper()
+	var msg struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg.Type, msg.Data
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(nil)
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	conn := dial(t, srv)
+
+	// Nothing is sent before subscribing
+	s.Publish(testMessage("10.1.0.0/16", bgp.ClassificationHijack))
+
+	send(t, conn, `{"type":"ris_subscribe","data":{"prefix":"10.0.0.0/8","socketOptions":{"acknowledge":true}}}`)
+	typ, data := read(t, conn)
+	if typ != "ris_subscribe_ok" || !strings.Contains(string(data), `"prefix":"10.0.0.0/8"`) {
+		t.Fatalf("unexpected reply %s: %s", typ, data)
+	}
+	if s.Clients() != 1 {
+		t.Errorf("expected 1 client, got %d", s.Clients())
+	}
+
+	s.Publish(testMessage("192.0.2.0/24", bgp.ClassificationHijack))
+	s.Publish(testMessage("10.1.0.0/16", bgp.ClassificationHijack))
+	typ, data = read(t, conn)
+	if typ != "ris_message" {
+		t.Fatalf("unexpected message %s: %s", typ, data)
+	}
+	var d Data
+	if err := json.Unmarshal(data, &d); err != nil {
+		t.Fatal(err)
+	}
+	if pe := d.Enrichment.Prefixes["10.1.0.0/16"]; pe == nil || pe.Classification != "bgp_hijack" {
+		t.Errorf("unexpected message: %s", data)
+	}
+
+	send(t, conn, `{"type":"ping"}`)
+	if typ, _ := read(t, conn); typ != "pong" {
+		t.Errorf("expected a pong, got %s", typ)
+	}
+	send(t, conn, `{"type":"ris_subscribe","data":{"path":"x"}}`)
+	if typ, data := read(t, conn); typ != "ris_error" {
+		t.Errorf("expected an error, got %s: %s", typ, data)
+	}
+
+	// After unsubscribing only the ping gets an answer
+	send(t, conn, `{"type":"ris_unsubscribe","data":{"prefix":"10.0.0.0/8"}}`)
+	send(t, conn, `{"type":"ping"}`)
+	if typ, _ := read(t, conn); typ != "pong" {
+		t.Fatalf("expected a pong, got %s", typ)
+	}
+	s.Publish(testMessage("10.1.0.0/16", bgp.ClassificationHijack))
+	send(t, conn, `{"type":"ping"}`)
+	if typ, data := read(t, conn); typ != "pong" {
+		t.Errorf("expected no more messages, got %s: %s", typ, data)
+	}
+}
@@ -0,0 +1,207 @@
+package rislive
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+// Subscription is the data of a ris_subscribe or ris_unsubscribe message. The
+// fields and their defaults follow RIS Live: Prefix is a prefix or a list of
+// prefixes, MoreSpecific defaults to true and Path is an ASN or a
+// comma-separated sequence of ASNs, optionally anchored with ^ and $.
+type Subscription struct {
+	Host          string          `json:"host,omitempty"`
+	Type          string          `json:"type,omitempty"`
+	Require       string          `json:"require,omitempty"`
+	Peer          string          `json:"peer,omitempty"`
+	Path          string          `json:"path,omitempty"`
+	Prefix        json.RawMessage `json:"prefix,omitempty"`
+	MoreSpecific  *bool           `json:"moreSpecific,omitempty"`
+	LessSpecific  bool            `json:"lessSpecific,omitempty"`
+	SocketOptions *SocketOptions  `json:"socketOptions,omitempty"`
+}
+
+// SocketOptions change how the server answers a subscription.
+type SocketOptions struct {
+	// Acknowledge asks for a ris_subscribe_ok reply.
+	Acknowledge bool `json:"acknowledge,omitempty"`
+}
+
+// filter is the compiled form of a Subscription.
+type filter struct {
+	// key identifies the subscription for ris_unsubscribe
+	key string
+
+	host, typ, require, peer string
+	path                     *pathPattern
+	prefixes                 []netip.Prefix
+	moreSpecific             bool
+	lessSpecific             bool
+}
+
+func newFilter(sub *Subscription) (*filter, error) {
+	f := &filter{
+		host:         sub.Host,
+		typ:          strings.ToUpper(sub.Type),
+		require:      sub.Require,
+		peer:         sub.Peer,
+		moreSpecific: sub.MoreSpecific == nil || *sub.MoreSpecific,
+		lessSpecific: sub.LessSpecific,
+	}
+	if f.require != "" && f.require != "announcements" && f.require != "withdrawals" {
+		return nil, errors.New(`require must be "announcements" or "withdrawals"`)
+	}
+	if sub.Path != "" {
+		p, err := parsePathPattern(sub.Path)
+		if err != nil {
+			return nil, err
+		}
+		f.path = p
+	}
+	if len(sub.Prefix) > 0 {
+		var prefixes []string
+		if err := json.Unmarshal(sub.Prefix, &prefixes); err != nil {
+			var prefix string
+			if err := json.Unmarshal(sub.Prefix, &prefix); err != nil {
+				return nil, errors.New("prefix must be a string or a list of strings")
+			}
+			prefixes = []string{prefix}
+		}
+		for _, s := range prefixes {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, errors.New("invalid prefix " + strconv.Quote(s))
+			}
+			f.prefixes = append(f.prefixes, p.Masked())
+		}
+	}
+
+	key := *sub
+	key.SocketOptions = nil
+	key.MoreSpecific = &f.moreSpecific
+	b, _ := json.Marshal(key)
+	f.key = string(b)
+	return f, nil
+}
+
+func (f *filter) matches(d *bgp.RISMessageData) bool {
+	if f.host != "" && f.host != d.Host {
+		return false
+	}
+	if f.typ != "" && f.typ != strings.ToUpper(d.Type) {
+		return false
+	}
+	if f.peer != "" && f.peer != d.Peer {
+		return false
+	}
+	switch f.require {
+	case "announcements":
+		if len(d.Announcements) == 0 {
+			return false
+		}
+	case "withdrawals":
+		if len(d.Withdrawals) == 0 {
+			return false
+		}
+	}
+	if f.path != nil && !f.path.matches(d.Hops()) {
+		return false
+	}
+	if f.prefixes == nil {
+		return true
+	}
+	for _, ann := range d.Announcements {
+		for _, p := range ann.Prefixes {
+			if f.matchPrefix(p) {
+				return true
+			}
+		}
+	}
+	for _, p := range d.Withdrawals {
+		if f.matchPrefix(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *filter) matchPrefix(prefix string) bool {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return false
+	}
+	for _, fp := range f.prefixes {
+		switch {
+		case p == fp:
+			return true
+		case f.moreSpecific && p.Bits() > fp.Bits() && fp.Contains(p.Addr()):
+			return true
+		case f.lessSpecific && p.Bits() < fp.Bits() && p.Contains(fp.Addr()):
+			return true
+		}
+	}
+	return false
+}
+
+// pathPattern matches a sequence of ASNs in an AS path.
+type pathPattern struct {
+	asns                   []uint32
+	anchorStart, anchorEnd bool
+}
+
+func parsePathPattern(s string) (*pathPattern, error) {
+	p := &pathPattern{}
+	if strings.HasPrefix(s, "^") {
+		p.anchorStart = true
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "$") {
+		p.anchorEnd = true
+		s = s[:len(s)-1]
+	}
+	for _, part := range strings.Split(s, ",") {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(part)), "AS"), 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid path " + strconv.Quote(s))
+		}
+		p.asns = append(p.asns, uint32(asn))
+	}
+	return p, nil
+}
+
+func (p *pathPattern) matches(hops [][]uint32) bool {
+	n := len(p.asns)
+	for start := 0; start+n <= len(hops); start++ {
+		if p.anchorStart && start > 0 {
+			break
+		}
+		if p.anchorEnd && start+n != len(hops) {
+			continue
+		}
+		if p.matchesAt(hops, start) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pathPattern) matchesAt(hops [][]uint32, start int) bool {
+	for i, asn := range p.asns {
+		found := false
+		for _, member := range hops[start+i] {
+			if member == asn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
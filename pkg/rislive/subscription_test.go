@@ -0,0 +1,111 @@
+package rislive
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+func path(hops ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(hops))
+	for i, h := range hops {
+		out[i] = json.RawMessage(h)
+	}
+	return out
+}
+
+func TestPathPattern(t *testing.T) {
+	hops := (&bgp.RISMessageData{Path: path("100", "200", "[300,400]", "500")}).Hops()
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{"200", true},
+		{"400", true},
+		{"600", false},
+		{"200,300", true},
+		{"AS200,AS400,500", true},
+		{"100,300", false},
+		{"^100,200", true},
+		{"^200", false},
+		{"500$", true},
+		{"400,500$", true},
+		{"200$", false},
+		{"^100,200,300,500$", true},
+	}
+	for _, tt := range tests {
+		p, err := parsePathPattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.pattern, err)
+		}
+		if got := p.matches(hops); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+	if _, err := parsePathPattern("100,x"); err == nil {
+		t.Error("expected an invalid path to be rejected")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data := &bgp.RISMessageData{
+		Host: "rrc00",
+		Peer: "192.0.2.1",
+		Type: "UPDATE",
+		Path: path("100", "200"),
+		Announcements: []bgp.RISAnnouncement{
+			{NextHop: "192.0.2.1", Prefixes: []string{"10.1.0.0/16"}},
+		},
+	}
+	tests := []struct {
+		name string
+		sub  string
+		want bool
+	}{
+		{"everything", `{}`, true},
+		{"exact prefix", `{"prefix":"10.1.0.0/16"}`, true},
+		{"more-specific by default", `{"prefix":"10.0.0.0/8"}`, true},
+		{"more-specific disabled", `{"prefix":"10.0.0.0/8","moreSpecific":false}`, false},
+		{"less-specific", `{"prefix":"10.1.2.0/24","lessSpecific":true}`, true},
+		{"less-specific not asked for", `{"prefix":"10.1.2.0/24"}`, false},
+		{"prefix list", `{"prefix":["192.0.2.0/24","10.1.0.0/16"]}`, true},
+		{"host", `{"host":"rrc01"}`, false},
+		{"type", `{"type":"update"}`, true},
+		{"peer", `{"peer":"192.0.2.1"}`, true},
+		{"origin", `{"path":"200$"}`, true},
+		{"require withdrawals", `{"require":"withdrawals"}`, false},
+		{"require announcements", `{"require":"announcements","path":"^100"}`, true},
+	}
+	for _, tt := range tests {
+		var sub Subscription
+		if err := json.Unmarshal([]byte(tt.sub), &sub); err != nil {
+			t.Fatal(err)
+		}
+		f, err := newFilter(&sub)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := f.matches(data); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`{"prefix":"nope"}`, `{"prefix":1}`, `{"require":"both"}`} {
+		var sub Subscription
+		_ = json.Unmarshal([]byte(bad), &sub)
+		if _, err := newFilter(&sub); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+
+	// The default of moreSpecific doesn't make two subscriptions different
+	var a, b Subscription
+	_ = json.Unmarshal([]byte(`{"prefix":"10.0.0.0/8"}`), &a)
+	_ = json.Unmarshal([]byte(`{"prefix":"10.0.0.0/8","moreSpecific":true,"socketOptions":{"acknowledge":true}}`), &b)
+	fa, _ := newFilter(&a)
+	fb, _ := newFilter(&b)
+	if fa.key != fb.key {
+		t.Errorf("expected equal keys, got %s and %s", fa.key, fb.key)
+	}
+}
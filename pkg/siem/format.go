@@ -0,0 +1,230 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// FieldNames are the exported fields, in output order.
+var FieldNames = []string{
+	"time", "classification", "previous_classification", "severity",
+	"prefix", "origin", "origin_name", "previous_origin",
+	"leaker", "victim", "leak_type", "rpki",
+	"country", "city", "lat", "lng",
+	"peer", "host", "path",
+}
+
+// cefKeys map the fields to CEF extension keys. Custom strings and numbers
+// get a label with the field name.
+var cefKeys = map[string]string{
+	"time":                    "rt",
+	"classification":          "cat",
+	"previous_classification": "cs5",
+	"prefix":                  "cs1",
+	"origin":                  "cn1",
+	"leaker":                  "cn2",
+	"victim":                  "cn3",
+	"leak_type":               "cs6",
+	"rpki":                    "cs2",
+	"country":                 "cs3",
+	"city":                    "cs4",
+	"lat":                     "dlat",
+	"lng":                     "dlong",
+	"peer":                    "src",
+	"host":                    "dvchost",
+	"path":                    "msg",
+}
+
+var cefCustomKey = regexp.MustCompile(`^(c[sn]|cfp)[0-9]$`)
+
+type field struct {
+	name  string
+	value any
+}
+
+// formatter turns a transition into one line of JSON or CEF.
+type formatter struct {
+	cef        bool
+	keys       map[string]string
+	asnMapping *utils.ASNMapping
+}
+
+func newFormatter(format string, fields map[string]string, asnMapping *utils.ASNMapping) (*formatter, error) {
+	f := &formatter{keys: make(map[string]string), asnMapping: asnMapping}
+	switch format {
+	case "", "json":
+		for _, name := range FieldNames {
+			f.keys[name] = name
+		}
+	case "cef":
+		f.cef = true
+		for name, key := range cefKeys {
+			f.keys[name] = key
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	for name, key := range fields {
+		if !slices.Contains(FieldNames, name) {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		f.keys[name] = key
+	}
+	return f, nil
+}
+
+// fields returns the non-empty fields of t in FieldNames order.
+func (f *formatter) fields(t *bgp.Transition) []field {
+	var fs []field
+	add := func(name string, value any) {
+		switch v := value.(type) {
+		case string:
+			if v == "" {
+				return
+			}
+		case uint32:
+			if v == 0 {
+				return
+			}
+		}
+		fs = append(fs, field{name, value})
+	}
+	add("time", t.Time.UTC())
+	add("classification", t.To.Key())
+	add("previous_classification", t.From.Key())
+	add("severity", severity(t.To))
+	add("prefix", t.Prefix)
+	add("origin", t.OriginASN)
+	if t.OriginASN != 0 {
+		add("origin_name", api.NetworkName(f.asnMapping, t.OriginASN))
+	}
+	if t.HistoricalASN != t.OriginASN {
+		add("previous_origin", t.HistoricalASN)
+	}
+	if ld := t.LeakDetail; ld != nil {
+		add("leaker", ld.LeakerASN)
+		add("victim", ld.VictimASN)
+		if ld.Type != bgp.LeakUnknown {
+			add("leak_type", ld.Type.String())
+		}
+	}
+	if t.Evidence.RPKIStatus != 0 {
+		add("rpki", utils.RPKIStatus(t.Evidence.RPKIStatus).String())
+	}
+	add("country", t.Country)
+	add("city", t.City)
+	if t.Lat != 0 || t.Lng != 0 {
+		add("lat", t.Lat)
+		add("lng", t.Lng)
+	}
+	add("peer", t.Evidence.Peer)
+	add("host", t.Evidence.Host)
+	add("path", t.Evidence.Path)
+	return fs
+}
+
+func (f *formatter) format(t *bgp.Transition) []byte {
+	if f.cef {
+		return f.formatCEF(t)
+	}
+	return f.formatJSON(t)
+}
+
+func (f *formatter) formatJSON(t *bgp.Transition) []byte {
+	var b strings.Builder
+	b.WriteByte('{')
+	first := true
+	for _, fl := range f.fields(t) {
+		key := f.keys[fl.name]
+		if key == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		k, _ := json.Marshal(key)
+		v, _ := json.Marshal(fl.value)
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return []byte(b.String())
+}
+
+// formatCEF renders t as
+// CEF:0|bgp-stream|bgp-stream|1.0|<classification>|<name>|<severity>|<extension>.
+func (f *formatter) formatCEF(t *bgp.Transition) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|bgp-stream|bgp-stream|1.0|%s|%s|%d|",
+		cefHeader(t.To.Key()), cefHeader(t.To.String()), severity(t.To))
+	first := true
+	for _, fl := range f.fields(t) {
+		key := f.keys[fl.name]
+		if key == "" {
+			continue
+		}
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+		if cefCustomKey.MatchString(key) {
+			b.WriteString(key + "Label=" + cefValue(fl.name) + " ")
+		}
+		b.WriteString(key + "=" + cefValue(cefString(fl.value)))
+	}
+	return []byte(b.String())
+}
+
+func cefString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case interface{ UnixMilli() int64 }:
+		return strconv.FormatInt(v.UnixMilli(), 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeader(s string) string { return cefHeaderEscaper.Replace(s) }
+
+func cefValue(s string) string { return cefValueEscaper.Replace(s) }
+
+// severity rates a classification from 0 to 10 as in CEF: incidents are the
+// most severe, then the other critical classifications, flaps and policy
+// changes.
+func severity(t bgp.ClassificationType) int {
+	switch {
+	case t.Priority() == 3:
+		return 10
+	case t.IsCritical():
+		return 8
+	case t.Priority() == 2:
+		return 5
+	case t.Priority() == 1:
+		return 3
+	default:
+		return 1
+	}
+}
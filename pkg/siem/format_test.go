@@ -0,0 +1,102 @@
+package siem
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+func testTransition() *bgp.Transition {
+	return &bgp.Transition{
+		Time:          time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Prefix:        "192.0.2.0/24",
+		From:          bgp.ClassificationNone,
+		To:            bgp.ClassificationRouteLeak,
+		OriginASN:     64500,
+		HistoricalASN: 64500,
+		Lat:           52.37,
+		Lng:           4.89,
+		Country:       "NL",
+		City:          "Amsterdam",
+		LeakDetail:    &bgp.LeakDetail{Type: bgp.LeakLateral, LeakerASN: 64501, VictimASN: 64502},
+		Evidence: bgp.Evidence{
+			Peer:       "198.51.100.1",
+			Host:       "rrc00",
+			Path:       "64496 64501 64500",
+			RPKIStatus: int32(utils.RPKIInvalidASN),
+		},
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	f, err := newFormatter("json", map[string]string{"prefix": "bgp_prefix", "path": ""}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(f.format(testTransition()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"time":                    "2024-01-01T12:00:00Z",
+		"classification":          "route_leak",
+		"previous_classification": "none",
+		"severity":                float64(10),
+		"bgp_prefix":              "192.0.2.0/24",
+		"origin":                  float64(64500),
+		"leaker":                  float64(64501),
+		"victim":                  float64(64502),
+		"leak_type":               bgp.LeakLateral.String(),
+		"rpki":                    "InvalidASN",
+		"country":                 "NL",
+		"city":                    "Amsterdam",
+		"lat":                     52.37,
+		"lng":                     4.89,
+		"peer":                    "198.51.100.1",
+		"host":                    "rrc00",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got fields %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestFormatCEF(t *testing.T) {
+	f, err := newFormatter("cef", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := testTransition()
+	tr.City = "Den Haag=The Hague"
+	got := string(f.format(tr))
+	for _, want := range []string{
+		"CEF:0|bgp-stream|bgp-stream|1.0|route_leak|Route Leak|10|rt=1704110400000 cat=route_leak ",
+		"cs1Label=prefix cs1=192.0.2.0/24",
+		"cn1Label=origin cn1=64500",
+		"cn2Label=leaker cn2=64501 cn3Label=victim cn3=64502",
+		"cs2Label=rpki cs2=InvalidASN",
+		`cs4Label=city cs4=Den Haag\=The Hague`,
+		"dlat=52.37 dlong=4.89 src=198.51.100.1 dvchost=rrc00 msg=64496 64501 64500",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in %s", want, got)
+		}
+	}
+}
+
+func TestNewFormatter_Invalid(t *testing.T) {
+	if _, err := newFormatter("xml", nil, nil); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+	if _, err := newFormatter("json", map[string]string{"asn": "origin"}, nil); err == nil {
+		t.Error("expected an unknown field to be rejected")
+	}
+}
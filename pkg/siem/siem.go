@@ -0,0 +1,237 @@
+// Package siem exports classification changes to security tooling: RFC 5424
+// syslog over UDP, TCP or TLS and rotating files, formatted as JSON or
+// ArcSight CEF. Every sink buffers on its own, so a slow or unreachable sink
+// drops events instead of holding up the processor.
+package siem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// defaultBuffer is how many events a sink holds while it is busy.
+const defaultBuffer = 10000
+
+// Config is the SIEM configuration file.
+type Config struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig describes one sink. Type is syslog or file; the other fields
+// apply to the types noted next to them.
+type SinkConfig struct {
+	Type string `json:"type"`
+	// Name identifies the sink in logs and defaults to Type
+	Name string `json:"name,omitempty"`
+	// Format is json (the default) or cef
+	Format string `json:"format,omitempty"`
+	// Classifications are the classification keys to export. They default to
+	// the critical ones: hijacks, route leaks, outages, DDoS mitigation and
+	// bogons.
+	Classifications []string `json:"classifications,omitempty"`
+	// Fields renames the exported fields, or leaves them out when mapped to
+	// an empty string. See FieldNames for the fields.
+	Fields map[string]string `json:"fields,omitempty"`
+	// Buffer is how many events the sink holds while it is busy
+	Buffer int `json:"buffer,omitempty"`
+
+	// syslog: udp (the default), tcp or tls
+	Network string `json:"network,omitempty"`
+	Addr    string `json:"addr,omitempty"`
+
+	// file. The file is rotated once it reaches MaxSizeMB (default 100),
+	// keeping MaxFiles old files (default 5).
+	Path      string `json:"path,omitempty"`
+	MaxSizeMB int    `json:"max_size_mb,omitempty"`
+	MaxFiles  int    `json:"max_files,omitempty"`
+}
+
+// Load reads a SIEM configuration file.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// writer delivers formatted events to a destination.
+type writer interface {
+	write(line []byte, severity int) error
+	Close() error
+}
+
+type sink struct {
+	name    string
+	classes map[bgp.ClassificationType]struct{}
+	format  *formatter
+	out     writer
+	queue   chan bgp.Transition
+	dropped atomic.Uint64
+}
+
+// Exporter sends classification changes to its sinks. OnTransition may be
+// called from any goroutine.
+type Exporter struct {
+	sinks []*sink
+}
+
+// New creates the sinks described by c. asnMapping may be nil, in which case
+// origin names are left out.
+func New(c *Config, asnMapping *utils.ASNMapping) (*Exporter, error) {
+	e := &Exporter{}
+	for i := range c.Sinks {
+		s, err := newSink(&c.Sinks[i], asnMapping)
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		e.sinks = append(e.sinks, s)
+	}
+	return e, nil
+}
+
+func newSink(c *SinkConfig, asnMapping *utils.ASNMapping) (*sink, error) {
+	name := c.Name
+	if name == "" {
+		name = c.Type
+	}
+	s := &sink{name: name, classes: make(map[bgp.ClassificationType]struct{})}
+	if len(c.Classifications) == 0 {
+		for t := bgp.ClassificationFlap; t <= bgp.ClassificationBogon; t++ {
+			if t.IsCritical() {
+				s.classes[t] = struct{}{}
+			}
+		}
+	}
+	for _, key := range c.Classifications {
+		t, ok := bgp.ParseClassificationKey(key)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown classification %q", name, key)
+		}
+		s.classes[t] = struct{}{}
+	}
+	f, err := newFormatter(c.Format, c.Fields, asnMapping)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	s.format = f
+	buffer := c.Buffer
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	s.queue = make(chan bgp.Transition, buffer)
+
+	switch c.Type {
+	case "syslog":
+		if c.Addr == "" {
+			return nil, fmt.Errorf("%s: addr is required", name)
+		}
+		network := c.Network
+		if network == "" {
+			network = "udp"
+		}
+		if network != "udp" && network != "tcp" && network != "tls" {
+			return nil, fmt.Errorf("%s: unknown network %q", name, c.Network)
+		}
+		s.out = &syslogWriter{network: network, addr: c.Addr}
+	case "file":
+		if c.Path == "" {
+			return nil, fmt.Errorf("%s: path is required", name)
+		}
+		maxSize, maxFiles := c.MaxSizeMB, c.MaxFiles
+		if maxSize <= 0 {
+			maxSize = 100
+		}
+		if maxFiles <= 0 {
+			maxFiles = 5
+		}
+		s.out = &rotatingFile{path: c.Path, maxSize: int64(maxSize) << 20, maxFiles: maxFiles}
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", c.Type)
+	}
+	return s, nil
+}
+
+// OnTransition queues a classification change for the sinks that export its
+// new classification. It never blocks.
+func (e *Exporter) OnTransition(t *bgp.Transition) {
+	for _, s := range e.sinks {
+		if _, ok := s.classes[t.To]; !ok || t.From == t.To {
+			continue
+		}
+		select {
+		case s.queue <- *t:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Run writes queued events until ctx is canceled, then closes the sinks.
+func (e *Exporter) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range e.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Dropped returns how many events were lost because a sink was full or
+// failing.
+func (e *Exporter) Dropped() uint64 {
+	var n uint64
+	for _, s := range e.sinks {
+		n += s.dropped.Load()
+	}
+	return n
+}
+
+// Close closes the sinks. Run closes them on its way out, so Close is only
+// needed when Run was never started.
+func (e *Exporter) Close() {
+	for _, s := range e.sinks {
+		_ = s.out.Close()
+	}
+}
+
+func (s *sink) run(ctx context.Context) {
+	defer func() { _ = s.out.Close() }()
+	failing := false
+	for {
+		var t bgp.Transition
+		select {
+		case <-ctx.Done():
+			return
+		case t = <-s.queue:
+		}
+		err := s.out.write(s.format.format(&t), severity(t.To))
+		switch {
+		case err != nil:
+			s.dropped.Add(1)
+			if !failing {
+				log.Printf("Warning: SIEM sink %s failed, dropping events until it recovers: %v", s.name, err)
+				failing = true
+			}
+		case failing:
+			log.Printf("[SIEM] Sink %s recovered", s.name)
+			failing = false
+		}
+	}
+}
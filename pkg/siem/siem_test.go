@@ -0,0 +1,154 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+func TestExporter_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "siem", "events.jsonl")
+	e, err := New(&Config{Sinks: []SinkConfig{{Type: "file", Path: path}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	e.OnTransition(testTransition())
+	// Classifications outside the default set are left out
+	flap := testTransition()
+	flap.To = bgp.ClassificationFlap
+	e.OnTransition(flap)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(path)
+		if strings.Count(string(b), "\n") == 1 {
+			if !strings.Contains(string(b), `"classification":"route_leak"`) {
+				t.Errorf("unexpected file contents %s", b)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("event was not written, got %q", b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestExporter_Full(t *testing.T) {
+	e, err := New(&Config{Sinks: []SinkConfig{{Type: "syslog", Addr: "127.0.0.1:9", Classifications: []string{"route_leak"}, Buffer: 2}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+	// Without Run nothing is drained, and a full sink drops instead of blocking
+	for range 5 {
+		e.OnTransition(testTransition())
+	}
+	if got := e.Dropped(); got != 3 {
+		t.Errorf("expected 3 dropped events, got %d", got)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, c := range []SinkConfig{
+		{Type: "syslog"},
+		{Type: "syslog", Addr: "localhost:514", Network: "unix"},
+		{Type: "file"},
+		{Type: "file", Path: "x", Format: "leef"},
+		{Type: "file", Path: "x", Classifications: []string{"leak"}},
+		{Type: "kafka"},
+	} {
+		if _, err := New(&Config{Sinks: []SinkConfig{c}}, nil); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+}
+
+func TestSyslogWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	lines := make(chan string, 4)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			lines <- string(buf)
+		}
+	}()
+
+	// Both messages share one connection, each framed with its length
+	w := &syslogWriter{network: "tcp", addr: ln.Addr().String()}
+	defer func() { _ = w.Close() }()
+	for _, sev := range []int{10, 3} {
+		if err := w.write([]byte("CEF:0|test"), sev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, pri := range []string{"<130>1 ", "<133>1 "} {
+		select {
+		case got := <-lines:
+			if !strings.HasPrefix(got, pri) || !strings.HasSuffix(got, " bgp-stream "+strconv.Itoa(os.Getpid())+" - - CEF:0|test") {
+				t.Errorf("unexpected message %q", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("message was not received")
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	r := &rotatingFile{path: path, maxSize: 10, maxFiles: 2}
+	for i := range 4 {
+		if err := r.write([]byte("event-"+strconv.Itoa(i)), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = r.Close()
+	for name, want := range map[string]string{
+		path:        "event-3\n",
+		path + ".1": "event-2\n",
+		path + ".2": "event-1\n",
+	} {
+		if b, _ := os.ReadFile(name); string(b) != want {
+			t.Errorf("%s: got %q, want %q", filepath.Base(name), b, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("expected the oldest file to be removed")
+	}
+}
@@ -0,0 +1,162 @@
+package siem
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// dialTimeout bounds connecting to a syslog server.
+const dialTimeout = 10 * time.Second
+
+// syslogWriter sends RFC 5424 messages over a connection that is kept open and
+// re-established after errors. TCP and TLS use octet-counted framing.
+type syslogWriter struct {
+	network string
+	addr    string
+
+	conn     net.Conn
+	hostname string
+}
+
+// syslogPriority returns facility local0 with a syslog severity matching the
+// CEF severity.
+func syslogPriority(severity int) int {
+	s := 5 // notice
+	switch {
+	case severity >= 10:
+		s = 2 // critical
+	case severity >= 8:
+		s = 3 // error
+	case severity >= 5:
+		s = 4 // warning
+	}
+	return 16*8 + s
+}
+
+func (w *syslogWriter) write(line []byte, severity int) error {
+	if w.hostname == "" {
+		if w.hostname, _ = os.Hostname(); w.hostname == "" {
+			w.hostname = "-"
+		}
+	}
+	msg := fmt.Appendf(nil, "<%d>1 %s %s bgp-stream %d - - ",
+		syslogPriority(severity), time.Now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"), w.hostname, os.Getpid())
+	msg = append(msg, line...)
+	if w.network != "udp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	// A connection that broke since the last message gets one retry
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			conn, err := w.dial()
+			if err != nil {
+				return err
+			}
+			w.conn = conn
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+		_, err := w.conn.Write(msg)
+		if err == nil {
+			return nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (w *syslogWriter) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: dialTimeout}
+	if w.network == "tls" {
+		host, _, err := net.SplitHostPort(w.addr)
+		if err != nil {
+			return nil, err
+		}
+		return tls.DialWithDialer(d, "tcp", w.addr, &tls.Config{ServerName: host})
+	}
+	return d.Dial(w.network, w.addr)
+}
+
+func (w *syslogWriter) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// rotatingFile appends lines to path, moving it to path.1 (and older files to
+// path.2 and so on) once it would grow past maxSize.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) write(line []byte, _ int) error {
+	line = append(line, '\n')
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	_ = r.f.Close()
+	r.f = nil
+	_ = os.Remove(r.path + "." + strconv.Itoa(r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(r.path+"."+strconv.Itoa(i), r.path+"."+strconv.Itoa(i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
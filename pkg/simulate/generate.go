@@ -0,0 +1,296 @@
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+const (
+	// tableSpread is how long after the start the peers finish sending their
+	// routes
+	tableSpread = 10 * time.Second
+	// maxDelay is how much later than the first peer the last one sees an
+	// event
+	maxDelay = 3 * time.Second
+	// quietMargin keeps churn away from the prefixes of events
+	quietMargin = 5 * time.Minute
+)
+
+// blackhole is the RTBH community, 65535:666.
+var blackhole = []any{uint32(65535), uint32(666)}
+
+// session is a peer of a collector.
+type session struct {
+	collector string
+	peer      Peer
+	index     int
+}
+
+type interval struct {
+	from, to time.Time
+}
+
+type generator struct {
+	sc       *Scenario
+	rng      *rand.Rand
+	sessions []session
+	msgs     []*bgp.RISMessageData
+	// busyPrefixes holds when prefixes take part in events
+	busyPrefixes map[netip.Prefix][]interval
+}
+
+// Generate returns the updates of a scenario, ordered by time. The same
+// scenario and seed always give the same updates.
+func Generate(sc *Scenario) []*bgp.RISMessageData {
+	g := &generator{
+		sc:           sc,
+		rng:          rand.New(rand.NewSource(sc.Seed)),
+		busyPrefixes: make(map[netip.Prefix][]interval),
+	}
+	for _, c := range sc.Collectors {
+		for _, p := range c.Peers {
+			g.sessions = append(g.sessions, session{collector: c.Name, peer: p, index: len(g.sessions)})
+		}
+	}
+
+	for _, s := range g.sessions {
+		for i := range sc.Networks {
+			n := &sc.Networks[i]
+			g.announce(sc.Start.Add(g.jitter(tableSpread)), s, g.path(s, n, n.Upstreams[s.index%len(n.Upstreams)]), nil, n.prefixes)
+		}
+	}
+	events := slices.Clone(sc.Events)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at < events[j].at })
+	for i := range events {
+		g.event(&events[i])
+	}
+	g.churn()
+
+	end := sc.End()
+	msgs := g.msgs[:0]
+	for _, m := range g.msgs {
+		if !m.Time().After(end) {
+			msgs = append(msgs, m)
+		}
+	}
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Timestamp < msgs[j].Timestamp })
+	for i, m := range msgs {
+		m.ID = fmt.Sprintf("%s-%d", m.Host, i)
+	}
+	return msgs
+}
+
+func (g *generator) event(ev *Event) {
+	start := g.sc.Start.Add(ev.at)
+	end := g.sc.End()
+	if ev.duration > 0 {
+		end = start.Add(ev.duration)
+	}
+	restore := ev.duration > 0 && end.Before(g.sc.End())
+	quiet := interval{start.Add(-quietMargin), end.Add(quietMargin)}
+
+	if ev.Type == EventSessionReset {
+		for _, s := range g.sessions {
+			if s.collector != ev.Collector || s.peer.ASN != ev.Peer {
+				continue
+			}
+			// Withdrawals by other peers at the same time would look like
+			// an outage
+			for p := range g.sc.origins {
+				g.busyPrefixes[p] = append(g.busyPrefixes[p], quiet)
+			}
+			var all []netip.Prefix
+			for i := range g.sc.Networks {
+				all = append(all, g.sc.Networks[i].prefixes...)
+			}
+			g.withdraw(start, s, all)
+			if restore {
+				for i := range g.sc.Networks {
+					g.restore(end.Add(g.jitter(tableSpread)), s, g.sc.Networks[i].prefixes)
+				}
+			}
+		}
+		return
+	}
+
+	for _, p := range ev.prefixes {
+		g.busyPrefixes[p] = append(g.busyPrefixes[p], quiet)
+	}
+	for _, s := range g.sessions {
+		at := start.Add(g.jitter(maxDelay))
+		switch ev.Type {
+		case EventHijack:
+			g.announce(at, s, g.path(s, nil, ev.Upstream, ev.ASN), nil, ev.prefixes)
+		case EventLeak:
+			for _, np := range g.byNetwork(ev.prefixes) {
+				g.announce(at, s, g.path(s, nil, ev.To, ev.ASN, ev.From, np.network.ASN), nil, np.prefixes)
+			}
+		case EventRTBH:
+			for _, np := range g.byNetwork(ev.prefixes) {
+				n := np.network
+				g.announce(at, s, g.path(s, n, n.Upstreams[s.index%len(n.Upstreams)]), [][]any{blackhole}, np.prefixes)
+			}
+		case EventOutage:
+			g.withdraw(at, s, ev.prefixes)
+		}
+		if restore {
+			g.restore(end.Add(g.jitter(maxDelay)), s, ev.prefixes)
+		}
+	}
+}
+
+// churn adds the background route changes, away from the prefixes of
+// events.
+func (g *generator) churn() {
+	span := g.sc.duration - tableSpread
+	n := int(g.sc.Churn.Rate * g.sc.duration.Minutes())
+	if span <= 0 {
+		return
+	}
+	for range n {
+		at := g.sc.Start.Add(tableSpread + time.Duration(g.rng.Int63n(int64(span))))
+		network := &g.sc.Networks[g.rng.Intn(len(g.sc.Networks))]
+		prefix := network.prefixes[g.rng.Intn(len(network.prefixes))]
+		s := g.sessions[g.rng.Intn(len(g.sessions))]
+		withdraw := g.rng.Float64() < g.sc.Churn.Withdraw
+		if busy(g.busyPrefixes[prefix], at) {
+			continue
+		}
+
+		normal := network.Upstreams[s.index%len(network.Upstreams)]
+		prefixes := []netip.Prefix{prefix}
+		if withdraw {
+			g.withdraw(at, s, prefixes)
+			g.restore(at.Add(5*time.Second+g.jitter(time.Minute)), s, prefixes)
+			continue
+		}
+		// Another upstream, or another MED if there is only one. Prepending
+		// behind a Tier-1 would look like traffic redirection to a scrubber.
+		if len(network.Upstreams) > 1 {
+			alt := network.Upstreams[g.rng.Intn(len(network.Upstreams))]
+			for alt == normal {
+				alt = network.Upstreams[g.rng.Intn(len(network.Upstreams))]
+			}
+			g.announce(at, s, g.path(s, network, alt), nil, prefixes)
+		} else {
+			g.announce(at, s, g.path(s, network, normal), nil, prefixes)
+			g.msgs[len(g.msgs)-1].Med = int32(10 * (1 + g.rng.Intn(10)))
+		}
+		g.restore(at.Add(2*time.Minute+g.jitter(8*time.Minute)), s, prefixes)
+	}
+}
+
+func busy(intervals []interval, t time.Time) bool {
+	for _, iv := range intervals {
+		if !t.Before(iv.from) && !t.After(iv.to) {
+			return true
+		}
+	}
+	return false
+}
+
+// restore puts prefixes back to their normal routes: the networks' own
+// prefixes are announced again and anything else is withdrawn.
+func (g *generator) restore(at time.Time, s session, prefixes []netip.Prefix) {
+	var withdrawn []netip.Prefix
+	for _, p := range prefixes {
+		if _, ok := g.sc.origins[p]; !ok {
+			withdrawn = append(withdrawn, p)
+		}
+	}
+	for _, np := range g.byNetwork(prefixes) {
+		n := np.network
+		own := slices.DeleteFunc(np.prefixes, func(p netip.Prefix) bool { return g.sc.origins[p] == nil })
+		if len(own) > 0 {
+			g.announce(at, s, g.path(s, n, n.Upstreams[s.index%len(n.Upstreams)]), nil, own)
+		}
+	}
+	if len(withdrawn) > 0 {
+		g.withdraw(at, s, withdrawn)
+	}
+}
+
+// networkPrefixes are prefixes covered by one network.
+type networkPrefixes struct {
+	network  *Network
+	prefixes []netip.Prefix
+}
+
+// byNetwork groups prefixes by the network covering them, in the order the
+// networks first appear.
+func (g *generator) byNetwork(prefixes []netip.Prefix) []networkPrefixes {
+	var groups []networkPrefixes
+	for _, p := range prefixes {
+		n := g.sc.covering(p)
+		i := slices.IndexFunc(groups, func(np networkPrefixes) bool { return np.network == n })
+		if i < 0 {
+			i = len(groups)
+			groups = append(groups, networkPrefixes{network: n})
+		}
+		groups[i].prefixes = append(groups[i].prefixes, p)
+	}
+	return groups
+}
+
+// path returns the AS path a session sees: the peer, then hops, then the
+// origin of n if there is one. A hop equal to the one before it is dropped.
+func (g *generator) path(s session, n *Network, hops ...uint32) []uint32 {
+	path := []uint32{s.peer.ASN}
+	if n != nil {
+		hops = append(hops, n.ASN)
+	}
+	for _, asn := range hops {
+		if asn != path[len(path)-1] {
+			path = append(path, asn)
+		}
+	}
+	return path
+}
+
+// jitter returns a random delay of up to limit, in whole milliseconds.
+func (g *generator) jitter(limit time.Duration) time.Duration {
+	return time.Duration(g.rng.Int63n(int64(limit/time.Millisecond))) * time.Millisecond
+}
+
+func (g *generator) announce(at time.Time, s session, path []uint32, community [][]any, prefixes []netip.Prefix) {
+	m := g.message(at, s)
+	for _, asn := range path {
+		m.Path = append(m.Path, json.RawMessage(strconv.FormatUint(uint64(asn), 10)))
+	}
+	m.Community = community
+	m.Origin = "IGP"
+	m.Announcements = []bgp.RISAnnouncement{{NextHop: s.peer.Address, Prefixes: prefixStrings(prefixes)}}
+	g.msgs = append(g.msgs, m)
+}
+
+func (g *generator) withdraw(at time.Time, s session, prefixes []netip.Prefix) {
+	m := g.message(at, s)
+	m.Withdrawals = prefixStrings(prefixes)
+	g.msgs = append(g.msgs, m)
+}
+
+func (g *generator) message(at time.Time, s session) *bgp.RISMessageData {
+	return &bgp.RISMessageData{
+		Timestamp: float64(at.UnixMilli()) / 1000,
+		Type:      "UPDATE",
+		Host:      s.collector,
+		Peer:      s.peer.Address,
+		PeerASN:   strconv.FormatUint(uint64(s.peer.ASN), 10),
+	}
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	s := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		s[i] = p.String()
+	}
+	return s
+}
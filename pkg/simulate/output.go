@@ -0,0 +1,243 @@
+package simulate
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	gobgp "github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/osrg/gobgp/v3/pkg/packet/mrt"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+)
+
+// risFrame is a message as sent by RIS Live.
+type risFrame struct {
+	Type string              `json:"type"`
+	Data *bgp.RISMessageData `json:"data"`
+}
+
+// WriteRISJSON writes messages one per line, framed as RIS Live sends them.
+func WriteRISJSON(w io.Writer, msgs []*bgp.RISMessageData) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, m := range msgs {
+		if err := enc.Encode(risFrame{Type: "ris_message", Data: m}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadRISJSON passes the messages of a RIS-JSON recording, as written by
+// WriteRISJSON, to fn. Lines that are not RIS messages are skipped.
+func ReadRISJSON(r io.Reader, fn func(m *bgp.RISMessageData)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var frame risFrame
+		if err := json.Unmarshal(sc.Bytes(), &frame); err != nil || frame.Type != "ris_message" || frame.Data == nil {
+			continue
+		}
+		fn(frame.Data)
+	}
+	return sc.Err()
+}
+
+// WriteMRT writes the messages of each collector to a gzipped BGP4MP update
+// file named after it in dir, such as rrc00.updates.gz, and returns the
+// paths.
+func WriteMRT(dir string, msgs []*bgp.RISMessageData) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	type mrtWriter struct {
+		f  *os.File
+		gz *gzip.Writer
+	}
+	writers := make(map[string]*mrtWriter)
+	var paths []string
+	closeAll := func() error {
+		var first error
+		for _, w := range writers {
+			if err := w.gz.Close(); err != nil && first == nil {
+				first = err
+			}
+			if err := w.f.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+
+	for _, m := range msgs {
+		w := writers[m.Host]
+		if w == nil {
+			path := filepath.Join(dir, m.Host+".updates.gz")
+			f, err := os.Create(path)
+			if err != nil {
+				_ = closeAll()
+				return nil, err
+			}
+			w = &mrtWriter{f: f, gz: gzip.NewWriter(f)}
+			writers[m.Host] = w
+			paths = append(paths, path)
+		}
+		b, err := encodeMRT(m)
+		if err != nil {
+			_ = closeAll()
+			return nil, fmt.Errorf("message %s: %v", m.ID, err)
+		}
+		if _, err := w.gz.Write(b); err != nil {
+			_ = closeAll()
+			return nil, err
+		}
+	}
+	if err := closeAll(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// encodeMRT encodes a RIS message as a BGP4MP MESSAGE_AS4 record. IPv6
+// prefixes go in the multiprotocol attributes.
+func encodeMRT(m *bgp.RISMessageData) ([]byte, error) {
+	peerASN, err := strconv.ParseUint(m.PeerASN, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer ASN %q", m.PeerASN)
+	}
+	peer, err := netip.ParseAddr(m.Peer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer address %q", m.Peer)
+	}
+
+	var withdrawn, nlri []*gobgp.IPAddrPrefix
+	var withdrawn6, nlri6 []gobgp.AddrPrefixInterface
+	for _, s := range m.Withdrawals {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		if p.Addr().Is4() {
+			withdrawn = append(withdrawn, gobgp.NewIPAddrPrefix(uint8(p.Bits()), p.Addr().String()))
+		} else {
+			withdrawn6 = append(withdrawn6, gobgp.NewIPv6AddrPrefix(uint8(p.Bits()), p.Addr().String()))
+		}
+	}
+
+	var attrs []gobgp.PathAttributeInterface
+	if len(m.Announcements) > 0 {
+		ann := m.Announcements[0]
+		var asns []uint32
+		for _, hop := range m.Hops() {
+			asns = append(asns, hop...)
+		}
+		attrs = append(attrs,
+			gobgp.NewPathAttributeOrigin(0),
+			gobgp.NewPathAttributeAsPath([]gobgp.AsPathParamInterface{gobgp.NewAs4PathParam(gobgp.BGP_ASPATH_ATTR_TYPE_SEQ, asns)}),
+		)
+		for _, s := range ann.Prefixes {
+			p, err := netip.ParsePrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			if p.Addr().Is4() {
+				nlri = append(nlri, gobgp.NewIPAddrPrefix(uint8(p.Bits()), p.Addr().String()))
+			} else {
+				nlri6 = append(nlri6, gobgp.NewIPv6AddrPrefix(uint8(p.Bits()), p.Addr().String()))
+			}
+		}
+		if len(nlri) > 0 {
+			attrs = append(attrs, gobgp.NewPathAttributeNextHop(ann.NextHop))
+		}
+		if len(nlri6) > 0 {
+			nextHop := ann.NextHop
+			if addr, err := netip.ParseAddr(nextHop); err != nil || !addr.Is6() {
+				nextHop = "::"
+			}
+			attrs = append(attrs, gobgp.NewPathAttributeMpReachNLRI(nextHop, nlri6))
+		}
+		var communities []uint32
+		for _, c := range m.Community {
+			if len(c) != 2 {
+				continue
+			}
+			high, ok1 := communityPart(c[0])
+			low, ok2 := communityPart(c[1])
+			if ok1 && ok2 {
+				communities = append(communities, high<<16|low)
+			}
+		}
+		if len(communities) > 0 {
+			attrs = append(attrs, gobgp.NewPathAttributeCommunities(communities))
+		}
+	}
+	if len(withdrawn6) > 0 {
+		attrs = append(attrs, gobgp.NewPathAttributeMpUnreachNLRI(withdrawn6))
+	}
+
+	localIP := "0.0.0.0"
+	if peer.Is6() {
+		localIP = "::"
+	}
+	body := mrt.NewBGP4MPMessage(uint32(peerASN), 0, 0, peer.String(), localIP, true, gobgp.NewBGPUpdateMessage(withdrawn, attrs, nlri))
+	ts := m.Time()
+	msg, err := mrt.NewMRTMessage(uint32(ts.Unix()), mrt.BGP4MP, mrt.MESSAGE_AS4, body)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Serialize()
+}
+
+// communityPart reads one half of a community as decoded from JSON, where it
+// is a float64, or as built by the generator.
+func communityPart(v any) (uint32, bool) {
+	switch n := v.(type) {
+	case float64:
+		return uint32(n), true
+	case uint32:
+		return n, true
+	case int:
+		return uint32(n), true
+	}
+	return 0, false
+}
+
+// Play calls fn with each message at its time, with the time between
+// messages divided by speed, until ctx is done. now is the time of the
+// message. A speed of 0 or less plays the messages as fast as fn takes them.
+func Play(ctx context.Context, msgs []*bgp.RISMessageData, speed float64, fn func(now time.Time, m *bgp.RISMessageData)) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	first := msgs[0].Time()
+	began := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for _, m := range msgs {
+		t := m.Time()
+		if speed > 0 {
+			due := began.Add(time.Duration(float64(t.Sub(first)) / speed))
+			if wait := time.Until(due); wait > 0 {
+				timer.Reset(wait)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fn(t, m)
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+// Package simulate generates reproducible BGP update streams from a scenario:
+// a set of collectors and networks, background churn, and incidents such as
+// hijacks, route leaks, RTBH announcements, outages and peer session resets.
+// The updates are RIS Live messages that can be written as RIS-JSON or MRT,
+// or played into a processor.
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// Event types of a scenario.
+const (
+	EventHijack       = "hijack"
+	EventLeak         = "leak"
+	EventRTBH         = "rtbh"
+	EventOutage       = "outage"
+	EventSessionReset = "session_reset"
+)
+
+// DefaultStart is when a scenario without a start begins.
+var DefaultStart = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+// Scenario describes what to simulate. Durations are Go durations, and the
+// times of events are offsets from the start.
+type Scenario struct {
+	Start    time.Time `json:"start,omitzero"`
+	Duration string    `json:"duration"`
+	// Seed makes the churn and the delays between peers reproducible
+	Seed int64 `json:"seed,omitempty"`
+	// Collectors and their peers. Defaults to two collectors with three
+	// peers each.
+	Collectors []Collector `json:"collectors,omitempty"`
+	Networks   []Network   `json:"networks"`
+	Churn      Churn       `json:"churn"`
+	Events     []Event     `json:"events,omitempty"`
+
+	duration time.Duration
+	// origins maps every prefix of the networks to its network
+	origins map[netip.Prefix]*Network
+}
+
+// Collector is a route collector, named as in the host of its messages.
+type Collector struct {
+	Name  string `json:"name"`
+	Peers []Peer `json:"peers"`
+}
+
+// Peer is a BGP session of a collector.
+type Peer struct {
+	ASN     uint32 `json:"asn"`
+	Address string `json:"address,omitempty"`
+}
+
+// Network is an origin AS, the prefixes it announces and the upstreams its
+// routes reach the peers through. Each peer uses one of the upstreams.
+type Network struct {
+	ASN       uint32   `json:"asn"`
+	Prefixes  []string `json:"prefixes"`
+	Upstreams []uint32 `json:"upstreams,omitempty"`
+
+	prefixes []netip.Prefix
+}
+
+// Churn is the background noise: Rate route changes per minute, each a path
+// change through another upstream or, a Withdraw share of the time, a short
+// withdrawal of a route by one peer.
+type Churn struct {
+	Rate     float64 `json:"rate"`
+	Withdraw float64 `json:"withdraw,omitempty"`
+}
+
+// Event is an incident. Which fields apply depends on the type:
+//   - hijack: ASN announces Prefixes, which are the networks' prefixes or
+//     more-specifics of them, through Upstream;
+//   - leak: ASN re-announces the routes of Prefixes, or of the networks in
+//     ASNs, learned from From to To;
+//   - rtbh: the network covering Prefixes, which are host routes, announces
+//     them with the 65535:666 blackhole community;
+//   - outage: Prefixes, or those of the networks in ASNs, are withdrawn;
+//   - session_reset: Peer of Collector withdraws all of its routes.
+//
+// Routes go back to normal after Duration, or stay that way until the end
+// without one.
+type Event struct {
+	Type     string   `json:"type"`
+	At       string   `json:"at"`
+	Duration string   `json:"duration,omitempty"`
+	Prefixes []string `json:"prefixes,omitempty"`
+	ASNs     []uint32 `json:"asns,omitempty"`
+	ASN      uint32   `json:"asn,omitempty"`
+	Upstream uint32   `json:"upstream,omitempty"`
+	From     uint32   `json:"from,omitempty"`
+	To       uint32   `json:"to,omitempty"`
+	// Collector and Peer identify the session of a session reset
+	Collector string `json:"collector,omitempty"`
+	Peer      uint32 `json:"peer,omitempty"`
+
+	at, duration time.Duration
+	prefixes     []netip.Prefix
+}
+
+var defaultCollectors = []Collector{
+	{Name: "rrc00", Peers: []Peer{{ASN: 1103}, {ASN: 8283}, {ASN: 12779}}},
+	{Name: "rrc01", Peers: []Peer{{ASN: 20205}, {ASN: 25091}, {ASN: 34549}}},
+}
+
+// Upstreams used by networks without their own.
+var defaultUpstreams = []uint32{1299, 3356, 2914}
+
+// Defaults of the ASes that carry hijacks and leaks.
+const (
+	defaultHijackUpstream = 174
+	defaultLeakFrom       = 3491
+	defaultLeakTo         = 3320
+)
+
+// Load reads and checks a scenario file.
+func Load(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %v", path, err)
+	}
+	return &sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	if sc.Start.IsZero() {
+		sc.Start = DefaultStart
+	}
+	d, err := parseDuration("duration", sc.Duration)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	sc.duration = d
+
+	if len(sc.Collectors) == 0 {
+		for _, c := range defaultCollectors {
+			sc.Collectors = append(sc.Collectors, Collector{Name: c.Name, Peers: slices.Clone(c.Peers)})
+		}
+	}
+	names := make(map[string]bool)
+	for i := range sc.Collectors {
+		c := &sc.Collectors[i]
+		if c.Name == "" || names[c.Name] {
+			return fmt.Errorf("collector %d needs a unique name", i+1)
+		}
+		names[c.Name] = true
+		if len(c.Peers) == 0 {
+			return fmt.Errorf("collector %s has no peers", c.Name)
+		}
+		for j := range c.Peers {
+			p := &c.Peers[j]
+			if p.ASN == 0 {
+				return fmt.Errorf("collector %s: peer %d has no ASN", c.Name, j+1)
+			}
+			if p.Address == "" {
+				p.Address = fmt.Sprintf("192.0.2.%d", len(names)*16+j+1)
+			} else if _, err := netip.ParseAddr(p.Address); err != nil {
+				return fmt.Errorf("collector %s: invalid peer address %q", c.Name, p.Address)
+			}
+		}
+	}
+
+	if len(sc.Networks) == 0 {
+		return fmt.Errorf("no networks")
+	}
+	sc.origins = make(map[netip.Prefix]*Network)
+	for i := range sc.Networks {
+		n := &sc.Networks[i]
+		if n.ASN == 0 || len(n.Prefixes) == 0 {
+			return fmt.Errorf("network %d needs an ASN and prefixes", i+1)
+		}
+		if len(n.Upstreams) == 0 {
+			n.Upstreams = defaultUpstreams
+		}
+		n.prefixes, err = parsePrefixes(n.Prefixes)
+		if err != nil {
+			return fmt.Errorf("network AS%d: %v", n.ASN, err)
+		}
+		for _, p := range n.prefixes {
+			if _, ok := sc.origins[p]; ok {
+				return fmt.Errorf("prefix %s is in more than one network", p)
+			}
+			sc.origins[p] = n
+		}
+	}
+
+	if sc.Churn.Rate < 0 || sc.Churn.Withdraw < 0 || sc.Churn.Withdraw > 1 {
+		return fmt.Errorf("churn rate must not be negative and withdraw must be between 0 and 1")
+	}
+	for i := range sc.Events {
+		ev := &sc.Events[i]
+		if err := sc.validateEvent(ev); err != nil {
+			return fmt.Errorf("event %d (%s): %v", i+1, ev.Type, err)
+		}
+	}
+	return nil
+}
+
+func (sc *Scenario) validateEvent(ev *Event) error {
+	var err error
+	if ev.at, err = parseDuration("at", ev.At); err != nil {
+		return err
+	}
+	if ev.at < 0 || ev.at >= sc.duration {
+		return fmt.Errorf("at must be within the scenario")
+	}
+	if ev.Duration != "" {
+		if ev.duration, err = parseDuration("duration", ev.Duration); err != nil {
+			return err
+		}
+	}
+	if ev.duration < 0 {
+		return fmt.Errorf("duration must not be negative")
+	}
+	if ev.prefixes, err = parsePrefixes(ev.Prefixes); err != nil {
+		return err
+	}
+	for _, asn := range ev.ASNs {
+		n := sc.network(asn)
+		if n == nil {
+			return fmt.Errorf("AS%d is not one of the networks", asn)
+		}
+		ev.prefixes = append(ev.prefixes, n.prefixes...)
+	}
+
+	switch ev.Type {
+	case EventHijack:
+		if ev.ASN == 0 {
+			return fmt.Errorf("no hijacking ASN")
+		}
+		if ev.Upstream == 0 {
+			ev.Upstream = defaultHijackUpstream
+		}
+	case EventLeak:
+		if ev.ASN == 0 {
+			return fmt.Errorf("no leaking ASN")
+		}
+		if ev.From == 0 {
+			ev.From = defaultLeakFrom
+		}
+		if ev.To == 0 {
+			ev.To = defaultLeakTo
+		}
+	case EventRTBH:
+		for _, p := range ev.prefixes {
+			if p.Bits() != p.Addr().BitLen() {
+				return fmt.Errorf("%s is not a host route", p)
+			}
+		}
+	case EventOutage:
+	case EventSessionReset:
+		if sc.peer(ev.Collector, ev.Peer) == nil {
+			return fmt.Errorf("no peer AS%d on collector %q", ev.Peer, ev.Collector)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown event type")
+	}
+	if len(ev.prefixes) == 0 {
+		return fmt.Errorf("no prefixes")
+	}
+	for _, p := range ev.prefixes {
+		if sc.covering(p) == nil {
+			return fmt.Errorf("%s is not covered by a network", p)
+		}
+	}
+	return nil
+}
+
+func (sc *Scenario) network(asn uint32) *Network {
+	for i := range sc.Networks {
+		if sc.Networks[i].ASN == asn {
+			return &sc.Networks[i]
+		}
+	}
+	return nil
+}
+
+func (sc *Scenario) peer(collector string, asn uint32) *Peer {
+	for i := range sc.Collectors {
+		if sc.Collectors[i].Name != collector {
+			continue
+		}
+		for j := range sc.Collectors[i].Peers {
+			if sc.Collectors[i].Peers[j].ASN == asn {
+				return &sc.Collectors[i].Peers[j]
+			}
+		}
+	}
+	return nil
+}
+
+// covering returns the network announcing p or a less-specific of it.
+func (sc *Scenario) covering(p netip.Prefix) *Network {
+	for bits := p.Bits(); bits >= 0; bits-- {
+		parent, _ := p.Addr().Prefix(bits)
+		if n, ok := sc.origins[parent]; ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// End is when the scenario ends.
+func (sc *Scenario) End() time.Time {
+	return sc.Start.Add(sc.duration)
+}
+
+// Origins maps every prefix of the networks to its origin, as kept in the
+// seen prefixes database.
+func (sc *Scenario) Origins() map[string]uint32 {
+	origins := make(map[string]uint32, len(sc.origins))
+	for p, n := range sc.origins {
+		origins[p.String()] = n.ASN
+	}
+	return origins
+}
+
+// ROAs authorizes every prefix of the networks for its origin, up to the
+// prefix length, so hijacks are RPKI invalid.
+func (sc *Scenario) ROAs() []utils.VRP {
+	vrps := make([]utils.VRP, 0, len(sc.origins))
+	for p, n := range sc.origins {
+		vrps = append(vrps, utils.VRP{Prefix: p.String(), MaxLength: p.Bits(), ASN: n.ASN})
+	}
+	return vrps
+}
+
+func parseDuration(field, s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", field, s, err)
+	}
+	return d, nil
+}
+
+func parsePrefixes(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q: %v", s, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,229 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gobgp "github.com/osrg/gobgp/v3/pkg/packet/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/eval"
+	"github.com/sudorandom/bgp-stream/pkg/mrtfile"
+)
+
+func loadTestScenario(t *testing.T) *Scenario {
+	t.Helper()
+	sc, err := Load(filepath.Join("testdata", "scenario.json"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return sc
+}
+
+func TestGenerate(t *testing.T) {
+	sc := loadTestScenario(t)
+	msgs := Generate(sc)
+	if len(msgs) == 0 {
+		t.Fatal("Expected messages")
+	}
+
+	again := Generate(loadTestScenario(t))
+	a, _ := json.Marshal(msgs)
+	b, _ := json.Marshal(again)
+	if !bytes.Equal(a, b) {
+		t.Errorf("Expected the same scenario to generate the same messages")
+	}
+
+	hosts := make(map[string]bool)
+	for i, m := range msgs {
+		ts := m.Time()
+		if ts.Before(sc.Start) || ts.After(sc.End()) {
+			t.Errorf("Message %s at %v is outside the scenario", m.ID, ts)
+		}
+		if i > 0 && m.Timestamp < msgs[i-1].Timestamp {
+			t.Errorf("Message %s is out of order", m.ID)
+		}
+		hosts[m.Host] = true
+	}
+	if !hosts["rrc00"] || !hosts["rrc01"] {
+		t.Errorf("Expected messages from the default collectors, got %v", hosts)
+	}
+
+	// The hijacker's route reaches every peer
+	hijacked := 0
+	for _, m := range msgs {
+		hops := m.Hops()
+		if len(m.Announcements) > 0 && len(hops) > 0 && hops[len(hops)-1][0] == 202666 {
+			hijacked++
+		}
+	}
+	if hijacked != 6 {
+		t.Errorf("Expected 6 hijacked routes, got %d", hijacked)
+	}
+}
+
+// TestScenarioClassification replays a scenario through the classifier and
+// checks every incident in it is detected.
+func TestScenarioClassification(t *testing.T) {
+	sc := loadTestScenario(t)
+	dir := t.TempDir()
+	if _, err := WriteMRT(dir, Generate(sc)); err != nil {
+		t.Fatalf("WriteMRT failed: %v", err)
+	}
+	origins, _ := json.Marshal(sc.Origins())
+	roas, _ := json.Marshal(sc.ROAs())
+	incident := func(name, start, end, classification, prefixes string, leaker, victim uint32) string {
+		return fmt.Sprintf(`{"name": %q, "start": "2024-03-01T%s:00Z", "end": "2024-03-01T%s:00Z", "classification": %q, "prefixes": [%s], "leaker": %d, "victim": %d, "mrt": ["*.updates.gz"], "origins": %s, "roas": %s}`,
+			name, start, end, classification, prefixes, leaker, victim, origins, roas)
+	}
+	catalogue := fmt.Sprintf(`{"incidents": [%s, %s, %s, %s, %s, %s]}`,
+		incident("leak", "10:05", "10:15", "route_leak", `"185.10.0.0/24", "185.11.0.0/24"`, 8220, 3491),
+		incident("hijack", "10:05", "10:12", "bgp_hijack", `"185.40.0.0/22"`, 202666, 201040),
+		incident("rtbh", "10:08", "10:13", "ddos_mitigation", `"185.50.0.1/32"`, 0, 0),
+		incident("outage", "10:10", "10:25", "outage", `"185.30.0.0/24", "185.31.0.0/24"`, 0, 0),
+		incident("session-reset", "10:30", "10:40", "none", `"185.0.0.0/8"`, 0, 0),
+		incident("quiet", "10:00", "10:40", "none", `"185.51.0.0/24"`, 0, 0),
+	)
+	path := filepath.Join(dir, "catalogue.json")
+	if err := os.WriteFile(path, []byte(catalogue), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := eval.LoadCatalogue(path)
+	if err != nil {
+		t.Fatalf("LoadCatalogue failed: %v", err)
+	}
+	r, err := eval.Run(c, eval.Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for _, res := range r.Incidents {
+		if !res.Detected || res.FalsePositives > 0 {
+			t.Errorf("%s: detected %v as %v with %d false positives: %+v",
+				res.Incident.Name, res.Detected, res.Predicted, res.FalsePositives, res.Detections)
+		}
+	}
+}
+
+func TestRISJSON(t *testing.T) {
+	msgs := Generate(loadTestScenario(t))
+	var buf bytes.Buffer
+	if err := WriteRISJSON(&buf, msgs); err != nil {
+		t.Fatalf("WriteRISJSON failed: %v", err)
+	}
+	buf.WriteString("{\"type\": \"ris_error\", \"data\": {}}\n")
+
+	var read []*bgp.RISMessageData
+	if err := ReadRISJSON(&buf, func(m *bgp.RISMessageData) { read = append(read, m) }); err != nil {
+		t.Fatalf("ReadRISJSON failed: %v", err)
+	}
+	if len(read) != len(msgs) {
+		t.Fatalf("Expected %d messages, got %d", len(msgs), len(read))
+	}
+	for i := range msgs {
+		if read[i].ID != msgs[i].ID || read[i].Timestamp != msgs[i].Timestamp ||
+			!reflect.DeepEqual(read[i].Announcements, msgs[i].Announcements) ||
+			!reflect.DeepEqual(read[i].Withdrawals, msgs[i].Withdrawals) ||
+			!reflect.DeepEqual(read[i].Hops(), msgs[i].Hops()) {
+			t.Fatalf("Message %d differs: %+v, want %+v", i, read[i], msgs[i])
+		}
+	}
+}
+
+func TestWriteMRT(t *testing.T) {
+	msgs := Generate(loadTestScenario(t))
+	paths, err := WriteMRT(t.TempDir(), msgs)
+	if err != nil {
+		t.Fatalf("WriteMRT failed: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("Expected a file per collector, got %v", paths)
+	}
+
+	want := make(map[string]int)
+	for _, m := range msgs {
+		for _, a := range m.Announcements {
+			want[m.Host] += len(a.Prefixes)
+		}
+		want[m.Host] += len(m.Withdrawals)
+	}
+	got := make(map[string]int)
+	blackholed := false
+	for _, path := range paths {
+		collector := filepath.Base(path)[:5]
+		mrtfile.ScanMessages(collector, path, func(msg *mrtfile.Message) {
+			update := msg.Message.Body.(*gobgp.BGPUpdate)
+			got[collector] += len(update.NLRI) + len(update.WithdrawnRoutes)
+			if ctx := mrtfile.Context(msg, update.PathAttributes); ctx.CommStr == "[65535:666]" {
+				blackholed = true
+			}
+		})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected prefixes per collector %v, got %v", want, got)
+	}
+	if !blackholed {
+		t.Errorf("Expected the RTBH route to keep its community")
+	}
+}
+
+func TestPlay(t *testing.T) {
+	msgs := Generate(loadTestScenario(t))
+	var played int
+	var last time.Time
+	err := Play(context.Background(), msgs, 0, func(now time.Time, m *bgp.RISMessageData) {
+		if now.Before(last) {
+			t.Errorf("Message %s played out of order", m.ID)
+		}
+		last = now
+		played++
+	})
+	if err != nil || played != len(msgs) {
+		t.Errorf("Expected %d messages played, got %d (%v)", len(msgs), played, err)
+	}
+
+	// At real time only the first messages play before the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	played = 0
+	err = Play(ctx, msgs, 1, func(time.Time, *bgp.RISMessageData) { played++ })
+	if err == nil || played == 0 || played >= len(msgs) {
+		t.Errorf("Expected a partial replay ending with an error, played %d (%v)", played, err)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	network := `"networks": [{"asn": 64500, "prefixes": ["192.0.2.0/24"]}]`
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"duration", `{"duration": "forever", ` + network + `}`},
+		{"networks", `{"duration": "10m"}`},
+		{"prefix", `{"duration": "10m", "networks": [{"asn": 64500, "prefixes": ["192.0.2.0"]}]}`},
+		{"overlap", `{"duration": "10m", "networks": [{"asn": 64500, "prefixes": ["192.0.2.0/24"]}, {"asn": 64501, "prefixes": ["192.0.2.0/24"]}]}`},
+		{"churn", `{"duration": "10m", "churn": {"rate": 1, "withdraw": 2}, ` + network + `}`},
+		{"type", `{"duration": "10m", ` + network + `, "events": [{"type": "meteor", "at": "1m", "prefixes": ["192.0.2.0/24"]}]}`},
+		{"at", `{"duration": "10m", ` + network + `, "events": [{"type": "outage", "at": "11m", "prefixes": ["192.0.2.0/24"]}]}`},
+		{"uncovered", `{"duration": "10m", ` + network + `, "events": [{"type": "outage", "at": "1m", "prefixes": ["198.51.100.0/24"]}]}`},
+		{"hijacker", `{"duration": "10m", ` + network + `, "events": [{"type": "hijack", "at": "1m", "prefixes": ["192.0.2.0/24"]}]}`},
+		{"host route", `{"duration": "10m", ` + network + `, "events": [{"type": "rtbh", "at": "1m", "prefixes": ["192.0.2.0/24"]}]}`},
+		{"session", `{"duration": "10m", ` + network + `, "events": [{"type": "session_reset", "at": "1m", "collector": "rrc00", "peer": 64999}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scenario.json")
+			if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
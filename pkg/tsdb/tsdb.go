@@ -0,0 +1,402 @@
+// Package tsdb is a small embedded time-series store that keeps metric history
+// at several resolutions, each with its own retention.
+package tsdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// DefaultPath is where the viewer stores its metric history unless configured
+// otherwise.
+const DefaultPath = "./data/metrics.db"
+
+type Kind int
+
+const (
+	// Counter values are summed over a bucket.
+	Counter Kind = iota
+	// Gauge values are averaged over a bucket, weighted by time.
+	Gauge
+)
+
+type Field struct {
+	Name string `json:"name"`
+	Kind Kind   `json:"kind"`
+}
+
+type Resolution struct {
+	Name      string
+	Step      time.Duration
+	Retention time.Duration
+}
+
+// Resolutions are the bucket sizes every sample is recorded at.
+var Resolutions = []Resolution{
+	{Name: "2s", Step: 2 * time.Second, Retention: time.Hour},
+	{Name: "1m", Step: time.Minute, Retention: 7 * 24 * time.Hour},
+	{Name: "1h", Step: time.Hour, Retention: 365 * 24 * time.Hour},
+}
+
+// ParseResolution returns the resolution with the given name.
+func ParseResolution(name string) (Resolution, bool) {
+	for _, r := range Resolutions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Resolution{}, false
+}
+
+// Sample is one bucket of history. Counters hold the total over the bucket
+// and gauges the time-weighted mean. Seconds is how much of the bucket was
+// actually observed.
+type Sample struct {
+	Time    time.Time
+	Seconds float64
+	Values  []float64
+}
+
+// Rate returns the per-second rate of the counter at index i.
+func (s *Sample) Rate(i int) float64 {
+	if s.Seconds <= 0 {
+		return 0
+	}
+	return s.Values[i] / s.Seconds
+}
+
+var schemaKey = []byte("schema")
+
+type bucket struct {
+	start  int64
+	sample Sample
+}
+
+type Store struct {
+	db     *badger.DB
+	fields []Field
+
+	mu      sync.Mutex
+	pending []*bucket
+}
+
+func badgerOptions(path string) badger.Options {
+	opts := badger.DefaultOptions(path)
+	opts.Logger = nil
+	// The store is tiny compared to the prefix databases
+	opts.MemTableSize = 8 << 20
+	opts.ValueLogFileSize = 16 << 20
+	opts.BlockCacheSize = 8 << 20
+	opts.IndexCacheSize = 4 << 20
+	return opts
+}
+
+// Open opens or creates the store at path for recording the given fields.
+// Samples written with an older field list are remapped by field name.
+func Open(path string, fields []Field) (*Store, error) {
+	db, err := badger.Open(badgerOptions(path))
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, fields: fields, pending: make([]*bucket, len(Resolutions))}
+	if err := s.migrate(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenReadOnly opens an existing store for querying with the fields it was
+// last written with.
+func OpenReadOnly(path string) (*Store, error) {
+	opts := badgerOptions(path)
+	opts.ReadOnly = true
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, pending: make([]*bucket, len(Resolutions))}
+	if s.fields, err = s.storedFields(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Fields() []Field {
+	return s.fields
+}
+
+func (s *Store) storedFields() ([]Field, error) {
+	var fields []Field
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(schemaKey)
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			return json.Unmarshal(v, &fields)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metric schema: %v", err)
+	}
+	return fields, nil
+}
+
+// migrate rewrites stored samples when the field list has changed.
+func (s *Store) migrate() error {
+	old, err := s.storedFields()
+	if err != nil {
+		return err
+	}
+	if old != nil && !sameFields(old, s.fields) {
+		index := make(map[string]int, len(s.fields))
+		for i, f := range s.fields {
+			index[f.Name] = i
+		}
+		err := s.db.Update(func(txn *badger.Txn) error {
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+			for it.Rewind(); it.Valid(); it.Next() {
+				item := it.Item()
+				if len(item.Key()) != 9 {
+					continue
+				}
+				v, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				sample := decodeSample(v)
+				remapped := make([]float64, len(s.fields))
+				for i, f := range old {
+					if j, ok := index[f.Name]; ok && i < len(sample.Values) {
+						remapped[j] = sample.Values[i]
+					}
+				}
+				sample.Values = remapped
+				e := badger.NewEntry(item.KeyCopy(nil), encodeSample(&sample))
+				if exp := item.ExpiresAt(); exp != 0 {
+					e.ExpiresAt = exp
+				}
+				if err := txn.SetEntry(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate metric history: %v", err)
+		}
+	}
+	data, err := json.Marshal(s.fields)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(schemaKey, data)
+	})
+}
+
+func sameFields(a, b []Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sampleKey(res int, start int64) []byte {
+	key := make([]byte, 9)
+	key[0] = byte(res)
+	binary.BigEndian.PutUint64(key[1:], uint64(start))
+	return key
+}
+
+func encodeSample(sample *Sample) []byte {
+	buf := make([]byte, 8*(len(sample.Values)+1))
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(sample.Seconds))
+	for i, v := range sample.Values {
+		binary.LittleEndian.PutUint64(buf[8*(i+1):], math.Float64bits(v))
+	}
+	return buf
+}
+
+func decodeSample(buf []byte) Sample {
+	var sample Sample
+	if len(buf) < 8 {
+		return sample
+	}
+	sample.Seconds = math.Float64frombits(binary.LittleEndian.Uint64(buf))
+	n := len(buf)/8 - 1
+	sample.Values = make([]float64, n)
+	for i := 0; i < n; i++ {
+		sample.Values[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*(i+1):]))
+	}
+	return sample
+}
+
+// Add records values observed over the given number of seconds ending at t.
+// Completed buckets are written to disk.
+func (s *Store) Add(t time.Time, seconds float64, values []float64) error {
+	if len(values) != len(s.fields) {
+		return fmt.Errorf("expected %d metric values, got %d", len(s.fields), len(values))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ri, res := range Resolutions {
+		start := t.Truncate(res.Step).Unix()
+		b := s.pending[ri]
+		if b != nil && b.start != start {
+			if err := s.write(ri, b); err != nil {
+				return err
+			}
+			b = nil
+		}
+		if b == nil {
+			// Resume a bucket that was partially written before a restart
+			b = &bucket{start: start, sample: s.load(ri, start)}
+			s.pending[ri] = b
+		}
+		b.sample.add(s.fields, seconds, values)
+	}
+	return nil
+}
+
+func (sample *Sample) add(fields []Field, seconds float64, values []float64) {
+	if len(sample.Values) != len(fields) {
+		sample.Values = make([]float64, len(fields))
+		sample.Seconds = 0
+	}
+	total := sample.Seconds + seconds
+	for i, f := range fields {
+		switch f.Kind {
+		case Counter:
+			sample.Values[i] += values[i]
+		case Gauge:
+			if total > 0 {
+				sample.Values[i] = (sample.Values[i]*sample.Seconds + values[i]*seconds) / total
+			}
+		}
+	}
+	sample.Seconds = total
+}
+
+func (s *Store) load(res int, start int64) Sample {
+	var sample Sample
+	_ = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(sampleKey(res, start))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			sample = decodeSample(v)
+			return nil
+		})
+	})
+	return sample
+}
+
+func (s *Store) write(res int, b *bucket) error {
+	e := badger.NewEntry(sampleKey(res, b.start), encodeSample(&b.sample)).
+		WithTTL(Resolutions[res].Retention + Resolutions[res].Step)
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(e)
+	})
+}
+
+// Flush writes the buckets that are still being filled, so that they survive
+// a restart.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ri, b := range s.pending {
+		if b == nil {
+			continue
+		}
+		if err := s.write(ri, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range returns the stored samples of a resolution with bucket start times in
+// [from, to), oldest first. Buckets that are still being filled are included.
+func (s *Store) Range(res Resolution, from, to time.Time) ([]Sample, error) {
+	ri := -1
+	for i, r := range Resolutions {
+		if r == res {
+			ri = i
+		}
+	}
+	if ri < 0 {
+		return nil, fmt.Errorf("unknown resolution %q", res.Name)
+	}
+
+	var samples []Sample
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte{byte(ri)}
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		end := sampleKey(ri, to.Unix())
+		for it.Seek(sampleKey(ri, from.Truncate(res.Step).Unix())); it.Valid(); it.Next() {
+			item := it.Item()
+			if string(item.Key()) >= string(end) {
+				break
+			}
+			start := int64(binary.BigEndian.Uint64(item.Key()[1:]))
+			err := item.Value(func(v []byte) error {
+				sample := decodeSample(v)
+				sample.Time = time.Unix(start, 0)
+				samples = append(samples, sample)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if b := s.pending[ri]; b != nil && b.start >= from.Truncate(res.Step).Unix() && b.start < to.Unix() {
+		sample := Sample{Time: time.Unix(b.start, 0), Seconds: b.sample.Seconds, Values: append([]float64(nil), b.sample.Values...)}
+		if n := len(samples); n > 0 && samples[n-1].Time.Unix() == b.start {
+			samples[n-1] = sample
+		} else {
+			samples = append(samples, sample)
+		}
+	}
+	s.mu.Unlock()
+	return samples, nil
+}
+
+// Close flushes buckets that are still being filled and closes the store.
+func (s *Store) Close() error {
+	if !s.db.Opts().ReadOnly {
+		if err := s.Flush(); err != nil {
+			_ = s.db.Close()
+			return err
+		}
+	}
+	return s.db.Close()
+}
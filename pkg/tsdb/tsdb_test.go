@@ -0,0 +1,120 @@
+package tsdb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testFields = []Field{
+	{Name: "msgs", Kind: Counter},
+	{Name: "ips", Kind: Gauge},
+}
+
+func TestStore_Downsampling(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, testFields)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Two minutes of one-second samples: 10 msgs/sec, ips ramping up by one
+	for i := 0; i < 120; i++ {
+		if err := s.Add(base.Add(time.Duration(i)*time.Second), 1, []float64{10, float64(i)}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	res, _ := ParseResolution("1m")
+	samples, err := s.Range(res, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 one-minute samples, got %d", len(samples))
+	}
+	first := samples[0]
+	if first.Seconds != 60 || first.Values[0] != 600 || first.Rate(0) != 10 {
+		t.Errorf("unexpected first bucket: %+v", first)
+	}
+	if first.Values[1] != 29.5 {
+		t.Errorf("expected gauge mean 29.5, got %v", first.Values[1])
+	}
+
+	res, _ = ParseResolution("2s")
+	samples, err = s.Range(res, base.Add(10*time.Second), base.Add(20*time.Second))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if len(samples) != 5 || !samples[0].Time.Equal(base.Add(10*time.Second)) || samples[0].Values[0] != 20 {
+		t.Errorf("unexpected 2s samples: %+v", samples)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The partial hour bucket survives a restart and keeps accumulating
+	s, err = Open(dir, testFields)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := s.Add(base.Add(3*time.Minute), 1, []float64{10, 0}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	res, _ = ParseResolution("1h")
+	samples, err = s.Range(res, base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Seconds != 121 || samples[0].Values[0] != 1210 {
+		t.Errorf("unexpected hour bucket after restart: %+v", samples)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestStore_SchemaChange(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "metrics")
+	s, err := Open(dir, testFields)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Add(base, 1, []float64{5, 7}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	fields := []Field{{Name: "ips", Kind: Gauge}, {Name: "new", Kind: Counter}, {Name: "msgs", Kind: Counter}}
+	s, err = Open(dir, fields)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s, err = OpenReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer func() { _ = s.Close() }()
+	if len(s.Fields()) != 3 || s.Fields()[2].Name != "msgs" {
+		t.Fatalf("unexpected stored fields: %+v", s.Fields())
+	}
+	samples, err := s.Range(Resolutions[0], base, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	if v := samples[0].Values; v[0] != 7 || v[1] != 0 || v[2] != 5 {
+		t.Errorf("values not remapped by name: %v", v)
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"bufio"
 	"compress/gzip"
 	"encoding/json"
+	"io"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type ASNInfo struct {
@@ -18,6 +21,8 @@ type ASNInfo struct {
 
 type ASNMapping struct {
 	data map[uint32]ASNInfo
+	// dataTime is when the oldest source was downloaded
+	dataTime time.Time
 }
 
 func NewASNMapping() *ASNMapping {
@@ -49,6 +54,18 @@ func (m *ASNMapping) Load() error {
 	return nil
 }
 
+// DataTime returns when the oldest loaded source was downloaded, or the zero
+// time if nothing was loaded.
+func (m *ASNMapping) DataTime() time.Time {
+	return m.dataTime
+}
+
+func (m *ASNMapping) noteDataTime(r io.Reader) {
+	if t := DataTime(r); m.dataTime.IsZero() || t.Before(m.dataTime) {
+		m.dataTime = t
+	}
+}
+
 func (m *ASNMapping) loadCustomOrgs() {
 	// Hardcoded known associations that are often missed or split in CAIDA
 	knownSiblings := map[uint32]string{
@@ -197,6 +214,7 @@ func (m *ASNMapping) loadCAIDA() error {
 			log.Printf("Error closing CAIDA reader: %v", err)
 		}
 	}()
+	m.noteDataTime(r)
 
 	gr, err := gzip.NewReader(r)
 	if err != nil {
@@ -307,6 +325,7 @@ func (m *ASNMapping) loadThyme() error {
 			log.Printf("Error closing Thyme ASN reader: %v", err)
 		}
 	}()
+	m.noteDataTime(r)
 
 	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
@@ -349,6 +368,7 @@ func (m *ASNMapping) loadPeeringDB() error {
 			log.Printf("Error closing PeeringDB ASN reader: %v", err)
 		}
 	}()
+	m.noteDataTime(r)
 
 	var response struct {
 		Data []struct {
@@ -372,6 +392,16 @@ func (m *ASNMapping) loadPeeringDB() error {
 	return nil
 }
 
+// ASNs returns every ASN with a mapping, in ascending order.
+func (m *ASNMapping) ASNs() []uint32 {
+	asns := make([]uint32, 0, len(m.data))
+	for asn := range m.data {
+		asns = append(asns, asn)
+	}
+	slices.Sort(asns)
+	return asns
+}
+
 func (m *ASNMapping) GetName(asn uint32) string {
 	if info, ok := m.data[asn]; ok {
 		return info.Name
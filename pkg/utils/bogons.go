@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"sort"
+	"time"
+)
+
+// FullBogonsPath is where the unallocated address and ASN space derived from
+// the RIR delegated stats is stored.
+const FullBogonsPath = "./data/full-bogons.json"
+
+type SpecialASNRange struct {
+	Start, End uint32
+	Reason     string
+}
+
+// SpecialPurposeASNs is the IANA special-purpose AS number registry, plus the
+// remaining reserved ranges that should never appear in the global table.
+var SpecialPurposeASNs = []SpecialASNRange{
+	{0, 0, "AS0, reserved (RFC 7607)"},
+	{23456, 23456, "AS_TRANS (RFC 6793)"},
+	{64496, 64511, "Documentation ASN (RFC 5398)"},
+	{64512, 65534, "Private-Use ASN (RFC 6996)"},
+	{65535, 65535, "Reserved ASN (RFC 7300)"},
+	{65536, 65551, "Documentation ASN (RFC 5398)"},
+	{65552, 131071, "Reserved ASN (IANA)"},
+	{4200000000, 4294967294, "Private-Use ASN (RFC 6996)"},
+	{4294967295, 4294967295, "Reserved ASN (RFC 7300)"},
+}
+
+type SpecialPrefix struct {
+	Prefix netip.Prefix
+	Reason string
+}
+
+// SpecialPurposePrefixes is the IANA IPv4 and IPv6 special-purpose address
+// registries, restricted to blocks that are not globally reachable, plus the
+// deprecated IPv6 blocks that bogon filters still drop.
+var SpecialPurposePrefixes = []SpecialPrefix{
+	{netip.MustParsePrefix("0.0.0.0/8"), "\"This network\" (RFC 791)"},
+	{netip.MustParsePrefix("10.0.0.0/8"), "Private-Use (RFC 1918)"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "Shared Address Space / CGNAT (RFC 6598)"},
+	{netip.MustParsePrefix("127.0.0.0/8"), "Loopback (RFC 1122)"},
+	{netip.MustParsePrefix("169.254.0.0/16"), "Link Local (RFC 3927)"},
+	{netip.MustParsePrefix("172.16.0.0/12"), "Private-Use (RFC 1918)"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "IETF Protocol Assignments (RFC 6890)"},
+	{netip.MustParsePrefix("192.0.2.0/24"), "Documentation TEST-NET-1 (RFC 5737)"},
+	{netip.MustParsePrefix("192.88.99.0/24"), "Deprecated 6to4 Relay Anycast (RFC 7526)"},
+	{netip.MustParsePrefix("192.168.0.0/16"), "Private-Use (RFC 1918)"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "Benchmarking (RFC 2544)"},
+	{netip.MustParsePrefix("198.51.100.0/24"), "Documentation TEST-NET-2 (RFC 5737)"},
+	{netip.MustParsePrefix("203.0.113.0/24"), "Documentation TEST-NET-3 (RFC 5737)"},
+	{netip.MustParsePrefix("224.0.0.0/4"), "Multicast (RFC 5771)"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "Reserved for Future Use (RFC 1112)"},
+	{netip.MustParsePrefix("::/128"), "Unspecified Address (RFC 4291)"},
+	{netip.MustParsePrefix("::1/128"), "Loopback Address (RFC 4291)"},
+	{netip.MustParsePrefix("::ffff:0:0/96"), "IPv4-mapped Address (RFC 4291)"},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), "IPv4-IPv6 Translation, local use (RFC 8215)"},
+	{netip.MustParsePrefix("100::/64"), "Discard-Only Address Block (RFC 6666)"},
+	{netip.MustParsePrefix("2001:2::/48"), "Benchmarking (RFC 5180)"},
+	{netip.MustParsePrefix("2001:10::/28"), "Deprecated ORCHID (RFC 4843)"},
+	{netip.MustParsePrefix("2001:db8::/32"), "Documentation (RFC 3849)"},
+	{netip.MustParsePrefix("2002::/16"), "6to4 (RFC 3056)"},
+	{netip.MustParsePrefix("3fff::/20"), "Documentation (RFC 9637)"},
+	{netip.MustParsePrefix("5f00::/16"), "Segment Routing SIDs (RFC 9602)"},
+	{netip.MustParsePrefix("fc00::/7"), "Unique-Local (RFC 4193)"},
+	{netip.MustParsePrefix("fe80::/10"), "Link-Local Unicast (RFC 4291)"},
+	{netip.MustParsePrefix("fec0::/10"), "Deprecated Site-Local (RFC 3879)"},
+	{netip.MustParsePrefix("ff00::/8"), "Multicast (RFC 4291)"},
+}
+
+// SpecialPurposeASN returns the registry entry matching asn, if any.
+func SpecialPurposeASN(asn uint32) (string, bool) {
+	for _, r := range SpecialPurposeASNs {
+		if asn >= r.Start && asn <= r.End {
+			return r.Reason, true
+		}
+	}
+	return "", false
+}
+
+// SpecialPurposePrefix returns the registry entry covering pfx, if any. A
+// prefix only matches when it is equal to or more specific than the block.
+func SpecialPurposePrefix(pfx netip.Prefix) (string, bool) {
+	if pfx.Bits() == 0 {
+		return "Default route", true
+	}
+	for _, sp := range SpecialPurposePrefixes {
+		if pfx.Bits() >= sp.Prefix.Bits() && sp.Prefix.Contains(pfx.Addr()) {
+			return fmt.Sprintf("%s in %s", sp.Reason, sp.Prefix), true
+		}
+	}
+	return "", false
+}
+
+// Range is an inclusive range of IPv4 addresses or AS numbers.
+type Range struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+}
+
+// FullBogons holds IPv4 address space and AS numbers that no RIR has
+// allocated or assigned. Ranges are sorted and non-overlapping.
+type FullBogons struct {
+	IPv4 []Range `json:"ipv4"`
+	ASNs []Range `json:"asns"`
+}
+
+// NewFullBogons derives unallocated space from the allocated IPv4 and ASN
+// ranges listed in the RIR delegated stats files.
+func NewFullBogons(allocatedIPv4, allocatedASNs []Range) *FullBogons {
+	return &FullBogons{
+		IPv4: complementRanges(allocatedIPv4, 0),
+		// AS0 is covered by the special-purpose registry
+		ASNs: complementRanges(allocatedASNs, 1),
+	}
+}
+
+func complementRanges(allocated []Range, minValue uint32) []Range {
+	sorted := make([]Range, len(allocated))
+	copy(sorted, allocated)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var res []Range
+	next := uint64(minValue)
+	for _, r := range sorted {
+		if uint64(r.Start) > next {
+			res = append(res, Range{Start: uint32(next), End: r.Start - 1})
+		}
+		if uint64(r.End)+1 > next {
+			next = uint64(r.End) + 1
+		}
+	}
+	if next <= 0xFFFFFFFF {
+		res = append(res, Range{Start: uint32(next), End: 0xFFFFFFFF})
+	}
+	return res
+}
+
+func containsRange(ranges []Range, start, end uint32) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].End >= start })
+	return i < len(ranges) && ranges[i].Start <= start && ranges[i].End >= end
+}
+
+// IsUnallocatedPrefix reports whether the whole prefix lies in unallocated space.
+func (b *FullBogons) IsUnallocatedPrefix(pfx netip.Prefix) bool {
+	if !pfx.Addr().Is4() {
+		return false
+	}
+	a := pfx.Masked().Addr().As4()
+	start := binary.BigEndian.Uint32(a[:])
+	end := start | uint32(uint64(1)<<(32-pfx.Bits())-1)
+	return containsRange(b.IPv4, start, end)
+}
+
+// IsUnallocatedASN reports whether no RIR has allocated or assigned asn.
+func (b *FullBogons) IsUnallocatedASN(asn uint32) bool {
+	return containsRange(b.ASNs, asn, asn)
+}
+
+// Save writes the list to a temporary file and renames it over path, so a
+// FullBogonsWatcher never reads a partly written file.
+func (b *FullBogons) Save(path string) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func LoadFullBogons(path string) (*FullBogons, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b := &FullBogons{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to parse full bogons: %v", err)
+	}
+	return b, nil
+}
+
+// fullBogonsReloadInterval is how often a FullBogonsWatcher checks its file
+const fullBogonsReloadInterval = time.Minute
+
+// FullBogonsWatcher hands the full bogons file to a long-running process and
+// swaps in the new list whenever bgp-cli fetch rewrites it after a RIR
+// refresh.
+type FullBogonsWatcher struct {
+	path  string
+	apply func(*FullBogons)
+
+	modTime time.Time
+	size    int64
+}
+
+// NewFullBogonsWatcher returns a watcher that passes every version of the file
+// at path to apply. Nothing is loaded until Reload or Run is called.
+func NewFullBogonsWatcher(path string, apply func(*FullBogons)) *FullBogonsWatcher {
+	return &FullBogonsWatcher{path: path, apply: apply}
+}
+
+// Reload applies the file if it changed since it was last read. A file that
+// fails to load is reported once and the previous list stays in place.
+func (w *FullBogonsWatcher) Reload() error {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return nil
+	}
+	w.modTime, w.size = fi.ModTime(), fi.Size()
+	b, err := LoadFullBogons(w.path)
+	if err != nil {
+		return err
+	}
+	w.apply(b)
+	log.Printf("[BOGONS] Loaded %d unallocated IPv4 ranges and %d unallocated ASN ranges", len(b.IPv4), len(b.ASNs))
+	return nil
+}
+
+// Run reloads the file whenever it changes until ctx is canceled. A missing
+// file is not reported, so the list is picked up once bgp-cli fetch has run.
+func (w *FullBogonsWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(fullBogonsReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.Reload(); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Warning: Failed to reload full bogons, keeping the previous list: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSpecialPurposeASN(t *testing.T) {
+	tests := []struct {
+		asn     uint32
+		special bool
+	}{
+		{0, true},
+		{23456, true},
+		{64496, true},
+		{65000, true},
+		{65535, true},
+		{65540, true},
+		{100000, true},
+		{4200000001, true},
+		{4294967295, true},
+		{13335, false},
+		{131072, false},
+		{396998, false},
+	}
+	for _, tt := range tests {
+		if _, ok := SpecialPurposeASN(tt.asn); ok != tt.special {
+			t.Errorf("SpecialPurposeASN(%d) = %v, want %v", tt.asn, ok, tt.special)
+		}
+	}
+}
+
+func TestSpecialPurposePrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		reason string
+	}{
+		{"0.0.0.0/0", "Default route"},
+		{"10.1.0.0/16", "Private-Use (RFC 1918) in 10.0.0.0/8"},
+		{"100.64.0.0/10", "Shared Address Space / CGNAT (RFC 6598) in 100.64.0.0/10"},
+		{"198.18.0.0/24", "Benchmarking (RFC 2544) in 198.18.0.0/15"},
+		{"192.0.0.0/8", ""}, // Less specific than 192.0.0.0/24
+		{"1.1.1.0/24", ""},
+		{"::/0", "Default route"},
+		{"::1/128", "Loopback Address (RFC 4291) in ::1/128"},
+		{"::ffff:10.0.0.0/104", "IPv4-mapped Address (RFC 4291) in ::ffff:0.0.0.0/96"},
+		{"2001:db8:1::/48", "Documentation (RFC 3849) in 2001:db8::/32"},
+		{"3fff:100::/24", "Documentation (RFC 9637) in 3fff::/20"},
+		{"fd12:3456::/32", "Unique-Local (RFC 4193) in fc00::/7"},
+		{"fe80::/64", "Link-Local Unicast (RFC 4291) in fe80::/10"},
+		{"ff02::/16", "Multicast (RFC 4291) in ff00::/8"},
+		{"2001::/16", ""}, // Less specific than 2001:db8::/32
+		{"2606:4700::/32", ""},
+	}
+	for _, tt := range tests {
+		reason, _ := SpecialPurposePrefix(netip.MustParsePrefix(tt.prefix))
+		if reason != tt.reason {
+			t.Errorf("SpecialPurposePrefix(%s) = %q, want %q", tt.prefix, reason, tt.reason)
+		}
+	}
+}
+
+func TestFullBogons(t *testing.T) {
+	ipRange := func(start, end string) Range {
+		s, e := netip.MustParseAddr(start).As4(), netip.MustParseAddr(end).As4()
+		return Range{Start: IPToUint32(s[:]), End: IPToUint32(e[:])}
+	}
+	b := NewFullBogons(
+		[]Range{ipRange("1.0.0.0", "1.255.255.255"), ipRange("3.0.0.0", "3.0.255.255"), ipRange("1.0.0.0", "1.0.0.255")},
+		[]Range{{Start: 1, End: 1000}, {Start: 2000, End: 3000}},
+	)
+
+	path := filepath.Join(t.TempDir(), "full-bogons.json")
+	if err := b.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	b, err := LoadFullBogons(path)
+	if err != nil {
+		t.Fatalf("LoadFullBogons() error = %v", err)
+	}
+
+	prefixes := []struct {
+		prefix      string
+		unallocated bool
+	}{
+		{"1.1.1.0/24", false},
+		{"2.0.0.0/8", true},
+		{"3.0.0.0/16", false},
+		{"3.0.0.0/15", false}, // Partially allocated
+		{"3.1.0.0/16", true},
+		{"255.255.255.0/24", true},
+	}
+	for _, tt := range prefixes {
+		if got := b.IsUnallocatedPrefix(netip.MustParsePrefix(tt.prefix)); got != tt.unallocated {
+			t.Errorf("IsUnallocatedPrefix(%s) = %v, want %v", tt.prefix, got, tt.unallocated)
+		}
+	}
+
+	asns := []struct {
+		asn         uint32
+		unallocated bool
+	}{
+		{500, false},
+		{1500, true},
+		{2500, false},
+		{4000000000, true},
+	}
+	for _, tt := range asns {
+		if got := b.IsUnallocatedASN(tt.asn); got != tt.unallocated {
+			t.Errorf("IsUnallocatedASN(%d) = %v, want %v", tt.asn, got, tt.unallocated)
+		}
+	}
+}
+
+func TestFullBogonsWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full-bogons.json")
+	var applied []*FullBogons
+	w := NewFullBogonsWatcher(path, func(b *FullBogons) { applied = append(applied, b) })
+
+	if err := w.Reload(); !os.IsNotExist(err) {
+		t.Fatalf("Reload() error = %v, want a missing file", err)
+	}
+	save := func(b *FullBogons, modTime time.Time) {
+		if err := b.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	save(NewFullBogons(nil, nil), start)
+	if err := w.Reload(); err != nil || len(applied) != 1 {
+		t.Fatalf("Reload() error = %v, applied %d lists, want 1", err, len(applied))
+	}
+	if err := w.Reload(); err != nil || len(applied) != 1 {
+		t.Errorf("expected an unchanged file not to be applied again, applied %d lists (err %v)", len(applied), err)
+	}
+
+	// A refresh allocates 1.0.0.0/8
+	save(NewFullBogons([]Range{{Start: 0x01000000, End: 0x01FFFFFF}}, nil), start.Add(time.Minute))
+	if err := w.Reload(); err != nil || len(applied) != 2 {
+		t.Fatalf("Reload() error = %v, applied %d lists, want 2", err, len(applied))
+	}
+	if applied[1].IsUnallocatedPrefix(netip.MustParsePrefix("1.1.1.0/24")) {
+		t.Error("expected the refreshed list to be applied")
+	}
+
+	// A broken file keeps the previous list
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, start.Add(2*time.Minute), start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err == nil || len(applied) != 2 {
+		t.Errorf("Reload() error = %v, applied %d lists; want an error and 2", err, len(applied))
+	}
+	if err := w.Reload(); err != nil {
+		t.Errorf("expected a broken file to be reported once, got %v", err)
+	}
+}
@@ -4,15 +4,103 @@ package utils
 import (
 	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"log"
 	"net"
+	"os"
+	"runtime"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
 )
 
 type DiskTrie struct {
-	db    *badger.DB
-	cache sync.Map
+	db       *badger.DB
+	path     string
+	cache    sync.Map
+	cacheLen atomic.Int64
+
+	// index serves lookups from memory once EnableIndex has been called
+	index     atomic.Pointer[LPMIndex]
+	indexMu   sync.Mutex
+	indexPath string
+	// staleWrites counts single-entry writes not yet in the index. Lookups
+	// go to the database while it is non-zero.
+	staleWrites  atomic.Uint64
+	rebuildTimer *time.Timer
+
+	// writeMu is held for reading by every write and for writing by each
+	// Rewrite chunk, so an entry cannot change between the read and the write
+	// of a chunk. Write-behind flushes run while the database is compacted.
+	writeMu sync.RWMutex
+
+	lookups lookupStats
+}
+
+// LookupLatencyBuckets are the upper bounds, in seconds, of the lookup latency
+// histogram in DiskTrieStats.
+var LookupLatencyBuckets = [...]float64{1e-6, 1e-5, 1e-4, 1e-3, 1e-2, 1e-1}
+
+// DiskTrieStats describes the lookups served by a DiskTrie, whether from the
+// index, the lookup cache or the database.
+type DiskTrieStats struct {
+	Lookups uint64  `json:"lookups"`
+	Seconds float64 `json:"seconds"`
+	// Buckets holds the cumulative number of lookups that took at most the
+	// matching LookupLatencyBuckets bound
+	Buckets []uint64 `json:"buckets"`
+}
+
+type lookupStats struct {
+	count   atomic.Uint64
+	nanos   atomic.Uint64
+	buckets [len(LookupLatencyBuckets)]atomic.Uint64
+}
+
+func (s *lookupStats) observe(start time.Time) {
+	d := time.Since(start)
+	s.count.Add(1)
+	s.nanos.Add(uint64(d))
+	for i, bound := range LookupLatencyBuckets {
+		if d.Seconds() <= bound {
+			s.buckets[i].Add(1)
+			return
+		}
+	}
+}
+
+// Stats returns the lookups served since the trie was opened.
+func (t *DiskTrie) Stats() DiskTrieStats {
+	if t == nil {
+		return DiskTrieStats{Buckets: make([]uint64, len(LookupLatencyBuckets))}
+	}
+	st := DiskTrieStats{
+		Lookups: t.lookups.count.Load(),
+		Seconds: time.Duration(t.lookups.nanos.Load()).Seconds(),
+		Buckets: make([]uint64, len(LookupLatencyBuckets)),
+	}
+	var cum uint64
+	for i := range st.Buckets {
+		cum += t.lookups.buckets[i].Load()
+		st.Buckets[i] = cum
+	}
+	return st
+}
+
+// lookupCacheSize bounds the number of addresses cached by lookups that are
+// not served from an index. The cache is reset when it fills up.
+const lookupCacheSize = 1 << 18
+
+func (t *DiskTrie) cacheLookup(ip uint32, res any) {
+	if _, loaded := t.cache.LoadOrStore(ip, res); loaded {
+		return
+	}
+	if t.cacheLen.Add(1) > lookupCacheSize {
+		t.cache.Clear()
+		t.cacheLen.Store(0)
+	}
 }
 
 func getBadgerOptions(path string) badger.Options {
@@ -32,7 +120,7 @@ func OpenDiskTrie(path string) (*DiskTrie, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DiskTrie{db: db}, nil
+	return &DiskTrie{db: db, path: path}, nil
 }
 
 func OpenDiskTrieReadOnly(path string) (*DiskTrie, error) {
@@ -42,13 +130,187 @@ func OpenDiskTrieReadOnly(path string) (*DiskTrie, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DiskTrie{db: db}, nil
+	return &DiskTrie{db: db, path: path}, nil
+}
+
+// Path returns the directory the database was opened from.
+func (t *DiskTrie) Path() string {
+	return t.path
 }
 
 func (t *DiskTrie) Close() error {
+	t.indexMu.Lock()
+	if t.rebuildTimer != nil {
+		t.rebuildTimer.Stop()
+		t.rebuildTimer = nil
+	}
+	if idx := t.index.Swap(nil); idx != nil {
+		idx.retire()
+	}
+	t.indexMu.Unlock()
 	return t.db.Close()
 }
 
+// EnableIndex builds an in-memory LPM index of the prefixes in the database and
+// serves Lookup, LookupUint32 and LookupAll from it. Batch writes rebuild the
+// index right away and single-entry writes within indexRebuildDelay share one
+// rebuild, so it is meant for datasets that are replaced in bulk and read far
+// more often. When snapshotPath is set, the index is loaded from that file if
+// it matches the database, and written there otherwise.
+func (t *DiskTrie) EnableIndex(snapshotPath string) error {
+	if t == nil || t.db == nil {
+		return nil
+	}
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	t.indexPath = snapshotPath
+
+	version := t.contentVersion()
+	if snapshotPath != "" {
+		if idx, err := OpenLPMIndex(snapshotPath); err == nil {
+			if idx.Version() == version {
+				t.swapIndex(idx)
+				return nil
+			}
+			_ = idx.Close()
+		} else if !os.IsNotExist(err) {
+			log.Printf("Warning: ignoring LPM index snapshot: %v", err)
+		}
+	}
+	return t.rebuildIndex(version)
+}
+
+// indexRebuildDelay is how long single-entry writes are collected before the
+// index is rebuilt.
+const indexRebuildDelay = time.Second
+
+// refreshIndex rebuilds the index after a write and swaps it in atomically.
+// Lookups in progress finish on the old index. It does nothing if EnableIndex
+// has not been called.
+func (t *DiskTrie) refreshIndex() error {
+	if t.index.Load() == nil {
+		return nil
+	}
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	if t.index.Load() == nil {
+		return nil
+	}
+	if t.rebuildTimer != nil {
+		t.rebuildTimer.Stop()
+		t.rebuildTimer = nil
+	}
+	return t.rebuildIndex(t.contentVersion())
+}
+
+// scheduleIndexRefresh rebuilds the index indexRebuildDelay after the first of
+// a run of single-entry writes. Until then lookups skip the index and the
+// lookup cache so that the writes are visible.
+func (t *DiskTrie) scheduleIndexRefresh() {
+	if t.index.Load() == nil {
+		return
+	}
+	t.staleWrites.Add(1)
+	t.indexMu.Lock()
+	defer t.indexMu.Unlock()
+	if t.rebuildTimer == nil && t.index.Load() != nil {
+		t.rebuildTimer = time.AfterFunc(indexRebuildDelay, func() {
+			if err := t.refreshIndex(); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		})
+	}
+}
+
+// rebuildIndex must be called with indexMu held.
+func (t *DiskTrie) rebuildIndex(version uint64) error {
+	stale := t.staleWrites.Load()
+	var entries []LPMEntry
+	err := t.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			k := item.Key()
+			if len(k) != 5 || k[4] > 32 {
+				continue
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, LPMEntry{Addr: binary.BigEndian.Uint32(k), Bits: int(k[4]), Value: v})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build LPM index: %v", err)
+	}
+	idx := BuildLPMIndex(entries, version)
+	if t.indexPath != "" {
+		if err := idx.WriteFile(t.indexPath); err != nil {
+			log.Printf("Warning: failed to write LPM index snapshot: %v", err)
+		}
+	}
+	t.swapIndex(idx)
+	// Writes made while the index was being built may be missing from it
+	t.staleWrites.CompareAndSwap(stale, 0)
+	return nil
+}
+
+// swapIndex must be called with indexMu held. A replaced mapped snapshot may
+// still be in use by a concurrent lookup, so it is unmapped once the last
+// lookup holding it is done.
+func (t *DiskTrie) swapIndex(idx *LPMIndex) {
+	if old := t.index.Swap(idx); old != nil {
+		old.retire()
+	}
+}
+
+// acquireIndex returns the index with a reference held, or nil when lookups
+// should go to the database. The caller must release the index.
+func (t *DiskTrie) acquireIndex() *LPMIndex {
+	if t.staleWrites.Load() != 0 {
+		return nil
+	}
+	for {
+		idx := t.index.Load()
+		if idx == nil {
+			return nil
+		}
+		idx.refs.Add(1)
+		if t.index.Load() == idx {
+			return idx
+		}
+		// Swapped out in the meantime, and possibly already unmapped
+		idx.release()
+	}
+}
+
+// indexValue returns a value from idx that stays valid after idx is released.
+func indexValue(idx *LPMIndex, val []byte) []byte {
+	if !idx.mapped || val == nil {
+		return val
+	}
+	return append([]byte(nil), val...)
+}
+
+// contentVersion identifies the current contents of the database, so that a
+// snapshot built from different contents is not reused.
+func (t *DiskTrie) contentVersion() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], t.db.MaxVersion())
+	_, _ = h.Write(buf[:])
+	for _, ti := range t.db.Tables() {
+		binary.LittleEndian.PutUint64(buf[:], ti.ID)
+		_, _ = h.Write(buf[:])
+		binary.LittleEndian.PutUint64(buf[:], uint64(ti.KeyCount))
+		_, _ = h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
 func (t *DiskTrie) Insert(ipNet *net.IPNet, value []byte) error {
 	ip := ipNet.IP.To4()
 	if ip == nil {
@@ -61,15 +323,31 @@ func (t *DiskTrie) Insert(ipNet *net.IPNet, value []byte) error {
 	copy(key, ip)
 	key[4] = byte(ones)
 
-	return t.db.Update(func(txn *badger.Txn) error {
+	t.writeMu.RLock()
+	err := t.db.Update(func(txn *badger.Txn) error {
 		return txn.Set(key, value)
 	})
+	t.writeMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	t.scheduleIndexRefresh()
+	return nil
 }
 
 func (t *DiskTrie) BatchInsert(entries map[string][]byte) error {
 	if t == nil || t.db == nil {
 		return nil
 	}
+	if err := t.batchInsert(entries); err != nil {
+		return err
+	}
+	return t.refreshIndex()
+}
+
+func (t *DiskTrie) batchInsert(entries map[string][]byte) error {
+	t.writeMu.RLock()
+	defer t.writeMu.RUnlock()
 	wb := t.db.NewWriteBatch()
 	defer wb.Cancel()
 
@@ -97,6 +375,8 @@ func (t *DiskTrie) BatchInsertUint32(entries map[uint32][]byte) error {
 	if t == nil || t.db == nil {
 		return nil
 	}
+	t.writeMu.RLock()
+	defer t.writeMu.RUnlock()
 	wb := t.db.NewWriteBatch()
 	defer wb.Cancel()
 
@@ -108,13 +388,18 @@ func (t *DiskTrie) BatchInsertUint32(entries map[uint32][]byte) error {
 			return err
 		}
 	}
-	return wb.Flush()
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	return t.refreshIndex()
 }
 
 func (t *DiskTrie) BatchInsertIPNets(entries []IPNetEntry) error {
 	if t == nil || t.db == nil {
 		return nil
 	}
+	t.writeMu.RLock()
+	defer t.writeMu.RUnlock()
 	wb := t.db.NewWriteBatch()
 	defer wb.Cancel()
 
@@ -131,7 +416,10 @@ func (t *DiskTrie) BatchInsertIPNets(entries []IPNetEntry) error {
 			return err
 		}
 	}
-	return wb.Flush()
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	return t.refreshIndex()
 }
 
 type IPNetEntry struct {
@@ -143,6 +431,8 @@ func (t *DiskTrie) BatchInsertRaw(entries map[string][]byte) error {
 	if t == nil || t.db == nil {
 		return nil
 	}
+	t.writeMu.RLock()
+	defer t.writeMu.RUnlock()
 	wb := t.db.NewWriteBatch()
 	defer wb.Cancel()
 
@@ -166,13 +456,17 @@ func (t *DiskTrie) BatchInsertRaw(entries map[string][]byte) error {
 			return err
 		}
 	}
-	return wb.Flush()
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	return t.refreshIndex()
 }
 
 func (t *DiskTrie) Get(prefix string) ([]byte, error) {
 	if t == nil || t.db == nil {
 		return nil, nil
 	}
+	defer t.lookups.observe(time.Now())
 	_, ipNet, err := net.ParseCIDR(prefix)
 	if err != nil {
 		// Fallback to raw string key if it's not a CIDR
@@ -222,6 +516,8 @@ func (t *DiskTrie) Put(prefix string, val []byte) error {
 	_, ipNet, err := net.ParseCIDR(prefix)
 	if err != nil {
 		// Fallback to raw string key if it's not a CIDR
+		t.writeMu.RLock()
+		defer t.writeMu.RUnlock()
 		return t.db.Update(func(txn *badger.Txn) error {
 			return txn.Set([]byte(prefix), val)
 		})
@@ -236,13 +532,20 @@ type lookupResult struct {
 
 // Lookup returns the value and mask length associated with the longest prefix matching the IP.
 func (t *DiskTrie) Lookup(ip net.IP) (val []byte, maskLen int, err error) {
+	defer t.lookups.observe(time.Now())
 	target := ip.To4()
 	if target == nil {
 		return nil, 0, fmt.Errorf("invalid IPv4")
 	}
 
 	targetInt := binary.BigEndian.Uint32(target)
-	if v, ok := t.cache.Load(targetInt); ok {
+	if idx := t.acquireIndex(); idx != nil {
+		defer idx.release()
+		val, maskLen, _ = idx.Lookup(targetInt)
+		return indexValue(idx, val), maskLen, nil
+	}
+	cached := t.staleWrites.Load() == 0
+	if v, ok := t.cache.Load(targetInt); ok && cached {
 		if v == nil {
 			return nil, 0, nil
 		}
@@ -277,11 +580,11 @@ func (t *DiskTrie) Lookup(ip net.IP) (val []byte, maskLen int, err error) {
 		return nil
 	})
 
-	if err == nil {
+	if err == nil && cached {
 		if foundVal == nil {
-			t.cache.Store(targetInt, nil)
+			t.cacheLookup(targetInt, nil)
 		} else {
-			t.cache.Store(targetInt, lookupResult{val: foundVal, maskLen: foundMask})
+			t.cacheLookup(targetInt, lookupResult{val: foundVal, maskLen: foundMask})
 		}
 	}
 	return foundVal, foundMask, err
@@ -289,12 +592,21 @@ func (t *DiskTrie) Lookup(ip net.IP) (val []byte, maskLen int, err error) {
 
 // LookupAll returns all values associated with prefixes that cover the IP.
 func (t *DiskTrie) LookupAll(ip net.IP) (vals [][]byte, err error) {
+	defer t.lookups.observe(time.Now())
 	target := ip.To4()
 	if target == nil {
 		return nil, fmt.Errorf("invalid IPv4")
 	}
 
 	targetInt := binary.BigEndian.Uint32(target)
+	if idx := t.acquireIndex(); idx != nil {
+		defer idx.release()
+		vals = idx.LookupAll(targetInt)
+		for i, v := range vals {
+			vals[i] = indexValue(idx, v)
+		}
+		return vals, nil
+	}
 	err = t.db.View(func(txn *badger.Txn) error {
 		key := make([]byte, 5)
 		for m := 32; m >= 0; m-- {
@@ -324,7 +636,14 @@ func (t *DiskTrie) LookupAll(ip net.IP) (vals [][]byte, err error) {
 }
 
 func (t *DiskTrie) LookupUint32(ip uint32) (val []byte, maskLen int, err error) {
-	if v, ok := t.cache.Load(ip); ok {
+	defer t.lookups.observe(time.Now())
+	if idx := t.acquireIndex(); idx != nil {
+		defer idx.release()
+		val, maskLen, _ = idx.Lookup(ip)
+		return indexValue(idx, val), maskLen, nil
+	}
+	cached := t.staleWrites.Load() == 0
+	if v, ok := t.cache.Load(ip); ok && cached {
 		if v == nil {
 			return nil, 0, nil
 		}
@@ -358,20 +677,27 @@ func (t *DiskTrie) LookupUint32(ip uint32) (val []byte, maskLen int, err error)
 		return nil
 	})
 
-	if err == nil {
+	if err == nil && cached {
 		if foundVal == nil {
-			t.cache.Store(ip, nil)
+			t.cacheLookup(ip, nil)
 		} else {
-			t.cache.Store(ip, lookupResult{val: foundVal, maskLen: foundMask})
+			t.cacheLookup(ip, lookupResult{val: foundVal, maskLen: foundMask})
 		}
 	}
 	return foundVal, foundMask, err
 }
 
 func (t *DiskTrie) DeleteRaw(key []byte) error {
-	return t.db.Update(func(txn *badger.Txn) error {
+	t.writeMu.RLock()
+	err := t.db.Update(func(txn *badger.Txn) error {
 		return txn.Delete(key)
 	})
+	t.writeMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	t.scheduleIndexRefresh()
+	return nil
 }
 
 func (t *DiskTrie) ForEach(fn func(k []byte, v []byte) error) error {
@@ -421,5 +747,149 @@ func (t *DiskTrie) Clear() error {
 	if t == nil || t.db == nil {
 		return nil
 	}
-	return t.db.DropAll()
+	t.cache.Clear()
+	t.cacheLen.Store(0)
+	t.writeMu.RLock()
+	err := t.db.DropAll()
+	t.writeMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return t.refreshIndex()
+}
+
+// ReplaceAll replaces the contents of the database with entries, as Clear
+// followed by BatchInsert would. An index is only rebuilt once the new entries
+// are in place, so indexed lookups never see the database empty.
+func (t *DiskTrie) ReplaceAll(entries map[string][]byte) error {
+	if t == nil || t.db == nil {
+		return nil
+	}
+	t.cache.Clear()
+	t.cacheLen.Store(0)
+	t.writeMu.RLock()
+	err := t.db.DropAll()
+	t.writeMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := t.batchInsert(entries); err != nil {
+		return err
+	}
+	return t.refreshIndex()
+}
+
+// rewriteChunkSize bounds the number of entries changed in a single transaction.
+const rewriteChunkSize = 1000
+
+// Rewrite calls fn for every entry and replaces the value with the one
+// returned, or deletes the entry when del is set. Entries are left untouched
+// when fn returns a nil value without del. Entries are processed in chunks,
+// and no other write to the trie lands while a chunk is evaluated, so fn must
+// not write to the trie itself.
+func (t *DiskTrie) Rewrite(fn func(k, v []byte) (newVal []byte, del bool)) (updated, deleted int, err error) {
+	if t == nil || t.db == nil {
+		return 0, 0, nil
+	}
+
+	var start []byte
+	for {
+		var next []byte
+		var chunkUpdated, chunkDeleted int
+		t.writeMu.Lock()
+		txnErr := t.db.Update(func(txn *badger.Txn) error {
+			next = nil
+			chunkUpdated, chunkDeleted = 0, 0
+			it := txn.NewIterator(badger.DefaultIteratorOptions)
+			defer it.Close()
+
+			n := 0
+			for it.Seek(start); it.Valid(); it.Next() {
+				item := it.Item()
+				if n == rewriteChunkSize {
+					next = item.KeyCopy(nil)
+					break
+				}
+				n++
+				k := item.KeyCopy(nil)
+				v, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				newVal, del := fn(k, v)
+				switch {
+				case del:
+					if err = txn.Delete(k); err == nil {
+						chunkDeleted++
+					}
+				case newVal != nil:
+					if err = txn.Set(k, newVal); err == nil {
+						chunkUpdated++
+					}
+				}
+				if err == badger.ErrTxnTooBig && chunkUpdated+chunkDeleted > 0 {
+					// Commit what fits and pick this entry up in the next chunk
+					next = k
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		t.writeMu.Unlock()
+		if txnErr == badger.ErrConflict {
+			continue
+		}
+		if txnErr != nil {
+			return updated, deleted, txnErr
+		}
+		updated += chunkUpdated
+		deleted += chunkDeleted
+		if next == nil {
+			break
+		}
+		start = next
+	}
+
+	t.cache.Clear()
+	t.cacheLen.Store(0)
+	return updated, deleted, t.refreshIndex()
+}
+
+// Size returns the on-disk size of the LSM tree and the value log in bytes.
+func (t *DiskTrie) Size() (lsm, vlog int64) {
+	if t == nil || t.db == nil {
+		return 0, 0
+	}
+	return t.db.Size()
+}
+
+// RunValueLogGC rewrites value log files until no file has at least
+// discardRatio of stale data, and returns the number of files rewritten.
+func (t *DiskTrie) RunValueLogGC(discardRatio float64) (int, error) {
+	if t == nil || t.db == nil {
+		return 0, nil
+	}
+	rewritten := 0
+	for {
+		err := t.db.RunValueLogGC(discardRatio)
+		if err == badger.ErrNoRewrite {
+			return rewritten, nil
+		}
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+}
+
+// Flatten compacts the LSM tree into a single level, dropping deleted and
+// overwritten keys. It should only be used while there are no other writers.
+func (t *DiskTrie) Flatten() error {
+	if t == nil || t.db == nil {
+		return nil
+	}
+	return t.db.Flatten(runtime.NumCPU())
 }
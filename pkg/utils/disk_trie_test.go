@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestDiskTrie(t *testing.T) {
@@ -250,3 +251,79 @@ func BenchmarkDiskTrieLookup(b *testing.B) {
 		_, _, _ = trie.Lookup(ip)
 	}
 }
+
+func TestDiskTrieStats(t *testing.T) {
+	trie, err := OpenDiskTrie(filepath.Join(t.TempDir(), "stats.db"))
+	if err != nil {
+		t.Fatalf("Failed to open DiskTrie: %v", err)
+	}
+	defer func() { _ = trie.Close() }()
+
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	if err := trie.Insert(ipNet, []byte("a")); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	_, _, _ = trie.Lookup(net.ParseIP("10.1.2.3"))
+	_, _, _ = trie.LookupUint32(0x0a000001)
+	_, _ = trie.Get("10.0.0.0/8")
+
+	st := trie.Stats()
+	if st.Lookups != 3 || st.Seconds <= 0 {
+		t.Errorf("Expected 3 timed lookups, got %+v", st)
+	}
+	if n := st.Buckets[len(st.Buckets)-1]; n > 3 {
+		t.Errorf("Expected cumulative buckets to stay within the lookup count, got %v", st.Buckets)
+	}
+	for i := 1; i < len(st.Buckets); i++ {
+		if st.Buckets[i] < st.Buckets[i-1] {
+			t.Errorf("Expected cumulative buckets, got %v", st.Buckets)
+		}
+	}
+
+	var nilTrie *DiskTrie
+	if st := nilTrie.Stats(); st.Lookups != 0 || len(st.Buckets) != len(LookupLatencyBuckets) {
+		t.Errorf("Expected empty stats for a nil trie, got %+v", st)
+	}
+}
+
+func TestDiskTrieRewriteKeepsConcurrentWrites(t *testing.T) {
+	trie, err := OpenDiskTrie(filepath.Join(t.TempDir(), "rewrite.db"))
+	if err != nil {
+		t.Fatalf("Failed to open DiskTrie: %v", err)
+	}
+	defer func() { _ = trie.Close() }()
+
+	if err := trie.BatchInsertRaw(map[string][]byte{"10.0.0.0/24": []byte("v1")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A write-behind flush of a newer value lands while the entry is being
+	// rewritten
+	flushed := make(chan error, 1)
+	_, _, err = trie.Rewrite(func(k, v []byte) ([]byte, bool) {
+		if bytes.Equal(v, []byte("v1")) {
+			go func() { flushed <- trie.BatchInsertRaw(map[string][]byte{"10.0.0.0/24": []byte("v2")}) }()
+			select {
+			case err := <-flushed:
+				flushed <- err
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+		return append(bytes.Clone(v), " compacted"...), false
+	})
+	if err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	if err := <-flushed; err != nil {
+		t.Fatalf("BatchInsertRaw failed: %v", err)
+	}
+
+	got, err := trie.Get("10.0.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The flush waits for the chunk and replaces the rewritten value
+	if string(got) != "v2" {
+		t.Errorf("expected the concurrent write to survive the rewrite, got %q", got)
+	}
+}
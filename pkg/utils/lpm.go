@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+)
+
+// LPMIndex is an immutable IPv4 longest-prefix-match index. The address space
+// is flattened into non-overlapping ranges that each point at the most
+// specific prefix covering them, so a lookup is a single binary search. Every
+// prefix also points at the next less specific prefix covering it, which lets
+// LookupAll walk the covering prefixes without searching again.
+//
+// The index lives in one byte slice with the same layout as its snapshot file,
+// so a snapshot can be memory-mapped and used without decoding:
+//
+//	header    lpmHeaderSize bytes
+//	starts    ranges x uint32, the first address of each range
+//	targets   ranges x uint32, the prefix of each range or lpmNone
+//	prefixes  prefixes x lpmPrefixSize bytes (addr, bits, parent, value offset, value length)
+//	values    deduplicated value bytes
+//
+// All integers are little-endian.
+type LPMIndex struct {
+	data     []byte
+	ranges   int
+	prefixes int
+
+	startsOff, targetsOff, prefixesOff, valuesOff int
+
+	version uint64
+	unmap   func() error
+	mapped  bool
+
+	// refs counts the lookups holding the index. A retired mapped index is
+	// unmapped once the last of them is released.
+	refs    atomic.Int64
+	retired atomic.Bool
+	closed  atomic.Bool
+}
+
+const (
+	lpmHeaderSize = 32
+	lpmPrefixSize = 20
+	lpmNone       = ^uint32(0)
+)
+
+var lpmMagic = []byte("BGPLPM01")
+
+// LPMEntry is one prefix and its value used to build an index.
+type LPMEntry struct {
+	Addr  uint32
+	Bits  int
+	Value []byte
+}
+
+type lpmBuildPrefix struct {
+	addr, end uint64
+	bits      int
+	value     []byte
+}
+
+// BuildLPMIndex builds an index from entries. Host bits are ignored, and when
+// the same prefix appears twice the last value wins. version is stored in the
+// snapshot header so callers can tell whether a snapshot is stale.
+func BuildLPMIndex(entries []LPMEntry, version uint64) *LPMIndex {
+	prefixes := make([]lpmBuildPrefix, 0, len(entries))
+	for _, e := range entries {
+		if e.Bits < 0 || e.Bits > 32 {
+			continue
+		}
+		var mask uint32
+		if e.Bits > 0 {
+			mask = ^uint32(0) << (32 - e.Bits)
+		}
+		addr := uint64(e.Addr & mask)
+		prefixes = append(prefixes, lpmBuildPrefix{addr: addr, end: addr + (uint64(1) << (32 - e.Bits)) - 1, bits: e.Bits, value: e.Value})
+	}
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		if prefixes[i].addr != prefixes[j].addr {
+			return prefixes[i].addr < prefixes[j].addr
+		}
+		return prefixes[i].bits < prefixes[j].bits
+	})
+	// Drop duplicates, keeping the last one
+	n := 0
+	for i := range prefixes {
+		if n > 0 && prefixes[n-1].addr == prefixes[i].addr && prefixes[n-1].bits == prefixes[i].bits {
+			prefixes[n-1] = prefixes[i]
+			continue
+		}
+		prefixes[n] = prefixes[i]
+		n++
+	}
+	prefixes = prefixes[:n]
+
+	var starts, targets []uint32
+	emit := func(start uint64, target uint32) {
+		if len(targets) > 0 && targets[len(targets)-1] == target {
+			return
+		}
+		starts = append(starts, uint32(start))
+		targets = append(targets, target)
+	}
+
+	// Sweep the address space with a stack of the prefixes covering the
+	// current position, most specific on top
+	parents := make([]uint32, len(prefixes))
+	var stack []uint32
+	top := func() uint32 {
+		if len(stack) == 0 {
+			return lpmNone
+		}
+		return stack[len(stack)-1]
+	}
+	var pos uint64
+	closeUntil := func(limit uint64) {
+		for len(stack) > 0 && prefixes[top()].end < limit {
+			end := prefixes[top()].end
+			if pos <= end {
+				emit(pos, top())
+				pos = end + 1
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	for i := range prefixes {
+		p := &prefixes[i]
+		closeUntil(p.addr)
+		if p.addr > pos {
+			emit(pos, top())
+			pos = p.addr
+		}
+		parents[i] = top()
+		stack = append(stack, uint32(i))
+	}
+	closeUntil(1 << 32)
+	if pos <= 0xFFFFFFFF {
+		emit(pos, lpmNone)
+	}
+
+	// Identical values are stored once
+	var values bytes.Buffer
+	valueOffsets := make(map[string]uint32)
+	valueOff := make([]uint32, len(prefixes))
+	for i := range prefixes {
+		off, ok := valueOffsets[string(prefixes[i].value)]
+		if !ok {
+			off = uint32(values.Len())
+			valueOffsets[string(prefixes[i].value)] = off
+			values.Write(prefixes[i].value)
+		}
+		valueOff[i] = off
+	}
+
+	idx := &LPMIndex{ranges: len(starts), prefixes: len(prefixes), version: version}
+	idx.layout()
+	data := make([]byte, idx.valuesOff+values.Len())
+	copy(data, lpmMagic)
+	binary.LittleEndian.PutUint32(data[8:], uint32(idx.ranges))
+	binary.LittleEndian.PutUint32(data[12:], uint32(idx.prefixes))
+	binary.LittleEndian.PutUint32(data[16:], uint32(values.Len()))
+	binary.LittleEndian.PutUint64(data[24:], version)
+	for i := range starts {
+		binary.LittleEndian.PutUint32(data[idx.startsOff+4*i:], starts[i])
+		binary.LittleEndian.PutUint32(data[idx.targetsOff+4*i:], targets[i])
+	}
+	for i := range prefixes {
+		p := data[idx.prefixesOff+lpmPrefixSize*i:]
+		binary.LittleEndian.PutUint32(p, uint32(prefixes[i].addr))
+		binary.LittleEndian.PutUint32(p[4:], uint32(prefixes[i].bits))
+		binary.LittleEndian.PutUint32(p[8:], parents[i])
+		binary.LittleEndian.PutUint32(p[12:], valueOff[i])
+		binary.LittleEndian.PutUint32(p[16:], uint32(len(prefixes[i].value)))
+	}
+	copy(data[idx.valuesOff:], values.Bytes())
+	idx.data = data
+	return idx
+}
+
+func (idx *LPMIndex) layout() {
+	idx.startsOff = lpmHeaderSize
+	idx.targetsOff = idx.startsOff + 4*idx.ranges
+	idx.prefixesOff = idx.targetsOff + 4*idx.ranges
+	idx.valuesOff = idx.prefixesOff + lpmPrefixSize*idx.prefixes
+}
+
+// parseLPMIndex validates a snapshot and returns an index that reads from it.
+func parseLPMIndex(data []byte) (*LPMIndex, error) {
+	if len(data) < lpmHeaderSize || !bytes.Equal(data[:8], lpmMagic) {
+		return nil, fmt.Errorf("not an LPM index snapshot")
+	}
+	idx := &LPMIndex{
+		ranges:   int(binary.LittleEndian.Uint32(data[8:])),
+		prefixes: int(binary.LittleEndian.Uint32(data[12:])),
+		version:  binary.LittleEndian.Uint64(data[24:]),
+	}
+	idx.layout()
+	valuesLen := int(binary.LittleEndian.Uint32(data[16:]))
+	if idx.ranges == 0 || len(data) != idx.valuesOff+valuesLen {
+		return nil, fmt.Errorf("truncated LPM index snapshot")
+	}
+	valid := func(i uint32) bool { return i == lpmNone || int(i) < idx.prefixes }
+	for i := 0; i < idx.ranges; i++ {
+		if !valid(binary.LittleEndian.Uint32(data[idx.targetsOff+4*i:])) {
+			return nil, fmt.Errorf("corrupt LPM index snapshot")
+		}
+	}
+	for i := 0; i < idx.prefixes; i++ {
+		p := data[idx.prefixesOff+lpmPrefixSize*i:]
+		end := uint64(binary.LittleEndian.Uint32(p[12:])) + uint64(binary.LittleEndian.Uint32(p[16:]))
+		// Parents always sort before their children, which also rules out cycles
+		parent := binary.LittleEndian.Uint32(p[8:])
+		if (parent != lpmNone && int(parent) >= i) || binary.LittleEndian.Uint32(p[4:]) > 32 || end > uint64(valuesLen) {
+			return nil, fmt.Errorf("corrupt LPM index snapshot")
+		}
+	}
+	idx.data = data
+	return idx, nil
+}
+
+// Version returns the version the index was built with.
+func (idx *LPMIndex) Version() uint64 {
+	return idx.version
+}
+
+// Len returns the number of prefixes in the index.
+func (idx *LPMIndex) Len() int {
+	return idx.prefixes
+}
+
+func (idx *LPMIndex) prefix(i uint32) (bits int, parent uint32, val []byte) {
+	p := idx.data[idx.prefixesOff+lpmPrefixSize*int(i):]
+	bits = int(binary.LittleEndian.Uint32(p[4:]))
+	parent = binary.LittleEndian.Uint32(p[8:])
+	off := idx.valuesOff + int(binary.LittleEndian.Uint32(p[12:]))
+	n := int(binary.LittleEndian.Uint32(p[16:]))
+	return bits, parent, idx.data[off : off+n : off+n]
+}
+
+func (idx *LPMIndex) find(ip uint32) uint32 {
+	// Largest range start <= ip; the first range always starts at 0
+	lo, hi := 0, idx.ranges
+	for hi-lo > 1 {
+		mid := int(uint(lo+hi) >> 1)
+		if binary.LittleEndian.Uint32(idx.data[idx.startsOff+4*mid:]) <= ip {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return binary.LittleEndian.Uint32(idx.data[idx.targetsOff+4*lo:])
+}
+
+// Lookup returns the value and mask length of the most specific prefix
+// containing ip. The value aliases the index and must not be modified.
+func (idx *LPMIndex) Lookup(ip uint32) (val []byte, maskLen int, ok bool) {
+	i := idx.find(ip)
+	if i == lpmNone {
+		return nil, 0, false
+	}
+	bits, _, val := idx.prefix(i)
+	return val, bits, true
+}
+
+// LookupAll returns the values of every prefix containing ip, most specific
+// first. The values alias the index and must not be modified.
+func (idx *LPMIndex) LookupAll(ip uint32) [][]byte {
+	var vals [][]byte
+	for i := idx.find(ip); i != lpmNone; {
+		_, parent, val := idx.prefix(i)
+		vals = append(vals, val)
+		i = parent
+	}
+	return vals
+}
+
+// WriteFile writes a snapshot of the index to path. The file is replaced
+// atomically, so a snapshot that is currently mapped stays valid.
+func (idx *LPMIndex) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(idx.data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// OpenLPMIndex maps the snapshot at path into memory. The index must be
+// closed once no lookups are using it.
+func OpenLPMIndex(path string) (*LPMIndex, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := parseLPMIndex(data)
+	if err != nil {
+		if unmap != nil {
+			_ = unmap()
+		}
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	idx.unmap = unmap
+	idx.mapped = unmap != nil
+	return idx, nil
+}
+
+// Close releases a mapped snapshot. Values returned by lookups are invalid
+// afterwards.
+func (idx *LPMIndex) Close() error {
+	if idx.unmap == nil {
+		return nil
+	}
+	err := idx.unmap()
+	idx.unmap = nil
+	idx.data = nil
+	return err
+}
+
+// retire closes the index once no lookups hold it anymore.
+func (idx *LPMIndex) retire() {
+	idx.retired.Store(true)
+	if idx.refs.Load() == 0 {
+		idx.closeRetired()
+	}
+}
+
+// release drops a reference taken by a lookup.
+func (idx *LPMIndex) release() {
+	if idx.refs.Add(-1) == 0 && idx.retired.Load() {
+		idx.closeRetired()
+	}
+}
+
+func (idx *LPMIndex) closeRetired() {
+	if idx.closed.CompareAndSwap(false, true) {
+		_ = idx.Close()
+	}
+}
@@ -0,0 +1,293 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func randomLPMEntries(r *rand.Rand, n int) []LPMEntry {
+	entries := make([]LPMEntry, 0, n+1)
+	// A default route exercises the range that covers everything
+	entries = append(entries, LPMEntry{Addr: 0, Bits: 0, Value: []byte("default")})
+	for i := 0; i < n; i++ {
+		bits := 8 + r.Intn(25)
+		// Keep addresses in a small space so prefixes nest
+		addr := uint32(10)<<24 | uint32(r.Intn(1<<16))<<8 | uint32(r.Intn(256))
+		entries = append(entries, LPMEntry{Addr: addr, Bits: bits, Value: []byte(fmt.Sprintf("v%d", i%50))})
+	}
+	return entries
+}
+
+type lpmProbe map[[2]uint32][]byte
+
+func newLPMProbe(entries []LPMEntry) lpmProbe {
+	probe := make(lpmProbe)
+	for _, e := range entries {
+		mask := uint32(0)
+		if e.Bits > 0 {
+			mask = ^uint32(0) << (32 - e.Bits)
+		}
+		probe[[2]uint32{e.Addr & mask, uint32(e.Bits)}] = e.Value
+	}
+	return probe
+}
+
+// lookup finds the covering prefixes of ip one mask length at a time, the way
+// DiskTrie does without an index. Results are most specific first.
+func (probe lpmProbe) lookup(ip uint32) (vals [][]byte, bits []int) {
+	for m := 32; m >= 0; m-- {
+		mask := uint32(0)
+		if m > 0 {
+			mask = ^uint32(0) << (32 - m)
+		}
+		if v, ok := probe[[2]uint32{ip & mask, uint32(m)}]; ok {
+			vals = append(vals, v)
+			bits = append(bits, m)
+		}
+	}
+	return vals, bits
+}
+
+func TestLPMIndex_MatchesProbing(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	entries := randomLPMEntries(r, 2000)
+	idx := BuildLPMIndex(entries, 0)
+	probe := newLPMProbe(entries)
+
+	for i := 0; i < 20000; i++ {
+		ip := uint32(10)<<24 | uint32(r.Intn(1<<24))
+		if i%10 == 0 {
+			ip = r.Uint32()
+		}
+		wantVals, wantBits := probe.lookup(ip)
+
+		val, bits, ok := idx.Lookup(ip)
+		if !ok || !bytes.Equal(val, wantVals[0]) || bits != wantBits[0] {
+			t.Fatalf("Lookup(%#08x) = (%s, %d, %v), want (%s, %d)", ip, val, bits, ok, wantVals[0], wantBits[0])
+		}
+		all := idx.LookupAll(ip)
+		if len(all) != len(wantVals) {
+			t.Fatalf("LookupAll(%#08x) returned %d values, want %d", ip, len(all), len(wantVals))
+		}
+		for j := range all {
+			if !bytes.Equal(all[j], wantVals[j]) {
+				t.Fatalf("LookupAll(%#08x)[%d] = %s, want %s", ip, j, all[j], wantVals[j])
+			}
+		}
+	}
+}
+
+func TestLPMIndex_Empty(t *testing.T) {
+	idx := BuildLPMIndex(nil, 0)
+	if _, _, ok := idx.Lookup(0x01020304); ok {
+		t.Error("expected no match in an empty index")
+	}
+	if vals := idx.LookupAll(0x01020304); len(vals) != 0 {
+		t.Errorf("expected no values, got %d", len(vals))
+	}
+}
+
+func TestLPMIndex_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lpm")
+	entries := []LPMEntry{
+		{Addr: 0x01020000, Bits: 16, Value: []byte("wide")},
+		{Addr: 0x01020300, Bits: 24, Value: []byte("narrow")},
+	}
+	if err := BuildLPMIndex(entries, 42).WriteFile(path); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	idx, err := OpenLPMIndex(path)
+	if err != nil {
+		t.Fatalf("OpenLPMIndex: %v", err)
+	}
+	if idx.Version() != 42 || idx.Len() != 2 {
+		t.Errorf("unexpected header: version %d, len %d", idx.Version(), idx.Len())
+	}
+	if val, bits, _ := idx.Lookup(0x01020304); string(val) != "narrow" || bits != 24 {
+		t.Errorf("Lookup = (%s, %d), want (narrow, 24)", val, bits)
+	}
+	if vals := idx.LookupAll(0x01020304); len(vals) != 2 || string(vals[1]) != "wide" {
+		t.Errorf("unexpected LookupAll result: %q", vals)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, data[:len(data)-3], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenLPMIndex(path); err == nil {
+		t.Error("expected an error for a truncated snapshot")
+	}
+}
+
+func TestDiskTrie_Index(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	snapshot := filepath.Join(dir, "test.lpm")
+
+	trie, err := OpenDiskTrie(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DiskTrie: %v", err)
+	}
+	if err := trie.BatchInsert(map[string][]byte{"1.2.0.0/16": []byte("wide")}); err != nil {
+		t.Fatalf("BatchInsert: %v", err)
+	}
+	if err := trie.EnableIndex(snapshot); err != nil {
+		t.Fatalf("EnableIndex: %v", err)
+	}
+	if _, err := os.Stat(snapshot); err != nil {
+		t.Fatalf("expected a snapshot to be written: %v", err)
+	}
+
+	// Writes are visible through the rebuilt index
+	_, ipNet, _ := net.ParseCIDR("1.2.3.0/24")
+	if err := trie.Insert(ipNet, []byte("narrow")); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if val, mask, _ := trie.Lookup(net.ParseIP("1.2.3.4")); string(val) != "narrow" || mask != 24 {
+		t.Errorf("Lookup = (%s, %d), want (narrow, 24)", val, mask)
+	}
+	if vals, _ := trie.LookupAll(net.ParseIP("1.2.3.4")); len(vals) != 2 {
+		t.Errorf("expected 2 covering prefixes, got %d", len(vals))
+	}
+
+	if err := trie.ReplaceAll(map[string][]byte{"5.6.0.0/16": []byte("other")}); err != nil {
+		t.Fatalf("ReplaceAll: %v", err)
+	}
+	if val, _, _ := trie.LookupUint32(0x01020304); val != nil {
+		t.Errorf("expected replaced prefix to be gone, got %s", val)
+	}
+	if err := trie.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// A read-only open writes a snapshot that the next open maps instead of
+	// rebuilding the index
+	for i := 0; i < 2; i++ {
+		before, _ := os.Stat(snapshot)
+		trie, err = OpenDiskTrieReadOnly(dbPath)
+		if err != nil {
+			t.Fatalf("Failed to reopen DiskTrie: %v", err)
+		}
+		if err := trie.EnableIndex(snapshot); err != nil {
+			t.Fatalf("EnableIndex: %v", err)
+		}
+		if val, mask, _ := trie.LookupUint32(0x05060708); string(val) != "other" || mask != 16 {
+			t.Errorf("LookupUint32 = (%s, %d), want (other, 16)", val, mask)
+		}
+		if err := trie.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		after, _ := os.Stat(snapshot)
+		if i == 1 && !os.SameFile(before, after) {
+			t.Error("expected the snapshot to be reused")
+		}
+	}
+}
+
+func TestDiskTrie_IndexUnmapsRetiredSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	snapshot := filepath.Join(dir, "test.lpm")
+
+	trie, err := OpenDiskTrie(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open DiskTrie: %v", err)
+	}
+	if err := trie.BatchInsert(map[string][]byte{"1.2.0.0/16": []byte("wide")}); err != nil {
+		t.Fatalf("BatchInsert: %v", err)
+	}
+	// Reopen until the index is mapped from the snapshot instead of rebuilt
+	for i := 0; ; i++ {
+		if err := trie.EnableIndex(snapshot); err != nil {
+			t.Fatalf("EnableIndex: %v", err)
+		}
+		if trie.index.Load().mapped {
+			break
+		}
+		if i == 3 {
+			t.Fatal("expected the snapshot to be mapped")
+		}
+		if err := trie.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if trie, err = OpenDiskTrie(dbPath); err != nil {
+			t.Fatalf("Failed to reopen DiskTrie: %v", err)
+		}
+	}
+	defer func() { _ = trie.Close() }()
+
+	val, _, _ := trie.LookupUint32(0x01020304)
+	held := trie.acquireIndex()
+	if err := trie.BatchInsert(map[string][]byte{"1.2.3.0/24": []byte("narrow")}); err != nil {
+		t.Fatalf("BatchInsert: %v", err)
+	}
+	if held.closed.Load() {
+		t.Fatal("expected the retired snapshot to stay mapped while a lookup holds it")
+	}
+	held.release()
+	if !held.closed.Load() {
+		t.Error("expected the retired snapshot to be unmapped after the last lookup")
+	}
+	if string(val) != "wide" {
+		t.Errorf("expected a value from the retired snapshot to stay valid, got %q", val)
+	}
+	if val, mask, _ := trie.LookupUint32(0x01020304); string(val) != "narrow" || mask != 24 {
+		t.Errorf("LookupUint32 = (%s, %d), want (narrow, 24)", val, mask)
+	}
+}
+
+func TestDiskTrie_IndexBatchesSingleWrites(t *testing.T) {
+	trie, err := OpenDiskTrie(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open DiskTrie: %v", err)
+	}
+	defer func() { _ = trie.Close() }()
+	if err := trie.EnableIndex(""); err != nil {
+		t.Fatalf("EnableIndex: %v", err)
+	}
+	before := trie.index.Load()
+
+	for i := 1; i <= 3; i++ {
+		_, ipNet, _ := net.ParseCIDR(fmt.Sprintf("10.%d.0.0/16", i))
+		if err := trie.Insert(ipNet, []byte{byte(i)}); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+	if trie.index.Load() != before {
+		t.Fatal("expected single writes not to rebuild the index right away")
+	}
+	// The writes are served from the database until the rebuild
+	if val, mask, _ := trie.LookupUint32(0x0a020304); !bytes.Equal(val, []byte{2}) || mask != 16 {
+		t.Errorf("LookupUint32 = (%v, %d), want ([2], 16)", val, mask)
+	}
+	if err := trie.DeleteRaw([]byte{10, 3, 0, 0, 16}); err != nil {
+		t.Fatalf("DeleteRaw: %v", err)
+	}
+	if val, _, _ := trie.LookupUint32(0x0a030304); val != nil {
+		t.Errorf("expected deleted prefix to be gone, got %v", val)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for trie.staleWrites.Load() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("index was not rebuilt after the writes")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := trie.index.Load(); got == before || got.Len() != 2 {
+		t.Errorf("expected a rebuilt index with 2 prefixes")
+	}
+	if val, _, _ := trie.LookupUint32(0x0a010304); !bytes.Equal(val, []byte{1}) {
+		t.Errorf("LookupUint32 = %v, want [1]", val)
+	}
+}
@@ -2,13 +2,29 @@ package utils
 
 import (
 	"container/list"
+	"sync/atomic"
 )
 
-// LRUCache is a simple LRU cache. It is NOT thread-safe.
+// LRUCache is a simple LRU cache. It is NOT thread-safe, except for Stats.
 type LRUCache[K comparable, V any] struct {
 	capacity  int
 	items     map[K]*list.Element
 	evictList *list.List
+	onEvict   func(key K, value V)
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// CacheStats counts the lookups of a cache.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// Add returns the sum of s and o.
+func (s CacheStats) Add(o CacheStats) CacheStats {
+	return CacheStats{Hits: s.Hits + o.Hits, Misses: s.Misses + o.Misses}
 }
 
 type lruEntry[K comparable, V any] struct {
@@ -25,12 +41,35 @@ func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
 	}
 }
 
+// SetOnEvict registers a callback that is invoked whenever an item is evicted
+// to make room for a new one. It is not invoked by Clear.
+func (c *LRUCache[K, V]) SetOnEvict(fn func(key K, value V)) {
+	c.onEvict = fn
+}
+
 // Get looks up a key's value from the cache.
 func (c *LRUCache[K, V]) Get(key K) (V, bool) {
 	if ent, ok := c.items[key]; ok {
+		c.hits.Add(1)
 		c.evictList.MoveToFront(ent)
 		return ent.Value.(*lruEntry[K, V]).value, true
 	}
+	c.misses.Add(1)
+	var zero V
+	return zero, false
+}
+
+// Stats returns how many Get calls found their key and how many did not. Unlike
+// the other methods it may be called from any goroutine.
+func (c *LRUCache[K, V]) Stats() CacheStats {
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// Peek looks up a key's value without updating its recency.
+func (c *LRUCache[K, V]) Peek(key K) (V, bool) {
+	if ent, ok := c.items[key]; ok {
+		return ent.Value.(*lruEntry[K, V]).value, true
+	}
 	var zero V
 	return zero, false
 }
@@ -59,6 +98,20 @@ func (c *LRUCache[K, V]) removeOldest() {
 		c.evictList.Remove(ent)
 		kv := ent.Value.(*lruEntry[K, V])
 		delete(c.items, kv.key)
+		if c.onEvict != nil {
+			c.onEvict(kv.key, kv.value)
+		}
+	}
+}
+
+// Range calls fn for every item from the least to the most recently used,
+// without updating their recency, until fn returns false.
+func (c *LRUCache[K, V]) Range(fn func(key K, value V) bool) {
+	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
+		kv := ent.Value.(*lruEntry[K, V])
+		if !fn(kv.key, kv.value) {
+			return
+		}
 	}
 }
 
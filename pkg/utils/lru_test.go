@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -45,3 +46,81 @@ func TestLRUCache(t *testing.T) {
 		t.Errorf("Expected c=3, got %v, %v", val, ok)
 	}
 }
+
+func TestLRUCacheOnEvict(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	evicted := make(map[string]int)
+	cache.SetOnEvict(func(k string, v int) {
+		evicted[k] = v
+	})
+
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	if _, ok := cache.Peek("a"); !ok {
+		t.Errorf("Expected a to be present")
+	}
+	// Peek does not promote a, so it is still the oldest entry
+	cache.Add("c", 3)
+
+	if v, ok := evicted["a"]; !ok || v != 1 {
+		t.Errorf("Expected a=1 to be evicted, got %v", evicted)
+	}
+	if len(evicted) != 1 {
+		t.Errorf("Expected exactly one eviction, got %v", evicted)
+	}
+
+	cache.Clear()
+	if len(evicted) != 1 {
+		t.Errorf("Expected Clear not to invoke the eviction callback, got %v", evicted)
+	}
+}
+
+func TestLRUCacheStats(t *testing.T) {
+	cache := NewLRUCache[string, int](2)
+	cache.Add("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+	cache.Peek("b")
+
+	if got := cache.Stats(); got != (CacheStats{Hits: 2, Misses: 1}) {
+		t.Errorf("Expected 2 hits and 1 miss, got %+v", got)
+	}
+}
+
+func TestLRUCacheRange(t *testing.T) {
+	cache := NewLRUCache[string, int](3)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	cache.Add("c", 3)
+	cache.Get("a")
+
+	var keys []string
+	cache.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	if got := strings.Join(keys, ","); got != "b,c,a" {
+		t.Errorf("Expected b,c,a from least to most recent, got %s", got)
+	}
+
+	// Re-adding in Range order keeps the recency of the original
+	copied := NewLRUCache[string, int](3)
+	cache.Range(func(k string, v int) bool {
+		copied.Add(k, v)
+		return true
+	})
+	copied.Add("d", 4)
+	if _, ok := copied.Peek("b"); ok {
+		t.Errorf("Expected b to be evicted first from the copy")
+	}
+
+	keys = nil
+	cache.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		return false
+	})
+	if len(keys) != 1 {
+		t.Errorf("Expected Range to stop when fn returns false, got %v", keys)
+	}
+}
@@ -2,10 +2,13 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type RPKIStatus int
@@ -37,7 +40,10 @@ type VRP struct {
 }
 
 type RPKIManager struct {
-	trie *DiskTrie
+	trie     *DiskTrie
+	readOnly bool
+	// dataTime is when the VRPs of the last Sync were downloaded, in Unix nanoseconds
+	dataTime atomic.Int64
 }
 
 func NewRPKIManager(dbPath string) (*RPKIManager, error) {
@@ -45,14 +51,38 @@ func NewRPKIManager(dbPath string) (*RPKIManager, error) {
 	if err != nil {
 		return nil, err
 	}
+	// VRPs only change on Sync, so validation is served from memory
+	if err := trie.EnableIndex(""); err != nil {
+		_ = trie.Close()
+		return nil, err
+	}
 	return &RPKIManager{trie: trie}, nil
 }
 
+var errRPKIReadOnly = errors.New("the VRP database is open read-only")
+
+// NewRPKIManagerReadOnly opens the VRPs stored by another process's Sync for
+// validation only. Sync and Load fail on it.
+func NewRPKIManagerReadOnly(dbPath string) (*RPKIManager, error) {
+	trie, err := OpenDiskTrieReadOnly(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := trie.EnableIndex(""); err != nil {
+		_ = trie.Close()
+		return nil, err
+	}
+	return &RPKIManager{trie: trie, readOnly: true}, nil
+}
+
 func (m *RPKIManager) Close() error {
 	return m.trie.Close()
 }
 
 func (m *RPKIManager) Sync() error {
+	if m.readOnly {
+		return errRPKIReadOnly
+	}
 	// Using a reliable public VRP export
 	url := "https://console.rpki-client.org/vrps.json"
 	log.Printf("[RPKI] Syncing VRPs from %s", url)
@@ -102,21 +132,49 @@ func (m *RPKIManager) Sync() error {
 		vrpMap[raw.Prefix] = append(vrpMap[raw.Prefix], v)
 	}
 
-	encodedMap := make(map[string][]byte)
+	if err := m.trie.ReplaceAll(encodeVRPs(vrpMap)); err != nil {
+		return err
+	}
+	m.dataTime.Store(DataTime(r).UnixNano())
+
+	log.Printf("[RPKI] Loaded %d prefixes with ROAs", len(vrpMap))
+	return nil
+}
+
+// Load replaces the VRPs in use with a fixed set instead of the published
+// ones, such as the ROAs of a labeled incident.
+func (m *RPKIManager) Load(vrps []VRP) error {
+	if m.readOnly {
+		return errRPKIReadOnly
+	}
+	vrpMap := make(map[string][]VRP)
+	for _, v := range vrps {
+		vrpMap[v.Prefix] = append(vrpMap[v.Prefix], v)
+	}
+	return m.trie.ReplaceAll(encodeVRPs(vrpMap))
+}
+
+func encodeVRPs(vrpMap map[string][]VRP) map[string][]byte {
+	encodedMap := make(map[string][]byte, len(vrpMap))
 	for prefix, vrps := range vrpMap {
 		b, _ := json.Marshal(vrps)
 		encodedMap[prefix] = b
 	}
+	return encodedMap
+}
 
-	if err := m.trie.Clear(); err != nil {
-		return err
-	}
-	if err := m.trie.BatchInsert(encodedMap); err != nil {
-		return err
+// DataTime returns when the VRPs in use were downloaded, or the zero time
+// before the first successful Sync.
+func (m *RPKIManager) DataTime() time.Time {
+	if ns := m.dataTime.Load(); ns != 0 {
+		return time.Unix(0, ns)
 	}
+	return time.Time{}
+}
 
-	log.Printf("[RPKI] Loaded %d prefixes with ROAs", len(vrpMap))
-	return nil
+// TrieStats returns the lookups served by the VRP database.
+func (m *RPKIManager) TrieStats() DiskTrieStats {
+	return m.trie.Stats()
 }
 
 // SetVRPInTrie is a test helper to manually set VRP data.
@@ -167,6 +225,36 @@ func (m *RPKIManager) Validate(prefix string, originASN uint32) (RPKIStatus, err
 	return RPKIInvalidASN, nil
 }
 
+// CoveringVRPs returns the VRPs whose prefix covers prefix, most specific
+// first.
+func (m *RPKIManager) CoveringVRPs(prefix string) ([]VRP, error) {
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, err
+	}
+	ones, _ := ipNet.Mask.Size()
+
+	vals, err := m.trie.LookupAll(ipNet.IP)
+	if err != nil {
+		return nil, err
+	}
+	var covering []VRP
+	for _, val := range vals {
+		var vrps []VRP
+		if err := json.Unmarshal(val, &vrps); err != nil {
+			continue
+		}
+		for _, vrp := range vrps {
+			if _, vrpNet, err := net.ParseCIDR(vrp.Prefix); err == nil {
+				if bits, _ := vrpNet.Mask.Size(); bits <= ones {
+					covering = append(covering, vrp)
+				}
+			}
+		}
+	}
+	return covering, nil
+}
+
 func (m *RPKIManager) GetExpectedASN(prefix string) uint32 {
 	_, ipNet, err := net.ParseCIDR(prefix)
 	if err != nil {
@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -92,3 +93,90 @@ func TestRPKIManager_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestRPKIManager_Load(t *testing.T) {
+	m, err := NewRPKIManager(filepath.Join(t.TempDir(), "rpki.db"))
+	if err != nil {
+		t.Fatalf("Failed to create RPKIManager: %v", err)
+	}
+	defer func() {
+		_ = m.Close()
+	}()
+
+	if err := m.Load([]VRP{{Prefix: "1.1.0.0/16", MaxLength: 24, ASN: 100}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, _ := m.Validate("1.1.1.0/24", 999); got != RPKIInvalidASN {
+		t.Errorf("Validate() after Load got = %v, want %v", got, RPKIInvalidASN)
+	}
+
+	// A second Load replaces the first set
+	if err := m.Load([]VRP{{Prefix: "2.2.0.0/16", MaxLength: 24, ASN: 200}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got, _ := m.Validate("1.1.1.0/24", 999); got != RPKIUnknown {
+		t.Errorf("Validate() of replaced VRP got = %v, want %v", got, RPKIUnknown)
+	}
+	if got, _ := m.Validate("2.2.2.0/24", 200); got != RPKIValid {
+		t.Errorf("Validate() of loaded VRP got = %v, want %v", got, RPKIValid)
+	}
+}
+
+func TestNewRPKIManagerReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rpki.db")
+	m, err := NewRPKIManager(path)
+	if err != nil {
+		t.Fatalf("Failed to create RPKIManager: %v", err)
+	}
+	if err := m.Load([]VRP{{Prefix: "1.1.0.0/16", MaxLength: 24, ASN: 100}}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ro, err := NewRPKIManagerReadOnly(path)
+	if err != nil {
+		t.Fatalf("NewRPKIManagerReadOnly failed: %v", err)
+	}
+	defer func() {
+		_ = ro.Close()
+	}()
+	if got, _ := ro.Validate("1.1.1.0/24", 100); got != RPKIValid {
+		t.Errorf("Validate() got = %v, want %v", got, RPKIValid)
+	}
+	if err := ro.Load([]VRP{{Prefix: "2.2.0.0/16", MaxLength: 24, ASN: 200}}); err == nil {
+		t.Errorf("Expected Load to fail on a read-only manager")
+	}
+}
+
+func TestRPKIManager_CoveringVRPs(t *testing.T) {
+	m, err := NewRPKIManager(filepath.Join(t.TempDir(), "rpki.db"))
+	if err != nil {
+		t.Fatalf("Failed to create RPKIManager: %v", err)
+	}
+	defer func() {
+		_ = m.Close()
+	}()
+
+	if err := m.Load([]VRP{
+		{Prefix: "1.1.0.0/16", MaxLength: 24, ASN: 100},
+		{Prefix: "1.1.0.0/22", MaxLength: 22, ASN: 200},
+		{Prefix: "1.1.0.0/24", MaxLength: 24, ASN: 300},
+		{Prefix: "2.2.0.0/16", MaxLength: 24, ASN: 400},
+	}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	vrps, err := m.CoveringVRPs("1.1.0.0/22")
+	if err != nil {
+		t.Fatalf("CoveringVRPs failed: %v", err)
+	}
+	// The more-specific /24 starts at the same address but does not cover the /22
+	if len(vrps) != 2 || vrps[0].ASN != 200 || vrps[1].ASN != 100 {
+		t.Errorf("CoveringVRPs() got = %+v, want the /22 then the /16", vrps)
+	}
+	if vrps, _ := m.CoveringVRPs("3.3.3.0/24"); len(vrps) != 0 {
+		t.Errorf("CoveringVRPs() of an uncovered prefix got = %+v", vrps)
+	}
+}
@@ -257,6 +257,17 @@ func FindCachedURL(urls []string, logPrefix string) (string, bool) {
 }
 
 // GetCachedReader returns a reader for the given URL, using a local cache if enabled.
+// DataTime returns when the data behind a reader from GetCachedReader was
+// downloaded: the modification time of the cached file, or now when streaming.
+func DataTime(r io.Reader) time.Time {
+	if f, ok := r.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil {
+			return fi.ModTime()
+		}
+	}
+	return time.Now()
+}
+
 func GetCachedReader(urlStr string, useCache bool, logPrefix string) (io.ReadCloser, error) {
 	if useCache {
 		cacheDir := "./data/cache"
@@ -0,0 +1,341 @@
+package watchlist
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/api"
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+// Kind is what an alert is about.
+type Kind string
+
+const (
+	KindOriginChange   Kind = "origin_change"
+	KindRPKIInvalid    Kind = "rpki_invalid"
+	KindVisibilityLoss Kind = "visibility_loss"
+	KindNewUpstream    Kind = "new_upstream"
+	KindRouteLeak      Kind = "route_leak"
+)
+
+// Alert is sent to the notifiers as the JSON payload of a notify.Message.
+type Alert struct {
+	Time            time.Time `json:"time"`
+	Kind            Kind      `json:"kind"`
+	Prefix          string    `json:"prefix"`
+	WatchedPrefix   string    `json:"watched_prefix,omitempty"`
+	WatchedASN      uint32    `json:"watched_asn,omitempty"`
+	Description     string    `json:"description,omitempty"`
+	OriginASN       uint32    `json:"origin_asn,omitempty"`
+	ExpectedOrigins []uint32  `json:"expected_origins,omitempty"`
+	UpstreamASN     uint32    `json:"upstream_asn,omitempty"`
+	RPKI            string    `json:"rpki,omitempty"`
+	Visibility      float64   `json:"visibility,omitempty"`
+	Leak            *api.Leak `json:"leak,omitempty"`
+	Peer            string    `json:"peer,omitempty"`
+	Host            string    `json:"host,omitempty"`
+	Path            string    `json:"path,omitempty"`
+	Summary         string    `json:"summary"`
+}
+
+// alertQueueSize bounds the alerts waiting for the notifiers.
+const alertQueueSize = 256
+
+// Monitor checks processed messages and classification changes against a
+// watchlist. OnMessage and OnTransition may be called from any goroutine.
+type Monitor struct {
+	list       *Watchlist
+	asnMapping *utils.ASNMapping
+	now        bgp.TimeProvider
+	dispatcher *notify.Dispatcher
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+
+	// onAlert replaces delivery in tests
+	onAlert func(a *Alert)
+}
+
+// NewMonitor creates a monitor for list. asnMapping may be nil, in which case
+// alerts don't name networks.
+func NewMonitor(list *Watchlist, asnMapping *utils.ASNMapping, now bgp.TimeProvider) *Monitor {
+	return &Monitor{
+		list:       list,
+		asnMapping: asnMapping,
+		now:        now,
+		dispatcher: notify.NewDispatcher(list.Notifiers(), alertQueueSize),
+		sent:       make(map[string]time.Time),
+	}
+}
+
+// Run delivers alerts until ctx is canceled.
+func (m *Monitor) Run(ctx context.Context) {
+	m.dispatcher.Run(ctx)
+}
+
+// OnMessage checks the announcements of a processed message.
+func (m *Monitor) OnMessage(msg *bgp.EnrichedMessage) {
+	var hops [][]uint32
+	for i := range msg.Prefixes {
+		p := &msg.Prefixes[i]
+		if p.EventType == bgp.EventWithdrawal || p.ASN == 0 {
+			continue
+		}
+		pw := m.list.matchPrefix(p.Prefix)
+		aw := m.list.asns[p.ASN]
+		if pw == nil && aw == nil {
+			continue
+		}
+		if hops == nil {
+			hops = msg.Data.Hops()
+		}
+		base := Alert{
+			Prefix:    p.Prefix,
+			OriginASN: p.ASN,
+			Peer:      msg.Data.Peer,
+			Host:      msg.Data.Host,
+			Path:      formatPath(hops),
+		}
+
+		upstreamChecked := false
+		if pw != nil {
+			base.WatchedPrefix = pw.prefix.String()
+			base.Description = pw.Description
+			expected := len(pw.Origins) == 0 || slices.Contains(pw.Origins, p.ASN)
+			if !expected {
+				a := base
+				a.Kind = KindOriginChange
+				a.ExpectedOrigins = pw.Origins
+				a.Summary = fmt.Sprintf("%s is announced by %s instead of %s", p.Prefix, m.network(p.ASN), m.networks(pw.Origins))
+				m.alert(&a, p.Prefix, strconv.FormatUint(uint64(p.ASN), 10))
+			}
+			if p.RPKIStatus == utils.RPKIInvalidASN || p.RPKIStatus == utils.RPKIInvalidMaxLength {
+				a := base
+				a.Kind = KindRPKIInvalid
+				a.RPKI = p.RPKIStatus.String()
+				a.Summary = fmt.Sprintf("%s from %s is RPKI %s", p.Prefix, m.network(p.ASN), p.RPKIStatus)
+				m.alert(&a, p.Prefix, strconv.FormatUint(uint64(p.ASN), 10))
+			}
+			if expected && len(pw.Upstreams) > 0 {
+				upstreamChecked = true
+				if up := upstreamOf(hops, p.ASN); up != 0 && !slices.Contains(pw.Upstreams, up) {
+					a := base
+					a.Kind = KindNewUpstream
+					a.UpstreamASN = up
+					a.Summary = fmt.Sprintf("%s is seen through new upstream %s", p.Prefix, m.network(up))
+					m.alert(&a, pw.prefix.String(), strconv.FormatUint(uint64(up), 10))
+				}
+			}
+		}
+		if aw != nil && !upstreamChecked && len(aw.Upstreams) > 0 {
+			if up := upstreamOf(hops, p.ASN); up != 0 && !slices.Contains(aw.Upstreams, up) {
+				a := base
+				a.Kind = KindNewUpstream
+				a.WatchedASN = aw.ASN
+				a.UpstreamASN = up
+				if a.Description == "" {
+					a.Description = aw.Description
+				}
+				a.Summary = fmt.Sprintf("%s of %s is seen through new upstream %s", p.Prefix, m.network(aw.ASN), m.network(up))
+				m.alert(&a, "AS"+strconv.FormatUint(uint64(aw.ASN), 10), strconv.FormatUint(uint64(up), 10))
+			}
+		}
+	}
+}
+
+// OnTransition checks classification changes for outages and route leaks.
+func (m *Monitor) OnTransition(t *bgp.Transition) {
+	switch t.To {
+	case bgp.ClassificationOutage:
+		pw := m.list.matchPrefix(t.Prefix)
+		if pw == nil {
+			return
+		}
+		a := &Alert{
+			Kind:          KindVisibilityLoss,
+			Prefix:        t.Prefix,
+			WatchedPrefix: pw.prefix.String(),
+			Description:   pw.Description,
+			OriginASN:     t.OriginASN,
+			Peer:          t.Evidence.Peer,
+			Host:          t.Evidence.Host,
+		}
+		a.Summary = fmt.Sprintf("%s lost visibility", t.Prefix)
+		if t.LeakDetail != nil && t.LeakDetail.PeakVisibility > 0 {
+			a.Visibility = t.LeakDetail.Visibility
+			a.Summary = fmt.Sprintf("%s lost visibility (%.0f%% of peers, down from %.0f%%)", t.Prefix, t.LeakDetail.Visibility*100, t.LeakDetail.PeakVisibility*100)
+		}
+		m.alert(a, t.Prefix)
+	case bgp.ClassificationRouteLeak:
+		ld := t.LeakDetail
+		if ld == nil {
+			return
+		}
+		pw := m.list.matchPrefix(t.Prefix)
+		var aw *ASNWatch
+		for _, asn := range []uint32{ld.LeakerASN, ld.VictimASN, t.OriginASN} {
+			if aw = m.list.asns[asn]; aw != nil {
+				break
+			}
+		}
+		if pw == nil && aw == nil {
+			return
+		}
+		a := &Alert{
+			Kind:      KindRouteLeak,
+			Prefix:    t.Prefix,
+			OriginASN: t.OriginASN,
+			Leak:      api.NewLeak(ld),
+			Peer:      t.Evidence.Peer,
+			Host:      t.Evidence.Host,
+			Path:      t.Evidence.Path,
+		}
+		if pw != nil {
+			a.WatchedPrefix = pw.prefix.String()
+			a.Description = pw.Description
+		}
+		if aw != nil {
+			a.WatchedASN = aw.ASN
+			if a.Description == "" {
+				a.Description = aw.Description
+			}
+		}
+		a.Summary = fmt.Sprintf("%s is leaked by %s", t.Prefix, m.network(ld.LeakerASN))
+		if ld.VictimASN != 0 {
+			a.Summary += " at the expense of " + m.network(ld.VictimASN)
+		}
+		m.alert(a, t.Prefix, strconv.FormatUint(uint64(ld.LeakerASN), 10))
+	}
+}
+
+// alert sends a unless an alert with the same kind and key was sent within the
+// realert interval.
+func (m *Monitor) alert(a *Alert, key ...string) {
+	now := m.now()
+	id := string(a.Kind) + "|" + strings.Join(key, "|")
+	m.mu.Lock()
+	if last, ok := m.sent[id]; ok && now.Sub(last) < m.list.realertAfter {
+		m.mu.Unlock()
+		return
+	}
+	m.sent[id] = now
+	if len(m.sent) > 10000 {
+		for k, t := range m.sent {
+			if now.Sub(t) >= m.list.realertAfter {
+				delete(m.sent, k)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	a.Time = now.UTC()
+	log.Printf("[WATCH] %s", a.Summary)
+	if m.onAlert != nil {
+		m.onAlert(a)
+		return
+	}
+	m.dispatcher.Send(&notify.Message{Subject: a.Summary, Text: a.text(m.asnMapping), Payload: a})
+}
+
+// text describes the alert over several lines for email and chat.
+func (a *Alert) text(asnMapping *utils.ASNMapping) string {
+	var sb strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", label, value)
+		}
+	}
+	name := func(asn uint32) string {
+		if asn == 0 {
+			return ""
+		}
+		return networkName(asnMapping, asn)
+	}
+	line("Time", a.Time.Format(time.RFC3339))
+	line("Prefix", a.Prefix)
+	if a.WatchedPrefix != a.Prefix {
+		line("Watched prefix", a.WatchedPrefix)
+	}
+	line("Watched ASN", name(a.WatchedASN))
+	line("Description", a.Description)
+	line("Origin", name(a.OriginASN))
+	if len(a.ExpectedOrigins) > 0 {
+		names := make([]string, len(a.ExpectedOrigins))
+		for i, asn := range a.ExpectedOrigins {
+			names[i] = name(asn)
+		}
+		line("Expected origins", strings.Join(names, ", "))
+	}
+	line("Upstream", name(a.UpstreamASN))
+	line("RPKI", a.RPKI)
+	if l := a.Leak; l != nil {
+		line("Leak type", l.Type)
+		line("Leaker", name(l.LeakerASN))
+		line("Victim", name(l.VictimASN))
+	}
+	line("Path", a.Path)
+	if a.Peer != "" {
+		line("Seen by", a.Peer+" at "+a.Host)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (m *Monitor) network(asn uint32) string {
+	return networkName(m.asnMapping, asn)
+}
+
+func (m *Monitor) networks(asns []uint32) string {
+	names := make([]string, len(asns))
+	for i, asn := range asns {
+		names[i] = m.network(asn)
+	}
+	return strings.Join(names, " or ")
+}
+
+func networkName(asnMapping *utils.ASNMapping, asn uint32) string {
+	s := "AS" + strconv.FormatUint(uint64(asn), 10)
+	if name := api.NetworkName(asnMapping, asn); name != "" {
+		s += " (" + name + ")"
+	}
+	return s
+}
+
+// upstreamOf returns the neighbor of origin in an AS path, skipping prepends,
+// or 0 if there is none or it is an AS set.
+func upstreamOf(hops [][]uint32, origin uint32) uint32 {
+	for i := len(hops) - 1; i >= 0; i-- {
+		h := hops[i]
+		if len(h) == 1 && h[0] == origin {
+			continue
+		}
+		if len(h) != 1 || i == len(hops)-1 {
+			return 0
+		}
+		return h[0]
+	}
+	return 0
+}
+
+func formatPath(hops [][]uint32) string {
+	parts := make([]string, len(hops))
+	for i, h := range hops {
+		asns := make([]string, len(h))
+		for j, asn := range h {
+			asns[j] = strconv.FormatUint(uint64(asn), 10)
+		}
+		parts[i] = strings.Join(asns, ",")
+		if len(h) != 1 {
+			parts[i] = "{" + parts[i] + "}"
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,156 @@
+package watchlist
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/bgp"
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+	"github.com/sudorandom/bgp-stream/pkg/utils"
+)
+
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestMonitor(t *testing.T) (*Monitor, *[]*Alert, *time.Time) {
+	t.Helper()
+	w, err := New(&Config{
+		Prefixes: []PrefixWatch{{Prefix: "192.0.2.0/23", Description: "office", Origins: []uint32{64500}, Upstreams: []uint32{64501}}},
+		ASNs:     []ASNWatch{{ASN: 64510, Upstreams: []uint32{64511}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := testNow
+	m := NewMonitor(w, nil, func() time.Time { return now })
+	var alerts []*Alert
+	m.onAlert = func(a *Alert) { alerts = append(alerts, a) }
+	return m, &alerts, &now
+}
+
+func message(prefix string, origin uint32, rpki utils.RPKIStatus, path ...string) *bgp.EnrichedMessage {
+	raw := make([]json.RawMessage, len(path))
+	for i, p := range path {
+		raw[i] = json.RawMessage(p)
+	}
+	return &bgp.EnrichedMessage{
+		Data: &bgp.RISMessageData{Peer: "203.0.113.1", Host: "rrc00", Path: raw},
+		Prefixes: []bgp.EnrichedPrefix{{
+			PendingEvent: bgp.PendingEvent{Prefix: prefix, ASN: origin, EventType: bgp.EventUpdate},
+			RPKIStatus:   rpki,
+		}},
+	}
+}
+
+func TestMonitor_OnMessage(t *testing.T) {
+	m, alerts, now := newTestMonitor(t)
+
+	// Expected origin and upstream, another network's prefix
+	m.OnMessage(message("192.0.2.0/24", 64500, utils.RPKIValid, "3356", "64501", "64500"))
+	m.OnMessage(message("198.51.100.0/24", 64666, utils.RPKIInvalidASN, "3356", "64666"))
+	if len(*alerts) != 0 {
+		t.Fatalf("expected no alerts, got %+v", (*alerts)[0])
+	}
+
+	// A more-specific from another origin that is also RPKI invalid
+	m.OnMessage(message("192.0.3.0/25", 64666, utils.RPKIInvalidASN, "3356", "64666"))
+	if len(*alerts) != 2 || (*alerts)[0].Kind != KindOriginChange || (*alerts)[1].Kind != KindRPKIInvalid {
+		t.Fatalf("expected origin change and RPKI alerts, got %d", len(*alerts))
+	}
+	a := (*alerts)[0]
+	if a.WatchedPrefix != "192.0.2.0/23" || a.OriginASN != 64666 || a.Path != "3356 64666" || a.Description != "office" || !a.Time.Equal(testNow) {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+	if a.Summary != "192.0.3.0/25 is announced by AS64666 instead of AS64500" {
+		t.Errorf("unexpected summary %q", a.Summary)
+	}
+
+	// Repeats are suppressed until the realert interval has passed
+	m.OnMessage(message("192.0.3.0/25", 64666, utils.RPKIInvalidASN, "3356", "64666"))
+	if len(*alerts) != 2 {
+		t.Fatalf("expected repeats to be suppressed, got %d alerts", len(*alerts))
+	}
+	*now = now.Add(2 * time.Hour)
+	m.OnMessage(message("192.0.3.0/25", 64666, utils.RPKIValid, "3356", "64666"))
+	if len(*alerts) != 3 {
+		t.Fatalf("expected the alert to repeat, got %d alerts", len(*alerts))
+	}
+
+	// New upstreams, prepends skipped, for the prefix and for the watched ASN
+	m.OnMessage(message("192.0.2.0/24", 64500, utils.RPKIValid, "3356", "174", "64500", "64500"))
+	m.OnMessage(message("203.0.113.0/24", 64510, utils.RPKIValid, "3356", "64511", "64510"))
+	m.OnMessage(message("203.0.113.0/24", 64510, utils.RPKIValid, "3356", "1299", "64510"))
+	if len(*alerts) != 5 {
+		t.Fatalf("expected two upstream alerts, got %d alerts", len(*alerts))
+	}
+	if a := (*alerts)[3]; a.Kind != KindNewUpstream || a.UpstreamASN != 174 {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+	if a := (*alerts)[4]; a.Kind != KindNewUpstream || a.UpstreamASN != 1299 || a.WatchedASN != 64510 {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+}
+
+func TestMonitor_OnTransition(t *testing.T) {
+	m, alerts, _ := newTestMonitor(t)
+
+	m.OnTransition(&bgp.Transition{Prefix: "198.51.100.0/24", To: bgp.ClassificationOutage})
+	m.OnTransition(&bgp.Transition{Prefix: "192.0.2.0/24", To: bgp.ClassificationFlap})
+	m.OnTransition(&bgp.Transition{
+		Prefix:     "192.0.2.0/24",
+		To:         bgp.ClassificationOutage,
+		OriginASN:  64500,
+		LeakDetail: &bgp.LeakDetail{Visibility: 0.1, PeakVisibility: 0.9},
+	})
+	m.OnTransition(&bgp.Transition{
+		Prefix:     "198.51.100.0/24",
+		To:         bgp.ClassificationRouteLeak,
+		OriginASN:  64999,
+		LeakDetail: &bgp.LeakDetail{Type: bgp.LeakLateral, LeakerASN: 64510, VictimASN: 64999},
+	})
+	if len(*alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(*alerts))
+	}
+	if a := (*alerts)[0]; a.Kind != KindVisibilityLoss || a.Visibility != 0.1 || a.Summary != "192.0.2.0/24 lost visibility (10% of peers, down from 90%)" {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+	if a := (*alerts)[1]; a.Kind != KindRouteLeak || a.WatchedASN != 64510 || a.Leak.LeakerASN != 64510 {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+}
+
+func TestMonitor_Webhook(t *testing.T) {
+	got := make(chan Alert, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		_ = json.NewDecoder(r.Body).Decode(&a)
+		got <- a
+	}))
+	defer srv.Close()
+
+	w, err := New(&Config{
+		Prefixes:  []PrefixWatch{{Prefix: "192.0.2.0/24", Origins: []uint32{64500}}},
+		Notifiers: []notify.Config{{Type: "webhook", URL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMonitor(w, nil, func() time.Time { return testNow })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	m.OnMessage(message("192.0.2.0/24", 64666, utils.RPKIUnknown, "3356", "64666"))
+	select {
+	case a := <-got:
+		if a.Kind != KindOriginChange || a.OriginASN != 64666 || !strings.Contains(a.Summary, "AS64666") {
+			t.Errorf("unexpected alert: %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert was not delivered")
+	}
+}
@@ -0,0 +1,132 @@
+// Package watchlist alerts on routing changes that affect a network's own
+// prefixes and ASNs: unexpected origins, RPKI invalids, visibility loss, new
+// upstreams and route leaks.
+package watchlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+)
+
+// Config is the watchlist file.
+type Config struct {
+	Prefixes  []PrefixWatch   `json:"prefixes"`
+	ASNs      []ASNWatch      `json:"asns"`
+	Notifiers []notify.Config `json:"notifiers"`
+	// RealertAfter is how long an alert is suppressed after it was sent, as a
+	// Go duration. It defaults to one hour.
+	RealertAfter string `json:"realert_after,omitempty"`
+}
+
+// PrefixWatch is a monitored prefix. More-specifics are covered too unless
+// IgnoreMoreSpecifics is set. An empty Origins or Upstreams list disables the
+// corresponding check.
+type PrefixWatch struct {
+	Prefix              string   `json:"prefix"`
+	Description         string   `json:"description,omitempty"`
+	Origins             []uint32 `json:"origins,omitempty"`
+	Upstreams           []uint32 `json:"upstreams,omitempty"`
+	IgnoreMoreSpecifics bool     `json:"ignore_more_specifics,omitempty"`
+
+	prefix netip.Prefix
+}
+
+// ASNWatch is a monitored ASN. It alerts when the ASN is involved in a route
+// leak, and when one of its prefixes is seen through an upstream that is not
+// listed.
+type ASNWatch struct {
+	ASN         uint32   `json:"asn"`
+	Description string   `json:"description,omitempty"`
+	Upstreams   []uint32 `json:"upstreams,omitempty"`
+}
+
+// Watchlist is a parsed Config.
+type Watchlist struct {
+	prefixes     []*PrefixWatch
+	asns         map[uint32]*ASNWatch
+	notifiers    []notify.Notifier
+	realertAfter time.Duration
+}
+
+// Load reads a watchlist file.
+func Load(path string) (*Watchlist, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return New(&c)
+}
+
+// New validates c.
+func New(c *Config) (*Watchlist, error) {
+	w := &Watchlist{asns: make(map[uint32]*ASNWatch), realertAfter: time.Hour}
+	for i := range c.Prefixes {
+		pw := c.Prefixes[i]
+		p, err := netip.ParsePrefix(pw.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix %q: %v", pw.Prefix, err)
+		}
+		pw.prefix = p.Masked()
+		w.prefixes = append(w.prefixes, &pw)
+	}
+	// Most specific first, so lookups find the closest entry
+	slices.SortStableFunc(w.prefixes, func(a, b *PrefixWatch) int {
+		return b.prefix.Bits() - a.prefix.Bits()
+	})
+	for i := range c.ASNs {
+		aw := c.ASNs[i]
+		if aw.ASN == 0 {
+			return nil, fmt.Errorf("asns[%d]: asn is required", i)
+		}
+		w.asns[aw.ASN] = &aw
+	}
+	for _, nc := range c.Notifiers {
+		n, err := notify.New(nc)
+		if err != nil {
+			return nil, err
+		}
+		w.notifiers = append(w.notifiers, n)
+	}
+	if c.RealertAfter != "" {
+		d, err := time.ParseDuration(c.RealertAfter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid realert_after: %v", err)
+		}
+		w.realertAfter = d
+	}
+	return w, nil
+}
+
+// Notifiers returns the notifiers configured in the watchlist.
+func (w *Watchlist) Notifiers() []notify.Notifier {
+	return w.notifiers
+}
+
+// matchPrefix returns the closest watched prefix covering prefix, or nil.
+func (w *Watchlist) matchPrefix(prefix string) *PrefixWatch {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return nil
+	}
+	p = p.Masked()
+	for _, pw := range w.prefixes {
+		if p.Bits() < pw.prefix.Bits() || !pw.prefix.Contains(p.Addr()) {
+			continue
+		}
+		if pw.IgnoreMoreSpecifics && p.Bits() != pw.prefix.Bits() {
+			continue
+		}
+		return pw
+	}
+	return nil
+}
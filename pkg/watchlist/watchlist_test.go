@@ -0,0 +1,87 @@
+package watchlist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sudorandom/bgp-stream/pkg/notify"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchlist.json")
+	err := os.WriteFile(path, []byte(`{
+		"prefixes": [
+			{"prefix": "192.0.2.0/23", "origins": [64500], "upstreams": [64501]},
+			{"prefix": "192.0.2.128/25", "description": "anycast", "ignore_more_specifics": true}
+		],
+		"asns": [{"asn": 64500, "upstreams": [64501, 64502]}],
+		"notifiers": [{"type": "webhook", "url": "http://localhost:9000/hook"}],
+		"realert_after": "15m"
+	}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Notifiers()) != 1 || w.realertAfter != 15*time.Minute || w.asns[64500] == nil {
+		t.Errorf("unexpected watchlist: %+v", w)
+	}
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"192.0.2.0/23", "192.0.2.0/23"},
+		{"192.0.3.0/24", "192.0.2.0/23"},
+		{"192.0.2.128/25", "192.0.2.128/25"},
+		// More-specifics of the /25 are ignored, but still covered by the /23
+		{"192.0.2.192/26", "192.0.2.0/23"},
+		{"192.0.0.0/16", ""},
+		{"198.51.100.0/24", ""},
+		{"bogus", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if pw := w.matchPrefix(tt.prefix); pw != nil {
+			got = pw.prefix.String()
+		}
+		if got != tt.want {
+			t.Errorf("%s: matched %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, c := range []*Config{
+		{Prefixes: []PrefixWatch{{Prefix: "192.0.2.0"}}},
+		{ASNs: []ASNWatch{{Description: "no ASN"}}},
+		{Notifiers: []notify.Config{{Type: "pager"}}},
+		{RealertAfter: "soon"},
+	} {
+		if _, err := New(c); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+}
+
+func TestUpstreamOf(t *testing.T) {
+	tests := []struct {
+		hops [][]uint32
+		want uint32
+	}{
+		{[][]uint32{{1}, {2}, {3}}, 2},
+		{[][]uint32{{1}, {2}, {3}, {3}, {3}}, 2},
+		{[][]uint32{{3}}, 0},
+		{[][]uint32{{1}, {2, 4}, {3}}, 0},
+		{[][]uint32{{1}, {2}}, 0},
+	}
+	for _, tt := range tests {
+		if got := upstreamOf(tt.hops, 3); got != tt.want {
+			t.Errorf("%v: got %d, want %d", tt.hops, got, tt.want)
+		}
+	}
+}